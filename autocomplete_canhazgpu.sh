@@ -85,7 +85,7 @@ _canhazgpu_complete() {
             COMPREPLY=( $(compgen -W "--gpus --gpu-ids -g -G --duration -d --help" -- "$cur") )
             ;;
         release)
-            COMPREPLY=( $(compgen -W "--gpu-ids -G --help" -- "$cur") )
+            COMPREPLY=( $(compgen -W "--gpu-ids -G --all --kill --help" -- "$cur") )
             ;;
         run)
             COMPREPLY=( $(compgen -W "--gpus --gpu-ids -g -G --timeout -t --help --" -- "$cur") )
@@ -99,6 +99,12 @@ _canhazgpu_complete() {
         web)
             COMPREPLY=( $(compgen -W "--port --host --help" -- "$cur") )
             ;;
+        --gpu-ids|-G)
+            if [[ " ${words[*]} " == *" release "* ]]; then
+                # Offer the GPUs you hold, as looked up by canhazgpu itself
+                COMPREPLY=( $(compgen -W "$("${words[0]}" __complete release --gpu-ids "$cur" 2>/dev/null | grep -v '^:' | cut -f1)" -- "$cur") )
+            fi
+            ;;
         --duration)
             COMPREPLY=( $(compgen -W "30m 1h 2h 4h 8h 1d 2d" -- "$cur") )
             ;;
@@ -1,8 +1,14 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/russellb/canhazgpu/internal/gpu"
 	"github.com/russellb/canhazgpu/internal/redis_client"
@@ -16,33 +22,140 @@ var adminCmd = &cobra.Command{
 	Long: `Initialize the GPU pool by setting the number of GPUs available on this machine.
 This must be run once before using other commands.
 
-Use --force to reinitialize an existing pool (this will clear all reservations).`,
+Use --force to reinitialize an existing pool (this will clear all reservations).
+If any GPUs are reserved, it shows the affected reservations and users and
+asks for confirmation first. Pass --yes to skip the prompt, e.g. in scripts.
+
+Use --export to save the pool state (GPU count, provider, reservations, and
+queue) to a file, for example before Redis maintenance, and --import to
+restore it, possibly into a different Redis instance. Importing into an
+initialized pool requires --force and a matching GPU count. Usage history is
+not included.
+
+Use --set to change a pool-wide setting stored in Redis, shared by every host
+using the pool, --unset to restore its default, and --list to show the
+current settings. Available settings:
+  heartbeat-timeout  How long a run reservation may go without a heartbeat
+                     before it is released (default 5m, 2m to 24h)
+
+Use --init-from-smi instead of --gpus to count the GPUs with nvidia-smi or
+amd-smi and initialize the pool with all of them. Like --gpus, it refuses
+to overwrite an initialized pool without --force.
+
+Use --provider without --gpus to switch an initialized pool to another GPU
+provider, e.g. after it was auto-migrated to nvidia on an AMD host.
+Reservations are kept. The provider's tool (nvidia-smi or amd-smi) must be
+present, and it warns if the provider doesn't match the detected hardware.
+
+Use --reset-usage-history to delete all usage history records, e.g. after
+tests or for privacy requests. GPU reservations are not affected. It asks
+for confirmation first unless --yes is given.
+
+Use --annotate with --gpu-ids to leave a note on reservations, e.g. after
+contacting their owner. The note is shown next to the reservation in status
+and the web dashboard, and is dropped when the GPU is released. Pass an empty
+note ("") to remove it.
+
+Example usage:
+  canhazgpu admin --gpus 8
+  canhazgpu admin --init-from-smi
+  canhazgpu admin --provider amd
+  canhazgpu admin --export state.json
+  canhazgpu admin --import state.json --force
+  canhazgpu admin --set heartbeat-timeout 10m
+  canhazgpu admin --list
+  canhazgpu admin --reset-usage-history --yes
+  canhazgpu admin --annotate --gpu-ids 3 "contacted owner, will expire tonight"`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		gpuCount := viper.GetInt("admin.gpus")
 		force := viper.GetBool("admin.force")
+		yes := viper.GetBool("admin.yes")
 		provider := viper.GetString("admin.provider")
+		exportPath := viper.GetString("admin.export")
+		importPath := viper.GetString("admin.import")
+		set := viper.GetString("admin.set")
+		unset := viper.GetString("admin.unset")
+		list := viper.GetBool("admin.list")
+		resetUsageHistory := viper.GetBool("admin.reset-usage-history")
+		initFromSMI := viper.GetBool("admin.init-from-smi")
+		annotate := viper.GetBool("admin.annotate")
+		gpuIDs := viper.GetIntSlice("admin.gpu-ids")
+
+		if annotate {
+			if len(args) != 1 {
+				return fmt.Errorf("--annotate requires the annotation text (use \"\" to clear it)")
+			}
+			return runAdminAnnotate(cmd.Context(), gpuIDs, args[0])
+		}
+		if set != "" {
+			name, value, err := parseSetArgs(set, args)
+			if err != nil {
+				return err
+			}
+			return runAdminSet(cmd.Context(), name, value)
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected argument '%s'", args[0])
+		}
+		if unset != "" {
+			return runAdminUnset(cmd.Context(), unset)
+		}
+		if list {
+			return runAdminList(cmd.Context())
+		}
+		if resetUsageHistory {
+			return runAdminResetUsageHistory(cmd.Context(), yes)
+		}
+		if exportPath != "" {
+			return runAdminExport(cmd.Context(), exportPath)
+		}
+		if importPath != "" {
+			return runAdminImport(cmd.Context(), importPath, force)
+		}
+
+		if initFromSMI {
+			return runAdmin(cmd.Context(), 0, force, yes, provider, true)
+		}
+
+		if provider != "" && !cmd.Flags().Changed("gpus") {
+			return runAdminSetProvider(cmd.Context(), provider)
+		}
 
 		if gpuCount <= 0 {
 			return fmt.Errorf("GPU count must be greater than 0")
 		}
 
-		return runAdmin(cmd.Context(), gpuCount, force, provider)
+		return runAdmin(cmd.Context(), gpuCount, force, yes, provider, false)
 	},
 }
 
 func init() {
 	adminCmd.Flags().IntP("gpus", "g", 0, "Number of GPUs available on this machine (required)")
-	adminCmd.Flags().Bool("force", false, "Force reinitialization even if already initialized")
-	adminCmd.Flags().StringP("provider", "p", "", "GPU provider to use (nvidia, amd, or fake). If not specified, auto-detect available provider. Use 'fake' for development/testing without real GPUs")
-	if err := adminCmd.MarkFlagRequired("gpus"); err != nil {
-		// This should not happen in practice, but handle it
-		panic(fmt.Sprintf("Failed to mark gpus flag as required: %v", err))
-	}
+	adminCmd.Flags().Bool("init-from-smi", false, "Initialize the pool with the number of GPUs detected by nvidia-smi or amd-smi, instead of --gpus")
+	adminCmd.Flags().Bool("force", false, "Force reinitialization (or --import) even if already initialized")
+	adminCmd.Flags().BoolP("yes", "y", false, "Clear active reservations with --force, or usage history with --reset-usage-history, without asking for confirmation")
+	adminCmd.Flags().StringP("provider", "p", "", "GPU provider to use (nvidia, amd, or fake). If not specified, auto-detect available provider. Use 'fake' for development/testing without real GPUs. Without --gpus, switches the provider of an initialized pool")
+	adminCmd.Flags().String("export", "", "Export the pool state (reservations and queue) to a JSON file")
+	adminCmd.Flags().String("import", "", "Restore the pool state from a JSON file written by --export")
+	adminCmd.Flags().String("set", "", "Set a pool-wide setting shared by all hosts (e.g. --set heartbeat-timeout 10m)")
+	adminCmd.Flags().String("unset", "", "Restore a pool-wide setting to its default")
+	adminCmd.Flags().Bool("list", false, "List the pool-wide settings")
+	adminCmd.Flags().Bool("reset-usage-history", false, "Delete all usage history records (GPU reservations are kept)")
+	adminCmd.Flags().Bool("annotate", false, "Leave a note on the reservations of --gpu-ids, shown in status (\"\" clears it)")
+	adminCmd.Flags().IntSliceP("gpu-ids", "G", nil, "GPU IDs to annotate with --annotate (comma-separated)")
+	adminCmd.MarkFlagsOneRequired("gpus", "init-from-smi", "provider", "export", "import", "set", "unset", "list", "reset-usage-history", "annotate")
+	adminCmd.MarkFlagsMutuallyExclusive("gpus", "init-from-smi", "export", "import", "set", "unset", "list", "reset-usage-history", "annotate")
+	adminCmd.MarkFlagsMutuallyExclusive("init-from-smi", "export", "import", "set", "unset", "list", "reset-usage-history", "annotate")
+	adminCmd.MarkFlagsMutuallyExclusive("provider", "export", "import", "set", "unset", "list", "reset-usage-history", "annotate")
+	adminCmd.MarkFlagsRequiredTogether("annotate", "gpu-ids")
 
 	rootCmd.AddCommand(adminCmd)
 }
 
-func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider string) error {
+// runAdmin initializes the pool with gpuCount GPUs or, if detectCount is
+// set, with as many as the provider's SMI tool finds
+func runAdmin(ctx context.Context, gpuCount int, force, yes bool, explicitProvider string, detectCount bool) error {
 	config := getConfig()
 	client := redis_client.NewClient(config)
 	defer func() {
@@ -56,8 +169,17 @@ func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider st
 		return fmt.Errorf("failed to connect to Redis: %v", err)
 	}
 
+	// A configured nvidia_smi_path or amd_smi_path that doesn't exist would
+	// otherwise only show up as the provider being unavailable
+	if explicitProvider != "fake" {
+		if err := gpu.ValidateSMIPaths(config); err != nil {
+			return err
+		}
+	}
+
 	// Determine which provider to use
 	var providerName string
+	var selected gpu.GPUProvider
 	if explicitProvider != "" {
 		// Use explicitly specified provider
 		fmt.Printf("Using explicitly specified GPU provider: %s\n", explicitProvider)
@@ -73,13 +195,14 @@ func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider st
 			providerName = explicitProvider
 		} else {
 			// Validate that the specified provider is available
-			pm := gpu.NewProviderManager()
+			pm := gpu.NewProviderManager(config)
 			availableProviders := pm.GetAvailableProviders()
 
 			available := false
 			for _, provider := range availableProviders {
 				if provider.Name() == explicitProvider {
 					available = true
+					selected = provider
 					break
 				}
 			}
@@ -93,7 +216,7 @@ func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider st
 	} else {
 		// Auto-detect available provider
 		fmt.Print("Detecting available GPU provider... ")
-		pm := gpu.NewProviderManager()
+		pm := gpu.NewProviderManager(config)
 		availableProviders := pm.GetAvailableProviders()
 
 		if len(availableProviders) == 0 {
@@ -108,18 +231,36 @@ func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider st
 			return fmt.Errorf("multiple GPU providers detected: %v. Please specify one with --provider", names)
 		}
 
-		providerName = availableProviders[0].Name()
+		selected = availableProviders[0]
+		providerName = selected.Name()
 		fmt.Printf("found %s\n", providerName)
 	}
 
+	if detectCount {
+		if selected == nil {
+			return fmt.Errorf("--init-from-smi can't count fake GPUs. Use --gpus to choose how many")
+		}
+		count, err := countProviderGPUs(ctx, selected)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Detected %d %s GPU(s)\n", count, providerName)
+		gpuCount = count
+	}
+
 	// Check if already initialized
 	existingCount, err := client.GetGPUCount(ctx)
 	if err == nil && !force {
-		return fmt.Errorf("GPU pool already initialized with %d GPUs. Use --force to reinitialize", existingCount)
+		return fmt.Errorf("GPU pool already initialized with %d GPUs%s. Use --force to reinitialize", existingCount, poolLabel(poolName))
 	}
 
 	// Clear existing state if force is used
 	if force && err == nil {
+		if !yes {
+			if err := confirmForceReset(ctx, client, existingCount); err != nil {
+				return err
+			}
+		}
 		fmt.Printf("Releasing all GPUs: admin force reset (clearing %d existing GPUs)\n", existingCount)
 		if err := client.ClearAllGPUStates(ctx); err != nil {
 			return fmt.Errorf("failed to clear existing GPU states: %v", err)
@@ -131,16 +272,178 @@ func runAdmin(ctx context.Context, gpuCount int, force bool, explicitProvider st
 		return fmt.Errorf("failed to set GPU count: %v", err)
 	}
 
+	// Record the boot ID so reservations left over from before a reboot can
+	// be recognized
+	if bootID, err := gpu.HostBootID(); err == nil {
+		if _, err := client.SwapBootID(ctx, bootID); err != nil {
+			fmt.Printf("Warning: failed to record boot ID: %v\n", err)
+		}
+	}
+
 	// Store available provider
 	if err := client.SetAvailableProvider(ctx, providerName); err != nil {
 		return fmt.Errorf("failed to store provider information: %v", err)
 	}
 
 	if force && existingCount > 0 {
-		fmt.Printf("Reinitialized %d GPUs (IDs 0 to %d)\n", gpuCount, gpuCount-1)
+		fmt.Printf("Reinitialized %d GPUs (IDs 0 to %d)%s\n", gpuCount, gpuCount-1, poolLabel(poolName))
 	} else {
-		fmt.Printf("Initialized %d GPUs (IDs 0 to %d)\n", gpuCount, gpuCount-1)
+		fmt.Printf("Initialized %d GPUs (IDs 0 to %d)%s\n", gpuCount, gpuCount-1, poolLabel(poolName))
+	}
+
+	return nil
+}
+
+// countProviderGPUs counts the GPUs of the provider for 'admin
+// --init-from-smi'
+func countProviderGPUs(ctx context.Context, provider gpu.GPUProvider) (int, error) {
+	count, err := provider.GetGPUCount(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count GPUs: %v", err)
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no GPUs detected by the %s provider", provider.Name())
+	}
+	return count, nil
+}
+
+// activeReservation is a reservation that 'admin --force' would clear
+type activeReservation struct {
+	GPUID int
+	User  string
+}
+
+// activeReservations returns the reservations held on the pool's GPUs, one
+// per holder of a shared GPU
+func activeReservations(ctx context.Context, client *redis_client.Client, gpuCount int) ([]activeReservation, error) {
+	var reservations []activeReservation
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		if state.IsShared() {
+			for _, share := range state.Shares {
+				reservations = append(reservations, activeReservation{gpuID, share.User})
+			}
+		} else if state.User != "" {
+			reservations = append(reservations, activeReservation{gpuID, state.User})
+		}
+	}
+	return reservations, nil
+}
+
+// forceResetSummary describes the reservations 'admin --force' would clear,
+// e.g. "3 active reservation(s) on GPU(s) 0, 1, 4 held by alice, bob"
+func forceResetSummary(reservations []activeReservation) string {
+	var gpuIDs []string
+	var users []string
+	seenGPU := make(map[int]bool)
+	seenUser := make(map[string]bool)
+	for _, r := range reservations {
+		if !seenGPU[r.GPUID] {
+			seenGPU[r.GPUID] = true
+			gpuIDs = append(gpuIDs, strconv.Itoa(r.GPUID))
+		}
+		if !seenUser[r.User] {
+			seenUser[r.User] = true
+			users = append(users, r.User)
+		}
+	}
+	sort.Strings(users)
+
+	return fmt.Sprintf("%d active reservation(s) on GPU(s) %s held by %s",
+		len(reservations), strings.Join(gpuIDs, ", "), strings.Join(users, ", "))
+}
+
+// confirmForceReset asks before 'admin --force' clears active reservations.
+// It fails without asking when stdin isn't a terminal, so that scripts must
+// pass --yes to clear reservations.
+func confirmForceReset(ctx context.Context, client *redis_client.Client, gpuCount int) error {
+	reservations, err := activeReservations(ctx, client, gpuCount)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
 	}
 
+	summary := forceResetSummary(reservations)
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("refusing to clear %s without confirmation. Use --yes to clear them", summary)
+	}
+
+	confirmed, err := promptYesNo(os.Stdin, os.Stdout, fmt.Sprintf("This will clear %s.\nContinue?", summary))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return fmt.Errorf("aborted: GPU pool not reinitialized")
+	}
 	return nil
 }
+
+// runAdminResetUsageHistory deletes the usage history after asking for
+// confirmation, which like 'admin --force' requires --yes when stdin isn't a
+// terminal
+func runAdminResetUsageHistory(ctx context.Context, yes bool) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	if !yes {
+		count, err := client.CountUsageHistory(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count usage history: %v", err)
+		}
+		if count == 0 {
+			fmt.Println("No usage history records to delete")
+			return nil
+		}
+
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("refusing to delete %d usage history record(s) without confirmation. Use --yes to delete them", count)
+		}
+		confirmed, err := promptYesNo(os.Stdin, os.Stdout, fmt.Sprintf("This will permanently delete %d usage history record(s).\nContinue?", count))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("aborted: usage history not deleted")
+		}
+	}
+
+	deleted, err := client.ClearUsageHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete usage history: %v", err)
+	}
+	fmt.Printf("Deleted %d usage history record(s)\n", deleted)
+	return nil
+}
+
+// promptYesNo writes question to out and reads the answer from in. Only "y"
+// and "yes" count as yes.
+func promptYesNo(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read answer: %v", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
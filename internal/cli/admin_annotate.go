@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+)
+
+// runAdminAnnotate leaves an annotation on the reservations of the given
+// GPUs, e.g. "contacted owner, will expire tonight", or clears it if text is
+// empty
+func runAdminAnnotate(ctx context.Context, gpuIDs []int, text string) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	annotation := strings.TrimSpace(text)
+	engine := gpu.NewAllocationEngine(client, config)
+	if err := engine.AnnotateGPUs(ctx, gpuIDs, annotation, getCurrentUser()); err != nil {
+		return err
+	}
+
+	if annotation == "" {
+		fmt.Printf("Cleared annotation on GPU(s) %v\n", gpuIDs)
+	} else {
+		fmt.Printf("Annotated GPU(s) %v\n", gpuIDs)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+)
+
+// runAdminSetProvider switches an initialized pool to another GPU provider,
+// e.g. to correct a pool that was auto-migrated to nvidia. Reservations are
+// kept. The provider's tool must be present on this host; mismatches with the
+// detected hardware are reported as warnings.
+func runAdminSetProvider(ctx context.Context, providerName string) error {
+	if providerName != "nvidia" && providerName != "amd" && providerName != "fake" {
+		return invalidArgument(fmt.Errorf("invalid provider '%s'. Valid providers are: nvidia, amd, fake", providerName))
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	poolCount, err := client.GetGPUCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	if providerName != "fake" {
+		if err := gpu.ValidateSMIPaths(config); err != nil {
+			return err
+		}
+	}
+
+	var detected []string
+	detectedCount := -1
+	for _, provider := range gpu.NewProviderManager(config).GetAvailableProviders() {
+		detected = append(detected, provider.Name())
+		if provider.Name() != providerName {
+			continue
+		}
+		count, err := provider.GetGPUCount(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to count %s GPUs: %v\n", providerName, err)
+			continue
+		}
+		detectedCount = count
+	}
+
+	if providerName != "fake" && !slices.Contains(detected, providerName) {
+		return fmt.Errorf("provider '%s' is not available on this system", providerName)
+	}
+	for _, warning := range providerWarnings(providerName, detected, poolCount, detectedCount) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	current, err := client.GetAvailableProvider(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current provider: %v", err)
+	}
+	if current == providerName {
+		fmt.Printf("GPU provider is already %s\n", providerName)
+		return nil
+	}
+
+	if err := client.SetAvailableProvider(ctx, providerName); err != nil {
+		return fmt.Errorf("failed to store provider information: %v", err)
+	}
+	fmt.Printf("GPU provider changed from %s to %s\n", current, providerName)
+	return nil
+}
+
+// providerWarnings describes how switching a pool of poolCount GPUs to
+// providerName conflicts with the hardware on this host: detected lists the
+// providers whose tools were found, and detectedCount is the number of GPUs
+// providerName reports, or -1 if unknown
+func providerWarnings(providerName string, detected []string, poolCount, detectedCount int) []string {
+	var warnings []string
+	for _, name := range detected {
+		if name == providerName {
+			continue
+		}
+		if providerName == "fake" {
+			warnings = append(warnings, fmt.Sprintf("%s GPUs were detected on this host, but the pool will use fake GPUs", name))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s GPUs were also detected on this host", name))
+		}
+	}
+	if detectedCount >= 0 && detectedCount != poolCount {
+		warnings = append(warnings, fmt.Sprintf("%s reports %d GPU(s), but the pool has %d. Use 'canhazgpu admin --gpus %d --provider %s --force' to resize it",
+			providerName, detectedCount, poolCount, detectedCount, providerName))
+	}
+	return warnings
+}
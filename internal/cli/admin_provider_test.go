@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderWarnings(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      string
+		detected      []string
+		poolCount     int
+		detectedCount int
+		want          []string
+	}{
+		{
+			name:          "matches hardware",
+			provider:      "amd",
+			detected:      []string{"amd"},
+			poolCount:     8,
+			detectedCount: 8,
+			want:          nil,
+		},
+		{
+			name:          "other provider detected",
+			provider:      "amd",
+			detected:      []string{"nvidia", "amd"},
+			poolCount:     8,
+			detectedCount: 8,
+			want:          []string{"nvidia GPUs were also detected on this host"},
+		},
+		{
+			name:          "count mismatch",
+			provider:      "nvidia",
+			detected:      []string{"nvidia"},
+			poolCount:     8,
+			detectedCount: 4,
+			want: []string{
+				"nvidia reports 4 GPU(s), but the pool has 8. Use 'canhazgpu admin --gpus 4 --provider nvidia --force' to resize it",
+			},
+		},
+		{
+			name:          "unknown count",
+			provider:      "nvidia",
+			detected:      []string{"nvidia"},
+			poolCount:     8,
+			detectedCount: -1,
+			want:          nil,
+		},
+		{
+			name:          "fake on real hardware",
+			provider:      "fake",
+			detected:      []string{"nvidia"},
+			poolCount:     4,
+			detectedCount: -1,
+			want:          []string{"nvidia GPUs were detected on this host, but the pool will use fake GPUs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, providerWarnings(tt.provider, tt.detected, tt.poolCount, tt.detectedCount))
+		})
+	}
+}
+
+func TestCountProviderGPUs(t *testing.T) {
+	count, err := countProviderGPUs(context.Background(), gpu.NewFakeProvider(4))
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	_, err = countProviderGPUs(context.Background(), gpu.NewFakeProvider(0))
+	assert.ErrorContains(t, err, "no GPUs detected by the fake provider")
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+)
+
+// poolSetting describes a pool-wide setting managed with 'admin --set'.
+// Settings are stored in Redis, so every host using the pool shares them.
+type poolSetting struct {
+	name        string
+	description string
+	// normalize validates a value given on the command line and returns it
+	// in the form stored in Redis
+	normalize func(value string) (string, error)
+	// format returns a stored value in human readable form
+	format func(stored string) string
+	// defaultValue is shown when the setting isn't set
+	defaultValue string
+}
+
+var poolSettings = []poolSetting{
+	{
+		name:         types.SettingHeartbeatTimeout,
+		description:  "Run reservations without a heartbeat for this long are released",
+		normalize:    normalizeHeartbeatTimeout,
+		format:       formatSecondsSetting,
+		defaultValue: utils.FormatDuration(types.HeartbeatTimeout),
+	},
+}
+
+// findPoolSetting looks up a pool setting by name
+func findPoolSetting(name string) (*poolSetting, error) {
+	var names []string
+	for i := range poolSettings {
+		if poolSettings[i].name == name {
+			return &poolSettings[i], nil
+		}
+		names = append(names, poolSettings[i].name)
+	}
+	return nil, fmt.Errorf("unknown setting '%s'. Valid settings are: %s", name, strings.Join(names, ", "))
+}
+
+// parseSetArgs splits the --set flag and any positional argument into a
+// setting name and value. Both "name=value" and "name value" are accepted.
+func parseSetArgs(flag string, args []string) (string, string, error) {
+	if name, value, found := strings.Cut(flag, "="); found {
+		if len(args) > 0 {
+			return "", "", fmt.Errorf("unexpected argument '%s'", args[0])
+		}
+		return strings.TrimSpace(name), strings.TrimSpace(value), nil
+	}
+	if len(args) != 1 {
+		return "", "", fmt.Errorf("--set requires a value, e.g. --set %s 10m", flag)
+	}
+	return strings.TrimSpace(flag), strings.TrimSpace(args[0]), nil
+}
+
+// normalizeHeartbeatTimeout parses a duration and stores it as seconds, which
+// the allocation scripts read directly
+func normalizeHeartbeatTimeout(value string) (string, error) {
+	timeout, err := utils.ParseDuration(value)
+	if err != nil {
+		return "", err
+	}
+	if err := types.ValidateHeartbeatTimeout(timeout); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(int(timeout / time.Second)), nil
+}
+
+// formatSecondsSetting formats a setting stored as a number of seconds
+func formatSecondsSetting(stored string) string {
+	seconds, err := strconv.Atoi(stored)
+	if err != nil {
+		return fmt.Sprintf("%q (invalid)", stored)
+	}
+	return utils.FormatDuration(time.Duration(seconds) * time.Second)
+}
+
+func runAdminSet(ctx context.Context, name, value string) error {
+	setting, err := findPoolSetting(name)
+	if err != nil {
+		return err
+	}
+	stored, err := setting.normalize(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %v", name, err)
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	if err := client.SetPoolSetting(ctx, setting.name, stored); err != nil {
+		return fmt.Errorf("failed to store %s: %v", setting.name, err)
+	}
+
+	fmt.Printf("Set %s to %s for all hosts using this pool\n", setting.name, setting.format(stored))
+	return nil
+}
+
+func runAdminUnset(ctx context.Context, name string) error {
+	setting, err := findPoolSetting(name)
+	if err != nil {
+		return err
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	if err := client.UnsetPoolSetting(ctx, setting.name); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", setting.name, err)
+	}
+
+	fmt.Printf("Reset %s to the default (%s)\n", setting.name, setting.defaultValue)
+	return nil
+}
+
+func runAdminList(ctx context.Context) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	stored, err := client.GetPoolSettings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get pool settings: %v", err)
+	}
+
+	printPoolSettings(os.Stdout, stored)
+	return nil
+}
+
+// printPoolSettings writes each known pool setting with its current value
+func printPoolSettings(w io.Writer, stored map[string]string) {
+	_, _ = fmt.Fprintln(w, "Pool settings:")
+	for _, setting := range poolSettings {
+		value := setting.defaultValue + " (default)"
+		if raw, ok := stored[setting.name]; ok {
+			value = setting.format(raw)
+		}
+		_, _ = fmt.Fprintf(w, "  %-20s %-20s %s\n", setting.name, value, setting.description)
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSetArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		flag      string
+		args      []string
+		wantName  string
+		wantValue string
+		wantErr   string
+	}{
+		{"separate value", "heartbeat-timeout", []string{"10m"}, "heartbeat-timeout", "10m", ""},
+		{"equals", "heartbeat-timeout=10m", nil, "heartbeat-timeout", "10m", ""},
+		{"missing value", "heartbeat-timeout", nil, "", "", "--set requires a value"},
+		{"value given twice", "heartbeat-timeout=10m", []string{"5m"}, "", "", "unexpected argument '5m'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, value, err := parseSetArgs(tt.flag, tt.args)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantValue, value)
+		})
+	}
+}
+
+func TestPoolSettings(t *testing.T) {
+	_, err := findPoolSetting("heartbeat-interval")
+	assert.ErrorContains(t, err, "Valid settings are: heartbeat-timeout")
+
+	setting, err := findPoolSetting(types.SettingHeartbeatTimeout)
+	require.NoError(t, err)
+
+	stored, err := setting.normalize("10m")
+	require.NoError(t, err)
+	assert.Equal(t, "600", stored)
+	assert.Equal(t, "0h 10m 0s", setting.format(stored))
+
+	for _, invalid := range []string{"1m", "48h", "soon"} {
+		_, err := setting.normalize(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPrintPoolSettings(t *testing.T) {
+	var buf bytes.Buffer
+	printPoolSettings(&buf, map[string]string{})
+	assert.Contains(t, buf.String(), "heartbeat-timeout")
+	assert.Contains(t, buf.String(), "0h 5m 0s (default)")
+
+	buf.Reset()
+	printPoolSettings(&buf, map[string]string{types.SettingHeartbeatTimeout: "900"})
+	assert.Contains(t, buf.String(), "0h 15m 0s")
+	assert.NotContains(t, buf.String(), "(default)")
+}
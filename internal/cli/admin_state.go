@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// poolStateVersion is the version of the 'admin --export' file format
+const poolStateVersion = 1
+
+// PoolState is a snapshot of the GPU pool written by 'admin --export' and
+// restored by 'admin --import'. Usage history is not included.
+type PoolState struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	GPUCount   int                 `json:"gpu_count"`
+	Provider   string              `json:"provider"`
+	GPUs       []PoolGPUState      `json:"gpus"`
+	Queue      []*types.QueueEntry `json:"queue"`
+}
+
+// PoolGPUState is the stored state of a single GPU in a PoolState
+type PoolGPUState struct {
+	GPUID int             `json:"gpu_id"`
+	State *types.GPUState `json:"state"`
+}
+
+func runAdminExport(ctx context.Context, path string) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	state, err := exportPoolState(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool state: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	fmt.Printf("Exported state of %d GPUs (%d with stored state) and %d queue entries to %s\n",
+		state.GPUCount, len(state.GPUs), len(state.Queue), path)
+	return nil
+}
+
+// exportPoolState reads the GPU count, provider, GPU states, and queue
+func exportPoolState(ctx context.Context, client *redis_client.Client) (*PoolState, error) {
+	gpuCount, err := client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := client.GetAvailableProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &PoolState{
+		Version:    poolStateVersion,
+		ExportedAt: time.Now(),
+		GPUCount:   gpuCount,
+		Provider:   provider,
+		GPUs:       []PoolGPUState{},
+	}
+
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		gpuState, err := client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		// GPUs that have never been used have nothing to restore
+		if reflect.DeepEqual(*gpuState, types.GPUState{}) {
+			continue
+		}
+		state.GPUs = append(state.GPUs, PoolGPUState{GPUID: gpuID, State: gpuState})
+	}
+
+	state.Queue, err = client.GetAllQueueEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state.Queue == nil {
+		state.Queue = []*types.QueueEntry{}
+	}
+
+	return state, nil
+}
+
+func runAdminImport(ctx context.Context, path string, force bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var state PoolState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	if err := validatePoolState(&state); err != nil {
+		return fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	// Keep allocations from interleaving with the restore
+	if err := client.AcquireAllocationLock(ctx); err != nil {
+		return fmt.Errorf("failed to acquire allocation lock: %v", err)
+	}
+	defer func() {
+		_ = client.ReleaseAllocationLock(ctx)
+	}()
+
+	if err := importPoolState(ctx, client, &state, force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported state of %d GPUs (%d with stored state) and %d queue entries from %s (exported %s)\n",
+		state.GPUCount, len(state.GPUs), len(state.Queue), path, state.ExportedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// validatePoolState checks that a state file is internally consistent
+func validatePoolState(state *PoolState) error {
+	if state.Version < 1 || state.Version > poolStateVersion {
+		return fmt.Errorf("unsupported version %d", state.Version)
+	}
+	if state.GPUCount <= 0 {
+		return fmt.Errorf("GPU count must be greater than 0, got %d", state.GPUCount)
+	}
+	if state.Provider != "nvidia" && state.Provider != "amd" && state.Provider != "fake" {
+		return fmt.Errorf("invalid provider '%s'", state.Provider)
+	}
+
+	seen := make(map[int]bool)
+	for _, gpu := range state.GPUs {
+		if gpu.GPUID < 0 || gpu.GPUID >= state.GPUCount {
+			return fmt.Errorf("GPU ID %d is out of range (0-%d)", gpu.GPUID, state.GPUCount-1)
+		}
+		if seen[gpu.GPUID] {
+			return fmt.Errorf("duplicate state for GPU %d", gpu.GPUID)
+		}
+		seen[gpu.GPUID] = true
+		if gpu.State == nil {
+			return fmt.Errorf("missing state for GPU %d", gpu.GPUID)
+		}
+	}
+
+	for _, entry := range state.Queue {
+		if entry == nil || entry.ID == "" {
+			return fmt.Errorf("queue entry without an ID")
+		}
+		for _, gpuID := range entry.RequestedIDs {
+			if gpuID < 0 || gpuID >= state.GPUCount {
+				return fmt.Errorf("queue entry %s requests GPU ID %d, which is out of range (0-%d)",
+					entry.ID, gpuID, state.GPUCount-1)
+			}
+		}
+	}
+
+	return nil
+}
+
+// importPoolState replaces the pool's GPU states and queue with those in the
+// state file. An initialized pool is only overwritten with force, and only if
+// its GPU count matches the state file.
+func importPoolState(ctx context.Context, client *redis_client.Client, state *PoolState, force bool) error {
+	existingCount, err := client.GetGPUCount(ctx)
+	if err == nil {
+		if existingCount != state.GPUCount {
+			return fmt.Errorf("state file has %d GPUs but the pool has %d. Reinitialize with 'canhazgpu admin --gpus %d --force' first",
+				state.GPUCount, existingCount, state.GPUCount)
+		}
+		if !force {
+			return fmt.Errorf("GPU pool already initialized with %d GPUs. Use --force to overwrite its state", existingCount)
+		}
+
+		fmt.Printf("Releasing all GPUs: admin import (clearing %d existing GPUs and the queue)\n", existingCount)
+		if err := client.ClearAllGPUStates(ctx); err != nil {
+			return fmt.Errorf("failed to clear existing GPU states: %v", err)
+		}
+		if err := client.ClearQueue(ctx); err != nil {
+			return fmt.Errorf("failed to clear the queue: %v", err)
+		}
+	}
+
+	if err := client.SetGPUCount(ctx, state.GPUCount); err != nil {
+		return fmt.Errorf("failed to set GPU count: %v", err)
+	}
+	if err := client.SetAvailableProvider(ctx, state.Provider); err != nil {
+		return fmt.Errorf("failed to store provider information: %v", err)
+	}
+
+	for _, gpu := range state.GPUs {
+		if err := client.SetGPUState(ctx, gpu.GPUID, gpu.State); err != nil {
+			return fmt.Errorf("failed to restore state for GPU %d: %v", gpu.GPUID, err)
+		}
+	}
+
+	for _, entry := range state.Queue {
+		if err := client.AddToQueue(ctx, entry); err != nil {
+			return fmt.Errorf("failed to restore queue entry %s: %v", entry.ID, err)
+		}
+	}
+
+	return nil
+}
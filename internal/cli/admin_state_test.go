@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePoolState(t *testing.T) {
+	valid := func() *PoolState {
+		return &PoolState{
+			Version:  poolStateVersion,
+			GPUCount: 4,
+			Provider: "nvidia",
+			GPUs: []PoolGPUState{
+				{GPUID: 1, State: &types.GPUState{User: "alice", Type: types.ReservationTypeManual}},
+			},
+			Queue: []*types.QueueEntry{
+				{ID: "queue-1", User: "bob", RequestedIDs: []int{2, 3}},
+			},
+		}
+	}
+
+	require.NoError(t, validatePoolState(valid()))
+
+	tests := []struct {
+		name    string
+		modify  func(*PoolState)
+		wantErr string
+	}{
+		{"unknown version", func(s *PoolState) { s.Version = poolStateVersion + 1 }, "unsupported version"},
+		{"missing version", func(s *PoolState) { s.Version = 0 }, "unsupported version"},
+		{"no GPUs", func(s *PoolState) { s.GPUCount = 0 }, "GPU count must be greater than 0"},
+		{"bad provider", func(s *PoolState) { s.Provider = "tpu" }, "invalid provider"},
+		{"GPU out of range", func(s *PoolState) { s.GPUs[0].GPUID = 4 }, "GPU ID 4 is out of range"},
+		{"duplicate GPU", func(s *PoolState) { s.GPUs = append(s.GPUs, s.GPUs[0]) }, "duplicate state for GPU 1"},
+		{"missing GPU state", func(s *PoolState) { s.GPUs[0].State = nil }, "missing state for GPU 1"},
+		{"queue entry without ID", func(s *PoolState) { s.Queue[0].ID = "" }, "queue entry without an ID"},
+		{"queue entry out of range", func(s *PoolState) { s.Queue[0].RequestedIDs = []int{7} }, "requests GPU ID 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := valid()
+			tt.modify(state)
+			assert.ErrorContains(t, validatePoolState(state), tt.wantErr)
+		})
+	}
+}
+
+func TestPoolStateExportImport_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := &types.Config{
+		RedisHost: "localhost",
+		RedisPort: 6379,
+		RedisDB:   15,
+	}
+	client := redis_client.NewClient(config)
+	defer func() {
+		_ = client.Close()
+	}()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	require.NoError(t, client.FlushTestDB(ctx))
+	defer func() {
+		_ = client.FlushTestDB(ctx)
+	}()
+
+	// Build up some state
+	now := time.Now().Truncate(time.Second)
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+	require.NoError(t, client.SetAvailableProvider(ctx, "fake"))
+	require.NoError(t, client.SetGPUState(ctx, 1, &types.GPUState{
+		User:       "alice",
+		StartTime:  types.FlexibleTime{Time: now},
+		Type:       types.ReservationTypeManual,
+		ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)},
+		Note:       "experiment",
+	}))
+	require.NoError(t, client.SetGPUState(ctx, 2, &types.GPUState{LastReleased: types.FlexibleTime{Time: now}}))
+	require.NoError(t, client.AddToQueue(ctx, &types.QueueEntry{
+		ID:             "queue-1",
+		User:           "bob",
+		RequestedCount: 2,
+		EnqueueTime:    types.FlexibleTime{Time: now},
+	}))
+
+	exported, err := exportPoolState(ctx, client)
+	require.NoError(t, err)
+	assert.Equal(t, 4, exported.GPUCount)
+	assert.Equal(t, "fake", exported.Provider)
+	require.Len(t, exported.GPUs, 2)
+	assert.Equal(t, 1, exported.GPUs[0].GPUID)
+	assert.Equal(t, "alice", exported.GPUs[0].State.User)
+	require.Len(t, exported.Queue, 1)
+	require.NoError(t, validatePoolState(exported))
+
+	// An initialized pool is only overwritten with --force
+	err = importPoolState(ctx, client, exported, false)
+	assert.ErrorContains(t, err, "Use --force")
+
+	// The GPU count must match the pool
+	mismatched := *exported
+	mismatched.GPUCount = 8
+	err = importPoolState(ctx, client, &mismatched, true)
+	assert.ErrorContains(t, err, "state file has 8 GPUs but the pool has 4")
+
+	// Restore into an empty database
+	require.NoError(t, client.FlushTestDB(ctx))
+	require.NoError(t, importPoolState(ctx, client, exported, false))
+
+	count, err := client.GetGPUCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	state, err := client.GetGPUState(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", state.User)
+	assert.Equal(t, "experiment", state.Note)
+
+	entries, err := client.GetAllQueueEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "bob", entries[0].User)
+
+	// Importing with --force replaces existing reservations and queue entries
+	require.NoError(t, client.SetGPUState(ctx, 3, &types.GPUState{User: "carol", Type: types.ReservationTypeManual}))
+	require.NoError(t, client.AddToQueue(ctx, &types.QueueEntry{ID: "queue-2", User: "dave", EnqueueTime: types.FlexibleTime{Time: now}}))
+	require.NoError(t, importPoolState(ctx, client, exported, true))
+
+	state, err = client.GetGPUState(ctx, 3)
+	require.NoError(t, err)
+	assert.Empty(t, state.User)
+
+	entries, err = client.GetAllQueueEntries(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "queue-1", entries[0].ID)
+}
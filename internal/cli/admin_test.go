@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForceResetSummary(t *testing.T) {
+	reservations := []activeReservation{
+		{GPUID: 0, User: "bob"},
+		{GPUID: 2, User: "alice"},
+		{GPUID: 2, User: "bob"},
+	}
+	assert.Equal(t, "3 active reservation(s) on GPU(s) 0, 2 held by alice, bob", forceResetSummary(reservations))
+}
+
+func TestPromptYesNo(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" yes \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"sure\n", false},
+		{"", false}, // EOF
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got, err := promptYesNo(strings.NewReader(tt.answer), &out, "Continue?")
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "answer %q", tt.answer)
+		assert.Equal(t, "Continue? [y/N] ", out.String())
+	}
+}
+
+func TestActiveReservations_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := redis_client.NewClient(&types.Config{
+		RedisHost: "localhost",
+		RedisPort: 6379,
+		RedisDB:   15,
+	})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	require.NoError(t, client.FlushTestDB(ctx))
+	defer func() {
+		_ = client.FlushTestDB(ctx)
+	}()
+
+	require.NoError(t, client.SetGPUCount(ctx, 3))
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{User: "alice", Type: types.ReservationTypeRun}))
+	require.NoError(t, client.SetGPUState(ctx, 2, &types.GPUState{
+		User:   types.SharedGPUUser,
+		Type:   types.ReservationTypeShared,
+		Shares: []types.GPUShare{{User: "bob"}, {User: "carol"}},
+	}))
+
+	reservations, err := activeReservations(ctx, client, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []activeReservation{{0, "alice"}, {2, "bob"}, {2, "carol"}}, reservations)
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup v2 hierarchy is mounted
+var cgroupRoot = "/sys/fs/cgroup"
+
+// procSelfCgroup lists the cgroup membership of the current process
+var procSelfCgroup = "/proc/self/cgroup"
+
+// cgroupCPUPeriod is the cpu.max period in microseconds
+const cgroupCPUPeriod = 100000
+
+// resourceLimits are the optional CPU and memory limits for 'canhazgpu run'
+type resourceLimits struct {
+	CPUQuota    int64 // cpu.max quota in microseconds per cgroupCPUPeriod (0 = unlimited)
+	MemoryBytes int64 // memory.max in bytes (0 = unlimited)
+}
+
+// isSet returns true if any limit was requested
+func (l resourceLimits) isSet() bool {
+	return l.CPUQuota > 0 || l.MemoryBytes > 0
+}
+
+// parseResourceLimits parses the --cpu-limit and --mem-limit flags. Empty
+// values mean no limit.
+func parseResourceLimits(cpuLimit, memLimit string) (resourceLimits, error) {
+	var limits resourceLimits
+
+	if cpuLimit != "" {
+		cpus, err := strconv.ParseFloat(strings.TrimSpace(cpuLimit), 64)
+		if err != nil || cpus <= 0 {
+			return limits, fmt.Errorf("invalid CPU limit %q: must be a positive number of CPUs (e.g., 4 or 0.5)", cpuLimit)
+		}
+		limits.CPUQuota = int64(cpus * cgroupCPUPeriod)
+		if limits.CPUQuota < 1000 {
+			// The kernel rejects quotas below 1ms
+			return limits, fmt.Errorf("invalid CPU limit %q: must be at least 0.01 CPUs", cpuLimit)
+		}
+	}
+
+	if memLimit != "" {
+		bytes, err := parseMemorySize(memLimit)
+		if err != nil {
+			return limits, fmt.Errorf("invalid memory limit %q: %v", memLimit, err)
+		}
+		limits.MemoryBytes = bytes
+	}
+
+	return limits, nil
+}
+
+// parseMemorySize parses a memory size such as 512M, 32G, or 1.5Gi. Units are
+// binary (1G = 1024^3 bytes); a plain number is a count of bytes.
+func parseMemorySize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+	s = strings.TrimSuffix(s, "I")
+
+	multiplier := int64(1)
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("must be a positive size (e.g., 512M, 32G)")
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// currentCgroup returns the cgroup v2 path of the current process, relative
+// to the cgroup root
+func currentCgroup() (string, error) {
+	file, err := os.Open(procSelfCgroup)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// The cgroup v2 entry has hierarchy ID 0 and no controllers: "0::/path"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("cgroup v2 is not in use")
+}
+
+// createRunCgroup creates a cgroup with the given limits next to the current
+// process's cgroup and returns its path. Processes are not moved into it; see
+// joinCgroup.
+func createRunCgroup(limits resourceLimits, pid int) (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupRoot)
+	}
+
+	current, err := currentCgroup()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine current cgroup: %v", err)
+	}
+
+	// A cgroup with processes can't have children with controllers enabled,
+	// so create a sibling of the current cgroup instead of a child
+	parent := filepath.Join(cgroupRoot, filepath.Dir(current))
+
+	var controllers []string
+	if limits.CPUQuota > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if limits.MemoryBytes > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644); err != nil {
+		return "", fmt.Errorf("failed to enable cgroup controllers: %v", err)
+	}
+
+	path := filepath.Join(parent, fmt.Sprintf("canhazgpu-run-%d", pid))
+	if err := os.Mkdir(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cgroup: %v", err)
+	}
+
+	if limits.CPUQuota > 0 {
+		value := fmt.Sprintf("%d %d", limits.CPUQuota, cgroupCPUPeriod)
+		if err := os.WriteFile(filepath.Join(path, "cpu.max"), []byte(value), 0644); err != nil {
+			removeCgroup(path)
+			return "", fmt.Errorf("failed to set CPU limit: %v", err)
+		}
+	}
+	if limits.MemoryBytes > 0 {
+		value := strconv.FormatInt(limits.MemoryBytes, 10)
+		if err := os.WriteFile(filepath.Join(path, "memory.max"), []byte(value), 0644); err != nil {
+			removeCgroup(path)
+			return "", fmt.Errorf("failed to set memory limit: %v", err)
+		}
+	}
+
+	return path, nil
+}
+
+// joinCgroup moves a process into a cgroup. Processes it starts afterwards
+// inherit the cgroup.
+func joinCgroup(path string, pid int) error {
+	return os.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
+
+// removeCgroup removes a cgroup created by createRunCgroup. The kernel only
+// allows removing a cgroup once all of its processes have exited.
+func removeCgroup(path string) {
+	_ = os.Remove(path)
+}
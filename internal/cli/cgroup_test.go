@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceLimits(t *testing.T) {
+	limits, err := parseResourceLimits("", "")
+	require.NoError(t, err)
+	assert.False(t, limits.isSet())
+
+	limits, err = parseResourceLimits("4", "32G")
+	require.NoError(t, err)
+	assert.True(t, limits.isSet())
+	assert.Equal(t, int64(400000), limits.CPUQuota)
+	assert.Equal(t, int64(32<<30), limits.MemoryBytes)
+
+	limits, err = parseResourceLimits("0.5", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50000), limits.CPUQuota)
+	assert.Equal(t, int64(0), limits.MemoryBytes)
+
+	for _, bad := range []string{"0", "-1", "abc", "0.001"} {
+		_, err := parseResourceLimits(bad, "")
+		assert.Error(t, err, "cpu limit %q", bad)
+	}
+	for _, bad := range []string{"0", "-1G", "lots", "G"} {
+		_, err := parseResourceLimits("", bad)
+		assert.Error(t, err, "memory limit %q", bad)
+	}
+}
+
+func TestParseMemorySize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1024", 1024},
+		{"512K", 512 << 10},
+		{"512M", 512 << 20},
+		{"512MB", 512 << 20},
+		{"32G", 32 << 30},
+		{"32g", 32 << 30},
+		{"1.5Gi", 3 << 29},
+		{"1T", 1 << 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := parseMemorySize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, size)
+		})
+	}
+}
+
+// setupFakeCgroupRoot points the cgroup helpers at a fake cgroup v2 hierarchy
+// in which the current process is in /user.slice/session.scope
+func setupFakeCgroupRoot(t *testing.T) string {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpu memory"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "user.slice", "session.scope"), 0755))
+
+	self := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(self, []byte("0::/user.slice/session.scope\n"), 0644))
+
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, self
+	t.Cleanup(func() {
+		cgroupRoot, procSelfCgroup = oldRoot, oldSelf
+	})
+	return root
+}
+
+func TestCreateRunCgroup(t *testing.T) {
+	root := setupFakeCgroupRoot(t)
+
+	path, err := createRunCgroup(resourceLimits{CPUQuota: 200000, MemoryBytes: 1 << 30}, 1234)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "user.slice", "canhazgpu-run-1234"), path)
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "+cpu +memory", read(filepath.Join(root, "user.slice", "cgroup.subtree_control")))
+	assert.Equal(t, "200000 100000", read(filepath.Join(path, "cpu.max")))
+	assert.Equal(t, "1073741824", read(filepath.Join(path, "memory.max")))
+
+	require.NoError(t, joinCgroup(path, 1234))
+	assert.Equal(t, "1234", read(filepath.Join(path, "cgroup.procs")))
+}
+
+func TestCreateRunCgroup_Unavailable(t *testing.T) {
+	setupFakeCgroupRoot(t)
+
+	// No cgroup v2 hierarchy
+	cgroupRoot = t.TempDir()
+	_, err := createRunCgroup(resourceLimits{MemoryBytes: 1 << 30}, 1234)
+	assert.Error(t, err)
+
+	// Only cgroup v1 entries
+	root := setupFakeCgroupRoot(t)
+	require.NoError(t, os.WriteFile(procSelfCgroup, []byte("4:memory:/user.slice\n"), 0644))
+	_, err = createRunCgroup(resourceLimits{MemoryBytes: 1 << 30}, 1234)
+	assert.Error(t, err)
+	assert.NoDirExists(t, filepath.Join(root, "user.slice", "canhazgpu-run-1234"))
+}
@@ -24,7 +24,7 @@ func TestCommands_Structure(t *testing.T) {
 			use:           "admin",
 			shortContains: "Initialize GPU pool",
 			requiredFlags: []string{"gpus"},
-			optionalFlags: []string{"force"},
+			optionalFlags: []string{"init-from-smi", "force", "yes", "provider", "set", "unset", "list", "reset-usage-history", "annotate", "gpu-ids"},
 		},
 		{
 			name:          "status command",
@@ -40,7 +40,7 @@ func TestCommands_Structure(t *testing.T) {
 			use:           "run",
 			shortContains: "Reserve GPUs and run a command",
 			requiredFlags: []string{"gpus"},
-			optionalFlags: []string{},
+			optionalFlags: []string{"min-free-duration", "nice", "ionice", "health-check", "working-dir", "no-stdin", "count-from-env", "env", "log-dir", "log-keep", "gpu-class", "on-success", "on-failure", "timing", "login-shell", "checkpoint-signal", "checkpoint-grace"},
 		},
 		{
 			name:          "reserve command",
@@ -48,7 +48,15 @@ func TestCommands_Structure(t *testing.T) {
 			use:           "reserve",
 			shortContains: "Reserve GPUs manually",
 			requiredFlags: []string{},
-			optionalFlags: []string{"gpus", "duration"},
+			optionalFlags: []string{"gpus", "duration", "renewable", "min-free-duration", "shared", "gpu-class", "remind-before"},
+		},
+		{
+			name:          "keepalive command",
+			cmd:           keepaliveCmd,
+			use:           "keepalive",
+			shortContains: "Keep renewable GPU reservations alive",
+			requiredFlags: []string{"gpu-ids"},
+			optionalFlags: []string{"user"},
 		},
 		{
 			name:          "release command",
@@ -56,7 +64,55 @@ func TestCommands_Structure(t *testing.T) {
 			use:           "release",
 			shortContains: "Release manually reserved GPUs",
 			requiredFlags: []string{},
-			optionalFlags: []string{"gpu-ids"},
+			optionalFlags: []string{"gpu-ids", "all", "kill"},
+		},
+		{
+			name:          "schedule command",
+			cmd:           scheduleCmd,
+			use:           "schedule",
+			shortContains: "future time window",
+			requiredFlags: []string{},
+			optionalFlags: []string{"gpu-ids", "start", "end", "duration", "note", "user", "account", "list", "json", "cancel"},
+		},
+		{
+			name:          "daemon command",
+			cmd:           daemonCmd,
+			use:           "daemon",
+			shortContains: "periodic cleanup",
+			requiredFlags: []string{},
+			optionalFlags: []string{"interval", "metrics-addr"},
+		},
+		{
+			name:          "metrics command",
+			cmd:           metricsCmd,
+			use:           "metrics",
+			shortContains: "Prometheus metrics",
+			requiredFlags: []string{},
+			optionalFlags: []string{"textfile"},
+		},
+		{
+			name:          "explain-last command",
+			cmd:           explainLastCmd,
+			use:           "explain-last",
+			shortContains: "most recent GPU allocation",
+			requiredFlags: []string{},
+			optionalFlags: []string{"user", "json"},
+		},
+		{
+			name:          "shell command",
+			cmd:           shellCmd,
+			use:           "shell",
+			shortContains: "interactive shell",
+			requiredFlags: []string{},
+			optionalFlags: []string{"gpus", "gpu-ids", "timeout", "note", "user", "nonblock", "wait", "priority", "account", "gpu-class"},
+		},
+		{
+			name:          "wait command",
+			cmd:           waitCmd,
+			use:           "wait",
+			shortContains: "remote host",
+			requiredFlags: []string{"remote"},
+			optionalFlags: []string{"gpus", "timeout"},
 		},
 	}
 
@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonInterval    string
+	daemonMetricsAddr string
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run periodic cleanup of expired reservations and stale queue entries",
+	Long: `Run a long-lived process that periodically reclaims GPUs from expired
+manual reservations and run reservations with stale heartbeats, and removes
+stale queue entries.
+
+Without a daemon, this cleanup only happens when someone runs 'canhazgpu status',
+waits in the queue, or loads the web dashboard. On machines nobody polls, dead
+reservations can linger. The daemon makes reclamation independent of user activity.
+
+Only one daemon may run per Redis database. A second daemon pointed at the same
+database exits with an error while the first is alive.
+
+Optionally, --metrics-addr serves Prometheus metrics at /metrics.
+
+The daemon also reserves the GPUs of windows booked with 'canhazgpu schedule'
+when they start. Without a daemon, scheduled windows are never activated.
+
+When reminder_webhook.url is configured, the daemon also POSTs a reminder to
+it before each manual reservation made with 'reserve --remind-before'
+expires, once per reservation, reports scheduled windows whose GPUs are
+still in use when they start, and tells holders of manual reservations that
+were preempted by a higher-priority request.
+
+Example usage:
+  canhazgpu daemon
+  canhazgpu daemon --interval 30s
+  canhazgpu daemon --interval 1m --metrics-addr :9100`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemon(cmd.Context())
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringVarP(&daemonInterval, "interval", "i", "1m", "How often to run cleanup (e.g., 30s, 1m, 5m)")
+	daemonCmd.Flags().StringVar(&daemonMetricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on (e.g., :9100). Disabled by default.")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+func runDaemon(ctx context.Context) error {
+	interval, err := utils.ParseDuration(daemonInterval)
+	if err != nil {
+		return fmt.Errorf("invalid interval: %v", err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	// Claim the daemon lock so only one daemon runs per Redis database. The
+	// lock outlives a few missed intervals so a brief Redis hiccup doesn't
+	// let a second daemon take over.
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	lockTTL := 3 * interval
+
+	acquired, err := client.AcquireDaemonLock(ctx, owner, lockTTL)
+	if err != nil {
+		return fmt.Errorf("failed to acquire daemon lock: %v", err)
+	}
+	if !acquired {
+		holder, _ := client.GetDaemonLockOwner(ctx)
+		return fmt.Errorf("another daemon is already running for this Redis database (%s)", holder)
+	}
+	defer func() {
+		if err := client.ReleaseDaemonLock(context.Background(), owner); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to release daemon lock: %v\n", err)
+		}
+	}()
+
+	engine := gpu.NewAllocationEngine(client, config)
+	metrics := &daemonMetrics{}
+
+	var reminders *reminderSender
+	if config.ReminderWebhook.URL != "" {
+		reminders = newReminderSender(config.ReminderWebhook, hostname)
+	}
+
+	// Start metrics server if requested
+	if daemonMetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", metrics.handleMetrics)
+		server := &http.Server{Addr: daemonMetricsAddr, Handler: mux}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "Warning: metrics server failed: %v\n", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Serving metrics on http://%s/metrics\n", daemonMetricsAddr)
+	}
+
+	// Stop cleanly on Ctrl+C or SIGTERM
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	fmt.Printf("Running cleanup every %s (daemon %s)\n", utils.FormatDuration(interval), owner)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := runDaemonCycle(ctx, client, engine, metrics, reminders, owner, lockTTL); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-sigChan:
+			fmt.Printf("Received %v, stopping daemon\n", sig)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDaemonCycle refreshes the daemon lock, performs one round of cleanup,
+// and activates scheduled windows that have started, then sends due expiry
+// reminders and preemption notices unless reminders is nil. Cleanup failures
+// are logged and counted; only losing the lock is fatal.
+func runDaemonCycle(ctx context.Context, client *redis_client.Client, engine *gpu.AllocationEngine, metrics *daemonMetrics, reminders *reminderSender, owner string, lockTTL time.Duration) error {
+	held, err := client.RefreshDaemonLock(ctx, owner, lockTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to refresh daemon lock: %v\n", err)
+		metrics.recordCleanup(0, true)
+		return nil
+	}
+	if !held {
+		return fmt.Errorf("daemon lock lost to another daemon, exiting")
+	}
+
+	failed := false
+	if err := engine.CleanupExpiredReservations(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cleanup expired reservations: %v\n", err)
+		failed = true
+	}
+
+	removed, err := client.CleanupStaleQueueEntries(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cleanup stale queue entries: %v\n", err)
+		failed = true
+	} else if len(removed) > 0 {
+		fmt.Printf("Removed %d stale queue entries\n", len(removed))
+	}
+
+	if !activateSchedules(ctx, engine, reminders) {
+		failed = true
+	}
+
+	metrics.recordCleanup(len(removed), failed)
+
+	if reminders != nil {
+		reminders.sendDue(ctx, engine)
+		reminders.sendPreemptionNotices(ctx, engine)
+	}
+
+	// Only gather GPU status when someone can scrape it
+	if daemonMetricsAddr != "" {
+		statuses, err := engine.GetGPUStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to get GPU status for metrics: %v\n", err)
+		} else {
+			queueLength, _ := client.GetQueueLength(ctx)
+			latency, err := client.GetAllocationLatencyStats(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to get allocation latency for metrics: %v\n", err)
+			}
+			metrics.recordStatus(statuses, queueLength, latency)
+		}
+	}
+
+	return nil
+}
+
+// daemonMetrics holds the counters and gauges exposed on /metrics
+type daemonMetrics struct {
+	mu                  sync.Mutex
+	cleanupRuns         int
+	cleanupErrors       int
+	queueEntriesRemoved int
+	lastCleanup         time.Time
+	pool                *poolMetrics // nil until the first status is recorded
+}
+
+func (m *daemonMetrics) recordCleanup(queueEntriesRemoved int, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cleanupRuns++
+	if failed {
+		m.cleanupErrors++
+	}
+	m.queueEntriesRemoved += queueEntriesRemoved
+	m.lastCleanup = time.Now()
+}
+
+func (m *daemonMetrics) recordStatus(statuses []gpu.GPUStatusInfo, queueLength int, latency *types.AllocationLatencyStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pool = newPoolMetrics(statuses, queueLength, latency)
+}
+
+func (m *daemonMetrics) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeMetrics(w)
+}
+
+// writeMetrics renders the metrics in the Prometheus text exposition format
+func (m *daemonMetrics) writeMetrics(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetric(w, "canhazgpu_cleanup_runs_total", "Number of cleanup cycles run by the daemon.", "counter",
+		fmt.Sprintf("canhazgpu_cleanup_runs_total %d", m.cleanupRuns))
+	writeMetric(w, "canhazgpu_cleanup_errors_total", "Number of cleanup cycles that encountered an error.", "counter",
+		fmt.Sprintf("canhazgpu_cleanup_errors_total %d", m.cleanupErrors))
+	writeMetric(w, "canhazgpu_queue_entries_removed_total", "Number of stale queue entries removed.", "counter",
+		fmt.Sprintf("canhazgpu_queue_entries_removed_total %d", m.queueEntriesRemoved))
+
+	if !m.lastCleanup.IsZero() {
+		writeMetric(w, "canhazgpu_last_cleanup_timestamp_seconds", "Unix time of the last cleanup cycle.", "gauge",
+			fmt.Sprintf("canhazgpu_last_cleanup_timestamp_seconds %d", m.lastCleanup.Unix()))
+	}
+
+	if m.pool != nil {
+		m.pool.write(w)
+	}
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+)
+
+// reminderEvent identifies expiry reminders in webhook payloads
+const reminderEvent = "reservation_expiring"
+
+// preemptionEvent identifies preemption notices in webhook payloads
+const preemptionEvent = "reservation_preempted"
+
+// reminderPayload is the JSON body POSTed to the reminder webhook. Text is a
+// readable summary, so that chat webhooks such as Slack's can post it as is.
+type reminderPayload struct {
+	Event string `json:"event"`
+	Text  string `json:"text"`
+	Host  string `json:"host"`
+	gpu.Reminder
+}
+
+// preemptionPayload is the JSON body POSTed to the reminder webhook when a
+// manual reservation was preempted
+type preemptionPayload struct {
+	Event string `json:"event"`
+	Text  string `json:"text"`
+	Host  string `json:"host"`
+	gpu.PreemptionNotice
+}
+
+// reminderSender posts reminders to the reminder webhook before manual
+// reservations made with --remind-before expire, reports scheduled windows
+// whose GPUs couldn't be reserved, and notifies holders of preempted manual
+// reservations
+type reminderSender struct {
+	webhook types.WebhookConfig
+	host    string
+	client  *http.Client
+}
+
+func newReminderSender(webhook types.WebhookConfig, host string) *reminderSender {
+	return &reminderSender{
+		webhook: webhook,
+		host:    host,
+		client:  &http.Client{Timeout: types.ReminderWebhookTimeout},
+	}
+}
+
+// sendDue claims the reminders that are due and posts them. Each reminder is
+// claimed once, so one that fails to post is logged and not retried.
+func (s *reminderSender) sendDue(ctx context.Context, engine *gpu.AllocationEngine) {
+	now := time.Now()
+	reminders, err := engine.ClaimDueReminders(ctx, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to check for due reminders: %v\n", err)
+	}
+
+	for _, reminder := range reminders {
+		payload := reminderPayload{
+			Event:    reminderEvent,
+			Text:     reminderText(s.host, reminder, now),
+			Host:     s.host,
+			Reminder: reminder,
+		}
+		if err := s.post(ctx, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send reminder to %s: %v\n", reminder.User, err)
+			continue
+		}
+		fmt.Printf("Reminded %s that GPU(s) %v expire at %s\n",
+			reminder.User, reminder.GPUIDs, reminder.ExpiryTime.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// sendPreemptionNotices claims the notices of preempted manual reservations
+// and posts them. Like reminders, a notice that fails to post is not retried.
+func (s *reminderSender) sendPreemptionNotices(ctx context.Context, engine *gpu.AllocationEngine) {
+	notices, err := engine.ClaimPreemptionNotices(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to check for preemption notices: %v\n", err)
+	}
+
+	for _, notice := range notices {
+		payload := preemptionPayload{
+			Event:            preemptionEvent,
+			Text:             preemptionText(s.host, notice),
+			Host:             s.host,
+			PreemptionNotice: notice,
+		}
+		if err := s.post(ctx, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to send preemption notice to %s: %v\n", notice.User, err)
+			continue
+		}
+		fmt.Printf("Notified %s that GPU(s) %v were preempted\n", notice.User, notice.GPUIDs)
+	}
+}
+
+// post sends a payload to the webhook, treating any non-2xx response as a
+// failure
+func (s *reminderSender) post(ctx context.Context, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", s.webhook.URL, resp.Status)
+	}
+	return nil
+}
+
+// reminderText summarizes a reminder, e.g. "alice's reservation of GPU(s)
+// [0 2] on gpu-host expires in 0h 30m 0s (at 2026-03-04 17:00:00): training"
+func reminderText(host string, reminder gpu.Reminder, now time.Time) string {
+	text := fmt.Sprintf("%s's reservation of GPU(s) %v on %s expires in %s (at %s)",
+		reminder.User, reminder.GPUIDs, host,
+		utils.FormatDuration(reminder.ExpiryTime.Sub(now)),
+		reminder.ExpiryTime.Local().Format("2006-01-02 15:04:05"))
+	if reminder.Note != "" {
+		text += ": " + reminder.Note
+	}
+	return text
+}
+
+// preemptionText summarizes a preemption notice, e.g. "alice's idle manual
+// reservation of GPU(s) [0 2] on gpu-host was preempted by bob's
+// higher-priority request"
+func preemptionText(host string, notice gpu.PreemptionNotice) string {
+	by := "a higher-priority request"
+	if notice.PreemptedBy != "" {
+		by = notice.PreemptedBy + "'s higher-priority request"
+	}
+	return fmt.Sprintf("%s's idle manual reservation of GPU(s) %v on %s was preempted by %s",
+		notice.User, notice.GPUIDs, host, by)
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminderText(t *testing.T) {
+	now := time.Date(2026, 3, 4, 16, 30, 0, 0, time.Local)
+	reminder := gpu.Reminder{
+		User:       "alice",
+		GPUIDs:     []int{0, 2},
+		ExpiryTime: now.Add(30 * time.Minute),
+		Note:       "training",
+	}
+	assert.Equal(t, "alice's reservation of GPU(s) [0 2] on gpu-host expires in 0h 30m 0s (at 2026-03-04 17:00:00): training",
+		reminderText("gpu-host", reminder, now))
+
+	reminder.Note = ""
+	assert.Equal(t, "alice's reservation of GPU(s) [0 2] on gpu-host expires in 0h 30m 0s (at 2026-03-04 17:00:00)",
+		reminderText("gpu-host", reminder, now))
+}
+
+func TestPreemptionText(t *testing.T) {
+	notice := gpu.PreemptionNotice{User: "alice", GPUIDs: []int{0, 2}, PreemptedBy: "bob"}
+	assert.Equal(t, "alice's idle manual reservation of GPU(s) [0 2] on gpu-host was preempted by bob's higher-priority request",
+		preemptionText("gpu-host", notice))
+
+	notice.PreemptedBy = ""
+	assert.Equal(t, "alice's idle manual reservation of GPU(s) [0 2] on gpu-host was preempted by a higher-priority request",
+		preemptionText("gpu-host", notice))
+}
+
+func TestReminderSenderPost(t *testing.T) {
+	var got map[string]any
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	sender := newReminderSender(types.WebhookConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}, "gpu-host")
+	payload := reminderPayload{
+		Event: reminderEvent,
+		Text:  "alice's reservation expires soon",
+		Host:  "gpu-host",
+		Reminder: gpu.Reminder{
+			User:       "alice",
+			GPUIDs:     []int{1},
+			ExpiryTime: time.Date(2026, 3, 4, 17, 0, 0, 0, time.UTC),
+			JobID:      "job-1",
+		},
+	}
+	require.NoError(t, sender.post(context.Background(), payload))
+
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, "reservation_expiring", got["event"])
+	assert.Equal(t, "gpu-host", got["host"])
+	assert.Equal(t, "alice", got["user"])
+	assert.Equal(t, []any{1.0}, got["gpu_ids"])
+	assert.Equal(t, "2026-03-04T17:00:00Z", got["expiry_time"])
+	assert.Equal(t, "job-1", got["job_id"])
+
+	// Non-2xx responses are failures
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	sender = newReminderSender(types.WebhookConfig{URL: failing.URL}, "gpu-host")
+	assert.ErrorContains(t, sender.post(context.Background(), payload), "502 Bad Gateway")
+}
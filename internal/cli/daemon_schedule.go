@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// scheduleConflictEvent identifies scheduled window conflicts in webhook
+// payloads
+const scheduleConflictEvent = "scheduled_reservation_conflict"
+
+// scheduleConflictPayload is the JSON body POSTed to the reminder webhook
+// when a scheduled window starts but its GPUs can't be reserved
+type scheduleConflictPayload struct {
+	Event    string                     `json:"event"`
+	Text     string                     `json:"text"`
+	Host     string                     `json:"host"`
+	Schedule types.ScheduledReservation `json:"schedule"`
+}
+
+// activateSchedules reserves the GPUs of scheduled windows that have started.
+// Conflicts are logged and, unless notifier is nil, posted to the reminder
+// webhook. Returns false if the schedules couldn't be processed.
+func activateSchedules(ctx context.Context, engine *gpu.AllocationEngine, notifier *reminderSender) bool {
+	events, err := engine.ActivateDueSchedules(ctx, time.Now())
+
+	for _, event := range events {
+		schedule := event.Schedule
+		if event.Activated {
+			fmt.Printf("Started schedule %s: reserved GPU(s) %v for %s until %s\n",
+				schedule.ID, schedule.GPUIDs, schedule.User, formatScheduleTime(schedule.EndTime.Time))
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: schedule %s of %s could not reserve GPU(s) %v, retrying until %s: %s\n",
+			schedule.ID, schedule.User, schedule.GPUIDs, formatScheduleTime(schedule.EndTime.Time), schedule.Conflict)
+		if notifier != nil {
+			payload := scheduleConflictPayload{
+				Event:    scheduleConflictEvent,
+				Text:     scheduleConflictText(notifier.host, schedule),
+				Host:     notifier.host,
+				Schedule: schedule,
+			}
+			if err := notifier.post(ctx, payload); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to report schedule %s conflict: %v\n", schedule.ID, err)
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to activate scheduled reservations: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// scheduleConflictText summarizes a scheduled window whose GPUs couldn't be
+// reserved, e.g. "alice's scheduled reservation of GPU(s) [0 1] on gpu-host
+// from 2026-03-04 22:00 to 2026-03-05 06:00 could not start: GPU 1 is
+// already reserved by user 'bob'. Retrying until the window ends."
+func scheduleConflictText(host string, schedule types.ScheduledReservation) string {
+	return fmt.Sprintf("%s's scheduled reservation of GPU(s) %v on %s from %s to %s could not start: %s. Retrying until the window ends.",
+		schedule.User, schedule.GPUIDs, host,
+		formatScheduleTime(schedule.StartTime.Time), formatScheduleTime(schedule.EndTime.Time), schedule.Conflict)
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleConflictText(t *testing.T) {
+	start := time.Date(2026, 3, 4, 22, 0, 0, 0, time.Local)
+	schedule := types.ScheduledReservation{
+		ID:        "1f3a9c2e",
+		User:      "alice",
+		GPUIDs:    []int{0, 1},
+		StartTime: types.FlexibleTime{Time: start},
+		EndTime:   types.FlexibleTime{Time: start.Add(8 * time.Hour)},
+		Status:    types.ScheduleStatusConflict,
+		Conflict:  "GPU 1 is already reserved by user 'bob'",
+	}
+	assert.Equal(t, "alice's scheduled reservation of GPU(s) [0 1] on gpu-host from 2026-03-04 22:00 to 2026-03-05 06:00 "+
+		"could not start: GPU 1 is already reserved by user 'bob'. Retrying until the window ends.",
+		scheduleConflictText("gpu-host", schedule))
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonMetrics_WriteMetrics(t *testing.T) {
+	metrics := &daemonMetrics{}
+
+	// Before any status is recorded, only cleanup counters are exposed
+	var buf bytes.Buffer
+	metrics.writeMetrics(&buf)
+	output := buf.String()
+	assert.Contains(t, output, "# TYPE canhazgpu_cleanup_runs_total counter")
+	assert.Contains(t, output, "canhazgpu_cleanup_runs_total 0")
+	assert.NotContains(t, output, "canhazgpu_last_cleanup_timestamp_seconds")
+	assert.NotContains(t, output, "canhazgpu_gpus_total")
+
+	metrics.recordCleanup(2, false)
+	metrics.recordCleanup(0, true)
+	metrics.recordStatus([]gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE"},
+		{GPUID: 2, Status: "IN_USE"},
+		{GPUID: 3, Status: "UNRESERVED"},
+	}, 3, nil)
+
+	buf.Reset()
+	metrics.writeMetrics(&buf)
+	output = buf.String()
+	assert.Contains(t, output, "canhazgpu_cleanup_runs_total 2")
+	assert.Contains(t, output, "canhazgpu_cleanup_errors_total 1")
+	assert.Contains(t, output, "canhazgpu_queue_entries_removed_total 2")
+	assert.Contains(t, output, "canhazgpu_last_cleanup_timestamp_seconds ")
+	assert.Contains(t, output, "canhazgpu_gpus_total 4")
+	assert.Contains(t, output, `canhazgpu_gpus{status="available"} 1`)
+	assert.Contains(t, output, `canhazgpu_gpus{status="in_use"} 2`)
+	assert.Contains(t, output, `canhazgpu_gpus{status="unreserved"} 1`)
+	assert.Contains(t, output, `canhazgpu_gpus{status="error"} 0`)
+	assert.Contains(t, output, "canhazgpu_queue_length 3")
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/spf13/cobra"
+)
+
+// Error codes reported in JSON error output. Scripts match on these, so
+// existing codes must not change; add new ones instead.
+const (
+	ErrCodeRedisUnreachable  = "redis_unreachable"  // Redis couldn't be reached
+	ErrCodeInvalidArgument   = "invalid_argument"   // A flag or argument was invalid
+	ErrCodeNotFound          = "not_found"          // The requested item doesn't exist
+	ErrCodeRemoteUnreachable = "remote_unreachable" // A remote host's status couldn't be read
+	ErrCodeUnknown           = "unknown"            // Any other error
+)
+
+// CodedError is an error with a stable code for JSON error output
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+func (e *CodedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
+
+// withCode gives err the code for JSON error output
+func withCode(code string, err error) error {
+	return &CodedError{Code: code, Err: err}
+}
+
+// invalidArgument gives err the invalid_argument code, for flag validation
+func invalidArgument(err error) error {
+	return withCode(ErrCodeInvalidArgument, err)
+}
+
+// errorCode returns the code of err for JSON error output
+func errorCode(err error) string {
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return ErrCodeUnknown
+}
+
+// pingRedis checks that Redis can be reached
+func pingRedis(ctx context.Context, client *redis_client.Client) error {
+	if err := client.Ping(ctx); err != nil {
+		return withCode(ErrCodeRedisUnreachable, fmt.Errorf("failed to connect to Redis: %v", err))
+	}
+	return nil
+}
+
+// JSONError is the error written in JSON output mode
+type JSONError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// writeJSONError writes err as a JSON error object
+func writeJSONError(w io.Writer, err error) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(JSONError{Error: err.Error(), Code: errorCode(err)})
+}
+
+// jsonOutputRequested reports whether cmd was asked for JSON output, with
+// --json, --format json, or an --output path ending in .json
+func jsonOutputRequested(cmd *cobra.Command) bool {
+	flagValue := func(name string) string {
+		if flag := cmd.Flags().Lookup(name); flag != nil {
+			return flag.Value.String()
+		}
+		return ""
+	}
+
+	isJSON, err := resolveOutputFormat(flagValue("json") == "true", flagValue("format"), flagValue("output"))
+	return err == nil && isJSON
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, ErrCodeUnknown, errorCode(errors.New("boom")))
+
+	err := withCode(ErrCodeRedisUnreachable, errors.New("failed to connect to Redis: connection refused"))
+	assert.Equal(t, ErrCodeRedisUnreachable, errorCode(err))
+	assert.EqualError(t, err, "failed to connect to Redis: connection refused")
+
+	// Codes survive wrapping
+	assert.Equal(t, ErrCodeInvalidArgument, errorCode(fmt.Errorf("status: %w", invalidArgument(errors.New("bad flag")))))
+}
+
+func TestWriteJSONError(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeJSONError(&buf, withCode(ErrCodeNotFound, errors.New("no recent allocation found for alice"))))
+	assert.JSONEq(t, `{"error": "no recent allocation found for alice", "code": "not_found"}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeJSONError(&buf, errors.New("boom")))
+	assert.JSONEq(t, `{"error": "boom", "code": "unknown"}`, buf.String())
+}
+
+func TestJSONOutputRequested(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().Bool("json", false, "")
+		cmd.Flags().String("format", "", "")
+		cmd.Flags().String("output", "", "")
+		require.NoError(t, cmd.Flags().Parse(args))
+		return cmd
+	}
+
+	assert.False(t, jsonOutputRequested(newCmd()))
+	assert.True(t, jsonOutputRequested(newCmd("--json")))
+	assert.True(t, jsonOutputRequested(newCmd("--format", "json")))
+	assert.True(t, jsonOutputRequested(newCmd("--output", "status.json")))
+	assert.False(t, jsonOutputRequested(newCmd("--output", "status.json", "--format", "text")))
+	assert.False(t, jsonOutputRequested(newCmd("--format", "yaml")))
+
+	// Commands without JSON output
+	assert.False(t, jsonOutputRequested(&cobra.Command{Use: "release"}))
+}
+
+func TestReportInvalidArgumentCode(t *testing.T) {
+	oldTop := reportTop
+	defer func() { reportTop = oldTop }()
+
+	reportTop = -1
+	err := runReport(&cobra.Command{}, nil)
+	assert.ErrorContains(t, err, "invalid --top value")
+	assert.Equal(t, ErrCodeInvalidArgument, errorCode(err))
+}
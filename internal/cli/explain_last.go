@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var explainLastCmd = &cobra.Command{
+	Use:   "explain-last",
+	Short: "Explain how your most recent GPU allocation was decided",
+	Long: `Explain how your most recent GPU allocation was decided.
+
+Each allocation stores a short trace of its decision: which GPUs were
+candidates and how they were ranked, which GPUs were excluded and why, and
+which GPUs were picked. This command shows the trace for your most recent
+request, including requests that failed.
+
+When allocating by count, GPUs you used most recently are preferred (MRU).
+GPUs you have never used are ranked by when they were last released, least
+recently first (LRU). If usage history is disabled with
+record_usage_history: false, all GPUs are ranked by LRU. Traces are kept
+for 7 days.
+
+Example usage:
+  canhazgpu explain-last
+  canhazgpu explain-last --user alice
+  canhazgpu explain-last --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := viper.GetString("explain-last.user")
+		jsonOutput := viper.GetBool("explain-last.json")
+		return runExplainLast(cmd.Context(), user, jsonOutput)
+	},
+}
+
+func init() {
+	explainLastCmd.Flags().StringP("user", "u", "", "Show the trace for this user identifier instead of the current user")
+	explainLastCmd.Flags().Bool("json", false, "Output the trace as JSON")
+
+	rootCmd.AddCommand(explainLastCmd)
+}
+
+func runExplainLast(ctx context.Context, user string, jsonOutput bool) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := pingRedis(ctx, client); err != nil {
+		return err
+	}
+
+	if user == "" {
+		user = getCurrentUser()
+	}
+
+	trace, err := client.GetAllocationTrace(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to get allocation trace: %v", err)
+	}
+	if trace == nil {
+		return withCode(ErrCodeNotFound, fmt.Errorf("no recent allocation found for %s", user))
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(trace, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printAllocationTrace(os.Stdout, trace, time.Now())
+	return nil
+}
+
+// printAllocationTrace writes a human readable explanation of an allocation
+func printAllocationTrace(w io.Writer, trace *types.AllocationTrace, now time.Time) {
+	at := trace.Time.ToTime()
+	_, _ = fmt.Fprintf(w, "Last allocation for %s at %s (%s ago)\n",
+		trace.User, at.Format("2006-01-02 15:04:05"), utils.FormatDuration(now.Sub(at)))
+
+	if len(trace.RequestedIDs) > 0 {
+		_, _ = fmt.Fprintf(w, "Requested: specific GPU IDs %v\n", trace.RequestedIDs)
+	} else if trace.GPUClass != "" {
+		_, _ = fmt.Fprintf(w, "Requested: %d GPU(s) of class %s, ranked by your most recent use, then least recently released\n", trace.Requested, trace.GPUClass)
+	} else {
+		_, _ = fmt.Fprintf(w, "Requested: %d GPU(s), ranked by your most recent use, then least recently released\n", trace.Requested)
+	}
+
+	picked := make(map[int]bool)
+	for _, gpuID := range trace.Allocated {
+		picked[gpuID] = true
+	}
+
+	if len(trace.Candidates) > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "Candidates (best first):")
+		_, _ = fmt.Fprintf(w, "  %-6s %-5s %-18s %s\n", "Rank", "GPU", "Your last use", "Last released")
+		_, _ = fmt.Fprintf(w, "  %-6s %-5s %-18s %s\n", "----", "---", "-------------", "-------------")
+		for i, candidate := range trace.Candidates {
+			result := ""
+			if picked[candidate.GPUID] {
+				result = "picked"
+			}
+			line := fmt.Sprintf("  %-6d %-5d %-18s %-18s %s", i+1, candidate.GPUID,
+				traceTimeAgo(candidate.UserLastUsed, now), traceTimeAgo(candidate.LastReleased, now), result)
+			_, _ = fmt.Fprintln(w, strings.TrimRight(line, " "))
+		}
+	}
+
+	exclusions := []struct {
+		gpus   []int
+		reason string
+	}{
+		{trace.Unreserved, "in use without reservation"},
+		{trace.Reserved, "already reserved"},
+		{trace.CoolingDown, "cooling down after release"},
+		{trace.OtherClass, "not in the requested GPU class"},
+		{trace.NearExpiring, "next to a reservation expiring too soon"},
+	}
+	var excluded []string
+	for _, exclusion := range exclusions {
+		if len(exclusion.gpus) > 0 {
+			excluded = append(excluded, fmt.Sprintf("  GPUs %v: %s", exclusion.gpus, exclusion.reason))
+		}
+	}
+	if len(excluded) > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "Excluded:")
+		_, _ = fmt.Fprintln(w, strings.Join(excluded, "\n"))
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if trace.Error != "" {
+		_, _ = fmt.Fprintf(w, "Result: failed: %s\n", trace.Error)
+	} else {
+		_, _ = fmt.Fprintf(w, "Result: allocated GPUs %v\n", trace.Allocated)
+	}
+}
+
+// traceTimeAgo formats a Unix timestamp from an allocation trace
+func traceTimeAgo(seconds float64, now time.Time) string {
+	if seconds <= 0 {
+		return "never"
+	}
+	return utils.FormatDuration(now.Sub(time.Unix(int64(seconds), 0))) + " ago"
+}
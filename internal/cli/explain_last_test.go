@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintAllocationTrace(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	unix := func(d time.Duration) float64 { return float64(now.Add(-d).Unix()) }
+
+	trace := &types.AllocationTrace{
+		Time:      types.FlexibleTime{Time: now.Add(-5 * time.Minute)},
+		User:      "alice",
+		Requested: 2,
+		Candidates: []types.AllocationCandidate{
+			{GPUID: 3, UserLastUsed: unix(2 * time.Hour), LastReleased: unix(time.Hour)},
+			{GPUID: 0, LastReleased: unix(48 * time.Hour)},
+			{GPUID: 1},
+		},
+		Unreserved:   []int{2},
+		Reserved:     []int{4, 5},
+		CoolingDown:  []int{6},
+		OtherClass:   []int{7},
+		NearExpiring: []int{8},
+		GPUClass:     "large",
+		Allocated:    []int{3, 0},
+	}
+
+	var buf bytes.Buffer
+	printAllocationTrace(&buf, trace, now)
+	output := buf.String()
+
+	assert.Contains(t, output, "Last allocation for alice")
+	assert.Contains(t, output, "(0h 5m 0s ago)")
+	assert.Contains(t, output, "Requested: 2 GPU(s) of class large")
+	assert.Regexp(t, `1\s+3\s+2h 0m 0s ago\s+1h 0m 0s ago\s+picked`, output)
+	assert.Regexp(t, `2\s+0\s+never\s+48h 0m 0s ago\s+picked`, output)
+	assert.Regexp(t, `3\s+1\s+never\s+never\n`, output)
+	assert.Contains(t, output, "GPUs [2]: in use without reservation")
+	assert.Contains(t, output, "GPUs [4 5]: already reserved")
+	assert.Contains(t, output, "GPUs [6]: cooling down after release")
+	assert.Contains(t, output, "GPUs [7]: not in the requested GPU class")
+	assert.Contains(t, output, "GPUs [8]: next to a reservation expiring too soon")
+	assert.Contains(t, output, "Result: allocated GPUs [3 0]")
+
+	// Failed requests for specific GPUs show the reason
+	buf.Reset()
+	printAllocationTrace(&buf, &types.AllocationTrace{
+		Time:         types.FlexibleTime{Time: now},
+		User:         "alice",
+		RequestedIDs: []int{1},
+		Error:        "GPU 1 is already reserved by user 'bob'",
+	}, now)
+	output = buf.String()
+
+	assert.Contains(t, output, "Requested: specific GPU IDs [1]")
+	assert.NotContains(t, output, "Candidates")
+	assert.Contains(t, output, "Result: failed: GPU 1 is already reserved by user 'bob'")
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var keepaliveCmd = &cobra.Command{
+	Use:   "keepalive",
+	Short: "Keep renewable GPU reservations alive",
+	Long: `Keep renewable GPU reservations made with 'canhazgpu reserve --renewable'
+alive until this command is stopped.
+
+Each heartbeat (every minute) extends the reservations to their reserved
+duration from now. If the keepalive stops, because it was stopped, its host
+went down, or it can't reach Redis, the reservations are not released right
+away: they expire once their current expiry passes. This gives services that
+can't run under 'canhazgpu run' automatic cleanup while surviving brief
+disconnects.
+
+The command exits with an error if the reservations expire or are released
+while it is running.
+
+Examples:
+  canhazgpu reserve --gpu-ids 1,3 --duration 15m --renewable
+  canhazgpu keepalive --gpu-ids 1,3 &
+  canhazgpu keepalive --gpu-ids 0 --user alice   # Reserved with --user alice`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gpuIDs := viper.GetIntSlice("keepalive.gpu-ids")
+		customUser := viper.GetString("keepalive.user")
+		return runKeepalive(cmd.Context(), gpuIDs, customUser)
+	},
+}
+
+func init() {
+	keepaliveCmd.Flags().IntSliceP("gpu-ids", "G", nil, "GPU IDs of the renewable reservations to keep alive (comma-separated, e.g., 1,3,5)")
+	keepaliveCmd.Flags().StringP("user", "u", "", "Custom user identifier the GPUs were reserved with")
+
+	rootCmd.AddCommand(keepaliveCmd)
+}
+
+func runKeepalive(ctx context.Context, gpuIDs []int, customUser string) error {
+	if len(gpuIDs) == 0 {
+		return fmt.Errorf("--gpu-ids is required")
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	user := getCurrentUser()
+	if customUser != "" {
+		user = customUser
+	}
+
+	keepalive := gpu.NewKeepaliveManager(client, gpuIDs, user)
+	if err := keepalive.Start(); err != nil {
+		return fmt.Errorf("failed to keep GPUs %v alive: %v", gpuIDs, err)
+	}
+	defer keepalive.Stop()
+
+	fmt.Fprintf(os.Stderr, "Keeping GPUs %v alive, extending them by %s on each heartbeat\n",
+		gpuIDs, utils.FormatDuration(keepalive.Timeout()))
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		fmt.Fprintf(os.Stderr, "Keepalive stopped, reservations will expire within %s\n",
+			utils.FormatDuration(keepalive.Timeout()))
+		return nil
+	case <-keepalive.Lost():
+		return fmt.Errorf("lost the reservations for GPUs %v, stopping keepalive", gpuIDs)
+	}
+}
+
+// keepaliveCommand returns the command that keeps a renewable reservation of
+// the given GPUs alive
+func keepaliveCommand(gpuIDs []int, customUser string) string {
+	ids := make([]string, len(gpuIDs))
+	for i, gpuID := range gpuIDs {
+		ids[i] = strconv.Itoa(gpuID)
+	}
+	command := "canhazgpu keepalive --gpu-ids " + strings.Join(ids, ",")
+	if strings.ContainsAny(customUser, " \t'\"") {
+		command += " --user " + strconv.Quote(customUser)
+	} else if customUser != "" {
+		command += " --user " + customUser
+	}
+	return command
+}
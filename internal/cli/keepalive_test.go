@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepaliveCommand(t *testing.T) {
+	assert.Equal(t, "canhazgpu keepalive --gpu-ids 1,3", keepaliveCommand([]int{1, 3}, ""))
+	assert.Equal(t, "canhazgpu keepalive --gpu-ids 0 --user alice", keepaliveCommand([]int{0}, "alice"))
+	assert.Equal(t, `canhazgpu keepalive --gpu-ids 2 --user "Jane Doe"`, keepaliveCommand([]int{2}, "Jane Doe"))
+}
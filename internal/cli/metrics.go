@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Write Prometheus metrics for node_exporter's textfile collector",
+	Long: `Write the GPU pool metrics served by 'canhazgpu daemon --metrics-addr' in
+the Prometheus text format, without a long-running process.
+
+Use --textfile to write them to a file for node_exporter's textfile
+collector, e.g. from a cron job. The file is replaced atomically, so the
+collector never reads a partial file. Without --textfile the metrics are
+printed to stdout.
+
+The daemon's cleanup counters are only kept by a running daemon and are
+not included.
+
+Example usage:
+  canhazgpu metrics
+  canhazgpu metrics --textfile /var/lib/node_exporter/canhazgpu.prom`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetrics(cmd.Context(), viper.GetString("metrics.textfile"))
+	},
+}
+
+func init() {
+	metricsCmd.Flags().String("textfile", "", "Write the metrics to this file (e.g. /var/lib/node_exporter/canhazgpu.prom) instead of stdout")
+	rootCmd.AddCommand(metricsCmd)
+}
+
+func runMetrics(ctx context.Context, textfile string) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	engine := gpu.NewAllocationEngine(client, config)
+
+	// Clean up expired reservations first, like status
+	if err := engine.CleanupExpiredReservations(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to cleanup expired reservations: %v\n", err)
+	}
+
+	statuses, err := engine.GetGPUStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get GPU status: %v", err)
+	}
+	queueLength, err := client.GetQueueLength(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get queue length: %v", err)
+	}
+	latency, err := client.GetAllocationLatencyStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get allocation latency: %v", err)
+	}
+
+	pool := newPoolMetrics(statuses, queueLength, latency)
+	return writeOutput(textfile, func(w io.Writer) error {
+		pool.write(w)
+		return nil
+	})
+}
+
+// poolMetrics are the GPU and queue gauges exported by both 'daemon
+// --metrics-addr' and 'metrics'
+type poolMetrics struct {
+	gpuTotal     int
+	gpusByStatus map[string]int
+	queueLength  int
+	latency      *types.AllocationLatencyStats // nil if it couldn't be read
+}
+
+// newPoolMetrics counts the GPUs of the pool by status
+func newPoolMetrics(statuses []gpu.GPUStatusInfo, queueLength int, latency *types.AllocationLatencyStats) *poolMetrics {
+	m := &poolMetrics{
+		gpuTotal:     len(statuses),
+		gpusByStatus: map[string]int{"AVAILABLE": 0, "IN_USE": 0, "UNRESERVED": 0, "ERROR": 0},
+		queueLength:  queueLength,
+		latency:      latency,
+	}
+	for _, status := range statuses {
+		m.gpusByStatus[status.Status]++
+	}
+	return m
+}
+
+// write renders the metrics in the Prometheus text exposition format
+func (m *poolMetrics) write(w io.Writer) {
+	writeMetric(w, "canhazgpu_gpus_total", "Number of GPUs in the pool.", "gauge",
+		fmt.Sprintf("canhazgpu_gpus_total %d", m.gpuTotal))
+
+	statuses := make([]string, 0, len(m.gpusByStatus))
+	for status := range m.gpusByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	samples := make([]string, len(statuses))
+	for i, status := range statuses {
+		samples[i] = fmt.Sprintf("canhazgpu_gpus{status=%q} %d", strings.ToLower(status), m.gpusByStatus[status])
+	}
+	writeMetric(w, "canhazgpu_gpus", "Number of GPUs by status.", "gauge", samples...)
+
+	writeMetric(w, "canhazgpu_queue_length", "Number of requests waiting in the queue.", "gauge",
+		fmt.Sprintf("canhazgpu_queue_length %d", m.queueLength))
+
+	if m.latency != nil {
+		samples := make([]string, 0, len(m.latency.Buckets)+3)
+		for i, bound := range types.AllocationLatencyBuckets {
+			samples = append(samples, fmt.Sprintf("canhazgpu_allocation_duration_seconds_bucket{le=%q} %d",
+				strconv.FormatFloat(bound, 'g', -1, 64), m.latency.Buckets[i]))
+		}
+		samples = append(samples,
+			fmt.Sprintf("canhazgpu_allocation_duration_seconds_bucket{le=\"+Inf\"} %d", m.latency.Count),
+			fmt.Sprintf("canhazgpu_allocation_duration_seconds_sum %s", strconv.FormatFloat(m.latency.Sum, 'g', -1, 64)),
+			fmt.Sprintf("canhazgpu_allocation_duration_seconds_count %d", m.latency.Count))
+		writeMetric(w, "canhazgpu_allocation_duration_seconds",
+			"Time from the start of an allocation to the GPUs being reserved, including GPU detection and the allocation lock.",
+			"histogram", samples...)
+	}
+}
+
+// writeMetric writes a metric's HELP and TYPE lines followed by its samples
+func writeMetric(w io.Writer, name, help, metricType string, samples ...string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	for _, sample := range samples {
+		fmt.Fprintln(w, sample)
+	}
+}
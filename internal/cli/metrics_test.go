@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolMetrics_Textfile(t *testing.T) {
+	pool := newPoolMetrics([]gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE"},
+		{GPUID: 2, Status: "ERROR"},
+	}, 1, nil)
+
+	path := filepath.Join(t.TempDir(), "canhazgpu.prom")
+	require.NoError(t, writeOutput(path, func(w io.Writer) error {
+		pool.write(w)
+		return nil
+	}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP canhazgpu_gpus_total Number of GPUs in the pool.
+# TYPE canhazgpu_gpus_total gauge
+canhazgpu_gpus_total 3
+# HELP canhazgpu_gpus Number of GPUs by status.
+# TYPE canhazgpu_gpus gauge
+canhazgpu_gpus{status="available"} 1
+canhazgpu_gpus{status="error"} 1
+canhazgpu_gpus{status="in_use"} 1
+canhazgpu_gpus{status="unreserved"} 0
+# HELP canhazgpu_queue_length Number of requests waiting in the queue.
+# TYPE canhazgpu_queue_length gauge
+canhazgpu_queue_length 1
+`, string(data))
+
+	// The daemon serves the same metrics after its own counters
+	metrics := &daemonMetrics{}
+	metrics.recordStatus([]gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE"},
+		{GPUID: 2, Status: "ERROR"},
+	}, 1, nil)
+	var buf strings.Builder
+	metrics.writeMetrics(&buf)
+	assert.True(t, strings.HasSuffix(buf.String(), string(data)))
+}
+
+func TestPoolMetrics_AllocationLatency(t *testing.T) {
+	pool := newPoolMetrics(nil, 0, &types.AllocationLatencyStats{
+		Count:   3,
+		Sum:     1.5,
+		Buckets: []int64{0, 1, 1, 2, 2, 3, 3, 3},
+	})
+
+	var buf strings.Builder
+	pool.write(&buf)
+	assert.Contains(t, buf.String(), `# TYPE canhazgpu_allocation_duration_seconds histogram
+canhazgpu_allocation_duration_seconds_bucket{le="0.05"} 0
+canhazgpu_allocation_duration_seconds_bucket{le="0.1"} 1
+canhazgpu_allocation_duration_seconds_bucket{le="0.25"} 1
+canhazgpu_allocation_duration_seconds_bucket{le="0.5"} 2
+canhazgpu_allocation_duration_seconds_bucket{le="1"} 2
+canhazgpu_allocation_duration_seconds_bucket{le="2.5"} 3
+canhazgpu_allocation_duration_seconds_bucket{le="5"} 3
+canhazgpu_allocation_duration_seconds_bucket{le="10"} 3
+canhazgpu_allocation_duration_seconds_bucket{le="+Inf"} 3
+canhazgpu_allocation_duration_seconds_sum 1.5
+canhazgpu_allocation_duration_seconds_count 3
+`)
+}
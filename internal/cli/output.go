@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats for --format
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+// resolveOutputFormat reports whether JSON should be written, given --json,
+// --format, and the --output path. Without --format, a path ending in .json
+// selects JSON and any other path selects text.
+func resolveOutputFormat(jsonFlag bool, format, path string) (bool, error) {
+	switch format {
+	case "":
+		return jsonFlag || strings.EqualFold(filepath.Ext(path), ".json"), nil
+	case outputFormatJSON:
+		return true, nil
+	case outputFormatText:
+		if jsonFlag {
+			return false, fmt.Errorf("cannot use --json and --format text together")
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid format %q: must be %s or %s", format, outputFormatText, outputFormatJSON)
+	}
+}
+
+// writeOutput calls write with stdout, or with the file at path if one is
+// given. The file is written to a temporary file next to it and renamed into
+// place once write succeeds, so readers never see a partial file.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer func() {
+		// Leaves nothing behind once the file has been renamed into place
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	return nil
+}
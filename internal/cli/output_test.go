@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOutputFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonFlag bool
+		format   string
+		path     string
+		wantJSON bool
+		wantErr  string
+	}{
+		{name: "stdout defaults to text"},
+		{name: "--json", jsonFlag: true, wantJSON: true},
+		{name: ".json path", path: "/var/log/gpu-status.json", wantJSON: true},
+		{name: "upper case .JSON path", path: "status.JSON", wantJSON: true},
+		{name: "other path", path: "/var/log/gpu-status.txt"},
+		{name: "--json with other path", jsonFlag: true, path: "status.log", wantJSON: true},
+		{name: "--format json", format: "json", path: "status.log", wantJSON: true},
+		{name: "--format text overrides extension", format: "text", path: "status.json"},
+		{name: "--format text with --json", jsonFlag: true, format: "text", wantErr: "cannot use --json and --format text together"},
+		{name: "invalid format", format: "yaml", wantErr: `invalid format "yaml"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotJSON, err := resolveOutputFormat(tt.jsonFlag, tt.format, tt.path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantJSON, gotJSON)
+		})
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	// A failed write leaves the existing file alone
+	err := writeOutput(path, func(w io.Writer) error {
+		_, _ = fmt.Fprint(w, "partial")
+		return fmt.Errorf("lost connection")
+	})
+	assert.EqualError(t, err, "lost connection")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+
+	require.NoError(t, writeOutput(path, func(w io.Writer) error {
+		_, err := fmt.Fprint(w, "new")
+		return err
+	}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	err = writeOutput(filepath.Join(dir, "missing", "status.json"), func(w io.Writer) error { return nil })
+	assert.ErrorContains(t, err, "failed to create output file")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/russellb/canhazgpu/internal/gpu"
@@ -21,7 +22,13 @@ var queueCmd = &cobra.Command{
 
 When GPUs are not immediately available, 'run' and 'reserve' commands
 will add entries to a queue and wait for resources to become available.
-This command shows all entries currently waiting in the queue.
+This command shows your entries in the queue by default, with their queue
+position, how many GPUs are still needed, and a rough estimate of how much
+longer it will wait (ETA). Use --all to show everyone's entries.
+
+The ETA assumes reservations last as long as the average reservation in the
+last 7 days of usage history, so it is only a guide. It is shown as "-"
+when there is no usage history to go by.
 
 The queue operates on a First Come First Served (FCFS) basis. Only the
 first entry in the queue can acquire newly available GPUs. As GPUs become
@@ -30,19 +37,22 @@ until all requested GPUs are allocated.
 
 Example usage:
   canhazgpu queue
+  canhazgpu queue --all
   canhazgpu queue --json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		jsonOutput := viper.GetBool("queue.json")
-		return runQueue(cmd.Context(), jsonOutput)
+		showAll := viper.GetBool("queue.all")
+		return runQueue(cmd.Context(), jsonOutput, showAll)
 	},
 }
 
 func init() {
 	queueCmd.Flags().Bool("json", false, "Output in JSON format")
+	queueCmd.Flags().BoolP("all", "a", false, "Show queue entries for all users")
 	rootCmd.AddCommand(queueCmd)
 }
 
-func runQueue(ctx context.Context, jsonOutput bool) error {
+func runQueue(ctx context.Context, jsonOutput bool, showAll bool) error {
 	config := getConfig()
 	client := redis_client.NewClient(config)
 	defer func() {
@@ -52,8 +62,8 @@ func runQueue(ctx context.Context, jsonOutput bool) error {
 	}()
 
 	// Test Redis connection
-	if err := client.Ping(ctx); err != nil {
-		return fmt.Errorf("failed to connect to Redis: %v", err)
+	if err := pingRedis(ctx, client); err != nil {
+		return err
 	}
 
 	// Create allocation engine
@@ -65,15 +75,97 @@ func runQueue(ctx context.Context, jsonOutput bool) error {
 		return fmt.Errorf("failed to get queue status: %v", err)
 	}
 
+	user := ""
+	if !showAll {
+		user = getCurrentUser()
+	}
+	view := newQueueView(status, user, time.Now())
+
 	if jsonOutput {
-		return printQueueJSON(status)
+		return printQueueJSON(view)
+	}
+
+	return printQueueTable(view)
+}
+
+// QueueEntryJSON is a queue entry with its position and progress, for display
+type QueueEntryJSON struct {
+	*types.QueueEntry
+	Position        int    `json:"position"`
+	AllocatedCount  int    `json:"allocated_count"`
+	RemainingCount  int    `json:"remaining_count"`
+	WaitTime        string `json:"wait_time"`
+	WaitTimeSeconds int    `json:"wait_time_seconds"`
+
+	// Rough estimate of how much longer the entry will wait, omitted if
+	// there is none
+	EstimatedWait        string `json:"estimated_wait,omitempty"`
+	EstimatedWaitSeconds *int   `json:"estimated_wait_seconds,omitempty"`
+}
+
+// QueueJSON is the queue as shown by 'canhazgpu queue'
+type QueueJSON struct {
+	Entries            []QueueEntryJSON `json:"entries"`
+	TotalWaiting       int              `json:"total_waiting"`
+	TotalGPUsRequested int              `json:"total_gpus_requested"`
+	TotalGPUsAllocated int              `json:"total_gpus_allocated"`
+	QueueLength        int              `json:"queue_length"` // Entries in the whole queue, including other users'
+	User               string           `json:"user,omitempty"`
+
+	// Limits on new entries (0 = unlimited)
+	MaxQueueLength         int `json:"max_queue_length,omitempty"`
+	MaxQueueEntriesPerUser int `json:"max_queue_entries_per_user,omitempty"`
+}
+
+// newQueueView builds the queue display, keeping only entries owned by user
+// (matched on display or OS user) unless user is empty. Positions are always
+// positions in the whole queue.
+func newQueueView(status *types.QueueStatus, user string, now time.Time) *QueueJSON {
+	view := &QueueJSON{
+		Entries:                []QueueEntryJSON{},
+		QueueLength:            status.TotalWaiting,
+		User:                   user,
+		MaxQueueLength:         status.MaxQueueLength,
+		MaxQueueEntriesPerUser: status.MaxQueueEntriesPerUser,
+	}
+
+	for i, entry := range status.Entries {
+		if user != "" && entry.User != user && entry.ActualUser != user {
+			continue
+		}
+
+		requested := entry.GetRequestedGPUCount()
+		allocated := len(entry.AllocatedGPUs)
+		remaining := requested - allocated
+		if remaining < 0 {
+			remaining = 0
+		}
+		waitTime := now.Sub(entry.EnqueueTime.ToTime())
+
+		entryView := QueueEntryJSON{
+			QueueEntry:      entry,
+			Position:        i + 1,
+			AllocatedCount:  allocated,
+			RemainingCount:  remaining,
+			WaitTime:        utils.FormatDuration(waitTime),
+			WaitTimeSeconds: int(waitTime.Seconds()),
+		}
+		if estimate, ok := status.EstimatedWaits[entry.ID]; ok {
+			seconds := int(estimate.Seconds())
+			entryView.EstimatedWait = formatEstimate(estimate)
+			entryView.EstimatedWaitSeconds = &seconds
+		}
+		view.Entries = append(view.Entries, entryView)
+		view.TotalWaiting++
+		view.TotalGPUsRequested += requested
+		view.TotalGPUsAllocated += allocated
 	}
 
-	return printQueueTable(status)
+	return view
 }
 
-func printQueueJSON(status *types.QueueStatus) error {
-	data, err := json.MarshalIndent(status, "", "  ")
+func printQueueJSON(view *QueueJSON) error {
+	data, err := json.MarshalIndent(view, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal queue status: %v", err)
 	}
@@ -81,51 +173,103 @@ func printQueueJSON(status *types.QueueStatus) error {
 	return nil
 }
 
-func printQueueTable(status *types.QueueStatus) error {
-	if status.TotalWaiting == 0 {
-		fmt.Println("GPU Reservation Queue")
-		fmt.Println("=====================")
-		fmt.Println("No entries waiting in queue.")
+func printQueueTable(view *QueueJSON) error {
+	fmt.Println("GPU Reservation Queue")
+	fmt.Println("=====================")
+
+	if view.TotalWaiting == 0 {
+		if view.User != "" && view.QueueLength > 0 {
+			fmt.Printf("No entries waiting in queue for %s (%d from other users, use --all to show them).\n",
+				view.User, view.QueueLength)
+		} else {
+			fmt.Println("No entries waiting in queue.")
+		}
+		if limits := formatQueueLimits(view); limits != "" {
+			fmt.Println(limits)
+		}
 		return nil
 	}
 
-	fmt.Println("GPU Reservation Queue")
-	fmt.Println("=====================")
 	fmt.Println()
 
 	// Print header
-	fmt.Printf("%-10s %-15s %-15s %-12s %s\n",
-		"Position", "User", "Requested", "Allocated", "Waiting")
-	fmt.Printf("%-10s %-15s %-15s %-12s %s\n",
-		"--------", "----", "---------", "---------", "-------")
+	fmt.Printf("%-10s %-15s %-15s %-12s %-12s %-12s %s\n",
+		"Position", "User", "Requested", "Allocated", "Remaining", "Waiting", "ETA (est.)")
+	fmt.Printf("%-10s %-15s %-15s %-12s %-12s %-12s %s\n",
+		"--------", "----", "---------", "---------", "---------", "-------", "----------")
 
 	// Print entries
-	now := time.Now()
-	for i, entry := range status.Entries {
-		waitTime := now.Sub(entry.EnqueueTime.ToTime())
+	hasEstimates := false
+	for _, entry := range view.Entries {
 		requested := fmt.Sprintf("%d GPUs", entry.GetRequestedGPUCount())
 		if len(entry.RequestedIDs) > 0 {
 			requested = fmt.Sprintf("IDs: %v", entry.RequestedIDs)
 		}
-		allocated := fmt.Sprintf("%d/%d", len(entry.AllocatedGPUs), entry.GetRequestedGPUCount())
+		allocated := fmt.Sprintf("%d/%d", entry.AllocatedCount, entry.GetRequestedGPUCount())
+		eta := "-"
+		if entry.EstimatedWait != "" {
+			eta = entry.EstimatedWait
+			hasEstimates = true
+		}
 
-		fmt.Printf("%-10d %-15s %-15s %-12s %s\n",
-			i+1,
+		fmt.Printf("%-10d %-15s %-15s %-12s %-12d %-12s %s\n",
+			entry.Position,
 			truncateString(entry.User, 15),
 			truncateString(requested, 15),
 			allocated,
-			utils.FormatDuration(waitTime))
+			entry.RemainingCount,
+			entry.WaitTime,
+			eta)
 	}
 
 	fmt.Println()
 	fmt.Printf("Total: %d entries waiting for %d GPUs (%d partially allocated)\n",
-		status.TotalWaiting,
-		status.TotalGPUsRequested-status.TotalGPUsAllocated,
-		status.TotalGPUsAllocated)
+		view.TotalWaiting,
+		view.TotalGPUsRequested-view.TotalGPUsAllocated,
+		view.TotalGPUsAllocated)
+	if hasEstimates {
+		fmt.Println("ETAs are rough estimates based on the average reservation length in recent usage history.")
+	}
+
+	if view.User != "" && view.QueueLength > view.TotalWaiting {
+		fmt.Printf("Showing entries for %s only; %d entries in the whole queue (use --all to show everyone).\n",
+			view.User, view.QueueLength)
+	}
+	if limits := formatQueueLimits(view); limits != "" {
+		fmt.Println(limits)
+	}
 
 	return nil
 }
 
+// formatQueueLimits describes the configured queue limits, e.g. "Queue
+// limits: 20 entries, 3 per user", or returns "" if there are none
+func formatQueueLimits(view *QueueJSON) string {
+	var limits []string
+	if view.MaxQueueLength > 0 {
+		limits = append(limits, fmt.Sprintf("%d entries", view.MaxQueueLength))
+	}
+	if view.MaxQueueEntriesPerUser > 0 {
+		limits = append(limits, fmt.Sprintf("%d per user", view.MaxQueueEntriesPerUser))
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return "Queue limits: " + strings.Join(limits, ", ")
+}
+
+// formatEstimate formats an estimated wait to the minute, e.g. "~1h 20m"
+func formatEstimate(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("~%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("~%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
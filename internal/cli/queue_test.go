@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewQueueView(t *testing.T) {
+	now := time.Now()
+	status := &types.QueueStatus{
+		Entries: []*types.QueueEntry{
+			{
+				ID:             "a",
+				User:           "alice",
+				ActualUser:     "alice",
+				RequestedCount: 4,
+				AllocatedGPUs:  []int{0, 1},
+				EnqueueTime:    types.FlexibleTime{Time: now.Add(-5 * time.Minute)},
+			},
+			{
+				ID:            "b",
+				User:          "bob-experiment",
+				ActualUser:    "bob",
+				RequestedIDs:  []int{2, 3},
+				AllocatedGPUs: []int{},
+				EnqueueTime:   types.FlexibleTime{Time: now.Add(-2 * time.Minute)},
+			},
+			{
+				ID:             "c",
+				User:           "alice",
+				ActualUser:     "alice",
+				RequestedCount: 1,
+				AllocatedGPUs:  []int{},
+				EnqueueTime:    types.FlexibleTime{Time: now.Add(-time.Minute)},
+			},
+		},
+		TotalWaiting: 3,
+		EstimatedWaits: map[string]time.Duration{
+			"a": 20 * time.Minute,
+			"b": 0,
+		},
+	}
+
+	t.Run("AllUsers", func(t *testing.T) {
+		view := newQueueView(status, "", now)
+		require.Len(t, view.Entries, 3)
+		assert.Equal(t, 3, view.TotalWaiting)
+		assert.Equal(t, 3, view.QueueLength)
+		assert.Equal(t, 7, view.TotalGPUsRequested)
+		assert.Equal(t, 2, view.TotalGPUsAllocated)
+
+		first := view.Entries[0]
+		assert.Equal(t, 1, first.Position)
+		assert.Equal(t, 2, first.AllocatedCount)
+		assert.Equal(t, 2, first.RemainingCount)
+		assert.Equal(t, 300, first.WaitTimeSeconds)
+
+		// Specific IDs count towards the requested total
+		assert.Equal(t, 2, view.Entries[1].RemainingCount)
+
+		assert.Equal(t, "~20m", first.EstimatedWait)
+		require.NotNil(t, first.EstimatedWaitSeconds)
+		assert.Equal(t, 1200, *first.EstimatedWaitSeconds)
+		require.NotNil(t, view.Entries[1].EstimatedWaitSeconds)
+		assert.Equal(t, 0, *view.Entries[1].EstimatedWaitSeconds)
+		assert.Nil(t, view.Entries[2].EstimatedWaitSeconds)
+	})
+
+	t.Run("CurrentUserKeepsQueuePositions", func(t *testing.T) {
+		view := newQueueView(status, "alice", now)
+		require.Len(t, view.Entries, 2)
+		assert.Equal(t, 1, view.Entries[0].Position)
+		assert.Equal(t, 3, view.Entries[1].Position)
+		assert.Equal(t, 2, view.TotalWaiting)
+		assert.Equal(t, 3, view.QueueLength)
+	})
+
+	t.Run("MatchesActualUser", func(t *testing.T) {
+		view := newQueueView(status, "bob", now)
+		require.Len(t, view.Entries, 1)
+		assert.Equal(t, 2, view.Entries[0].Position)
+	})
+
+	t.Run("JSONIncludesEntryFields", func(t *testing.T) {
+		view := newQueueView(status, "alice", now)
+		data, err := json.Marshal(view)
+		require.NoError(t, err)
+
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		entries := decoded["entries"].([]interface{})
+		entry := entries[0].(map[string]interface{})
+		assert.Equal(t, "a", entry["id"])
+		assert.Equal(t, "alice", entry["user"])
+		assert.Equal(t, float64(4), entry["requested_count"])
+		assert.Equal(t, float64(2), entry["remaining_count"])
+		assert.Equal(t, float64(1), entry["position"])
+		assert.Equal(t, "~20m", entry["estimated_wait"])
+		assert.Equal(t, float64(1200), entry["estimated_wait_seconds"])
+
+		// Entries without an estimate leave it out
+		entry = entries[1].(map[string]interface{})
+		assert.NotContains(t, entry, "estimated_wait")
+		assert.NotContains(t, entry, "estimated_wait_seconds")
+	})
+}
+
+func TestFormatEstimate(t *testing.T) {
+	assert.Equal(t, "<1m", formatEstimate(0))
+	assert.Equal(t, "<1m", formatEstimate(45*time.Second))
+	assert.Equal(t, "~20m", formatEstimate(19*time.Minute+40*time.Second))
+	assert.Equal(t, "~1h 0m", formatEstimate(59*time.Minute+45*time.Second))
+	assert.Equal(t, "~2h 5m", formatEstimate(2*time.Hour+5*time.Minute))
+}
+
+func TestFormatQueueLimits(t *testing.T) {
+	status := &types.QueueStatus{}
+	assert.Empty(t, formatQueueLimits(newQueueView(status, "", time.Now())))
+
+	status.MaxQueueLength = 20
+	assert.Equal(t, "Queue limits: 20 entries", formatQueueLimits(newQueueView(status, "", time.Now())))
+
+	status.MaxQueueEntriesPerUser = 3
+	view := newQueueView(status, "", time.Now())
+	assert.Equal(t, "Queue limits: 20 entries, 3 per user", formatQueueLimits(view))
+
+	data, err := json.Marshal(view)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"max_queue_length":20,"max_queue_entries_per_user":3`)
+}
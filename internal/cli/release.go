@@ -3,9 +3,14 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"syscall"
 
 	"github.com/russellb/canhazgpu/internal/gpu"
 	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,29 +21,49 @@ var releaseCmd = &cobra.Command{
 	Long: `Release manually reserved GPUs held by the current user.
 
 By default, releases all manually reserved GPUs. You can optionally specify
-which GPU(s) to release using the --gpu-ids flag.
+which GPU(s) to release using the --gpu-ids flag, or release every GPU you
+hold, both manual and run-type reservations, with --all. With --gpu-ids, the
+result is reported for each GPU: released, not owned (reserved by someone
+else), not reserved, or no such GPU.
 
 This command can release:
 - Manual reservations made with the 'reserve' command
 - Run-type reservations made with the 'run' command (useful for cleaning up
   after known failures faster than waiting for heartbeat timeout)
 
+With --kill, processes still running on the GPUs are stopped as well: they
+are sent SIGTERM, and SIGKILL if they haven't exited after a 30-second grace
+period. Only your own processes are killed; processes owned by other users
+are reported and left alone.
+
 Examples:
-  canhazgpu release                # Release all manually reserved GPUs
-  canhazgpu release --gpu-ids 1,3  # Release specific GPUs`,
+  canhazgpu release                       # Release all manually reserved GPUs
+  canhazgpu release --gpu-ids 1,3         # Release specific GPUs
+  canhazgpu release --all                 # Release all of your reservations, including run-type
+  canhazgpu release --gpu-ids 0 --kill    # Release GPU 0 and kill your processes on it`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		gpuIDs := viper.GetIntSlice("release.gpu-ids")
-		return runRelease(cmd.Context(), gpuIDs)
+		kill := viper.GetBool("release.kill")
+		all := viper.GetBool("release.all")
+		// --all on the command line wins over GPU IDs from the config file
+		if all && !cmd.Flags().Changed("gpu-ids") {
+			gpuIDs = nil
+		}
+		return runRelease(cmd.Context(), gpuIDs, all, kill)
 	},
 }
 
 func init() {
 	releaseCmd.Flags().IntSliceP("gpu-ids", "G", nil, "Specific GPU IDs to release (comma-separated, e.g., 1,3,5)")
+	releaseCmd.Flags().Bool("all", false, "Release all of your reservations, both manual and run-type")
+	releaseCmd.Flags().Bool("kill", false, "Also terminate your processes running on the released GPUs")
+	releaseCmd.MarkFlagsMutuallyExclusive("all", "gpu-ids")
+	_ = releaseCmd.RegisterFlagCompletionFunc("gpu-ids", completeReleaseGPUIDs)
 
 	rootCmd.AddCommand(releaseCmd)
 }
 
-func runRelease(ctx context.Context, gpuIDs []int) error {
+func runRelease(ctx context.Context, gpuIDs []int, all bool, kill bool) error {
 	config := getConfig()
 	client := redis_client.NewClient(config)
 	defer func() {
@@ -60,9 +85,21 @@ func runRelease(ctx context.Context, gpuIDs []int) error {
 	var releasedGPUs []int
 	var err error
 
+	var results []gpuReleaseResult
 	if len(gpuIDs) > 0 {
-		// Release specific GPUs
-		releasedGPUs, err = engine.ReleaseSpecificGPUs(ctx, user, gpuIDs)
+		// Release specific GPUs, checking first which ones are ours so that
+		// each GPU can be reported on
+		var owned []int
+		results, owned, err = checkReleaseOwnership(ctx, client, user, gpuIDs)
+		if err != nil {
+			return fmt.Errorf("failed to check GPU reservations: %v", err)
+		}
+		if len(owned) > 0 {
+			releasedGPUs, err = engine.ReleaseSpecificGPUs(ctx, user, owned)
+		}
+	} else if all {
+		// Release every GPU the user holds, manual or run-type
+		releasedGPUs, err = engine.ReleaseAllGPUs(ctx, user)
 	} else {
 		// Release all manually reserved GPUs
 		releasedGPUs, err = engine.ReleaseGPUs(ctx, user)
@@ -72,9 +109,12 @@ func runRelease(ctx context.Context, gpuIDs []int) error {
 		return fmt.Errorf("failed to release GPUs: %v", err)
 	}
 
-	if len(releasedGPUs) == 0 {
-		if len(gpuIDs) > 0 {
-			fmt.Printf("No reservations found for current user on GPU(s): %v\n", gpuIDs)
+	if len(gpuIDs) > 0 {
+		finishReleaseResults(results, releasedGPUs)
+		displayReleaseResults(os.Stdout, results, len(releasedGPUs))
+	} else if len(releasedGPUs) == 0 {
+		if all {
+			fmt.Println("No reservations found for current user")
 		} else {
 			fmt.Println("No manually reserved GPUs found for current user")
 		}
@@ -82,5 +122,80 @@ func runRelease(ctx context.Context, gpuIDs []int) error {
 		fmt.Printf("Released %d GPU(s): %v\n", len(releasedGPUs), releasedGPUs)
 	}
 
+	if kill {
+		// Processes can outlive a reservation that was already cleaned up,
+		// so GPUs named explicitly are checked even if nothing was released
+		killGPUs := releasedGPUs
+		if len(gpuIDs) > 0 {
+			killGPUs = gpuIDs
+		}
+		if err := killGPUProcesses(ctx, engine, killGPUs); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// killGPUProcesses terminates the current user's processes on the given GPUs
+func killGPUProcesses(ctx context.Context, engine *gpu.AllocationEngine, gpuIDs []int) error {
+	if len(gpuIDs) == 0 {
+		return nil
+	}
+
+	owner, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("failed to determine the current user, not killing any processes: %v", err)
+	}
+
+	usage, err := engine.GetGPUUsage(ctx, gpuIDs)
+	if err != nil {
+		return fmt.Errorf("failed to detect processes on GPUs %v: %v", gpuIDs, err)
+	}
+
+	pids, others := ownedProcesses(usage, owner.Username)
+	for _, process := range others {
+		fmt.Printf("Not killing process %d (%s) owned by %s\n", process.PID, process.ProcessName, process.User)
+	}
+	if len(pids) == 0 {
+		fmt.Printf("No processes of yours found on GPU(s): %v\n", gpuIDs)
+		return nil
+	}
+
+	fmt.Printf("Sending SIGTERM to %d process(es): %v\n", len(pids), pids)
+	terminateProcesses(pids, syscall.SIGTERM, killGracePeriod, "")
+	return nil
+}
+
+// ownedProcesses splits the processes found on GPUs into the PIDs owned by
+// owner and the processes owned by anyone else. Processes using several GPUs
+// are listed once. A process whose owner couldn't be determined is treated as
+// someone else's.
+func ownedProcesses(usage map[int]*types.GPUUsage, owner string) ([]int, []types.GPUProcessInfo) {
+	seen := make(map[int]bool)
+	var owned []int
+	var others []types.GPUProcessInfo
+
+	gpuIDs := make([]int, 0, len(usage))
+	for gpuID := range usage {
+		gpuIDs = append(gpuIDs, gpuID)
+	}
+	sort.Ints(gpuIDs)
+
+	for _, gpuID := range gpuIDs {
+		for _, process := range usage[gpuID].Processes {
+			if process.PID <= 0 || seen[process.PID] {
+				continue
+			}
+			seen[process.PID] = true
+
+			if process.User == owner {
+				owned = append(owned, process.PID)
+			} else {
+				others = append(others, process)
+			}
+		}
+	}
+
+	return owned, others
+}
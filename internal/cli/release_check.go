@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/spf13/cobra"
+)
+
+// Outcomes of releasing a GPU named with 'release --gpu-ids'
+const (
+	releaseReleased    = "released"
+	releaseNotOwned    = "not owned"
+	releaseNotReserved = "not reserved"
+	releaseNoSuchGPU   = "no such GPU"
+)
+
+// gpuReleaseResult is what happened to one GPU named with --gpu-ids
+type gpuReleaseResult struct {
+	GPUID   int
+	Outcome string
+	Holder  string // Who holds a GPU that isn't ours
+}
+
+// checkReleaseOwnership looks up each GPU named with --gpu-ids before it's
+// released. It returns a result for each GPU, in the order given and without
+// duplicates, and the GPUs the user holds. Those have no outcome yet: it's
+// filled in by the release, see finishReleaseResults.
+func checkReleaseOwnership(ctx context.Context, client *redis_client.Client, user string, gpuIDs []int) ([]gpuReleaseResult, []int, error) {
+	gpuCount, err := client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []gpuReleaseResult
+	var owned []int
+	seen := make(map[int]bool)
+	for _, gpuID := range gpuIDs {
+		if seen[gpuID] {
+			continue
+		}
+		seen[gpuID] = true
+
+		if gpuID < 0 || gpuID >= gpuCount {
+			results = append(results, gpuReleaseResult{GPUID: gpuID, Outcome: releaseNoSuchGPU})
+			continue
+		}
+		state, err := client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+
+		result := releaseOwnership(gpuID, state, user)
+		if result.Outcome == "" {
+			owned = append(owned, gpuID)
+		}
+		results = append(results, result)
+	}
+	return results, owned, nil
+}
+
+// releaseOwnership returns the result for a GPU the user asked to release,
+// with no outcome if the user holds it
+func releaseOwnership(gpuID int, state *types.GPUState, user string) gpuReleaseResult {
+	result := gpuReleaseResult{GPUID: gpuID}
+	switch {
+	case state.IsShared():
+		if state.ShareOf(user) < 0 {
+			holders := make([]string, len(state.Shares))
+			for i, share := range state.Shares {
+				holders[i] = share.User
+			}
+			result.Outcome = releaseNotOwned
+			result.Holder = strings.Join(holders, ", ")
+		}
+	case state.User == "":
+		result.Outcome = releaseNotReserved
+	case state.User != user:
+		result.Outcome = releaseNotOwned
+		result.Holder = state.User
+	}
+	return result
+}
+
+// finishReleaseResults fills in the outcome of the GPUs the user held. One
+// that wasn't released after all had its reservation end in the meantime.
+func finishReleaseResults(results []gpuReleaseResult, released []int) {
+	for i := range results {
+		if results[i].Outcome != "" {
+			continue
+		}
+		if slices.Contains(released, results[i].GPUID) {
+			results[i].Outcome = releaseReleased
+		} else {
+			results[i].Outcome = releaseNotReserved
+		}
+	}
+}
+
+// displayReleaseResults prints the outcome for each GPU named with --gpu-ids
+func displayReleaseResults(w io.Writer, results []gpuReleaseResult, released int) {
+	for _, result := range results {
+		if result.Holder != "" {
+			fmt.Fprintf(w, "GPU %d: %s (reserved by %s)\n", result.GPUID, result.Outcome, result.Holder)
+		} else {
+			fmt.Fprintf(w, "GPU %d: %s\n", result.GPUID, result.Outcome)
+		}
+	}
+	fmt.Fprintf(w, "Released %d of %d GPU(s)\n", released, len(results))
+}
+
+// completeReleaseGPUIDs completes --gpu-ids with the GPUs the current user
+// holds
+func completeReleaseGPUIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client := redis_client.NewClient(getConfig())
+	defer func() {
+		_ = client.Close()
+	}()
+
+	gpuCount, err := client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	user := getCurrentUser()
+	held := make(map[int]string)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			continue
+		}
+		if releaseOwnership(gpuID, state, user).Outcome == "" {
+			held[gpuID] = state.Type
+		}
+	}
+	return gpuIDCompletions(held, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// gpuIDCompletions returns completions for a comma-separated list of GPU IDs
+// from the held GPUs, keyed by ID with their reservation type. IDs already
+// in the list aren't offered again.
+func gpuIDCompletions(held map[int]string, toComplete string) []string {
+	prefix := ""
+	listed := make(map[string]bool)
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+		for _, id := range strings.Split(toComplete[:i], ",") {
+			listed[strings.TrimSpace(id)] = true
+		}
+	}
+
+	gpuIDs := make([]int, 0, len(held))
+	for gpuID := range held {
+		gpuIDs = append(gpuIDs, gpuID)
+	}
+	slices.Sort(gpuIDs)
+
+	var completions []string
+	for _, gpuID := range gpuIDs {
+		id := strconv.Itoa(gpuID)
+		if listed[id] {
+			continue
+		}
+		completions = append(completions, fmt.Sprintf("%s%s\t%s reservation", prefix, id, held[gpuID]))
+	}
+	return completions
+}
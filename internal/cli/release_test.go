@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnedProcesses(t *testing.T) {
+	usage := map[int]*types.GPUUsage{
+		0: {GPUID: 0, Processes: []types.GPUProcessInfo{
+			{PID: 100, ProcessName: "python", User: "alice"},
+			{PID: 200, ProcessName: "python", User: "bob"},
+		}},
+		1: {GPUID: 1, Processes: []types.GPUProcessInfo{
+			{PID: 100, ProcessName: "python", User: "alice"}, // same process on a second GPU
+			{PID: 300, ProcessName: "vllm", User: "alice"},
+			{PID: 400, ProcessName: "unknown", User: "unknown"},
+		}},
+		2: {GPUID: 2},
+	}
+
+	owned, others := ownedProcesses(usage, "alice")
+	assert.Equal(t, []int{100, 300}, owned)
+	assert.Equal(t, []types.GPUProcessInfo{
+		{PID: 200, ProcessName: "python", User: "bob"},
+		{PID: 400, ProcessName: "unknown", User: "unknown"},
+	}, others)
+
+	owned, others = ownedProcesses(nil, "alice")
+	assert.Empty(t, owned)
+	assert.Empty(t, others)
+}
+
+func TestReleaseOwnership(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   *types.GPUState
+		outcome string
+		holder  string
+	}{
+		{"ours", &types.GPUState{User: "alice", Type: types.ReservationTypeManual}, "", ""},
+		{"someone else's", &types.GPUState{User: "bob", Type: types.ReservationTypeRun}, releaseNotOwned, "bob"},
+		{"available", &types.GPUState{}, releaseNotReserved, ""},
+		{"our share", &types.GPUState{Type: types.ReservationTypeShared, Shares: []types.GPUShare{{User: "bob"}, {User: "alice"}}}, "", ""},
+		{"others' shares", &types.GPUState{Type: types.ReservationTypeShared, Shares: []types.GPUShare{{User: "bob"}, {User: "carol"}}}, releaseNotOwned, "bob, carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := releaseOwnership(3, tt.state, "alice")
+			assert.Equal(t, gpuReleaseResult{GPUID: 3, Outcome: tt.outcome, Holder: tt.holder}, result)
+		})
+	}
+}
+
+func TestDisplayReleaseResults(t *testing.T) {
+	results := []gpuReleaseResult{
+		{GPUID: 1},
+		{GPUID: 2},
+		{GPUID: 3, Outcome: releaseNotOwned, Holder: "bob"},
+		{GPUID: 5, Outcome: releaseNotReserved},
+		{GPUID: 9, Outcome: releaseNoSuchGPU},
+	}
+	// GPU 2 was released by someone else between the check and the release
+	finishReleaseResults(results, []int{1})
+
+	var buf bytes.Buffer
+	displayReleaseResults(&buf, results, 1)
+	assert.Equal(t, `GPU 1: released
+GPU 2: not reserved
+GPU 3: not owned (reserved by bob)
+GPU 5: not reserved
+GPU 9: no such GPU
+Released 1 of 5 GPU(s)
+`, buf.String())
+}
+
+func TestGPUIDCompletions(t *testing.T) {
+	held := map[int]string{3: types.ReservationTypeRun, 0: types.ReservationTypeManual, 5: types.ReservationTypeManual}
+
+	assert.Equal(t, []string{"0\tmanual reservation", "3\trun reservation", "5\tmanual reservation"}, gpuIDCompletions(held, ""))
+	assert.Equal(t, []string{"0,3\trun reservation", "0,5\tmanual reservation"}, gpuIDCompletions(held, "0,"))
+	assert.Empty(t, gpuIDCompletions(nil, ""))
+}
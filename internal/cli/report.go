@@ -1,39 +1,107 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/russellb/canhazgpu/internal/gpu"
 	"github.com/russellb/canhazgpu/internal/redis_client"
 	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var (
 	reportDays       int
 	reportJSONOutput bool
+	reportFollow     bool
+	reportInterval   string
+	reportTop        int
+	reportMinHours   float64
+	reportOutput     string
+	reportFormat     string
+	reportType       string
 )
 
 var reportCmd = &cobra.Command{
 	Use:   "report",
 	Short: "Generate GPU reservation reports",
-	Long:  `Generate reports on GPU reservations over time, showing reservation data by user and aggregate totals.`,
-	RunE:  runReport,
+	Long: `Generate reports on GPU reservations over time, showing reservation data by user and aggregate totals.
+
+Use --follow to keep the report on screen and recompute it every --interval
+(30s by default), including the time accrued so far by reservations that are
+still in progress. Press Ctrl+C to stop.
+
+Use --output <path> to write the report to a file instead of stdout. The file
+is replaced atomically, so readers never see a partial report. Paths ending in
+.json get JSON unless --format text is given; use --format json for any other
+path.
+
+Use --type run or --type manual to report only 'canhazgpu run' reservations or
+only manual reservations (including shared ones), for example to tell compute
+from parked GPUs. Totals and percentages then cover that type alone.`,
+	RunE: runReport,
 }
 
 func init() {
 	reportCmd.Flags().IntVarP(&reportDays, "days", "d", 30, "Number of days to include in the report")
 	reportCmd.Flags().BoolVarP(&reportJSONOutput, "json", "j", false, "Output report as JSON")
+	reportCmd.Flags().BoolVarP(&reportFollow, "follow", "f", false, "Recompute and redraw the report periodically until interrupted")
+	reportCmd.Flags().StringVar(&reportInterval, "interval", "30s", "How often to refresh the report with --follow (e.g., 10s, 1m)")
+	reportCmd.Flags().IntVar(&reportTop, "top", 0, "Show only the N users with the most GPU hours (0 shows every user)")
+	reportCmd.Flags().Float64Var(&reportMinHours, "min-hours", 0, "Hide users with fewer GPU hours than this")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "Write the report to this file instead of stdout")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "", "Output format: text or json (default: json for --output paths ending in .json, otherwise text)")
+	reportCmd.Flags().StringVar(&reportType, "type", "", "Only include reservations of this type: run or manual (default: both)")
 	rootCmd.AddCommand(reportCmd)
 }
 
 func runReport(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 
+	if reportTop < 0 {
+		return invalidArgument(fmt.Errorf("invalid --top value %d: must be 0 or more", reportTop))
+	}
+	if reportMinHours < 0 {
+		return invalidArgument(fmt.Errorf("invalid --min-hours value %g: must be 0 or more", reportMinHours))
+	}
+
+	jsonReport, err := resolveOutputFormat(reportJSONOutput, reportFormat, reportOutput)
+	if err != nil {
+		return invalidArgument(err)
+	}
+
+	reportType, err = parseReportType(reportType)
+	if err != nil {
+		return invalidArgument(err)
+	}
+
+	var interval time.Duration
+	if reportFollow {
+		if jsonReport {
+			return invalidArgument(fmt.Errorf("cannot use --follow and --json together"))
+		}
+		if reportOutput != "" {
+			return invalidArgument(fmt.Errorf("cannot use --follow and --output together"))
+		}
+		interval, err = utils.ParseDuration(reportInterval)
+		if err != nil {
+			return invalidArgument(fmt.Errorf("invalid interval format: %v", err))
+		}
+		if interval <= 0 {
+			return invalidArgument(fmt.Errorf("invalid interval: must be greater than 0"))
+		}
+	}
+
 	// Initialize Redis client
 	config := getConfig()
 	client := redis_client.NewClient(config)
@@ -44,46 +112,151 @@ func runReport(cmd *cobra.Command, args []string) error {
 	}()
 
 	// Test connection
-	if err := client.Ping(ctx); err != nil {
-		return fmt.Errorf("failed to connect to Redis: %v", err)
+	if err := pingRedis(ctx, client); err != nil {
+		return err
+	}
+
+	if config.DisableUsageHistory {
+		fmt.Fprintln(os.Stderr, "Note: usage history recording is disabled (record_usage_history: false), so completed reservations only appear if they were recorded before it was disabled")
+	}
+
+	ae := gpu.NewAllocationEngine(client, config)
+
+	if reportFollow {
+		return followReport(ctx, client, ae, config, interval)
 	}
 
 	// Calculate time range
 	endTime := time.Now()
 	startTime := endTime.AddDate(0, 0, -reportDays)
 
+	allRecords, err := collectReportRecords(ctx, client, ae, config, startTime, endTime, getCurrentUsageRecords)
+	if err != nil {
+		return err
+	}
+
+	// Generate and display report
+	return writeOutput(reportOutput, func(w io.Writer) error {
+		if jsonReport {
+			return displayReportJSON(w, allRecords, startTime, endTime, config.GPUHourWeights)
+		}
+		displayReport(w, allRecords, startTime, endTime, config.GPUHourWeights)
+		return nil
+	})
+}
+
+// collectReportRecords returns the usage history for the report period plus
+// records for the reservations still in progress, built by currentRecords,
+// attributed to the owners of service accounts
+func collectReportRecords(ctx context.Context, client *redis_client.Client, ae *gpu.AllocationEngine, config *types.Config, startTime, endTime time.Time,
+	currentRecords func([]gpu.GPUStatusInfo, time.Time) []*types.UsageRecord) ([]*types.UsageRecord, error) {
 	// Get historical usage data
 	historicalRecords, err := client.GetUsageHistory(ctx, startTime, endTime)
 	if err != nil {
-		return fmt.Errorf("failed to get usage history: %v", err)
+		return nil, fmt.Errorf("failed to get usage history: %v", err)
 	}
 
 	// Get current GPU states for in-progress usage
-	ae := gpu.NewAllocationEngine(client, config)
 	currentStatuses, err := ae.GetGPUStatus(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get current GPU status: %v", err)
+		return nil, fmt.Errorf("failed to get current GPU status: %v", err)
 	}
 
-	// Add current usage to records
-	currentRecords := getCurrentUsageRecords(currentStatuses, endTime)
-	allRecords := append(historicalRecords, currentRecords...)
+	records := append(historicalRecords, currentRecords(currentStatuses, endTime)...)
+	attributeToOwners(records, config)
+	return filterRecordsByType(records, reportType), nil
+}
 
-	// Generate and display report
-	if reportJSONOutput {
-		displayReportJSON(allRecords, startTime, endTime)
-	} else {
-		displayReport(allRecords, startTime, endTime)
+// attributeToOwners credits usage by an OS account listed in process_owners,
+// such as a service account, to the person or team responsible for it
+func attributeToOwners(records []*types.UsageRecord, config *types.Config) {
+	for _, record := range records {
+		record.User = config.ProcessOwner(record.User)
+	}
+}
+
+// parseReportType checks a --type value, returning it in lower case. An empty
+// value includes every reservation type.
+func parseReportType(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", types.ReservationTypeRun, types.ReservationTypeManual:
+		return value, nil
 	}
+	return "", fmt.Errorf("invalid --type %q: must be %s or %s", value, types.ReservationTypeRun, types.ReservationTypeManual)
+}
+
+// filterRecordsByType keeps the usage records of one reservation type, as
+// returned by parseReportType. Shared reservations count as manual, since
+// they are made with 'canhazgpu reserve'.
+func filterRecordsByType(records []*types.UsageRecord, reservationType string) []*types.UsageRecord {
+	if reservationType == "" {
+		return records
+	}
+
+	var filtered []*types.UsageRecord
+	for _, record := range records {
+		isRun := record.ReservationType == types.ReservationTypeRun
+		if isRun == (reservationType == types.ReservationTypeRun) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// followReport redraws the report every interval until interrupted. In-progress
+// reservations are counted for their full elapsed time, as in the web
+// dashboard, so their hours grow with each refresh.
+func followReport(ctx context.Context, client *redis_client.Client, ae *gpu.AllocationEngine, config *types.Config, interval time.Duration) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	redraw := isTerminal(os.Stdout)
+	for {
+		endTime := time.Now()
+		startTime := endTime.AddDate(0, 0, -reportDays)
+
+		records, err := collectReportRecords(ctx, client, ae, config, startTime, endTime, getCurrentUsageRecordsWeb)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if redraw {
+			// Move the cursor home and clear the screen
+			fmt.Print("\033[H\033[2J")
+		}
+		if err != nil {
+			// Keep following through transient Redis errors
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			displayReport(os.Stdout, records, startTime, endTime, config.GPUHourWeights)
+		}
+		fmt.Printf("Updated %s, refreshing every %s (Ctrl+C to stop)\n",
+			endTime.Format("15:04:05"), utils.FormatDuration(interval))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
 
-	return nil
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func getCurrentUsageRecords(statuses []gpu.GPUStatusInfo, now time.Time) []*types.UsageRecord {
 	var records []*types.UsageRecord
 
 	for _, status := range statuses {
-		if status.Status == "IN_USE" && status.User != "" {
+		if status.Status == "IN_USE" && len(status.Shares) > 0 {
+			records = append(records, sharedUsageRecords(status, now)...)
+		} else if status.Status == "IN_USE" && status.User != "" {
 			// Calculate duration from start time to now
 			duration := now.Sub(status.LastHeartbeat).Seconds()
 			if status.ReservationType == types.ReservationTypeManual && !status.ExpiryTime.IsZero() {
@@ -98,29 +271,70 @@ func getCurrentUsageRecords(statuses []gpu.GPUStatusInfo, now time.Time) []*type
 				EndTime:         types.FlexibleTime{Time: now},
 				Duration:        duration,
 				ReservationType: status.ReservationType,
+				Account:         status.Account,
+				Host:            utils.Hostname(),
+				GPUModel:        status.GPUModel,
 			}
-			records = append(records, record)
+			records = append(records, sinceCheckpoint(record, status))
 		}
 	}
 
 	return records
 }
 
-func displayReport(records []*types.UsageRecord, startTime, endTime time.Time) {
+// sinceCheckpoint trims the record of an in-progress reservation that has
+// been checkpointed to the time since its last checkpoint, since the usage
+// history already covers the time before
+func sinceCheckpoint(record *types.UsageRecord, status gpu.GPUStatusInfo) *types.UsageRecord {
+	if status.LastCheckpoint.IsZero() {
+		return record
+	}
+	record.ReservationStart = types.FlexibleTime{Time: status.StartTime}
+	record.StartTime = types.FlexibleTime{Time: status.LastCheckpoint}
+	record.Duration = record.EndTime.ToTime().Sub(status.LastCheckpoint).Seconds()
+	return record
+}
+
+// sharedUsageRecords returns a usage record for each holder of a shared GPU,
+// so that every holder is billed for their own share
+func sharedUsageRecords(status gpu.GPUStatusInfo, now time.Time) []*types.UsageRecord {
+	records := make([]*types.UsageRecord, len(status.Shares))
+	for i, share := range status.Shares {
+		records[i] = &types.UsageRecord{
+			User:            share.User,
+			GPUID:           status.GPUID,
+			StartTime:       share.StartTime,
+			EndTime:         types.FlexibleTime{Time: now},
+			Duration:        now.Sub(share.StartTime.ToTime()).Seconds(),
+			ReservationType: types.ReservationTypeShared,
+			Account:         share.Account,
+			Host:            utils.Hostname(),
+			GPUModel:        status.GPUModel,
+		}
+	}
+	return records
+}
+
+func displayReport(w io.Writer, records []*types.UsageRecord, startTime, endTime time.Time, weights map[string]float64) {
 	// Aggregate usage by user
 	userUsage := make(map[string]float64)
 	userGPUHours := make(map[string]float64)
+	userWeightedGPUHours := make(map[string]float64)
 	userRunCount := make(map[string]int)
 	userManualCount := make(map[string]int)
 
-	var totalDuration float64
+	var totalDuration, totalWeightedGPUHours float64
 
 	for _, record := range records {
 		userUsage[record.User] += record.Duration
 		userGPUHours[record.User] += record.Duration / 3600.0
+		userWeightedGPUHours[record.User] += weightedGPUHours(record, weights)
 		totalDuration += record.Duration
+		totalWeightedGPUHours += weightedGPUHours(record, weights)
 
-		if record.ReservationType == types.ReservationTypeRun {
+		if record.Checkpoint {
+			// The reservation is counted once, by its final record
+		} else if record.ReservationType == types.ReservationTypeRun {
 			userRunCount[record.User]++
 		} else {
 			userManualCount[record.User]++
@@ -135,80 +349,255 @@ func displayReport(records []*types.UsageRecord, startTime, endTime time.Time) {
 	sort.Slice(users, func(i, j int) bool {
 		return userUsage[users[i]] > userUsage[users[j]]
 	})
+	shownUsers := limitReportUsers(users, func(user string) float64 { return userGPUHours[user] }, reportTop, reportMinHours)
 
 	// Display report header
-	fmt.Printf("\n=== GPU Reservation Report ===\n")
-	fmt.Printf("Period: %s to %s (%d days)\n",
+	fmt.Fprintf(w, "\n=== GPU Reservation Report ===\n")
+	fmt.Fprintf(w, "Period: %s to %s (%d days)\n",
 		startTime.Format("2006-01-02"),
 		endTime.Format("2006-01-02"),
 		reportDays)
-	fmt.Printf("\n")
+	if reportType != "" {
+		fmt.Fprintf(w, "Reservation type: %s only\n", reportType)
+	}
+	fmt.Fprintf(w, "\n")
+
+	// Weighted GPU hours are only shown when weights are configured
+	weighted := len(weights) > 0
+	printHeader := func(name string) {
+		if weighted {
+			fmt.Fprintf(w, "%-20s %15s %15s %15s %10s %10s\n",
+				name, "GPU Hours", "Weighted Hours", "Percentage", "Run", "Manual")
+			fmt.Fprintf(w, "%s\n", strings.Repeat("-", 91))
+		} else {
+			fmt.Fprintf(w, "%-20s %15s %15s %10s %10s\n",
+				name, "GPU Hours", "Percentage", "Run", "Manual")
+			fmt.Fprintf(w, "%s\n", strings.Repeat("-", 75))
+		}
+	}
+	printRow := func(name string, gpuHours, weightedHours float64, percentage string, runCount, manualCount int) {
+		if weighted {
+			fmt.Fprintf(w, "%-20s %15.2f %15.2f %15s %10d %10d\n",
+				name, gpuHours, weightedHours, percentage, runCount, manualCount)
+		} else {
+			fmt.Fprintf(w, "%-20s %15.2f %15s %10d %10d\n",
+				name, gpuHours, percentage, runCount, manualCount)
+		}
+	}
 
 	// Display per-user statistics
-	fmt.Printf("%-20s %15s %15s %10s %10s\n",
-		"User", "GPU Hours", "Percentage", "Run", "Manual")
-	fmt.Printf("%s\n", strings.Repeat("-", 75))
+	printHeader("User")
 
 	totalGPUHours := totalDuration / 3600.0
-	for _, user := range users {
+	for _, user := range shownUsers {
 		percentage := (userUsage[user] / totalDuration) * 100
-		fmt.Printf("%-20s %15.2f %14.1f%% %10d %10d\n",
-			user,
+		printRow(user,
 			userGPUHours[user],
-			percentage,
+			userWeightedGPUHours[user],
+			fmt.Sprintf("%.1f%%", percentage),
 			userRunCount[user],
 			userManualCount[user])
 	}
 
+	if hidden := len(users) - len(shownUsers); hidden > 0 {
+		fmt.Fprintf(w, "(%d more user(s) not shown)\n", hidden)
+	}
+
 	// Display summary
-	fmt.Printf("%s\n", strings.Repeat("-", 75))
-	fmt.Printf("%-20s %15.2f %14s %10d %10d\n",
-		"TOTAL",
+	if weighted {
+		fmt.Fprintf(w, "%s\n", strings.Repeat("-", 91))
+	} else {
+		fmt.Fprintf(w, "%s\n", strings.Repeat("-", 75))
+	}
+	printRow("TOTAL",
 		totalGPUHours,
+		totalWeightedGPUHours,
 		"100.0%",
-		len(records),
+		countReservations(records),
 		0)
 
-	fmt.Printf("\nTotal reservations: %d\n", len(records))
-	fmt.Printf("Unique users: %d\n", len(users))
-	fmt.Printf("\n")
+	fmt.Fprintf(w, "\nTotal reservations: %d\n", countReservations(records))
+	fmt.Fprintf(w, "Unique users: %d\n", len(users))
+	fmt.Fprintf(w, "\n")
+
+	// Display how long reservations lasted, to tell many short jobs from a
+	// few long ones
+	if overall := reservationDurationStats(records); overall != nil {
+		userStats := reservationDurationStatsByUser(records)
+		printStats := func(name string, stats *ReportDurationStatsJSON) {
+			fmt.Fprintf(w, "%-20s %15s %15s %15s %10d\n", name,
+				formatStatsSeconds(stats.MedianSeconds),
+				formatStatsSeconds(stats.P90Seconds),
+				formatStatsSeconds(stats.MaxSeconds),
+				stats.Count)
+		}
+
+		fmt.Fprintf(w, "%-20s %15s %15s %15s %10s\n", "Reservation Length", "Median", "P90", "Max", "Count")
+		fmt.Fprintf(w, "%s\n", strings.Repeat("-", 79))
+		for _, user := range shownUsers {
+			printStats(user, userStats[user])
+		}
+		fmt.Fprintf(w, "%s\n", strings.Repeat("-", 79))
+		printStats("TOTAL", overall)
+		fmt.Fprintf(w, "\n")
+	}
+
+	// Display per-account statistics if any usage is billed to an account
+	if accounts := aggregateByAccount(records, weights); len(accounts) > 0 {
+		printHeader("Account")
+		for _, account := range accounts {
+			printRow(account.Name,
+				account.GPUHours,
+				account.WeightedGPUHours,
+				fmt.Sprintf("%.1f%%", account.Percentage),
+				account.RunCount,
+				account.ManualCount)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// noAccount is the report label for usage that isn't billed to an account
+const noAccount = "(none)"
+
+// aggregateByAccount sums usage per account, most used first. It returns nil
+// if no usage is billed to an account.
+func aggregateByAccount(records []*types.UsageRecord, weights map[string]float64) []ReportAccountJSON {
+	usage := make(map[string]*ReportAccountJSON)
+	var totalDuration float64
+	hasAccount := false
+
+	for _, record := range records {
+		name := record.Account
+		if name == "" {
+			name = noAccount
+		} else {
+			hasAccount = true
+		}
+
+		entry, ok := usage[name]
+		if !ok {
+			entry = &ReportAccountJSON{Name: name}
+			usage[name] = entry
+		}
+		entry.GPUHours += record.Duration / 3600.0
+		entry.WeightedGPUHours += weightedGPUHours(record, weights)
+		totalDuration += record.Duration
+
+		if record.Checkpoint {
+			// The reservation is counted once, by its final record
+		} else if record.ReservationType == types.ReservationTypeRun {
+			entry.RunCount++
+		} else {
+			entry.ManualCount++
+		}
+	}
+
+	if !hasAccount {
+		return nil
+	}
+
+	accounts := make([]ReportAccountJSON, 0, len(usage))
+	for _, entry := range usage {
+		if totalDuration > 0 {
+			entry.Percentage = (entry.GPUHours * 3600.0 / totalDuration) * 100
+		}
+		accounts = append(accounts, *entry)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].GPUHours != accounts[j].GPUHours {
+			return accounts[i].GPUHours > accounts[j].GPUHours
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	return accounts
 }
 
+// reportJSONSchemaVersion is the version of the 'report --json' output. See
+// statusJSONSchemaVersion for when it changes.
+const reportJSONSchemaVersion = 1
+
 // ReportJSON is the JSON output structure for the report command
 type ReportJSON struct {
-	Users             []ReportUserJSON `json:"users"`
-	TotalGPUHours     float64          `json:"total_gpu_hours"`
-	TotalReservations int              `json:"total_reservations"`
-	UniqueUsers       int              `json:"unique_users"`
-	StartDate         string           `json:"start_date"`
-	EndDate           string           `json:"end_date"`
-	Days              int              `json:"days"`
+	SchemaVersion         int                 `json:"schema_version"`
+	Users                 []ReportUserJSON    `json:"users"`
+	Accounts              []ReportAccountJSON `json:"accounts,omitempty"`
+	TotalGPUHours         float64             `json:"total_gpu_hours"`
+	TotalWeightedGPUHours float64             `json:"total_weighted_gpu_hours"`
+	TotalReservations     int                 `json:"total_reservations"`
+	UniqueUsers           int                 `json:"unique_users"`
+	StartDate             string              `json:"start_date"`
+	EndDate               string              `json:"end_date"`
+	Days                  int                 `json:"days"`
+	Type                  string              `json:"type,omitempty"` // Reservation type the report is limited to, if any
+
+	DurationStats *ReportDurationStatsJSON `json:"duration_stats,omitempty"`
 }
 
 // ReportUserJSON is the JSON output structure for per-user report data
 type ReportUserJSON struct {
-	Name        string  `json:"name"`
-	GPUHours    float64 `json:"gpu_hours"`
-	Percentage  float64 `json:"percentage"`
-	RunCount    int     `json:"run_count"`
-	ManualCount int     `json:"manual_count"`
+	Name             string  `json:"name"`
+	GPUHours         float64 `json:"gpu_hours"`
+	WeightedGPUHours float64 `json:"weighted_gpu_hours"`
+	Percentage       float64 `json:"percentage"`
+	RunCount         int     `json:"run_count"`
+	ManualCount      int     `json:"manual_count"`
+
+	DurationStats *ReportDurationStatsJSON `json:"duration_stats,omitempty"`
+}
+
+// ReportDurationStatsJSON summarizes how long reservations lasted
+type ReportDurationStatsJSON struct {
+	Count         int     `json:"count"`
+	MedianSeconds float64 `json:"median_seconds"`
+	P90Seconds    float64 `json:"p90_seconds"`
+	MaxSeconds    float64 `json:"max_seconds"`
+}
+
+// ReportAccountJSON is the JSON output structure for per-account report data
+type ReportAccountJSON struct {
+	Name             string  `json:"name"`
+	GPUHours         float64 `json:"gpu_hours"`
+	WeightedGPUHours float64 `json:"weighted_gpu_hours"`
+	Percentage       float64 `json:"percentage"`
+	RunCount         int     `json:"run_count"`
+	ManualCount      int     `json:"manual_count"`
+}
+
+func displayReportJSON(w io.Writer, records []*types.UsageRecord, startTime, endTime time.Time, weights map[string]float64) error {
+	report := buildReportJSON(records, startTime, endTime, weights)
+
+	// Output JSON
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
 }
 
-func displayReportJSON(records []*types.UsageRecord, startTime, endTime time.Time) {
+// buildReportJSON aggregates usage records by user for JSON output
+func buildReportJSON(records []*types.UsageRecord, startTime, endTime time.Time, weights map[string]float64) ReportJSON {
 	// Aggregate usage by user
 	userUsage := make(map[string]float64)
 	userGPUHours := make(map[string]float64)
+	userWeightedGPUHours := make(map[string]float64)
 	userRunCount := make(map[string]int)
 	userManualCount := make(map[string]int)
 
-	var totalDuration float64
+	var totalDuration, totalWeightedGPUHours float64
 
 	for _, record := range records {
 		userUsage[record.User] += record.Duration
 		userGPUHours[record.User] += record.Duration / 3600.0
+		userWeightedGPUHours[record.User] += weightedGPUHours(record, weights)
 		totalDuration += record.Duration
+		totalWeightedGPUHours += weightedGPUHours(record, weights)
 
-		if record.ReservationType == types.ReservationTypeRun {
+		if record.Checkpoint {
+			// The reservation is counted once, by its final record
+		} else if record.ReservationType == types.ReservationTypeRun {
 			userRunCount[record.User]++
 		} else {
 			userManualCount[record.User]++
@@ -228,33 +617,138 @@ func displayReportJSON(records []*types.UsageRecord, startTime, endTime time.Tim
 
 	// Build JSON output
 	report := ReportJSON{
-		TotalGPUHours:     totalGPUHours,
-		TotalReservations: len(records),
-		UniqueUsers:       len(users),
-		StartDate:         startTime.Format("2006-01-02"),
-		EndDate:           endTime.Format("2006-01-02"),
-		Days:              reportDays,
+		SchemaVersion:         reportJSONSchemaVersion,
+		TotalGPUHours:         totalGPUHours,
+		TotalWeightedGPUHours: totalWeightedGPUHours,
+		TotalReservations:     countReservations(records),
+		UniqueUsers:           len(users),
+		StartDate:             startTime.Format("2006-01-02"),
+		EndDate:               endTime.Format("2006-01-02"),
+		Days:                  reportDays,
+		Type:                  reportType,
+		Accounts:              aggregateByAccount(records, weights),
+		DurationStats:         reservationDurationStats(records),
 	}
 
-	for _, user := range users {
+	userStats := reservationDurationStatsByUser(records)
+	for _, user := range limitReportUsers(users, func(user string) float64 { return userGPUHours[user] }, reportTop, reportMinHours) {
 		percentage := 0.0
 		if totalDuration > 0 {
 			percentage = (userUsage[user] / totalDuration) * 100
 		}
 		report.Users = append(report.Users, ReportUserJSON{
-			Name:        user,
-			GPUHours:    userGPUHours[user],
-			Percentage:  percentage,
-			RunCount:    userRunCount[user],
-			ManualCount: userManualCount[user],
+			Name:             user,
+			GPUHours:         userGPUHours[user],
+			WeightedGPUHours: userWeightedGPUHours[user],
+			Percentage:       percentage,
+			RunCount:         userRunCount[user],
+			ManualCount:      userManualCount[user],
+			DurationStats:    userStats[user],
 		})
 	}
 
-	// Output JSON
-	jsonData, err := json.MarshalIndent(report, "", "  ")
-	if err != nil {
-		fmt.Printf("Error encoding JSON: %v\n", err)
-		return
+	return report
+}
+
+// gpuHourWeight returns how much an hour on a GPU of the given model counts
+// for in weighted GPU hours. Patterns in weights are matched
+// case-insensitively against the model and the longest match wins. Models
+// that match no pattern, including unknown models, weigh 1.
+func gpuHourWeight(model string, weights map[string]float64) float64 {
+	model = strings.ToLower(model)
+
+	weight, matched := 1.0, ""
+	for pattern, w := range weights {
+		pattern = strings.ToLower(pattern)
+		if pattern == "" || !strings.Contains(model, pattern) {
+			continue
+		}
+		if len(pattern) > len(matched) || (len(pattern) == len(matched) && pattern < matched) {
+			weight, matched = w, pattern
+		}
+	}
+	return weight
+}
+
+// weightedGPUHours returns a usage record's GPU hours weighted by its GPU
+// model
+func weightedGPUHours(record *types.UsageRecord, weights map[string]float64) float64 {
+	return record.Duration / 3600.0 * gpuHourWeight(record.GPUModel, weights)
+}
+
+// limitReportUsers trims users, sorted by GPU hours descending, to those with
+// at least minHours and then to the first top of them. A top of 0 keeps every
+// user. Report totals still cover every user.
+func limitReportUsers[T any](users []T, gpuHours func(T) float64, top int, minHours float64) []T {
+	n := sort.Search(len(users), func(i int) bool {
+		return gpuHours(users[i]) < minHours
+	})
+	if top > 0 && top < n {
+		n = top
+	}
+	return users[:n]
+}
+
+// reservationDurationStats returns the median, 90th percentile, and longest
+// duration of the usage records, or nil if there are none. Percentiles use
+// the nearest-rank method, so each is the duration of an actual reservation.
+// Checkpoint records are skipped, the final record giving the reservation's
+// whole duration.
+func reservationDurationStats(records []*types.UsageRecord) *ReportDurationStatsJSON {
+	var durations []float64
+	for _, record := range records {
+		if !record.Checkpoint {
+			durations = append(durations, record.ReservationDuration())
+		}
+	}
+	if len(durations) == 0 {
+		return nil
+	}
+	sort.Float64s(durations)
+
+	return &ReportDurationStatsJSON{
+		Count:         len(durations),
+		MedianSeconds: nearestRank(durations, 50),
+		P90Seconds:    nearestRank(durations, 90),
+		MaxSeconds:    durations[len(durations)-1],
+	}
+}
+
+// countReservations returns the number of reservations with usage records,
+// leaving out the checkpoints of long reservations, which also have a final
+// record
+func countReservations(records []*types.UsageRecord) int {
+	count := 0
+	for _, record := range records {
+		if !record.Checkpoint {
+			count++
+		}
 	}
-	fmt.Println(string(jsonData))
+	return count
+}
+
+// reservationDurationStatsByUser returns the reservation duration statistics
+// of each user's usage records
+func reservationDurationStatsByUser(records []*types.UsageRecord) map[string]*ReportDurationStatsJSON {
+	byUser := make(map[string][]*types.UsageRecord)
+	for _, record := range records {
+		byUser[record.User] = append(byUser[record.User], record)
+	}
+
+	stats := make(map[string]*ReportDurationStatsJSON, len(byUser))
+	for user, userRecords := range byUser {
+		stats[user] = reservationDurationStats(userRecords)
+	}
+	return stats
+}
+
+// nearestRank returns the percentile p of sorted, which must not be empty
+func nearestRank(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// formatStatsSeconds formats a reservation duration from the report statistics
+func formatStatsSeconds(seconds float64) string {
+	return utils.FormatDuration(time.Duration(seconds * float64(time.Second)))
 }
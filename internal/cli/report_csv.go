@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// reportFormatCSV is the format=csv value of the dashboard's report API
+const reportFormatCSV = "csv"
+
+// reportCSVHeader names the columns of a report in CSV format
+var reportCSVHeader = []string{
+	"user", "gpu_hours", "weighted_gpu_hours", "percentage", "run_count", "manual_count",
+	"median_seconds", "p90_seconds", "max_seconds",
+}
+
+// writeReportCSV writes the report as CSV, one row per user followed by a
+// TOTAL row for every user, including any left out of a limited report.
+// Empty columns have no value: the TOTAL row's counts, and duration
+// statistics when there are none.
+func writeReportCSV(w io.Writer, report reportData) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportCSVHeader); err != nil {
+		return err
+	}
+
+	for _, user := range report.Users {
+		row := []string{
+			user.Name,
+			formatCSVFloat(user.GPUHours),
+			formatCSVFloat(user.WeightedGPUHours),
+			formatCSVFloat(user.Percentage),
+			strconv.Itoa(user.RunCount),
+			strconv.Itoa(user.ManualCount),
+		}
+		if err := cw.Write(append(row, durationStatsCSV(user.DurationStats)...)); err != nil {
+			return err
+		}
+	}
+
+	total := []string{
+		"TOTAL",
+		formatCSVFloat(report.TotalGPUHours),
+		formatCSVFloat(report.TotalWeightedGPUHours),
+		"100.00",
+		"",
+		"",
+	}
+	if err := cw.Write(append(total, durationStatsCSV(report.DurationStats)...)); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// durationStatsCSV returns the CSV columns for reservation duration
+// statistics
+func durationStatsCSV(stats *ReportDurationStatsJSON) []string {
+	if stats == nil {
+		return []string{"", "", ""}
+	}
+	return []string{
+		formatCSVFloat(stats.MedianSeconds),
+		formatCSVFloat(stats.P90Seconds),
+		formatCSVFloat(stats.MaxSeconds),
+	}
+}
+
+// formatCSVFloat formats a number for the report CSV with two decimals
+func formatCSVFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// serveReportCSV sends the report as a CSV file download
+func serveReportCSV(w http.ResponseWriter, report reportData) {
+	filename := fmt.Sprintf("canhazgpu-report-%s-to-%s.csv", report.StartDate, report.EndDate)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if err := writeReportCSV(w, report); err != nil {
+		http.Error(w, "Failed to encode CSV", http.StatusInternalServerError)
+	}
+}
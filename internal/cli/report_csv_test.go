@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReportCSV(t *testing.T) {
+	report := reportData{
+		Users: []userReport{
+			{Name: "alice", GPUHours: 12.5, WeightedGPUHours: 25, Percentage: 62.5, RunCount: 3, ManualCount: 1,
+				DurationStats: &ReportDurationStatsJSON{Count: 4, MedianSeconds: 3600, P90Seconds: 7200, MaxSeconds: 9000}},
+			{Name: "bob, jr", GPUHours: 7.5, WeightedGPUHours: 7.5, Percentage: 37.5, ManualCount: 2},
+		},
+		TotalGPUHours:         20,
+		TotalWeightedGPUHours: 32.5,
+		DurationStats:         &ReportDurationStatsJSON{Count: 6, MedianSeconds: 3600, P90Seconds: 9000, MaxSeconds: 9000},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeReportCSV(&buf, report))
+	assert.Equal(t, `user,gpu_hours,weighted_gpu_hours,percentage,run_count,manual_count,median_seconds,p90_seconds,max_seconds
+alice,12.50,25.00,62.50,3,1,3600.00,7200.00,9000.00
+"bob, jr",7.50,7.50,37.50,0,2,,,
+TOTAL,20.00,32.50,100.00,,,3600.00,9000.00,9000.00
+`, buf.String())
+}
+
+func TestHandleAPIReport_CSV(t *testing.T) {
+	ws := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+
+	rec := httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?days=7&limit=2&format=csv", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="canhazgpu-report-\d{4}-\d{2}-\d{2}-to-\d{4}-\d{2}-\d{2}\.csv"$`, rec.Header().Get("Content-Disposition"))
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "user,gpu_hours,"))
+	assert.True(t, strings.HasPrefix(lines[1], "alice,"))
+	assert.True(t, strings.HasPrefix(lines[3], "TOTAL,"))
+
+	rec = httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?format=pdf", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid format: pdf")
+}
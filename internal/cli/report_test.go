@@ -0,0 +1,375 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReportJSON(t *testing.T) {
+	endTime := time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC)
+	startTime := endTime.AddDate(0, 0, -7)
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 3 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Duration: 3600, ReservationType: types.ReservationTypeManual},
+	}
+
+	report := buildReportJSON(records, startTime, endTime, nil)
+	assert.Equal(t, reportJSONSchemaVersion, report.SchemaVersion)
+	assert.Equal(t, 4.0, report.TotalGPUHours)
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, "alice", report.Users[0].Name)
+	assert.Equal(t, 75.0, report.Users[0].Percentage)
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	var output map[string]any
+	require.NoError(t, json.Unmarshal(data, &output))
+	assert.Equal(t, float64(reportJSONSchemaVersion), output["schema_version"])
+}
+
+func TestGPUHourWeight(t *testing.T) {
+	weights := map[string]float64{"H100": 3, "h100 nvl": 4, "RTX 3090": 0.5}
+
+	assert.Equal(t, 3.0, gpuHourWeight("H100 80GB HBM3", weights))
+	assert.Equal(t, 4.0, gpuHourWeight("H100 NVL", weights), "longest pattern wins")
+	assert.Equal(t, 0.5, gpuHourWeight("GeForce RTX 3090", weights))
+	assert.Equal(t, 1.0, gpuHourWeight("A100-SXM4-40GB", weights))
+	assert.Equal(t, 1.0, gpuHourWeight("", weights))
+	assert.Equal(t, 1.0, gpuHourWeight("H100 80GB HBM3", nil))
+}
+
+func TestBuildReportJSONWeighted(t *testing.T) {
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 2 * 3600, GPUModel: "H100 80GB HBM3", Account: "ml"},
+		{User: "bob", Duration: 4 * 3600, GPUModel: "GeForce RTX 3090", Account: "ml"},
+		{User: "bob", Duration: 3600},
+	}
+	weights := map[string]float64{"h100": 3, "3090": 0.5}
+
+	report := buildReportJSON(records, time.Now().AddDate(0, 0, -1), time.Now(), weights)
+	assert.Equal(t, 7.0, report.TotalGPUHours)
+	assert.Equal(t, 9.0, report.TotalWeightedGPUHours)
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, "bob", report.Users[0].Name)
+	assert.Equal(t, 5.0, report.Users[0].GPUHours)
+	assert.Equal(t, 3.0, report.Users[0].WeightedGPUHours)
+	assert.Equal(t, 6.0, report.Users[1].WeightedGPUHours)
+	require.Len(t, report.Accounts, 2)
+	assert.Equal(t, "ml", report.Accounts[0].Name)
+	assert.Equal(t, 8.0, report.Accounts[0].WeightedGPUHours)
+
+	webReport := generateReportData(records, time.Now().AddDate(0, 0, -1), time.Now(), 1, 0, weights)
+	assert.Equal(t, 9.0, webReport.TotalWeightedGPUHours)
+	assert.Equal(t, 3.0, webReport.Users[0].WeightedGPUHours)
+}
+
+func TestDisplayReportWeightedColumn(t *testing.T) {
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 2 * 3600, GPUModel: "H100 80GB HBM3", ReservationType: types.ReservationTypeRun},
+	}
+
+	var buf bytes.Buffer
+	displayReport(&buf, records, time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	assert.NotContains(t, buf.String(), "Weighted Hours")
+
+	buf.Reset()
+	displayReport(&buf, records, time.Now().AddDate(0, 0, -1), time.Now(), map[string]float64{"h100": 3})
+	assert.Contains(t, buf.String(), "Weighted Hours")
+	assert.Regexp(t, `alice\s+2\.00\s+6\.00\s+100\.0%`, buf.String())
+}
+
+func TestLimitReportUsers(t *testing.T) {
+	hours := map[string]float64{"alice": 10, "bob": 5, "carol": 0.5, "dave": 0.1}
+	users := []string{"alice", "bob", "carol", "dave"}
+	gpuHours := func(user string) float64 { return hours[user] }
+
+	assert.Equal(t, users, limitReportUsers(users, gpuHours, 0, 0))
+	assert.Equal(t, []string{"alice", "bob"}, limitReportUsers(users, gpuHours, 2, 0))
+	assert.Equal(t, []string{"alice", "bob", "carol"}, limitReportUsers(users, gpuHours, 0, 0.5))
+	assert.Equal(t, []string{"alice"}, limitReportUsers(users, gpuHours, 1, 0.5))
+	assert.Equal(t, []string{"alice", "bob"}, limitReportUsers(users, gpuHours, 10, 1))
+	assert.Empty(t, limitReportUsers(users, gpuHours, 0, 100))
+}
+
+func TestBuildReportJSONTopUsers(t *testing.T) {
+	defer func(top int, minHours float64) {
+		reportTop, reportMinHours = top, minHours
+	}(reportTop, reportMinHours)
+	reportTop, reportMinHours = 1, 0
+
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 3 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Duration: 3600, ReservationType: types.ReservationTypeManual},
+	}
+
+	report := buildReportJSON(records, time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	require.Len(t, report.Users, 1)
+	assert.Equal(t, "alice", report.Users[0].Name)
+	// Totals still cover the users that aren't listed
+	assert.Equal(t, 2, report.UniqueUsers)
+	assert.Equal(t, 4.0, report.TotalGPUHours)
+}
+
+func TestGenerateReportDataLimit(t *testing.T) {
+	now := time.Now()
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 3600, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Duration: 3 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "carol", Duration: 2 * 3600, ReservationType: types.ReservationTypeManual},
+	}
+
+	report := generateReportData(records, now.AddDate(0, 0, -1), now, 1, 2, nil)
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, "bob", report.Users[0].Name)
+	assert.Equal(t, "carol", report.Users[1].Name)
+	assert.Equal(t, 3, report.UniqueUsers)
+}
+
+func TestGenerateReportDataSchemaVersion(t *testing.T) {
+	now := time.Now()
+	report := generateReportData(nil, now.AddDate(0, 0, -1), now, 1, 0, nil)
+	assert.Equal(t, reportJSONSchemaVersion, report.SchemaVersion)
+}
+
+func TestAggregateByAccount(t *testing.T) {
+	records := []*types.UsageRecord{
+		{User: "alice", Account: "ml-infra", Duration: 2 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Account: "ml-infra", Duration: 3600, ReservationType: types.ReservationTypeManual},
+		{User: "ci", Account: "research", Duration: 4 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "carol", Duration: 3600, ReservationType: types.ReservationTypeRun},
+	}
+
+	accounts := aggregateByAccount(records, nil)
+	require.Len(t, accounts, 3)
+
+	assert.Equal(t, "research", accounts[0].Name)
+	assert.Equal(t, 4.0, accounts[0].GPUHours)
+	assert.Equal(t, 50.0, accounts[0].Percentage)
+
+	assert.Equal(t, "ml-infra", accounts[1].Name)
+	assert.Equal(t, 3.0, accounts[1].GPUHours)
+	assert.Equal(t, 1, accounts[1].RunCount)
+	assert.Equal(t, 1, accounts[1].ManualCount)
+
+	assert.Equal(t, noAccount, accounts[2].Name)
+
+	// No breakdown when nothing is billed to an account
+	assert.Nil(t, aggregateByAccount(records[3:], nil))
+
+	report := buildReportJSON(records, time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	assert.Len(t, report.Accounts, 3)
+	assert.Len(t, report.Users, 4)
+}
+
+func TestRunReportFollowValidation(t *testing.T) {
+	defer func(follow, jsonOutput bool, interval string) {
+		reportFollow, reportJSONOutput, reportInterval = follow, jsonOutput, interval
+	}(reportFollow, reportJSONOutput, reportInterval)
+
+	reportFollow, reportJSONOutput, reportInterval = true, true, "30s"
+	assert.ErrorContains(t, runReport(reportCmd, nil), "cannot use --follow and --json together")
+
+	reportJSONOutput = false
+	reportInterval = "soon"
+	assert.ErrorContains(t, runReport(reportCmd, nil), "invalid interval format")
+
+	reportInterval = "0s"
+	assert.ErrorContains(t, runReport(reportCmd, nil), "must be greater than 0")
+}
+
+func TestRunReportLimitValidation(t *testing.T) {
+	defer func(top int, minHours float64) {
+		reportTop, reportMinHours = top, minHours
+	}(reportTop, reportMinHours)
+
+	reportTop, reportMinHours = -1, 0
+	assert.ErrorContains(t, runReport(reportCmd, nil), "invalid --top value")
+
+	reportTop, reportMinHours = 0, -1
+	assert.ErrorContains(t, runReport(reportCmd, nil), "invalid --min-hours value")
+}
+
+func TestParseReportType(t *testing.T) {
+	for value, expected := range map[string]string{"": "", "run": "run", "Manual": "manual", " run ": "run"} {
+		reservationType, err := parseReportType(value)
+		require.NoError(t, err)
+		assert.Equal(t, expected, reservationType)
+	}
+
+	_, err := parseReportType("shared")
+	assert.ErrorContains(t, err, `invalid --type "shared": must be run or manual`)
+
+	defer func(reservationType string) { reportType = reservationType }(reportType)
+	reportType = "batch"
+	assert.ErrorContains(t, runReport(reportCmd, nil), "invalid --type")
+}
+
+func TestFilterRecordsByType(t *testing.T) {
+	defer func(reservationType string) { reportType = reservationType }(reportType)
+
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 3 * 3600, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Duration: 3600, ReservationType: types.ReservationTypeManual},
+		{User: "carol", Duration: 3600, ReservationType: types.ReservationTypeShared},
+	}
+
+	assert.Equal(t, records, filterRecordsByType(records, ""))
+	assert.Equal(t, records[:1], filterRecordsByType(records, types.ReservationTypeRun))
+	assert.Equal(t, records[1:], filterRecordsByType(records, types.ReservationTypeManual))
+	assert.Empty(t, filterRecordsByType(records[1:], types.ReservationTypeRun))
+
+	// Totals and percentages only cover the reservations of the type
+	reportType = types.ReservationTypeManual
+	report := buildReportJSON(filterRecordsByType(records, reportType), time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	assert.Equal(t, "manual", report.Type)
+	assert.Equal(t, 2.0, report.TotalGPUHours)
+	assert.Equal(t, 2, report.TotalReservations)
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, 50.0, report.Users[0].Percentage)
+
+	var buf bytes.Buffer
+	displayReport(&buf, filterRecordsByType(records, reportType), time.Now().AddDate(0, 0, -1), time.Now(), nil)
+	assert.Contains(t, buf.String(), "Reservation type: manual only")
+	assert.NotContains(t, buf.String(), "alice")
+}
+
+func TestAttributeToOwners(t *testing.T) {
+	records := []*types.UsageRecord{
+		{User: "svc-vllm", Duration: 3600},
+		{User: "bob", Duration: 3600},
+	}
+	attributeToOwners(records, &types.Config{ProcessOwners: map[string]string{"svc-vllm": "alice"}})
+	assert.Equal(t, "alice", records[0].User)
+	assert.Equal(t, "bob", records[1].User)
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "report")
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	assert.False(t, isTerminal(f))
+}
+
+func TestGetCurrentUsageRecordsShared(t *testing.T) {
+	now := time.Now()
+	statuses := []gpu.GPUStatusInfo{
+		{
+			GPUID:           0,
+			Status:          "IN_USE",
+			User:            "alice, bob",
+			ReservationType: types.ReservationTypeShared,
+			Shares: []types.GPUShare{
+				{User: "alice", StartTime: types.FlexibleTime{Time: now.Add(-time.Hour)}, Account: "ml"},
+				{User: "bob", StartTime: types.FlexibleTime{Time: now.Add(-30 * time.Minute)}},
+			},
+		},
+	}
+
+	records := getCurrentUsageRecords(statuses, now)
+	require.Len(t, records, 2)
+	assert.Equal(t, "alice", records[0].User)
+	assert.Equal(t, "ml", records[0].Account)
+	assert.InDelta(t, 3600, records[0].Duration, 1)
+	assert.Equal(t, "bob", records[1].User)
+	assert.InDelta(t, 1800, records[1].Duration, 1)
+	assert.Equal(t, types.ReservationTypeShared, records[1].ReservationType)
+}
+
+func TestGetCurrentUsageRecordsCheckpointed(t *testing.T) {
+	now := time.Now()
+	status := gpu.GPUStatusInfo{
+		GPUID:           0,
+		Status:          "IN_USE",
+		User:            "alice",
+		ReservationType: types.ReservationTypeRun,
+		Duration:        50 * time.Hour,
+		StartTime:       now.Add(-50 * time.Hour),
+		LastCheckpoint:  now.Add(-2 * time.Hour),
+	}
+
+	// Only the time since the last checkpoint is still missing from history
+	for _, records := range [][]*types.UsageRecord{
+		getCurrentUsageRecords([]gpu.GPUStatusInfo{status}, now),
+		getCurrentUsageRecordsWeb([]gpu.GPUStatusInfo{status}, now),
+	} {
+		require.Len(t, records, 1)
+		assert.InDelta(t, 2*3600, records[0].Duration, 1)
+		assert.Equal(t, now.Add(-2*time.Hour), records[0].StartTime.ToTime())
+		assert.InDelta(t, 50*3600, records[0].ReservationDuration(), 1)
+	}
+}
+
+func TestReportCheckpointRecords(t *testing.T) {
+	now := time.Now()
+	start := types.FlexibleTime{Time: now.Add(-50 * time.Hour)}
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 24 * 3600, ReservationType: types.ReservationTypeRun, Checkpoint: true, ReservationStart: start},
+		{User: "alice", Duration: 24 * 3600, ReservationType: types.ReservationTypeRun, Checkpoint: true, ReservationStart: start},
+		{User: "alice", Duration: 2 * 3600, ReservationType: types.ReservationTypeRun, ReservationStart: start,
+			EndTime: types.FlexibleTime{Time: now}},
+	}
+
+	report := buildReportJSON(records, now.AddDate(0, 0, -7), now, nil)
+	assert.Equal(t, 1, report.TotalReservations)
+	require.Len(t, report.Users, 1)
+	assert.InDelta(t, 50, report.Users[0].GPUHours, 0.01)
+	assert.Equal(t, 1, report.Users[0].RunCount)
+	require.NotNil(t, report.DurationStats)
+	assert.InDelta(t, 50*3600, report.DurationStats.MaxSeconds, 1)
+}
+
+func TestReservationDurationStats(t *testing.T) {
+	assert.Nil(t, reservationDurationStats(nil))
+
+	var records []*types.UsageRecord
+	for i := 1; i <= 10; i++ {
+		records = append(records, &types.UsageRecord{User: "alice", Duration: float64(i * 600)})
+	}
+	records = append(records, &types.UsageRecord{User: "bob", Duration: 8 * 3600})
+
+	stats := reservationDurationStats(records)
+	require.NotNil(t, stats)
+	assert.Equal(t, 11, stats.Count)
+	assert.Equal(t, 3600.0, stats.MedianSeconds)
+	assert.Equal(t, 6000.0, stats.P90Seconds)
+	assert.Equal(t, 8*3600.0, stats.MaxSeconds)
+
+	byUser := reservationDurationStatsByUser(records)
+	assert.Equal(t, &ReportDurationStatsJSON{Count: 10, MedianSeconds: 3000, P90Seconds: 5400, MaxSeconds: 6000}, byUser["alice"])
+	assert.Equal(t, &ReportDurationStatsJSON{Count: 1, MedianSeconds: 8 * 3600, P90Seconds: 8 * 3600, MaxSeconds: 8 * 3600}, byUser["bob"])
+}
+
+func TestReportDurationStatsOutput(t *testing.T) {
+	now := time.Now()
+	records := []*types.UsageRecord{
+		{User: "alice", Duration: 600, ReservationType: types.ReservationTypeRun},
+		{User: "alice", Duration: 1800, ReservationType: types.ReservationTypeRun},
+		{User: "bob", Duration: 7200, ReservationType: types.ReservationTypeManual},
+	}
+
+	report := buildReportJSON(records, now.AddDate(0, 0, -1), now, nil)
+	require.NotNil(t, report.DurationStats)
+	assert.Equal(t, 1800.0, report.DurationStats.MedianSeconds)
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, 600.0, report.Users[1].DurationStats.MedianSeconds)
+
+	webReport := generateReportData(records, now.AddDate(0, 0, -1), now, 1, 0, nil)
+	assert.Equal(t, report.DurationStats, webReport.DurationStats)
+	assert.Equal(t, 7200.0, webReport.Users[0].DurationStats.MaxSeconds)
+
+	var buf bytes.Buffer
+	displayReport(&buf, records, now.AddDate(0, 0, -1), now, nil)
+	assert.Regexp(t, `Reservation Length\s+Median\s+P90\s+Max\s+Count`, buf.String())
+	assert.Regexp(t, `alice\s+0h 10m 0s\s+0h 30m 0s\s+0h 30m 0s\s+2`, buf.String())
+	assert.Regexp(t, `TOTAL\s+0h 30m 0s\s+2h 0m 0s\s+2h 0m 0s\s+3`, buf.String())
+}
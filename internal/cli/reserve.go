@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -41,6 +40,35 @@ Use --force to reserve GPUs that are currently in unreserved use. This is
 useful when you've started a job without using canhazgpu and want to create
 a reservation retroactively.
 
+Use --gpus all to reserve every GPU that is available at the time of the
+request. It fails if any GPU is in unreserved use, unless the
+gpus_all_exclude_unreserved config option is set, in which case those GPUs are
+skipped. Requests for all GPUs never wait in the queue.
+
+Use --renewable to make a reservation that stays alive while
+'canhazgpu keepalive --gpu-ids ...' is running. Each keepalive heartbeat
+extends the reservation to --duration from now, so it is released about
+--duration after the keepalive stops. This suits services that can't run
+under 'canhazgpu run' but should still be cleaned up when they go away.
+
+Use --shared to reserve a share of GPUs that other users may reserve too, up
+to the max_shares_per_gpu config option (default 2) holders per GPU. GPUs that
+are already shared are used before free ones. Each holder's share expires
+separately, and a shared GPU is free again once all of its shares are
+released. Shared reservations never wait in the queue.
+
+Use --gpu-class to reserve only GPUs of a memory class on a host with mixed
+GPUs: small (less than 40000MB of memory) or large (40000MB or more). Other
+classes can be defined with the gpu_classes config option.
+
+Use --remind-before to be reminded before the reservation expires, so there
+is time to save work or make a new reservation. The daemon sends the reminder
+once, to the webhook set by the reminder_webhook.url config option.
+
+Use --priority to set the reservation priority (low, normal, or high). Idle
+reservations may be preempted by 'canhazgpu run --preempt' requests of a
+higher priority.
+
 Duration formats supported:
 - 30m (30 minutes)
 - 2h (2 hours)
@@ -54,31 +82,52 @@ variable based on the GPU IDs shown in the output:
 
 Example usage:
   canhazgpu reserve --gpus 2 --duration 4h
+  canhazgpu reserve --gpus all --duration 8h
   canhazgpu reserve --gpu-ids 1,3 --duration 2h
   canhazgpu reserve --gpu-ids 0,1,2 --duration 8h --force
+  canhazgpu reserve --gpu-ids 1 --duration 15m --renewable  # Then run 'canhazgpu keepalive --gpu-ids 1'
+  canhazgpu reserve --gpus 2 --duration 4h --gpu-class large
+  canhazgpu reserve --gpus 1 --duration 8h --remind-before 1h
+  canhazgpu reserve --gpus 1 --duration 2h --shared  # Share a GPU with other light jobs
   canhazgpu reserve --nonblock --gpus 4 --duration 2h  # Fail if unavailable
   canhazgpu reserve --wait 30m --gpus 4 --duration 2h  # Wait up to 30 minutes
   export CUDA_VISIBLE_DEVICES=$(canhazgpu reserve --gpus 2 --short)  # For scripting
 
+The default duration of 30m can be changed with the default_reserve_duration
+config option.
+
 The reserved GPUs must be manually released with 'canhazgpu release' or will
 automatically expire after the specified duration.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		gpuCount := viper.GetInt("reserve.gpus")
-		gpuIDs := viper.GetIntSlice("reserve.gpu-ids")
-		durationStr := viper.GetString("reserve.duration")
-		force := viper.GetBool("reserve.force")
-		note := viper.GetString("reserve.note")
-		customUser := viper.GetString("reserve.user")
-		nonblock := viper.GetBool("reserve.nonblock")
-		waitStr := viper.GetString("reserve.wait")
-		short := viper.GetBool("reserve.short")
-
-		return runReserve(cmd.Context(), gpuCount, gpuIDs, durationStr, force, note, customUser, nonblock, waitStr, short)
+		gpuCount, err := parseGPUCount(viper.GetString("reserve.gpus"))
+		if err != nil {
+			return err
+		}
+		opts := reserveOptions{
+			GPUCount:        gpuCount,
+			GPUIDs:          viper.GetIntSlice("reserve.gpu-ids"),
+			Duration:        stringFlagOrDefault(viper.GetViper(), cmd, "duration", "default_reserve_duration"),
+			Force:           viper.GetBool("reserve.force"),
+			Note:            viper.GetString("reserve.note"),
+			User:            viper.GetString("reserve.user"),
+			Nonblock:        viper.GetBool("reserve.nonblock"),
+			Wait:            viper.GetString("reserve.wait"),
+			Short:           viper.GetBool("reserve.short"),
+			Priority:        viper.GetString("reserve.priority"),
+			Account:         stringFlagOrDefault(viper.GetViper(), cmd, "account", "default_account"),
+			Renewable:       viper.GetBool("reserve.renewable"),
+			MinFreeDuration: viper.GetString("reserve.min-free-duration"),
+			Shared:          viper.GetBool("reserve.shared"),
+			GPUClass:        strings.ToLower(strings.TrimSpace(viper.GetString("reserve.gpu-class"))),
+			RemindBefore:    viper.GetString("reserve.remind-before"),
+		}
+
+		return runReserve(cmd.Context(), opts)
 	},
 }
 
 func init() {
-	reserveCmd.Flags().IntP("gpus", "g", 1, "Number of GPUs to reserve")
+	reserveCmd.Flags().StringP("gpus", "g", "1", "Number of GPUs to reserve, or 'all' for every available GPU")
 	reserveCmd.Flags().IntSliceP("gpu-ids", "G", nil, "Specific GPU IDs to reserve (comma-separated, e.g., 1,3,5)")
 	reserveCmd.Flags().StringP("duration", "d", "30m", "Duration to reserve GPUs (e.g., 30m, 2h, 1d)")
 	reserveCmd.Flags().BoolP("force", "f", false, "Force reservation even if GPU is in unreserved use")
@@ -87,33 +136,91 @@ func init() {
 	reserveCmd.Flags().Bool("nonblock", false, "Fail immediately if GPUs are unavailable instead of waiting in queue")
 	reserveCmd.Flags().StringP("wait", "w", "", "Maximum time to wait for GPUs (e.g., 30m, 2h). Default: wait forever.")
 	reserveCmd.Flags().BoolP("short", "s", false, "Output only the GPU IDs (for use with command substitution)")
+	reserveCmd.Flags().String("priority", types.PriorityNormal, "Reservation priority: low, normal, or high (low reservations may be preempted when idle)")
+	reserveCmd.Flags().String("account", "", "Team account to bill the usage to (default: your primary group)")
+	reserveCmd.Flags().Bool("renewable", false, "Extend the reservation by --duration on each 'canhazgpu keepalive' heartbeat")
+	reserveCmd.Flags().String("min-free-duration", "", "Fail unless the reservation can't be preempted for at least this long, and skip GPUs next to reservations expiring sooner (e.g., 1h)")
+	reserveCmd.Flags().Bool("shared", false, "Reserve a share of the GPUs that other users may also reserve, up to max_shares_per_gpu holders")
+	reserveCmd.Flags().String("gpu-class", "", "Only reserve GPUs of this memory class: small or large, or a class from gpu_classes")
+	reserveCmd.Flags().String("remind-before", "", "Have the daemon send a reminder to the reminder webhook this long before the reservation expires (e.g., 1h)")
 
 	rootCmd.AddCommand(reserveCmd)
 }
 
-func runReserve(ctx context.Context, gpuCount int, gpuIDs []int, durationStr string, force bool, note string, customUser string, nonblock bool, waitStr string, short bool) error {
+// reserveOptions are the settings of a 'reserve', taken from its flags
+type reserveOptions struct {
+	GPUCount        int
+	GPUIDs          []int
+	Duration        string
+	Force           bool
+	Note            string
+	User            string // Display user, empty for the OS user
+	Nonblock        bool
+	Wait            string // How long to wait in the queue, empty to wait forever
+	Short           bool
+	Priority        string
+	Account         string
+	Renewable       bool
+	MinFreeDuration string
+	Shared          bool
+	GPUClass        string
+	RemindBefore    string
+}
+
+func runReserve(ctx context.Context, opts reserveOptions) error {
 	// If neither is specified, default to 1 GPU
-	if gpuCount == 0 && len(gpuIDs) == 0 {
-		gpuCount = 1
+	if opts.GPUCount == 0 && len(opts.GPUIDs) == 0 {
+		opts.GPUCount = 1
 	}
 
 	// Parse duration
-	duration, err := utils.ParseDuration(durationStr)
+	duration, err := utils.ParseDuration(opts.Duration)
 	if err != nil {
 		return err
 	}
 
+	if opts.Shared {
+		if err := checkSharedReserve(opts.GPUCount, opts.Force, opts.Renewable, opts.GPUClass); err != nil {
+			return err
+		}
+		if opts.RemindBefore != "" {
+			return fmt.Errorf("--shared cannot be used with --remind-before")
+		}
+	}
+
+	remindBefore, err := parseRemindBefore(opts.RemindBefore, duration)
+	if err != nil {
+		return err
+	}
+
+	if opts.Renewable && duration < types.MinRenewDuration {
+		return fmt.Errorf("renewable reservations must be at least %s, so that a missed keepalive doesn't release them",
+			utils.FormatDuration(types.MinRenewDuration))
+	}
+
 	// Parse wait timeout if provided
 	var waitTimeout *time.Duration
-	if waitStr != "" {
-		wt, err := utils.ParseDuration(waitStr)
+	if opts.Wait != "" {
+		wt, err := utils.ParseDuration(opts.Wait)
 		if err != nil {
 			return fmt.Errorf("invalid wait timeout format: %v", err)
 		}
 		waitTimeout = &wt
 	}
 
+	if err := types.ValidatePriority(opts.Priority); err != nil {
+		return err
+	}
+	minFree, err := checkMinFreeDuration(opts.MinFreeDuration, duration, opts.Priority)
+	if err != nil {
+		return err
+	}
+
 	config := getConfig()
+	if err := checkGPUClass(config.GPUClasses, opts.GPUClass, opts.GPUCount, opts.GPUIDs); err != nil {
+		return err
+	}
+
 	client := redis_client.NewClient(config)
 	defer func() {
 		if err := client.Close(); err != nil {
@@ -132,56 +239,118 @@ func runReserve(ctx context.Context, gpuCount int, gpuIDs []int, durationStr str
 	// Get actual OS user and determine display user
 	actualUser := getCurrentUser()
 	displayUser := actualUser
-	if customUser != "" {
-		displayUser = customUser
+	if opts.User != "" {
+		displayUser = opts.User
 	}
 
 	// Create allocation request
 	expiryTime := time.Now().Add(duration)
 	request := &gpu.QueuedAllocationRequest{
 		AllocationRequest: &types.AllocationRequest{
-			GPUCount:        gpuCount,
-			GPUIDs:          gpuIDs,
+			GPUCount:        max(opts.GPUCount, 0),
+			AllAvailable:    opts.GPUCount == gpuCountAll,
+			GPUIDs:          opts.GPUIDs,
 			User:            displayUser,
 			ActualUser:      actualUser,
 			ReservationType: types.ReservationTypeManual,
 			ExpiryTime:      &expiryTime,
-			Force:           force,
-			Note:            note,
+			Force:           opts.Force,
+			Note:            opts.Note,
+			Source:          types.ReservationSourceReserve,
+			Priority:        opts.Priority,
+			Account:         resolveAccount(opts.Account),
+			Renewable:       opts.Renewable,
+			GPUClass:        opts.GPUClass,
+			RemindBefore:    remindBefore,
+			MinFreeDuration: minFree,
 		},
-		Blocking:    !nonblock,
+		Blocking:    !opts.Nonblock,
 		WaitTimeout: waitTimeout,
 	}
 
-	// Allocate GPUs (with queue support)
-	result, err := engine.AllocateGPUsWithQueue(ctx, request)
-	if err != nil {
-		return err
+	var allocatedGPUs []int
+	if opts.Shared {
+		// Shared reservations never wait in the queue
+		request.ReservationType = types.ReservationTypeShared
+		allocatedGPUs, err = engine.AllocateSharedGPUs(ctx, request.AllocationRequest)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Allocate GPUs (with queue support)
+		result, err := engine.AllocateGPUsWithQueue(ctx, request)
+		if err != nil {
+			return err
+		}
+		allocatedGPUs = result.AllocatedGPUs
 	}
-	allocatedGPUs := result.AllocatedGPUs
 
 	// Sort GPU IDs for consistent ordering in output and environment variable
 	sort.Ints(allocatedGPUs)
 
 	// Build list for CUDA_VISIBLE_DEVICES
-	ids := make([]string, len(allocatedGPUs))
-	for i, id := range allocatedGPUs {
-		ids[i] = strconv.Itoa(id)
-	}
+	devices := visibleDevices(config, allocatedGPUs)
 
-	if short {
+	if opts.Short {
 		// Short output: just the GPU IDs for command substitution
-		fmt.Print(strings.Join(ids, ","))
+		fmt.Print(devices)
 		return nil
 	}
 
-	fmt.Printf("Reserved %d GPU(s): %v for %s\n",
-		len(allocatedGPUs), allocatedGPUs, utils.FormatDuration(duration))
+	if opts.Shared {
+		fmt.Printf("Reserved a share of %s: %v for %s\n",
+			reservedGPUsLabel(len(allocatedGPUs), false), allocatedGPUs, utils.FormatDuration(duration))
+	} else {
+		fmt.Printf("Reserved %s: %v for %s\n",
+			reservedGPUsLabel(len(allocatedGPUs), opts.GPUCount == gpuCountAll), allocatedGPUs, utils.FormatDuration(duration))
+	}
 
 	fmt.Printf(
 		"\nRun the following command to run only on these GPUs:\nexport CUDA_VISIBLE_DEVICES=%s\n",
-		strings.Join(ids, ","),
+		devices,
 	)
 
+	if opts.Renewable {
+		fmt.Printf("\nThe reservation is renewable. Keep it alive with:\n%s\n", keepaliveCommand(allocatedGPUs, opts.User))
+	}
+	if remindBefore > 0 {
+		fmt.Printf("\nA reminder will be sent %s before the reservation expires.\n", utils.FormatDuration(remindBefore))
+	}
+
+	return nil
+}
+
+// parseRemindBefore parses --remind-before, which must leave time between
+// reserving and the reminder. An empty value means no reminder.
+func parseRemindBefore(value string, duration time.Duration) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	remindBefore, err := utils.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --remind-before: %v", err)
+	}
+	if remindBefore < time.Second {
+		return 0, fmt.Errorf("--remind-before must be at least 1s")
+	}
+	if remindBefore >= duration {
+		return 0, fmt.Errorf("--remind-before (%s) must be shorter than --duration (%s)",
+			utils.FormatDuration(remindBefore), utils.FormatDuration(duration))
+	}
+	return remindBefore, nil
+}
+
+// checkSharedReserve rejects options that can't be combined with --shared
+func checkSharedReserve(gpuCount int, force, renewable bool, gpuClass string) error {
+	switch {
+	case gpuCount == gpuCountAll:
+		return fmt.Errorf("--shared cannot be used with --gpus all")
+	case force:
+		return fmt.Errorf("--shared cannot be used with --force")
+	case renewable:
+		return fmt.Errorf("--shared cannot be used with --renewable")
+	case gpuClass != "":
+		return fmt.Errorf("--shared cannot be used with --gpu-class")
+	}
 	return nil
 }
@@ -2,19 +2,32 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/russellb/canhazgpu/internal/gpu"
 	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var (
-	config     *types.Config
-	configFile string
-	rootCmd    = &cobra.Command{
+	config      *types.Config
+	configFile  string
+	profileName string
+	poolName    string
+	rootCmd     = &cobra.Command{
 		Use:   "canhazgpu",
 		Short: "A GPU reservation tool for single host shared development systems",
 		Long: `canhazgpu provides a simple reservation system that coordinates GPU access 
@@ -30,10 +43,14 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default is $HOME/.canhazgpu.yaml)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file in YAML, TOML, or JSON format (default is $HOME/.canhazgpu.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Config file profile to use, e.g. for a different cluster (default: top-level settings)")
+	rootCmd.PersistentFlags().StringVar(&poolName, "pool", "", "Named GPU pool to use, from pools in the config file (default: the Redis database from --redis-db)")
 	rootCmd.PersistentFlags().String("redis-host", "localhost", "Redis host")
 	rootCmd.PersistentFlags().Int("redis-port", 6379, "Redis port")
 	rootCmd.PersistentFlags().Int("redis-db", 0, "Redis database")
+	rootCmd.PersistentFlags().String("redis-read-host", "", "Redis read replica host for status/report queries (default: use primary)")
+	rootCmd.PersistentFlags().Int("redis-read-port", 0, "Redis read replica port (default: same as --redis-port)")
 	rootCmd.PersistentFlags().Int("memory-threshold", types.MemoryThresholdMB, "Memory threshold in MB to consider a GPU as 'in use' (default: 1024)")
 
 	if err := viper.BindPFlag("redis.host", rootCmd.PersistentFlags().Lookup("redis-host")); err != nil {
@@ -45,6 +62,12 @@ func init() {
 	if err := viper.BindPFlag("redis.db", rootCmd.PersistentFlags().Lookup("redis-db")); err != nil {
 		panic(fmt.Sprintf("Failed to bind redis-db flag: %v", err))
 	}
+	if err := viper.BindPFlag("redis.read_host", rootCmd.PersistentFlags().Lookup("redis-read-host")); err != nil {
+		panic(fmt.Sprintf("Failed to bind redis-read-host flag: %v", err))
+	}
+	if err := viper.BindPFlag("redis.read_port", rootCmd.PersistentFlags().Lookup("redis-read-port")); err != nil {
+		panic(fmt.Sprintf("Failed to bind redis-read-port flag: %v", err))
+	}
 	if err := viper.BindPFlag("memory.threshold", rootCmd.PersistentFlags().Lookup("memory-threshold")); err != nil {
 		panic(fmt.Sprintf("Failed to bind memory-threshold flag: %v", err))
 	}
@@ -57,44 +80,557 @@ func init() {
 }
 
 func initConfig() {
-	if configFile != "" {
-		// Use config file from the flag
-		viper.SetConfigFile(configFile)
-	} else {
-		// Find home directory
+	// Allow the config file to be chosen via the environment, e.g. in containers
+	if configFile == "" {
+		configFile = os.Getenv(envVarName("config"))
+	}
+
+	path := configFile
+	if path == "" {
+		// Search for .canhazgpu.yaml (or .yml, .toml, .json) in the home
+		// directory, then the current directory
+		var dirs []string
 		home, err := os.UserHomeDir()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: Could not find home directory: %v\n", err)
 		} else {
-			// Search config in home directory with name ".canhazgpu" (without extension)
-			viper.AddConfigPath(home)
-			viper.AddConfigPath(".")
-			viper.SetConfigType("yaml")
-			viper.SetConfigName(".canhazgpu")
+			dirs = append(dirs, home)
 		}
+		path = findConfigFile(append(dirs, "."))
 	}
 
 	// Enable reading from environment variables
-	viper.SetEnvPrefix("CANHAZGPU")
-	viper.AutomaticEnv()
+	configureEnv(viper.GetViper())
 
 	// If a config file is found, read it in
-	_ = viper.ReadInConfig()
+	if path != "" {
+		if err := readConfigFile(viper.GetViper(), path); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	// Layer the selected profile over the top-level settings. Running against
+	// the wrong cluster is worse than not running, so a missing profile is fatal.
+	if profileName == "" {
+		profileName = viper.GetString("profile")
+	}
+	if err := applyProfile(viper.GetViper(), profileName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Bind all flags to viper for automatic config file support
 	bindAllFlags()
 
-	config = &types.Config{
-		RedisHost:       viper.GetString("redis.host"),
-		RedisPort:       viper.GetInt("redis.port"),
-		RedisDB:         viper.GetInt("redis.db"),
-		MemoryThreshold: viper.GetInt("memory.threshold"),
-		RemoteHosts:     viper.GetStringSlice("remote_hosts"),
+	// A pool selects its Redis database. Like a profile, a missing pool is
+	// fatal rather than falling back to another pool's GPUs.
+	if poolName == "" {
+		poolName = viper.GetString("pool")
+	}
+	if err := applyPool(viper.GetViper(), poolName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Commands that read flag variables directly need env and config file
+	// values applied to the flags themselves
+	walkCommands(rootCmd, func(cmd *cobra.Command) {
+		if err := applyConfigToFlags(viper.GetViper(), cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	})
+
+	config = newConfigFromViper(viper.GetViper())
+
+	// GPU indices can change across reboots, so map them to UUIDs every time
+	if config.UseGPUUUIDs {
+		uuids, err := gpu.NewProviderManager(config).DetectGPUUUIDs(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: gpu_uuids is set but GPU UUIDs could not be detected: %v\n", err)
+		}
+		config.GPUUUIDs = uuids
+	}
+}
+
+// configFileTypes are the supported config file extensions, in the order
+// they are looked for in the default locations, and the format of each. A
+// file without an extension is YAML.
+var configFileTypes = []struct {
+	ext    string
+	format string
+}{
+	{".yaml", "yaml"},
+	{".yml", "yaml"},
+	{".toml", "toml"},
+	{".json", "json"},
+	{"", "yaml"},
+}
+
+// configFileType returns the format of a config file from its extension
+func configFileType(path string) (string, error) {
+	base := filepath.Base(path)
+	ext := strings.ToLower(filepath.Ext(base))
+	if ext == strings.ToLower(base) {
+		// A dotfile such as .canhazgpu has no extension
+		ext = ""
+	}
+	for _, t := range configFileTypes {
+		if t.ext == ext {
+			return t.format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported config file format %q: use .yaml, .yml, .toml, or .json", ext)
+}
+
+// findConfigFile returns the first .canhazgpu config file found in dirs,
+// trying the extensions in configFileTypes order in each, or "" if there is
+// none
+func findConfigFile(dirs []string) string {
+	for _, dir := range dirs {
+		for _, t := range configFileTypes {
+			path := filepath.Join(dir, ".canhazgpu"+t.ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads a YAML, TOML, or JSON config file into v, choosing
+// the format from the file's extension. Keys mean the same in every format.
+// A missing file is not an error.
+func readConfigFile(v *viper.Viper, path string) error {
+	format, err := configFileType(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	v.SetConfigFile(path)
+	v.SetConfigType(format)
+	if err := v.ReadInConfig(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// applyProfile merges the settings of the named entry under "profiles" over
+// the top-level config file settings, so a profile only needs to list what
+// differs. An empty name selects no profile.
+func applyProfile(v *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profiles := v.GetStringMap("profiles")
+	profile, ok := profiles[strings.ToLower(name)].(map[string]interface{})
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for profileName := range profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("profile '%s' not found: no profiles are defined in the config file", name)
+		}
+		return fmt.Errorf("profile '%s' not found. Available profiles: %s", name, strings.Join(names, ", "))
+	}
+
+	return v.MergeConfigMap(profile)
+}
+
+// applyPool points v at the Redis database of the named pool from the pools
+// section of the config file, which maps pool names to database numbers. The
+// pool's database takes precedence over --redis-db.
+func applyPool(v *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	pools := v.GetStringMap("pools")
+	value, ok := pools[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(pools))
+		for poolName := range pools {
+			names = append(names, poolName)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("pool '%s' not found: no pools are defined in the config file", name)
+		}
+		return fmt.Errorf("pool '%s' not found. Available pools: %s", name, strings.Join(names, ", "))
+	}
+
+	db, err := strconv.Atoi(strings.TrimSpace(fmt.Sprint(value)))
+	if err != nil || db < 0 {
+		return fmt.Errorf("pool '%s' must be a Redis database number, got %v", name, value)
+	}
+	v.Set("redis.db", db)
+	return nil
+}
+
+// poolLabel names the pool selected with --pool for messages, e.g. " in pool
+// 'training'", or is empty without one
+func poolLabel(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" in pool '%s'", name)
+}
+
+// envKeyReplacer maps viper keys to environment variable names, so that
+// "redis.host" is read from CANHAZGPU_REDIS_HOST and "run.gpu-ids" from
+// CANHAZGPU_RUN_GPU_IDS
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// configureEnv enables CANHAZGPU_* environment variable overrides
+func configureEnv(v *viper.Viper) {
+	v.SetEnvPrefix("CANHAZGPU")
+	v.SetEnvKeyReplacer(envKeyReplacer)
+	v.AutomaticEnv()
+}
+
+// envVarName returns the environment variable that overrides a config key
+func envVarName(key string) string {
+	return "CANHAZGPU_" + strings.ToUpper(envKeyReplacer.Replace(key))
+}
+
+// newConfigFromViper builds the application configuration. Values resolve
+// in order of precedence: flag > environment variable > config file > default.
+func newConfigFromViper(v *viper.Viper) *types.Config {
+	lockTimeout, lockMaxRetries := lockConfig(v)
+
+	return &types.Config{
+		RedisHost:         v.GetString("redis.host"),
+		RedisPort:         v.GetInt("redis.port"),
+		RedisDB:           v.GetInt("redis.db"),
+		RedisReadHost:     v.GetString("redis.read_host"),
+		RedisReadPort:     v.GetInt("redis.read_port"),
+		RedisDialTimeout:  redisTimeout(v, "redis.dial_timeout"),
+		RedisReadTimeout:  redisTimeout(v, "redis.read_timeout"),
+		RedisWriteTimeout: redisTimeout(v, "redis.write_timeout"),
+		MemoryThreshold:   v.GetInt("memory.threshold"),
+		RemoteHosts:       remoteHosts(v),
+
+		UseGPUUUIDs:              v.GetBool("gpu_uuids"),
+		AllGPUsExcludeUnreserved: v.GetBool("gpus_all_exclude_unreserved"),
+		GPUCooldown:              time.Duration(max(v.GetInt("cooldown"), 0)) * time.Second,
+		ConfirmUnreservedUsage:   v.GetBool("confirm_unreserved_usage"),
+		LockTimeout:              lockTimeout,
+		LockMaxRetries:           lockMaxRetries,
+		UsageSink:                usageSinkConfig(v),
+		DisableUsageHistory:      v.IsSet("record_usage_history") && !v.GetBool("record_usage_history"),
+		NvidiaSMIPath:            strings.TrimSpace(v.GetString("nvidia_smi_path")),
+		AMDSMIPath:               strings.TrimSpace(v.GetString("amd_smi_path")),
+		MaxSharesPerGPU:          max(v.GetInt("max_shares_per_gpu"), 0),
+		MaxQueueLength:           max(v.GetInt("max_queue_length"), 0),
+		MaxQueueEntriesPerUser:   max(v.GetInt("max_queue_entries_per_user"), 0),
+		GPUHourWeights:           gpuHourWeights(v),
+		ReminderWebhook:          reminderWebhookConfig(v),
+		GPUClasses:               gpuClasses(v),
+		CommandRedactFlags:       splitList(v.GetStringSlice("command_redact_flags")),
+		IgnoreUsers:              splitList(v.GetStringSlice("ignore_users")),
+		IgnoreProcesses:          splitList(v.GetStringSlice("ignore_processes")),
+		ProcessOwners:            processOwners(v),
+	}
+}
+
+// remoteHosts reads the remote_hosts option. Entries are either SSH
+// addresses or tables with a host and optional ssh_user, ssh_port and
+// redis_port overrides, and both can be mixed in one list. Invalid entries
+// are skipped with a warning.
+func remoteHosts(v *viper.Viper) []types.RemoteHost {
+	var entries []any
+	switch value := v.Get("remote_hosts").(type) {
+	case []any:
+		entries = value
+	case []map[string]any:
+		// TOML arrays of tables
+		for _, entry := range value {
+			entries = append(entries, entry)
+		}
+	default:
+		// A single string, e.g. "host1,host2" from an environment variable
+		for _, host := range splitList(v.GetStringSlice("remote_hosts")) {
+			entries = append(entries, host)
+		}
+	}
+
+	var hosts []types.RemoteHost
+	for i, entry := range entries {
+		switch entry := entry.(type) {
+		case map[string]any:
+			host, err := remoteHostEntry(entry)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring invalid remote_hosts entry %d: %v\n", i+1, err)
+				continue
+			}
+			hosts = append(hosts, host)
+		default:
+			for _, host := range splitList([]string{fmt.Sprint(entry)}) {
+				hosts = append(hosts, types.RemoteHost{Host: host})
+			}
+		}
+	}
+	return hosts
+}
+
+// remoteHostEntry reads a remote_hosts entry given as a table
+func remoteHostEntry(entry map[string]any) (types.RemoteHost, error) {
+	var host types.RemoteHost
+	for key, value := range entry {
+		if value == nil {
+			continue
+		}
+		raw := strings.TrimSpace(fmt.Sprint(value))
+		switch strings.ToLower(key) {
+		case "host":
+			host.Host = raw
+		case "ssh_user":
+			host.SSHUser = raw
+		case "ssh_port", "redis_port":
+			port, err := strconv.Atoi(raw)
+			if err != nil || port < 1 || port > 65535 {
+				return host, fmt.Errorf("%s must be a port number from 1 to 65535, got %q", key, raw)
+			}
+			if strings.ToLower(key) == "ssh_port" {
+				host.SSHPort = port
+			} else {
+				host.RedisPort = port
+			}
+		default:
+			return host, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	if host.Host == "" {
+		return host, fmt.Errorf("host is required")
+	}
+	return host, nil
+}
+
+// gpuClasses reads the gpu_classes option. Configured classes replace or add
+// to the default small and large classes; invalid entries are skipped with a
+// warning. Returns nil when the option isn't set.
+func gpuClasses(v *viper.Viper) map[string]types.GPUClass {
+	configured := v.GetStringMap("gpu_classes")
+	if len(configured) == 0 {
+		return nil
+	}
+
+	classes := types.DefaultGPUClasses()
+	for name := range configured {
+		class, err := gpuClassConfig(v, "gpu_classes."+name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid gpu_classes entry %q: %v\n", name, err)
+			continue
+		}
+		classes[name] = class
+	}
+	return classes
+}
+
+// gpuClassConfig reads the memory range of one gpu_classes entry
+func gpuClassConfig(v *viper.Viper, key string) (types.GPUClass, error) {
+	var class types.GPUClass
+	var err error
+	if class.MinMemoryMB, err = memoryMBOption(v, key+".min_memory_mb"); err != nil {
+		return class, err
+	}
+	if class.MaxMemoryMB, err = memoryMBOption(v, key+".max_memory_mb"); err != nil {
+		return class, err
+	}
+	if class.MaxMemoryMB != 0 && class.MaxMemoryMB <= class.MinMemoryMB {
+		return class, fmt.Errorf("max_memory_mb must be greater than min_memory_mb")
+	}
+	return class, nil
+}
+
+// memoryMBOption reads a memory size in MB, 0 if unset
+func memoryMBOption(v *viper.Viper, key string) (int, error) {
+	raw := strings.TrimSpace(v.GetString(key))
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number of MB, got %q", key[strings.LastIndex(key, ".")+1:], raw)
+	}
+	return value, nil
+}
+
+// gpuHourWeights reads the gpu_hour_weights option, skipping invalid
+// entries with a warning
+func gpuHourWeights(v *viper.Viper) map[string]float64 {
+	var weights map[string]float64
+	for pattern, value := range v.GetStringMapString("gpu_hour_weights") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid gpu_hour_weights entry %q: %q\n", pattern, value)
+			continue
+		}
+		if weights == nil {
+			weights = make(map[string]float64)
+		}
+		weights[pattern] = weight
+	}
+	return weights
+}
+
+// processOwners reads the process_owners option, which maps OS accounts to
+// the person or team responsible for them. Entries without an owner are
+// skipped with a warning.
+func processOwners(v *viper.Viper) map[string]string {
+	var owners map[string]string
+	for account, owner := range v.GetStringMapString("process_owners") {
+		owner = strings.TrimSpace(owner)
+		if owner == "" {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring process_owners entry %q without an owner\n", account)
+			continue
+		}
+		if owners == nil {
+			owners = make(map[string]string)
+		}
+		owners[strings.ToLower(account)] = owner
+	}
+	return owners
+}
+
+// usageSinkConfig reads the usage_sink options. An invalid URL disables the
+// sink and an invalid timeout is replaced with the default, with a warning.
+func usageSinkConfig(v *viper.Viper) types.UsageSinkConfig {
+	sink := types.UsageSinkConfig{
+		URL:     strings.TrimSpace(v.GetString("usage_sink.url")),
+		Timeout: types.UsageSinkTimeout,
+		Headers: v.GetStringMapString("usage_sink.headers"),
+		Buffer:  v.GetBool("usage_sink.buffer"),
+	}
+	if sink.URL == "" {
+		return types.UsageSinkConfig{}
+	}
+
+	if err := checkWebhookURL(sink.URL); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid usage_sink.url %q: %v\n", sink.URL, err)
+		return types.UsageSinkConfig{}
+	}
+
+	if value := v.GetString("usage_sink.timeout"); value != "" {
+		timeout, err := utils.ParseDuration(value)
+		if err == nil && timeout <= 0 {
+			err = fmt.Errorf("must be greater than 0")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid usage_sink.timeout %q: %v\n", value, err)
+		} else {
+			sink.Timeout = timeout
+		}
+	}
+
+	return sink
+}
+
+// reminderWebhookConfig reads the reminder_webhook options. An invalid URL
+// disables reminders, with a warning.
+func reminderWebhookConfig(v *viper.Viper) types.WebhookConfig {
+	webhook := types.WebhookConfig{
+		URL:     strings.TrimSpace(v.GetString("reminder_webhook.url")),
+		Headers: v.GetStringMapString("reminder_webhook.headers"),
+	}
+	if webhook.URL == "" {
+		return types.WebhookConfig{}
+	}
+	if err := checkWebhookURL(webhook.URL); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid reminder_webhook.url %q: %v\n", webhook.URL, err)
+		return types.WebhookConfig{}
+	}
+	return webhook
+}
+
+// checkWebhookURL checks that a URL notifications are POSTed to is an
+// absolute http or https URL
+func checkWebhookURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("must be an http or https URL")
+	}
+	return nil
+}
+
+// redisTimeout reads one of the redis.*_timeout options, returning 0 to use
+// the default. Invalid values are reported and ignored.
+func redisTimeout(v *viper.Viper, key string) time.Duration {
+	value := strings.TrimSpace(v.GetString(key))
+	if value == "" {
+		return 0
+	}
+	timeout, err := utils.ParseDuration(value)
+	if err == nil && timeout <= 0 {
+		err = fmt.Errorf("must be greater than 0")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring invalid %s %q: %v\n", key, value, err)
+		return 0
 	}
+	return timeout
 }
 
+// lockConfig reads the lock_timeout and lock_max_retries options. Invalid
+// values are reported and replaced with the defaults, so that a typo can't
+// stop every command from allocating.
+func lockConfig(v *viper.Viper) (time.Duration, int) {
+	timeout, maxRetries := types.LockTimeout, types.MaxLockRetries
+
+	if value := v.GetString("lock_timeout"); value != "" {
+		parsed, err := utils.ParseDuration(value)
+		if err == nil {
+			err = types.ValidateLockSettings(parsed, maxRetries)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid lock_timeout %q: %v\n", value, err)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	if v.IsSet("lock_max_retries") {
+		retries := v.GetInt("lock_max_retries")
+		if err := types.ValidateLockSettings(timeout, retries); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid lock_max_retries %q: %v\n", v.GetString("lock_max_retries"), err)
+		} else {
+			maxRetries = retries
+		}
+	}
+
+	return timeout, maxRetries
+}
+
+// splitList splits comma-separated entries so that list values given as a
+// single environment variable ("host1,host2") behave like YAML lists
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// Execute runs the command line. If the command that fails was asked for
+// JSON output, the error is also written to stdout as a JSON object.
 func Execute(ctx context.Context) error {
-	return rootCmd.ExecuteContext(ctx)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	if err != nil && cmd != nil && jsonOutputRequested(cmd) {
+		_ = writeJSONError(os.Stdout, err)
+	}
+	return err
 }
 
 func SetVersion(v string) {
@@ -114,11 +650,7 @@ func bindAllFlags() {
 	// Walk through all commands and bind their flags
 	walkCommands(rootCmd, func(cmd *cobra.Command) {
 		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
-			// Create viper key from command and flag name
-			viperKey := flag.Name
-			if cmd.Name() != "canhazgpu" { // Don't prefix root command flags
-				viperKey = cmd.Name() + "." + flag.Name
-			}
+			viperKey := flagViperKey(cmd, flag)
 
 			// Bind flag to viper
 			if err := viper.BindPFlag(viperKey, flag); err != nil {
@@ -128,6 +660,68 @@ func bindAllFlags() {
 	})
 }
 
+// flagViperKey returns the viper key for a command flag, e.g. "run.timeout"
+func flagViperKey(cmd *cobra.Command, flag *pflag.Flag) string {
+	if cmd.Name() == "canhazgpu" { // Don't prefix root command flags
+		return flag.Name
+	}
+	return cmd.Name() + "." + flag.Name
+}
+
+// flagConfigured reports whether a flag has an environment variable or config
+// file entry.
+func flagConfigured(v *viper.Viper, cmd *cobra.Command, flag *pflag.Flag) bool {
+	key := flagViperKey(cmd, flag)
+	_, inEnv := os.LookupEnv(envVarName(key))
+	return inEnv || v.InConfig(key)
+}
+
+// applyConfigToFlags sets each flag that was not given on the command line
+// from its environment variable or config file entry, if either is present.
+// Flags given explicitly always win. Configured values are not marked as
+// changed, so Changed still means the flag was given on the command line and
+// mutually exclusive flag groups only consider the command line.
+func applyConfigToFlags(v *viper.Viper, cmd *cobra.Command) error {
+	var firstErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || flag.Name == "help" || flag.Name == "config" {
+			return
+		}
+		if !flagConfigured(v, cmd, flag) {
+			return
+		}
+
+		key := flagViperKey(cmd, flag)
+
+		var value string
+		switch v.Get(key).(type) {
+		case []interface{}, []string:
+			value = strings.Join(v.GetStringSlice(key), ",")
+		default:
+			value = v.GetString(key)
+		}
+
+		if err := flag.Value.Set(value); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("invalid value %q for %s: %v", value, key, err)
+		}
+	})
+	return firstErr
+}
+
+// stringFlagOrDefault returns a string flag's value. When the flag was not set
+// on the command line, in the environment, or in the command's config section,
+// the defaultKey config option is used instead if it is set. This lets admins
+// change a default (e.g. default_reserve_duration) without changing the flag.
+func stringFlagOrDefault(v *viper.Viper, cmd *cobra.Command, flagName, defaultKey string) string {
+	flag := cmd.Flags().Lookup(flagName)
+	if !flag.Changed && !flagConfigured(v, cmd, flag) {
+		if value := v.GetString(defaultKey); value != "" {
+			return value
+		}
+	}
+	return flag.Value.String()
+}
+
 // walkCommands recursively walks through all commands
 func walkCommands(cmd *cobra.Command, fn func(*cobra.Command)) {
 	fn(cmd)
@@ -145,3 +739,120 @@ func getCurrentUser() string {
 	}
 	return "unknown"
 }
+
+// resolveAccount returns the team account to bill usage to. Without an
+// explicit account, the current user's primary group is used, or no account
+// if it can't be determined.
+func resolveAccount(account string) string {
+	if account = strings.TrimSpace(account); account != "" {
+		return account
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		return ""
+	}
+	return group.Name
+}
+
+// gpuCountAll is the GPU count parseGPUCount returns for '--gpus all'
+const gpuCountAll = -1
+
+// parseGPUCount parses the value of --gpus, which is either a number of GPUs
+// or "all" for every GPU available at allocation time
+func parseGPUCount(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "all") {
+		return gpuCountAll, nil
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid --gpus value '%s': must be a positive number or 'all'", value)
+	}
+	return count, nil
+}
+
+// checkMinFreeDuration parses minFreeStr and checks that a reservation
+// lasting length (0 meaning until it is released) at the given priority can't
+// be preempted for at least that long. Preemption can take idle reservations
+// below high priority once they are PreemptionMinAge old. The returned
+// duration goes into AllocationRequest.MinFreeDuration, so that GPUs next to
+// reservations expiring sooner are skipped. An empty minFreeStr requires no
+// guarantee and returns 0.
+func checkMinFreeDuration(minFreeStr string, length time.Duration, priority string) (time.Duration, error) {
+	if minFreeStr == "" {
+		return 0, nil
+	}
+	minFree, err := utils.ParseDuration(minFreeStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid min free duration format: %v", err)
+	}
+
+	if length > 0 && length < minFree {
+		return 0, fmt.Errorf("the reservation lasts %s, less than the --min-free-duration of %s",
+			utils.FormatDuration(length), utils.FormatDuration(minFree))
+	}
+	if types.PriorityRank(priority) < types.PriorityRank(types.PriorityHigh) && minFree > types.PreemptionMinAge {
+		if priority == "" {
+			priority = types.PriorityNormal
+		}
+		return 0, fmt.Errorf("%s priority reservations may be preempted when idle after %s, so they can't be kept for the --min-free-duration of %s (use --priority %s)",
+			priority, utils.FormatDuration(types.PreemptionMinAge), utils.FormatDuration(minFree), types.PriorityHigh)
+	}
+	return minFree, nil
+}
+
+// checkGPUClass checks that --gpu-class names a configured GPU class and
+// isn't combined with options that choose the GPUs another way
+func checkGPUClass(classes map[string]types.GPUClass, className string, gpuCount int, gpuIDs []int) error {
+	if className == "" {
+		return nil
+	}
+	if len(gpuIDs) > 0 {
+		return fmt.Errorf("--gpu-class cannot be used with --gpu-ids")
+	}
+	if gpuCount == gpuCountAll {
+		return fmt.Errorf("--gpu-class cannot be used with --gpus all")
+	}
+
+	if classes == nil {
+		classes = types.DefaultGPUClasses()
+	}
+	if _, ok := classes[className]; !ok {
+		names := make([]string, 0, len(classes))
+		for name := range classes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown GPU class '%s'. Available classes: %s", className, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// reservedGPUsLabel describes how many GPUs were reserved, making it clear
+// when '--gpus all' was used
+func reservedGPUsLabel(count int, all bool) string {
+	if all {
+		return fmt.Sprintf("all %d available GPU(s)", count)
+	}
+	return fmt.Sprintf("%d GPU(s)", count)
+}
+
+// visibleDevices returns the CUDA_VISIBLE_DEVICES value for the given GPUs:
+// their UUIDs when GPUs are keyed by UUID, otherwise their indices
+func visibleDevices(config *types.Config, gpuIDs []int) string {
+	devices := make([]string, len(gpuIDs))
+	for i, gpuID := range gpuIDs {
+		if config.UseGPUUUIDs && gpuID < len(config.GPUUUIDs) {
+			devices[i] = config.GPUUUIDs[gpuID]
+		} else {
+			devices[i] = strconv.Itoa(gpuID)
+		}
+	}
+	return strings.Join(devices, ",")
+}
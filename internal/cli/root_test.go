@@ -0,0 +1,863 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "CANHAZGPU_REDIS_HOST", envVarName("redis.host"))
+	assert.Equal(t, "CANHAZGPU_MEMORY_THRESHOLD", envVarName("memory.threshold"))
+	assert.Equal(t, "CANHAZGPU_RUN_GPU_IDS", envVarName("run.gpu-ids"))
+	assert.Equal(t, "CANHAZGPU_REDIS_READ_HOST", envVarName("redis.read_host"))
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"gpu1", "gpu2"}, splitList([]string{"gpu1", "gpu2"}))
+	assert.Equal(t, []string{"gpu1", "gpu2", "gpu3"}, splitList([]string{"gpu1, gpu2", "gpu3"}))
+	assert.Nil(t, splitList(nil))
+}
+
+// newTestViper writes a YAML config file and loads it into a new viper
+// instance with environment overrides enabled
+func newTestViper(t *testing.T, yaml string) *viper.Viper {
+	path := filepath.Join(t.TempDir(), "canhazgpu.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yaml), 0644))
+
+	v := viper.New()
+	configureEnv(v)
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+	return v
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	v := newTestViper(t, `
+redis:
+  host: file-host
+  port: 1111
+  db: 1
+remote_hosts:
+  - gpu-server-1
+`)
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().Int("redis-db", 0, "")
+	cmd.Flags().Int("memory-threshold", 1024, "")
+	require.NoError(t, v.BindPFlag("redis.db", cmd.Flags().Lookup("redis-db")))
+	require.NoError(t, v.BindPFlag("memory.threshold", cmd.Flags().Lookup("memory-threshold")))
+
+	// Environment beats the config file
+	t.Setenv("CANHAZGPU_REDIS_PORT", "2222")
+	t.Setenv("CANHAZGPU_REMOTE_HOSTS", "gpu-server-2,gpu-server-3")
+
+	// An explicit flag beats the environment
+	t.Setenv("CANHAZGPU_REDIS_DB", "3")
+	require.NoError(t, cmd.Flags().Set("redis-db", "5"))
+
+	config := newConfigFromViper(v)
+	assert.Equal(t, "file-host", config.RedisHost) // config file
+	assert.Equal(t, 2222, config.RedisPort)        // env over file
+	assert.Equal(t, 5, config.RedisDB)             // flag over env and file
+	assert.Equal(t, 1024, config.MemoryThreshold)  // flag default
+	assert.Equal(t, "", config.RedisReadHost)      // unset
+	assert.Equal(t, []string{"gpu-server-2", "gpu-server-3"}, config.RemoteHostNames())
+}
+
+func TestReadReplicaConfig(t *testing.T) {
+	v := newTestViper(t, `
+redis:
+  host: primary
+  read_host: replica
+`)
+	t.Setenv("CANHAZGPU_REDIS_READ_PORT", "6380")
+
+	config := newConfigFromViper(v)
+	assert.Equal(t, "primary", config.RedisHost)
+	assert.Equal(t, "replica", config.RedisReadHost)
+	assert.Equal(t, 6380, config.RedisReadPort)
+}
+
+func TestRemoteHosts(t *testing.T) {
+	v := newTestViper(t, `
+remote_hosts:
+  - gpu-server-1
+  - host: gpu-server-2
+    ssh_user: ops
+    ssh_port: 2222
+    redis_port: 6380
+  - host: gpu-server-3
+    redis_port: 99999
+  - ssh_user: nobody
+  - "gpu-server-4, gpu-server-5"
+`)
+	assert.Equal(t, []types.RemoteHost{
+		{Host: "gpu-server-1"},
+		{Host: "gpu-server-2", SSHUser: "ops", SSHPort: 2222, RedisPort: 6380},
+		{Host: "gpu-server-4"},
+		{Host: "gpu-server-5"},
+	}, remoteHosts(v))
+
+	// TOML arrays of tables
+	v = viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`
+[[remote_hosts]]
+host = "gpu-server-1"
+
+[[remote_hosts]]
+host = "gpu-server-2"
+ssh_port = 2222
+`)))
+	assert.Equal(t, []types.RemoteHost{
+		{Host: "gpu-server-1"},
+		{Host: "gpu-server-2", SSHPort: 2222},
+	}, remoteHosts(v))
+
+	assert.Nil(t, remoteHosts(viper.New()))
+}
+
+func TestRemoteHostEntry(t *testing.T) {
+	_, err := remoteHostEntry(map[string]any{"host": "gpu1", "redis_port": 0})
+	assert.ErrorContains(t, err, "redis_port must be a port number")
+	_, err = remoteHostEntry(map[string]any{"host": "gpu1", "ssh_port": "abc"})
+	assert.ErrorContains(t, err, "ssh_port must be a port number")
+	_, err = remoteHostEntry(map[string]any{"host": "gpu1", "sshuser": "ops"})
+	assert.ErrorContains(t, err, `unknown option "sshuser"`)
+	_, err = remoteHostEntry(map[string]any{"ssh_user": "ops"})
+	assert.ErrorContains(t, err, "host is required")
+
+	host, err := remoteHostEntry(map[string]any{"host": "gpu1", "ssh_user": nil})
+	require.NoError(t, err)
+	assert.Equal(t, types.RemoteHost{Host: "gpu1"}, host)
+}
+
+func TestApplyConfigToFlags(t *testing.T) {
+	v := newTestViper(t, `
+example:
+  json: true
+  days: 7
+  hosts:
+    - a
+    - b
+`)
+
+	var jsonOut bool
+	var days int
+	var port int
+	var hosts []string
+	cmd := &cobra.Command{Use: "example"}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "")
+	cmd.Flags().IntVar(&days, "days", 30, "")
+	cmd.Flags().IntVar(&port, "port", 8080, "")
+	cmd.Flags().StringSliceVar(&hosts, "hosts", nil, "")
+
+	// Explicit flag wins over the environment and config file
+	require.NoError(t, cmd.Flags().Set("days", "14"))
+	t.Setenv("CANHAZGPU_EXAMPLE_DAYS", "21")
+
+	// Environment applies when the flag was not given
+	t.Setenv("CANHAZGPU_EXAMPLE_PORT", "9090")
+
+	require.NoError(t, applyConfigToFlags(v, cmd))
+
+	assert.True(t, jsonOut)                    // config file
+	assert.Equal(t, 14, days)                  // flag
+	assert.Equal(t, 9090, port)                // env
+	assert.Equal(t, []string{"a", "b"}, hosts) // config file list
+
+	// Invalid values are reported
+	var other bool
+	bad := &cobra.Command{Use: "bad"}
+	bad.Flags().BoolVar(&other, "json", false, "")
+	t.Setenv("CANHAZGPU_BAD_JSON", "maybe")
+	assert.Error(t, applyConfigToFlags(v, bad))
+}
+
+// resetFlags restores a command's flags to their defaults when the test ends
+func resetFlags(t *testing.T, cmd *cobra.Command) {
+	t.Cleanup(func() {
+		cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				_ = slice.Replace(nil)
+			} else {
+				_ = flag.Value.Set(flag.DefValue)
+			}
+			flag.Changed = false
+		})
+	})
+}
+
+func TestConfiguredFlagsNotChanged(t *testing.T) {
+	v := newTestViper(t, `
+run:
+  gpus: 2
+release:
+  gpu-ids: [1, 3]
+admin:
+  gpus: 8
+`)
+
+	// Configured values apply without counting as given on the command line
+	t.Run("run count-from-env", func(t *testing.T) {
+		resetFlags(t, runCmd)
+		require.NoError(t, runCmd.ParseFlags([]string{"--count-from-env"}))
+		require.NoError(t, applyConfigToFlags(v, runCmd))
+		assert.Equal(t, "2", runCmd.Flags().Lookup("gpus").Value.String())
+		assert.False(t, runCmd.Flags().Changed("gpus"))
+		assert.NoError(t, runCmd.ValidateFlagGroups())
+	})
+
+	t.Run("release all", func(t *testing.T) {
+		resetFlags(t, releaseCmd)
+		require.NoError(t, releaseCmd.ParseFlags([]string{"--all"}))
+		require.NoError(t, applyConfigToFlags(v, releaseCmd))
+		assert.False(t, releaseCmd.Flags().Changed("gpu-ids"))
+		assert.NoError(t, releaseCmd.ValidateFlagGroups())
+	})
+
+	for _, flag := range []string{"--export", "--import"} {
+		t.Run("admin "+flag, func(t *testing.T) {
+			resetFlags(t, adminCmd)
+			require.NoError(t, adminCmd.ParseFlags([]string{flag, "state.json"}))
+			require.NoError(t, applyConfigToFlags(v, adminCmd))
+			assert.Equal(t, "8", adminCmd.Flags().Lookup("gpus").Value.String())
+			assert.NoError(t, adminCmd.ValidateFlagGroups())
+		})
+	}
+
+	// Flags given on the command line are still mutually exclusive
+	t.Run("release all and gpu-ids", func(t *testing.T) {
+		resetFlags(t, releaseCmd)
+		require.NoError(t, releaseCmd.ParseFlags([]string{"--all", "--gpu-ids", "1"}))
+		assert.Error(t, releaseCmd.ValidateFlagGroups())
+	})
+}
+
+func TestStringFlagOrDefault(t *testing.T) {
+	v := newTestViper(t, `
+default_reserve_duration: 2h
+`)
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{Use: "reserve"}
+		cmd.Flags().String("duration", "30m", "")
+		cmd.Flags().String("timeout", "", "")
+		return cmd
+	}
+
+	// Config default applies when the flag is unset
+	cmd := newCmd()
+	assert.Equal(t, "2h", stringFlagOrDefault(v, cmd, "duration", "default_reserve_duration"))
+
+	// No config default falls back to the flag default
+	assert.Equal(t, "", stringFlagOrDefault(v, cmd, "timeout", "default_run_timeout"))
+
+	// Environment variables set the default too
+	t.Setenv("CANHAZGPU_DEFAULT_RUN_TIMEOUT", "4h")
+	assert.Equal(t, "4h", stringFlagOrDefault(v, cmd, "timeout", "default_run_timeout"))
+
+	// The command's own config section wins over the default
+	t.Setenv("CANHAZGPU_RESERVE_DURATION", "1h")
+	cmd = newCmd()
+	require.NoError(t, applyConfigToFlags(v, cmd))
+	assert.Equal(t, "1h", stringFlagOrDefault(v, cmd, "duration", "default_reserve_duration"))
+
+	// An explicit flag wins
+	require.NoError(t, cmd.Flags().Set("duration", "45m"))
+	assert.Equal(t, "45m", stringFlagOrDefault(v, cmd, "duration", "default_reserve_duration"))
+}
+
+func TestResolveAccount(t *testing.T) {
+	assert.Equal(t, "ml-infra", resolveAccount("ml-infra"))
+	assert.Equal(t, "ml-infra", resolveAccount("  ml-infra "))
+
+	// Defaults to the primary group
+	current, err := user.Current()
+	require.NoError(t, err)
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		assert.Equal(t, "", resolveAccount(""))
+		return
+	}
+	assert.Equal(t, group.Name, resolveAccount(""))
+}
+
+func TestParseGPUCount(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		wantErr  bool
+	}{
+		{"1", 1, false},
+		{"8", 8, false},
+		{"0", 0, false},
+		{"all", gpuCountAll, false},
+		{"ALL", gpuCountAll, false},
+		{" all ", gpuCountAll, false},
+		{"-2", 0, true},
+		{"two", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			count, err := parseGPUCount(tt.value)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "must be a positive number or 'all'")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, count)
+		})
+	}
+}
+
+func TestCheckMinFreeDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		minFree    string
+		length     time.Duration
+		priority   string
+		expected   time.Duration
+		errContain string
+	}{
+		{"no guarantee requested", "", 5 * time.Minute, types.PriorityLow, 0, ""},
+		{"long enough reservation", "1h", 2 * time.Hour, types.PriorityHigh, time.Hour, ""},
+		{"no end to the reservation", "1h", 0, types.PriorityHigh, time.Hour, ""},
+		{"within preemption grace period", "10m", 30 * time.Minute, types.PriorityLow, 10 * time.Minute, ""},
+		{"reservation too short", "1h", 30 * time.Minute, types.PriorityHigh, 0, "less than the --min-free-duration"},
+		{"normal priority may be preempted", "1h", 2 * time.Hour, types.PriorityNormal, 0, "normal priority reservations may be preempted"},
+		{"empty priority is normal", "1h", 2 * time.Hour, "", 0, "normal priority reservations may be preempted"},
+		{"low priority may be preempted", "1h", 0, types.PriorityLow, 0, "low priority reservations may be preempted"},
+		{"invalid duration", "soon", time.Hour, types.PriorityHigh, 0, "invalid min free duration format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minFree, err := checkMinFreeDuration(tt.minFree, tt.length, tt.priority)
+			if tt.errContain == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, minFree)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errContain)
+		})
+	}
+}
+
+func TestParseRemindBefore(t *testing.T) {
+	remindBefore, err := parseRemindBefore("", 2*time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, remindBefore)
+
+	remindBefore, err = parseRemindBefore("30m", 2*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, remindBefore)
+
+	_, err = parseRemindBefore("2h", 2*time.Hour)
+	assert.ErrorContains(t, err, "must be shorter than --duration")
+	_, err = parseRemindBefore("0s", 2*time.Hour)
+	assert.ErrorContains(t, err, "must be at least 1s")
+	_, err = parseRemindBefore("soon", 2*time.Hour)
+	assert.ErrorContains(t, err, "invalid --remind-before")
+}
+
+func TestRunReserve_Validation(t *testing.T) {
+	// Invalid options are rejected before connecting to Redis
+	tests := []struct {
+		name       string
+		opts       reserveOptions
+		errContain string
+	}{
+		{"invalid duration", reserveOptions{Duration: "soon"}, "invalid"},
+		{"shared with a reminder", reserveOptions{Duration: "2h", Shared: true, RemindBefore: "30m"}, "--shared cannot be used with --remind-before"},
+		{"reminder after expiry", reserveOptions{Duration: "1h", RemindBefore: "2h"}, "must be shorter than --duration"},
+		{"short renewable reservation", reserveOptions{Duration: "10s", Renewable: true}, "renewable reservations must be at least"},
+		{"invalid wait", reserveOptions{Duration: "1h", Wait: "soon"}, "invalid wait timeout format"},
+		{"invalid priority", reserveOptions{Duration: "1h", Priority: "urgent"}, "priority"},
+		{"reservation shorter than the min free duration", reserveOptions{Duration: "30m", Priority: types.PriorityHigh, MinFreeDuration: "1h"}, "less than the --min-free-duration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, runReserve(context.Background(), tt.opts), tt.errContain)
+		})
+	}
+}
+
+func TestReservedGPUsLabel(t *testing.T) {
+	assert.Equal(t, "2 GPU(s)", reservedGPUsLabel(2, false))
+	assert.Equal(t, "all 6 available GPU(s)", reservedGPUsLabel(6, true))
+}
+
+func TestVisibleDevices(t *testing.T) {
+	config := &types.Config{}
+	assert.Equal(t, "0,2", visibleDevices(config, []int{0, 2}))
+
+	config.UseGPUUUIDs = true
+	config.GPUUUIDs = []string{"GPU-aaaa", "GPU-bbbb", "GPU-cccc"}
+	assert.Equal(t, "GPU-aaaa,GPU-cccc", visibleDevices(config, []int{0, 2}))
+}
+
+func TestLockConfig(t *testing.T) {
+	timeout, retries := lockConfig(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Equal(t, types.LockTimeout, timeout)
+	assert.Equal(t, types.MaxLockRetries, retries)
+
+	timeout, retries = lockConfig(newTestViper(t, "lock_timeout: 30s\nlock_max_retries: 8\n"))
+	assert.Equal(t, 30*time.Second, timeout)
+	assert.Equal(t, 8, retries)
+
+	// Invalid values fall back to the defaults
+	timeout, retries = lockConfig(newTestViper(t, "lock_timeout: 1h\nlock_max_retries: 0\n"))
+	assert.Equal(t, types.LockTimeout, timeout)
+	assert.Equal(t, types.MaxLockRetries, retries)
+
+	timeout, _ = lockConfig(newTestViper(t, "lock_timeout: soon\n"))
+	assert.Equal(t, types.LockTimeout, timeout)
+
+	t.Setenv("CANHAZGPU_LOCK_MAX_RETRIES", "3")
+	_, retries = lockConfig(newTestViper(t, "lock_max_retries: 8\n"))
+	assert.Equal(t, 3, retries)
+}
+
+func TestRedisTimeoutConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Zero(t, config.RedisDialTimeout)
+	assert.Zero(t, config.RedisReadTimeout)
+	assert.Zero(t, config.RedisWriteTimeout)
+
+	config = newConfigFromViper(newTestViper(t, "redis:\n  dial_timeout: 2s\n  read_timeout: 10s\n  write_timeout: 1m\n"))
+	assert.Equal(t, 2*time.Second, config.RedisDialTimeout)
+	assert.Equal(t, 10*time.Second, config.RedisReadTimeout)
+	assert.Equal(t, time.Minute, config.RedisWriteTimeout)
+
+	// Invalid values fall back to the default
+	config = newConfigFromViper(newTestViper(t, "redis:\n  dial_timeout: soon\n  read_timeout: 0s\n"))
+	assert.Zero(t, config.RedisDialTimeout)
+	assert.Zero(t, config.RedisReadTimeout)
+
+	t.Setenv("CANHAZGPU_REDIS_READ_TIMEOUT", "3s")
+	config = newConfigFromViper(newTestViper(t, "redis:\n  read_timeout: 10s\n"))
+	assert.Equal(t, 3*time.Second, config.RedisReadTimeout)
+}
+
+func TestUsageSinkConfig(t *testing.T) {
+	assert.Equal(t, types.UsageSinkConfig{}, usageSinkConfig(newTestViper(t, "redis:\n  host: localhost\n")))
+
+	sink := usageSinkConfig(newTestViper(t, `
+usage_sink:
+  url: https://warehouse.example.com/usage
+  timeout: 2s
+  buffer: true
+  headers:
+    Authorization: Bearer secret
+`))
+	assert.Equal(t, "https://warehouse.example.com/usage", sink.URL)
+	assert.Equal(t, 2*time.Second, sink.Timeout)
+	assert.True(t, sink.Buffer)
+	assert.Equal(t, "Bearer secret", sink.Headers["authorization"])
+
+	// An invalid timeout falls back to the default
+	sink = usageSinkConfig(newTestViper(t, "usage_sink:\n  url: http://localhost:8080/usage\n  timeout: soon\n"))
+	assert.Equal(t, "http://localhost:8080/usage", sink.URL)
+	assert.Equal(t, types.UsageSinkTimeout, sink.Timeout)
+
+	// An invalid URL disables the sink
+	assert.Equal(t, types.UsageSinkConfig{}, usageSinkConfig(newTestViper(t, "usage_sink:\n  url: kafka://broker:9092/usage\n")))
+	assert.Equal(t, types.UsageSinkConfig{}, usageSinkConfig(newTestViper(t, "usage_sink:\n  url: /usage\n")))
+
+	t.Setenv("CANHAZGPU_USAGE_SINK_URL", "https://override.example.com/usage")
+	sink = usageSinkConfig(newTestViper(t, "usage_sink:\n  url: http://localhost:8080/usage\n"))
+	assert.Equal(t, "https://override.example.com/usage", sink.URL)
+}
+
+func TestReminderWebhookConfig(t *testing.T) {
+	assert.Equal(t, types.WebhookConfig{}, reminderWebhookConfig(newTestViper(t, "redis:\n  host: localhost\n")))
+
+	webhook := reminderWebhookConfig(newTestViper(t, `
+reminder_webhook:
+  url: https://hooks.example.com/canhazgpu
+  headers:
+    Authorization: Bearer secret
+`))
+	assert.Equal(t, "https://hooks.example.com/canhazgpu", webhook.URL)
+	assert.Equal(t, "Bearer secret", webhook.Headers["authorization"])
+
+	// An invalid URL disables reminders
+	assert.Equal(t, types.WebhookConfig{}, reminderWebhookConfig(newTestViper(t, "reminder_webhook:\n  url: hooks.example.com\n")))
+}
+
+func TestApplyProfile(t *testing.T) {
+	yaml := `
+redis:
+  host: localhost
+  port: 6379
+memory:
+  threshold: 2048
+remote_hosts: [local1]
+profiles:
+  prod:
+    redis:
+      host: redis.prod.example.com
+    remote_hosts: [gpu1, gpu2]
+    run:
+      timeout: 4h
+  Dev:
+    redis:
+      port: 6380
+`
+
+	v := newTestViper(t, yaml)
+	require.NoError(t, applyProfile(v, "prod"))
+	cfg := newConfigFromViper(v)
+	assert.Equal(t, "redis.prod.example.com", cfg.RedisHost)
+	assert.Equal(t, 6379, cfg.RedisPort, "unset profile values fall back to the top level")
+	assert.Equal(t, 2048, cfg.MemoryThreshold)
+	assert.Equal(t, []string{"gpu1", "gpu2"}, cfg.RemoteHostNames())
+	assert.True(t, v.InConfig("run.timeout"))
+	assert.Equal(t, "4h", v.GetString("run.timeout"))
+
+	// Profile names are case-insensitive
+	v = newTestViper(t, yaml)
+	require.NoError(t, applyProfile(v, "DEV"))
+	cfg = newConfigFromViper(v)
+	assert.Equal(t, "localhost", cfg.RedisHost)
+	assert.Equal(t, 6380, cfg.RedisPort)
+
+	// No profile leaves the top-level settings alone
+	v = newTestViper(t, yaml)
+	require.NoError(t, applyProfile(v, ""))
+	assert.Equal(t, "localhost", newConfigFromViper(v).RedisHost)
+
+	// Environment variables still override the profile
+	t.Setenv("CANHAZGPU_REDIS_HOST", "redis.override.example.com")
+	v = newTestViper(t, yaml)
+	require.NoError(t, applyProfile(v, "prod"))
+	assert.Equal(t, "redis.override.example.com", newConfigFromViper(v).RedisHost)
+
+	err := applyProfile(newTestViper(t, yaml), "staging")
+	assert.ErrorContains(t, err, "profile 'staging' not found. Available profiles: dev, prod")
+
+	err = applyProfile(newTestViper(t, "redis:\n  host: localhost\n"), "prod")
+	assert.ErrorContains(t, err, "no profiles are defined")
+}
+
+func TestSMIPathConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Empty(t, config.NvidiaSMIPath)
+	assert.Empty(t, config.AMDSMIPath)
+
+	config = newConfigFromViper(newTestViper(t, "nvidia_smi_path: /opt/nvidia/bin/nvidia-smi\namd_smi_path: /opt/rocm/bin/amd-smi\n"))
+	assert.Equal(t, "/opt/nvidia/bin/nvidia-smi", config.NvidiaSMIPath)
+	assert.Equal(t, "/opt/rocm/bin/amd-smi", config.AMDSMIPath)
+
+	nvidiaCommand, amdCommand := remoteGPUModelCommands(config)
+	assert.True(t, strings.HasPrefix(nvidiaCommand, "'/opt/nvidia/bin/nvidia-smi' --query-gpu=gpu_name"))
+	assert.True(t, strings.HasPrefix(amdCommand, "'/opt/rocm/bin/amd-smi' list --json"))
+
+	nvidiaCommand, _ = remoteGPUModelCommands(&types.Config{})
+	assert.True(t, strings.HasPrefix(nvidiaCommand, "nvidia-smi "))
+}
+
+func TestMaxSharesPerGPUConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Equal(t, 0, config.MaxSharesPerGPU)
+
+	config = newConfigFromViper(newTestViper(t, "max_shares_per_gpu: 4\n"))
+	assert.Equal(t, 4, config.MaxSharesPerGPU)
+
+	config = newConfigFromViper(newTestViper(t, "max_shares_per_gpu: -1\n"))
+	assert.Equal(t, 0, config.MaxSharesPerGPU)
+}
+
+func TestQueueLimitsConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Equal(t, 0, config.MaxQueueLength)
+	assert.Equal(t, 0, config.MaxQueueEntriesPerUser)
+
+	config = newConfigFromViper(newTestViper(t, "max_queue_length: 20\nmax_queue_entries_per_user: 3\n"))
+	assert.Equal(t, 20, config.MaxQueueLength)
+	assert.Equal(t, 3, config.MaxQueueEntriesPerUser)
+
+	config = newConfigFromViper(newTestViper(t, "max_queue_length: -1\n"))
+	assert.Equal(t, 0, config.MaxQueueLength)
+}
+
+func TestRecordUsageHistoryConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.False(t, config.DisableUsageHistory)
+
+	config = newConfigFromViper(newTestViper(t, "record_usage_history: true\n"))
+	assert.False(t, config.DisableUsageHistory)
+
+	config = newConfigFromViper(newTestViper(t, "record_usage_history: false\n"))
+	assert.True(t, config.DisableUsageHistory)
+}
+
+func TestGPUHourWeightsConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Nil(t, config.GPUHourWeights)
+
+	config = newConfigFromViper(newTestViper(t, "gpu_hour_weights:\n  H100: 3\n  rtx 3090: \"0.5\"\n  a100: lots\n  v100: -1\n"))
+	assert.Equal(t, map[string]float64{"h100": 3, "rtx 3090": 0.5}, config.GPUHourWeights)
+}
+
+func TestProcessOwnersConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Nil(t, config.ProcessOwners)
+
+	config = newConfigFromViper(newTestViper(t, "process_owners:\n  svc-vllm: \" alice \"\n  Inference: ml-platform\n  orphan: \"\"\n"))
+	assert.Equal(t, map[string]string{"svc-vllm": "alice", "inference": "ml-platform"}, config.ProcessOwners)
+}
+
+func TestCheckGPUClass(t *testing.T) {
+	assert.NoError(t, checkGPUClass(nil, "", 1, []int{0}))
+	assert.NoError(t, checkGPUClass(nil, types.GPUClassLarge, 2, nil))
+	assert.EqualError(t, checkGPUClass(nil, "huge", 2, nil), "unknown GPU class 'huge'. Available classes: large, small")
+	assert.EqualError(t, checkGPUClass(nil, types.GPUClassSmall, 0, []int{0}), "--gpu-class cannot be used with --gpu-ids")
+	assert.EqualError(t, checkGPUClass(nil, types.GPUClassSmall, gpuCountAll, nil), "--gpu-class cannot be used with --gpus all")
+
+	classes := map[string]types.GPUClass{"huge": {MinMemoryMB: 100000}}
+	assert.NoError(t, checkGPUClass(classes, "huge", 1, nil))
+}
+
+func TestGPUClassesConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Nil(t, config.GPUClasses)
+
+	config = newConfigFromViper(newTestViper(t, "gpu_classes:\n"+
+		"  small:\n    max_memory_mb: 30000\n"+
+		"  huge:\n    min_memory_mb: 100000\n"+
+		"  mid:\n    min_memory_mb: 40000\n    max_memory_mb: 90000\n"+
+		"  backwards:\n    min_memory_mb: 80000\n    max_memory_mb: 40000\n"+
+		"  typo:\n    min_memory_mb: lots\n"))
+	assert.Equal(t, map[string]types.GPUClass{
+		"small": {MaxMemoryMB: 30000},
+		"large": {MinMemoryMB: types.DefaultGPUClassBoundaryMB},
+		"huge":  {MinMemoryMB: 100000},
+		"mid":   {MinMemoryMB: 40000, MaxMemoryMB: 90000},
+	}, config.GPUClasses)
+}
+
+func TestCommandRedactFlagsConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Empty(t, config.CommandRedactFlags)
+
+	config = newConfigFromViper(newTestViper(t, "command_redact_flags:\n  - --wandb-key\n  - \" --db-password \"\n"))
+	assert.Equal(t, []string{"--wandb-key", "--db-password"}, config.CommandRedactFlags)
+}
+
+func TestIgnoreUnreservedUsageConfig(t *testing.T) {
+	config := newConfigFromViper(newTestViper(t, "redis:\n  host: localhost\n"))
+	assert.Empty(t, config.IgnoreUsers)
+	assert.Empty(t, config.IgnoreProcesses)
+
+	config = newConfigFromViper(newTestViper(t, "ignore_users: [gdm, dcgm]\nignore_processes:\n  - Xorg\n  - dcgm-exporter\n"))
+	assert.Equal(t, []string{"gdm", "dcgm"}, config.IgnoreUsers)
+	assert.Equal(t, []string{"Xorg", "dcgm-exporter"}, config.IgnoreProcesses)
+}
+
+func TestConfigFileType(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/alice/.canhazgpu.yaml", "yaml"},
+		{"config.YML", "yaml"},
+		{"/etc/canhazgpu/config.toml", "toml"},
+		{"config.json", "json"},
+		{"/home/alice/.canhazgpu", "yaml"},
+		{"canhazgpu-config", "yaml"},
+	}
+	for _, tt := range tests {
+		format, err := configFileType(tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, format, tt.path)
+	}
+
+	_, err := configFileType("config.ini")
+	assert.ErrorContains(t, err, `unsupported config file format ".ini"`)
+}
+
+func TestFindConfigFile(t *testing.T) {
+	home, cwd := t.TempDir(), t.TempDir()
+	assert.Equal(t, "", findConfigFile([]string{home, cwd}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(cwd, ".canhazgpu.yaml"), nil, 0644))
+	assert.Equal(t, filepath.Join(cwd, ".canhazgpu.yaml"), findConfigFile([]string{home, cwd}))
+
+	// The home directory comes first, whatever the format
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".canhazgpu.json"), nil, 0644))
+	assert.Equal(t, filepath.Join(home, ".canhazgpu.json"), findConfigFile([]string{home, cwd}))
+
+	// YAML comes first within a directory
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".canhazgpu.toml"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".canhazgpu.yml"), nil, 0644))
+	assert.Equal(t, filepath.Join(home, ".canhazgpu.yml"), findConfigFile([]string{home, cwd}))
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A missing file is ignored
+	v := viper.New()
+	require.NoError(t, readConfigFile(v, filepath.Join(dir, "missing.yaml")))
+
+	path := filepath.Join(dir, "broken.toml")
+	require.NoError(t, os.WriteFile(path, []byte("redis = [\n"), 0644))
+	assert.ErrorContains(t, readConfigFile(viper.New(), path), "failed to read config file")
+
+	assert.ErrorContains(t, readConfigFile(viper.New(), filepath.Join(dir, "config.ini")), "unsupported config file format")
+}
+
+func TestConfigFormatsAreEquivalent(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+redis:
+  host: redis.example.com
+  port: 6380
+remote_hosts:
+  - gpu-server-1
+  - gpu-server-2
+cooldown: 30
+gpu_classes:
+  huge:
+    min_memory_mb: 100000
+gpu_hour_weights:
+  "H100*": 2.5
+usage_sink:
+  url: https://warehouse.example.com/usage
+  headers:
+    Authorization: Bearer secret
+run:
+  timeout: 2h
+  gpu-ids: [1, 3]
+profiles:
+  staging:
+    redis:
+      host: staging-redis
+`,
+		"config.toml": `
+remote_hosts = ["gpu-server-1", "gpu-server-2"]
+cooldown = 30
+
+[redis]
+host = "redis.example.com"
+port = 6380
+
+[gpu_classes.huge]
+min_memory_mb = 100000
+
+[gpu_hour_weights]
+"H100*" = 2.5
+
+[usage_sink]
+url = "https://warehouse.example.com/usage"
+
+[usage_sink.headers]
+Authorization = "Bearer secret"
+
+[run]
+timeout = "2h"
+gpu-ids = [1, 3]
+
+[profiles.staging.redis]
+host = "staging-redis"
+`,
+		"config.json": `{
+  "redis": {"host": "redis.example.com", "port": 6380},
+  "remote_hosts": ["gpu-server-1", "gpu-server-2"],
+  "cooldown": 30,
+  "gpu_classes": {"huge": {"min_memory_mb": 100000}},
+  "gpu_hour_weights": {"H100*": 2.5},
+  "usage_sink": {
+    "url": "https://warehouse.example.com/usage",
+    "headers": {"Authorization": "Bearer secret"}
+  },
+  "run": {"timeout": "2h", "gpu-ids": [1, 3]},
+  "profiles": {"staging": {"redis": {"host": "staging-redis"}}}
+}`,
+	}
+
+	load := func(name, profile string) *viper.Viper {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(files[name]), 0644))
+		v := viper.New()
+		require.NoError(t, readConfigFile(v, path))
+		require.NoError(t, applyProfile(v, profile))
+		return v
+	}
+
+	yamlViper := load("config.yaml", "")
+	want := newConfigFromViper(yamlViper)
+	assert.Equal(t, "redis.example.com", want.RedisHost)
+	assert.Equal(t, 6380, want.RedisPort)
+	assert.Equal(t, []string{"gpu-server-1", "gpu-server-2"}, want.RemoteHostNames())
+	assert.Equal(t, 100000, want.GPUClasses["huge"].MinMemoryMB)
+	assert.Equal(t, 2.5, want.GPUHourWeights["h100*"])
+	assert.Equal(t, "Bearer secret", want.UsageSink.Headers["authorization"])
+
+	for _, name := range []string{"config.toml", "config.json"} {
+		t.Run(name, func(t *testing.T) {
+			v := load(name, "")
+			assert.Equal(t, want, newConfigFromViper(v))
+			assert.Equal(t, yamlViper.GetString("run.timeout"), v.GetString("run.timeout"))
+			assert.Equal(t, yamlViper.GetIntSlice("run.gpu-ids"), v.GetIntSlice("run.gpu-ids"))
+
+			assert.Equal(t, "staging-redis", newConfigFromViper(load(name, "staging")).RedisHost)
+		})
+	}
+}
+
+func TestApplyPool(t *testing.T) {
+	yaml := `
+redis:
+  db: 3
+pools:
+  inference: 1
+  Training: 2
+  broken: two
+`
+
+	v := newTestViper(t, yaml)
+	require.NoError(t, applyPool(v, "inference"))
+	assert.Equal(t, 1, newConfigFromViper(v).RedisDB)
+
+	// Pool names are case-insensitive
+	v = newTestViper(t, yaml)
+	require.NoError(t, applyPool(v, "TRAINING"))
+	assert.Equal(t, 2, newConfigFromViper(v).RedisDB)
+
+	// No pool leaves the Redis database alone
+	v = newTestViper(t, yaml)
+	require.NoError(t, applyPool(v, ""))
+	assert.Equal(t, 3, newConfigFromViper(v).RedisDB)
+
+	err := applyPool(newTestViper(t, yaml), "batch")
+	assert.ErrorContains(t, err, "pool 'batch' not found. Available pools: broken, inference, training")
+
+	err = applyPool(newTestViper(t, yaml), "broken")
+	assert.ErrorContains(t, err, "pool 'broken' must be a Redis database number")
+
+	err = applyPool(newTestViper(t, "redis:\n  db: 0\n"), "inference")
+	assert.ErrorContains(t, err, "no pools are defined in the config file")
+}
+
+func TestPoolLabel(t *testing.T) {
+	assert.Equal(t, "", poolLabel(""))
+	assert.Equal(t, " in pool 'training'", poolLabel("training"))
+}
@@ -2,9 +2,12 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
@@ -64,15 +67,120 @@ GPUs after a specified duration. When the timeout is reached, SIGINT will be sen
 the entire process group (including all child processes) for graceful shutdown,
 followed by a 30-second grace period. If any processes haven't exited after the
 grace period, the entire process group will be force-killed with SIGKILL.
+A warning is printed when 90% of the timeout has elapsed, giving you a chance to
+checkpoint; use --expiry-warning to change the percentage, or 0 to disable it.
 This is useful for preventing runaway processes from holding GPUs indefinitely.
+Admins can set a default timeout with the default_run_timeout config option.
+For frameworks that save a checkpoint on a signal, --checkpoint-signal (e.g.,
+USR1) is sent to the process group first when the timeout is reached, and
+SIGINT only after --checkpoint-grace (default 5m) if the command is still
+running.
+
+Reservations carry a priority (low, normal, or high; default normal). With
+--preempt, if not enough GPUs are free, canhazgpu will take over reservations
+held by other users at a strictly lower priority, but only if they have been
+held for at least 10 minutes and their GPUs show no processes and memory usage
+below the threshold. A preempted 'run' job is notified and terminated by its
+supervisor. Nothing is preempted unless the whole request can be satisfied.
+
+Use --gpus all to reserve every GPU that is available at the time of the
+request, e.g. for whole-node jobs. It fails if any GPU is in unreserved use,
+unless the gpus_all_exclude_unreserved config option is set, in which case
+those GPUs are skipped. Requests for all GPUs never wait in the queue.
+
+On a host with a mix of GPUs, use --gpu-class to reserve only GPUs of a
+memory class: small (less than 40000MB of memory) or large (40000MB or more).
+Other classes can be defined with the gpu_classes config option. The GPUs are
+chosen from the class as usual, and the request waits in the queue for GPUs
+of that class.
+
+With --model-hints, canhazgpu detects the model from the command (e.g. the
+model argument to 'vllm serve') and warns before launching if fewer GPUs were
+requested than the model typically needs. The warning is advisory only. Hints
+can be added or overridden with the model_gpu_hints config option.
+
+Use --cpu-limit and --mem-limit to also cap the command's CPU and memory use
+with a cgroup v2, so a GPU job can't starve the rest of the host. If cgroups
+can't be managed (e.g. no cgroup v2 or no permission), a warning is printed
+and the command runs without limits.
+
+Use --nice to lower the CPU scheduling priority of the command, e.g. --nice 10
+for background jobs whose data loading shouldn't slow down interactive work
+on the same host, and --ionice idle or --ionice best-effort to lower its disk
+priority too. GPU scheduling isn't affected. Negative nice values need root.
+If a priority can't be set, a warning is printed and the command runs anyway.
+
+With --require-clean, the reserved GPUs are checked before the command
+starts. If any GPU has more memory in use than --clean-threshold (100MB by
+default), for example because a previous job is still shutting down, the
+processes found on it are listed and the GPUs are released without running
+the command. Use --clean-wait to wait up to the given time for the GPUs to
+become clean instead of giving up right away.
+
+With --health-check, the reserved GPUs are checked with nvidia-smi before
+the command starts. A GPU with uncorrected ECC errors or memory pages
+waiting to be retired, or one nvidia-smi can't see at all, is replaced with
+another available GPU and then released, so that one bad GPU doesn't fail a
+multi-GPU job. If no replacement is available, all the GPUs are released
+and the command doesn't run. Specific --gpu-ids can't be replaced, so an
+unhealthy one is an error; with --gpus all, unhealthy GPUs are left out.
+
+Wrappers that need to know which GPUs were allocated can use --gpu-ids-file
+to have them written as JSON to a file or file descriptor before the command
+starts, instead of parsing the "Reserved N GPU(s)" message. --allocation-json
+writes the same JSON, along with the timeout, note, and account, to stderr
+(or to a file with --allocation-json=FILE).
+
+Use --working-dir to start the command in another directory and --env
+KEY=VALUE (repeatable) to add or override environment variables, without
+wrapping the command in a shell. CUDA_VISIBLE_DEVICES is always set by
+canhazgpu and can't be overridden with --env.
+
+Under SLURM, use --count-from-env to reserve the GPUs SLURM allocated to the
+job instead of choosing them with --gpus or --gpu-ids. The count is read from
+SLURM_GPUS_ON_NODE, or another variable with --count-from-env=VAR, and the
+GPUs listed in SLURM_JOB_GPUS are reserved if it is set. The reservation shows
+up in status and reports, and the command keeps the CUDA_VISIBLE_DEVICES that
+SLURM set.
+
+Stdin is passed on when it is a terminal, a pipe, or a file, as in:
+generate | canhazgpu run -- cmd. Otherwise, e.g. when a service manager gives
+canhazgpu a socket, the command gets /dev/null as stdin, so that a batch job
+can't hang reading input nobody sends. Use --no-stdin to close stdin always,
+or --no-stdin=false to always pass it on.
+
+On HPC systems where GPU software is set up by environment modules, use
+--login-shell to run the command through a login shell, $SHELL -l -c, which
+reads your shell profile first. CUDA_VISIBLE_DEVICES is exported to the
+shell. Like ssh, the arguments are joined with spaces and parsed by the
+shell, so shell syntax works but arguments containing spaces or quotes must
+be quoted again, e.g. -- 'module load cuda && python train.py "my run"'.
+
+Use --on-failure and --on-success to run a shell command once the command
+exits, for example to post an alert, depending on whether it succeeded. The
+hook runs before the GPUs are released, with CANHAZGPU_EXIT_CODE and
+CANHAZGPU_GPU_IDS set. canhazgpu then exits with the command's exit status.
 
 Example usage:
   canhazgpu run --gpus 1 -- python train.py
   canhazgpu run --gpus 2 -- python -m torch.distributed.launch train.py
   canhazgpu run --gpu-ids 1,3 -- python train.py
+  canhazgpu run --gpus 2 --gpu-class large -- python train.py
+  canhazgpu run --gpus all -- torchrun --nproc-per-node gpu train.py
   canhazgpu run --gpus 1 --timeout 2h -- python long_training.py
+  canhazgpu run --timeout 24h --checkpoint-signal USR1 --checkpoint-grace 10m -- python train.py  # Checkpoint at the deadline
   canhazgpu run --nonblock --gpus 4 -- python train.py  # Fail if unavailable
   canhazgpu run --wait 30m --gpus 4 -- python train.py  # Wait up to 30 minutes
+  canhazgpu run --priority high --preempt --gpus 2 -- python train.py
+  canhazgpu run --gpus 1 --cpu-limit 8 --mem-limit 64G -- python train.py
+  canhazgpu run --gpus 1 --nice 10 --ionice idle -- python preprocess.py
+  canhazgpu run --gpus 2 --require-clean --clean-wait 2m -- python train.py
+  canhazgpu run --gpus 8 --health-check -- torchrun --nproc-per-node 8 train.py
+  canhazgpu run --working-dir ~/exp1 --env HF_HOME=/data/hf -- python train.py
+  canhazgpu run --gpus 2 --log-dir ~/logs/train -- python train.py
+  canhazgpu run --count-from-env -- python train.py  # Inside a SLURM job
+  canhazgpu run --login-shell -- 'module load cuda/12.4 && python train.py'
+  canhazgpu run --on-failure './notify.sh "train failed: $CANHAZGPU_EXIT_CODE"' -- python train.py
 
 Timeout formats supported:
 - 30s (30 seconds)
@@ -84,13 +192,60 @@ Timeout formats supported:
 The '--' separator is required - it tells canhazgpu where its options end
 and your command begins.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		gpuCount := viper.GetInt("run.gpus")
-		gpuIDs := viper.GetIntSlice("run.gpu-ids")
-		timeoutStr := viper.GetString("run.timeout")
-		note := viper.GetString("run.note")
-		customUser := viper.GetString("run.user")
-		nonblock := viper.GetBool("run.nonblock")
-		waitStr := viper.GetString("run.wait")
+		gpuCount, err := parseGPUCount(viper.GetString("run.gpus"))
+		if err != nil {
+			return err
+		}
+		stdinMode, stdinOK := fileMode(os.Stdin)
+		opts := runOptions{
+			GPUCount:         gpuCount,
+			GPUIDs:           viper.GetIntSlice("run.gpu-ids"),
+			Timeout:          stringFlagOrDefault(viper.GetViper(), cmd, "timeout", "default_run_timeout"),
+			Note:             viper.GetString("run.note"),
+			User:             viper.GetString("run.user"),
+			Nonblock:         viper.GetBool("run.nonblock"),
+			Wait:             viper.GetString("run.wait"),
+			Priority:         viper.GetString("run.priority"),
+			Preempt:          viper.GetBool("run.preempt"),
+			CPULimit:         viper.GetString("run.cpu-limit"),
+			MemLimit:         viper.GetString("run.mem-limit"),
+			Nice:             viper.GetInt("run.nice"),
+			IOClass:          viper.GetString("run.ionice"),
+			ExpiryWarning:    viper.GetInt("run.expiry-warning"),
+			GPUIDsFile:       viper.GetString("run.gpu-ids-file"),
+			AllocationJSON:   viper.GetString("run.allocation-json"),
+			Account:          stringFlagOrDefault(viper.GetViper(), cmd, "account", "default_account"),
+			RequireClean:     viper.GetBool("run.require-clean"),
+			CleanThreshold:   viper.GetInt("run.clean-threshold"),
+			CleanWait:        viper.GetString("run.clean-wait"),
+			HealthCheck:      viper.GetBool("run.health-check"),
+			MinFreeDuration:  viper.GetString("run.min-free-duration"),
+			WorkingDir:       viper.GetString("run.working-dir"),
+			NoStdin:          runClosesStdin(viper.IsSet("run.no-stdin"), viper.GetBool("run.no-stdin"), stdinMode, stdinOK),
+			Env:              viper.GetStringSlice("run.env"),
+			LogDir:           viper.GetString("run.log-dir"),
+			LogKeep:          viper.GetInt("run.log-keep"),
+			GPUClass:         strings.ToLower(strings.TrimSpace(viper.GetString("run.gpu-class"))),
+			OnSuccess:        viper.GetString("run.on-success"),
+			OnFailure:        viper.GetString("run.on-failure"),
+			Timing:           viper.GetBool("run.timing"),
+			CheckpointSignal: viper.GetString("run.checkpoint-signal"),
+			CheckpointGrace:  viper.GetString("run.checkpoint-grace"),
+		}
+		modelHints := viper.GetBool("run.model-hints")
+		loginShell := viper.GetBool("run.login-shell")
+
+		// Take the GPUs from SLURM's allocation instead of the flags
+		if countEnv := viper.GetString("run.count-from-env"); countEnv != "" {
+			if cmd.Flags().Changed("gpus") || cmd.Flags().Changed("gpu-ids") {
+				return fmt.Errorf("--count-from-env cannot be used with --gpus or --gpu-ids")
+			}
+			opts.GPUCount, opts.GPUIDs, opts.InheritedDevices, err = gpuRequestFromEnv(countEnv, os.Getenv)
+			if err != nil {
+				return err
+			}
+			warnIfUnknownSLURMDevices(opts.GPUIDs, opts.InheritedDevices)
+		}
 
 		// Check if "--" separator was used
 		dashIndex := cmd.ArgsLenAtDash()
@@ -100,7 +255,15 @@ and your command begins.`,
 			return err
 		}
 
-		err := runRun(cmd.Context(), gpuCount, gpuIDs, timeoutStr, note, customUser, nonblock, waitStr, args)
+		if modelHints && opts.GPUCount != gpuCountAll {
+			warnIfTooFewGPUsForModel(os.Stderr, args, opts.GPUCount, opts.GPUIDs, modelGPUHints(viper.GetViper()))
+		}
+
+		if loginShell {
+			args = loginShellCommand(userShell(), args)
+		}
+
+		err = runRun(cmd.Context(), opts, args)
 
 		// Handle exit code errors
 		if exitErr, ok := err.(*ExitCodeError); ok {
@@ -113,13 +276,44 @@ and your command begins.`,
 }
 
 func init() {
-	runCmd.Flags().IntP("gpus", "g", 1, "Number of GPUs to reserve")
+	runCmd.Flags().StringP("gpus", "g", "1", "Number of GPUs to reserve, or 'all' for every available GPU")
 	runCmd.Flags().IntSliceP("gpu-ids", "G", nil, "Specific GPU IDs to reserve (comma-separated, e.g., 1,3,5)")
 	runCmd.Flags().StringP("timeout", "t", "", "Timeout duration for graceful command termination (e.g., 30m, 2h, 1d). Disabled by default.")
 	runCmd.Flags().StringP("note", "n", "", "Optional note describing the reservation purpose")
 	runCmd.Flags().StringP("user", "u", "", "Custom user identifier (e.g., your name when using a shared account)")
 	runCmd.Flags().Bool("nonblock", false, "Fail immediately if GPUs are unavailable instead of waiting in queue")
 	runCmd.Flags().StringP("wait", "w", "", "Maximum time to wait for GPUs (e.g., 30m, 2h). Default: wait forever.")
+	runCmd.Flags().String("priority", types.PriorityNormal, "Reservation priority: low, normal, or high")
+	runCmd.Flags().Bool("preempt", false, "Preempt idle lower-priority reservations if GPUs are unavailable")
+	runCmd.Flags().Bool("model-hints", false, "Warn if fewer GPUs are requested than the detected model typically needs")
+	runCmd.Flags().String("cpu-limit", "", "Limit the command to this many CPUs (e.g., 4 or 0.5) using a cgroup")
+	runCmd.Flags().String("mem-limit", "", "Limit the command's memory (e.g., 512M, 32G) using a cgroup")
+	runCmd.Flags().Int("nice", 0, "Run the command at this nice value, from -20 to 19 (e.g., 10 to yield CPU to interactive work)")
+	runCmd.Flags().String("ionice", "", "Run the command in this I/O scheduling class: idle or best-effort (lowest priority)")
+	runCmd.Flags().Int("expiry-warning", 90, "Warn when this percentage of --timeout has elapsed (0 to disable)")
+	runCmd.Flags().String("checkpoint-signal", "", "When --timeout is reached, first send this signal (e.g., USR1) so the command can save a checkpoint")
+	runCmd.Flags().String("checkpoint-grace", defaultCheckpointGrace, "How long to wait after --checkpoint-signal before sending SIGINT")
+	runCmd.Flags().String("gpu-ids-file", "", "Write the allocated GPU IDs as JSON to this file before starting the command (e.g., /dev/fd/3)")
+	runCmd.Flags().String("allocation-json", "", "Write a JSON description of the allocation to stderr, or with --allocation-json=FILE to a file, before starting the command")
+	runCmd.Flags().Lookup("allocation-json").NoOptDefVal = allocationJSONStderr
+	runCmd.Flags().String("account", "", "Team account to bill the usage to (default: your primary group)")
+	runCmd.Flags().Bool("require-clean", false, "Check that the reserved GPUs have no leftover memory in use before starting the command")
+	runCmd.Flags().Int("clean-threshold", 100, "Memory in MB above which a GPU is not considered clean by --require-clean")
+	runCmd.Flags().String("clean-wait", "", "With --require-clean, wait up to this long for the GPUs to become clean (e.g., 30s, 2m)")
+	runCmd.Flags().Bool("health-check", false, "Check the health of the reserved GPUs and replace unhealthy ones before starting the command (NVIDIA only)")
+	runCmd.Flags().String("working-dir", "", "Directory to run the command in (default: the current directory)")
+	runCmd.Flags().Bool("no-stdin", false, "Give the command /dev/null as stdin (default: only when stdin isn't a terminal, pipe, or file; --no-stdin=false keeps it)")
+	runCmd.Flags().String("count-from-env", "", "Reserve the number of GPUs in this environment variable, and the GPUs in SLURM_JOB_GPUS if set, keeping CUDA_VISIBLE_DEVICES (default variable: SLURM_GPUS_ON_NODE)")
+	runCmd.Flags().Lookup("count-from-env").NoOptDefVal = defaultCountEnv
+	runCmd.Flags().StringArray("env", nil, "Set an environment variable for the command, as KEY=VALUE (repeatable)")
+	runCmd.Flags().String("min-free-duration", "", "Fail unless the reservation can't be preempted for at least this long, and skip GPUs next to reservations expiring sooner (e.g., 1h)")
+	runCmd.Flags().String("on-success", "", "Shell command to run after the command exits successfully, before the GPUs are released")
+	runCmd.Flags().String("on-failure", "", "Shell command to run after the command fails, before the GPUs are released")
+	runCmd.Flags().String("gpu-class", "", "Only reserve GPUs of this memory class: small or large, or a class from gpu_classes")
+	runCmd.Flags().String("log-dir", "", "Also write the command's stdout and stderr to <timestamp>-<pid>.out and .err files in this directory")
+	runCmd.Flags().Int("log-keep", 20, "With --log-dir, keep the logs of only this many most recent runs (0 to keep all)")
+	runCmd.Flags().Bool("login-shell", false, "Run the command through a login shell ($SHELL -l -c) so the shell profile is read, e.g. for 'module load'")
+	runCmd.Flags().Bool("timing", false, "Print how long the allocation took, including GPU detection and waiting for the allocation lock")
 
 	// Require explicit -- separator: only parse flags before --, everything after is treated as opaque args
 	runCmd.Flags().SetInterspersed(false)
@@ -127,6 +321,300 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 }
 
+// modelGPUHints returns the built-in model GPU hints merged with any
+// model_gpu_hints entries from the config file, which take precedence
+func modelGPUHints(v *viper.Viper) map[string]int {
+	hints := make(map[string]int, len(gpu.DefaultModelGPUHints))
+	for pattern, count := range gpu.DefaultModelGPUHints {
+		hints[pattern] = count
+	}
+	for pattern, value := range v.GetStringMapString("model_gpu_hints") {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid model_gpu_hints entry %q: %q\n", pattern, value)
+			continue
+		}
+		hints[pattern] = count
+	}
+	return hints
+}
+
+// warnIfTooFewGPUsForModel prints an advisory warning if the command runs a
+// model that typically needs more GPUs than were requested
+func warnIfTooFewGPUsForModel(w io.Writer, command []string, gpuCount int, gpuIDs []int, hints map[string]int) {
+	modelInfo := gpu.DetectModelFromCommand(command)
+	if modelInfo == nil {
+		return
+	}
+
+	requested := gpuCount
+	if len(gpuIDs) > 0 {
+		requested = len(gpuIDs)
+	}
+	if requested == 0 {
+		requested = 1
+	}
+
+	minGPUs, _ := gpu.MinGPUsForModel(modelInfo.Model, hints)
+	if requested >= minGPUs {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Warning: %s typically needs at least %d GPUs, but %d requested. It may run out of memory.\n",
+		modelInfo.Model, minGPUs, requested)
+}
+
+// cleanCheckInterval is how often --require-clean checks GPU memory while
+// waiting for the GPUs to become clean
+const cleanCheckInterval = 5 * time.Second
+
+// waitForCleanGPUs checks that none of the GPUs has more than threshold MB of
+// memory in use, waiting up to wait for them to become clean. The run
+// reservation's heartbeat is kept fresh while waiting, since the supervisor
+// that normally sends it hasn't started yet.
+func waitForCleanGPUs(ctx context.Context, engine *gpu.AllocationEngine, client *redis_client.Client, gpuIDs []int, user string, threshold int, wait time.Duration) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	deadline := time.Now().Add(wait)
+	reported := ""
+	for {
+		usage, err := engine.GetGPUUsage(ctx, gpuIDs)
+		if err != nil {
+			return fmt.Errorf("failed to check GPU memory for --require-clean: %v", err)
+		}
+
+		dirty := dirtyGPUs(usage, gpuIDs, threshold)
+		if len(dirty) == 0 {
+			return nil
+		}
+
+		// Only repeat the report when something changed
+		remaining := time.Until(deadline)
+		if report := formatDirtyGPUs(usage, dirty); report != reported {
+			fmt.Fprintf(os.Stderr, "GPU(s) %v still have memory in use:\n%s", dirty, report)
+			if remaining > 0 {
+				fmt.Fprintf(os.Stderr, "Waiting up to %s for them to become clean...\n", utils.FormatDuration(remaining))
+			}
+			reported = report
+		}
+
+		if remaining <= 0 {
+			return fmt.Errorf("GPU(s) %v are not clean (more than %dMB in use), not starting the command", dirty, threshold)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted while waiting for GPUs to become clean")
+		case <-time.After(cleanCheckInterval):
+		}
+
+		refreshRunHeartbeat(ctx, client, gpuIDs, user)
+	}
+}
+
+// dirtyGPUs returns the GPUs with more than threshold MB of memory in use
+func dirtyGPUs(usage map[int]*types.GPUUsage, gpuIDs []int, threshold int) []int {
+	var dirty []int
+	for _, gpuID := range gpuIDs {
+		if gpuUsage, ok := usage[gpuID]; ok && gpuUsage.MemoryMB > threshold {
+			dirty = append(dirty, gpuID)
+		}
+	}
+	return dirty
+}
+
+// formatDirtyGPUs describes the memory and processes found on each GPU, one
+// line per GPU
+func formatDirtyGPUs(usage map[int]*types.GPUUsage, dirty []int) string {
+	var b strings.Builder
+	for _, gpuID := range dirty {
+		gpuUsage := usage[gpuID]
+		fmt.Fprintf(&b, "  GPU %d: %dMB used", gpuID, gpuUsage.MemoryMB)
+		if len(gpuUsage.Processes) == 0 {
+			b.WriteString(", no processes found (memory may still be being freed)")
+		}
+		for i, process := range gpuUsage.Processes {
+			sep := ", "
+			if i == 0 {
+				sep = " by "
+			}
+			fmt.Fprintf(&b, "%sPID %d (%s, %s)", sep, process.PID, process.ProcessName, process.User)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// refreshRunHeartbeat updates the heartbeat of the user's run reservations on
+// the GPUs so they aren't reclaimed as stale
+func refreshRunHeartbeat(ctx context.Context, client *redis_client.Client, gpuIDs []int, user string) {
+	now := types.FlexibleTime{Time: time.Now()}
+	for _, gpuID := range gpuIDs {
+		state, err := client.GetGPUState(ctx, gpuID)
+		if err != nil || state.User != user || state.Type != types.ReservationTypeRun {
+			continue
+		}
+		state.LastHeartbeat = now
+		if err := client.SetGPUState(ctx, gpuID, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update heartbeat for GPU %d: %v\n", gpuID, err)
+		}
+	}
+}
+
+// allocationJSONStderr is the --allocation-json destination used when the
+// flag is given without a file
+const allocationJSONStderr = "stderr"
+
+// RunAllocationJSON describes the GPUs allocated by 'canhazgpu run', as
+// written to --gpu-ids-file and --allocation-json
+type RunAllocationJSON struct {
+	GPUIDs             []int   `json:"gpu_ids"`
+	CUDAVisibleDevices string  `json:"cuda_visible_devices"`
+	User               string  `json:"user"`
+	PID                int     `json:"pid"`                       // PID of the command (canhazgpu execs into it)
+	TimeoutSeconds     float64 `json:"timeout_seconds,omitempty"` // --timeout, if set
+	Note               string  `json:"note,omitempty"`
+	Account            string  `json:"account,omitempty"`
+}
+
+// newRunAllocationJSON describes an allocation of gpuIDs to user
+func newRunAllocationJSON(gpuIDs []int, user string, pid int) RunAllocationJSON {
+	parts := make([]string, len(gpuIDs))
+	for i, gpuID := range gpuIDs {
+		parts[i] = strconv.Itoa(gpuID)
+	}
+
+	return RunAllocationJSON{
+		GPUIDs:             gpuIDs,
+		CUDAVisibleDevices: strings.Join(parts, ","),
+		User:               user,
+		PID:                pid,
+	}
+}
+
+// writeGPUIDsFile writes the allocation as one line of JSON to path, which
+// may be a regular file or a file descriptor such as /dev/fd/3
+func writeGPUIDsFile(path string, allocation RunAllocationJSON) error {
+	data, err := json.Marshal(allocation)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// writeAllocationJSON writes the allocation as one line of JSON to w when
+// dest is allocationJSONStderr, or to the file dest otherwise
+func writeAllocationJSON(w io.Writer, dest string, allocation RunAllocationJSON) error {
+	if dest != allocationJSONStderr {
+		return writeGPUIDsFile(dest, allocation)
+	}
+
+	data, err := json.Marshal(allocation)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// checkWorkingDir checks that the --working-dir of a run exists and is a
+// directory, before any GPUs are reserved for the command
+func checkWorkingDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("invalid working directory: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid working directory: %s is not a directory", dir)
+	}
+	return nil
+}
+
+// validateEnvVars checks that each --env value has the form KEY=VALUE
+func validateEnvVars(envVars []string) error {
+	for _, envVar := range envVars {
+		key, _, found := strings.Cut(envVar, "=")
+		if !found || key == "" {
+			return fmt.Errorf("invalid --env value '%s': must be KEY=VALUE", envVar)
+		}
+	}
+	return nil
+}
+
+// runCommandEnv returns the environment for the command: base, overridden by
+// the --env values (the last one wins if a key is repeated), with
+// CUDA_VISIBLE_DEVICES always set to the reserved GPUs
+func runCommandEnv(base []string, envVars []string, devices string) []string {
+	lastOverride := make(map[string]int)
+	for i, envVar := range envVars {
+		key, _, _ := strings.Cut(envVar, "=")
+		lastOverride[key] = i
+	}
+
+	var env []string
+	for _, e := range base {
+		key, _, _ := strings.Cut(e, "=")
+		if _, overridden := lastOverride[key]; key != "CUDA_VISIBLE_DEVICES" && !overridden {
+			env = append(env, e)
+		}
+	}
+	for i, envVar := range envVars {
+		key, _, _ := strings.Cut(envVar, "=")
+		if lastOverride[key] != i {
+			continue
+		}
+		if key == "CUDA_VISIBLE_DEVICES" {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring --env %s, CUDA_VISIBLE_DEVICES is set to the reserved GPUs\n", envVar)
+			continue
+		}
+		env = append(env, envVar)
+	}
+	return append(env, "CUDA_VISIBLE_DEVICES="+devices)
+}
+
+// redactedValue replaces the value of a secret flag in a stored command line
+const redactedValue = "***"
+
+// redactCommand returns the command line stored with a run reservation, with
+// arguments quoted as needed and the values of the secret flags, given as
+// "--token abc" or "--token=abc", replaced by redactedValue. Flags are matched
+// case-insensitively.
+func redactCommand(command []string, secretFlags []string) string {
+	secret := make(map[string]bool, len(secretFlags))
+	for _, flag := range secretFlags {
+		secret[strings.ToLower(flag)] = true
+	}
+
+	args := make([]string, len(command))
+	redactNext := false
+	for i, arg := range command {
+		flag, _, hasValue := strings.Cut(arg, "=")
+		switch {
+		case redactNext:
+			args[i] = redactedValue
+			redactNext = false
+		case secret[strings.ToLower(arg)]:
+			args[i] = quoteCommandArg(arg)
+			redactNext = true
+		case hasValue && secret[strings.ToLower(flag)]:
+			args[i] = quoteCommandArg(flag) + "=" + redactedValue
+		default:
+			args[i] = quoteCommandArg(arg)
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// quoteCommandArg shell-quotes a command line argument if it is empty or has
+// characters the shell would interpret
+func quoteCommandArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?;&|<>()[]{}!#~") {
+		return utils.ShellQuote(arg)
+	}
+	return arg
+}
+
 // validateRunCommand validates that a command was provided with required "--" separator
 func validateRunCommand(args []string, dashIndex int) error {
 	// Case 1: No arguments at all
@@ -144,33 +632,132 @@ func validateRunCommand(args []string, dashIndex int) error {
 	return nil
 }
 
-func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string, note string, customUser string, nonblock bool, waitStr string, command []string) error {
+// runOptions are the settings of a 'run', taken from its flags. 'shell' runs
+// the user's shell with a subset of them.
+type runOptions struct {
+	GPUCount         int
+	GPUIDs           []int
+	Timeout          string // Empty for no timeout
+	Note             string
+	User             string // Display user, empty for the OS user
+	Nonblock         bool
+	Wait             string // How long to wait in the queue, empty to wait forever
+	Priority         string
+	Preempt          bool
+	CPULimit         string
+	MemLimit         string
+	Nice             int
+	IOClass          string
+	ExpiryWarning    int
+	GPUIDsFile       string
+	AllocationJSON   string
+	Account          string
+	RequireClean     bool
+	CleanThreshold   int
+	CleanWait        string
+	HealthCheck      bool
+	MinFreeDuration  string
+	WorkingDir       string
+	NoStdin          bool
+	Env              []string
+	InheritedDevices string // CUDA_VISIBLE_DEVICES set by SLURM, kept under --count-from-env
+	LogDir           string
+	LogKeep          int
+	GPUClass         string
+	OnSuccess        string
+	OnFailure        string
+	Timing           bool
+	CheckpointSignal string
+	CheckpointGrace  string
+}
+
+func runRun(ctx context.Context, opts runOptions, command []string) error {
 	// Cobra has already processed the "--" separator and given us just the command args
 
 	// If neither is specified, default to 1 GPU
-	if gpuCount == 0 && len(gpuIDs) == 0 {
-		gpuCount = 1
+	if opts.GPUCount == 0 && len(opts.GPUIDs) == 0 {
+		opts.GPUCount = 1
 	}
 
 	config := getConfig()
 
 	// Validate timeout format early (before allocating GPUs)
-	if timeoutStr != "" {
-		if _, err := utils.ParseDuration(timeoutStr); err != nil {
+	var runTimeout time.Duration
+	if opts.Timeout != "" {
+		t, err := utils.ParseDuration(opts.Timeout)
+		if err != nil {
 			return fmt.Errorf("invalid timeout format: %v", err)
 		}
+		runTimeout = t
+	}
+	checkpointSig, _, err := parseCheckpointOptions(opts.CheckpointSignal, opts.CheckpointGrace, runTimeout)
+	if err != nil {
+		return err
+	}
+	if opts.ExpiryWarning < 0 || opts.ExpiryWarning >= 100 {
+		return fmt.Errorf("invalid expiry warning: must be a percentage from 0 to 99, got %d", opts.ExpiryWarning)
 	}
 
 	// Parse wait timeout if provided
 	var waitTimeout *time.Duration
-	if waitStr != "" {
-		wt, err := utils.ParseDuration(waitStr)
+	if opts.Wait != "" {
+		wt, err := utils.ParseDuration(opts.Wait)
 		if err != nil {
 			return fmt.Errorf("invalid wait timeout format: %v", err)
 		}
 		waitTimeout = &wt
 	}
 
+	if err := types.ValidatePriority(opts.Priority); err != nil {
+		return err
+	}
+	minFree, err := checkMinFreeDuration(opts.MinFreeDuration, runTimeout, opts.Priority)
+	if err != nil {
+		return err
+	}
+	if err := checkGPUClass(config.GPUClasses, opts.GPUClass, opts.GPUCount, opts.GPUIDs); err != nil {
+		return err
+	}
+
+	// Validate the command's directory and environment before allocating GPUs
+	if opts.WorkingDir != "" {
+		if err := checkWorkingDir(opts.WorkingDir); err != nil {
+			return err
+		}
+	}
+	if err := validateEnvVars(opts.Env); err != nil {
+		return err
+	}
+	if opts.LogDir != "" {
+		dir, err := prepareLogDir(opts.LogDir)
+		if err != nil {
+			return err
+		}
+		opts.LogDir = dir
+	}
+
+	limits, err := parseResourceLimits(opts.CPULimit, opts.MemLimit)
+	if err != nil {
+		return err
+	}
+	schedPriority, err := parseSchedulingPriority(opts.Nice, opts.IOClass)
+	if err != nil {
+		return err
+	}
+
+	var cleanWait time.Duration
+	if opts.RequireClean {
+		if opts.CleanThreshold < 0 {
+			return fmt.Errorf("invalid clean threshold: must be 0 or more MB, got %d", opts.CleanThreshold)
+		}
+		if opts.CleanWait != "" {
+			cleanWait, err = utils.ParseDuration(opts.CleanWait)
+			if err != nil {
+				return fmt.Errorf("invalid clean wait format: %v", err)
+			}
+		}
+	}
+
 	client := redis_client.NewClient(config)
 	// Note: We don't defer close here because we'll exec() and the process will be replaced
 
@@ -186,22 +773,30 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 	// Get actual OS user and determine display user
 	actualUser := getCurrentUser()
 	displayUser := actualUser
-	if customUser != "" {
-		displayUser = customUser
+	if opts.User != "" {
+		displayUser = opts.User
 	}
 
 	// Create allocation request
 	request := &gpu.QueuedAllocationRequest{
 		AllocationRequest: &types.AllocationRequest{
-			GPUCount:        gpuCount,
-			GPUIDs:          gpuIDs,
+			GPUCount:        max(opts.GPUCount, 0),
+			AllAvailable:    opts.GPUCount == gpuCountAll,
+			GPUIDs:          opts.GPUIDs,
 			User:            displayUser,
 			ActualUser:      actualUser,
 			ReservationType: types.ReservationTypeRun,
 			ExpiryTime:      nil, // No expiry for run-type reservations
-			Note:            note,
+			Note:            opts.Note,
+			Source:          types.ReservationSourceRun,
+			Priority:        opts.Priority,
+			Preempt:         opts.Preempt,
+			Account:         resolveAccount(opts.Account),
+			GPUClass:        opts.GPUClass,
+			Command:         redactCommand(command, append(types.DefaultCommandRedactFlags(), config.CommandRedactFlags...)),
+			MinFreeDuration: minFree,
 		},
-		Blocking:    !nonblock,
+		Blocking:    !opts.Nonblock,
 		WaitTimeout: waitTimeout,
 	}
 
@@ -214,15 +809,41 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 	allocatedGPUs := result.AllocatedGPUs
 
 	// Verify we got the requested number of GPUs
-	expectedCount := gpuCount
-	if len(gpuIDs) > 0 {
-		expectedCount = len(gpuIDs)
+	expectedCount := opts.GPUCount
+	if len(opts.GPUIDs) > 0 {
+		expectedCount = len(opts.GPUIDs)
+	} else if opts.GPUCount == gpuCountAll {
+		expectedCount = len(allocatedGPUs)
 	}
 	if len(allocatedGPUs) != expectedCount {
 		_ = client.Close()
 		return fmt.Errorf("failed to allocate requested GPUs: requested %d, got %d", expectedCount, len(allocatedGPUs))
 	}
 
+	// Swap out GPUs that are allocated but broken, e.g. by ECC errors
+	if opts.HealthCheck {
+		replacementRequest := *request.AllocationRequest
+		replacementRequest.GPUIDs = nil
+		replacementRequest.AllAvailable = false
+		allocatedGPUs, err = replaceUnhealthyGPUs(os.Stderr, allocatedGPUs, len(opts.GPUIDs) > 0, opts.GPUCount == gpuCountAll, gpuHealthOps{
+			check: func(ids []int) (map[int]string, error) {
+				return engine.CheckGPUHealth(ctx, ids)
+			},
+			allocate: func(count int) ([]int, error) {
+				replacementRequest.GPUCount = count
+				return engine.AllocateGPUs(ctx, &replacementRequest)
+			},
+			release: func(ids []int) error {
+				_, err := engine.ReleaseSpecificGPUs(context.Background(), displayUser, ids)
+				return err
+			},
+		})
+		if err != nil {
+			_ = client.Close()
+			return err
+		}
+	}
+
 	// Sort GPU IDs for consistent ordering in output and environment variable
 	sort.Ints(allocatedGPUs)
 
@@ -234,18 +855,71 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 	gpuListStr := strings.Join(gpuListParts, ",")
 
 	// Print reservation info
-	if timeoutStr != "" {
-		timeout, _ := utils.ParseDuration(timeoutStr)
-		fmt.Printf("Reserved %d GPU(s): %v for command execution (timeout: %s)\n",
-			len(allocatedGPUs), allocatedGPUs, utils.FormatDuration(timeout))
+	if opts.Timeout != "" {
+		timeout, _ := utils.ParseDuration(opts.Timeout)
+		fmt.Printf("Reserved %s: %v for command execution (timeout: %s)\n",
+			reservedGPUsLabel(len(allocatedGPUs), opts.GPUCount == gpuCountAll), allocatedGPUs, utils.FormatDuration(timeout))
 	} else {
-		fmt.Printf("Reserved %d GPU(s): %v for command execution\n",
-			len(allocatedGPUs), allocatedGPUs)
+		fmt.Printf("Reserved %s: %v for command execution\n",
+			reservedGPUsLabel(len(allocatedGPUs), opts.GPUCount == gpuCountAll), allocatedGPUs)
+	}
+	if opts.Timing {
+		fmt.Println(formatAllocationTiming(result.Timing))
+	}
+
+	// Don't start the command on GPUs a previous job hasn't let go of yet
+	if opts.RequireClean {
+		if err := waitForCleanGPUs(ctx, engine, client, allocatedGPUs, displayUser, opts.CleanThreshold, cleanWait); err != nil {
+			if _, releaseErr := engine.ReleaseSpecificGPUs(context.Background(), displayUser, allocatedGPUs); releaseErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to release GPUs: %v\n", releaseErr)
+			}
+			_ = client.Close()
+			return err
+		}
+	}
+
+	// Tell wrappers which GPUs were allocated. They rely on this, so give the
+	// GPUs back rather than run the command if it can't be written.
+	allocation := newRunAllocationJSON(allocatedGPUs, displayUser, os.Getpid())
+	if opts.Timeout != "" {
+		timeout, _ := utils.ParseDuration(opts.Timeout)
+		allocation.TimeoutSeconds = timeout.Seconds()
+	}
+	allocation.Note = opts.Note
+	allocation.Account = request.Account
+	var writeErr error
+	if opts.GPUIDsFile != "" {
+		if err := writeGPUIDsFile(opts.GPUIDsFile, allocation); err != nil {
+			writeErr = fmt.Errorf("failed to write GPU IDs file: %v", err)
+		}
+	}
+	if opts.AllocationJSON != "" && writeErr == nil {
+		if err := writeAllocationJSON(os.Stderr, opts.AllocationJSON, allocation); err != nil {
+			writeErr = fmt.Errorf("failed to write allocation JSON: %v", err)
+		}
+	}
+	if writeErr != nil {
+		if _, releaseErr := engine.ReleaseSpecificGPUs(ctx, displayUser, allocatedGPUs); releaseErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to release GPUs: %v\n", releaseErr)
+		}
+		_ = client.Close()
+		return writeErr
 	}
 
 	// Close Redis client before spawning supervisor (supervisor will create its own)
 	_ = client.Close()
 
+	// Create the cgroup for resource limits now, but only join it after the
+	// supervisor has started so the supervisor isn't limited too. Limits are
+	// best effort: without permission to manage cgroups, run without them.
+	cgroupPath := ""
+	if limits.isSet() {
+		cgroupPath, err = createRunCgroup(limits, os.Getpid())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: resource limits not applied: %v\n", err)
+		}
+	}
+
 	// Get our own executable path for spawning supervisor
 	executable, err := os.Executable()
 	if err != nil {
@@ -253,16 +927,16 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 	}
 
 	// Build supervisor command arguments
-	supervisorArgs := []string{
-		executable,
-		"supervisor",
-		"--gpus", gpuListStr,
-		"--user", displayUser,
-		"--pid", strconv.Itoa(os.Getpid()),
-	}
-	if timeoutStr != "" {
-		supervisorArgs = append(supervisorArgs, "--timeout", timeoutStr)
-	}
+	supervisorArgs := buildSupervisorArgs(executable, config, supervisorOptions{
+		GPUs:             gpuListStr,
+		User:             displayUser,
+		PID:              os.Getpid(),
+		Timeout:          opts.Timeout,
+		ExpiryWarning:    opts.ExpiryWarning,
+		CheckpointSignal: checkpointSig,
+		CheckpointGrace:  opts.CheckpointGrace,
+		Cgroup:           cgroupPath,
+	})
 
 	// Start supervisor process (detached, will monitor us)
 	supervisorCmd := exec.Command(supervisorArgs[0], supervisorArgs[1:]...)
@@ -276,12 +950,40 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 	}
 
 	if err := supervisorCmd.Start(); err != nil {
+		if cgroupPath != "" {
+			removeCgroup(cgroupPath)
+		}
 		return fmt.Errorf("failed to start supervisor: %v", err)
 	}
 
+	// Move ourselves into the cgroup; the command we exec stays in it
+	if cgroupPath != "" {
+		if err := joinCgroup(cgroupPath, os.Getpid()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: resource limits not applied: failed to join cgroup: %v\n", err)
+		}
+	}
+
+	// Lower our priority only now that the supervisor has started, so the
+	// supervisor keeps running at normal priority
+	if schedPriority.isSet() {
+		if err := applySchedulingPriority(schedPriority); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: scheduling priority not applied: %v\n", err)
+		}
+	}
+
 	// Give supervisor a moment to initialize
 	time.Sleep(50 * time.Millisecond)
 
+	// Change directory first so relative command paths resolve from there
+	if opts.WorkingDir != "" {
+		if err := os.Chdir(opts.WorkingDir); err != nil {
+			if supervisorCmd.Process != nil {
+				_ = supervisorCmd.Process.Kill()
+			}
+			return fmt.Errorf("failed to change to working directory: %v", err)
+		}
+	}
+
 	// Find the binary to exec
 	binary, err := exec.LookPath(command[0])
 	if err != nil {
@@ -292,14 +994,42 @@ func runRun(ctx context.Context, gpuCount int, gpuIDs []int, timeoutStr string,
 		return fmt.Errorf("command not found: %s", command[0])
 	}
 
-	// Set up environment with CUDA_VISIBLE_DEVICES, replacing any existing value
-	var env []string
-	for _, e := range os.Environ() {
-		if !strings.HasPrefix(e, "CUDA_VISIBLE_DEVICES=") {
-			env = append(env, e)
+	// Under --count-from-env, keep the devices SLURM made visible to the job
+	devices := visibleDevices(config, allocatedGPUs)
+	if opts.InheritedDevices != "" {
+		devices = opts.InheritedDevices
+	}
+	env := runCommandEnv(os.Environ(), opts.Env, devices)
+
+	// Tee the command's output to log files; our PID stays the command's PID
+	if opts.LogDir != "" {
+		if err := rotateRunLogs(opts.LogDir, opts.LogKeep); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove old logs: %v\n", err)
+		}
+		outPath, errPath := runLogPaths(opts.LogDir, time.Now(), os.Getpid())
+		if err := startRunLogs(executable, outPath, errPath); err != nil {
+			if supervisorCmd.Process != nil {
+				_ = supervisorCmd.Process.Kill()
+			}
+			return err
+		}
+	}
+
+	if opts.NoStdin {
+		if err := redirectStdinToDevNull(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: stdin not closed: %v\n", err)
+		}
+	}
+
+	// With hooks, wait for the command instead of becoming it, so the hook can
+	// run while the GPUs are still reserved
+	if opts.OnSuccess != "" || opts.OnFailure != "" {
+		err := runWithHooks(binary, command, env, allocatedGPUs, opts.OnSuccess, opts.OnFailure)
+		if _, ok := err.(*ExitCodeError); !ok && err != nil && supervisorCmd.Process != nil {
+			_ = supervisorCmd.Process.Kill()
 		}
+		return err
 	}
-	env = append(env, fmt.Sprintf("CUDA_VISIBLE_DEVICES=%s", gpuListStr))
 
 	// Exec the user's command - this replaces the current process
 	// The supervisor will continue running and monitor our PID
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/utils"
+)
+
+// defaultCheckpointGrace is how long a command is given to save a checkpoint
+// after --checkpoint-signal before it's sent SIGINT
+const defaultCheckpointGrace = "5m"
+
+// checkpointSignals are the signals --checkpoint-signal accepts, by name.
+// SIGKILL and SIGSTOP can't be caught, so they can't ask for a checkpoint.
+var checkpointSignals = map[string]syscall.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGALRM":  syscall.SIGALRM,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGCONT":  syscall.SIGCONT,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// parseCheckpointSignal parses a --checkpoint-signal value: a signal name,
+// with or without the SIG prefix and in any case (USR1, SIGUSR1), or its
+// number
+func parseCheckpointSignal(value string) (syscall.Signal, error) {
+	name := strings.ToUpper(strings.TrimSpace(value))
+	if n, err := strconv.Atoi(name); err == nil {
+		for _, sig := range checkpointSignals {
+			if int(sig) == n {
+				return sig, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid checkpoint signal %q: signal %d is not supported", value, n)
+	}
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig, ok := checkpointSignals[name]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid checkpoint signal %q: must be one of HUP, INT, QUIT, USR1, USR2, ALRM, TERM, CONT, or WINCH", value)
+}
+
+// parseCheckpointOptions validates --checkpoint-signal and --checkpoint-grace.
+// A checkpoint signal is only sent when --timeout fires, so it needs one. It
+// returns 0 when no checkpoint signal was asked for.
+func parseCheckpointOptions(signalStr, graceStr string, timeout time.Duration) (syscall.Signal, time.Duration, error) {
+	if signalStr == "" {
+		return 0, 0, nil
+	}
+	if timeout <= 0 {
+		return 0, 0, fmt.Errorf("--checkpoint-signal requires --timeout")
+	}
+
+	sig, err := parseCheckpointSignal(signalStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if graceStr == "" {
+		graceStr = defaultCheckpointGrace
+	}
+	grace, err := utils.ParseDuration(graceStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid checkpoint grace format: %v", err)
+	}
+	if grace <= 0 {
+		return 0, 0, fmt.Errorf("invalid checkpoint grace: must be greater than 0")
+	}
+	return sig, grace, nil
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCheckpointSignal(t *testing.T) {
+	for _, value := range []string{"USR1", "SIGUSR1", "usr1", " sigusr1 ", "10"} {
+		sig, err := parseCheckpointSignal(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, syscall.SIGUSR1, sig, value)
+	}
+
+	sig, err := parseCheckpointSignal("TERM")
+	require.NoError(t, err)
+	assert.Equal(t, syscall.SIGTERM, sig)
+
+	for _, value := range []string{"KILL", "SIGSTOP", "9", "bogus", ""} {
+		_, err := parseCheckpointSignal(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseCheckpointOptions(t *testing.T) {
+	sig, grace, err := parseCheckpointOptions("", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, syscall.Signal(0), sig)
+	assert.Zero(t, grace)
+
+	sig, grace, err = parseCheckpointOptions("USR1", "10m", 2*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, syscall.SIGUSR1, sig)
+	assert.Equal(t, 10*time.Minute, grace)
+
+	_, grace, err = parseCheckpointOptions("USR2", "", 2*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, grace)
+
+	_, _, err = parseCheckpointOptions("USR1", "10m", 0)
+	assert.EqualError(t, err, "--checkpoint-signal requires --timeout")
+
+	_, _, err = parseCheckpointOptions("USR1", "soon", time.Hour)
+	assert.Error(t, err)
+	_, _, err = parseCheckpointOptions("USR1", "0m", time.Hour)
+	assert.Error(t, err)
+}
+
+func TestSignalName(t *testing.T) {
+	assert.Equal(t, "SIGINT", signalName(syscall.SIGINT))
+	assert.Equal(t, "SIGUSR1", signalName(syscall.SIGUSR1))
+	assert.Equal(t, "SIGKILL", signalName(syscall.SIGKILL))
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+)
+
+// gpuHealthOps are the operations replaceUnhealthyGPUs needs from the
+// allocation engine
+type gpuHealthOps struct {
+	// check returns what is wrong with each unhealthy GPU of gpuIDs
+	check func(gpuIDs []int) (map[int]string, error)
+	// allocate reserves count more GPUs for the run, without waiting
+	allocate func(count int) ([]int, error)
+	// release gives the GPUs back
+	release func(gpuIDs []int) error
+}
+
+// replaceUnhealthyGPUs checks the health of the freshly allocated GPUs for
+// 'run --health-check' and returns the GPUs to run on. Unhealthy GPUs are
+// kept until replacements have been allocated, so the same GPUs can't be
+// handed out again, and are then released. With specific GPU IDs there is
+// nothing to replace them with, so an unhealthy GPU is an error; with
+// --gpus all the unhealthy GPUs are just dropped. On error, every GPU held
+// is released.
+func replaceUnhealthyGPUs(w io.Writer, allocated []int, specificIDs bool, allAvailable bool, ops gpuHealthOps) ([]int, error) {
+	healthy, bad, err := splitUnhealthyGPUs(w, allocated, ops.check)
+	if errors.Is(err, gpu.ErrHealthCheckUnsupported) {
+		fmt.Fprintf(w, "Warning: skipping --health-check: %v\n", err)
+		return allocated, nil
+	}
+	if err != nil {
+		return nil, releaseAfterHealthCheck(w, allocated, ops, fmt.Errorf("failed to check GPU health: %v", err))
+	}
+	if len(bad) == 0 {
+		return allocated, nil
+	}
+
+	if specificIDs {
+		return nil, releaseAfterHealthCheck(w, allocated, ops,
+			fmt.Errorf("requested GPU(s) %v failed the health check, not starting the command", bad))
+	}
+
+	if !allAvailable {
+		// Replacements can turn out to be unhealthy too
+		for missing := len(bad); missing > 0; {
+			replacements, err := ops.allocate(missing)
+			if err != nil {
+				return nil, releaseAfterHealthCheck(w, slices.Concat(healthy, bad), ops,
+					fmt.Errorf("failed to replace unhealthy GPU(s) %v: %v", bad, err))
+			}
+			fmt.Fprintf(w, "Allocated replacement GPU(s) %v\n", replacements)
+
+			moreHealthy, moreBad, err := splitUnhealthyGPUs(w, replacements, ops.check)
+			if err != nil {
+				return nil, releaseAfterHealthCheck(w, slices.Concat(healthy, bad, replacements), ops, fmt.Errorf("failed to check GPU health: %v", err))
+			}
+			healthy = append(healthy, moreHealthy...)
+			bad = append(bad, moreBad...)
+			missing = len(moreBad)
+		}
+	} else if len(healthy) == 0 {
+		return nil, releaseAfterHealthCheck(w, bad, ops, fmt.Errorf("none of the available GPUs passed the health check"))
+	}
+
+	sort.Ints(bad)
+	if err := ops.release(bad); err != nil {
+		fmt.Fprintf(w, "Warning: failed to release unhealthy GPU(s) %v: %v\n", bad, err)
+	} else {
+		fmt.Fprintf(w, "Released unhealthy GPU(s) %v\n", bad)
+	}
+	return healthy, nil
+}
+
+// splitUnhealthyGPUs checks the GPUs and reports the unhealthy ones
+func splitUnhealthyGPUs(w io.Writer, gpuIDs []int, check func([]int) (map[int]string, error)) (healthy, bad []int, err error) {
+	unhealthy, err := check(gpuIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, gpuID := range gpuIDs {
+		if problem, ok := unhealthy[gpuID]; ok {
+			fmt.Fprintf(w, "GPU %d failed the health check: %s\n", gpuID, problem)
+			bad = append(bad, gpuID)
+		} else {
+			healthy = append(healthy, gpuID)
+		}
+	}
+	return healthy, bad, nil
+}
+
+// releaseAfterHealthCheck releases the GPUs held by a run that won't start,
+// and returns err
+func releaseAfterHealthCheck(w io.Writer, gpuIDs []int, ops gpuHealthOps, err error) error {
+	gpuIDs = slices.Clone(gpuIDs)
+	sort.Ints(gpuIDs)
+	if releaseErr := ops.release(gpuIDs); releaseErr != nil {
+		fmt.Fprintf(w, "Warning: failed to release GPUs: %v\n", releaseErr)
+	}
+	return err
+}
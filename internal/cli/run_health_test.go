@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGPUPool stands in for the allocation engine in replaceUnhealthyGPUs
+// tests. GPUs are allocated from free in order.
+type fakeGPUPool struct {
+	free      []int
+	unhealthy map[int]string
+	checkErr  error
+	released  [][]int
+}
+
+func (p *fakeGPUPool) ops() gpuHealthOps {
+	return gpuHealthOps{
+		check: func(gpuIDs []int) (map[int]string, error) {
+			if p.checkErr != nil {
+				return nil, p.checkErr
+			}
+			result := make(map[int]string)
+			for _, gpuID := range gpuIDs {
+				if problem, ok := p.unhealthy[gpuID]; ok {
+					result[gpuID] = problem
+				}
+			}
+			return result, nil
+		},
+		allocate: func(count int) ([]int, error) {
+			if count > len(p.free) {
+				return nil, errors.New("not enough GPUs available")
+			}
+			allocated := p.free[:count]
+			p.free = p.free[count:]
+			return allocated, nil
+		},
+		release: func(gpuIDs []int) error {
+			p.released = append(p.released, gpuIDs)
+			return nil
+		},
+	}
+}
+
+func TestReplaceUnhealthyGPUs(t *testing.T) {
+	t.Run("all healthy", func(t *testing.T) {
+		pool := &fakeGPUPool{free: []int{4}}
+		var buf bytes.Buffer
+		gpus, err := replaceUnhealthyGPUs(&buf, []int{0, 1}, false, false, pool.ops())
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, gpus)
+		assert.Empty(t, pool.released)
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		// The first replacement is unhealthy too
+		pool := &fakeGPUPool{
+			free:      []int{5, 6},
+			unhealthy: map[int]string{1: "2 uncorrected ECC error(s)", 5: "not reported by the GPU provider"},
+		}
+		var buf bytes.Buffer
+		gpus, err := replaceUnhealthyGPUs(&buf, []int{0, 1, 2}, false, false, pool.ops())
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 2, 6}, gpus)
+		assert.Equal(t, [][]int{{1, 5}}, pool.released)
+		assert.Equal(t, "GPU 1 failed the health check: 2 uncorrected ECC error(s)\n"+
+			"Allocated replacement GPU(s) [5]\n"+
+			"GPU 5 failed the health check: not reported by the GPU provider\n"+
+			"Allocated replacement GPU(s) [6]\n"+
+			"Released unhealthy GPU(s) [1 5]\n", buf.String())
+	})
+
+	t.Run("no replacement available", func(t *testing.T) {
+		pool := &fakeGPUPool{unhealthy: map[int]string{1: "memory page retirement pending"}}
+		var buf bytes.Buffer
+		_, err := replaceUnhealthyGPUs(&buf, []int{1, 0}, false, false, pool.ops())
+		assert.ErrorContains(t, err, "failed to replace unhealthy GPU(s) [1]: not enough GPUs available")
+		assert.Equal(t, [][]int{{0, 1}}, pool.released)
+	})
+
+	t.Run("specific GPU IDs", func(t *testing.T) {
+		pool := &fakeGPUPool{free: []int{4}, unhealthy: map[int]string{3: "memory page retirement pending"}}
+		var buf bytes.Buffer
+		_, err := replaceUnhealthyGPUs(&buf, []int{1, 3}, true, false, pool.ops())
+		assert.ErrorContains(t, err, "requested GPU(s) [3] failed the health check")
+		assert.Equal(t, [][]int{{1, 3}}, pool.released)
+	})
+
+	t.Run("all available", func(t *testing.T) {
+		pool := &fakeGPUPool{unhealthy: map[int]string{2: "1 uncorrected ECC error(s)"}}
+		var buf bytes.Buffer
+		gpus, err := replaceUnhealthyGPUs(&buf, []int{0, 1, 2, 3}, false, true, pool.ops())
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 3}, gpus)
+		assert.Equal(t, [][]int{{2}}, pool.released)
+
+		pool = &fakeGPUPool{unhealthy: map[int]string{0: "1 uncorrected ECC error(s)"}}
+		_, err = replaceUnhealthyGPUs(&buf, []int{0}, false, true, pool.ops())
+		assert.ErrorContains(t, err, "none of the available GPUs passed the health check")
+		assert.Equal(t, [][]int{{0}}, pool.released)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		pool := &fakeGPUPool{checkErr: gpu.ErrHealthCheckUnsupported}
+		var buf bytes.Buffer
+		gpus, err := replaceUnhealthyGPUs(&buf, []int{0, 1}, false, false, pool.ops())
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1}, gpus)
+		assert.Contains(t, buf.String(), "Warning: skipping --health-check")
+	})
+
+	t.Run("check failed", func(t *testing.T) {
+		pool := &fakeGPUPool{checkErr: errors.New("nvidia-smi failed")}
+		var buf bytes.Buffer
+		_, err := replaceUnhealthyGPUs(&buf, []int{0, 1}, false, false, pool.ops())
+		assert.ErrorContains(t, err, "failed to check GPU health: nvidia-smi failed")
+		assert.Equal(t, [][]int{{0, 1}}, pool.released)
+	})
+}
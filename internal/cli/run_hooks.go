@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// runWithHooks runs the command as a child process instead of exec'ing it, so
+// that once it exits the --on-success or --on-failure hook can run. Our PID is
+// the one the supervisor watches, so the GPUs stay reserved until the hook has
+// finished. Returns an ExitCodeError carrying the command's exit status if it
+// failed.
+func runWithHooks(binary string, command []string, env []string, gpuIDs []int, onSuccess, onFailure string) error {
+	cmd := exec.Command(binary, command[1:]...)
+	cmd.Args = command
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Catch signals before starting the command so that none can kill us and
+	// skip the hook
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %v", err)
+	}
+
+	// Pass signals on to the command until it exits
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigChan:
+				if shouldForwardSignal(sig.(syscall.Signal), syscall.Getpgrp() == os.Getpid()) {
+					_ = cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	waitErr := cmd.Wait()
+	close(done)
+
+	exitCode, err := commandExitCode(waitErr)
+	if err != nil {
+		return fmt.Errorf("failed to wait for command: %v", err)
+	}
+
+	hook := onSuccess
+	if exitCode != 0 {
+		hook = onFailure
+	}
+	if hook != "" {
+		runHook(hook, hookEnv(env, exitCode, gpuIDs))
+	}
+
+	if exitCode != 0 {
+		return &ExitCodeError{Code: exitCode, Message: fmt.Sprintf("command exited with status %d", exitCode)}
+	}
+	return nil
+}
+
+// shouldForwardSignal reports whether a signal we received should be passed on
+// to the command. The terminal sends SIGINT and SIGHUP to the whole foreground
+// process group, and the supervisor signals the group we lead, so the command
+// already has them when we lead our group. SIGTERM is usually sent to a
+// single process and is always passed on.
+func shouldForwardSignal(sig syscall.Signal, groupLeader bool) bool {
+	return sig == syscall.SIGTERM || !groupLeader
+}
+
+// commandExitCode returns the exit status of a finished command from the error
+// returned by its Wait, using the shell convention of 128 plus the signal
+// number for commands killed by a signal
+func commandExitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
+
+// hookEnv returns the environment for an --on-success or --on-failure hook:
+// the command's environment plus its exit status and the reserved GPU IDs
+func hookEnv(env []string, exitCode int, gpuIDs []int) []string {
+	ids := make([]string, len(gpuIDs))
+	for i, gpuID := range gpuIDs {
+		ids[i] = strconv.Itoa(gpuID)
+	}
+
+	hookEnv := append([]string{}, env...)
+	return append(hookEnv,
+		"CANHAZGPU_EXIT_CODE="+strconv.Itoa(exitCode),
+		"CANHAZGPU_GPU_IDS="+strings.Join(ids, ","),
+	)
+}
+
+// runHook runs a hook command with the shell. A failing hook is reported but
+// doesn't change the command's exit status.
+func runHook(hook string, env []string) {
+	cmd := exec.Command("/bin/sh", "-c", hook)
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: hook command failed: %v\n", err)
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandExitCode(t *testing.T) {
+	code, err := commandExitCode(exec.Command("/bin/sh", "-c", "exit 0").Run())
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	code, err = commandExitCode(exec.Command("/bin/sh", "-c", "exit 3").Run())
+	require.NoError(t, err)
+	assert.Equal(t, 3, code)
+
+	// Killed by a signal, like a shell reports it
+	code, err = commandExitCode(exec.Command("/bin/sh", "-c", "kill -TERM $$").Run())
+	require.NoError(t, err)
+	assert.Equal(t, 128+int(syscall.SIGTERM), code)
+
+	_, err = commandExitCode(errors.New("wait failed"))
+	assert.Error(t, err)
+}
+
+func TestHookEnv(t *testing.T) {
+	env := hookEnv([]string{"CUDA_VISIBLE_DEVICES=1,3"}, 2, []int{1, 3})
+	assert.Equal(t, []string{"CUDA_VISIBLE_DEVICES=1,3", "CANHAZGPU_EXIT_CODE=2", "CANHAZGPU_GPU_IDS=1,3"}, env)
+}
+
+func TestShouldForwardSignal(t *testing.T) {
+	assert.True(t, shouldForwardSignal(syscall.SIGTERM, true))
+	assert.True(t, shouldForwardSignal(syscall.SIGTERM, false))
+	assert.False(t, shouldForwardSignal(syscall.SIGINT, true), "the terminal already sent it to the command")
+	assert.True(t, shouldForwardSignal(syscall.SIGINT, false))
+	assert.True(t, shouldForwardSignal(syscall.SIGHUP, false))
+}
+
+func TestRunWithHooks(t *testing.T) {
+	dir := t.TempDir()
+	hookOutput := filepath.Join(dir, "hook")
+	hook := "echo \"$CANHAZGPU_EXIT_CODE $CANHAZGPU_GPU_IDS\" > " + hookOutput
+	env := append(os.Environ(), "CUDA_VISIBLE_DEVICES=0,2")
+
+	// A failing command runs the failure hook and keeps its exit status
+	err := runWithHooks("/bin/sh", []string{"sh", "-c", "exit 7"}, env, []int{0, 2}, "", hook)
+	var exitErr *ExitCodeError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 7, exitErr.Code)
+	output, err := os.ReadFile(hookOutput)
+	require.NoError(t, err)
+	assert.Equal(t, "7 0,2\n", string(output))
+
+	// Only the success hook runs for a successful command, and a failing hook
+	// doesn't change the result
+	require.NoError(t, os.Remove(hookOutput))
+	err = runWithHooks("/bin/sh", []string{"sh", "-c", "exit 0"}, env, []int{0, 2}, "exit 1", hook)
+	assert.NoError(t, err)
+	assert.NoFileExists(t, hookOutput)
+
+	err = runWithHooks("/bin/sh", []string{"sh", "-c", "exit 0"}, env, []int{0, 2}, hook, "")
+	assert.NoError(t, err)
+	output, err = os.ReadFile(hookOutput)
+	require.NoError(t, err)
+	assert.Equal(t, "0 0,2\n", string(output))
+}
@@ -0,0 +1,12 @@
+package cli
+
+import "strings"
+
+// loginShellCommand wraps a command for 'run --login-shell', so that it runs
+// in a login shell that has read the user's profile, e.g. to make 'module
+// load' work. Like ssh, the arguments are joined with spaces and the shell
+// parses the result, so shell syntax such as && works, and arguments
+// containing spaces or quotes need quoting a second time.
+func loginShellCommand(shell string, command []string) []string {
+	return []string{shell, "-l", "-c", strings.Join(command, " ")}
+}
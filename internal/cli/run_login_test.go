@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginShellCommand(t *testing.T) {
+	assert.Equal(t, []string{"/bin/bash", "-l", "-c", "python train.py --epochs 3"},
+		loginShellCommand("/bin/bash", []string{"python", "train.py", "--epochs", "3"}))
+
+	// A single argument is passed to the shell as is
+	assert.Equal(t, []string{"/bin/zsh", "-l", "-c", "module load cuda/12.4 && python train.py"},
+		loginShellCommand("/bin/zsh", []string{"module load cuda/12.4 && python train.py"}))
+}
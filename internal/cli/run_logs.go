@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// runLogTimeFormat is the timestamp at the start of --log-dir file names.
+// It sorts in time order, which log rotation relies on.
+const runLogTimeFormat = "20060102-150405"
+
+// runLogNamePattern matches the files written to a --log-dir
+var runLogNamePattern = regexp.MustCompile(`^(\d{8}-\d{6}-\d+)\.(out|err)$`)
+
+// File descriptors the log-tee process receives its pipes and log files on
+const (
+	logTeeStdoutPipeFD = 3 + iota
+	logTeeStderrPipeFD
+	logTeeStdoutFileFD
+	logTeeStderrFileFD
+)
+
+var logTeeCmd = &cobra.Command{
+	Use:    "log-tee",
+	Short:  "Internal mode for copying run output to log files",
+	Hidden: true, // Hidden from help - internal use only
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLogTee()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logTeeCmd)
+}
+
+// prepareLogDir creates the --log-dir of a run if needed and returns its
+// absolute path, so it still refers to the same place after --working-dir
+func prepareLogDir(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid log directory: %v", err)
+	}
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create log directory: %v", err)
+	}
+	return absDir, nil
+}
+
+// runLogPaths returns the stdout and stderr log files for a command started
+// at start with the given PID
+func runLogPaths(dir string, start time.Time, pid int) (string, string) {
+	base := filepath.Join(dir, start.Format(runLogTimeFormat)+"-"+strconv.Itoa(pid))
+	return base + ".out", base + ".err"
+}
+
+// rotateRunLogs removes the oldest runs' log files from dir so that at most
+// keep runs remain, counting one about to be added. keep <= 0 keeps them all.
+// Files that weren't written by --log-dir are left alone.
+func rotateRunLogs(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	runs := make(map[string][]string)
+	for _, entry := range entries {
+		match := runLogNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil || !entry.Type().IsRegular() {
+			continue
+		}
+		runs[match[1]] = append(runs[match[1]], entry.Name())
+	}
+	if len(runs) < keep {
+		return nil
+	}
+
+	names := make([]string, 0, len(runs))
+	for name := range runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names[:len(names)-keep+1] {
+		for _, file := range runs[name] {
+			if err := os.Remove(filepath.Join(dir, file)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startRunLogs starts a log-tee process that copies everything written to
+// our stdout and stderr to both the terminal and the given log files, then
+// points our stdout and stderr at it. The command exec'd afterwards inherits
+// them, and the log-tee process exits once the command closes them.
+func startRunLogs(executable, outPath, errPath string) error {
+	outFile, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %v", err)
+	}
+	defer func() { _ = outFile.Close() }()
+	errFile, err := os.OpenFile(errPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %v", err)
+	}
+	defer func() { _ = errFile.Close() }()
+
+	outRead, outWrite, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %v", err)
+	}
+	defer func() { _ = outRead.Close(); _ = outWrite.Close() }()
+	errRead, errWrite, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %v", err)
+	}
+	defer func() { _ = errRead.Close(); _ = errWrite.Close() }()
+
+	teeCmd := exec.Command(executable, "log-tee")
+	teeCmd.Stdout = os.Stdout
+	teeCmd.Stderr = os.Stderr
+	teeCmd.ExtraFiles = []*os.File{outRead, errRead, outFile, errFile}
+
+	// Like the supervisor, keep it out of our process group so Ctrl-C reaches
+	// only the command, and the output written as it exits is still logged
+	teeCmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	if err := teeCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start log-tee: %v", err)
+	}
+
+	if err := syscall.Dup3(int(outWrite.Fd()), 1, 0); err != nil {
+		return fmt.Errorf("failed to redirect stdout: %v", err)
+	}
+	if err := syscall.Dup3(int(errWrite.Fd()), 2, 0); err != nil {
+		return fmt.Errorf("failed to redirect stderr: %v", err)
+	}
+	return nil
+}
+
+// runLogTee copies the run's stdout and stderr pipes to the terminal and
+// their log files until the command closes them
+func runLogTee() error {
+	// Keep logging if the terminal goes away
+	signal.Ignore(syscall.SIGINT, syscall.SIGHUP)
+
+	outPipe := os.NewFile(logTeeStdoutPipeFD, "stdout-pipe")
+	errPipe := os.NewFile(logTeeStderrPipeFD, "stderr-pipe")
+	outFile := os.NewFile(logTeeStdoutFileFD, "stdout-log")
+	errFile := os.NewFile(logTeeStderrFileFD, "stderr-log")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		teeStream(outPipe, os.Stdout, outFile, os.Stderr)
+	}()
+	go func() {
+		defer wg.Done()
+		teeStream(errPipe, os.Stderr, errFile, os.Stderr)
+	}()
+	wg.Wait()
+
+	_ = outFile.Close()
+	_ = errFile.Close()
+	return nil
+}
+
+// teeStream copies src to terminal and file until src is closed. A failed
+// write to either one doesn't stop the copy, so the command isn't killed by
+// SIGPIPE because its terminal or log file has gone away; a failed log write
+// is reported on warnings.
+func teeStream(src io.Reader, terminal io.Writer, file io.Writer, warnings io.Writer) {
+	logWriter := &bestEffortWriter{w: file, onError: func(err error) {
+		fmt.Fprintf(warnings, "Warning: canhazgpu stopped writing the log file: %v\n", err)
+	}}
+	_, _ = io.Copy(io.MultiWriter(logWriter, &bestEffortWriter{w: terminal}), src)
+}
+
+// bestEffortWriter passes writes through to w until one fails, then discards
+// everything. It never returns an error, so it doesn't stop an io.MultiWriter.
+type bestEffortWriter struct {
+	w       io.Writer
+	failed  bool
+	onError func(error)
+}
+
+func (b *bestEffortWriter) Write(p []byte) (int, error) {
+	if !b.failed {
+		if _, err := b.w.Write(p); err != nil {
+			b.failed = true
+			if b.onError != nil {
+				b.onError(err)
+			}
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareLogDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs", "train")
+	absDir, err := prepareLogDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, absDir)
+	assert.DirExists(t, dir)
+
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	_, err = prepareLogDir(filepath.Join(file, "logs"))
+	assert.ErrorContains(t, err, "failed to create log directory")
+}
+
+func TestRunLogPaths(t *testing.T) {
+	start := time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local)
+	outPath, errPath := runLogPaths("/logs", start, 1234)
+	assert.Equal(t, "/logs/20260304-050607-1234.out", outPath)
+	assert.Equal(t, "/logs/20260304-050607-1234.err", errPath)
+}
+
+func TestRotateRunLogs(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"20260101-000000-10.out", "20260101-000000-10.err",
+		"20260102-000000-11.out", "20260102-000000-11.err",
+		"20260103-000000-12.out",
+		"notes.txt",
+	}
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	remaining := func() []string {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var files []string
+		for _, entry := range entries {
+			files = append(files, entry.Name())
+		}
+		return files
+	}
+
+	require.NoError(t, rotateRunLogs(dir, 0))
+	assert.Len(t, remaining(), len(names))
+
+	require.NoError(t, rotateRunLogs(dir, 4))
+	assert.Len(t, remaining(), len(names))
+
+	// Room is made for the run about to start
+	require.NoError(t, rotateRunLogs(dir, 2))
+	assert.Equal(t, []string{"20260103-000000-12.out", "notes.txt"}, remaining())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("input/output error")
+}
+
+func TestTeeStream(t *testing.T) {
+	var terminal, file, warnings bytes.Buffer
+	teeStream(strings.NewReader("epoch 1\nepoch 2\n"), &terminal, &file, &warnings)
+	assert.Equal(t, "epoch 1\nepoch 2\n", terminal.String())
+	assert.Equal(t, "epoch 1\nepoch 2\n", file.String())
+	assert.Empty(t, warnings.String())
+
+	// Logging continues after the terminal goes away
+	file.Reset()
+	teeStream(strings.NewReader("epoch 1\n"), failingWriter{}, &file, &warnings)
+	assert.Equal(t, "epoch 1\n", file.String())
+	assert.Empty(t, warnings.String())
+
+	// A failed log write is reported once and the output still reaches the terminal
+	terminal.Reset()
+	teeStream(strings.NewReader("epoch 1\n"), &terminal, failingWriter{}, &warnings)
+	assert.Equal(t, "epoch 1\n", terminal.String())
+	assert.Equal(t, "Warning: canhazgpu stopped writing the log file: input/output error\n", warnings.String())
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// I/O scheduling classes and the priority shift for ioprio_set(2)
+const (
+	ioprioClassShift      = 13
+	ioprioClassBestEffort = 2
+	ioprioClassIdle       = 3
+	ioprioWhoProcess      = 1
+
+	// ioprioLowestBestEffort is the lowest priority level within the
+	// best-effort class
+	ioprioLowestBestEffort = 7
+)
+
+// schedulingPriority is the CPU and I/O scheduling priority for the command
+// of 'canhazgpu run'
+type schedulingPriority struct {
+	Nice   int // Nice value, -20 to 19 (0 = unchanged)
+	IOPrio int // ioprio_set(2) value (0 = unchanged)
+}
+
+// isSet returns true if any priority was requested
+func (p schedulingPriority) isSet() bool {
+	return p.Nice != 0 || p.IOPrio != 0
+}
+
+// parseSchedulingPriority validates the --nice and --ionice flags. --ionice
+// is "idle", to only get disk time nobody else wants, or "best-effort", for
+// the lowest priority among everyone else.
+func parseSchedulingPriority(nice int, ioClass string) (schedulingPriority, error) {
+	var priority schedulingPriority
+
+	if nice < -20 || nice > 19 {
+		return priority, fmt.Errorf("invalid nice value: must be from -20 to 19, got %d", nice)
+	}
+	priority.Nice = nice
+
+	switch strings.ToLower(strings.TrimSpace(ioClass)) {
+	case "":
+	case "idle":
+		priority.IOPrio = ioprioClassIdle << ioprioClassShift
+	case "best-effort":
+		priority.IOPrio = ioprioClassBestEffort<<ioprioClassShift | ioprioLowestBestEffort
+	default:
+		return priority, fmt.Errorf("invalid I/O scheduling class %q: must be idle or best-effort", ioClass)
+	}
+
+	return priority, nil
+}
+
+// applySchedulingPriority sets the priority of the current thread, which the
+// command we exec or start inherits. Linux keeps both priorities per thread,
+// so the goroutine stays locked to this thread from now on.
+func applySchedulingPriority(priority schedulingPriority) error {
+	runtime.LockOSThread()
+
+	if priority.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, priority.Nice); err != nil {
+			return fmt.Errorf("failed to set nice value %d: %v", priority.Nice, err)
+		}
+	}
+	if priority.IOPrio != 0 {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(priority.IOPrio))
+		if errno != 0 {
+			return fmt.Errorf("failed to set I/O priority: %v", errno)
+		}
+	}
+	return nil
+}
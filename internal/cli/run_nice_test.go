@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedulingPriority(t *testing.T) {
+	tests := []struct {
+		name    string
+		nice    int
+		ioClass string
+		want    schedulingPriority
+		wantErr string
+	}{
+		{name: "unset", want: schedulingPriority{}},
+		{name: "nice", nice: 10, want: schedulingPriority{Nice: 10}},
+		{name: "negative nice", nice: -5, want: schedulingPriority{Nice: -5}},
+		{name: "idle", ioClass: "idle", want: schedulingPriority{IOPrio: 3 << 13}},
+		{name: "best-effort", nice: 19, ioClass: "Best-Effort", want: schedulingPriority{Nice: 19, IOPrio: 2<<13 | 7}},
+		{name: "nice too high", nice: 20, wantErr: "must be from -20 to 19"},
+		{name: "nice too low", nice: -21, wantErr: "must be from -20 to 19"},
+		{name: "unknown class", ioClass: "realtime", wantErr: "must be idle or best-effort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSchedulingPriority(tt.nice, tt.ioClass)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.nice != 0 || tt.ioClass != "", got.isSet())
+		})
+	}
+}
+
+func TestApplySchedulingPriority(t *testing.T) {
+	// The thread stays locked with the lower priority, and exits with the
+	// goroutine
+	result := make(chan int, 1)
+	errs := make(chan error, 1)
+	go func() {
+		if err := applySchedulingPriority(schedulingPriority{Nice: 19}); err != nil {
+			errs <- err
+			return
+		}
+		// The raw syscall returns 20 - nice
+		prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+		if err != nil {
+			errs <- err
+			return
+		}
+		result <- 20 - prio
+	}()
+
+	select {
+	case err := <-errs:
+		t.Skipf("can't change the scheduling priority here: %v", err)
+	case nice := <-result:
+		assert.Equal(t, 19, nice)
+	}
+}
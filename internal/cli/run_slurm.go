@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultCountEnv is the variable 'run --count-from-env' reads the GPU
+	// count from when no variable is named
+	defaultCountEnv = "SLURM_GPUS_ON_NODE"
+
+	// slurmJobGPUsEnv lists the indexes of the GPUs SLURM assigned to the job
+	slurmJobGPUsEnv = "SLURM_JOB_GPUS"
+)
+
+// gpuRequestFromEnv reads the GPU count for 'run --count-from-env' from the
+// environment variable name. If SLURM lists the GPUs it assigned to the job,
+// they are returned as the GPUs to reserve, so that canhazgpu tracks the same
+// GPUs SLURM handed out. devices is the CUDA_VISIBLE_DEVICES value already
+// set for the job, which the command keeps (empty = set it to the reserved
+// GPUs as usual).
+func gpuRequestFromEnv(name string, getenv func(string) string) (count int, gpuIDs []int, devices string, err error) {
+	value := strings.TrimSpace(getenv(name))
+	if value == "" {
+		return 0, nil, "", fmt.Errorf("--count-from-env: %s is not set", name)
+	}
+	count, err = strconv.Atoi(value)
+	if err != nil || count < 1 {
+		return 0, nil, "", fmt.Errorf("--count-from-env: %s=%q is not a GPU count", name, value)
+	}
+
+	if assigned := strings.TrimSpace(getenv(slurmJobGPUsEnv)); assigned != "" {
+		gpuIDs, err = parseGPUList(assigned)
+		if err != nil {
+			return 0, nil, "", fmt.Errorf("--count-from-env: invalid %s=%q: %v", slurmJobGPUsEnv, assigned, err)
+		}
+		if len(gpuIDs) != count {
+			return 0, nil, "", fmt.Errorf("--count-from-env: %s lists %d GPU(s) but %s is %d", slurmJobGPUsEnv, len(gpuIDs), name, count)
+		}
+	}
+
+	return count, gpuIDs, strings.TrimSpace(getenv("CUDA_VISIBLE_DEVICES")), nil
+}
+
+// warnIfUnknownSLURMDevices warns when the job keeps SLURM's
+// CUDA_VISIBLE_DEVICES but SLURM didn't say which GPUs those are, so the GPUs
+// canhazgpu reserves may not be the ones the command uses
+func warnIfUnknownSLURMDevices(gpuIDs []int, devices string) {
+	if devices != "" && len(gpuIDs) == 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not set, so the reserved GPUs may differ from CUDA_VISIBLE_DEVICES=%s\n", slurmJobGPUsEnv, devices)
+	}
+}
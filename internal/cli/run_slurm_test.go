@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPURequestFromEnv(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+
+	count, gpuIDs, devices, err := gpuRequestFromEnv(defaultCountEnv, env(map[string]string{
+		"SLURM_GPUS_ON_NODE":   "2",
+		"SLURM_JOB_GPUS":       "2,3",
+		"CUDA_VISIBLE_DEVICES": "0,1",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []int{2, 3}, gpuIDs)
+	assert.Equal(t, "0,1", devices)
+
+	// Without SLURM_JOB_GPUS, only the count is taken
+	count, gpuIDs, devices, err = gpuRequestFromEnv("MY_GPU_COUNT", env(map[string]string{"MY_GPU_COUNT": " 4 "}))
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Nil(t, gpuIDs)
+	assert.Empty(t, devices)
+
+	_, _, _, err = gpuRequestFromEnv(defaultCountEnv, env(nil))
+	assert.EqualError(t, err, "--count-from-env: SLURM_GPUS_ON_NODE is not set")
+
+	_, _, _, err = gpuRequestFromEnv(defaultCountEnv, env(map[string]string{"SLURM_GPUS_ON_NODE": "0"}))
+	assert.EqualError(t, err, `--count-from-env: SLURM_GPUS_ON_NODE="0" is not a GPU count`)
+
+	_, _, _, err = gpuRequestFromEnv(defaultCountEnv, env(map[string]string{"SLURM_GPUS_ON_NODE": "2", "SLURM_JOB_GPUS": "1"}))
+	assert.EqualError(t, err, "--count-from-env: SLURM_JOB_GPUS lists 1 GPU(s) but SLURM_GPUS_ON_NODE is 2")
+
+	_, _, _, err = gpuRequestFromEnv(defaultCountEnv, env(map[string]string{"SLURM_GPUS_ON_NODE": "1", "SLURM_JOB_GPUS": "gpu0"}))
+	assert.ErrorContains(t, err, "invalid SLURM_JOB_GPUS")
+}
+
+func TestRunCountFromEnvFlag(t *testing.T) {
+	flag := runCmd.Flags().Lookup("count-from-env")
+	require.NotNil(t, flag)
+	assert.Equal(t, defaultCountEnv, flag.NoOptDefVal)
+}
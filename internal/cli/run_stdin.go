@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// runClosesStdin reports whether 'run' gives the command /dev/null as stdin.
+// Unless --no-stdin says otherwise, stdin is kept when it is a terminal, a
+// pipe, or a regular file, which carry input meant for the command. Anything
+// else, such as a socket inherited from a service manager or a closed stdin,
+// is replaced, since a batch job could otherwise hang reading input nobody
+// sends.
+func runClosesStdin(noStdinSet, noStdin bool, stdinMode os.FileMode, stdinOK bool) bool {
+	if noStdinSet {
+		return noStdin
+	}
+	if !stdinOK {
+		return true
+	}
+	return stdinMode&(os.ModeCharDevice|os.ModeNamedPipe) == 0 && !stdinMode.IsRegular()
+}
+
+// fileMode returns the mode of an open file, and whether it could be read
+func fileMode(f *os.File) (os.FileMode, bool) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false
+	}
+	return info.Mode(), true
+}
+
+// redirectStdinToDevNull replaces our stdin with /dev/null, which the
+// command we exec or start inherits
+func redirectStdinToDevNull() error {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", os.DevNull, err)
+	}
+	defer func() {
+		_ = devNull.Close()
+	}()
+
+	if err := syscall.Dup3(int(devNull.Fd()), int(os.Stdin.Fd()), 0); err != nil {
+		return fmt.Errorf("failed to redirect stdin: %v", err)
+	}
+	return nil
+}
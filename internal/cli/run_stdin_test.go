@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunClosesStdin(t *testing.T) {
+	terminal := os.ModeDevice | os.ModeCharDevice
+
+	// By default stdin is kept for a terminal, a pipe, or a regular file
+	assert.False(t, runClosesStdin(false, false, terminal, true))
+	assert.False(t, runClosesStdin(false, false, os.ModeNamedPipe, true))
+	assert.False(t, runClosesStdin(false, false, 0644, true))
+
+	// and replaced for anything else, or when it can't be checked
+	assert.True(t, runClosesStdin(false, false, os.ModeSocket, true))
+	assert.True(t, runClosesStdin(false, false, os.ModeDir, true))
+	assert.True(t, runClosesStdin(false, false, 0, false))
+
+	// --no-stdin overrides that either way
+	assert.True(t, runClosesStdin(true, true, terminal, true))
+	assert.False(t, runClosesStdin(true, false, os.ModeSocket, true))
+}
+
+func TestFileMode(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stdin")
+	assert.NoError(t, err)
+	mode, ok := fileMode(f)
+	assert.True(t, ok)
+	assert.True(t, mode.IsRegular())
+
+	assert.NoError(t, f.Close())
+	_, ok = fileMode(f)
+	assert.False(t, ok)
+}
@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"syscall"
 	"testing"
 	"time"
@@ -12,6 +15,7 @@ import (
 	"github.com/russellb/canhazgpu/internal/redis_client"
 	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // isNvidiaSmiAvailable checks if nvidia-smi command is available
@@ -121,7 +125,8 @@ func TestRunCommand_Structure(t *testing.T) {
 	// Check flags
 	gpusFlag := runCmd.Flags().Lookup("gpus")
 	assert.NotNil(t, gpusFlag)
-	assert.Equal(t, "int", gpusFlag.Value.Type())
+	assert.Equal(t, "string", gpusFlag.Value.Type())
+	assert.Equal(t, "1", gpusFlag.DefValue)
 }
 
 func TestRunRun_Validation(t *testing.T) {
@@ -154,7 +159,7 @@ func TestRunRun_Validation(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 
-			err := runRun(ctx, tt.gpuCount, nil, "", "", "", true, "", tt.command)
+			err := runRun(ctx, runOptions{GPUCount: tt.gpuCount, Nonblock: true, ExpiryWarning: 90, CleanThreshold: 100}, tt.command)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -515,3 +520,153 @@ func TestValidateRunCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestWarnIfTooFewGPUsForModel(t *testing.T) {
+	hints := map[string]int{"-70b": 2}
+	command := []string{"vllm", "serve", "meta-llama/Llama-3.1-70B-Instruct"}
+
+	// Too few GPUs requested by count
+	var buf bytes.Buffer
+	warnIfTooFewGPUsForModel(&buf, command, 1, nil, hints)
+	assert.Contains(t, buf.String(), "meta-llama/Llama-3.1-70B-Instruct typically needs at least 2 GPUs, but 1 requested")
+
+	// Enough GPUs requested by count or by ID
+	buf.Reset()
+	warnIfTooFewGPUsForModel(&buf, command, 2, nil, hints)
+	assert.Empty(t, buf.String())
+	warnIfTooFewGPUsForModel(&buf, command, 1, []int{0, 3}, hints)
+	assert.Empty(t, buf.String())
+
+	// Unknown model or no model at all
+	warnIfTooFewGPUsForModel(&buf, []string{"vllm", "serve", "meta-llama/Llama-3.1-8B-Instruct"}, 1, nil, hints)
+	assert.Empty(t, buf.String())
+	warnIfTooFewGPUsForModel(&buf, []string{"python", "train.py"}, 1, nil, hints)
+	assert.Empty(t, buf.String())
+}
+
+func TestModelGPUHints(t *testing.T) {
+	v := newTestViper(t, `
+model_gpu_hints:
+  "-70b": 4
+  deepseek-v3: 8
+  broken: lots
+`)
+
+	hints := modelGPUHints(v)
+	assert.Equal(t, 4, hints["-70b"])        // config overrides built-in
+	assert.Equal(t, 8, hints["deepseek-v3"]) // config adds new hints
+	assert.Equal(t, 8, hints["-405b"])       // built-in kept
+	assert.NotContains(t, hints, "broken")
+}
+
+func TestWriteGPUIDsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpus.json")
+	require.NoError(t, writeGPUIDsFile(path, newRunAllocationJSON([]int{1, 3}, "alice", 4242)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var allocation RunAllocationJSON
+	require.NoError(t, json.Unmarshal(data, &allocation))
+	assert.Equal(t, []int{1, 3}, allocation.GPUIDs)
+	assert.Equal(t, "1,3", allocation.CUDAVisibleDevices)
+	assert.Equal(t, "alice", allocation.User)
+	assert.Equal(t, 4242, allocation.PID)
+
+	assert.Error(t, writeGPUIDsFile(filepath.Join(t.TempDir(), "missing", "gpus.json"), newRunAllocationJSON([]int{0}, "alice", 1)))
+}
+
+func TestWriteAllocationJSON(t *testing.T) {
+	allocation := newRunAllocationJSON([]int{0, 2}, "alice", 4242)
+	allocation.TimeoutSeconds = 7200
+	allocation.Account = "ml-team"
+
+	var buf bytes.Buffer
+	require.NoError(t, writeAllocationJSON(&buf, allocationJSONStderr, allocation))
+	assert.Equal(t, `{"gpu_ids":[0,2],"cuda_visible_devices":"0,2","user":"alice","pid":4242,"timeout_seconds":7200,"account":"ml-team"}`+"\n", buf.String())
+
+	// Any other destination is a file
+	buf.Reset()
+	path := filepath.Join(t.TempDir(), "allocation.json")
+	require.NoError(t, writeAllocationJSON(&buf, path, allocation))
+	assert.Empty(t, buf.String())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written RunAllocationJSON
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, allocation, written)
+}
+
+func TestDirtyGPUs(t *testing.T) {
+	usage := map[int]*types.GPUUsage{
+		0: {GPUID: 0, MemoryMB: 0},
+		1: {GPUID: 1, MemoryMB: 2048, Processes: []types.GPUProcessInfo{
+			{PID: 1234, ProcessName: "python", User: "alice"},
+			{PID: 1240, ProcessName: "python", User: "alice"},
+		}},
+		2: {GPUID: 2, MemoryMB: 100},
+		3: {GPUID: 3, MemoryMB: 600},
+	}
+
+	assert.Equal(t, []int{1, 3}, dirtyGPUs(usage, []int{0, 1, 2, 3}, 100))
+	assert.Equal(t, []int{1}, dirtyGPUs(usage, []int{0, 1, 2, 3}, 1000))
+	assert.Empty(t, dirtyGPUs(usage, []int{0, 2, 5}, 100), "GPUs without usage are clean")
+
+	assert.Equal(t,
+		"  GPU 1: 2048MB used by PID 1234 (python, alice), PID 1240 (python, alice)\n"+
+			"  GPU 3: 600MB used, no processes found (memory may still be being freed)\n",
+		formatDirtyGPUs(usage, []int{1, 3}))
+}
+
+func TestCheckWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, checkWorkingDir(dir))
+
+	file := filepath.Join(dir, "train.py")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	assert.ErrorContains(t, checkWorkingDir(file), "is not a directory")
+	assert.ErrorContains(t, checkWorkingDir(filepath.Join(dir, "missing")), "invalid working directory")
+}
+
+func TestValidateEnvVars(t *testing.T) {
+	assert.NoError(t, validateEnvVars(nil))
+	assert.NoError(t, validateEnvVars([]string{"HF_HOME=/data/hf", "EMPTY=", "OPTS=a=b,c"}))
+	assert.ErrorContains(t, validateEnvVars([]string{"HF_HOME"}), "must be KEY=VALUE")
+	assert.ErrorContains(t, validateEnvVars([]string{"=value"}), "must be KEY=VALUE")
+}
+
+func TestRedactCommand(t *testing.T) {
+	secretFlags := types.DefaultCommandRedactFlags()
+
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+	}{
+		{"plain", []string{"python", "train.py", "--epochs", "3"}, "python train.py --epochs 3"},
+		{"separate value", []string{"vllm", "serve", "my/model", "--hf-token", "hf_abc"}, "vllm serve my/model --hf-token ***"},
+		{"joined value", []string{"python", "app.py", "--api-key=sk-123", "--port=80"}, "python app.py --api-key=*** --port=80"},
+		{"case-insensitive", []string{"tool", "--TOKEN", "abc"}, "tool --TOKEN ***"},
+		{"quoting", []string{"bash", "-c", "python a.py && python b.py", ""}, "bash -c 'python a.py && python b.py' ''"},
+		{"flag at end", []string{"tool", "--password"}, "tool --password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactCommand(tt.command, secretFlags))
+		})
+	}
+
+	// Configured flags are redacted as well
+	assert.Equal(t, "train --wandb-key ***", redactCommand([]string{"train", "--wandb-key", "w123"}, append(secretFlags, "--wandb-key")))
+}
+
+func TestRunCommandEnv(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "HF_HOME=/home/alice/.cache", "CUDA_VISIBLE_DEVICES=0,1,2,3"}
+
+	env := runCommandEnv(base, []string{"HF_HOME=/data/hf", "SEED=1", "SEED=2", "CUDA_VISIBLE_DEVICES=7"}, "2,3")
+	assert.Equal(t, []string{"PATH=/usr/bin", "HF_HOME=/data/hf", "SEED=2", "CUDA_VISIBLE_DEVICES=2,3"}, env)
+
+	env = runCommandEnv(base, nil, "1")
+	assert.Equal(t, []string{"PATH=/usr/bin", "HF_HOME=/home/alice/.cache", "CUDA_VISIBLE_DEVICES=1"}, env)
+}
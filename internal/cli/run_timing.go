@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/utils"
+)
+
+// formatAllocationTiming describes how long an allocation took for 'run
+// --timing', e.g. "Allocated in 230ms (GPU detection 180ms, lock wait 2ms)"
+func formatAllocationTiming(timing gpu.AllocationTiming) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Allocated in %s (GPU detection %s, lock wait %s)",
+		formatLatency(timing.Total), formatLatency(timing.Detection), formatLatency(timing.LockWait))
+	if timing.QueueWait > 0 {
+		fmt.Fprintf(&b, " after %s in the queue", utils.FormatDuration(timing.QueueWait))
+	}
+	return b.String()
+}
+
+// formatLatency rounds a latency to milliseconds, e.g. 230ms or 1.204s
+func formatLatency(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAllocationTiming(t *testing.T) {
+	assert.Equal(t, "Allocated in 230ms (GPU detection 180ms, lock wait 2ms)", formatAllocationTiming(gpu.AllocationTiming{
+		Total:     230*time.Millisecond + 400*time.Microsecond,
+		Detection: 180 * time.Millisecond,
+		LockWait:  1600 * time.Microsecond,
+	}))
+
+	assert.Equal(t, "Allocated in 1.204s (GPU detection 0s, lock wait 1.1s) after 0h 5m 3s in the queue", formatAllocationTiming(gpu.AllocationTiming{
+		Total:     1204 * time.Millisecond,
+		LockWait:  1100 * time.Millisecond,
+		QueueWait: 5*time.Minute + 3*time.Second,
+	}))
+}
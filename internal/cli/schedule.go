@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Reserve specific GPUs for a future time window",
+	Long: `Book specific GPUs for a future time window, e.g. for a large job that
+should run overnight.
+
+The window is stored in Redis, and 'canhazgpu daemon' reserves the GPUs
+when it starts, as a manual reservation that expires when the window ends.
+Without a running daemon, scheduled windows are never activated.
+
+Windows can't overlap other scheduled windows on the same GPUs, but GPUs
+that are reserved now can be scheduled, in case they are released before
+the window starts. If a GPU is still reserved or in unreserved use when the
+window starts, the conflict is logged by the daemon and sent to the webhook
+set by the reminder_webhook.url config option, and the daemon keeps trying
+to reserve the GPUs until the window ends.
+
+--start and --end take a time of day (22:00), which means the next time it
+comes around, or a date and time (2026-03-04 22:00). A time of day for --end
+is the first one after --start, so a window from 22:00 to 06:00 ends the
+next morning. Use --duration instead of --end to give the window's length.
+
+Use --list to show all scheduled windows and --cancel <id> to remove one of
+yours that hasn't started. Once a window is active, its GPUs are released
+with 'canhazgpu release' like any other reservation.
+
+Example usage:
+  canhazgpu schedule --gpu-ids 0,1,2,3 --start 22:00 --end 06:00 --note "pretraining"
+  canhazgpu schedule --gpu-ids 4 --start "2026-03-04 09:00" --duration 8h
+  canhazgpu schedule --list
+  canhazgpu schedule --cancel 1f3a9c2e`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if viper.GetBool("schedule.list") {
+			return runScheduleList(cmd.Context(), viper.GetBool("schedule.json"))
+		}
+		if id := viper.GetString("schedule.cancel"); id != "" {
+			return runScheduleCancel(cmd.Context(), id)
+		}
+
+		gpuIDs := viper.GetIntSlice("schedule.gpu-ids")
+		if len(gpuIDs) == 0 {
+			return fmt.Errorf("--gpu-ids is required to schedule a reservation")
+		}
+		start := viper.GetString("schedule.start")
+		end := viper.GetString("schedule.end")
+		durationStr := viper.GetString("schedule.duration")
+		note := viper.GetString("schedule.note")
+		customUser := viper.GetString("schedule.user")
+		account := stringFlagOrDefault(viper.GetViper(), cmd, "account", "default_account")
+
+		return runSchedule(cmd.Context(), gpuIDs, start, end, durationStr, note, customUser, account)
+	},
+}
+
+func init() {
+	scheduleCmd.Flags().IntSliceP("gpu-ids", "G", nil, "GPU IDs to reserve (comma-separated, e.g., 0,1,2,3)")
+	scheduleCmd.Flags().String("start", "", "When the window starts: a time of day (22:00) or date and time (2026-03-04 22:00)")
+	scheduleCmd.Flags().String("end", "", "When the window ends: a time of day (06:00) or date and time")
+	scheduleCmd.Flags().StringP("duration", "d", "", "Length of the window, instead of --end (e.g., 8h)")
+	scheduleCmd.Flags().StringP("note", "n", "", "Optional note describing the reservation purpose")
+	scheduleCmd.Flags().StringP("user", "u", "", "Custom user identifier (e.g., your name when using a shared account)")
+	scheduleCmd.Flags().String("account", "", "Team account to bill the usage to (default: your primary group)")
+	scheduleCmd.Flags().Bool("list", false, "List the scheduled windows")
+	scheduleCmd.Flags().Bool("json", false, "Output --list in JSON format")
+	scheduleCmd.Flags().String("cancel", "", "Cancel the scheduled window with this ID")
+	scheduleCmd.MarkFlagsMutuallyExclusive("list", "cancel", "gpu-ids")
+	scheduleCmd.MarkFlagsMutuallyExclusive("end", "duration")
+
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+func runSchedule(ctx context.Context, gpuIDs []int, startStr, endStr, durationStr, note, customUser, account string) error {
+	startTime, endTime, err := parseScheduleWindow(startStr, endStr, durationStr, time.Now())
+	if err != nil {
+		return err
+	}
+
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	engine := gpu.NewAllocationEngine(client, config)
+
+	actualUser := getCurrentUser()
+	displayUser := actualUser
+	if customUser != "" {
+		displayUser = customUser
+	}
+
+	schedule := &types.ScheduledReservation{
+		ID:         uuid.New().String()[:8],
+		User:       displayUser,
+		ActualUser: actualUser,
+		GPUIDs:     gpuIDs,
+		StartTime:  types.FlexibleTime{Time: startTime},
+		EndTime:    types.FlexibleTime{Time: endTime},
+		Note:       note,
+		Account:    resolveAccount(account),
+		CreatedAt:  types.FlexibleTime{Time: time.Now()},
+		Status:     types.ScheduleStatusPending,
+	}
+	if err := engine.AddScheduledReservation(ctx, schedule); err != nil {
+		return err
+	}
+
+	fmt.Printf("Scheduled GPU(s) %v from %s to %s (%s)\n", gpuIDs,
+		formatScheduleTime(startTime), formatScheduleTime(endTime), utils.FormatDuration(endTime.Sub(startTime)))
+	fmt.Printf("Cancel it with: canhazgpu schedule --cancel %s\n", schedule.ID)
+
+	if owner, err := client.GetDaemonLockOwner(ctx); err == nil && owner == "" {
+		fmt.Fprintln(os.Stderr, "Warning: no 'canhazgpu daemon' is running; scheduled windows are only activated by the daemon")
+	}
+
+	return nil
+}
+
+// parseScheduleWindow parses the start and end of a scheduled window. The end
+// is given by --end or as a --duration after the start.
+func parseScheduleWindow(startStr, endStr, durationStr string, now time.Time) (time.Time, time.Time, error) {
+	if startStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("--start is required to schedule a reservation")
+	}
+	if endStr == "" && durationStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("--end or --duration is required to schedule a reservation")
+	}
+
+	startTime, err := parseScheduleTime(startStr, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %v", err)
+	}
+	if !startTime.After(now) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--start %s is in the past; use 'canhazgpu reserve' to reserve GPUs now", startStr)
+	}
+
+	var endTime time.Time
+	if durationStr != "" {
+		duration, err := utils.ParseDuration(durationStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --duration: %v", err)
+		}
+		endTime = startTime.Add(duration)
+	} else if endTime, err = parseScheduleTime(endStr, startTime); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --end: %v", err)
+	}
+	if !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the window must end after it starts")
+	}
+
+	return startTime, endTime, nil
+}
+
+// parseScheduleTime parses a local date and time, or a time of day, which is
+// its first occurrence after the given time
+func parseScheduleTime(value string, after time.Time) (time.Time, error) {
+	if clock, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		year, month, day := after.Date()
+		t := time.Date(year, month, day, clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if !t.After(after) {
+			t = time.Date(year, month, day+1, clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		}
+		return t, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time of day (22:00) or date and time (2026-03-04 22:00)", value)
+}
+
+// formatScheduleTime formats the start or end of a scheduled window
+func formatScheduleTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func runScheduleCancel(ctx context.Context, id string) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	engine := gpu.NewAllocationEngine(client, config)
+	schedule, err := engine.CancelScheduledReservation(ctx, id, getCurrentUser())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Canceled schedule %s of GPU(s) %v from %s to %s\n", schedule.ID, schedule.GPUIDs,
+		formatScheduleTime(schedule.StartTime.Time), formatScheduleTime(schedule.EndTime.Time))
+	return nil
+}
+
+func runScheduleList(ctx context.Context, jsonOutput bool) error {
+	config := getConfig()
+	client := redis_client.NewClient(config)
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Printf("Warning: failed to close Redis client: %v\n", err)
+		}
+	}()
+
+	// Test Redis connection
+	if err := pingRedis(ctx, client); err != nil {
+		return err
+	}
+
+	schedules, err := client.ReadOnly().GetScheduledReservations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(schedules, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal scheduled reservations: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printScheduleTable(schedules)
+	return nil
+}
+
+func printScheduleTable(schedules []*types.ScheduledReservation) {
+	fmt.Println("Scheduled Reservations")
+	fmt.Println("======================")
+
+	if len(schedules) == 0 {
+		fmt.Println("No scheduled reservations.")
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("%-10s %-15s %-12s %-17s %-17s %-10s %s\n",
+		"ID", "User", "GPUs", "Start", "End", "Status", "Note")
+	fmt.Printf("%-10s %-15s %-12s %-17s %-17s %-10s %s\n",
+		"--", "----", "----", "-----", "---", "------", "----")
+
+	var conflicts []*types.ScheduledReservation
+	for _, schedule := range schedules {
+		fmt.Printf("%-10s %-15s %-12s %-17s %-17s %-10s %s\n",
+			schedule.ID,
+			truncateString(schedule.User, 15),
+			truncateString(fmt.Sprint(schedule.GPUIDs), 12),
+			formatScheduleTime(schedule.StartTime.Time),
+			formatScheduleTime(schedule.EndTime.Time),
+			schedule.Status,
+			schedule.Note)
+		if schedule.Conflict != "" {
+			conflicts = append(conflicts, schedule)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		fmt.Println()
+		for _, schedule := range conflicts {
+			fmt.Printf("Schedule %s is waiting for its GPUs: %s\n", schedule.ID, schedule.Conflict)
+		}
+	}
+}
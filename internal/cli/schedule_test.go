@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScheduleTime(t *testing.T) {
+	now := time.Date(2026, 3, 4, 16, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		value string
+		after time.Time
+		want  time.Time
+	}{
+		{"22:00", now, time.Date(2026, 3, 4, 22, 0, 0, 0, time.Local)},
+		{"09:00", now, time.Date(2026, 3, 5, 9, 0, 0, 0, time.Local)},
+		{"16:30", now, time.Date(2026, 3, 5, 16, 30, 0, 0, time.Local)},
+		{"06:00", time.Date(2026, 3, 4, 22, 0, 0, 0, time.Local), time.Date(2026, 3, 5, 6, 0, 0, 0, time.Local)},
+		{"2026-03-10 08:15", now, time.Date(2026, 3, 10, 8, 15, 0, 0, time.Local)},
+		{"2026-03-10T08:15", now, time.Date(2026, 3, 10, 8, 15, 0, 0, time.Local)},
+		{"2026-03-10T08:15:00Z", now, time.Date(2026, 3, 10, 8, 15, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseScheduleTime(tt.value, tt.after)
+		require.NoError(t, err, tt.value)
+		assert.True(t, tt.want.Equal(got), "%s: got %v, want %v", tt.value, got, tt.want)
+	}
+
+	for _, value := range []string{"", "tonight", "25:00", "2026-03-10"} {
+		_, err := parseScheduleTime(value, now)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseScheduleWindow(t *testing.T) {
+	now := time.Date(2026, 3, 4, 16, 30, 0, 0, time.Local)
+
+	start, end, err := parseScheduleWindow("22:00", "06:00", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 4, 22, 0, 0, 0, time.Local), start)
+	assert.Equal(t, time.Date(2026, 3, 5, 6, 0, 0, 0, time.Local), end)
+
+	start, end, err = parseScheduleWindow("2026-03-05 09:00", "", "8h", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 5, 9, 0, 0, 0, time.Local), start)
+	assert.Equal(t, 8*time.Hour, end.Sub(start))
+
+	tests := []struct {
+		start, end, duration string
+		wantErr              string
+	}{
+		{"", "06:00", "", "--start is required"},
+		{"22:00", "", "", "--end or --duration is required"},
+		{"soon", "06:00", "", "invalid --start"},
+		{"22:00", "later", "", "invalid --end"},
+		{"22:00", "", "forever", "invalid --duration"},
+		{"2026-03-01 22:00", "06:00", "", "is in the past"},
+		{"2026-03-05 22:00", "2026-03-05 21:00", "", "must end after it starts"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseScheduleWindow(tt.start, tt.end, tt.duration, now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tt.wantErr)
+	}
+}
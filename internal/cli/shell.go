@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// shellPromptPrefix is put in front of the prompt of a 'canhazgpu shell'
+const shellPromptPrefix = "(canhazgpu) "
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Reserve GPUs and start an interactive shell with CUDA_VISIBLE_DEVICES set",
+	Long: `Reserve GPUs and start an interactive shell for exploratory work. The GPUs
+are released when you exit the shell.
+
+This is the same as 'canhazgpu run -- $SHELL' (or /bin/sh if SHELL isn't
+set): GPUs are reserved, waiting in the queue if needed, and the shell runs
+with CUDA_VISIBLE_DEVICES set and a heartbeat keeping the reservation active.
+
+Inside the shell, CANHAZGPU_SHELL is set to 1 and CANHAZGPU_PROMPT to
+"(canhazgpu) ". An exported PS1 is prefixed with CANHAZGPU_PROMPT. Shells
+that set their prompt in a startup file (e.g. ~/.bashrc) replace it, so add
+$CANHAZGPU_PROMPT to the prompt there to see when you are in a reservation.
+
+Example usage:
+  canhazgpu shell
+  canhazgpu shell --gpus 2
+  canhazgpu shell --gpu-ids 1,3 --timeout 4h
+  canhazgpu shell --gpus 1 --gpu-class large --note "debugging OOM"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gpuCount, err := parseGPUCount(viper.GetString("shell.gpus"))
+		if err != nil {
+			return err
+		}
+		ps1, hasPS1 := os.LookupEnv("PS1")
+		opts := runOptions{
+			GPUCount:      gpuCount,
+			GPUIDs:        viper.GetIntSlice("shell.gpu-ids"),
+			Timeout:       stringFlagOrDefault(viper.GetViper(), cmd, "timeout", "default_run_timeout"),
+			Note:          viper.GetString("shell.note"),
+			User:          viper.GetString("shell.user"),
+			Nonblock:      viper.GetBool("shell.nonblock"),
+			Wait:          viper.GetString("shell.wait"),
+			Priority:      viper.GetString("shell.priority"),
+			ExpiryWarning: 90,
+			Account:       stringFlagOrDefault(viper.GetViper(), cmd, "account", "default_account"),
+			Env:           shellEnv(ps1, hasPS1),
+			GPUClass:      strings.ToLower(strings.TrimSpace(viper.GetString("shell.gpu-class"))),
+		}
+		err = runRun(cmd.Context(), opts, []string{userShell()})
+
+		// Exit with the shell's exit status, like run
+		if exitErr, ok := err.(*ExitCodeError); ok {
+			os.Exit(exitErr.Code)
+		}
+
+		return err
+	},
+}
+
+func init() {
+	shellCmd.Flags().StringP("gpus", "g", "1", "Number of GPUs to reserve, or 'all' for every available GPU")
+	shellCmd.Flags().IntSliceP("gpu-ids", "G", nil, "Specific GPU IDs to reserve (comma-separated, e.g., 1,3,5)")
+	shellCmd.Flags().StringP("timeout", "t", "", "Timeout duration after which the shell is terminated (e.g., 30m, 2h, 1d). Disabled by default.")
+	shellCmd.Flags().StringP("note", "n", "", "Optional note describing the reservation purpose")
+	shellCmd.Flags().StringP("user", "u", "", "Custom user identifier (e.g., your name when using a shared account)")
+	shellCmd.Flags().Bool("nonblock", false, "Fail immediately if GPUs are unavailable instead of waiting in queue")
+	shellCmd.Flags().StringP("wait", "w", "", "Maximum time to wait for GPUs (e.g., 30m, 2h). Default: wait forever.")
+	shellCmd.Flags().String("priority", types.PriorityNormal, "Reservation priority: low, normal, or high")
+	shellCmd.Flags().String("account", "", "Team account to bill the usage to (default: your primary group)")
+	shellCmd.Flags().String("gpu-class", "", "Only reserve GPUs of this memory class: small or large, or a class from gpu_classes")
+
+	rootCmd.AddCommand(shellCmd)
+}
+
+// userShell returns the user's login shell from SHELL, or /bin/sh
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// shellEnv returns the --env values for a 'canhazgpu shell': markers that
+// the shell runs in a reservation, and the inherited PS1, if any, with the
+// prompt prefix in front
+func shellEnv(ps1 string, hasPS1 bool) []string {
+	env := []string{
+		"CANHAZGPU_SHELL=1",
+		"CANHAZGPU_PROMPT=" + shellPromptPrefix,
+	}
+	if hasPS1 {
+		env = append(env, "PS1="+shellPromptPrefix+ps1)
+	}
+	return env
+}
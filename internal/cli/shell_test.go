@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserShell(t *testing.T) {
+	t.Setenv("SHELL", "/usr/bin/zsh")
+	assert.Equal(t, "/usr/bin/zsh", userShell())
+
+	t.Setenv("SHELL", "")
+	assert.Equal(t, "/bin/sh", userShell())
+}
+
+func TestShellEnv(t *testing.T) {
+	assert.Equal(t, []string{
+		"CANHAZGPU_SHELL=1",
+		"CANHAZGPU_PROMPT=(canhazgpu) ",
+	}, shellEnv("", false))
+
+	assert.Equal(t, []string{
+		"CANHAZGPU_SHELL=1",
+		"CANHAZGPU_PROMPT=(canhazgpu) ",
+		"PS1=(canhazgpu) \\u@\\h:\\w\\$ ",
+	}, shellEnv("\\u@\\h:\\w\\$ ", true))
+
+	// The values are valid --env values for runRun
+	assert.NoError(t, validateEnvVars(shellEnv("$ ", true)))
+}
@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -34,51 +36,265 @@ Remote host status:
 
 Summary mode:
 - Use --summary or -s to show a condensed summary
-- Works with local, --remote, or --all modes`,
+- Works with local, --remote, or --all modes
+- With --json, prints each host's counts and GPU models, and totals over
+  all hosts, without the per-GPU details
+
+Fast mode:
+- Use --no-validate to skip GPU validation and show only the reservation
+  state stored in Redis (no validation info, no unreserved usage detection)
+
+Wide mode:
+- Use --wide to add GPU model, process PIDs, reservation start time,
+  priority, source, and command columns to the table. The command line of
+  a run reservation is truncated; --json shows all of it
+
+Telemetry:
+- Use --telemetry to add fan speed and SM and memory clock columns, and the
+  same values to --json, for thermal and overclocking debugging. It costs
+  an extra nvidia-smi or amd-smi call, so it is off by default
+
+Process PIDs:
+- Use --show-pids to list the PID and name of each process using a GPU,
+  reserved or not, e.g. to find the process to kill. Adds a PIDS column,
+  or adds the names to the --wide PIDS column
+
+GPU selection:
+- Use --gpu-ids/-G to show only the listed GPUs, e.g. --gpu-ids 0,2.
+  Works with the table, --json, --summary, --stale, --remote, and --all
+
+Stale mode:
+- Use --stale to show only run reservations whose last heartbeat is more
+  than half of the heartbeat timeout old, most stale first. These
+  reservations will be reclaimed soon unless their heartbeat resumes
+
+Delta mode:
+- Use --delta to show only the GPUs whose status or user changed since the
+  last 'status --delta', e.g. from a monitoring script. Each change is
+  marked + (now in use), - (now available), or ~ (other changes, such as a
+  different user). Nothing is printed if nothing changed. The previous
+  status of each host is kept in the user's cache directory; the first run
+  for a host only saves it. Works with --json, --remote, --all, and --gpu-ids
+
+Prompt mode:
+- Use --prompt to print only a one-line banner such as "GPU 3/8 free",
+  e.g. from a shell prompt or tmux status line. Add --no-validate to skip
+  nvidia-smi so it returns quickly
+- Use --prompt-format to change the banner with a Go template over .Free,
+  .Total, .InUse, .Unreserved, and .Level (green, yellow, or red by the
+  share of free GPUs). {{color .Level .Free}} colors a value
+
+Check mode:
+- Use --check <user>=<max GPUs> to check that a user holds no more than
+  that many GPUs, counting reservations, shares of shared GPUs, and
+  unreserved usage, e.g. from a monitoring job. Repeat it or separate
+  rules with commas to check several users; * applies to every user
+  without a rule of their own
+- Use --check-file to read the rules from a file, one per line, with
+  blank lines and lines starting with # ignored
+- Each violation is printed and the command exits with status 2. It exits
+  with status 0 if every check passes, and 1 on errors
+
+Table style:
+- Use --table-style to choose how tables are drawn: light (default),
+  ascii for plain ASCII separators, markdown for pasting into tickets and
+  pull requests, or compact for columns separated only by spaces
+
+Colors:
+- Output is colored only when it is a terminal and NO_COLOR isn't set.
+  Use --no-color to turn colors off, or --force-color (or CLICOLOR_FORCE=1)
+  to keep them when piping the status to a colorizing log viewer or
+  writing it to a file with --output
+
+Output file:
+- Use --output/-o <path> to write the status to a file instead of stdout,
+  e.g. from a scheduled job. The file is replaced atomically, so readers
+  never see a partial status. Paths ending in .json get JSON unless
+  --format text is given; use --format json for any other path`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runStatus(cmd.Context())
+		err := runStatus(cmd.Context())
+
+		// Failed checks have already been reported
+		if exitErr, ok := err.(*ExitCodeError); ok {
+			os.Exit(exitErr.Code)
+		}
+
+		return err
 	},
 }
 
 var (
-	jsonOutput  bool
-	showAll     bool
-	remoteName  string
-	showSummary bool
-	noColorFlag bool
+	jsonOutput    bool
+	showAll       bool
+	remoteName    string
+	showSummary   bool
+	noColorFlag   bool
+	forceColor    bool
+	noValidate    bool
+	wideOutput    bool
+	showPIDs      bool
+	showTelemetry bool
+	staleOnly     bool
+	errorsOnly    bool
+	statusDelta   bool
+	statusPrompt  bool
+	tableStyle    string
+
+	statusPromptFormat string
+
+	statusChecks    []string
+	statusCheckFile string
+
+	statusGPUIDs []int
+	statusOutput string
+	statusFormat string
 )
 
+// staleHeartbeatFraction is the fraction of the heartbeat timeout after which
+// 'status --stale' reports a run reservation as stale
+const staleHeartbeatFraction = 0.5
+
 func init() {
-	statusCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Output status as JSON array")
+	statusCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "Output status as JSON")
 	statusCmd.Flags().BoolVar(&showAll, "all", false, "Show status for all configured remote hosts")
 	statusCmd.Flags().StringVarP(&remoteName, "remote", "r", "", "Show status for a specific remote host")
 	statusCmd.Flags().BoolVarP(&showSummary, "summary", "s", false, "Show summary with GPU counts and availability")
 	statusCmd.Flags().BoolVar(&noColorFlag, "no-color", false, "Disable colored output")
+	statusCmd.Flags().BoolVar(&forceColor, "force-color", false, "Color the output even when it isn't a terminal, e.g. when piped to a log viewer")
+	statusCmd.MarkFlagsMutuallyExclusive("no-color", "force-color")
+	statusCmd.Flags().BoolVar(&noValidate, "no-validate", false, "Skip GPU validation and show reservation state from Redis only")
+	statusCmd.Flags().BoolVar(&wideOutput, "wide", false, "Show additional columns (GPU model, PIDs, start time, priority, source, command)")
+	statusCmd.Flags().BoolVar(&showPIDs, "show-pids", false, "Show the PID and name of each process using a GPU")
+	statusCmd.Flags().BoolVar(&showTelemetry, "telemetry", false, "Show fan speed and SM and memory clocks (extra nvidia-smi/amd-smi call)")
+	statusCmd.Flags().BoolVar(&staleOnly, "stale", false, "Show only run reservations with stale heartbeats that will soon be reclaimed")
+	statusCmd.Flags().BoolVar(&errorsOnly, "errors-only", false, "Show only GPUs that need attention: errors, unreserved usage, and stale heartbeats")
+	statusCmd.Flags().BoolVar(&statusDelta, "delta", false, "Show only GPUs whose status changed since the last 'status --delta'")
+	statusCmd.Flags().BoolVar(&statusPrompt, "prompt", false, "Print a one-line GPU availability banner for shell prompts, e.g. 'GPU 3/8 free'")
+	statusCmd.Flags().StringVar(&statusPromptFormat, "prompt-format", defaultPromptFormat, "Go template for the --prompt banner")
+	statusCmd.Flags().StringSliceVar(&statusChecks, "check", nil, "Exit with status 2 if a user holds more GPUs than allowed, e.g. alice=2 or '*=4' (repeatable)")
+	statusCmd.Flags().StringVar(&statusCheckFile, "check-file", "", "Read --check rules from this file, one per line")
+	statusCmd.Flags().IntSliceVarP(&statusGPUIDs, "gpu-ids", "G", nil, "Show only these GPU IDs (comma-separated, e.g., 0,2)")
+	statusCmd.Flags().StringVar(&tableStyle, "table-style", "light", "Table style: light, ascii, markdown, or compact")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "", "Write the status to this file instead of stdout")
+	statusCmd.Flags().StringVar(&statusFormat, "format", "", "Output format: text or json (default: json for --output paths ending in .json, otherwise text)")
 	rootCmd.AddCommand(statusCmd)
 }
 
 func runStatus(ctx context.Context) error {
 	config := getConfig()
 
-	// Set color mode
-	SetNoColor(noColorFlag)
+	// Set color mode. The prompt banner is colored for the shell even though
+	// its output is captured.
+	setupColor(noColorFlag, forceColor || statusPrompt)
 
 	// Validate flags
 	if showAll && remoteName != "" {
-		return fmt.Errorf("cannot use --all and --remote together")
+		return invalidArgument(fmt.Errorf("cannot use --all and --remote together"))
+	}
+	if staleOnly && showSummary {
+		return invalidArgument(fmt.Errorf("cannot use --stale and --summary together"))
+	}
+	if errorsOnly && (showSummary || staleOnly) {
+		return invalidArgument(fmt.Errorf("cannot use --errors-only with --summary or --stale"))
+	}
+	if statusDelta && (showSummary || staleOnly || errorsOnly) {
+		return invalidArgument(fmt.Errorf("cannot use --delta with --summary, --stale, or --errors-only"))
+	}
+	if showTelemetry && (showSummary || statusDelta || noValidate) {
+		return invalidArgument(fmt.Errorf("cannot use --telemetry with --summary, --delta, or --no-validate"))
+	}
+	if statusPrompt && (jsonOutput || showSummary || staleOnly || errorsOnly || statusDelta || showTelemetry || showAll || remoteName != "" || statusFormat != "") {
+		return invalidArgument(fmt.Errorf("cannot use --prompt with --json, --format, --summary, --stale, --errors-only, --delta, --telemetry, --all, or --remote"))
+	}
+	checking := len(statusChecks) > 0 || statusCheckFile != ""
+	if checking && (statusPrompt || jsonOutput || showSummary || staleOnly || errorsOnly || statusDelta || showTelemetry || showAll || remoteName != "" || statusFormat != "") {
+		return invalidArgument(fmt.Errorf("cannot use --check or --check-file with --prompt, --json, --format, --summary, --stale, --errors-only, --delta, --telemetry, --all, or --remote"))
+	}
+	if _, err := statusTableStyle(tableStyle); err != nil {
+		return invalidArgument(err)
+	}
+	var err error
+	if jsonOutput, err = resolveOutputFormat(jsonOutput, statusFormat, statusOutput); err != nil {
+		return invalidArgument(err)
+	}
+	// Color codes are noise in a file, and in markdown, which is meant to be
+	// pasted elsewhere, unless asked for with --force-color
+	if (statusOutput != "" || tableStyle == "markdown") && !forceColor {
+		SetNoColor(true)
+	}
+
+	if statusPrompt {
+		tmpl, err := parsePromptFormat(statusPromptFormat)
+		if err != nil {
+			return invalidArgument(err)
+		}
+		return writeOutput(statusOutput, func(w io.Writer) error {
+			return runStatusPrompt(ctx, config, tmpl, w)
+		})
+	}
+
+	if checking {
+		rules, err := parseGPULimitRules(statusChecks, statusCheckFile)
+		if err != nil {
+			return invalidArgument(err)
+		}
+		var violations int
+		if err := writeOutput(statusOutput, func(w io.Writer) error {
+			violations, err = runStatusCheck(ctx, config, rules, w)
+			return err
+		}); err != nil {
+			return err
+		}
+		if violations > 0 {
+			return &ExitCodeError{
+				Code:    checkFailedExitCode,
+				Message: fmt.Sprintf("%d user(s) hold more GPUs than allowed", violations),
+			}
+		}
+		return nil
+	}
+
+	return writeOutput(statusOutput, func(w io.Writer) error {
+		// Determine execution mode
+		if statusDelta {
+			return runStatusDelta(ctx, config, w)
+		} else if showAll {
+			return runStatusAllHosts(ctx, config, w)
+		} else if remoteName != "" {
+			return runStatusRemoteHost(ctx, remoteName, w)
+		} else {
+			return runStatusLocal(ctx, config, w)
+		}
+	})
+}
+
+func runStatusLocal(ctx context.Context, config *types.Config, w io.Writer) error {
+	statuses, err := getLocalStatuses(ctx, config)
+	if err != nil {
+		return err
 	}
 
-	// Determine execution mode
-	if showAll {
-		return runStatusAllHosts(ctx, config)
-	} else if remoteName != "" {
-		return runStatusRemoteHost(ctx, remoteName)
+	// Display status in requested format
+	if showSummary {
+		if err := displaySingleHostSummary(w, "localhost", statuses); err != nil {
+			return err
+		}
+		if !jsonOutput {
+			printValidationSkippedNotice(w)
+		}
+	} else if jsonOutput {
+		return displayGPUStatusJSON(w, statuses)
 	} else {
-		return runStatusLocal(ctx, config)
+		displayGPUStatusTable(w, statuses)
+		printValidationSkippedNotice(w)
 	}
+
+	return nil
 }
 
-func runStatusLocal(ctx context.Context, config *types.Config) error {
+// getLocalStatuses cleans up expired reservations and returns the status of
+// the local GPUs, narrowed by --gpu-ids, --stale, and --errors-only
+func getLocalStatuses(ctx context.Context, config *types.Config) ([]gpu.GPUStatusInfo, error) {
 	client := redis_client.NewClient(config)
 	defer func() {
 		if err := client.Close(); err != nil {
@@ -87,8 +303,8 @@ func runStatusLocal(ctx context.Context, config *types.Config) error {
 	}()
 
 	// Test Redis connection
-	if err := client.Ping(ctx); err != nil {
-		return fmt.Errorf("failed to connect to Redis: %v", err)
+	if err := pingRedis(ctx, client); err != nil {
+		return nil, err
 	}
 
 	// Create allocation engine and get status
@@ -96,66 +312,182 @@ func runStatusLocal(ctx context.Context, config *types.Config) error {
 
 	// Clean up expired reservations first
 	if err := engine.CleanupExpiredReservations(ctx); err != nil {
-		fmt.Printf("Warning: Failed to cleanup expired reservations: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to cleanup expired reservations: %v\n", err)
+	}
+
+	statuses, err := getEngineStatus(ctx, engine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU status: %v", err)
+	}
+	statuses, err = applyGPUIDFilter(statuses)
+	if err != nil {
+		return nil, invalidArgument(err)
 	}
+	return applyStatusFilters(statuses), nil
+}
 
+// getEngineStatus returns GPU status, skipping validation if --no-validate
+// was given and adding telemetry if --telemetry was
+func getEngineStatus(ctx context.Context, engine *gpu.AllocationEngine) ([]gpu.GPUStatusInfo, error) {
+	if noValidate {
+		return engine.GetGPUStatusWithoutValidation(ctx)
+	}
 	statuses, err := engine.GetGPUStatus(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get GPU status: %v", err)
+		return nil, err
+	}
+	if showTelemetry {
+		addGPUTelemetry(ctx, engine, statuses)
 	}
+	return statuses, nil
+}
 
-	// Display status in requested format
-	if showSummary {
-		displaySingleHostSummary("localhost", statuses)
-	} else if jsonOutput {
-		return displayGPUStatusJSON(statuses)
-	} else {
-		displayGPUStatusTable(statuses)
+// addGPUTelemetry adds the fan speed and clocks of each GPU to its status.
+// Status is still shown without them if they can't be read.
+func addGPUTelemetry(ctx context.Context, engine *gpu.AllocationEngine, statuses []gpu.GPUStatusInfo) {
+	telemetry, err := engine.GetGPUTelemetry(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read GPU telemetry: %v\n", err)
+		return
+	}
+	for i := range statuses {
+		statuses[i].Telemetry = telemetry[statuses[i].GPUID]
+	}
+}
+
+// applyGPUIDFilter narrows statuses to the GPUs given with --gpu-ids
+func applyGPUIDFilter(statuses []gpu.GPUStatusInfo) ([]gpu.GPUStatusInfo, error) {
+	return filterStatusesByGPUIDs(statuses, statusGPUIDs)
+}
+
+// filterStatusesByGPUIDs returns the statuses of the given GPUs, in GPU
+// order. Every ID must be in the host's pool.
+func filterStatusesByGPUIDs(statuses []gpu.GPUStatusInfo, gpuIDs []int) ([]gpu.GPUStatusInfo, error) {
+	if len(gpuIDs) == 0 {
+		return statuses, nil
 	}
 
-	return nil
+	wanted := make(map[int]bool, len(gpuIDs))
+	for _, gpuID := range gpuIDs {
+		if gpuID < 0 || gpuID >= len(statuses) {
+			return nil, fmt.Errorf("GPU ID %d is out of range (0-%d)", gpuID, len(statuses)-1)
+		}
+		wanted[gpuID] = true
+	}
+
+	filtered := []gpu.GPUStatusInfo{}
+	for _, status := range statuses {
+		if wanted[status.GPUID] {
+			filtered = append(filtered, status)
+		}
+	}
+	return filtered, nil
 }
 
-func runStatusRemoteHost(ctx context.Context, host string) error {
+// applyStatusFilters narrows statuses to stale reservations if --stale was
+// given, or to GPUs needing attention if --errors-only was
+func applyStatusFilters(statuses []gpu.GPUStatusInfo) []gpu.GPUStatusInfo {
+	if staleOnly {
+		return filterStaleStatuses(statuses, time.Now())
+	}
+	if errorsOnly {
+		return filterProblemStatuses(statuses, time.Now())
+	}
+	return statuses
+}
+
+// isStaleHeartbeat reports whether status is a run reservation whose last
+// heartbeat is older than staleHeartbeatFraction of the heartbeat timeout
+func isStaleHeartbeat(status gpu.GPUStatusInfo, now time.Time) bool {
+	if status.Status != "IN_USE" || status.ReservationType != types.ReservationTypeRun || status.LastHeartbeat.IsZero() {
+		return false
+	}
+	threshold := time.Duration(float64(types.HeartbeatTimeout) * staleHeartbeatFraction)
+	return now.Sub(status.LastHeartbeat) > threshold
+}
+
+// filterProblemStatuses returns the GPUs that need attention, in GPU order:
+// those in error, in use without a reservation, or held by a run reservation
+// with a stale heartbeat
+func filterProblemStatuses(statuses []gpu.GPUStatusInfo, now time.Time) []gpu.GPUStatusInfo {
+	problems := []gpu.GPUStatusInfo{}
+	for _, status := range statuses {
+		if status.Status == "ERROR" || status.Status == "UNRESERVED" || isStaleHeartbeat(status, now) {
+			problems = append(problems, status)
+		}
+	}
+	return problems
+}
+
+// filterStaleStatuses returns the run reservations with stale heartbeats,
+// most stale first
+func filterStaleStatuses(statuses []gpu.GPUStatusInfo, now time.Time) []gpu.GPUStatusInfo {
+	stale := []gpu.GPUStatusInfo{}
+	for _, status := range statuses {
+		if isStaleHeartbeat(status, now) {
+			stale = append(stale, status)
+		}
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].LastHeartbeat.Before(stale[j].LastHeartbeat)
+	})
+
+	return stale
+}
+
+// printValidationSkippedNotice tells the user that the displayed status was not validated
+func printValidationSkippedNotice(w io.Writer) {
+	if noValidate {
+		fmt.Fprintln(w, FormatDim("Validation skipped (--no-validate): local GPU usage was not checked and unreserved usage is not shown"))
+	}
+}
+
+func runStatusRemoteHost(ctx context.Context, host string, w io.Writer) error {
 	statuses, err := getRemoteStatus(ctx, host)
 	if err != nil {
-		return fmt.Errorf("failed to get status from %s: %v", host, err)
+		return withCode(ErrCodeRemoteUnreachable, fmt.Errorf("failed to get status from %s: %v", host, err))
 	}
+	statuses, err = applyGPUIDFilter(statuses)
+	if err != nil {
+		return invalidArgument(fmt.Errorf("failed to get status from %s: %v", host, err))
+	}
+	statuses = applyStatusFilters(statuses)
 
 	if showSummary {
-		displaySingleHostSummary(host, statuses)
+		return displaySingleHostSummary(w, host, statuses)
 	} else if jsonOutput {
-		return displayGPUStatusJSON(statuses)
+		return displayGPUStatusJSON(w, statuses)
 	} else {
-		fmt.Printf("Status for %s:\n", host)
-		displayGPUStatusTable(statuses)
+		fmt.Fprintf(w, "Status for %s:\n", host)
+		displayGPUStatusTable(w, statuses)
 	}
 
 	return nil
 }
 
-func runStatusAllHosts(ctx context.Context, config *types.Config) error {
+func runStatusAllHosts(ctx context.Context, config *types.Config, w io.Writer) error {
 	// Check if localhost Redis is available
 	localhostAvail := checkLocalhostAvailable(ctx, config)
 
 	// If localhost is not available and no remote hosts, fail
 	if !localhostAvail && len(config.RemoteHosts) == 0 {
-		return fmt.Errorf("failed to connect to Redis and no remote hosts configured")
+		return withCode(ErrCodeRedisUnreachable, fmt.Errorf("failed to connect to Redis and no remote hosts configured"))
 	}
 
 	// Warn if localhost is not available but we have remote hosts
 	if !localhostAvail {
-		fmt.Println("Warning: Redis not available locally, showing remote hosts only")
-	}
-
-	// JSON mode needs to collect all results first
-	if jsonOutput {
-		return runStatusAllHostsJSON(ctx, config, localhostAvail)
+		fmt.Fprintln(os.Stderr, "Warning: Redis not available locally, showing remote hosts only")
 	}
 
 	// For summary mode, collect all results first then display in table
 	if showSummary {
-		return runStatusAllHostsSummary(ctx, config, localhostAvail)
+		return runStatusAllHostsSummary(ctx, config, localhostAvail, w)
+	}
+
+	// JSON mode needs to collect all results first
+	if jsonOutput {
+		return runStatusAllHostsJSON(ctx, config, localhostAvail, w)
 	}
 
 	// Fetch all host statuses in parallel
@@ -163,65 +495,39 @@ func runStatusAllHosts(ctx context.Context, config *types.Config) error {
 
 	// Display results in order
 	for _, result := range results {
-		fmt.Println()
+		fmt.Fprintln(w)
 		if result.err != nil {
-			fmt.Printf("┌─ %s ─┐\n", FormatHost(result.host))
-			fmt.Printf("│ %s\n", FormatDim(fmt.Sprintf("ERROR: %v", result.err)))
-			fmt.Println("└────────────┘")
+			fmt.Fprintf(w, "┌─ %s ─┐\n", FormatHost(result.host))
+			fmt.Fprintf(w, "│ %s\n", FormatDim(fmt.Sprintf("ERROR: %v", result.err)))
+			fmt.Fprintln(w, "└────────────┘")
 		} else {
-			fmt.Printf("┌─ %s ─┐\n", FormatHost(result.host))
-			displayGPUStatusTable(result.statuses)
+			fmt.Fprintf(w, "┌─ %s ─┐\n", FormatHost(result.host))
+			displayGPUStatusTable(w, applyStatusFilters(result.statuses))
 		}
 	}
 
+	printValidationSkippedNotice(w)
+
 	return nil
 }
 
-// runStatusAllHostsSummary collects all results then displays summary table
-func runStatusAllHostsSummary(ctx context.Context, config *types.Config, localhostAvail bool) error {
+// runStatusAllHostsSummary collects all results then displays the summary
+// table or JSON
+func runStatusAllHostsSummary(ctx context.Context, config *types.Config, localhostAvail bool, w io.Writer) error {
 	// Fetch all host statuses in parallel
-	results := getAllHostStatuses(ctx, config, localhostAvail)
-
-	// Create table
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleLight)
-	t.Style().Options.SeparateRows = false
-	t.Style().Options.DrawBorder = false
-
-	// Set header
-	t.AppendHeader(table.Row{
-		FormatHeader("HOST"),
-		FormatHeader("TOTAL"),
-		FormatHeader("GPU MODELS"),
-		FormatHeader("AVAILABLE"),
-		FormatHeader("IN USE"),
-	})
+	summaries := summarizeHostResults(getAllHostStatuses(ctx, config, localhostAvail))
 
-	// Add rows for all hosts
-	for _, result := range results {
-		if result.err != nil {
-			t.AppendRow(table.Row{
-				FormatHost(result.host),
-				FormatDim("ERR"),
-				FormatDim(fmt.Sprintf("ERROR: %v", result.err)),
-				FormatDim("-"),
-				FormatDim("-"),
-			})
-		} else {
-			addSummaryRow(t, result.host, result.statuses)
-		}
+	if jsonOutput {
+		return displaySummaryJSON(w, summaries)
 	}
-
-	fmt.Println()
-	t.Render()
-	fmt.Println()
+	displaySummaryTable(w, summaries)
+	printValidationSkippedNotice(w)
 
 	return nil
 }
 
 // runStatusAllHostsJSON collects all results then outputs JSON
-func runStatusAllHostsJSON(ctx context.Context, config *types.Config, localhostAvail bool) error {
+func runStatusAllHostsJSON(ctx context.Context, config *types.Config, localhostAvail bool, w io.Writer) error {
 	// Fetch all host statuses in parallel
 	results := getAllHostStatuses(ctx, config, localhostAvail)
 
@@ -231,12 +537,15 @@ func runStatusAllHostsJSON(ctx context.Context, config *types.Config, localhostA
 		if result.err != nil {
 			allStatuses[result.host] = map[string]string{"error": result.err.Error()}
 		} else {
-			allStatuses[result.host] = result.statuses
+			allStatuses[result.host] = applyStatusFilters(result.statuses)
 		}
 	}
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(allStatuses)
+	return encoder.Encode(AllHostsStatusJSON{
+		SchemaVersion: statusJSONSchemaVersion,
+		Hosts:         allStatuses,
+	})
 }
 
 func getLocalStatus(ctx context.Context, config *types.Config) ([]gpu.GPUStatusInfo, error) {
@@ -254,7 +563,7 @@ func getLocalStatus(ctx context.Context, config *types.Config) ([]gpu.GPUStatusI
 	// Cleanup expired reservations
 	_ = engine.CleanupExpiredReservations(ctx)
 
-	return engine.GetGPUStatus(ctx)
+	return getEngineStatus(ctx, engine)
 }
 
 // hostResult holds the status result for a single host
@@ -273,8 +582,8 @@ func checkLocalhostAvailable(ctx context.Context, config *types.Config) bool {
 	return client.Ping(ctx) == nil
 }
 
-// getAllHostStatuses fetches status from localhost and all remote hosts in parallel
-// If includeLocalhost is false, localhost is skipped
+// getAllHostStatuses fetches status from localhost and all remote hosts in parallel,
+// limited to the GPUs given with --gpu-ids. If includeLocalhost is false, localhost is skipped
 func getAllHostStatuses(ctx context.Context, config *types.Config, includeLocalhost bool) []hostResult {
 	// Calculate total hosts
 	totalHosts := len(config.RemoteHosts)
@@ -298,6 +607,9 @@ func getAllHostStatuses(ctx context.Context, config *types.Config, includeLocalh
 		go func() {
 			defer wg.Done()
 			statuses, err := getLocalStatus(ctx, config)
+			if err == nil {
+				statuses, err = applyGPUIDFilter(statuses)
+			}
 			results[0] = hostResult{
 				host:     "localhost",
 				statuses: statuses,
@@ -308,10 +620,13 @@ func getAllHostStatuses(ctx context.Context, config *types.Config, includeLocalh
 	}
 
 	// Fetch each remote host status in parallel
-	for i, host := range config.RemoteHosts {
+	for i, host := range config.RemoteHostNames() {
 		go func(index int, h string) {
 			defer wg.Done()
 			statuses, err := getRemoteStatus(ctx, h)
+			if err == nil {
+				statuses, err = applyGPUIDFilter(statuses)
+			}
 			results[index] = hostResult{
 				host:     h,
 				statuses: statuses,
@@ -326,7 +641,11 @@ func getAllHostStatuses(ctx context.Context, config *types.Config, includeLocalh
 
 func getRemoteStatus(ctx context.Context, host string) ([]gpu.GPUStatusInfo, error) {
 	// Execute remote status command with JSON output
-	stdout, stderr, err := utils.ExecuteRemoteCanHazGPU(ctx, host, []string{"status", "--json"})
+	args := []string{"status", "--json"}
+	if showTelemetry {
+		args = append(args, "--telemetry")
+	}
+	stdout, stderr, err := utils.ExecuteRemoteCanHazGPU(ctx, getConfig().LookupRemoteHost(host), args)
 	if err != nil {
 		if stderr != "" {
 			return nil, fmt.Errorf("%v: %s", err, stderr)
@@ -334,10 +653,9 @@ func getRemoteStatus(ctx context.Context, host string) ([]gpu.GPUStatusInfo, err
 		return nil, err
 	}
 
-	// Parse JSON output
-	var statuses []JSONGPUStatus
-	if err := json.Unmarshal([]byte(stdout), &statuses); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON output: %v", err)
+	statuses, err := parseStatusJSON([]byte(stdout))
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert to GPUStatusInfo
@@ -373,16 +691,18 @@ func getRemoteStatus(ctx context.Context, host string) ([]gpu.GPUStatusInfo, err
 
 // getRemoteGPUModel tries to detect GPU model directly via nvidia-smi or amd-smi
 func getRemoteGPUModel(ctx context.Context, host string) string {
+	config := getConfig()
+	nvidiaCommand, amdCommand := remoteGPUModelCommands(config)
+	remote := config.LookupRemoteHost(host)
+
 	// Try nvidia-smi first
-	stdout, _, err := utils.ExecuteRemoteCommand(ctx, host,
-		"nvidia-smi --query-gpu=gpu_name --format=csv,noheader,nounits | head -1")
+	stdout, _, err := utils.ExecuteRemoteCommand(ctx, remote, nvidiaCommand)
 	if err == nil && stdout != "" {
 		return strings.TrimSpace(stdout)
 	}
 
 	// Try amd-smi
-	stdout, _, err = utils.ExecuteRemoteCommand(ctx, host,
-		"amd-smi list --json 2>/dev/null | jq -r '.[0].name' 2>/dev/null")
+	stdout, _, err = utils.ExecuteRemoteCommand(ctx, remote, amdCommand)
 	if err == nil && stdout != "" && stdout != "null" {
 		return strings.TrimSpace(stdout)
 	}
@@ -390,12 +710,31 @@ func getRemoteGPUModel(ctx context.Context, host string) string {
 	return ""
 }
 
+// remoteGPUModelCommands returns the shell commands that print the GPU model
+// on a remote host, running the SMI tools at the configured paths, which are
+// assumed to be the same across hosts
+func remoteGPUModelCommands(config *types.Config) (string, string) {
+	nvidiaSMI, amdSMI := "nvidia-smi", "amd-smi"
+	if config.NvidiaSMIPath != "" {
+		nvidiaSMI = utils.ShellQuote(config.NvidiaSMIPath)
+	}
+	if config.AMDSMIPath != "" {
+		amdSMI = utils.ShellQuote(config.AMDSMIPath)
+	}
+	return nvidiaSMI + " --query-gpu=gpu_name --format=csv,noheader,nounits | head -1",
+		amdSMI + " list --json 2>/dev/null | jq -r '.[0].name' 2>/dev/null"
+}
+
 func convertJSONToStatusInfo(j JSONGPUStatus) gpu.GPUStatusInfo {
 	status := gpu.GPUStatusInfo{
-		GPUID:  j.GPUID,
-		Status: j.Status,
-		User:   j.User,
-		Note:   j.Note,
+		GPUID:    j.GPUID,
+		Status:   j.Status,
+		User:     j.User,
+		Note:     j.Note,
+		Source:   j.Source,
+		Priority: j.Priority,
+		Account:  j.Account,
+		Command:  j.Command,
 	}
 
 	// Parse duration if present
@@ -408,8 +747,10 @@ func convertJSONToStatusInfo(j JSONGPUStatus) gpu.GPUStatusInfo {
 
 	status.ReservationType = strings.ToLower(j.ReservationType)
 	status.ValidationInfo = j.ValidationInfo
+	status.Utilization = j.Utilization
 	status.ProcessInfo = j.ProcessInfo
 	status.UnreservedUsers = j.UnreservedUsers
+	status.UnreservedOwners = j.UnreservedOwners
 	status.Error = j.Error
 
 	if j.LastReleased != nil {
@@ -421,6 +762,20 @@ func convertJSONToStatusInfo(j JSONGPUStatus) gpu.GPUStatusInfo {
 	if j.ExpiryTime != nil {
 		status.ExpiryTime = *j.ExpiryTime
 	}
+	status.Renewable = j.Renewable
+	status.JobID = j.JobID
+	status.Shares = j.Shares
+	status.MaxShares = j.MaxShares
+	status.Annotation = j.Annotation
+	status.AnnotatedBy = j.AnnotatedBy
+	if j.AnnotatedAt != nil {
+		status.AnnotatedAt = *j.AnnotatedAt
+	}
+	if j.StartTime != nil {
+		status.StartTime = *j.StartTime
+	}
+	status.PIDs = j.PIDs
+	status.Processes = j.Processes
 
 	if j.ModelInfo != nil {
 		status.ModelInfo = &gpu.ModelInfo{
@@ -430,17 +785,25 @@ func convertJSONToStatusInfo(j JSONGPUStatus) gpu.GPUStatusInfo {
 	}
 
 	status.GPUModel = j.GPUModel
+	status.UUID = j.UUID
+	status.Telemetry = j.GPUTelemetry
 
 	return status
 }
 
-func displaySingleHostSummary(host string, statuses []gpu.GPUStatusInfo) {
+func displaySingleHostSummary(w io.Writer, host string, statuses []gpu.GPUStatusInfo) error {
+	summaries := []hostSummary{summarizeHost(host, statuses)}
+	if jsonOutput {
+		return displaySummaryJSON(w, summaries)
+	}
+	displaySummaryTable(w, summaries)
+	return nil
+}
+
+// displaySummaryTable shows a row of GPU counts for each host
+func displaySummaryTable(w io.Writer, summaries []hostSummary) {
 	// Create table
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleLight)
-	t.Style().Options.SeparateRows = false
-	t.Style().Options.DrawBorder = false
+	t := newStatusTable(w)
 
 	// Set header
 	t.AppendHeader(table.Row{
@@ -451,84 +814,167 @@ func displaySingleHostSummary(host string, statuses []gpu.GPUStatusInfo) {
 		FormatHeader("IN USE"),
 	})
 
-	// Add single row
-	addSummaryRow(t, host, statuses)
+	for _, summary := range summaries {
+		if summary.Error != "" {
+			t.AppendRow(table.Row{
+				FormatHost(summary.Host),
+				FormatDim("ERR"),
+				FormatDim(fmt.Sprintf("ERROR: %s", summary.Error)),
+				FormatDim("-"),
+				FormatDim("-"),
+			})
+		} else {
+			addSummaryRow(t, summary)
+		}
+	}
+
+	fmt.Fprintln(w)
+	renderStatusTable(t)
+	fmt.Fprintln(w)
+}
 
-	fmt.Println()
-	t.Render()
-	fmt.Println()
+// displaySummaryJSON writes the --summary --json output
+func displaySummaryJSON(w io.Writer, summaries []hostSummary) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newStatusSummaryJSON(summaries))
+}
+
+// summaryCounts are the GPU counts shown by --summary
+type summaryCounts struct {
+	Total     int            `json:"total"`
+	Available int            `json:"available"`
+	InUse     int            `json:"in_use"`
+	GPUModels map[string]int `json:"gpu_models"` // Number of GPUs of each model, if known
 }
 
-func addSummaryRow(t table.Writer, host string, statuses []gpu.GPUStatusInfo) {
-	totalGPUs := len(statuses)
-	availableCount := 0
-	inUseCount := 0
+// hostSummary is the --summary of one host. Error is set instead of the
+// counts if the host's status couldn't be read.
+type hostSummary struct {
+	Host string `json:"host"`
+	*summaryCounts
+	Error string `json:"error,omitempty"`
+}
 
-	// Count by GPU model type
-	modelCounts := make(map[string]int)
+// StatusSummaryJSON is the JSON output of 'status --summary', with Totals
+// over the hosts that could be read
+type StatusSummaryJSON struct {
+	SchemaVersion int           `json:"schema_version"`
+	Hosts         []hostSummary `json:"hosts"`
+	Totals        summaryCounts `json:"totals"`
+}
 
-	for _, status := range statuses {
-		// Get GPU model from provider info
-		gpuModel := ""
-		if status.GPUModel != "" {
-			gpuModel = status.GPUModel
+// newStatusSummaryJSON adds up the host summaries
+func newStatusSummaryJSON(summaries []hostSummary) StatusSummaryJSON {
+	output := StatusSummaryJSON{
+		SchemaVersion: statusJSONSchemaVersion,
+		Hosts:         summaries,
+		Totals:        summaryCounts{GPUModels: map[string]int{}},
+	}
+	for _, summary := range summaries {
+		if summary.summaryCounts == nil {
+			continue
+		}
+		output.Totals.Total += summary.Total
+		output.Totals.Available += summary.Available
+		output.Totals.InUse += summary.InUse
+		for model, count := range summary.GPUModels {
+			output.Totals.GPUModels[model] += count
 		}
+	}
+	return output
+}
 
-		if gpuModel != "" {
-			modelCounts[gpuModel]++
+// summarizeHost counts the GPUs of a host by status and model
+func summarizeHost(host string, statuses []gpu.GPUStatusInfo) hostSummary {
+	summary := hostSummary{
+		Host:          host,
+		summaryCounts: &summaryCounts{Total: len(statuses), GPUModels: map[string]int{}},
+	}
+
+	for _, status := range statuses {
+		if status.GPUModel != "" {
+			summary.GPUModels[status.GPUModel]++
 		}
 
 		switch status.Status {
 		case "AVAILABLE":
-			availableCount++
+			summary.Available++
 		case "IN_USE", "UNRESERVED":
 			// Combine reserved and unreserved usage into IN_USE
-			inUseCount++
+			summary.InUse++
 		}
 	}
 
+	return summary
+}
+
+// summarizeHostResults summarizes each host, or records why it couldn't be
+// read
+func summarizeHostResults(results []hostResult) []hostSummary {
+	summaries := make([]hostSummary, 0, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			summaries = append(summaries, hostSummary{Host: result.host, Error: result.err.Error()})
+		} else {
+			summaries = append(summaries, summarizeHost(result.host, result.statuses))
+		}
+	}
+	return summaries
+}
+
+func addSummaryRow(t table.Writer, summary hostSummary) {
 	// Build GPU models string
 	var modelsStr string
-	if len(modelCounts) == 0 {
+	if len(summary.GPUModels) == 0 {
 		modelsStr = "-"
-	} else if len(modelCounts) == 1 {
-		for model := range modelCounts {
+	} else if len(summary.GPUModels) == 1 {
+		for model := range summary.GPUModels {
 			modelsStr = model
 		}
 	} else {
-		models := make([]string, 0, len(modelCounts))
-		for model := range modelCounts {
+		models := make([]string, 0, len(summary.GPUModels))
+		for model := range summary.GPUModels {
 			models = append(models, model)
 		}
 		sort.Strings(models)
 		var parts []string
 		for _, model := range models {
-			parts = append(parts, fmt.Sprintf("%d %s", modelCounts[model], model))
+			parts = append(parts, fmt.Sprintf("%d %s", summary.GPUModels[model], model))
 		}
 		modelsStr = strings.Join(parts, ", ")
 	}
 
 	// Format available column: show checkmark if > 0, X if 0
 	var availStr string
-	if availableCount > 0 {
-		availStr = fmt.Sprintf("%s %d", colorSuccess.Sprint("✓"), availableCount)
+	if summary.Available > 0 {
+		availStr = fmt.Sprintf("%s %d", colorSuccess.Sprint("✓"), summary.Available)
 	} else {
-		availStr = fmt.Sprintf("%s %d", colorError.Sprint("✗"), availableCount)
+		availStr = fmt.Sprintf("%s %d", colorError.Sprint("✗"), summary.Available)
 	}
 
 	// Format in-use column: just the number, no symbol
-	inUseStr := fmt.Sprintf("%d", inUseCount)
+	inUseStr := fmt.Sprintf("%d", summary.InUse)
 
 	t.AppendRow(table.Row{
-		FormatHost(host),
-		FormatMetric(totalGPUs),
+		FormatHost(summary.Host),
+		FormatMetric(summary.Total),
 		FormatDim(modelsStr),
 		availStr,
 		inUseStr,
 	})
 }
 
-func displayGPUStatusTable(statuses []gpu.GPUStatusInfo) {
+func displayGPUStatusTable(w io.Writer, statuses []gpu.GPUStatusInfo) {
+	if staleOnly && len(statuses) == 0 {
+		fmt.Fprintln(w, "No run reservations with stale heartbeats.")
+		return
+	}
+	if errorsOnly && len(statuses) == 0 {
+		fmt.Fprintln(w, "No GPUs need attention.")
+		return
+	}
+
 	// Check if any GPU has model information
 	hasModels := false
 	for _, status := range statuses {
@@ -539,38 +985,260 @@ func displayGPUStatusTable(statuses []gpu.GPUStatusInfo) {
 	}
 
 	// Create table
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-
-	// Set style
-	t.SetStyle(table.StyleLight)
-	t.Style().Options.SeparateRows = false
-	t.Style().Options.DrawBorder = false
+	t := newStatusTable(w)
 
 	// Set header
+	var header table.Row
 	if hasModels {
-		t.AppendHeader(table.Row{
+		header = table.Row{
 			FormatHeader("GPU"), FormatHeader("STATUS"), FormatHeader("USER"),
 			FormatHeader("DURATION"), FormatHeader("TYPE"), FormatHeader("DETAILS"),
 			FormatHeader("VALIDATION"), FormatHeader("MODEL"), FormatHeader("NOTE"),
-		})
+		}
 	} else {
-		t.AppendHeader(table.Row{
+		header = table.Row{
 			FormatHeader("GPU"), FormatHeader("STATUS"), FormatHeader("USER"),
 			FormatHeader("DURATION"), FormatHeader("TYPE"), FormatHeader("DETAILS"),
 			FormatHeader("VALIDATION"), FormatHeader("NOTE"),
-		})
+		}
 	}
+	if wideOutput {
+		header = append(header,
+			FormatHeader("GPU MODEL"), FormatHeader("PIDS"), FormatHeader("STARTED"),
+			FormatHeader("PRIORITY"), FormatHeader("SOURCE"), FormatHeader("COMMAND"),
+		)
+	} else if showPIDs {
+		header = append(header, FormatHeader("PIDS"))
+	}
+	if showTelemetry {
+		header = append(header, FormatHeader("FAN"), FormatHeader("SM CLOCK"), FormatHeader("MEM CLOCK"))
+	}
+	t.AppendHeader(header)
 
 	// Add rows
 	for _, status := range statuses {
-		addGPUStatusRow(t, status, hasModels)
+		row := gpuStatusRow(status, hasModels)
+		if wideOutput {
+			row = append(row, wideStatusColumns(status, showPIDs)...)
+		} else if showPIDs {
+			row = append(row, statusPIDs(status, true))
+		}
+		if showTelemetry {
+			row = append(row, telemetryColumns(status)...)
+		}
+		t.AppendRow(row)
 	}
 
+	renderStatusTable(t)
+}
+
+// newStatusTable returns a table writer for status output, drawn in the
+// style selected with --table-style, written to w
+func newStatusTable(w io.Writer) table.Writer {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+
+	style, err := statusTableStyle(tableStyle)
+	if err != nil {
+		style, _ = statusTableStyle("light")
+	}
+	t.SetStyle(style)
+
+	return t
+}
+
+// renderStatusTable writes a table created by newStatusTable to stdout
+func renderStatusTable(t table.Writer) {
+	if tableStyle == "markdown" {
+		t.RenderMarkdown()
+		return
+	}
 	t.Render()
 }
 
+// statusTableStyle returns the go-pretty style for a --table-style value.
+// The markdown style only affects the separators; the table must also be
+// rendered with RenderMarkdown.
+func statusTableStyle(name string) (table.Style, error) {
+	var style table.Style
+	switch name {
+	case "light":
+		style = table.StyleLight
+		style.Options.DrawBorder = false
+		style.Options.SeparateRows = false
+	case "ascii":
+		style = table.StyleDefault
+		style.Options.DrawBorder = false
+		style.Options.SeparateRows = false
+	case "markdown":
+		style = table.StyleDefault
+	case "compact":
+		style = table.StyleDefault
+		style.Options = table.OptionsNoBordersAndSeparators
+		style.Box.PaddingLeft = ""
+		style.Box.PaddingRight = "  "
+	default:
+		return table.Style{}, fmt.Errorf("invalid table style '%s': must be light, ascii, markdown, or compact", name)
+	}
+	return style, nil
+}
+
+// wideCommandWidth is how much of a run reservation's command line the
+// 'status --wide' table shows; --json has all of it
+const wideCommandWidth = 40
+
+// wideStatusColumns returns the extra columns shown by 'status --wide',
+// with process names in the PIDS column if withNames is set
+func wideStatusColumns(status gpu.GPUStatusInfo, withNames bool) table.Row {
+	gpuModel := FormatDim("-")
+	if status.GPUModel != "" {
+		gpuModel = status.GPUModel
+	}
+
+	pids := statusPIDs(status, withNames)
+
+	started := FormatDim("-")
+	if !status.StartTime.IsZero() {
+		started = status.StartTime.Local().Format("2006-01-02 15:04:05")
+	}
+
+	priority := FormatDim("-")
+	if status.Priority != "" {
+		priority = status.Priority
+	}
+
+	source := FormatDim("-")
+	if status.Source != "" {
+		source = status.Source
+	}
+
+	command := FormatDim("-")
+	if status.Command != "" {
+		command = utils.TruncateString(status.Command, wideCommandWidth)
+	}
+
+	return table.Row{gpuModel, pids, started, priority, source, command}
+}
+
+// telemetryColumns returns the fan speed and clock columns shown by
+// 'status --telemetry', e.g. "45%", "1980 MHz", "2619 MHz"
+func telemetryColumns(status gpu.GPUStatusInfo) table.Row {
+	var telemetry types.GPUTelemetry
+	if status.Telemetry != nil {
+		telemetry = *status.Telemetry
+	}
+	return table.Row{
+		formatTelemetryValue(telemetry.FanSpeed, "%"),
+		formatTelemetryValue(telemetry.SMClockMHz, " MHz"),
+		formatTelemetryValue(telemetry.MemClockMHz, " MHz"),
+	}
+}
+
+// formatTelemetryValue formats a telemetry value with its unit, or a dimmed
+// "-" if it wasn't reported
+func formatTelemetryValue(value *int, unit string) string {
+	if value == nil {
+		return FormatDim("-")
+	}
+	return fmt.Sprintf("%d%s", *value, unit)
+}
+
+// statusPIDs lists the PIDs of the processes using a GPU, as "1234,5678" or,
+// if withNames is set, "1234 (python), 5678 (vllm)". Statuses from a remote
+// host running an older version only have the PIDs.
+func statusPIDs(status gpu.GPUStatusInfo, withNames bool) string {
+	if withNames && len(status.Processes) > 0 {
+		procStrs := make([]string, len(status.Processes))
+		for i, proc := range status.Processes {
+			procStrs[i] = fmt.Sprintf("%d", proc.PID)
+			if proc.ProcessName != "" {
+				procStrs[i] += fmt.Sprintf(" (%s)", proc.ProcessName)
+			}
+		}
+		return strings.Join(procStrs, ", ")
+	}
+
+	if len(status.PIDs) == 0 {
+		return FormatDim("-")
+	}
+	pidStrs := make([]string, len(status.PIDs))
+	for i, pid := range status.PIDs {
+		pidStrs[i] = fmt.Sprintf("%d", pid)
+	}
+	return strings.Join(pidStrs, ",")
+}
+
 func addGPUStatusRow(t table.Writer, status gpu.GPUStatusInfo, includeModel bool) {
+	t.AppendRow(gpuStatusRow(status, includeModel))
+}
+
+// expiryDetails describes when a manual reservation expires
+func expiryDetails(status gpu.GPUStatusInfo) string {
+	details := fmt.Sprintf("expires %s", utils.FormatTimeUntil(status.ExpiryTime))
+	if status.Renewable {
+		details += " (renewable)"
+	}
+	return details
+}
+
+// sharedDetails describes a shared GPU's holders for the details column, e.g.
+// "shared 2/2, first expires in 1h 0m 0s"
+func sharedDetails(status gpu.GPUStatusInfo) string {
+	details := fmt.Sprintf("shared %d/%d", len(status.Shares), status.MaxShares)
+	if !status.ExpiryTime.IsZero() {
+		details += fmt.Sprintf(", first expires %s", utils.FormatTimeUntil(status.ExpiryTime))
+	}
+	return details
+}
+
+// formatStatusNote formats the NOTE column of a reservation: the user's note
+// followed by an admin's annotation, e.g. "training [admin bob: will expire
+// tonight]"
+func formatStatusNote(status gpu.GPUStatusInfo) string {
+	var parts []string
+	if status.Note != "" {
+		parts = append(parts, status.Note)
+	}
+	if status.Annotation != "" {
+		by := "admin"
+		if status.AnnotatedBy != "" {
+			by += " " + status.AnnotatedBy
+		}
+		parts = append(parts, colorWarning.Sprintf("[%s: %s]", by, status.Annotation))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+// reservationTypeLabel returns the TYPE column of a reservation. The source is
+// added when the type doesn't already say how the reservation was made, e.g.
+// "MANUAL (adopted)" for GPUs claimed with --force or "MANUAL (schedule)".
+func reservationTypeLabel(status gpu.GPUStatusInfo) string {
+	label := strings.ToUpper(status.ReservationType)
+	switch status.Source {
+	case "", types.ReservationSourceRun, types.ReservationSourceReserve:
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", label, status.Source)
+}
+
+// unreservedUserLabels returns the users of a GPU used without a reservation,
+// with service accounts shown as "account (owner)"
+func unreservedUserLabels(status gpu.GPUStatusInfo) []string {
+	labels := make([]string, 0, len(status.UnreservedUsers))
+	for _, user := range status.UnreservedUsers {
+		if owner, ok := status.UnreservedOwners[user]; ok {
+			user = fmt.Sprintf("%s (%s)", user, owner)
+		}
+		labels = append(labels, user)
+	}
+	return labels
+}
+
+// gpuStatusRow builds the default status table row for a GPU
+func gpuStatusRow(status gpu.GPUStatusInfo, includeModel bool) table.Row {
 	gpuID := fmt.Sprintf("%d", status.GPUID)
 
 	switch status.Status {
@@ -593,21 +1261,21 @@ func addGPUStatusRow(t table.Writer, status gpu.GPUStatusInfo, includeModel bool
 		}
 
 		if includeModel {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("AVAILABLE"), FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				details, FormatDim(validation), model, FormatDim("-"),
-			})
+			}
 		} else {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("AVAILABLE"), FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				details, FormatDim(validation), FormatDim("-"),
-			})
+			}
 		}
 
 	case "IN_USE":
 		user := status.User
 		duration := utils.FormatDuration(status.Duration)
-		reservationType := strings.ToUpper(status.ReservationType)
+		reservationType := reservationTypeLabel(status)
 
 		var details string
 		switch status.ReservationType {
@@ -619,10 +1287,12 @@ func addGPUStatusRow(t table.Writer, status gpu.GPUStatusInfo, includeModel bool
 			}
 		case "manual":
 			if !status.ExpiryTime.IsZero() {
-				details = fmt.Sprintf("expires %s", utils.FormatTimeUntil(status.ExpiryTime))
+				details = expiryDetails(status)
 			} else {
 				details = "manual reservation"
 			}
+		case "shared":
+			details = sharedDetails(status)
 		}
 
 		// Clean validation info
@@ -635,24 +1305,20 @@ func addGPUStatusRow(t table.Writer, status gpu.GPUStatusInfo, includeModel bool
 			model = status.ModelInfo.Model
 		}
 
-		// Format note
-		note := "-"
-		if status.Note != "" {
-			note = status.Note
-		}
+		note := formatStatusNote(status)
 
 		if includeModel {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("IN_USE"), user, duration, reservationType, details, FormatDim(validation), model, note,
-			})
+			}
 		} else {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("IN_USE"), user, duration, reservationType, details, FormatDim(validation), note,
-			})
+			}
 		}
 
 	case "UNRESERVED":
-		userList := utils.FormatUserList(status.UnreservedUsers, 2)
+		userList := utils.FormatUserList(unreservedUserLabels(status), 2)
 		details := status.ProcessInfo
 
 		// Set model info
@@ -662,63 +1328,125 @@ func addGPUStatusRow(t table.Writer, status gpu.GPUStatusInfo, includeModel bool
 		}
 
 		if includeModel {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("UNRESERVED"), userList, FormatDim("-"), FormatDim("-"),
 				details, FormatDim("-"), model, FormatDim("-"),
-			})
+			}
 		} else {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("UNRESERVED"), userList, FormatDim("-"), FormatDim("-"),
 				details, FormatDim("-"), FormatDim("-"),
-			})
+			}
 		}
 
 	case "ERROR":
 		if includeModel {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("ERROR"), FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				status.Error, FormatDim("-"), FormatDim("-"), FormatDim("-"),
-			})
+			}
 		} else {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, FormatStatus("ERROR"), FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				status.Error, FormatDim("-"), FormatDim("-"),
-			})
+			}
 		}
 
 	default:
 		if includeModel {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, "UNKNOWN", FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				"unknown status", FormatDim("-"), FormatDim("-"), FormatDim("-"),
-			})
+			}
 		} else {
-			t.AppendRow(table.Row{
+			return table.Row{
 				gpuID, "UNKNOWN", FormatDim("-"), FormatDim("-"), FormatDim("-"),
 				"unknown status", FormatDim("-"), FormatDim("-"),
-			})
+			}
 		}
 	}
 }
 
+// statusJSONSchemaVersion is the version of the 'status --json' output. Adding
+// fields doesn't change it, so consumers should ignore fields they don't
+// know. It is incremented when a field is removed or renamed, or its type or
+// meaning changes.
+const statusJSONSchemaVersion = 1
+
+// StatusJSON is the JSON output structure for the status command
+type StatusJSON struct {
+	SchemaVersion int             `json:"schema_version"`
+	GPUs          []JSONGPUStatus `json:"gpus"`
+}
+
+// AllHostsStatusJSON is the JSON output structure for 'status --all', keyed by
+// host
+type AllHostsStatusJSON struct {
+	SchemaVersion int            `json:"schema_version"`
+	Hosts         map[string]any `json:"hosts"`
+}
+
+// parseStatusJSON parses 'status --json' output from another canhazgpu,
+// including the bare array printed by versions before schema versioning
+func parseStatusJSON(data []byte) ([]JSONGPUStatus, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var statuses []JSONGPUStatus
+		if err := json.Unmarshal(trimmed, &statuses); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON output: %v", err)
+		}
+		return statuses, nil
+	}
+
+	var output StatusJSON
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON output: %v", err)
+	}
+	if output.SchemaVersion > statusJSONSchemaVersion {
+		return nil, fmt.Errorf("unsupported status JSON schema version %d (upgrade canhazgpu to read it)", output.SchemaVersion)
+	}
+	return output.GPUs, nil
+}
+
 // JSONGPUStatus represents a GPU status for JSON output
 type JSONGPUStatus struct {
-	GPUID           int            `json:"gpu_id"`
-	Status          string         `json:"status"`
-	User            string         `json:"user,omitempty"`
-	Duration        string         `json:"duration,omitempty"`
-	ReservationType string         `json:"type,omitempty"`
-	Note            string         `json:"note,omitempty"`
-	Details         string         `json:"details,omitempty"`
-	ValidationInfo  string         `json:"validation,omitempty"`
-	ModelInfo       *JSONModelInfo `json:"model,omitempty"`
-	GPUModel        string         `json:"gpu_model,omitempty"`
-	LastReleased    *time.Time     `json:"last_released,omitempty"`
-	LastHeartbeat   *time.Time     `json:"last_heartbeat,omitempty"`
-	ExpiryTime      *time.Time     `json:"expiry_time,omitempty"`
-	UnreservedUsers []string       `json:"unreserved_users,omitempty"`
-	ProcessInfo     string         `json:"process_info,omitempty"`
-	Error           string         `json:"error,omitempty"`
+	GPUID           int                    `json:"gpu_id"`
+	Status          string                 `json:"status"`
+	User            string                 `json:"user,omitempty"`
+	Duration        string                 `json:"duration,omitempty"`
+	ReservationType string                 `json:"type,omitempty"`
+	Note            string                 `json:"note,omitempty"`
+	Source          string                 `json:"source,omitempty"`
+	Priority        string                 `json:"priority,omitempty"`
+	Account         string                 `json:"account,omitempty"`
+	StartTime       *time.Time             `json:"start_time,omitempty"`
+	PIDs            []int                  `json:"pids,omitempty"`
+	Processes       []types.GPUProcessInfo `json:"processes,omitempty"`
+	Details         string                 `json:"details,omitempty"`
+	ValidationInfo  string                 `json:"validation,omitempty"`
+	Utilization     *int                   `json:"utilization,omitempty"`
+	ModelInfo       *JSONModelInfo         `json:"model,omitempty"`
+	GPUModel        string                 `json:"gpu_model,omitempty"`
+	UUID            string                 `json:"uuid,omitempty"`
+	LastReleased    *time.Time             `json:"last_released,omitempty"`
+	LastHeartbeat   *time.Time             `json:"last_heartbeat,omitempty"`
+	HeartbeatAge    *int64                 `json:"heartbeat_age_seconds,omitempty"` // Whole seconds since the last heartbeat of a run reservation
+	ExpiryTime      *time.Time             `json:"expiry_time,omitempty"`
+	Renewable       bool                   `json:"renewable,omitempty"`
+	JobID           string                 `json:"job_id,omitempty"`
+	Command         string                 `json:"command,omitempty"`    // Command line of a run reservation, with secrets redacted
+	Shares          []types.GPUShare       `json:"shares,omitempty"`     // Holders of a shared GPU
+	MaxShares       int                    `json:"max_shares,omitempty"` // How many holders a shared GPU can have
+	Annotation      string                 `json:"annotation,omitempty"` // Note left on the reservation with 'admin --annotate'
+	AnnotatedBy     string                 `json:"annotated_by,omitempty"`
+	AnnotatedAt     *time.Time             `json:"annotated_at,omitempty"`
+
+	// Fan speed and clocks, only with --telemetry
+	*types.GPUTelemetry
+
+	UnreservedUsers  []string          `json:"unreserved_users,omitempty"`
+	UnreservedOwners map[string]string `json:"unreserved_owners,omitempty"` // Owners responsible for the service accounts among unreserved_users
+	ProcessInfo      string            `json:"process_info,omitempty"`
+	Error            string            `json:"error,omitempty"`
 }
 
 // JSONModelInfo represents model information for JSON output
@@ -727,7 +1455,15 @@ type JSONModelInfo struct {
 	Model    string `json:"model"`
 }
 
-func displayGPUStatusJSON(statuses []gpu.GPUStatusInfo) error {
+func displayGPUStatusJSON(w io.Writer, statuses []gpu.GPUStatusInfo) error {
+	// Output as pretty-printed JSON
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(newStatusJSON(statuses))
+}
+
+// newStatusJSON converts GPU statuses to the 'status --json' output structure
+func newStatusJSON(statuses []gpu.GPUStatusInfo) StatusJSON {
 	jsonStatuses := make([]JSONGPUStatus, len(statuses))
 
 	for i, status := range statuses {
@@ -753,6 +1489,46 @@ func displayGPUStatusJSON(statuses []gpu.GPUStatusInfo) error {
 			jsonStatus.Note = status.Note
 		}
 
+		if status.Source != "" {
+			jsonStatus.Source = status.Source
+		}
+
+		if status.Priority != "" {
+			jsonStatus.Priority = status.Priority
+		}
+
+		if status.Account != "" {
+			jsonStatus.Account = status.Account
+		}
+
+		if status.JobID != "" {
+			jsonStatus.JobID = status.JobID
+		}
+
+		if status.Command != "" {
+			jsonStatus.Command = status.Command
+		}
+
+		if status.Annotation != "" {
+			jsonStatus.Annotation = status.Annotation
+			jsonStatus.AnnotatedBy = status.AnnotatedBy
+			if !status.AnnotatedAt.IsZero() {
+				jsonStatus.AnnotatedAt = &status.AnnotatedAt
+			}
+		}
+
+		if !status.StartTime.IsZero() {
+			jsonStatus.StartTime = &status.StartTime
+		}
+
+		if len(status.PIDs) > 0 {
+			jsonStatus.PIDs = status.PIDs
+		}
+
+		if len(status.Processes) > 0 {
+			jsonStatus.Processes = status.Processes
+		}
+
 		// Add details based on status type
 		switch status.Status {
 		case "AVAILABLE":
@@ -769,22 +1545,31 @@ func displayGPUStatusJSON(statuses []gpu.GPUStatusInfo) error {
 				if !status.LastHeartbeat.IsZero() {
 					jsonStatus.Details = fmt.Sprintf("heartbeat %s", utils.FormatTimeAgo(status.LastHeartbeat))
 					jsonStatus.LastHeartbeat = &status.LastHeartbeat
+					heartbeatAge := int64(time.Since(status.LastHeartbeat).Seconds())
+					jsonStatus.HeartbeatAge = &heartbeatAge
 				} else {
 					jsonStatus.Details = "active"
 				}
 			case "manual":
 				if !status.ExpiryTime.IsZero() {
-					jsonStatus.Details = fmt.Sprintf("expires %s", utils.FormatTimeUntil(status.ExpiryTime))
+					jsonStatus.Details = expiryDetails(status)
 					jsonStatus.ExpiryTime = &status.ExpiryTime
+					jsonStatus.Renewable = status.Renewable
 				} else {
 					jsonStatus.Details = "manual reservation"
 				}
+			case "shared":
+				jsonStatus.Details = sharedDetails(status)
+				jsonStatus.ExpiryTime = &status.ExpiryTime
+				jsonStatus.Shares = status.Shares
+				jsonStatus.MaxShares = status.MaxShares
 			}
 
 		case "UNRESERVED":
 			jsonStatus.Details = "WITHOUT RESERVATION"
 			if len(status.UnreservedUsers) > 0 {
 				jsonStatus.UnreservedUsers = status.UnreservedUsers
+				jsonStatus.UnreservedOwners = status.UnreservedOwners
 			}
 			if status.ProcessInfo != "" {
 				jsonStatus.ProcessInfo = status.ProcessInfo
@@ -806,6 +1591,7 @@ func displayGPUStatusJSON(statuses []gpu.GPUStatusInfo) error {
 			validation = strings.TrimPrefix(validation, "validated: ")
 			jsonStatus.ValidationInfo = validation
 		}
+		jsonStatus.Utilization = status.Utilization
 
 		// Add model info if present
 		if status.ModelInfo != nil && status.ModelInfo.Model != "" {
@@ -819,12 +1605,14 @@ func displayGPUStatusJSON(statuses []gpu.GPUStatusInfo) error {
 		if status.GPUModel != "" {
 			jsonStatus.GPUModel = status.GPUModel
 		}
+		jsonStatus.UUID = status.UUID
+		jsonStatus.GPUTelemetry = status.Telemetry
 
 		jsonStatuses[i] = jsonStatus
 	}
 
-	// Output as pretty-printed JSON
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(jsonStatuses)
+	return StatusJSON{
+		SchemaVersion: statusJSONSchemaVersion,
+		GPUs:          jsonStatuses,
+	}
 }
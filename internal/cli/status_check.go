@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// checkFailedExitCode is the exit status of 'status --check' when a user
+// holds more GPUs than allowed, to tell violations apart from errors, which
+// exit with status 1
+const checkFailedExitCode = 2
+
+// gpuLimitRule is a 'status --check' assertion that a user holds at most
+// MaxGPUs GPUs. The user "*" applies to every user without a rule of their
+// own.
+type gpuLimitRule struct {
+	User    string
+	MaxGPUs int
+}
+
+// gpuLimitViolation is a user holding more GPUs than their rule allows
+type gpuLimitViolation struct {
+	User    string
+	GPUIDs  []int
+	MaxGPUs int
+}
+
+// parseGPULimitRule parses a 'status --check' rule such as alice=2
+func parseGPULimitRule(value string) (gpuLimitRule, error) {
+	user, limit, ok := strings.Cut(value, "=")
+	user = strings.TrimSpace(user)
+	if !ok || user == "" {
+		return gpuLimitRule{}, fmt.Errorf("invalid check %q: expected <user>=<max GPUs>", value)
+	}
+	maxGPUs, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil || maxGPUs < 0 {
+		return gpuLimitRule{}, fmt.Errorf("invalid check %q: %q is not a GPU count", value, strings.TrimSpace(limit))
+	}
+	return gpuLimitRule{User: user, MaxGPUs: maxGPUs}, nil
+}
+
+// parseGPULimitRules parses the rules given with --check and read from
+// --check-file. A user can only have one rule.
+func parseGPULimitRules(checks []string, checkFile string) ([]gpuLimitRule, error) {
+	var rules []gpuLimitRule
+	for _, check := range checks {
+		rule, err := parseGPULimitRule(check)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	if checkFile != "" {
+		fileRules, err := readGPULimitRules(checkFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if seen[rule.User] {
+			return nil, fmt.Errorf("more than one check for user %s", rule.User)
+		}
+		seen[rule.User] = true
+	}
+	return rules, nil
+}
+
+// readGPULimitRules reads a --check-file with one rule per line. Blank lines
+// and lines starting with # are ignored.
+func readGPULimitRules(path string) ([]gpuLimitRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read check file: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var rules []gpuLimitRule
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseGPULimitRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read check file: %v", err)
+	}
+	return rules, nil
+}
+
+// gpusHeldByUser returns the GPUs each user holds: reserved GPUs, a share of
+// a shared GPU, and GPUs used without a reservation
+func gpusHeldByUser(statuses []gpu.GPUStatusInfo) map[string][]int {
+	held := make(map[string][]int)
+	for _, status := range statuses {
+		var users []string
+		switch {
+		case len(status.Shares) > 0:
+			for _, share := range status.Shares {
+				users = append(users, share.User)
+			}
+		case status.Status == "IN_USE":
+			users = []string{status.User}
+		case status.Status == "UNRESERVED":
+			users = status.UnreservedUsers
+		}
+
+		counted := make(map[string]bool, len(users))
+		for _, user := range users {
+			if user == "" || counted[user] {
+				continue
+			}
+			counted[user] = true
+			held[user] = append(held[user], status.GPUID)
+		}
+	}
+	return held
+}
+
+// checkGPULimits returns the users holding more GPUs than their rule allows,
+// sorted by user
+func checkGPULimits(statuses []gpu.GPUStatusInfo, rules []gpuLimitRule) []gpuLimitViolation {
+	limits := make(map[string]int, len(rules))
+	defaultLimit, hasDefault := 0, false
+	for _, rule := range rules {
+		if rule.User == "*" {
+			defaultLimit, hasDefault = rule.MaxGPUs, true
+		} else {
+			limits[rule.User] = rule.MaxGPUs
+		}
+	}
+
+	var violations []gpuLimitViolation
+	for user, gpuIDs := range gpusHeldByUser(statuses) {
+		limit, ok := limits[user]
+		if !ok {
+			if !hasDefault {
+				continue
+			}
+			limit = defaultLimit
+		}
+		if len(gpuIDs) > limit {
+			violations = append(violations, gpuLimitViolation{User: user, GPUIDs: gpuIDs, MaxGPUs: limit})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].User < violations[j].User
+	})
+	return violations
+}
+
+// runStatusCheck checks the local GPUs against the 'status --check' rules,
+// reports the violations, and returns how many there are
+func runStatusCheck(ctx context.Context, config *types.Config, rules []gpuLimitRule, w io.Writer) (int, error) {
+	statuses, err := getLocalStatuses(ctx, config)
+	if err != nil {
+		return 0, err
+	}
+
+	violations := checkGPULimits(statuses, rules)
+	displayGPULimitViolations(w, violations, len(rules))
+	return len(violations), nil
+}
+
+// displayGPULimitViolations prints a line for each violation, or that every
+// check passed
+func displayGPULimitViolations(w io.Writer, violations []gpuLimitViolation, ruleCount int) {
+	if len(violations) == 0 {
+		fmt.Fprintf(w, "OK: %d check(s) passed\n", ruleCount)
+		return
+	}
+	for _, v := range violations {
+		fmt.Fprintf(w, "FAIL: %s holds %d GPU(s) %v, more than the limit of %d\n",
+			v.User, len(v.GPUIDs), v.GPUIDs, v.MaxGPUs)
+	}
+}
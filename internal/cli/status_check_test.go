@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkTestStatuses() []gpu.GPUStatusInfo {
+	return []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "UNRESERVED", UnreservedUsers: []string{"alice", "carol"}},
+		{GPUID: 3, Status: "IN_USE", User: "bob, carol", Shares: []types.GPUShare{{User: "bob"}, {User: "carol"}}},
+		{GPUID: 4, Status: "AVAILABLE"},
+		{GPUID: 5, Status: "IN_USE", User: "bob"},
+	}
+}
+
+func TestParseGPULimitRule(t *testing.T) {
+	rule, err := parseGPULimitRule("alice=2")
+	require.NoError(t, err)
+	assert.Equal(t, gpuLimitRule{User: "alice", MaxGPUs: 2}, rule)
+
+	rule, err = parseGPULimitRule(" * = 0 ")
+	require.NoError(t, err)
+	assert.Equal(t, gpuLimitRule{User: "*", MaxGPUs: 0}, rule)
+
+	for _, value := range []string{"alice", "=2", "alice=", "alice=two", "alice=-1"} {
+		_, err := parseGPULimitRule(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseGPULimitRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits")
+	require.NoError(t, os.WriteFile(path, []byte("# Fair use\n\nbob=1\n*=4\n"), 0644))
+
+	rules, err := parseGPULimitRules([]string{"alice=2"}, path)
+	require.NoError(t, err)
+	assert.Equal(t, []gpuLimitRule{{"alice", 2}, {"bob", 1}, {"*", 4}}, rules)
+
+	_, err = parseGPULimitRules([]string{"bob=2"}, path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than one check for user bob")
+
+	require.NoError(t, os.WriteFile(path, []byte("bob=1\nbob\n"), 0644))
+	_, err = parseGPULimitRules(nil, path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path+":2:")
+
+	_, err = parseGPULimitRules(nil, filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestGPUsHeldByUser(t *testing.T) {
+	assert.Equal(t, map[string][]int{
+		"alice": {0, 1, 2},
+		"bob":   {3, 5},
+		"carol": {2, 3},
+	}, gpusHeldByUser(checkTestStatuses()))
+}
+
+func TestCheckGPULimits(t *testing.T) {
+	statuses := checkTestStatuses()
+
+	assert.Empty(t, checkGPULimits(statuses, []gpuLimitRule{{"alice", 3}, {"bob", 2}}))
+
+	// Users without a rule aren't checked unless there is a * rule
+	assert.Equal(t, []gpuLimitViolation{{User: "alice", GPUIDs: []int{0, 1, 2}, MaxGPUs: 2}},
+		checkGPULimits(statuses, []gpuLimitRule{{"alice", 2}}))
+
+	assert.Equal(t, []gpuLimitViolation{
+		{User: "alice", GPUIDs: []int{0, 1, 2}, MaxGPUs: 1},
+		{User: "carol", GPUIDs: []int{2, 3}, MaxGPUs: 1},
+	}, checkGPULimits(statuses, []gpuLimitRule{{"bob", 2}, {"*", 1}}))
+
+	// Service accounts are checked under their own name, not their owner's
+	owned := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "UNRESERVED", UnreservedUsers: []string{"svc-vllm"}, UnreservedOwners: map[string]string{"svc-vllm": "alice"}},
+		{GPUID: 1, Status: "UNRESERVED", UnreservedUsers: []string{"svc-vllm"}, UnreservedOwners: map[string]string{"svc-vllm": "alice"}},
+	}
+	assert.Equal(t, []gpuLimitViolation{{User: "svc-vllm", GPUIDs: []int{0, 1}, MaxGPUs: 1}},
+		checkGPULimits(owned, []gpuLimitRule{{"svc-vllm", 1}}))
+	assert.Empty(t, checkGPULimits(owned, []gpuLimitRule{{"alice", 1}}))
+}
+
+func TestDisplayGPULimitViolations(t *testing.T) {
+	var buf bytes.Buffer
+	displayGPULimitViolations(&buf, nil, 2)
+	assert.Equal(t, "OK: 2 check(s) passed\n", buf.String())
+
+	buf.Reset()
+	displayGPULimitViolations(&buf, []gpuLimitViolation{{User: "alice", GPUIDs: []int{0, 1, 2}, MaxGPUs: 2}}, 1)
+	assert.Equal(t, "FAIL: alice holds 3 GPU(s) [0 1 2], more than the limit of 2\n", buf.String())
+}
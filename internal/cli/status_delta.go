@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// Change indicators shown by 'status --delta'
+const (
+	deltaNowInUse = "+" // The GPU was available and is now in use
+	deltaNowFree  = "-" // The GPU was in use and is now available
+	deltaChanged  = "~" // Any other change, e.g. a different user
+)
+
+// statusSnapshot is the last status of a host's GPUs seen by 'status --delta'
+type statusSnapshot struct {
+	Host string        `json:"host"`
+	Time time.Time     `json:"time"`
+	GPUs []gpuSnapshot `json:"gpus"`
+}
+
+// gpuSnapshot is the part of a GPU's status compared by 'status --delta'
+type gpuSnapshot struct {
+	GPUID  int    `json:"gpu_id"`
+	Status string `json:"status"`
+	User   string `json:"user,omitempty"`
+}
+
+// statusChange is a GPU whose status changed since the last snapshot
+type statusChange struct {
+	GPUID          int    `json:"gpu_id"`
+	Change         string `json:"change"`
+	PreviousStatus string `json:"previous_status"`
+	PreviousUser   string `json:"previous_user,omitempty"`
+	Status         string `json:"status"`
+	User           string `json:"user,omitempty"`
+}
+
+// hostDeltaJSON is the 'status --delta --json' output for one host. Since
+// is omitted on the first run for the host, when there is nothing to compare.
+type hostDeltaJSON struct {
+	Host    string         `json:"host"`
+	Since   *time.Time     `json:"since,omitempty"`
+	Changes []statusChange `json:"changes"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// StatusDeltaJSON is the output of 'status --delta --json'
+type StatusDeltaJSON struct {
+	Hosts []hostDeltaJSON `json:"hosts"`
+}
+
+// runStatusDelta shows the GPUs of the selected hosts whose status changed
+// since the last 'status --delta', and saves the current status for next time
+func runStatusDelta(ctx context.Context, config *types.Config, w io.Writer) error {
+	dir, err := statusSnapshotDir()
+	if err != nil {
+		return err
+	}
+
+	var results []hostResult
+	if showAll {
+		localhostAvail := checkLocalhostAvailable(ctx, config)
+		if !localhostAvail && len(config.RemoteHosts) == 0 {
+			return withCode(ErrCodeRedisUnreachable, fmt.Errorf("failed to connect to Redis and no remote hosts configured"))
+		}
+		results = getAllHostStatuses(ctx, config, localhostAvail)
+	} else if remoteName != "" {
+		statuses, err := getRemoteStatus(ctx, remoteName)
+		if err != nil {
+			return withCode(ErrCodeRemoteUnreachable, fmt.Errorf("failed to get status from %s: %v", remoteName, err))
+		}
+		if statuses, err = applyGPUIDFilter(statuses); err != nil {
+			return invalidArgument(fmt.Errorf("failed to get status from %s: %v", remoteName, err))
+		}
+		results = []hostResult{{host: remoteName, statuses: statuses}}
+	} else {
+		statuses, err := getLocalStatus(ctx, config)
+		if err != nil {
+			return fmt.Errorf("failed to get GPU status: %v", err)
+		}
+		if statuses, err = applyGPUIDFilter(statuses); err != nil {
+			return invalidArgument(err)
+		}
+		results = []hostResult{{host: "localhost", statuses: statuses}}
+	}
+
+	now := time.Now()
+	output := StatusDeltaJSON{Hosts: []hostDeltaJSON{}}
+	for _, result := range results {
+		delta := hostDeltaJSON{Host: result.host, Changes: []statusChange{}}
+		if result.err != nil {
+			delta.Error = result.err.Error()
+			output.Hosts = append(output.Hosts, delta)
+			continue
+		}
+
+		path := statusSnapshotPath(dir, result.host, profileName, poolName)
+		previous, err := loadStatusSnapshot(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring previous status of %s: %v\n", result.host, err)
+		}
+
+		current := snapshotStatuses(result.statuses)
+		if previous != nil {
+			delta.Since = &previous.Time
+			delta.Changes = diffStatusSnapshots(previous.GPUs, current)
+		}
+		output.Hosts = append(output.Hosts, delta)
+
+		// GPUs left out with --gpu-ids keep their previous status
+		snapshot := &statusSnapshot{Host: result.host, Time: now, GPUs: current}
+		if previous != nil {
+			snapshot.GPUs = mergeGPUSnapshots(previous.GPUs, current)
+		}
+		if err := saveStatusSnapshot(path, snapshot); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+	displayStatusDelta(w, output.Hosts)
+	return nil
+}
+
+// displayStatusDelta prints the changed GPUs of each host. Hosts without
+// changes print nothing, so that monitoring scripts only see output when
+// something happened.
+func displayStatusDelta(w io.Writer, hosts []hostDeltaJSON) {
+	for _, host := range hosts {
+		if host.Error != "" {
+			fmt.Fprintf(w, "%s: %s\n", FormatHost(host.Host), FormatDim(fmt.Sprintf("ERROR: %s", host.Error)))
+			continue
+		}
+		if host.Since == nil {
+			fmt.Fprintf(os.Stderr, "%s: saved the current status; changes are shown from the next 'status --delta'\n", host.Host)
+			continue
+		}
+		if len(host.Changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s: %d change(s) since %s\n", FormatHost(host.Host), len(host.Changes), host.Since.Local().Format("2006-01-02 15:04:05"))
+		for _, change := range host.Changes {
+			fmt.Fprintf(w, "  %s GPU %d: %s -> %s\n", change.Change, change.GPUID,
+				formatDeltaState(change.PreviousStatus, change.PreviousUser),
+				formatDeltaState(change.Status, change.User))
+		}
+	}
+}
+
+// formatDeltaState formats a GPU's status and user, e.g. "IN_USE (alice)"
+func formatDeltaState(status, user string) string {
+	if user == "" {
+		return status
+	}
+	return fmt.Sprintf("%s (%s)", status, user)
+}
+
+// snapshotStatuses returns the parts of the statuses compared by
+// 'status --delta'
+func snapshotStatuses(statuses []gpu.GPUStatusInfo) []gpuSnapshot {
+	snapshots := make([]gpuSnapshot, 0, len(statuses))
+	for _, status := range statuses {
+		user := status.User
+		if user == "" && len(status.UnreservedUsers) > 0 {
+			users := append([]string(nil), status.UnreservedUsers...)
+			sort.Strings(users)
+			user = strings.Join(users, ", ")
+		}
+		snapshots = append(snapshots, gpuSnapshot{GPUID: status.GPUID, Status: status.Status, User: user})
+	}
+	return snapshots
+}
+
+// diffStatusSnapshots returns the GPUs in current whose status or user
+// differs from previous, in GPU order. GPUs that aren't in previous have
+// nothing to compare with and are left out.
+func diffStatusSnapshots(previous, current []gpuSnapshot) []statusChange {
+	before := make(map[int]gpuSnapshot, len(previous))
+	for _, snapshot := range previous {
+		before[snapshot.GPUID] = snapshot
+	}
+
+	changes := []statusChange{}
+	for _, now := range current {
+		was, ok := before[now.GPUID]
+		if !ok || was == now {
+			continue
+		}
+
+		change := deltaChanged
+		if was.Status == "AVAILABLE" && isInUseStatus(now.Status) {
+			change = deltaNowInUse
+		} else if isInUseStatus(was.Status) && now.Status == "AVAILABLE" {
+			change = deltaNowFree
+		}
+		changes = append(changes, statusChange{
+			GPUID:          now.GPUID,
+			Change:         change,
+			PreviousStatus: was.Status,
+			PreviousUser:   was.User,
+			Status:         now.Status,
+			User:           now.User,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].GPUID < changes[j].GPUID })
+	return changes
+}
+
+// isInUseStatus reports whether a GPU with the status is in use, reserved
+// or not
+func isInUseStatus(status string) bool {
+	return status == "IN_USE" || status == "UNRESERVED"
+}
+
+// mergeGPUSnapshots returns previous updated with the GPUs in current, in
+// GPU order
+func mergeGPUSnapshots(previous, current []gpuSnapshot) []gpuSnapshot {
+	byID := make(map[int]gpuSnapshot, len(previous)+len(current))
+	for _, snapshot := range previous {
+		byID[snapshot.GPUID] = snapshot
+	}
+	for _, snapshot := range current {
+		byID[snapshot.GPUID] = snapshot
+	}
+
+	merged := make([]gpuSnapshot, 0, len(byID))
+	for _, snapshot := range byID {
+		merged = append(merged, snapshot)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].GPUID < merged[j].GPUID })
+	return merged
+}
+
+// statusSnapshotDir returns the directory 'status --delta' keeps its
+// snapshots in, creating it if needed
+func statusSnapshotDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a directory for status snapshots: %v", err)
+	}
+	dir := filepath.Join(cacheDir, "canhazgpu")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create status snapshot directory: %v", err)
+	}
+	return dir, nil
+}
+
+// unsafeFileNameChars matches characters replaced in host names to build
+// snapshot file names, e.g. the @ and : in "alice@gpu-node:2222"
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// statusSnapshotPath returns the file the snapshot of host is kept in. A
+// profile or pool can point the same host at another Redis, so each has
+// snapshots of its own.
+func statusSnapshotPath(dir, host, profile, pool string) string {
+	name := "status-" + host
+	if profile != "" {
+		name += "-profile-" + profile
+	}
+	if pool != "" {
+		name += "-pool-" + pool
+	}
+	return filepath.Join(dir, unsafeFileNameChars.ReplaceAllString(name, "_")+".json")
+}
+
+// loadStatusSnapshot reads a snapshot saved by saveStatusSnapshot. Returns
+// nil without an error if there is none.
+func loadStatusSnapshot(path string) (*statusSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot statusSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid status snapshot %s: %v", path, err)
+	}
+	return &snapshot, nil
+}
+
+// saveStatusSnapshot replaces the snapshot at path
+func saveStatusSnapshot(path string, snapshot *statusSnapshot) error {
+	err := writeOutput(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(snapshot)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save status snapshot: %v", err)
+	}
+	return nil
+}
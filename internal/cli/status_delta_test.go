@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStatuses(t *testing.T) {
+	snapshots := snapshotStatuses([]gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "UNRESERVED", UnreservedUsers: []string{"carol", "bob"}},
+	})
+	assert.Equal(t, []gpuSnapshot{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "UNRESERVED", User: "bob, carol"},
+	}, snapshots)
+}
+
+func TestDiffStatusSnapshots(t *testing.T) {
+	previous := []gpuSnapshot{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "IN_USE", User: "bob"},
+		{GPUID: 3, Status: "IN_USE", User: "carol"},
+		{GPUID: 5, Status: "AVAILABLE"},
+	}
+	current := []gpuSnapshot{
+		{GPUID: 0, Status: "IN_USE", User: "dave"},
+		{GPUID: 1, Status: "AVAILABLE"},
+		{GPUID: 2, Status: "IN_USE", User: "bob"},
+		{GPUID: 3, Status: "UNRESERVED", User: "eve"},
+		// Not in the previous snapshot
+		{GPUID: 4, Status: "IN_USE", User: "frank"},
+		{GPUID: 5, Status: "ERROR"},
+	}
+
+	assert.Equal(t, []statusChange{
+		{GPUID: 0, Change: deltaNowInUse, PreviousStatus: "AVAILABLE", Status: "IN_USE", User: "dave"},
+		{GPUID: 1, Change: deltaNowFree, PreviousStatus: "IN_USE", PreviousUser: "alice", Status: "AVAILABLE"},
+		{GPUID: 3, Change: deltaChanged, PreviousStatus: "IN_USE", PreviousUser: "carol", Status: "UNRESERVED", User: "eve"},
+		{GPUID: 5, Change: deltaChanged, PreviousStatus: "AVAILABLE", Status: "ERROR"},
+	}, diffStatusSnapshots(previous, current))
+
+	assert.Empty(t, diffStatusSnapshots(current, current))
+}
+
+func TestMergeGPUSnapshots(t *testing.T) {
+	previous := []gpuSnapshot{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+	}
+	current := []gpuSnapshot{{GPUID: 1, Status: "AVAILABLE"}}
+
+	assert.Equal(t, []gpuSnapshot{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "AVAILABLE"},
+	}, mergeGPUSnapshots(previous, current))
+}
+
+func TestStatusSnapshotFile(t *testing.T) {
+	dir := t.TempDir()
+	path := statusSnapshotPath(dir, "alice@gpu-node:2222", "", "")
+	assert.Equal(t, filepath.Join(dir, "status-alice_gpu-node_2222.json"), path)
+
+	// Pools and profiles of the same host don't share a snapshot
+	assert.Equal(t, filepath.Join(dir, "status-localhost-pool-training.json"), statusSnapshotPath(dir, "localhost", "", "training"))
+	assert.Equal(t, filepath.Join(dir, "status-localhost-profile-cluster-b-pool-training.json"), statusSnapshotPath(dir, "localhost", "cluster-b", "training"))
+
+	snapshot, err := loadStatusSnapshot(path)
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+
+	saved := &statusSnapshot{
+		Host: "alice@gpu-node:2222",
+		Time: time.Date(2025, 7, 7, 18, 20, 0, 0, time.UTC),
+		GPUs: []gpuSnapshot{{GPUID: 0, Status: "IN_USE", User: "alice"}},
+	}
+	require.NoError(t, saveStatusSnapshot(path, saved))
+
+	snapshot, err = loadStatusSnapshot(path)
+	require.NoError(t, err)
+	assert.Equal(t, saved, snapshot)
+}
+
+func TestDisplayStatusDelta(t *testing.T) {
+	SetNoColor(true)
+	since := time.Date(2025, 7, 7, 18, 20, 0, 0, time.Local)
+
+	var buf bytes.Buffer
+	displayStatusDelta(&buf, []hostDeltaJSON{
+		{
+			Host:  "localhost",
+			Since: &since,
+			Changes: []statusChange{
+				{GPUID: 0, Change: deltaNowInUse, PreviousStatus: "AVAILABLE", Status: "IN_USE", User: "dave"},
+				{GPUID: 1, Change: deltaNowFree, PreviousStatus: "IN_USE", PreviousUser: "alice", Status: "AVAILABLE"},
+			},
+		},
+		{Host: "quiet-node", Since: &since, Changes: []statusChange{}},
+		{Host: "down-node", Error: "connection refused"},
+	})
+
+	assert.Equal(t, "localhost: 2 change(s) since 2025-07-07 18:20:00\n"+
+		"  + GPU 0: AVAILABLE -> IN_USE (dave)\n"+
+		"  - GPU 1: IN_USE (alice) -> AVAILABLE\n"+
+		"down-node: ERROR: connection refused\n", buf.String())
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// defaultPromptFormat is the 'status --prompt' template used unless
+// --prompt-format sets another
+const defaultPromptFormat = "GPU {{.Free}}/{{.Total}} free"
+
+// promptColors are the colors the 'status --prompt' template can apply with
+// the color function
+var promptColors = map[string]color.Attribute{
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+	"bold":    color.Bold,
+	"dim":     color.Faint,
+}
+
+// promptCounts are the values available to the 'status --prompt' template
+type promptCounts struct {
+	Total      int
+	Free       int
+	InUse      int // Reserved or in unreserved use
+	Unreserved int
+}
+
+// Level is "green", "yellow", or "red" by the share of GPUs that are free,
+// with the thresholds of the --summary availability colors
+func (c promptCounts) Level() string {
+	switch {
+	case c.Total > 0 && c.Free*100/c.Total > 50:
+		return "green"
+	case c.Total > 0 && c.Free*100/c.Total > 25:
+		return "yellow"
+	default:
+		return "red"
+	}
+}
+
+// newPromptCounts counts GPUs by status for 'status --prompt'
+func newPromptCounts(statuses []gpu.GPUStatusInfo) promptCounts {
+	counts := promptCounts{Total: len(statuses)}
+	for _, status := range statuses {
+		switch status.Status {
+		case "AVAILABLE":
+			counts.Free++
+		case "IN_USE":
+			counts.InUse++
+		case "UNRESERVED":
+			counts.InUse++
+			counts.Unreserved++
+		}
+	}
+	return counts
+}
+
+// parsePromptFormat parses a 'status --prompt' template. Its color function,
+// e.g. {{color "green" .Free}}, colors text even when the output isn't a
+// terminal, as it is when a shell prompt runs the command, unless colors are
+// disabled with --no-color.
+func parsePromptFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Funcs(template.FuncMap{
+		"color": promptColor,
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt format: %v", err)
+	}
+	return tmpl, nil
+}
+
+// promptColor applies a color from promptColors to a value
+func promptColor(name string, value any) (string, error) {
+	attr, ok := promptColors[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown color %q", name)
+	}
+	text := fmt.Sprint(value)
+	if noColor {
+		return text, nil
+	}
+	c := color.New(attr)
+	c.EnableColor()
+	return c.Sprint(text), nil
+}
+
+// runStatusPrompt writes the one-line 'status --prompt' banner for the local
+// host
+func runStatusPrompt(ctx context.Context, config *types.Config, tmpl *template.Template, w io.Writer) error {
+	statuses, err := getLocalStatuses(ctx, config)
+	if err != nil {
+		return err
+	}
+	return displayPrompt(w, tmpl, statuses)
+}
+
+// displayPrompt writes the one-line 'status --prompt' banner
+func displayPrompt(w io.Writer, tmpl *template.Template, statuses []gpu.GPUStatusInfo) error {
+	var line strings.Builder
+	if err := tmpl.Execute(&line, newPromptCounts(statuses)); err != nil {
+		return fmt.Errorf("invalid prompt format: %v", err)
+	}
+	_, err := fmt.Fprintln(w, line.String())
+	return err
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func promptTestStatuses() []gpu.GPUStatusInfo {
+	return []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "UNRESERVED", UnreservedUsers: []string{"bob"}},
+		{GPUID: 3, Status: "AVAILABLE"},
+		{GPUID: 4, Status: "AVAILABLE"},
+	}
+}
+
+func TestNewPromptCounts(t *testing.T) {
+	counts := newPromptCounts(promptTestStatuses())
+	assert.Equal(t, promptCounts{Total: 5, Free: 3, InUse: 2, Unreserved: 1}, counts)
+	assert.Equal(t, promptCounts{}, newPromptCounts(nil))
+}
+
+func TestPromptCountsLevel(t *testing.T) {
+	assert.Equal(t, "green", promptCounts{Total: 8, Free: 5}.Level())
+	assert.Equal(t, "yellow", promptCounts{Total: 8, Free: 4}.Level())
+	assert.Equal(t, "yellow", promptCounts{Total: 8, Free: 3}.Level())
+	assert.Equal(t, "red", promptCounts{Total: 8, Free: 2}.Level())
+	assert.Equal(t, "red", promptCounts{}.Level())
+}
+
+func TestDisplayPrompt(t *testing.T) {
+	defer SetNoColor(noColor)
+	SetNoColor(true)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{defaultPromptFormat, "GPU 3/5 free\n"},
+		{"{{.InUse}} busy, {{.Unreserved}} unreserved", "2 busy, 1 unreserved\n"},
+		{`{{color .Level .Free}}/{{.Total}}`, "3/5\n"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parsePromptFormat(tt.format)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, displayPrompt(&buf, tmpl, promptTestStatuses()))
+		assert.Equal(t, tt.want, buf.String(), tt.format)
+	}
+}
+
+func TestDisplayPromptColor(t *testing.T) {
+	defer SetNoColor(noColor)
+	SetNoColor(false)
+
+	tmpl, err := parsePromptFormat(`{{color "green" .Free}}`)
+	require.NoError(t, err)
+
+	// Colored even though the output is not a terminal
+	var buf bytes.Buffer
+	require.NoError(t, displayPrompt(&buf, tmpl, promptTestStatuses()))
+	assert.Equal(t, "\x1b[32m3\x1b[0m\n", buf.String())
+}
+
+func TestDisplayPromptErrors(t *testing.T) {
+	_, err := parsePromptFormat("GPU {{.Free")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid prompt format")
+
+	tmpl, err := parsePromptFormat(`{{color "purple" .Free}}`)
+	require.NoError(t, err)
+	err = displayPrompt(&bytes.Buffer{}, tmpl, promptTestStatuses())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown color "purple"`)
+
+	tmpl, err = parsePromptFormat("{{.Busy}}")
+	require.NoError(t, err)
+	assert.Error(t, displayPrompt(&bytes.Buffer{}, tmpl, promptTestStatuses()))
+}
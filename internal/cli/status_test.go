@@ -2,13 +2,18 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDisplayGPUStatusTable(t *testing.T) {
@@ -35,6 +40,13 @@ func TestDisplayGPUStatusTable(t *testing.T) {
 			UnreservedUsers: []string{"baduser"},
 			ProcessInfo:     "1024MB used by 1 process",
 		},
+		{
+			GPUID:            3,
+			Status:           "UNRESERVED",
+			UnreservedUsers:  []string{"svc-vllm"},
+			UnreservedOwners: map[string]string{"svc-vllm": "alice"},
+			ProcessInfo:      "8192MB used by 1 process",
+		},
 	}
 
 	// Test that the function doesn't panic and produces expected output structure
@@ -63,6 +75,7 @@ func TestDisplayGPUStatusTable(t *testing.T) {
 	assert.Contains(t, output, "UNRESERVED", "Should show unreserved status")
 	assert.Contains(t, output, "testuser", "Should show user name")
 	assert.Contains(t, output, "baduser", "Should show unreserved user")
+	assert.Contains(t, output, "svc-vllm (alice)", "Should show the owner of a service account")
 
 	// Check that it's formatted as a table
 	lines := strings.Split(output, "\n")
@@ -78,7 +91,7 @@ func TestDisplayGPUStatusTable_Structure(t *testing.T) {
 	// Test with empty status list (should not panic)
 	var emptyStatuses []gpu.GPUStatusInfo
 	assert.NotPanics(t, func() {
-		displayGPUStatusTable(emptyStatuses)
+		displayGPUStatusTable(io.Discard, emptyStatuses)
 	})
 }
 
@@ -221,3 +234,442 @@ func TestDisplayGPUStatusTable_ConditionalModelColumn(t *testing.T) {
 	assert.Contains(t, outputWithModel, "VALIDATION", "Should have VALIDATION column")
 	assert.Contains(t, outputWithModel, "meta-llama/Llama-2-7b-chat-hf", "Should display the detected model")
 }
+
+func TestWideStatusColumns(t *testing.T) {
+	SetNoColor(true)
+	defer SetNoColor(false)
+
+	startTime := time.Date(2025, 7, 7, 18, 0, 0, 0, time.Local)
+	status := gpu.GPUStatusInfo{
+		GPUID:           1,
+		Status:          "IN_USE",
+		User:            "alice",
+		ReservationType: "run",
+		GPUModel:        "H100",
+		PIDs:            []int{1234, 5678},
+		StartTime:       startTime,
+		Priority:        "high",
+		Source:          "run",
+		Command:         "python train.py --config configs/large-model-sweep.yaml",
+	}
+
+	row := wideStatusColumns(status, false)
+	assert.Equal(t, table.Row{"H100", "1234,5678", "2025-07-07 18:00:00", "high", "run", "python train.py --config configs/larg..."}, row)
+
+	// Missing values are shown as dashes
+	row = wideStatusColumns(gpu.GPUStatusInfo{GPUID: 0, Status: "AVAILABLE"}, true)
+	assert.Equal(t, table.Row{"-", "-", "-", "-", "-", "-"}, row)
+}
+
+func TestReservationTypeLabel(t *testing.T) {
+	status := gpu.GPUStatusInfo{Status: "IN_USE", User: "alice", ReservationType: "manual"}
+	assert.Equal(t, "MANUAL", reservationTypeLabel(status))
+
+	status.Source = types.ReservationSourceReserve
+	assert.Equal(t, "MANUAL", reservationTypeLabel(status))
+
+	status.Source = types.ReservationSourceAdopted
+	assert.Equal(t, "MANUAL (adopted)", reservationTypeLabel(status))
+
+	status.Source = types.ReservationSourceSchedule
+	assert.Equal(t, "MANUAL (schedule)", reservationTypeLabel(status))
+
+	// The source shows in the default table too
+	SetNoColor(true)
+	defer SetNoColor(false)
+	var buf bytes.Buffer
+	displayGPUStatusTable(&buf, []gpu.GPUStatusInfo{{GPUID: 0, Status: "IN_USE", User: "alice", ReservationType: "manual", Source: types.ReservationSourceAdopted}})
+	assert.Contains(t, buf.String(), "MANUAL (adopted)")
+}
+
+func TestFilterStaleStatuses(t *testing.T) {
+	now := time.Now()
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice", ReservationType: "run", LastHeartbeat: now.Add(-30 * time.Second)},
+		{GPUID: 2, Status: "IN_USE", User: "bob", ReservationType: "run", LastHeartbeat: now.Add(-3 * time.Minute)},
+		{GPUID: 3, Status: "IN_USE", User: "carol", ReservationType: "run", LastHeartbeat: now.Add(-4 * time.Minute)},
+		{GPUID: 4, Status: "IN_USE", User: "dave", ReservationType: "manual", ExpiryTime: now.Add(-time.Hour)},
+		{GPUID: 5, Status: "IN_USE", User: "erin", ReservationType: "run"},
+		{GPUID: 6, Status: "UNRESERVED", UnreservedUsers: []string{"frank"}},
+	}
+
+	stale := filterStaleStatuses(statuses, now)
+	if assert.Len(t, stale, 2) {
+		// Most stale first
+		assert.Equal(t, 3, stale[0].GPUID)
+		assert.Equal(t, 2, stale[1].GPUID)
+	}
+
+	assert.Empty(t, filterStaleStatuses(nil, now))
+}
+
+func TestFilterProblemStatuses(t *testing.T) {
+	now := time.Now()
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice", ReservationType: "run", LastHeartbeat: now.Add(-30 * time.Second)},
+		{GPUID: 2, Status: "IN_USE", User: "bob", ReservationType: "run", LastHeartbeat: now.Add(-4 * time.Minute)},
+		{GPUID: 3, Status: "ERROR"},
+		{GPUID: 4, Status: "IN_USE", User: "dave", ReservationType: "manual", ExpiryTime: now.Add(time.Hour)},
+		{GPUID: 5, Status: "UNRESERVED", UnreservedUsers: []string{"frank"}},
+	}
+
+	problems := filterProblemStatuses(statuses, now)
+	var gpuIDs []int
+	for _, status := range problems {
+		gpuIDs = append(gpuIDs, status.GPUID)
+	}
+	assert.Equal(t, []int{2, 3, 5}, gpuIDs)
+
+	assert.Empty(t, filterProblemStatuses(statuses[:2], now))
+}
+
+func TestDisplayGPUStatusTableErrorsOnlyEmpty(t *testing.T) {
+	errorsOnly = true
+	defer func() { errorsOnly = false }()
+
+	var buf bytes.Buffer
+	displayGPUStatusTable(&buf, []gpu.GPUStatusInfo{})
+	assert.Equal(t, "No GPUs need attention.\n", buf.String())
+}
+
+func TestSummarizeHost(t *testing.T) {
+	summary := summarizeHost("gpu-node", []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE", GPUModel: "H100"},
+		{GPUID: 1, Status: "IN_USE", GPUModel: "H100"},
+		{GPUID: 2, Status: "UNRESERVED", GPUModel: "A100"},
+		{GPUID: 3, Status: "ERROR"},
+	})
+	assert.Equal(t, hostSummary{
+		Host: "gpu-node",
+		summaryCounts: &summaryCounts{
+			Total:     4,
+			Available: 1,
+			InUse:     2,
+			GPUModels: map[string]int{"H100": 2, "A100": 1},
+		},
+	}, summary)
+}
+
+func TestDisplaySummaryJSON(t *testing.T) {
+	summaries := summarizeHostResults([]hostResult{
+		{host: "localhost", statuses: []gpu.GPUStatusInfo{
+			{GPUID: 0, Status: "AVAILABLE", GPUModel: "H100"},
+			{GPUID: 1, Status: "IN_USE", GPUModel: "H100"},
+		}},
+		{host: "gpu-node", statuses: []gpu.GPUStatusInfo{{GPUID: 0, Status: "AVAILABLE"}}},
+		{host: "down-node", err: errors.New("connection refused")},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, displaySummaryJSON(&buf, summaries))
+	assert.JSONEq(t, `{
+  "schema_version": 1,
+  "hosts": [
+    {"host": "localhost", "total": 2, "available": 1, "in_use": 1, "gpu_models": {"H100": 2}},
+    {"host": "gpu-node", "total": 1, "available": 1, "in_use": 0, "gpu_models": {}},
+    {"host": "down-node", "error": "connection refused"}
+  ],
+  "totals": {"total": 3, "available": 2, "in_use": 1, "gpu_models": {"H100": 2}}
+}`, buf.String())
+}
+
+func TestDisplaySummaryTable(t *testing.T) {
+	SetNoColor(true)
+	var buf bytes.Buffer
+	displaySummaryTable(&buf, []hostSummary{
+		{Host: "localhost", summaryCounts: &summaryCounts{Total: 3, Available: 1, InUse: 2, GPUModels: map[string]int{"H100": 2, "A100": 1}}},
+		{Host: "down-node", Error: "connection refused"},
+	})
+	output := buf.String()
+	assert.Contains(t, output, "1 A100, 2 H100")
+	assert.Contains(t, output, "ERROR: connection refused")
+}
+
+func TestStatusJSONSchemaVersion(t *testing.T) {
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice", ReservationType: "run", Account: "ml-infra", JobID: "job-1"},
+	}
+
+	data, err := json.Marshal(newStatusJSON(statuses))
+	require.NoError(t, err)
+
+	var output map[string]any
+	require.NoError(t, json.Unmarshal(data, &output))
+	assert.Equal(t, float64(statusJSONSchemaVersion), output["schema_version"])
+	assert.Len(t, output["gpus"], 2)
+
+	// Output round-trips through the parser used for remote hosts
+	parsed, err := parseStatusJSON(data)
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+	assert.Equal(t, "alice", parsed[1].User)
+	assert.Equal(t, "ml-infra", parsed[1].Account)
+	assert.Equal(t, "ml-infra", convertJSONToStatusInfo(parsed[1]).Account)
+	assert.Equal(t, "job-1", convertJSONToStatusInfo(parsed[1]).JobID)
+}
+
+func TestStatusJSONRenewable(t *testing.T) {
+	expiry := time.Now().Add(10 * time.Minute)
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice", ReservationType: "manual", ExpiryTime: expiry, Renewable: true},
+		{GPUID: 1, Status: "IN_USE", User: "bob", ReservationType: "manual", ExpiryTime: expiry},
+	}
+
+	output := newStatusJSON(statuses)
+	require.Len(t, output.GPUs, 2)
+	assert.True(t, output.GPUs[0].Renewable)
+	assert.Contains(t, output.GPUs[0].Details, "(renewable)")
+	assert.False(t, output.GPUs[1].Renewable)
+	assert.NotContains(t, output.GPUs[1].Details, "renewable")
+	assert.True(t, convertJSONToStatusInfo(output.GPUs[0]).Renewable)
+}
+
+func TestStatusJSONHeartbeatAge(t *testing.T) {
+	lastHeartbeat := time.Now().Add(-90 * time.Second)
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice", ReservationType: "run", LastHeartbeat: lastHeartbeat},
+		{GPUID: 1, Status: "IN_USE", User: "bob", ReservationType: "manual", LastHeartbeat: lastHeartbeat, ExpiryTime: time.Now().Add(time.Hour)},
+		{GPUID: 2, Status: "AVAILABLE"},
+	}
+
+	output := newStatusJSON(statuses)
+	require.NotNil(t, output.GPUs[0].HeartbeatAge)
+	assert.InDelta(t, 90, *output.GPUs[0].HeartbeatAge, 2)
+	assert.Nil(t, output.GPUs[1].HeartbeatAge)
+	assert.Nil(t, output.GPUs[2].HeartbeatAge)
+
+	data, err := json.Marshal(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"heartbeat_age_seconds":`)
+}
+
+func TestStatusJSONShared(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	shares := []types.GPUShare{
+		{User: "alice", ExpiryTime: types.FlexibleTime{Time: expiry}},
+		{User: "bob", ExpiryTime: types.FlexibleTime{Time: expiry.Add(time.Hour)}},
+	}
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice, bob", ReservationType: "shared", ExpiryTime: expiry, Shares: shares, MaxShares: 2},
+	}
+
+	output := newStatusJSON(statuses)
+	assert.Equal(t, "SHARED", output.GPUs[0].ReservationType)
+	assert.True(t, strings.HasPrefix(output.GPUs[0].Details, "shared 2/2, first expires in "))
+	assert.Equal(t, shares, output.GPUs[0].Shares)
+	assert.Equal(t, 2, output.GPUs[0].MaxShares)
+
+	// Remote status keeps the holders
+	status := convertJSONToStatusInfo(output.GPUs[0])
+	assert.Equal(t, shares, status.Shares)
+	assert.Equal(t, 2, status.MaxShares)
+}
+
+func TestStatusJSONAnnotation(t *testing.T) {
+	annotatedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice", ReservationType: "manual", Annotation: "contacted owner", AnnotatedBy: "bob", AnnotatedAt: annotatedAt},
+		{GPUID: 1, Status: "IN_USE", User: "carol", ReservationType: "manual"},
+	}
+
+	output := newStatusJSON(statuses)
+	assert.Equal(t, "contacted owner", output.GPUs[0].Annotation)
+	assert.Equal(t, "bob", output.GPUs[0].AnnotatedBy)
+	require.NotNil(t, output.GPUs[0].AnnotatedAt)
+	assert.Nil(t, output.GPUs[1].AnnotatedAt)
+
+	data, err := json.Marshal(output.GPUs[1])
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "annotat")
+
+	// Remote status keeps the annotation
+	status := convertJSONToStatusInfo(output.GPUs[0])
+	assert.Equal(t, "contacted owner", status.Annotation)
+	assert.Equal(t, "bob", status.AnnotatedBy)
+	assert.True(t, annotatedAt.Equal(status.AnnotatedAt))
+}
+
+func TestFormatStatusNote(t *testing.T) {
+	SetNoColor(true)
+	defer SetNoColor(false)
+
+	assert.Equal(t, "-", formatStatusNote(gpu.GPUStatusInfo{}))
+	assert.Equal(t, "training", formatStatusNote(gpu.GPUStatusInfo{Note: "training"}))
+	assert.Equal(t, "[admin bob: will expire tonight]",
+		formatStatusNote(gpu.GPUStatusInfo{Annotation: "will expire tonight", AnnotatedBy: "bob"}))
+	assert.Equal(t, "training [admin bob: will expire tonight]",
+		formatStatusNote(gpu.GPUStatusInfo{Note: "training", Annotation: "will expire tonight", AnnotatedBy: "bob"}))
+}
+
+func TestStatusTelemetry(t *testing.T) {
+	SetNoColor(true)
+	defer SetNoColor(false)
+
+	fan, sm := 45, 1980
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE", Telemetry: &types.GPUTelemetry{FanSpeed: &fan, SMClockMHz: &sm}},
+		{GPUID: 1, Status: "AVAILABLE"},
+	}
+
+	assert.Equal(t, table.Row{"45%", "1980 MHz", "-"}, telemetryColumns(statuses[0]))
+	assert.Equal(t, table.Row{"-", "-", "-"}, telemetryColumns(statuses[1]))
+
+	data, err := json.Marshal(newStatusJSON(statuses))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"fan_speed":45,"sm_clock_mhz":1980`)
+
+	parsed, err := parseStatusJSON(data)
+	require.NoError(t, err)
+	assert.Equal(t, statuses[0].Telemetry, convertJSONToStatusInfo(parsed[0]).Telemetry)
+	assert.Nil(t, convertJSONToStatusInfo(parsed[1]).Telemetry)
+}
+
+func TestStatusPIDs(t *testing.T) {
+	SetNoColor(true)
+	defer SetNoColor(false)
+
+	status := gpu.GPUStatusInfo{
+		GPUID:  0,
+		Status: "IN_USE",
+		PIDs:   []int{1234, 5678},
+		Processes: []types.GPUProcessInfo{
+			{PID: 1234, ProcessName: "python"},
+			{PID: 5678},
+		},
+	}
+	assert.Equal(t, "1234,5678", statusPIDs(status, false))
+	assert.Equal(t, "1234 (python), 5678", statusPIDs(status, true))
+
+	// Remote hosts running older versions only report PIDs
+	status.Processes = nil
+	assert.Equal(t, "1234,5678", statusPIDs(status, true))
+
+	assert.Equal(t, "-", statusPIDs(gpu.GPUStatusInfo{Status: "AVAILABLE"}, true))
+}
+
+func TestStatusJSONProcesses(t *testing.T) {
+	processes := []types.GPUProcessInfo{{PID: 1234, ProcessName: "python", User: "alice", MemoryMB: 8000}}
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "IN_USE", User: "alice", ReservationType: "manual", PIDs: []int{1234}, Processes: processes},
+	}
+
+	output := newStatusJSON(statuses)
+	assert.Equal(t, []int{1234}, output.GPUs[0].PIDs)
+	assert.Equal(t, processes, output.GPUs[0].Processes)
+
+	// Remote status keeps the processes
+	status := convertJSONToStatusInfo(output.GPUs[0])
+	assert.Equal(t, processes, status.Processes)
+}
+
+func TestParseStatusJSON(t *testing.T) {
+	t.Run("unversioned array from older versions", func(t *testing.T) {
+		parsed, err := parseStatusJSON([]byte(` [{"gpu_id": 0, "status": "AVAILABLE"}]`))
+		require.NoError(t, err)
+		require.Len(t, parsed, 1)
+		assert.Equal(t, "AVAILABLE", parsed[0].Status)
+	})
+
+	t.Run("unknown fields are ignored", func(t *testing.T) {
+		parsed, err := parseStatusJSON([]byte(`{"schema_version": 1, "gpus": [{"gpu_id": 3, "status": "IN_USE", "new_field": true}]}`))
+		require.NoError(t, err)
+		require.Len(t, parsed, 1)
+		assert.Equal(t, 3, parsed[0].GPUID)
+	})
+
+	t.Run("newer schema version", func(t *testing.T) {
+		_, err := parseStatusJSON([]byte(`{"schema_version": 99, "gpus": []}`))
+		assert.ErrorContains(t, err, "unsupported status JSON schema version 99")
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		_, err := parseStatusJSON([]byte(`not json`))
+		assert.Error(t, err)
+	})
+}
+
+func TestStatusTableStyle(t *testing.T) {
+	render := func(name string) string {
+		style, err := statusTableStyle(name)
+		require.NoError(t, err)
+
+		tbl := table.NewWriter()
+		tbl.SetStyle(style)
+		tbl.AppendHeader(table.Row{"GPU", "STATUS"})
+		tbl.AppendRow(table.Row{0, "AVAILABLE"})
+		if name == "markdown" {
+			return tbl.RenderMarkdown()
+		}
+		return tbl.Render()
+	}
+
+	light := render("light")
+	assert.Contains(t, light, "│")
+	assert.NotContains(t, light, "┌", "light style has no outer border")
+
+	ascii := render("ascii")
+	assert.Contains(t, ascii, "|")
+	for _, r := range ascii {
+		assert.Less(t, r, rune(128), "ascii style should only use ASCII characters")
+	}
+
+	assert.Equal(t, "| GPU | STATUS |\n| ---:| --- |\n| 0 | AVAILABLE |", render("markdown"))
+
+	compact := render("compact")
+	assert.NotContains(t, compact, "|")
+	assert.NotContains(t, compact, "│")
+	assert.Contains(t, compact, "GPU  STATUS")
+
+	_, err := statusTableStyle("fancy")
+	assert.ErrorContains(t, err, "invalid table style 'fancy'")
+}
+
+func TestFilterStatusesByGPUIDs(t *testing.T) {
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE", User: "alice"},
+		{GPUID: 2, Status: "AVAILABLE"},
+		{GPUID: 3, Status: "UNRESERVED"},
+	}
+
+	filtered, err := filterStatusesByGPUIDs(statuses, nil)
+	require.NoError(t, err)
+	assert.Equal(t, statuses, filtered)
+
+	filtered, err = filterStatusesByGPUIDs(statuses, []int{2, 0})
+	require.NoError(t, err)
+	require.Len(t, filtered, 2)
+	assert.Equal(t, 0, filtered[0].GPUID, "GPU order is kept")
+	assert.Equal(t, 2, filtered[1].GPUID)
+
+	_, err = filterStatusesByGPUIDs(statuses, []int{1, 4})
+	assert.ErrorContains(t, err, "GPU ID 4 is out of range (0-3)")
+	_, err = filterStatusesByGPUIDs(statuses, []int{-1})
+	assert.ErrorContains(t, err, "GPU ID -1 is out of range")
+}
+
+func TestColorDisabled(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+	none := env(nil)
+
+	// Without flags or environment, the terminal default applies
+	assert.True(t, colorDisabled(false, false, true, none))
+	assert.False(t, colorDisabled(false, false, false, none))
+
+	assert.True(t, colorDisabled(true, false, false, none), "--no-color")
+	assert.False(t, colorDisabled(false, true, true, none), "--force-color")
+
+	assert.False(t, colorDisabled(false, false, true, env(map[string]string{"CLICOLOR_FORCE": "1"})))
+	assert.True(t, colorDisabled(false, false, true, env(map[string]string{"CLICOLOR_FORCE": "0"})))
+	assert.True(t, colorDisabled(false, false, true, env(map[string]string{"CLICOLOR_FORCE": "1", "NO_COLOR": "1"})),
+		"NO_COLOR wins over CLICOLOR_FORCE")
+	assert.False(t, colorDisabled(false, true, true, env(map[string]string{"NO_COLOR": "1"})),
+		"--force-color wins over NO_COLOR")
+	assert.True(t, colorDisabled(true, false, false, env(map[string]string{"CLICOLOR_FORCE": "1"})),
+		"--no-color wins over CLICOLOR_FORCE")
+}
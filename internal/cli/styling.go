@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/fatih/color"
@@ -52,6 +53,34 @@ func SetNoColor(value bool) {
 	color.NoColor = value
 }
 
+// colorDisabledByDefault is fatih/color's own choice of whether to color
+// output, made from NO_COLOR, TERM=dumb, and whether stdout is a terminal
+var colorDisabledByDefault = color.NoColor
+
+// setupColor sets the color mode from --no-color and --force-color
+func setupColor(noColorFlag, forceColorFlag bool) {
+	SetNoColor(colorDisabled(noColorFlag, forceColorFlag, colorDisabledByDefault, os.Getenv))
+}
+
+// colorDisabled decides whether to leave output uncolored. --no-color and
+// --force-color come first, then NO_COLOR, then CLICOLOR_FORCE set to anything
+// but 0, which forces color even when output isn't a terminal. Otherwise the
+// default is used.
+func colorDisabled(noColorFlag, forceColorFlag, disabledByDefault bool, getenv func(string) string) bool {
+	switch {
+	case noColorFlag:
+		return true
+	case forceColorFlag:
+		return false
+	case getenv("NO_COLOR") != "":
+		return true
+	case getenv("CLICOLOR_FORCE") != "" && getenv("CLICOLOR_FORCE") != "0":
+		return false
+	default:
+		return disabledByDefault
+	}
+}
+
 // FormatStatus returns a colored status string
 func FormatStatus(status string) string {
 	switch status {
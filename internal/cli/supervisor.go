@@ -12,6 +12,7 @@ import (
 
 	"github.com/russellb/canhazgpu/internal/gpu"
 	"github.com/russellb/canhazgpu/internal/redis_client"
+	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/russellb/canhazgpu/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -25,6 +26,10 @@ var supervisorCmd = &cobra.Command{
 		user, _ := cmd.Flags().GetString("user")
 		pidStr, _ := cmd.Flags().GetString("pid")
 		timeoutStr, _ := cmd.Flags().GetString("timeout")
+		cgroupPath, _ := cmd.Flags().GetString("cgroup")
+		expiryWarning, _ := cmd.Flags().GetInt("expiry-warning")
+		checkpointSignalStr, _ := cmd.Flags().GetString("checkpoint-signal")
+		checkpointGraceStr, _ := cmd.Flags().GetString("checkpoint-grace")
 
 		// Parse GPU IDs
 		gpuIDs, err := parseGPUList(gpuStr)
@@ -49,7 +54,12 @@ var supervisorCmd = &cobra.Command{
 			hasTimeout = true
 		}
 
-		return runSupervisor(cmd.Context(), gpuIDs, user, pid, timeout, hasTimeout)
+		checkpointSignal, checkpointGrace, err := parseCheckpointOptions(checkpointSignalStr, checkpointGraceStr, timeout)
+		if err != nil {
+			return err
+		}
+
+		return runSupervisor(cmd.Context(), gpuIDs, user, pid, timeout, hasTimeout, expiryWarning, checkpointSignal, checkpointGrace, cgroupPath)
 	},
 }
 
@@ -58,10 +68,80 @@ func init() {
 	supervisorCmd.Flags().String("user", "", "User who owns the reservation")
 	supervisorCmd.Flags().String("pid", "", "PID of the process to monitor")
 	supervisorCmd.Flags().String("timeout", "", "Timeout duration for the command")
+	supervisorCmd.Flags().String("cgroup", "", "Resource limit cgroup to remove once the command exits")
+	supervisorCmd.Flags().Int("expiry-warning", 0, "Warn when this percentage of the timeout has elapsed (0 to disable)")
+	supervisorCmd.Flags().String("checkpoint-signal", "", "Signal to send when the timeout is reached, before SIGINT")
+	supervisorCmd.Flags().String("checkpoint-grace", "", "How long to wait after the checkpoint signal before sending SIGINT")
 
 	rootCmd.AddCommand(supervisorCmd)
 }
 
+// supervisorOptions are the settings the supervisor of a run reservation is
+// started with
+type supervisorOptions struct {
+	GPUs             string // Comma-separated GPU IDs
+	User             string
+	PID              int
+	Timeout          string // Empty for no timeout
+	ExpiryWarning    int
+	CheckpointSignal syscall.Signal // 0 for no checkpoint signal
+	CheckpointGrace  string
+	Cgroup           string
+}
+
+// buildSupervisorArgs returns the command line that starts the supervisor.
+// The supervisor has to heartbeat the reservation in the Redis it was made
+// in, so the config file, profile, and pool are passed on along with the
+// resolved Redis settings.
+func buildSupervisorArgs(executable string, config *types.Config, opts supervisorOptions) []string {
+	args := []string{executable, "supervisor"}
+	args = append(args, redisTargetArgs(config)...)
+	args = append(args,
+		"--gpus", opts.GPUs,
+		"--user", opts.User,
+		"--pid", strconv.Itoa(opts.PID),
+	)
+	if opts.Timeout != "" {
+		args = append(args, "--timeout", opts.Timeout)
+		args = append(args, "--expiry-warning", strconv.Itoa(opts.ExpiryWarning))
+	}
+	if opts.CheckpointSignal != 0 {
+		args = append(args, "--checkpoint-signal", signalName(opts.CheckpointSignal))
+		args = append(args, "--checkpoint-grace", opts.CheckpointGrace)
+	}
+	if opts.Cgroup != "" {
+		args = append(args, "--cgroup", opts.Cgroup)
+	}
+	return args
+}
+
+// redisTargetArgs returns the global flags that make another canhazgpu
+// process use the same config file, profile, pool, and Redis as this one
+func redisTargetArgs(config *types.Config) []string {
+	var args []string
+	if configFile != "" {
+		args = append(args, "--config", configFile)
+	}
+	if profileName != "" {
+		args = append(args, "--profile", profileName)
+	}
+	if poolName != "" {
+		args = append(args, "--pool", poolName)
+	}
+	args = append(args,
+		"--redis-host", config.RedisHost,
+		"--redis-port", strconv.Itoa(config.RedisPort),
+		"--redis-db", strconv.Itoa(config.RedisDB),
+	)
+	if config.RedisReadHost != "" {
+		args = append(args, "--redis-read-host", config.RedisReadHost)
+	}
+	if config.RedisReadPort != 0 {
+		args = append(args, "--redis-read-port", strconv.Itoa(config.RedisReadPort))
+	}
+	return args
+}
+
 // parseGPUList parses a comma-separated list of GPU IDs
 func parseGPUList(s string) ([]int, error) {
 	if s == "" {
@@ -80,8 +160,10 @@ func parseGPUList(s string) ([]int, error) {
 	return gpuIDs, nil
 }
 
-// runSupervisor runs the supervisor loop that monitors a process and maintains GPU heartbeats
-func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, timeout time.Duration, hasTimeout bool) error {
+// runSupervisor runs the supervisor loop that monitors a process and maintains GPU heartbeats.
+// With a checkpoint signal, the process is sent that signal when the timeout
+// is reached, and SIGINT only once checkpointGrace has passed.
+func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, timeout time.Duration, hasTimeout bool, expiryWarning int, checkpointSignal syscall.Signal, checkpointGrace time.Duration, cgroupPath string) error {
 	// Ignore SIGHUP so the supervisor survives SSH disconnects and terminal
 	// closures. The monitored process (e.g., vllm serve) may also ignore
 	// SIGHUP; if the supervisor died here, nobody would send heartbeats and
@@ -93,6 +175,11 @@ func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, time
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Remove the resource limit cgroup once the process is gone
+	if cgroupPath != "" {
+		defer removeCgroup(cgroupPath)
+	}
+
 	config := getConfig()
 	client := redis_client.NewClient(config)
 	defer func() {
@@ -108,6 +195,7 @@ func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, time
 
 	// Start heartbeat manager
 	heartbeat := gpu.NewHeartbeatManager(client, gpuIDs, user)
+	heartbeat.SetPID(pid)
 	if err := heartbeat.Start(); err != nil {
 		return fmt.Errorf("supervisor: failed to start heartbeat: %v", err)
 	}
@@ -115,11 +203,26 @@ func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, time
 
 	// Set up timeout handling if configured
 	var timeoutChan <-chan time.Time
+	var warningChan <-chan time.Time
 	if hasTimeout {
 		timer := time.NewTimer(timeout)
 		defer timer.Stop()
 		timeoutChan = timer.C
+
+		if warnAfter, ok := expiryWarningDelay(timeout, expiryWarning); ok {
+			warningTimer := time.NewTimer(warnAfter)
+			defer warningTimer.Stop()
+			warningChan = warningTimer.C
+		}
+	}
+	deadline := time.Now().Add(timeout)
+
+	// The signal the process gets when the timeout is reached
+	timeoutSignal := syscall.SIGINT
+	if checkpointSignal != 0 {
+		timeoutSignal = checkpointSignal
 	}
+	var checkpointChan <-chan time.Time
 
 	// Monitor the process
 	pollInterval := 500 * time.Millisecond
@@ -137,9 +240,43 @@ func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, time
 			gracefulKill(pid)
 			return nil
 
+		case <-heartbeat.Preempted():
+			fmt.Fprintf(os.Stderr, "supervisor: GPU reservation preempted by a higher-priority request, terminating process %d\n", pid)
+			gracefulKill(pid)
+			return nil
+
+		case <-heartbeat.Lost():
+			fmt.Fprintf(os.Stderr, "supervisor: heartbeats failed for longer than %s and the GPUs may have been reassigned, terminating process %d\n",
+				utils.FormatDuration(heartbeat.Timeout()), pid)
+			gracefulKill(pid)
+			return nil
+
+		case <-warningChan:
+			fmt.Fprintf(os.Stderr, "supervisor: warning: timeout reached in %s (at %s), process %d will then be sent %s\n",
+				utils.FormatDuration(time.Until(deadline)), deadline.Format("2006-01-02 15:04:05"), pid, signalName(timeoutSignal))
+
 		case <-timeoutChan:
-			fmt.Fprintf(os.Stderr, "supervisor: timeout reached after %s, sending SIGINT to process %d\n",
-				utils.FormatDuration(timeout), pid)
+			if checkpointSignal == 0 {
+				fmt.Fprintf(os.Stderr, "supervisor: timeout reached after %s, sending SIGINT to process %d\n",
+					utils.FormatDuration(timeout), pid)
+				gracefulKill(pid)
+				return nil
+			}
+
+			// Give the process a chance to save its work before it's stopped.
+			// Monitoring carries on, so that an early exit releases the GPUs.
+			fmt.Fprintf(os.Stderr, "supervisor: timeout reached after %s, sending %s to process %d to checkpoint, SIGINT follows in %s\n",
+				utils.FormatDuration(timeout), signalName(checkpointSignal), pid, utils.FormatDuration(checkpointGrace))
+			if err := signalProcessGroup(pid, checkpointSignal); err != nil {
+				fmt.Fprintf(os.Stderr, "supervisor: failed to send %s to process %d: %v\n", signalName(checkpointSignal), pid, err)
+			}
+			checkpointTimer := time.NewTimer(checkpointGrace)
+			defer checkpointTimer.Stop()
+			checkpointChan = checkpointTimer.C
+
+		case <-checkpointChan:
+			fmt.Fprintf(os.Stderr, "supervisor: checkpoint grace period of %s expired, sending SIGINT to process %d\n",
+				utils.FormatDuration(checkpointGrace), pid)
 			gracefulKill(pid)
 			return nil
 
@@ -154,30 +291,113 @@ func runSupervisor(ctx context.Context, gpuIDs []int, user string, pid int, time
 	}
 }
 
+// expiryWarningDelay returns how long after start to warn that the timeout is
+// approaching, given the percentage of the timeout that should elapse first.
+// It returns false if no warning should be given.
+func expiryWarningDelay(timeout time.Duration, percent int) (time.Duration, bool) {
+	if percent <= 0 || percent >= 100 || timeout <= 0 {
+		return 0, false
+	}
+	return timeout * time.Duration(percent) / 100, true
+}
+
+// killGracePeriod is how long processes are given to exit after the first
+// signal before they're sent SIGKILL
+const killGracePeriod = 30 * time.Second
+
 // gracefulKill sends SIGINT, waits a grace period, then SIGKILL if still running.
 func gracefulKill(pid int) {
-	if err := syscall.Kill(pid, syscall.SIGINT); err != nil {
-		if !isProcessRunning(pid) {
-			return
+	terminateProcesses([]int{pid}, syscall.SIGINT, killGracePeriod, "supervisor: ")
+}
+
+// terminateProcesses sends sig to each process, waits up to the grace period
+// for them to exit, then sends SIGKILL to any still running. A process that
+// leads its own process group is signalled along with the rest of its group,
+// so that child processes such as data loader or tensor parallel workers are
+// stopped too. Progress is written to stderr with the given prefix.
+func terminateProcesses(pids []int, sig syscall.Signal, gracePeriod time.Duration, prefix string) {
+	var running []int
+	for _, pid := range pids {
+		if err := signalProcessGroup(pid, sig); err != nil {
+			if !isProcessGroupRunning(pid) {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%sfailed to send %s to process %d: %v\n", prefix, signalName(sig), pid, err)
+		}
+		running = append(running, pid)
+	}
+	if len(running) == 0 {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%swaiting %s for graceful shutdown...\n", prefix, gracePeriod)
+	deadline := time.Now().Add(gracePeriod)
+	for {
+		var remaining []int
+		for _, pid := range running {
+			if isProcessGroupRunning(pid) {
+				remaining = append(remaining, pid)
+			}
+		}
+		running = remaining
+		if len(running) == 0 || !time.Now().Before(deadline) {
+			break
 		}
-		fmt.Fprintf(os.Stderr, "supervisor: failed to send SIGINT: %v\n", err)
+		time.Sleep(100 * time.Millisecond)
 	}
 
-	gracePeriod := 30 * time.Second
-	fmt.Fprintf(os.Stderr, "supervisor: waiting %s for graceful shutdown...\n", gracePeriod)
-	time.Sleep(gracePeriod)
+	for _, pid := range running {
+		fmt.Fprintf(os.Stderr, "%sgrace period expired, sending SIGKILL to process %d\n", prefix, pid)
+		if err := signalProcessGroup(pid, syscall.SIGKILL); err != nil {
+			fmt.Fprintf(os.Stderr, "%sfailed to send SIGKILL: %v\n", prefix, err)
+		}
+	}
+}
 
+// signalProcessGroup sends sig to the process group led by pid, or to just the
+// process if it doesn't lead a group
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
+
+// isProcessGroupRunning checks if the process, or any other process in the
+// group it leads, is still running
+func isProcessGroupRunning(pid int) bool {
 	if isProcessRunning(pid) {
-		fmt.Fprintf(os.Stderr, "supervisor: grace period expired, sending SIGKILL to process %d\n", pid)
-		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
-			fmt.Fprintf(os.Stderr, "supervisor: failed to send SIGKILL: %v\n", err)
+		return true
+	}
+	// The leader may have exited before the rest of its group. A pgid can
+	// only be reused once the whole group is gone.
+	return syscall.Kill(-pid, 0) == nil
+}
+
+// signalName returns the conventional name of the signals canhazgpu sends
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	}
+	for name, s := range checkpointSignals {
+		if s == sig {
+			return name
 		}
 	}
+	return sig.String()
 }
 
 // isProcessRunning checks if a process with the given PID is still running
 func isProcessRunning(pid int) bool {
 	// Sending signal 0 checks if process exists without actually sending a signal
-	err := syscall.Kill(pid, 0)
-	return err == nil
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	// A zombie still exists until its parent reaps it, but it's not running
+	return !gpu.IsZombieProcess(pid)
 }
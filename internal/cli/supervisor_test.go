@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bufio"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiryWarningDelay(t *testing.T) {
+	delay, ok := expiryWarningDelay(10*time.Hour, 90)
+	assert.True(t, ok)
+	assert.Equal(t, 9*time.Hour, delay)
+
+	delay, ok = expiryWarningDelay(2*time.Hour, 75)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Minute, delay)
+
+	// Disabled or out of range
+	_, ok = expiryWarningDelay(2*time.Hour, 0)
+	assert.False(t, ok)
+	_, ok = expiryWarningDelay(2*time.Hour, 100)
+	assert.False(t, ok)
+	_, ok = expiryWarningDelay(0, 90)
+	assert.False(t, ok)
+}
+
+func TestTerminateProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires process groups")
+	}
+
+	// A process group that ignores SIGTERM, so it has to be killed
+	cmd := exec.Command("sh", "-c", "trap '' TERM; echo ready; sleep 60; true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	ready, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ready\n", ready)
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+
+	start := time.Now()
+	terminateProcesses([]int{cmd.Process.Pid}, syscall.SIGTERM, 500*time.Millisecond, "test: ")
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond, "SIGTERM was ignored, so SIGKILL is needed")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process group was not killed")
+	}
+}
+
+func TestTerminateProcessesExitsEarly(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires process groups")
+	}
+
+	cmd := exec.Command("sleep", "60")
+	require.NoError(t, cmd.Start())
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	start := time.Now()
+	terminateProcesses([]int{cmd.Process.Pid}, syscall.SIGTERM, 10*time.Second, "test: ")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestBuildSupervisorArgs(t *testing.T) {
+	defer func(file, profile, pool string) {
+		configFile, profileName, poolName = file, profile, pool
+	}(configFile, profileName, poolName)
+	configFile, profileName, poolName = "", "", ""
+
+	config := &types.Config{RedisHost: "localhost", RedisPort: 6379}
+	args := buildSupervisorArgs("/usr/bin/canhazgpu", config, supervisorOptions{GPUs: "0,1", User: "alice", PID: 42})
+	assert.Equal(t, []string{
+		"/usr/bin/canhazgpu", "supervisor",
+		"--redis-host", "localhost", "--redis-port", "6379", "--redis-db", "0",
+		"--gpus", "0,1", "--user", "alice", "--pid", "42",
+	}, args)
+
+	// A pool or profile selects another Redis, which the supervisor has to
+	// heartbeat the reservation in
+	configFile, profileName, poolName = "/etc/canhazgpu.yaml", "cluster-b", "training"
+	config = &types.Config{RedisHost: "redis-b", RedisPort: 6380, RedisDB: 2, RedisReadHost: "replica-b", RedisReadPort: 6381}
+	args = buildSupervisorArgs("/usr/bin/canhazgpu", config, supervisorOptions{
+		GPUs:             "3",
+		User:             "bob",
+		PID:              7,
+		Timeout:          "2h",
+		ExpiryWarning:    90,
+		CheckpointSignal: syscall.SIGUSR1,
+		CheckpointGrace:  "5m",
+		Cgroup:           "/sys/fs/cgroup/canhazgpu-7",
+	})
+	assert.Equal(t, []string{
+		"/usr/bin/canhazgpu", "supervisor",
+		"--config", "/etc/canhazgpu.yaml", "--profile", "cluster-b", "--pool", "training",
+		"--redis-host", "redis-b", "--redis-port", "6380", "--redis-db", "2",
+		"--redis-read-host", "replica-b", "--redis-read-port", "6381",
+		"--gpus", "3", "--user", "bob", "--pid", "7",
+		"--timeout", "2h", "--expiry-warning", "90",
+		"--checkpoint-signal", "SIGUSR1", "--checkpoint-grace", "5m",
+		"--cgroup", "/sys/fs/cgroup/canhazgpu-7",
+	}, args)
+
+	// Every flag is one the supervisor accepts
+	for _, arg := range args[2:] {
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			flag := supervisorCmd.Flags().Lookup(name)
+			if flag == nil {
+				flag = supervisorCmd.InheritedFlags().Lookup(name)
+			}
+			assert.NotNil(t, flag, name)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait until a remote host has enough GPUs available",
+	Long: `Wait until a remote host has at least the given number of GPUs available,
+for launch scripts that coordinate jobs across nodes.
+
+The host's status is read over SSH like 'status --remote', right away and
+then again after 5 seconds, backing off to once a minute. canhazgpu exits
+with status 0 as soon as enough GPUs are AVAILABLE, or fails once --timeout
+has passed. Failures to read the host's status are reported and retried
+until then.
+
+Nothing is reserved: another user may take the GPUs before your job starts
+there, so the job itself should still reserve them with 'canhazgpu run'.
+
+Example usage:
+  canhazgpu wait --remote gpu-node-2 --gpus 4
+  canhazgpu wait --remote gpu-node-2 --gpus 8 --timeout 2h && ssh gpu-node-2 ./launch.sh`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host := viper.GetString("wait.remote")
+		gpuCount := viper.GetInt("wait.gpus")
+		timeoutStr := viper.GetString("wait.timeout")
+
+		if host == "" {
+			return invalidArgument(fmt.Errorf("--remote is required"))
+		}
+		if gpuCount <= 0 {
+			return invalidArgument(fmt.Errorf("GPU count must be greater than 0"))
+		}
+		var timeout time.Duration
+		if timeoutStr != "" {
+			t, err := utils.ParseDuration(timeoutStr)
+			if err != nil {
+				return invalidArgument(fmt.Errorf("invalid timeout format: %v", err))
+			}
+			timeout = t
+		}
+
+		return runWait(cmd.Context(), host, gpuCount, timeout)
+	},
+}
+
+func init() {
+	waitCmd.Flags().StringP("remote", "r", "", "Remote host to wait for (required)")
+	waitCmd.Flags().IntP("gpus", "g", 1, "Number of GPUs that must be available")
+	waitCmd.Flags().StringP("timeout", "t", "", "Maximum time to wait (e.g., 30m, 2h). Default: wait forever.")
+
+	rootCmd.AddCommand(waitCmd)
+}
+
+// Polling intervals for 'canhazgpu wait': the second check is
+// waitInitialInterval after the first, and each later one waits twice as
+// long as the one before, up to waitMaxInterval
+const (
+	waitInitialInterval = 5 * time.Second
+	waitMaxInterval     = time.Minute
+)
+
+func runWait(ctx context.Context, host string, gpuCount int, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	fmt.Printf("Waiting for %d GPU(s) to be available on %s...\n", gpuCount, host)
+	available, err := waitForAvailableGPUs(ctx, func(ctx context.Context) ([]gpu.GPUStatusInfo, error) {
+		return getRemoteStatus(ctx, host)
+	}, gpuCount, waitInitialInterval, waitMaxInterval)
+	if err != nil {
+		return fmt.Errorf("%s: %w", host, err)
+	}
+
+	fmt.Printf("%s has %d GPU(s) available: %v\n", host, len(available), available)
+	return nil
+}
+
+// waitForAvailableGPUs checks the statuses returned by getStatus, starting
+// immediately and backing off from interval to maxInterval between checks,
+// until at least gpuCount GPUs are AVAILABLE. Returns the IDs of the
+// available GPUs, or an error once ctx is done.
+func waitForAvailableGPUs(ctx context.Context, getStatus func(context.Context) ([]gpu.GPUStatusInfo, error), gpuCount int, interval, maxInterval time.Duration) ([]int, error) {
+	var lastErr error
+	lastAvailable := 0
+	for {
+		statuses, err := getStatus(ctx)
+		if err == nil {
+			available := availableGPUIDs(statuses)
+			if len(available) >= gpuCount {
+				return available, nil
+			}
+			lastErr = nil
+			lastAvailable = len(available)
+		} else if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to get status: %v (retrying in %s)\n", err, interval)
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, withCode(ErrCodeRemoteUnreachable, fmt.Errorf("gave up waiting for %d GPU(s): failed to get status: %v", gpuCount, lastErr))
+			}
+			return nil, fmt.Errorf("gave up waiting for %d GPU(s): only %d available", gpuCount, lastAvailable)
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, maxInterval)
+	}
+}
+
+// availableGPUIDs returns the IDs of the GPUs that are AVAILABLE
+func availableGPUIDs(statuses []gpu.GPUStatusInfo) []int {
+	var ids []int
+	for _, status := range statuses {
+		if status.Status == "AVAILABLE" {
+			ids = append(ids, status.GPUID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/gpu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailableGPUIDs(t *testing.T) {
+	statuses := []gpu.GPUStatusInfo{
+		{GPUID: 0, Status: "AVAILABLE"},
+		{GPUID: 1, Status: "IN_USE"},
+		{GPUID: 2, Status: "UNRESERVED"},
+		{GPUID: 3, Status: "AVAILABLE"},
+	}
+	assert.Equal(t, []int{0, 3}, availableGPUIDs(statuses))
+	assert.Empty(t, availableGPUIDs(nil))
+}
+
+func TestWaitForAvailableGPUs(t *testing.T) {
+	// Enough GPUs become available on the third check, after a failed one
+	calls := 0
+	getStatus := func(context.Context) ([]gpu.GPUStatusInfo, error) {
+		calls++
+		switch calls {
+		case 1:
+			return []gpu.GPUStatusInfo{{GPUID: 0, Status: "AVAILABLE"}, {GPUID: 1, Status: "IN_USE"}}, nil
+		case 2:
+			return nil, errors.New("ssh: connection refused")
+		default:
+			return []gpu.GPUStatusInfo{{GPUID: 0, Status: "AVAILABLE"}, {GPUID: 1, Status: "AVAILABLE"}}, nil
+		}
+	}
+
+	available, err := waitForAvailableGPUs(context.Background(), getStatus, 2, time.Millisecond, 2*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, available)
+	assert.Equal(t, 3, calls)
+}
+
+func TestWaitForAvailableGPUs_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := waitForAvailableGPUs(ctx, func(context.Context) ([]gpu.GPUStatusInfo, error) {
+		return []gpu.GPUStatusInfo{{GPUID: 0, Status: "AVAILABLE"}}, nil
+	}, 2, time.Millisecond, 5*time.Millisecond)
+	assert.EqualError(t, err, "gave up waiting for 2 GPU(s): only 1 available")
+	assert.Equal(t, ErrCodeUnknown, errorCode(err))
+
+	// A host that can't be reached is reported as such
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = waitForAvailableGPUs(ctx, func(context.Context) ([]gpu.GPUStatusInfo, error) {
+		return nil, errors.New("ssh: connection refused")
+	}, 1, time.Millisecond, 5*time.Millisecond)
+	assert.ErrorContains(t, err, "failed to get status: ssh: connection refused")
+	assert.Equal(t, ErrCodeRemoteUnreachable, errorCode(err))
+}
@@ -17,12 +17,15 @@ import (
 	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/russellb/canhazgpu/internal/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	webPort int
-	webHost string
-	webDemo bool
+	webPort   int
+	webHost   string
+	webDemo   bool
+	webRemote string
+	webAll    bool
 )
 
 //go:embed static/*
@@ -31,14 +34,27 @@ var staticFiles embed.FS
 var webCmd = &cobra.Command{
 	Use:   "web",
 	Short: "Start a web server for GPU status monitoring",
-	Long:  `Start a web server that provides a dashboard for monitoring GPU status and usage reports.`,
-	RunE:  runWeb,
+	Long: `Start a web server that provides a dashboard for monitoring GPU status and usage reports.
+
+By default the dashboard shows the local GPU pool, with a hosts overview when
+remote hosts are configured. Use --remote <host> to show a single remote host's
+GPUs instead, or --all to show the GPUs of localhost and every configured remote
+host together in one view, each labeled with its host. Remote hosts are queried
+over SSH, as with 'canhazgpu status --remote'.
+
+Example usage:
+  canhazgpu web
+  canhazgpu web --remote gpu-server-1
+  canhazgpu web --all --port 8000`,
+	RunE: runWeb,
 }
 
 func init() {
 	webCmd.Flags().IntVarP(&webPort, "port", "p", 8080, "Port to run the web server on")
 	webCmd.Flags().StringVar(&webHost, "host", "0.0.0.0", "Host to bind the web server to")
 	webCmd.Flags().BoolVar(&webDemo, "demo", false, "Run in demo mode with simulated data")
+	webCmd.Flags().StringVarP(&webRemote, "remote", "r", "", "Show GPUs for a specific remote host instead of localhost")
+	webCmd.Flags().BoolVar(&webAll, "all", false, "Show GPUs for localhost and all configured remote hosts in one view")
 	rootCmd.AddCommand(webCmd)
 }
 
@@ -47,10 +63,22 @@ func runWeb(cmd *cobra.Command, args []string) error {
 
 	var server *webServer
 
+	if webAll && webRemote != "" {
+		return fmt.Errorf("cannot use --all and --remote together")
+	}
+
 	// Get config (needed for both demo and normal mode for remote hosts)
 	config := getConfig()
 
-	if webDemo {
+	if webRemote != "" {
+		// Remote mode - the remote host's own Redis is queried over SSH
+		fmt.Printf("Showing GPUs for remote host %s\n", webRemote)
+		server = &webServer{
+			config:     config,
+			demo:       webDemo,
+			remoteHost: webRemote,
+		}
+	} else if webDemo {
 		// Demo mode - no Redis connection needed
 		fmt.Println("Starting web server in DEMO mode")
 		server = &webServer{
@@ -59,6 +87,7 @@ func runWeb(cmd *cobra.Command, args []string) error {
 			config:         config,
 			demo:           true,
 			localhostAvail: true, // Demo mode simulates localhost
+			aggregate:      webAll,
 		}
 	} else {
 		// Initialize Redis client
@@ -105,6 +134,7 @@ func runWeb(cmd *cobra.Command, args []string) error {
 			config:         config,
 			demo:           false,
 			localhostAvail: localhostAvail,
+			aggregate:      webAll,
 		}
 	}
 
@@ -115,8 +145,11 @@ func runWeb(cmd *cobra.Command, args []string) error {
 	http.HandleFunc("/api/hosts/status", server.handleAPIHostsStatus)
 	http.HandleFunc("/api/report", server.handleAPIReport)
 	http.HandleFunc("/api/queue", server.handleAPIQueue)
+	http.HandleFunc("/api/history", server.handleAPIHistory)
 	http.Handle("/static/", http.FileServer(http.FS(staticFiles)))
 
+	server.providerIcons = modelProviderIcons(viper.GetViper())
+
 	// Start server
 	addr := fmt.Sprintf("%s:%d", webHost, webPort)
 	fmt.Printf("Starting web server on http://%s\n", addr)
@@ -128,7 +161,13 @@ type webServer struct {
 	engine         *gpu.AllocationEngine
 	config         *types.Config
 	demo           bool
-	localhostAvail bool // Whether localhost Redis is available
+	localhostAvail bool   // Whether localhost Redis is available
+	remoteHost     string // If set, show only this remote host (--remote)
+	aggregate      bool   // Show all hosts' GPUs together (--all)
+
+	// Extra model provider icons from model_provider_icons, as HTML keyed by
+	// lowercase provider name
+	providerIcons map[string]string
 }
 
 func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -324,6 +363,11 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             fill: var(--accent-color);
             opacity: 0.8;
         }
+        .model-icon img {
+            width: 20px;
+            height: 20px;
+            object-fit: contain;
+        }
         .expand-icon {
             width: 20px;
             height: 20px;
@@ -397,6 +441,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
         .memory-low { background: var(--accent-color); }
         .memory-medium { background: #FF9800; }
         .memory-high { background: #f44336; }
+        .utilization-fill { background: #2196F3; }
         .memory-text {
             font-family: 'SF Mono', Monaco, monospace;
             font-size: 0.8em;
@@ -410,6 +455,10 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
         .gpu-details div {
             margin: 5px 0;
         }
+        .gpu-details .gpu-annotation {
+            border-left: 3px solid #f9a825;
+            padding-left: 8px;
+        }
         .controls {
             display: flex;
             gap: 20px;
@@ -788,13 +837,14 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             <div class="controls">
                 <button onclick="refreshStatus()">↻ Refresh</button>
                 <button onclick="toggleExpandAll()" id="expand-all-btn">⤧ Expand All</button>
+                <button onclick="toggleGroupByJob()" id="group-by-job-btn" title="Show GPUs reserved together on one card">⧉ Group by Job</button>
                 <div class="timestamp" id="status-timestamp"></div>
             </div>
             <div id="gpu-status" class="loading">Loading GPU status...</div>
         </div>
 
         <!-- Queue Section -->
-        <div class="section{{if .MultiHost}} hidden{{end}}" id="queue-section">
+        <div class="section{{if or .MultiHost (not .ShowQueue)}} hidden{{end}}" id="queue-section">
             <h2>Reservation Queue</h2>
             <div class="controls">
                 <button onclick="refreshQueue()">↻ Refresh</button>
@@ -803,7 +853,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             <div id="queue-status" class="loading">Loading queue status...</div>
         </div>
 
-        <div class="section{{if .MultiHost}} hidden{{end}}" id="report-section">
+        <div class="section{{if or .MultiHost (not .ShowReport)}} hidden{{end}}" id="report-section">
             <h2>GPU Reservation Report</h2>
             <div class="controls">
                 <div class="control-group">
@@ -819,6 +869,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                     </select>
                 </div>
                 <button onclick="refreshReport()">↻ Refresh</button>
+                <button onclick="downloadReport()">⬇ Download CSV</button>
                 <div class="timestamp" id="report-timestamp"></div>
             </div>
             <div id="usage-report" class="loading">Loading reservation report...</div>
@@ -834,6 +885,9 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
         // Multi-host state
         const isMultiHost = {{.MultiHost}};
         const localhostAvail = {{.LocalhostAvail}};
+        const showQueue = {{.ShowQueue}};
+        const showReport = {{.ShowReport}};
+        const customProviderIcons = {{.ProviderIcons}};
         let selectedHost = null;
         let hostsData = [];
 
@@ -888,6 +942,15 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        function downloadReport() {
+            const days = document.getElementById('days-select').value;
+            let url = '/api/report?format=csv&days=' + days;
+            if (selectedHost) {
+                url += '&host=' + encodeURIComponent(selectedHost);
+            }
+            window.location.href = url;
+        }
+
         async function fetchQueue() {
             try {
                 const response = await fetch('/api/queue');
@@ -921,6 +984,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             html += '<th>Requested</th>';
             html += '<th>Progress</th>';
             html += '<th>Waiting</th>';
+            html += '<th title="Rough estimate based on recent usage history">ETA (est.)</th>';
             html += '</tr></thead>';
             html += '<tbody>';
 
@@ -945,6 +1009,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                 html += '<span>' + allocated + '/' + total + '</span>';
                 html += '</div></td>';
                 html += '<td class="queue-wait-time ' + waitTimeClass + '">' + formatDuration(waitSeconds) + '</td>';
+                html += '<td class="queue-eta">' + (entry.estimated_wait || '-') + '</td>';
                 html += '</tr>';
             });
 
@@ -991,6 +1056,14 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        // escapeHTML makes free text, such as admin annotations, safe to
+        // insert into the page
+        function escapeHTML(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML;
+        }
+
         function formatTimestamp(timestamp) {
             if (!timestamp) return 'never';
             const date = new Date(timestamp);
@@ -1028,6 +1101,11 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
         function getProviderIcon(provider) {
             // Convert to lowercase for case-insensitive comparison
             const providerLower = provider ? provider.toLowerCase() : '';
+
+            // Icons from the model_provider_icons config option come first
+            if (Object.prototype.hasOwnProperty.call(customProviderIcons, providerLower)) {
+                return customProviderIcons[providerLower];
+            }
             
             switch (providerLower) {
                 case 'openai':
@@ -1230,6 +1308,48 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        // groupByJob is toggled from the controls and remembered across visits
+        let groupByJob = localStorage.getItem('groupByJob') === 'true';
+        let lastStatusData = null;
+
+        // groupStatusCards splits statuses into the GPUs shown on each card.
+        // With grouping on, GPUs reserved by the same request share a card.
+        function groupStatusCards(data) {
+            if (!groupByJob) {
+                return data.map(gpu => [gpu]);
+            }
+
+            const cards = [];
+            const jobs = {};
+            data.forEach(gpu => {
+                if (!gpu.job_id) {
+                    cards.push([gpu]);
+                    return;
+                }
+                // Job IDs are only unique within a host
+                const jobKey = (gpu.host || '') + '|' + gpu.job_id;
+                if (!jobs[jobKey]) {
+                    jobs[jobKey] = [];
+                    cards.push(jobs[jobKey]);
+                }
+                jobs[jobKey].push(gpu);
+            });
+            return cards;
+        }
+
+        function toggleGroupByJob() {
+            groupByJob = !groupByJob;
+            localStorage.setItem('groupByJob', groupByJob);
+            updateGroupByJobButton();
+            if (lastStatusData) {
+                renderStatus(lastStatusData);
+            }
+        }
+
+        function updateGroupByJobButton() {
+            document.getElementById('group-by-job-btn').textContent = groupByJob ? '⧉ Ungroup Jobs' : '⧉ Group by Job';
+        }
+
         function renderStatus(data) {
             const container = document.getElementById('gpu-status');
             
@@ -1237,12 +1357,13 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                 container.innerHTML = '<div class="error">No GPU data available</div>';
                 return;
             }
+            lastStatusData = data;
 
             // Save current expanded state before re-rendering
             const expandedStates = {};
             const existingCards = container.querySelectorAll('.gpu-card');
             existingCards.forEach(card => {
-                const gpuId = card.getAttribute('data-gpu-id');
+                const gpuId = card.getAttribute('data-gpu-key');
                 if (gpuId) {
                     expandedStates[gpuId] = card.classList.contains('expanded');
                 }
@@ -1250,7 +1371,9 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 
             let html = '<div class="gpu-grid">';
             
-            data.forEach(gpu => {
+            groupStatusCards(data).forEach(members => {
+                const gpu = members[0];
+                const gpuIds = members.map(member => member.gpu_id).join(',');
                 const statusClass = gpu.status.toLowerCase().replace('_', '-');
                 let statusText = gpu.status.replace('_', ' ');
                 
@@ -1272,11 +1395,22 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                     summary = 'Last released ' + formatTimestamp(gpu.last_released);
                 }
                 
+                // GPU IDs repeat across hosts when showing several hosts
+                const cardID = members.length > 1 ? 'job:' + gpu.job_id : String(gpu.gpu_id);
+                const gpuKey = gpu.host ? gpu.host + ':' + cardID : cardID;
+                let gpuLabel = (members.length > 1 ? 'GPUs ' : 'GPU ') + gpuIds;
+                if (gpu.host) {
+                    gpuLabel = gpu.gpu_id >= 0 ? gpu.host + ' / ' + gpuLabel : gpu.host;
+                }
+                if (gpu.status === 'ERROR' && gpu.error && !summary) {
+                    summary = gpu.error;
+                }
+
                 // Check if this GPU was previously expanded
-                const isExpanded = expandedStates[gpu.gpu_id] || false;
+                const isExpanded = expandedStates[gpuKey] || false;
                 const expandedClass = isExpanded ? ' expanded' : '';
                 
-                html += '<div class="gpu-card' + expandedClass + '" data-gpu-id="' + gpu.gpu_id + '" onclick="toggleCard(this)">';
+                html += '<div class="gpu-card' + expandedClass + '" data-gpu-id="' + gpuIds + '" data-gpu-key="' + gpuKey + '" onclick="toggleCard(this)">';
                 html += '<div class="gpu-header">';
                 html += '<div class="gpu-header-left">';
                 html += '<svg class="expand-icon" viewBox="0 0 24 24">';
@@ -1292,7 +1426,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                 html += '</div>';
                 
                 html += '<div class="gpu-header-center">';
-                html += '<div class="gpu-id">GPU ' + gpu.gpu_id + '</div>';
+                html += '<div class="gpu-id">' + gpuLabel + '</div>';
                 
                 if (summary) {
                     html += '<div class="gpu-summary">' + summary + '</div>';
@@ -1327,40 +1461,17 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                             html += '<div><strong>Expires in:</strong> ' + formatDuration(expiresIn / 1000) + '</div>';
                         }
                     }
-                }
-                
-                if (gpu.validation_info) {
-                    html += '<div><strong>Validation:</strong> ' + gpu.validation_info + '</div>';
-                    
-                    // Add memory usage bar if validation info contains memory data
-                    const memoryMatch = gpu.validation_info.match(/(\d+)MB/);
-                    if (memoryMatch) {
-                        const memoryMB = parseInt(memoryMatch[1]);
-                        const maxMemoryMB = 80000; // Rough estimate for H100
-                        const percentage = Math.min((memoryMB / maxMemoryMB) * 100, 100);
-                        let memoryClass = 'memory-low';
-                        if (percentage > 70) memoryClass = 'memory-high';
-                        else if (percentage > 40) memoryClass = 'memory-medium';
-                        
-                        html += '<div class="memory-usage">';
-                        html += '<div class="memory-bar">';
-                        html += '<div class="memory-fill ' + memoryClass + '" style="width: ' + percentage + '%"></div>';
-                        html += '</div>';
-                        html += '<div class="memory-text">' + percentage.toFixed(1) + '%</div>';
-                        html += '</div>';
+
+                    if (gpu.annotation) {
+                        const by = gpu.annotated_by ? ' (' + escapeHTML(gpu.annotated_by) + ')' : '';
+                        html += '<div class="gpu-annotation"><strong>Admin note' + by + ':</strong> ' + escapeHTML(gpu.annotation) + '</div>';
                     }
                 }
                 
-                // Show 0% memory usage for GPUs that are in use but have no detected usage
-                if (gpu.status === 'IN_USE' && (!gpu.validation_info || 
-                    (gpu.validation_info && gpu.validation_info.includes('no usage detected')))) {
-                    html += '<div class="memory-usage">';
-                    html += '<div class="memory-bar">';
-                    html += '<div class="memory-fill memory-low" style="width: 0%"></div>';
-                    html += '</div>';
-                    html += '<div class="memory-text">0.0%</div>';
-                    html += '</div>';
-                }
+                // A grouped card shows the usage of each of its GPUs
+                members.forEach(member => {
+                    html += renderGPUUsage(member, members.length > 1 ? 'GPU ' + member.gpu_id : 'Validation');
+                });
                 
                 if (gpu.model_info && gpu.model_info.model) {
                     html += '<div><strong>Model:</strong> ' + gpu.model_info.model + '</div>';
@@ -1387,6 +1498,56 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
             document.getElementById('status-timestamp').textContent = 'Last updated: ' + formatCompactTime(new Date());
         }
 
+        // renderGPUUsage renders a GPU's validation info with memory and
+        // compute utilization bars
+        function renderGPUUsage(member, label) {
+            let html = '';
+            if (member.validation_info) {
+                html += '<div><strong>' + label + ':</strong> ' + member.validation_info + '</div>';
+
+                // Add memory usage bar if validation info contains memory data
+                const memoryMatch = member.validation_info.match(/(\d+)MB/);
+                if (memoryMatch) {
+                    const memoryMB = parseInt(memoryMatch[1]);
+                    const maxMemoryMB = 80000; // Rough estimate for H100
+                    const percentage = Math.min((memoryMB / maxMemoryMB) * 100, 100);
+                    let memoryClass = 'memory-low';
+                    if (percentage > 70) memoryClass = 'memory-high';
+                    else if (percentage > 40) memoryClass = 'memory-medium';
+
+                    html += '<div class="memory-usage">';
+                    html += '<div class="memory-bar">';
+                    html += '<div class="memory-fill ' + memoryClass + '" style="width: ' + percentage + '%"></div>';
+                    html += '</div>';
+                    html += '<div class="memory-text">' + percentage.toFixed(1) + '%</div>';
+                    html += '</div>';
+                }
+            }
+
+            // Add compute utilization bar if the provider reports it, so
+            // reservations holding memory without computing stand out
+            if (member.utilization !== undefined && member.utilization !== null) {
+                html += '<div class="memory-usage" title="Compute utilization">';
+                html += '<div class="memory-bar">';
+                html += '<div class="memory-fill utilization-fill" style="width: ' + Math.min(member.utilization, 100) + '%"></div>';
+                html += '</div>';
+                html += '<div class="memory-text">' + member.utilization + '% util</div>';
+                html += '</div>';
+            }
+
+            // Show 0% memory usage for GPUs that are in use but have no detected usage
+            if (member.status === 'IN_USE' && (!member.validation_info || 
+                (member.validation_info && member.validation_info.includes('no usage detected')))) {
+                html += '<div class="memory-usage">';
+                html += '<div class="memory-bar">';
+                html += '<div class="memory-fill memory-low" style="width: 0%"></div>';
+                html += '</div>';
+                html += '<div class="memory-text">0.0%</div>';
+                html += '</div>';
+            }
+            return html;
+        }
+
         function renderReport(data) {
             const container = document.getElementById('usage-report');
             
@@ -1487,15 +1648,19 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
         } else {
             // Single-host mode: standard behavior
             refreshStatus();
-            refreshReport();
-            refreshQueue();
 
             // Auto-refresh status every 30 seconds
             statusRefreshInterval = setInterval(refreshStatus, 30000);
-            // Auto-refresh report every 5 minutes
-            reportRefreshInterval = setInterval(refreshReport, 300000);
-            // Auto-refresh queue every 5 seconds
-            queueRefreshInterval = setInterval(refreshQueue, 5000);
+            if (showReport) {
+                refreshReport();
+                // Auto-refresh report every 5 minutes
+                reportRefreshInterval = setInterval(refreshReport, 300000);
+            }
+            if (showQueue) {
+                refreshQueue();
+                // Auto-refresh queue every 5 seconds
+                queueRefreshInterval = setInterval(refreshQueue, 5000);
+            }
         }
 
         // Clean up intervals when page is hidden
@@ -1513,11 +1678,15 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
                 } else {
                     // Single-host mode or host selected: refresh status, report, and queue
                     refreshStatus();
-                    refreshReport();
-                    refreshQueue();
                     statusRefreshInterval = setInterval(refreshStatus, 30000);
-                    reportRefreshInterval = setInterval(refreshReport, 300000);
-                    queueRefreshInterval = setInterval(refreshQueue, 5000);
+                    if (showReport) {
+                        refreshReport();
+                        reportRefreshInterval = setInterval(refreshReport, 300000);
+                    }
+                    if (showQueue) {
+                        refreshQueue();
+                        queueRefreshInterval = setInterval(refreshQueue, 5000);
+                    }
                 }
             }
         });
@@ -1609,6 +1778,7 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 
         // Initialize theme on page load
         initTheme();
+        updateGroupByJobButton();
 
         // Listen for system theme changes
         window.matchMedia('(prefers-color-scheme: dark)').addEventListener('change', (e) => {
@@ -1638,7 +1808,19 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	// Enable multi-host view if:
 	// 1. Remote hosts are configured, OR
 	// 2. Localhost is not available (only remote hosts can be shown)
-	multiHost := ws.config != nil && len(ws.config.RemoteHosts) > 0
+	// --remote and --all each show a single GPU view instead
+	multiHost := ws.config != nil && len(ws.config.RemoteHosts) > 0 && ws.remoteHost == "" && !ws.aggregate
+
+	if ws.remoteHost != "" {
+		hostname = ws.remoteHost
+	} else if ws.aggregate {
+		hostname = "all hosts"
+	}
+
+	providerIcons := ws.providerIcons
+	if providerIcons == nil {
+		providerIcons = map[string]string{}
+	}
 
 	w.Header().Set("Content-Type", "text/html")
 	if err := t.Execute(w, struct {
@@ -1646,11 +1828,17 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		Demo           bool
 		MultiHost      bool
 		LocalhostAvail bool
+		ShowQueue      bool
+		ShowReport     bool
+		ProviderIcons  map[string]string
 	}{
 		Hostname:       hostname,
 		Demo:           ws.demo,
 		MultiHost:      multiHost,
 		LocalhostAvail: ws.localhostAvail,
+		ShowQueue:      ws.remoteHost == "" && !ws.aggregate, // The queue is only read from local Redis
+		ShowReport:     !ws.aggregate,
+		ProviderIcons:  providerIcons,
 	}); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 		return
@@ -1660,34 +1848,62 @@ func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 func (ws *webServer) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Check if localhost is available
-	if !ws.localhostAvail && !ws.demo {
-		http.Error(w, "localhost not available (Redis connection failed)", http.StatusServiceUnavailable)
-		return
-	}
+	var jsonStatuses []jsonGPUStatus
 
-	var statuses []gpu.GPUStatusInfo
-	var err error
-
-	if ws.demo {
-		// Use demo data
-		statuses = ws.generateDemoStatus()
+	if ws.remoteHost != "" {
+		// Show the remote host's GPUs
+		statuses, err := ws.getRemoteHostStatus(ctx, ws.remoteHost)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get GPU status from %s: %v", ws.remoteHost, err), http.StatusInternalServerError)
+			return
+		}
+		jsonStatuses = tagJSONStatusesWithHost(convertToJSONStatuses(statuses), ws.remoteHost)
+	} else if ws.aggregate {
+		// Show every host's GPUs together. A host that can't be reached gets a
+		// single ERROR entry rather than failing the whole response.
+		jsonStatuses = []jsonGPUStatus{}
+		for _, result := range ws.getHostStatuses(ctx) {
+			if result.err != nil {
+				jsonStatuses = append(jsonStatuses, jsonGPUStatus{
+					GPUID:  -1,
+					Status: "ERROR",
+					Error:  result.err.Error(),
+					Host:   result.host,
+				})
+				continue
+			}
+			jsonStatuses = append(jsonStatuses, tagJSONStatusesWithHost(convertToJSONStatuses(result.statuses), result.host)...)
+		}
 	} else {
-		// Clean up expired reservations first
-		if err := ws.engine.CleanupExpiredReservations(ctx); err != nil {
-			// Log but don't fail
-			fmt.Printf("Warning: Failed to cleanup expired reservations: %v\n", err)
+		// Check if localhost is available
+		if !ws.localhostAvail && !ws.demo {
+			http.Error(w, "localhost not available (Redis connection failed)", http.StatusServiceUnavailable)
+			return
 		}
 
-		statuses, err = ws.engine.GetGPUStatus(ctx)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get GPU status: %v", err), http.StatusInternalServerError)
-			return
+		var statuses []gpu.GPUStatusInfo
+		var err error
+
+		if ws.demo {
+			// Use demo data
+			statuses = ws.generateDemoStatus()
+		} else {
+			// Clean up expired reservations first
+			if err := ws.engine.CleanupExpiredReservations(ctx); err != nil {
+				// Log but don't fail
+				fmt.Printf("Warning: Failed to cleanup expired reservations: %v\n", err)
+			}
+
+			statuses, err = ws.engine.GetGPUStatus(ctx)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to get GPU status: %v", err), http.StatusInternalServerError)
+				return
+			}
 		}
-	}
 
-	// Convert to JSON-friendly format using shared function
-	jsonStatuses := convertToJSONStatuses(statuses)
+		// Convert to JSON-friendly format using shared function
+		jsonStatuses = convertToJSONStatuses(statuses)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(jsonStatuses); err != nil {
@@ -1696,6 +1912,52 @@ func (ws *webServer) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// getHostStatuses returns the status of every host shown by the dashboard:
+// the --remote host alone, or localhost (if available) and all configured
+// remote hosts
+func (ws *webServer) getHostStatuses(ctx context.Context) []hostResult {
+	if ws.remoteHost != "" {
+		statuses, err := ws.getRemoteHostStatus(ctx, ws.remoteHost)
+		return []hostResult{{host: ws.remoteHost, statuses: statuses, err: err}}
+	}
+
+	if ws.demo {
+		// In demo mode, generate demo data for localhost and fake remote hosts
+		results := []hostResult{
+			{host: "localhost", statuses: ws.generateDemoStatus()},
+		}
+		// Add demo remote hosts if configured
+		if ws.config != nil && len(ws.config.RemoteHosts) > 0 {
+			for _, h := range ws.config.RemoteHostNames() {
+				results = append(results, hostResult{
+					host:     h,
+					statuses: ws.generateDemoRemoteStatus(h),
+				})
+			}
+		}
+		return results
+	}
+
+	// Get all host statuses, excluding localhost if not available
+	return getAllHostStatuses(ctx, ws.config, ws.localhostAvail)
+}
+
+// getRemoteHostStatus returns the status of a remote host, simulated in demo mode
+func (ws *webServer) getRemoteHostStatus(ctx context.Context, host string) ([]gpu.GPUStatusInfo, error) {
+	if ws.demo {
+		return ws.generateDemoRemoteStatus(host), nil
+	}
+	return getRemoteStatus(ctx, host)
+}
+
+// tagJSONStatusesWithHost records which host each GPU status came from
+func tagJSONStatusesWithHost(statuses []jsonGPUStatus, host string) []jsonGPUStatus {
+	for i := range statuses {
+		statuses[i].Host = host
+	}
+	return statuses
+}
+
 // handleAPIHosts returns the list of configured hosts
 func (ws *webServer) handleAPIHosts(w http.ResponseWriter, r *http.Request) {
 	type hostInfo struct {
@@ -1705,14 +1967,17 @@ func (ws *webServer) handleAPIHosts(w http.ResponseWriter, r *http.Request) {
 
 	var hosts []hostInfo
 
-	// Include localhost only if available
-	if ws.localhostAvail {
+	// In remote mode, only the remote host is shown
+	if ws.remoteHost != "" {
+		hosts = append(hosts, hostInfo{Name: ws.remoteHost, IsLocal: false})
+	} else if ws.localhostAvail {
+		// Include localhost only if available
 		hosts = append(hosts, hostInfo{Name: "localhost", IsLocal: true})
 	}
 
 	// Add remote hosts if configured
-	if ws.config != nil && len(ws.config.RemoteHosts) > 0 {
-		for _, h := range ws.config.RemoteHosts {
+	if ws.remoteHost == "" && ws.config != nil && len(ws.config.RemoteHosts) > 0 {
+		for _, h := range ws.config.RemoteHostNames() {
 			hosts = append(hosts, hostInfo{Name: h, IsLocal: false})
 		}
 	}
@@ -1783,26 +2048,7 @@ func (ws *webServer) handleAPIHostsStatus(w http.ResponseWriter, r *http.Request
 	}
 
 	// Return summary for all hosts
-	var results []hostResult
-
-	if ws.demo {
-		// In demo mode, generate demo data for localhost and fake remote hosts
-		results = []hostResult{
-			{host: "localhost", statuses: ws.generateDemoStatus()},
-		}
-		// Add demo remote hosts if configured
-		if ws.config != nil && len(ws.config.RemoteHosts) > 0 {
-			for _, h := range ws.config.RemoteHosts {
-				results = append(results, hostResult{
-					host:     h,
-					statuses: ws.generateDemoRemoteStatus(h),
-				})
-			}
-		}
-	} else {
-		// Get all host statuses, excluding localhost if not available
-		results = getAllHostStatuses(ctx, ws.config, ws.localhostAvail)
-	}
+	results := ws.getHostStatuses(ctx)
 
 	// Build response with summaries
 	var responses []hostStatusResponse
@@ -1873,6 +2119,7 @@ type jsonGPUStatus struct {
 	ExpiryTime      *time.Time     `json:"expiry_time,omitempty"`
 	LastReleased    *time.Time     `json:"last_released,omitempty"`
 	ValidationInfo  string         `json:"validation_info,omitempty"`
+	Utilization     *int           `json:"utilization,omitempty"`
 	UnreservedUsers []string       `json:"unreserved_users,omitempty"`
 	ProcessInfo     string         `json:"process_info,omitempty"`
 	Error           string         `json:"error,omitempty"`
@@ -1880,6 +2127,12 @@ type jsonGPUStatus struct {
 	Provider        string         `json:"provider,omitempty"`
 	GPUModel        string         `json:"gpu_model,omitempty"`
 	Note            string         `json:"note,omitempty"`
+	Source          string         `json:"source,omitempty"`
+	JobID           string         `json:"job_id,omitempty"` // Shared by GPUs reserved together, used to group cards
+	Command         string         `json:"command,omitempty"`
+	Annotation      string         `json:"annotation,omitempty"` // Note left on the reservation with 'admin --annotate'
+	AnnotatedBy     string         `json:"annotated_by,omitempty"`
+	Host            string         `json:"host,omitempty"` // Set when showing a remote host or all hosts
 }
 
 // convertToJSONStatuses converts GPU statuses to JSON-friendly format
@@ -1893,6 +2146,7 @@ func convertToJSONStatuses(statuses []gpu.GPUStatusInfo) []jsonGPUStatus {
 			ReservationType: status.ReservationType,
 			Duration:        int64(status.Duration),
 			ValidationInfo:  status.ValidationInfo,
+			Utilization:     status.Utilization,
 			UnreservedUsers: status.UnreservedUsers,
 			ProcessInfo:     status.ProcessInfo,
 			Error:           status.Error,
@@ -1900,6 +2154,11 @@ func convertToJSONStatuses(statuses []gpu.GPUStatusInfo) []jsonGPUStatus {
 			Provider:        status.Provider,
 			GPUModel:        status.GPUModel,
 			Note:            status.Note,
+			Source:          status.Source,
+			JobID:           status.JobID,
+			Command:         status.Command,
+			Annotation:      status.Annotation,
+			AnnotatedBy:     status.AnnotatedBy,
 		}
 
 		if !status.LastHeartbeat.IsZero() {
@@ -1960,7 +2219,8 @@ func (ws *webServer) generateDemoRemoteStatus(host string) []gpu.GPUStatusInfo {
 				ReservationType: types.ReservationTypeRun,
 				LastHeartbeat:   now.Add(-time.Duration(i*15) * time.Second),
 				Duration:        time.Duration(30+i*15) * time.Minute,
-				ValidationInfo:  fmt.Sprintf("[validated: %dMB, 1 processes]", 8000+i*1000),
+				ValidationInfo:  fmt.Sprintf("[validated: %dMB, %d%% util, 1 processes]", 8000+i*1000, (i*37+hashVal)%101),
+				Utilization:     demoUtilization((i*37 + hashVal) % 101),
 				Provider:        "NVIDIA",
 				GPUModel:        gpuModel,
 				ModelInfo: &gpu.ModelInfo{
@@ -1986,16 +2246,49 @@ func (ws *webServer) handleAPIReport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// limit shows only the users with the most GPU hours, like report --top
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 0 {
+			http.Error(w, fmt.Sprintf("Invalid limit: %s", limitStr), http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	// type limits the report to run or manual reservations, like report --type
+	reservationType, err := parseReportType(r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid type: %s", r.URL.Query().Get("type")), http.StatusBadRequest)
+		return
+	}
+
+	// format=csv downloads the report as a CSV file instead of JSON
+	format := r.URL.Query().Get("format")
+	if format != "" && format != outputFormatJSON && format != reportFormatCSV {
+		http.Error(w, fmt.Sprintf("Invalid format: %s", format), http.StatusBadRequest)
+		return
+	}
+
 	host := r.URL.Query().Get("host")
+	if host == "" && ws.remoteHost != "" {
+		host = ws.remoteHost
+	}
 	isRemoteHost := host != "" && host != "localhost"
 
 	// For remote hosts, fetch via SSH
 	if isRemoteHost {
-		report, err := getRemoteReport(ctx, host, days)
+		report, err := getRemoteReport(ctx, host, days, reservationType)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Failed to get report from %s: %v", host, err), http.StatusInternalServerError)
 			return
 		}
+		report.Users = limitReportUsers(report.Users, userReportGPUHours, limit, 0)
+		if format == reportFormatCSV {
+			serveReportCSV(w, *report)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(report); err != nil {
 			http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
@@ -2014,6 +2307,7 @@ func (ws *webServer) handleAPIReport(w http.ResponseWriter, r *http.Request) {
 	if ws.demo {
 		// Use demo data
 		report = ws.generateDemoReport(days)
+		report.Users = limitReportUsers(report.Users, userReportGPUHours, limit, 0)
 	} else {
 		// Calculate time range
 		endTime := time.Now()
@@ -2036,9 +2330,17 @@ func (ws *webServer) handleAPIReport(w http.ResponseWriter, r *http.Request) {
 		// Add current usage to records
 		currentRecords := getCurrentUsageRecordsWeb(currentStatuses, endTime)
 		allRecords := append(historicalRecords, currentRecords...)
+		attributeToOwners(allRecords, ws.config)
+		allRecords = filterRecordsByType(allRecords, reservationType)
 
 		// Generate report data
-		report = generateReportData(allRecords, startTime, endTime, days)
+		report = generateReportData(allRecords, startTime, endTime, days, limit, ws.config.GPUHourWeights)
+		report.Type = reservationType
+	}
+
+	if format == reportFormatCSV {
+		serveReportCSV(w, report)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2048,15 +2350,24 @@ func (ws *webServer) handleAPIReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getRemoteReport fetches report data from a remote host via SSH
-func getRemoteReport(ctx context.Context, host string, days int) (*reportData, error) {
+// getRemoteReport fetches report data from a remote host via SSH, limited to
+// one reservation type unless reservationType is empty
+func getRemoteReport(ctx context.Context, host string, days int, reservationType string) (*reportData, error) {
+	args := []string{"report", "--json", "--days", strconv.Itoa(days)}
+	if reservationType != "" {
+		args = append(args, "--type", reservationType)
+	}
+
 	// Execute remote report command with JSON output
-	stdout, stderr, err := utils.ExecuteRemoteCanHazGPU(ctx, host, []string{"report", "--json", "--days", strconv.Itoa(days)})
+	stdout, stderr, err := utils.ExecuteRemoteCanHazGPU(ctx, getConfig().LookupRemoteHost(host), args)
 	if err != nil {
 		// Check if the remote host has an older canhazgpu without --json support
 		if strings.Contains(stderr, "unknown flag: --json") {
 			return nil, fmt.Errorf("remote host canhazgpu too old to provide GPU utilization report")
 		}
+		if strings.Contains(stderr, "unknown flag: --type") {
+			return nil, fmt.Errorf("remote host canhazgpu too old to filter the report by reservation type")
+		}
 		if stderr != "" {
 			return nil, fmt.Errorf("%v: %s", err, stderr)
 		}
@@ -2069,40 +2380,69 @@ func getRemoteReport(ctx context.Context, host string, days int) (*reportData, e
 		return nil, fmt.Errorf("failed to parse JSON output: %v", err)
 	}
 
+	switch {
+	case report.SchemaVersion == 0:
+		// Older canhazgpu versions don't report a version; their output
+		// matches version 1
+		report.SchemaVersion = 1
+	case report.SchemaVersion > reportJSONSchemaVersion:
+		return nil, fmt.Errorf("unsupported report JSON schema version %d (upgrade canhazgpu to read it)", report.SchemaVersion)
+	}
+
 	return &report, nil
 }
 
 type reportData struct {
-	Users             []userReport `json:"users"`
-	TotalGPUHours     float64      `json:"total_gpu_hours"`
-	TotalReservations int          `json:"total_reservations"`
-	UniqueUsers       int          `json:"unique_users"`
-	StartDate         string       `json:"start_date"`
-	EndDate           string       `json:"end_date"`
-	Days              int          `json:"days"`
+	SchemaVersion         int          `json:"schema_version"`
+	Users                 []userReport `json:"users"`
+	TotalGPUHours         float64      `json:"total_gpu_hours"`
+	TotalWeightedGPUHours float64      `json:"total_weighted_gpu_hours"`
+	TotalReservations     int          `json:"total_reservations"`
+	UniqueUsers           int          `json:"unique_users"`
+	StartDate             string       `json:"start_date"`
+	EndDate               string       `json:"end_date"`
+	Days                  int          `json:"days"`
+	Type                  string       `json:"type,omitempty"`
+
+	DurationStats *ReportDurationStatsJSON `json:"duration_stats,omitempty"`
 }
 
 type userReport struct {
-	Name        string  `json:"name"`
-	GPUHours    float64 `json:"gpu_hours"`
-	Percentage  float64 `json:"percentage"`
-	RunCount    int     `json:"run_count"`
-	ManualCount int     `json:"manual_count"`
+	Name             string  `json:"name"`
+	GPUHours         float64 `json:"gpu_hours"`
+	WeightedGPUHours float64 `json:"weighted_gpu_hours"`
+	Percentage       float64 `json:"percentage"`
+	RunCount         int     `json:"run_count"`
+	ManualCount      int     `json:"manual_count"`
+
+	DurationStats *ReportDurationStatsJSON `json:"duration_stats,omitempty"`
 }
 
-func generateReportData(records []*types.UsageRecord, startTime, endTime time.Time, days int) reportData {
+// userReportGPUHours returns a user's GPU hours, for limitReportUsers
+func userReportGPUHours(user userReport) float64 {
+	return user.GPUHours
+}
+
+// generateReportData aggregates usage records by user for the web dashboard,
+// listing only the limit users with the most GPU hours when limit is set
+func generateReportData(records []*types.UsageRecord, startTime, endTime time.Time, days int, limit int, weights map[string]float64) reportData {
 	// Aggregate usage by user
 	userUsage := make(map[string]float64)
+	userWeightedGPUHours := make(map[string]float64)
 	userRunCount := make(map[string]int)
 	userManualCount := make(map[string]int)
 
-	var totalDuration float64
+	var totalDuration, totalWeightedGPUHours float64
 
 	for _, record := range records {
 		userUsage[record.User] += record.Duration
+		userWeightedGPUHours[record.User] += weightedGPUHours(record, weights)
 		totalDuration += record.Duration
+		totalWeightedGPUHours += weightedGPUHours(record, weights)
 
-		if record.ReservationType == types.ReservationTypeRun {
+		if record.Checkpoint {
+			// The reservation is counted once, by its final record
+		} else if record.ReservationType == types.ReservationTypeRun {
 			userRunCount[record.User]++
 		} else {
 			userManualCount[record.User]++
@@ -2110,14 +2450,17 @@ func generateReportData(records []*types.UsageRecord, startTime, endTime time.Ti
 	}
 
 	// Create sorted user list
+	userStats := reservationDurationStatsByUser(records)
 	var users []userReport
 	for user, duration := range userUsage {
 		users = append(users, userReport{
-			Name:        user,
-			GPUHours:    duration / 3600.0,
-			Percentage:  (duration / totalDuration) * 100,
-			RunCount:    userRunCount[user],
-			ManualCount: userManualCount[user],
+			Name:             user,
+			GPUHours:         duration / 3600.0,
+			WeightedGPUHours: userWeightedGPUHours[user],
+			Percentage:       (duration / totalDuration) * 100,
+			RunCount:         userRunCount[user],
+			ManualCount:      userManualCount[user],
+			DurationStats:    userStats[user],
 		})
 	}
 
@@ -2129,15 +2472,19 @@ func generateReportData(records []*types.UsageRecord, startTime, endTime time.Ti
 			}
 		}
 	}
+	users = limitReportUsers(users, userReportGPUHours, limit, 0)
 
 	return reportData{
-		Users:             users,
-		TotalGPUHours:     totalDuration / 3600.0,
-		TotalReservations: len(records),
-		UniqueUsers:       len(userUsage),
-		StartDate:         startTime.Format("2006-01-02"),
-		EndDate:           endTime.Format("2006-01-02"),
-		Days:              days,
+		SchemaVersion:         reportJSONSchemaVersion,
+		Users:                 users,
+		TotalGPUHours:         totalDuration / 3600.0,
+		TotalWeightedGPUHours: totalWeightedGPUHours,
+		TotalReservations:     countReservations(records),
+		UniqueUsers:           len(userUsage),
+		StartDate:             startTime.Format("2006-01-02"),
+		EndDate:               endTime.Format("2006-01-02"),
+		Days:                  days,
+		DurationStats:         reservationDurationStats(records),
 	}
 }
 
@@ -2145,23 +2492,33 @@ func getCurrentUsageRecordsWeb(statuses []gpu.GPUStatusInfo, endTime time.Time)
 	var records []*types.UsageRecord
 
 	for _, status := range statuses {
-		if status.User != "" {
+		if len(status.Shares) > 0 {
+			records = append(records, sharedUsageRecords(status, endTime)...)
+		} else if status.User != "" {
 			duration := status.Duration.Seconds()
 			startTime := endTime.Add(-status.Duration)
-			records = append(records, &types.UsageRecord{
+			records = append(records, sinceCheckpoint(&types.UsageRecord{
 				User:            status.User,
 				GPUID:           status.GPUID,
 				StartTime:       types.FlexibleTime{Time: startTime},
 				EndTime:         types.FlexibleTime{Time: endTime},
 				Duration:        duration,
 				ReservationType: status.ReservationType,
-			})
+				Account:         status.Account,
+				Host:            utils.Hostname(),
+				GPUModel:        status.GPUModel,
+			}, status))
 		}
 	}
 
 	return records
 }
 
+// demoUtilization returns a compute utilization percent for demo statuses
+func demoUtilization(percent int) *int {
+	return &percent
+}
+
 // Demo mode data generation
 func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 	now := time.Now()
@@ -2185,9 +2542,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeRun,
 		LastHeartbeat:   now.Add(-30 * time.Second),
 		Duration:        75 * time.Minute,
-		ValidationInfo:  "[validated: 8452MB, 2 processes]",
+		ValidationInfo:  "[validated: 8452MB, 85% util, 2 processes]",
+		Utilization:     demoUtilization(85),
 		Provider:        "NVIDIA",
 		GPUModel:        "H100",
+		JobID:           "demo-job-alice",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "meta-llama/Llama-3.1-8B-Instruct",
 			Provider: "meta-llama",
@@ -2202,9 +2561,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeRun,
 		LastHeartbeat:   now.Add(-15 * time.Second),
 		Duration:        45 * time.Minute,
-		ValidationInfo:  "[validated: 15234MB, 1 processes]",
+		ValidationInfo:  "[validated: 15234MB, 92% util, 1 processes]",
+		Utilization:     demoUtilization(92),
 		Provider:        "NVIDIA",
 		GPUModel:        "A100",
+		JobID:           "demo-job-bob",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "deepseek-ai/deepseek-v2",
 			Provider: "deepseek-ai",
@@ -2219,9 +2580,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeRun,
 		LastHeartbeat:   now.Add(-15 * time.Second),
 		Duration:        45 * time.Minute,
-		ValidationInfo:  "[validated: 15234MB, 1 processes]",
+		ValidationInfo:  "[validated: 15234MB, 0% util, 1 processes]",
+		Utilization:     demoUtilization(0),
 		Provider:        "NVIDIA",
 		GPUModel:        "A100",
+		JobID:           "demo-job-bob",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "deepseek-ai/deepseek-v2",
 			Provider: "deepseek-ai",
@@ -2236,9 +2599,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeManual,
 		ExpiryTime:      now.Add(6 * time.Hour),
 		Duration:        2 * time.Hour,
-		ValidationInfo:  "[validated: 23045MB, 1 processes]",
+		ValidationInfo:  "[validated: 23045MB, 97% util, 1 processes]",
+		Utilization:     demoUtilization(97),
 		Provider:        "NVIDIA",
 		GPUModel:        "RTX 4090",
+		JobID:           "demo-job-charlie",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "qwen/Qwen2.5-72B-Instruct",
 			Provider: "qwen",
@@ -2253,9 +2618,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeRun,
 		LastHeartbeat:   now,
 		Duration:        30 * time.Minute,
-		ValidationInfo:  "[validated: 19532MB, 1 processes]",
+		ValidationInfo:  "[validated: 19532MB, 64% util, 1 processes]",
+		Utilization:     demoUtilization(64),
 		Provider:        "NVIDIA",
 		GPUModel:        "RTX 4090",
+		JobID:           "demo-job-david",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "mistralai/Mistral-Large-2",
 			Provider: "mistralai",
@@ -2270,9 +2637,11 @@ func (ws *webServer) generateDemoStatus() []gpu.GPUStatusInfo {
 		ReservationType: types.ReservationTypeRun,
 		LastHeartbeat:   now.Add(-45 * time.Second),
 		Duration:        90 * time.Minute,
-		ValidationInfo:  "[validated: 12856MB, 2 processes]",
+		ValidationInfo:  "[validated: 12856MB, 71% util, 2 processes]",
+		Utilization:     demoUtilization(71),
 		Provider:        "AMD",
 		GPUModel:        "",
+		JobID:           "demo-job-eve",
 		ModelInfo: &gpu.ModelInfo{
 			Model:    "redhatai/granite-20b-multilingual",
 			Provider: "redhatai",
@@ -2344,6 +2713,10 @@ func (ws *webServer) generateDemoReport(days int) reportData {
 	totalHours := 0.0
 	totalRun := 0
 	totalManual := 0
+	for i := range users {
+		// Demo GPUs aren't weighted
+		users[i].WeightedGPUHours = users[i].GPUHours
+	}
 	for _, u := range users {
 		totalHours += u.GPUHours
 		totalRun += u.RunCount
@@ -2351,13 +2724,15 @@ func (ws *webServer) generateDemoReport(days int) reportData {
 	}
 
 	return reportData{
-		Users:             users,
-		TotalGPUHours:     totalHours,
-		TotalReservations: totalRun + totalManual,
-		UniqueUsers:       len(users),
-		StartDate:         startTime.Format("2006-01-02"),
-		EndDate:           endTime.Format("2006-01-02"),
-		Days:              days,
+		SchemaVersion:         reportJSONSchemaVersion,
+		Users:                 users,
+		TotalGPUHours:         totalHours,
+		TotalWeightedGPUHours: totalHours,
+		TotalReservations:     totalRun + totalManual,
+		UniqueUsers:           len(users),
+		StartDate:             startTime.Format("2006-01-02"),
+		EndDate:               endTime.Format("2006-01-02"),
+		Days:                  days,
 	}
 }
 
@@ -2373,6 +2748,11 @@ type queueEntryJSON struct {
 	Note            string  `json:"note,omitempty"`
 	WaitTime        string  `json:"wait_time"`
 	WaitTimeSeconds float64 `json:"wait_time_seconds"`
+
+	// Rough estimate of how much longer the entry will wait, omitted if
+	// there is none
+	EstimatedWait        string   `json:"estimated_wait,omitempty"`
+	EstimatedWaitSeconds *float64 `json:"estimated_wait_seconds,omitempty"`
 }
 
 // queueStatusJSON represents the queue status for JSON output
@@ -2381,6 +2761,10 @@ type queueStatusJSON struct {
 	TotalWaiting       int              `json:"total_waiting"`
 	TotalGPUsRequested int              `json:"total_gpus_requested"`
 	TotalGPUsAllocated int              `json:"total_gpus_allocated"`
+
+	// Limits on new entries (0 = unlimited)
+	MaxQueueLength         int `json:"max_queue_length,omitempty"`
+	MaxQueueEntriesPerUser int `json:"max_queue_entries_per_user,omitempty"`
 }
 
 // handleAPIQueue returns the current queue status
@@ -2414,6 +2798,8 @@ func (ws *webServer) handleAPIQueue(w http.ResponseWriter, r *http.Request) {
 		response.TotalWaiting = status.TotalWaiting
 		response.TotalGPUsRequested = status.TotalGPUsRequested
 		response.TotalGPUsAllocated = status.TotalGPUsAllocated
+		response.MaxQueueLength = status.MaxQueueLength
+		response.MaxQueueEntriesPerUser = status.MaxQueueEntriesPerUser
 		response.Entries = make([]queueEntryJSON, len(status.Entries))
 
 		for i, entry := range status.Entries {
@@ -2430,6 +2816,11 @@ func (ws *webServer) handleAPIQueue(w http.ResponseWriter, r *http.Request) {
 				WaitTime:        utils.FormatDuration(waitTime),
 				WaitTimeSeconds: waitTime.Seconds(),
 			}
+			if estimate, ok := status.EstimatedWaits[entry.ID]; ok {
+				seconds := estimate.Seconds()
+				response.Entries[i].EstimatedWait = formatEstimate(estimate)
+				response.Entries[i].EstimatedWaitSeconds = &seconds
+			}
 		}
 	}
 
@@ -2442,29 +2833,34 @@ func (ws *webServer) handleAPIQueue(w http.ResponseWriter, r *http.Request) {
 
 // generateDemoQueue generates demo queue data
 func (ws *webServer) generateDemoQueue() queueStatusJSON {
+	aliceETA, bobETA := 1200.0, 2700.0
 	return queueStatusJSON{
 		Entries: []queueEntryJSON{
 			{
-				ID:              "demo-queue-1",
-				User:            "alice",
-				RequestedCount:  4,
-				AllocatedGPUs:   []int{0, 1},
-				AllocatedCount:  2,
-				ReservationType: "run",
-				Note:            "Training large model",
-				WaitTime:        "5m 30s",
-				WaitTimeSeconds: 330,
+				ID:                   "demo-queue-1",
+				User:                 "alice",
+				RequestedCount:       4,
+				AllocatedGPUs:        []int{0, 1},
+				AllocatedCount:       2,
+				ReservationType:      "run",
+				Note:                 "Training large model",
+				WaitTime:             "5m 30s",
+				WaitTimeSeconds:      330,
+				EstimatedWait:        "~20m",
+				EstimatedWaitSeconds: &aliceETA,
 			},
 			{
-				ID:              "demo-queue-2",
-				User:            "bob",
-				RequestedCount:  2,
-				AllocatedGPUs:   []int{},
-				AllocatedCount:  0,
-				ReservationType: "manual",
-				Note:            "Inference testing",
-				WaitTime:        "2m 15s",
-				WaitTimeSeconds: 135,
+				ID:                   "demo-queue-2",
+				User:                 "bob",
+				RequestedCount:       2,
+				AllocatedGPUs:        []int{},
+				AllocatedCount:       0,
+				ReservationType:      "manual",
+				Note:                 "Inference testing",
+				WaitTime:             "2m 15s",
+				WaitTimeSeconds:      135,
+				EstimatedWait:        "~45m",
+				EstimatedWaitSeconds: &bobETA,
 			},
 		},
 		TotalWaiting:       2,
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+)
+
+// Page sizes of /api/history
+const (
+	historyDefaultLimit = 100
+	historyMaxLimit     = 1000
+)
+
+// historyDefaultSince is how far back /api/history goes without since
+const historyDefaultSince = 7 * 24 * time.Hour
+
+// historyResponseJSON is a page of raw usage records from /api/history
+type historyResponseJSON struct {
+	Records    []*types.UsageRecord `json:"records"`
+	Total      int                  `json:"total"`                 // Records matching the query, on every page
+	Offset     int                  `json:"offset"`                // Index of the first record on this page
+	Limit      int                  `json:"limit"`                 // Most records on a page
+	NextOffset *int                 `json:"next_offset,omitempty"` // Offset of the next page, if there is one
+	Since      time.Time            `json:"since"`
+	Until      time.Time            `json:"until"`
+}
+
+// handleAPIHistory returns the usage records that ended between since and
+// until, oldest first, optionally for one user. It's a lower-level view than
+// /api/report, for building custom analytics.
+func (ws *webServer) handleAPIHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	now := time.Now()
+
+	// Usage history is read from this host's Redis; remote hosts only
+	// provide reports
+	if host := query.Get("host"); host != "" && host != "localhost" {
+		http.Error(w, fmt.Sprintf("Usage history is not available for remote host %s", host), http.StatusBadRequest)
+		return
+	}
+
+	since := now.Add(-historyDefaultSince)
+	if value := query.Get("since"); value != "" {
+		t, err := parseHistoryTime(value, now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid since: %v", err), http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	until := now
+	if value := query.Get("until"); value != "" {
+		t, err := parseHistoryTime(value, now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid until: %v", err), http.StatusBadRequest)
+			return
+		}
+		until = t
+	}
+	if until.Before(since) {
+		http.Error(w, "Invalid range: until is before since", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseHistoryPageParam(query.Get("limit"), historyDefaultLimit)
+	if err != nil || limit == 0 || limit > historyMaxLimit {
+		http.Error(w, fmt.Sprintf("Invalid limit: must be from 1 to %d", historyMaxLimit), http.StatusBadRequest)
+		return
+	}
+	offset, err := parseHistoryPageParam(query.Get("offset"), 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid offset: %s", query.Get("offset")), http.StatusBadRequest)
+		return
+	}
+
+	if !ws.localhostAvail && !ws.demo {
+		http.Error(w, "localhost not available (Redis connection failed)", http.StatusServiceUnavailable)
+		return
+	}
+
+	var records []*types.UsageRecord
+	if ws.demo {
+		records = demoUsageHistory(since, until)
+	} else {
+		records, err = ws.client.GetUsageHistory(ctx, since, until)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get usage history: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	records = filterRecordsByUser(records, query.Get("user"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(historyPage(records, offset, limit, since, until)); err != nil {
+		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+	}
+}
+
+// parseHistoryTime parses a since or until value: an RFC 3339 time, a date
+// (2006-01-02, midnight local time), or a duration before now (e.g., 12h, 7d)
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	ago, err := utils.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time, a date, or a duration", value)
+	}
+	return now.Add(-ago), nil
+}
+
+// parseHistoryPageParam parses a limit or offset, which must not be negative
+func parseHistoryPageParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("must be a number, 0 or more")
+	}
+	return n, nil
+}
+
+// filterRecordsByUser keeps the usage records of one user, or every record
+// if user is empty
+func filterRecordsByUser(records []*types.UsageRecord, user string) []*types.UsageRecord {
+	if user == "" {
+		return records
+	}
+	var filtered []*types.UsageRecord
+	for _, record := range records {
+		if record.User == user {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// historyPage returns the page of records starting at offset. Records made
+// before the host was recorded are given this host, where they were made.
+func historyPage(records []*types.UsageRecord, offset, limit int, since, until time.Time) historyResponseJSON {
+	for _, record := range records {
+		if record.Host == "" {
+			record.Host = utils.Hostname()
+		}
+	}
+
+	page := historyResponseJSON{
+		Records: []*types.UsageRecord{},
+		Total:   len(records),
+		Offset:  offset,
+		Limit:   limit,
+		Since:   since,
+		Until:   until,
+	}
+	if offset >= len(records) {
+		return page
+	}
+
+	end := min(offset+limit, len(records))
+	page.Records = records[offset:end]
+	if end < len(records) {
+		page.NextOffset = &end
+	}
+	return page
+}
+
+// demoUsageHistory returns made-up usage records that ended between since
+// and until, for demo mode
+func demoUsageHistory(since, until time.Time) []*types.UsageRecord {
+	users := []string{"alice", "bob", "charlie", "david"}
+	var records []*types.UsageRecord
+	for i := 0; i < 48; i++ {
+		end := until.Add(-time.Duration(i) * 3 * time.Hour)
+		if end.Before(since) {
+			break
+		}
+		duration := time.Duration(1+i%4) * time.Hour
+		reservationType := types.ReservationTypeRun
+		if i%3 == 0 {
+			reservationType = types.ReservationTypeManual
+		}
+		records = append(records, &types.UsageRecord{
+			User:            users[i%len(users)],
+			GPUID:           i % 8,
+			StartTime:       types.FlexibleTime{Time: end.Add(-duration)},
+			EndTime:         types.FlexibleTime{Time: end},
+			Duration:        duration.Seconds(),
+			ReservationType: reservationType,
+			Host:            "demo",
+			GPUModel:        "NVIDIA H100 80GB HBM3",
+		})
+	}
+	// Oldest first, as from Redis
+	slices.Reverse(records)
+	return records
+}
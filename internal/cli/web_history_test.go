@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseHistoryTime("2025-07-01T08:30:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 1, 8, 30, 0, 0, time.UTC), got)
+
+	got, err = parseHistoryTime("2025-07-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local), got)
+
+	got, err = parseHistoryTime("7d", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-7*24*time.Hour), got)
+
+	_, err = parseHistoryTime("last tuesday", now)
+	assert.Error(t, err)
+}
+
+func TestHistoryPage(t *testing.T) {
+	var records []*types.UsageRecord
+	for i := 0; i < 5; i++ {
+		records = append(records, &types.UsageRecord{GPUID: i})
+	}
+	since, until := time.Now().Add(-time.Hour), time.Now()
+
+	page := historyPage(records, 0, 2, since, until)
+	assert.Equal(t, 5, page.Total)
+	require.Len(t, page.Records, 2)
+	require.NotNil(t, page.NextOffset)
+	assert.Equal(t, 2, *page.NextOffset)
+
+	page = historyPage(records, 4, 2, since, until)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, 4, page.Records[0].GPUID)
+	assert.Nil(t, page.NextOffset)
+
+	page = historyPage(records, 10, 2, since, until)
+	assert.Empty(t, page.Records)
+	assert.NotNil(t, page.Records, "an empty page is [] rather than null")
+
+	// Records without a host are from this host
+	records = []*types.UsageRecord{{GPUID: 0}, {GPUID: 1, Host: "other-host"}}
+	page = historyPage(records, 0, 2, since, until)
+	assert.Equal(t, utils.Hostname(), page.Records[0].Host)
+	assert.Equal(t, "other-host", page.Records[1].Host)
+}
+
+func TestFilterRecordsByUser(t *testing.T) {
+	records := []*types.UsageRecord{{User: "alice"}, {User: "bob"}, {User: "alice"}}
+	assert.Len(t, filterRecordsByUser(records, "alice"), 2)
+	assert.Len(t, filterRecordsByUser(records, ""), 3)
+	assert.Empty(t, filterRecordsByUser(records, "carol"))
+}
+
+func TestHandleAPIHistory(t *testing.T) {
+	ws := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+
+	rec := httptest.NewRecorder()
+	ws.handleAPIHistory(rec, httptest.NewRequest(http.MethodGet, "/api/history?since=2d&user=alice&limit=3", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var page historyResponseJSON
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Records, 3)
+	assert.Greater(t, page.Total, 3)
+	require.NotNil(t, page.NextOffset)
+	assert.Equal(t, 3, *page.NextOffset)
+	for _, record := range page.Records {
+		assert.Equal(t, "alice", record.User)
+		assert.Equal(t, "demo", record.Host)
+		assert.False(t, record.EndTime.ToTime().Before(page.Since))
+	}
+	assert.True(t, page.Records[0].EndTime.ToTime().Before(page.Records[1].EndTime.ToTime()), "oldest first")
+
+	for _, query := range []string{"limit=0", "limit=5000", "offset=-1", "since=soon", "since=1d&until=2d", "host=gpu-box"} {
+		rec = httptest.NewRecorder()
+		ws.handleAPIHistory(rec, httptest.NewRequest(http.MethodGet, "/api/history?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
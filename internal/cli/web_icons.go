@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// modelProviderIcons returns the dashboard icons for model providers from the
+// model_provider_icons config option, as HTML keyed by lowercase provider
+// name. They are consulted before the built-in icons, so they can also
+// replace one. Invalid entries are skipped with a warning.
+func modelProviderIcons(v *viper.Viper) map[string]string {
+	icons := make(map[string]string)
+	for provider, value := range v.GetStringMapString("model_provider_icons") {
+		icon, err := providerIconHTML(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring model_provider_icons entry %q: %v\n", provider, err)
+			continue
+		}
+		icons[strings.ToLower(strings.TrimSpace(provider))] = icon
+	}
+	return icons
+}
+
+// providerIconHTML turns a model_provider_icons value, an inline SVG or an
+// http(s) image URL, into the HTML shown in a GPU card
+func providerIconHTML(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "<svg") {
+		if !strings.HasSuffix(value, "</svg>") {
+			return "", fmt.Errorf("inline SVG must end with </svg>")
+		}
+		if strings.Contains(strings.ToLower(value), "<script") {
+			return "", fmt.Errorf("inline SVG must not contain scripts")
+		}
+		return value, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("must be an inline <svg> or an http(s) image URL")
+	}
+	return `<img src="` + html.EscapeString(value) + `" alt="">`, nil
+}
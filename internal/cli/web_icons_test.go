@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderIconHTML(t *testing.T) {
+	svg := `<svg viewBox="0 0 24 24"><circle cx="12" cy="12" r="10"/></svg>`
+	icon, err := providerIconHTML("  " + svg + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, svg, icon)
+
+	icon, err = providerIconHTML("https://intranet.example.com/icons/acme.png?size=32&v=2")
+	require.NoError(t, err)
+	assert.Equal(t, `<img src="https://intranet.example.com/icons/acme.png?size=32&amp;v=2" alt="">`, icon)
+
+	for _, value := range []string{
+		"",
+		"acme.png",
+		"javascript:alert(1)",
+		"ftp://example.com/acme.png",
+		`<svg viewBox="0 0 24 24">`,
+		`<svg><script>alert(1)</script></svg>`,
+	} {
+		_, err := providerIconHTML(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestModelProviderIcons(t *testing.T) {
+	v := viper.New()
+	v.Set("model_provider_icons", map[string]any{
+		"Acme-Research": "https://intranet.example.com/icons/acme.png",
+		"internal":      `<svg viewBox="0 0 24 24"></svg>`,
+		"broken":        "not an icon",
+	})
+
+	icons := modelProviderIcons(v)
+	assert.Equal(t, map[string]string{
+		"acme-research": `<img src="https://intranet.example.com/icons/acme.png" alt="">`,
+		"internal":      `<svg viewBox="0 0 24 24"></svg>`,
+	}, icons)
+
+	assert.Empty(t, modelProviderIcons(viper.New()))
+}
+
+func TestHandleIndex_ProviderIcons(t *testing.T) {
+	ws := &webServer{
+		config:        &types.Config{},
+		demo:          true,
+		providerIcons: map[string]string{"acme-research": `<svg viewBox="0 0 24 24"></svg>`},
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	// The icons are escaped for the script, not dropped
+	assert.Contains(t, rec.Body.String(),
+		`const customProviderIcons = {"acme-research":"\u003csvg viewBox=\"0 0 24 24\"\u003e\u003c/svg\u003e"};`)
+
+	ws.providerIcons = nil
+	rec = httptest.NewRecorder()
+	ws.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Contains(t, rec.Body.String(), `const customProviderIcons = {};`)
+}
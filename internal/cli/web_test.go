@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getAPIStatus calls the /api/status handler and decodes the response
+func getAPIStatus(t *testing.T, ws *webServer) []jsonGPUStatus {
+	rec := httptest.NewRecorder()
+	ws.handleAPIStatus(rec, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var statuses []jsonGPUStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	return statuses
+}
+
+func TestHandleAPIStatus_Aggregate(t *testing.T) {
+	ws := &webServer{
+		config:         &types.Config{RemoteHosts: []types.RemoteHost{{Host: "gpu-server-1"}, {Host: "gpu-server-2"}}},
+		demo:           true,
+		localhostAvail: true,
+		aggregate:      true,
+	}
+
+	counts := make(map[string]int)
+	for _, status := range getAPIStatus(t, ws) {
+		counts[status.Host]++
+	}
+
+	assert.Equal(t, len(ws.generateDemoStatus()), counts["localhost"])
+	assert.Equal(t, len(ws.generateDemoRemoteStatus("gpu-server-1")), counts["gpu-server-1"])
+	assert.Equal(t, len(ws.generateDemoRemoteStatus("gpu-server-2")), counts["gpu-server-2"])
+	assert.Len(t, counts, 3)
+}
+
+func TestHandleAPIStatus_Remote(t *testing.T) {
+	ws := &webServer{
+		config:     &types.Config{},
+		demo:       true,
+		remoteHost: "gpu-server-1",
+	}
+
+	statuses := getAPIStatus(t, ws)
+	require.Len(t, statuses, len(ws.generateDemoRemoteStatus("gpu-server-1")))
+	for _, status := range statuses {
+		assert.Equal(t, "gpu-server-1", status.Host)
+	}
+
+	// Without --remote or --all, statuses are not tagged with a host
+	local := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+	for _, status := range getAPIStatus(t, local) {
+		assert.Empty(t, status.Host)
+	}
+}
+
+func TestHandleAPIStatus_JobID(t *testing.T) {
+	ws := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+
+	jobs := make(map[string][]int)
+	for _, status := range getAPIStatus(t, ws) {
+		if status.JobID != "" {
+			jobs[status.JobID] = append(jobs[status.JobID], status.GPUID)
+		}
+	}
+
+	// bob's model runs on GPUs 2 and 3 under one job, so the dashboard can
+	// group them into one card
+	assert.Equal(t, []int{2, 3}, jobs["demo-job-bob"])
+	assert.Equal(t, []int{1}, jobs["demo-job-alice"])
+}
+
+func TestHandleAPIReport_Limit(t *testing.T) {
+	ws := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+
+	rec := httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?days=7&limit=2", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var report reportData
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Users, 2)
+	assert.Equal(t, "alice", report.Users[0].Name)
+	assert.Equal(t, "bob", report.Users[1].Name)
+	assert.Equal(t, 6, report.UniqueUsers)
+
+	rec = httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?limit=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleAPIReport_Type(t *testing.T) {
+	ws := &webServer{config: &types.Config{}, demo: true, localhostAvail: true}
+
+	rec := httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?type=run", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	ws.handleAPIReport(rec, httptest.NewRequest(http.MethodGet, "/api/report?type=batch", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid type: batch")
+}
@@ -3,14 +3,18 @@ package gpu
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/russellb/canhazgpu/internal/redis_client"
 	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
 )
 
 type AllocationEngine struct {
@@ -25,52 +29,164 @@ func NewAllocationEngine(client *redis_client.Client, config *types.Config) *All
 	}
 }
 
-func (ae *AllocationEngine) detectGPUUsage(ctx context.Context) (map[int]*types.GPUUsage, error) {
+// providerManager returns a provider manager for the pool's provider, as
+// stored in Redis by 'canhazgpu admin'
+func (ae *AllocationEngine) providerManager(ctx context.Context) (*ProviderManager, error) {
 	providerName, err := ae.client.GetAvailableProvider(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cached provider information: %v", err)
 	}
 
-	var pm *ProviderManager
 	if providerName == "fake" {
 		// For fake provider, get GPU count from Redis to configure the provider
 		gpuCount, err := ae.client.GetGPUCount(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get GPU count for fake provider: %v", err)
 		}
-		pm = NewProviderManagerWithFake(gpuCount)
-	} else {
-		pm = NewProviderManagerFromNames([]string{providerName})
+		return NewProviderManagerWithFake(gpuCount), nil
+	}
+	return NewProviderManagerFromNames([]string{providerName}, ae.config), nil
+}
+
+func (ae *AllocationEngine) detectGPUUsage(ctx context.Context) (map[int]*types.GPUUsage, error) {
+	pm, err := ae.providerManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := pm.DetectAllGPUUsageWithoutChecks(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return pm.DetectAllGPUUsageWithoutChecks(ctx)
+	markIgnoredProcesses(usage, ae.config.IgnoreUsers, ae.config.IgnoreProcesses)
+	if ae.config.ConfirmUnreservedUsage {
+		ae.confirmUnreservedUsage(ctx, usage)
+	}
+
+	ae.recordGPUModels(ctx, usage)
+
+	return usage, nil
+}
+
+// recordGPUModels stores the detected GPU models, which usage records are
+// tagged with when reservations end
+func (ae *AllocationEngine) recordGPUModels(ctx context.Context, usage map[int]*types.GPUUsage) {
+	models := make(map[int]string)
+	for gpuID, u := range usage {
+		if u != nil && u.Model != "" {
+			models[gpuID] = u.Model
+		}
+	}
+	if err := ae.client.SetGPUModels(ctx, models); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record GPU models: %v\n", err)
+	}
+}
+
+// unreservedSampleMaxAge is how long a sample above the memory threshold
+// counts toward confirming unreserved usage
+const unreservedSampleMaxAge = 10 * time.Minute
+
+// confirmUnreservedUsage marks GPUs that are above the memory threshold for
+// the first time as unconfirmed, so that brief spikes aren't reported as
+// unreserved usage. The samples are kept in Redis so that they are shared by
+// all commands and the daemon. If they can't be read, usage is reported
+// immediately as if confirmation were off.
+func (ae *AllocationEngine) confirmUnreservedUsage(ctx context.Context, usage map[int]*types.GPUUsage) {
+	previous, err := ae.client.GetUnreservedSamples(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read unreserved usage samples: %v\n", err)
+		return
+	}
+
+	seen, cleared := markUnconfirmedUsage(usage, previous, ae.config.MemoryThreshold, time.Now())
+	if err := ae.client.UpdateUnreservedSamples(ctx, seen, cleared, unreservedSampleMaxAge); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record unreserved usage samples: %v\n", err)
+	}
+}
+
+// markUnconfirmedUsage sets Unconfirmed on GPUs above the memory threshold
+// unless they were also above it in an earlier, recent sample. It returns the
+// GPUs to record as seen above the threshold and those to forget.
+func markUnconfirmedUsage(usage map[int]*types.GPUUsage, previous map[int]time.Time, memoryThreshold int, now time.Time) (map[int]time.Time, []int) {
+	seen := make(map[int]time.Time)
+	var cleared []int
+
+	for gpuID, gpuUsage := range usage {
+		if gpuUsage.UnreservedMemoryMB() <= memoryThreshold {
+			if _, ok := previous[gpuID]; ok {
+				cleared = append(cleared, gpuID)
+			}
+			continue
+		}
+
+		// Samples taken at the same moment (e.g. by concurrent commands)
+		// don't confirm each other
+		last, ok := previous[gpuID]
+		age := now.Sub(last)
+		if !ok || age < time.Second || age > unreservedSampleMaxAge {
+			gpuUsage.Unconfirmed = true
+		}
+		if !ok || age >= time.Second {
+			seen[gpuID] = now
+		}
+	}
+
+	return seen, cleared
 }
 
 // AllocateGPUs allocates GPUs using MRU-per-user strategy with race condition protection
 func (ae *AllocationEngine) AllocateGPUs(ctx context.Context, request *types.AllocationRequest) ([]int, error) {
+	allocatedGPUs, _, err := ae.allocateGPUs(ctx, request)
+	return allocatedGPUs, err
+}
+
+// allocateGPUs is AllocateGPUs, also returning how long a successful
+// allocation took
+func (ae *AllocationEngine) allocateGPUs(ctx context.Context, request *types.AllocationRequest) ([]int, AllocationTiming, error) {
+	start := time.Now()
+	var timing AllocationTiming
+
 	// Validate the allocation request first
 	if err := request.Validate(); err != nil {
-		return nil, err
+		return nil, timing, err
 	}
 
 	// Validate GPU availability using cached provider information
 	usage, err := ae.detectGPUUsage(ctx)
+	timing.Detection = time.Since(start)
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate GPU usage: %v", err)
+		return nil, timing, fmt.Errorf("failed to validate GPU usage: %v", err)
 	}
 
 	// Get list of unreserved GPUs
 	unreservedGPUs := GetUnreservedGPUs(ctx, usage, ae.config.MemoryThreshold)
 
-	// If force flag is set, clear unreserved GPUs list to allow allocation
+	// If force flag is set, clear unreserved GPUs list to allow allocation,
+	// remembering which GPUs are being adopted
+	var adoptedGPUs []int
 	if request.Force {
+		adoptedGPUs = unreservedGPUs
 		unreservedGPUs = []int{}
 	}
 
+	// Keep GPUs outside the requested class out of the allocation
+	if request.GPUClass != "" {
+		classExcluded, err := ae.classExcludedGPUs(ctx, request, usage)
+		if err != nil {
+			return nil, timing, err
+		}
+		classRequest := *request
+		classRequest.ClassExcludedGPUs = classExcluded
+		request = &classRequest
+	}
+
 	// Acquire allocation lock
+	lockStart := time.Now()
 	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
-		return nil, err
+		return nil, timing, err
 	}
+	timing.LockWait = time.Since(lockStart)
 	defer func() {
 		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
 			// Log error but don't fail the operation
@@ -78,27 +194,435 @@ func (ae *AllocationEngine) AllocateGPUs(ctx context.Context, request *types.All
 		}
 	}()
 
+	// Keep GPUs that were just released, or that are next to a reservation
+	// about to expire, out of the allocation. Read under the lock, since the
+	// states when it was taken are what count.
+	request, err = ae.withGPUTimes(ctx, request)
+	if err != nil {
+		return nil, timing, err
+	}
+
+	// Resolve a request for all available GPUs now that no other allocation
+	// can change which GPUs are free
+	if request.AllAvailable {
+		request, err = ae.resolveAllAvailable(ctx, request, unreservedGPUs)
+		if err != nil {
+			return nil, timing, err
+		}
+	}
+
 	// Perform atomic allocation
 	allocatedGPUs, err := ae.client.AtomicReserveGPUs(ctx, request, unreservedGPUs)
+
+	// If GPUs are unavailable and preemption was requested, free idle
+	// lower-priority reservations and try once more
+	var preempted map[int]*types.GPUState
+	if err != nil && request.Preempt {
+		var preemptErr error
+		preempted, preemptErr = ae.preemptIdleReservations(ctx, request, usage, unreservedGPUs)
+		if preemptErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: preemption failed: %v\n", preemptErr)
+		} else if len(preempted) > 0 {
+			allocatedGPUs, err = ae.client.AtomicReserveGPUs(ctx, request, unreservedGPUs)
+		}
+	}
+
 	if err != nil {
 		// Check if it's an availability error and provide detailed message
 		if err.Error() == "Not enough GPUs available" {
 			gpuCount, _ := ae.client.GetGPUCount(ctx)
-			available := gpuCount - len(unreservedGPUs)
+			return nil, timing, notEnoughGPUsError(request, gpuCount, unreservedGPUs)
+		}
+		// For specific GPU ID errors, pass through the detailed error message
+		return nil, timing, err
+	}
 
-			var unreservedMsg string
-			if len(unreservedGPUs) > 0 {
-				unreservedMsg = fmt.Sprintf(" (%d GPUs in use without reservation - run 'canhazgpu status' for details)", len(unreservedGPUs))
-			}
+	timing.Total = time.Since(start)
+	ae.recordAllocationLatency(ctx, timing.Total)
+
+	ae.markAdoptedGPUs(ctx, allocatedGPUs, adoptedGPUs)
+	ae.markPreemptedGPUs(ctx, allocatedGPUs, preempted)
+
+	return allocatedGPUs, timing, nil
+}
+
+// classExcludedGPUs returns the GPUs that aren't in the request's GPU class.
+// GPUs whose total memory isn't known are treated as outside every class.
+// Fails if the class is unknown or has fewer GPUs than the request needs.
+func (ae *AllocationEngine) classExcludedGPUs(ctx context.Context, request *types.AllocationRequest, usage map[int]*types.GPUUsage) ([]int, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU count: %v", err)
+	}
+	return classExcludedGPUs(ae.config.GPUClasses, request.GPUClass, request.GPUCount, gpuCount, usage)
+}
 
-			return nil, fmt.Errorf("not enough GPUs available. Requested: %d, Available: %d%s",
-				request.GPUCount, available, unreservedMsg)
+// classExcludedGPUs returns the GPUs out of gpuCount whose detected total
+// memory is outside className, looked up in classes (nil = the defaults)
+func classExcludedGPUs(classes map[string]types.GPUClass, className string, requested, gpuCount int, usage map[int]*types.GPUUsage) ([]int, error) {
+	if classes == nil {
+		classes = types.DefaultGPUClasses()
+	}
+	class, ok := classes[className]
+	if !ok {
+		return nil, fmt.Errorf("unknown GPU class %q", className)
+	}
+
+	excluded := []int{}
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		gpuUsage := usage[gpuID]
+		if gpuUsage == nil || gpuUsage.TotalMemoryMB == 0 || !class.Matches(gpuUsage.TotalMemoryMB) {
+			excluded = append(excluded, gpuID)
 		}
-		// For specific GPU ID errors, pass through the detailed error message
+	}
+
+	inClass := gpuCount - len(excluded)
+	if inClass == 0 {
+		return nil, fmt.Errorf("no GPUs on this host are in GPU class %q (%s of memory)", className, class)
+	}
+	if requested > inClass {
+		return nil, fmt.Errorf("requested %d GPUs but only %d GPUs on this host are in GPU class %q", requested, inClass, className)
+	}
+	return excluded, nil
+}
+
+// resolveAllAvailable turns a request for all available GPUs into a request
+// for the number of GPUs that are currently free. GPUs in use without a
+// reservation make the request fail, unless AllGPUsExcludeUnreserved is set,
+// in which case they are skipped with a warning. Must be called while holding
+// the allocation lock.
+func (ae *AllocationEngine) resolveAllAvailable(ctx context.Context, request *types.AllocationRequest, unreservedGPUs []int) (*types.AllocationRequest, error) {
+	if len(unreservedGPUs) > 0 {
+		if !ae.config.AllGPUsExcludeUnreserved {
+			return nil, fmt.Errorf("cannot reserve all GPUs: GPUs %v are in use without reservation - run 'canhazgpu status' for details", unreservedGPUs)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: skipping GPUs %v, which are in use without reservation\n", unreservedGPUs)
+	}
+
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	return allocatedGPUs, nil
+	available := 0
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		if containsGPU(unreservedGPUs, gpuID) {
+			continue
+		}
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		if state.User == "" && !coolingDown(state, ae.config.GPUCooldown, time.Now()) {
+			available++
+		}
+	}
+
+	if available == 0 {
+		return nil, fmt.Errorf("no GPUs available - run 'canhazgpu status' for details")
+	}
+
+	resolved := *request
+	resolved.AllAvailable = false
+	resolved.GPUCount = available
+	return &resolved, nil
+}
+
+// coolingDown returns true if the GPU is free but was released less than the
+// cooldown period ago, so it can't be allocated yet
+func coolingDown(state *types.GPUState, cooldown time.Duration, now time.Time) bool {
+	if cooldown <= 0 || state.User != "" {
+		return false
+	}
+	lastReleased := state.LastReleased.ToTime()
+	return !lastReleased.IsZero() && now.Sub(lastReleased) < cooldown
+}
+
+// notEnoughGPUsError explains why a request by count couldn't be satisfied
+func notEnoughGPUsError(request *types.AllocationRequest, gpuCount int, unreservedGPUs []int) error {
+	available := gpuCount - len(unreservedGPUs)
+
+	var unreservedMsg string
+	if request.GPUClass != "" {
+		available = 0
+		for gpuID := 0; gpuID < gpuCount; gpuID++ {
+			if !containsGPU(unreservedGPUs, gpuID) && !containsGPU(request.ClassExcludedGPUs, gpuID) {
+				available++
+			}
+		}
+		unreservedMsg = fmt.Sprintf(" in GPU class %q", request.GPUClass)
+	}
+	if len(unreservedGPUs) > 0 {
+		unreservedMsg += fmt.Sprintf(" (%d GPUs in use without reservation - run 'canhazgpu status' for details)", len(unreservedGPUs))
+	}
+	if len(request.CoolingDownGPUs) > 0 {
+		unreservedMsg += fmt.Sprintf(" (GPUs %v were just released and are cooling down)", request.CoolingDownGPUs)
+	}
+	if request.MinFreeDuration > 0 {
+		unreservedMsg += fmt.Sprintf(" (GPUs next to reservations expiring within %s are skipped)", utils.FormatDuration(request.MinFreeDuration))
+	}
+
+	return fmt.Errorf("not enough GPUs available. Requested: %d, Available: %d%s",
+		request.GPUCount, available, unreservedMsg)
+}
+
+// withGPUTimes returns a copy of the request with the GPUs that are cooling
+// down, the release times of the free GPUs, and, with a minimum free
+// duration, when the manual reservations expire. Must be called while
+// holding the allocation lock.
+func (ae *AllocationEngine) withGPUTimes(ctx context.Context, request *types.AllocationRequest) (*types.AllocationRequest, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU count: %v", err)
+	}
+	states := ae.gpuStates(ctx, gpuCount)
+	releasedAt, cooling := freeGPUReleases(states, ae.config.GPUCooldown, time.Now())
+
+	timedRequest := *request
+	timedRequest.CoolingDownGPUs = cooling
+	timedRequest.ReleasedAt = releasedAt
+	if request.MinFreeDuration > 0 {
+		timedRequest.ExpiresAt = reservationExpiries(states)
+	}
+	return &timedRequest, nil
+}
+
+// gpuStates reads the state of each GPU, leaving out those that can't be read
+func (ae *AllocationEngine) gpuStates(ctx context.Context, gpuCount int) map[int]*types.GPUState {
+	states := make(map[int]*types.GPUState, gpuCount)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			continue
+		}
+		states[gpuID] = state
+	}
+	return states
+}
+
+// reservationExpiries returns the Unix time the manual reservation on each GPU
+// in states expires. GPUs without an expiring reservation are left out.
+func reservationExpiries(states map[int]*types.GPUState) map[int]int64 {
+	expiresAt := make(map[int]int64)
+	for gpuID, state := range states {
+		if state.User == "" || state.Type != types.ReservationTypeManual || state.ExpiryTime.IsZero() {
+			continue
+		}
+		expiresAt[gpuID] = state.ExpiryTime.Unix()
+	}
+	return expiresAt
+}
+
+// nearExpiringReservation reports whether a GPU next to gpuID, by index, holds
+// a manual reservation that expires within minFree of now. Adjacent GPUs
+// usually share a PCIe switch or NVLink, so a job placed there would soon
+// share them with whichever job takes over the expiring reservation's GPU.
+func nearExpiringReservation(expiresAt map[int]int64, gpuID int, minFree time.Duration, now time.Time) bool {
+	if minFree <= 0 {
+		return false
+	}
+	deadline := now.Add(minFree).Unix()
+	for _, neighbour := range []int{gpuID - 1, gpuID + 1} {
+		if expiry, ok := expiresAt[neighbour]; ok && expiry < deadline {
+			return true
+		}
+	}
+	return false
+}
+
+// freeGPUReleases returns the Unix time each free GPU in states was last
+// released, and the free GPUs released less than cooldown before now, in GPU
+// order. GPUs that were never released have no release time.
+func freeGPUReleases(states map[int]*types.GPUState, cooldown time.Duration, now time.Time) (map[int]int64, []int) {
+	releasedAt := make(map[int]int64)
+	cooling := []int{}
+	gpuIDs := slices.Sorted(maps.Keys(states))
+	for _, gpuID := range gpuIDs {
+		state := states[gpuID]
+		if state.User != "" || state.LastReleased.IsZero() {
+			continue
+		}
+		releasedAt[gpuID] = state.LastReleased.Unix()
+		if coolingDown(state, cooldown, now) {
+			cooling = append(cooling, gpuID)
+		}
+	}
+	return releasedAt, cooling
+}
+
+// preemptIdleReservations releases reservations that are held at a lower
+// priority than the request and show no GPU usage, so that the request can be
+// satisfied. Nothing is preempted unless enough candidates exist to satisfy the
+// whole request. Returns a map of preempted GPU ID to the reservation that was
+// released. Each freed GPU records the preempted user, so that the holder of a
+// manual reservation can be notified by the daemon even if the GPU isn't
+// reserved again. Must be called while holding the allocation lock.
+func (ae *AllocationEngine) preemptIdleReservations(ctx context.Context, request *types.AllocationRequest, usage map[int]*types.GPUUsage, unreservedGPUs []int) (map[int]*types.GPUState, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPU count: %v", err)
+	}
+
+	type candidate struct {
+		gpuID int
+		state *types.GPUState
+	}
+
+	now := time.Now()
+	free := 0
+	var candidates []candidate
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		if len(request.GPUIDs) > 0 && !containsGPU(request.GPUIDs, gpuID) {
+			continue
+		}
+		// GPUs the request can't be given are neither free nor worth
+		// preempting
+		if containsGPU(unreservedGPUs, gpuID) || containsGPU(request.ClassExcludedGPUs, gpuID) {
+			continue
+		}
+		if len(request.GPUIDs) == 0 && nearExpiringReservation(request.ExpiresAt, gpuID, request.MinFreeDuration, now) {
+			continue
+		}
+
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+
+		if state.User == "" {
+			if !containsGPU(request.CoolingDownGPUs, gpuID) {
+				free++
+			}
+			continue
+		}
+
+		if isPreemptible(state, usage[gpuID], request, ae.config.MemoryThreshold, now) {
+			candidates = append(candidates, candidate{gpuID: gpuID, state: state})
+		}
+	}
+
+	requested := request.GPUCount
+	if len(request.GPUIDs) > 0 {
+		requested = len(request.GPUIDs)
+	}
+	needed := requested - free
+	if needed <= 0 || len(candidates) < needed {
+		return nil, nil
+	}
+
+	// Preempt the lowest priority reservations first, then the longest held
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ri := types.PriorityRank(candidates[i].state.Priority)
+		rj := types.PriorityRank(candidates[j].state.Priority)
+		if ri != rj {
+			return ri < rj
+		}
+		return candidates[i].state.StartTime.ToTime().Before(candidates[j].state.StartTime.ToTime())
+	})
+
+	preempted := make(map[int]*types.GPUState)
+	for _, c := range candidates[:needed] {
+		usageRecord := types.NewUsageRecord(c.gpuID, c.state, now)
+		if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record usage history: %v\n", err)
+		}
+
+		availableState := &types.GPUState{
+			LastReleased:  types.FlexibleTime{Time: now},
+			PreemptedUser: c.state.User,
+			PreemptNotice: c.state.Type == types.ReservationTypeManual,
+		}
+		if err := ae.client.SetGPUState(ctx, c.gpuID, availableState); err != nil {
+			return preempted, fmt.Errorf("failed to release GPU %d: %v", c.gpuID, err)
+		}
+
+		preempted[c.gpuID] = c.state
+		fmt.Printf("Preempted idle %s-priority reservation on GPU %d held by %s\n",
+			priorityName(c.state.Priority), c.gpuID, c.state.User)
+	}
+
+	return preempted, nil
+}
+
+// isPreemptible reports whether a reservation may be preempted by the request.
+// Only reservations by other users, at a strictly lower priority, held for at
+// least PreemptionMinAge and demonstrably idle (no processes and memory usage
+// below the threshold) qualify. Partial queue allocations and shared GPUs are
+// never preempted.
+func isPreemptible(state *types.GPUState, usage *types.GPUUsage, request *types.AllocationRequest, memoryThreshold int, now time.Time) bool {
+	if state.User == "" || state.User == request.User || state.PartialQueueID != "" || state.IsShared() {
+		return false
+	}
+	if types.PriorityRank(state.Priority) >= types.PriorityRank(request.Priority) {
+		return false
+	}
+	if now.Sub(state.StartTime.ToTime()) < types.PreemptionMinAge {
+		return false
+	}
+	// Without usage information the reservation cannot be shown to be idle
+	if usage == nil {
+		return false
+	}
+	return len(usage.Processes) == 0 && usage.MemoryMB < memoryThreshold
+}
+
+// priorityName returns the display name of a priority, treating empty as normal
+func priorityName(priority string) string {
+	if priority == "" {
+		return types.PriorityNormal
+	}
+	return priority
+}
+
+// markPreemptedGPUs records which user was preempted on each newly allocated
+// GPU, so that the preempted user's supervisor can notice and stop its job,
+// or the daemon can notify the holder of a preempted manual reservation.
+// Must be called while holding the allocation lock.
+func (ae *AllocationEngine) markPreemptedGPUs(ctx context.Context, allocatedGPUs []int, preempted map[int]*types.GPUState) {
+	for _, gpuID := range allocatedGPUs {
+		victim, ok := preempted[gpuID]
+		if !ok {
+			continue
+		}
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil || state.User == "" {
+			continue
+		}
+		state.PreemptedUser = victim.User
+		state.PreemptNotice = victim.Type == types.ReservationTypeManual
+		if err := ae.client.SetGPUState(ctx, gpuID, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record preemption for GPU %d: %v\n", gpuID, err)
+		}
+	}
+}
+
+// markAdoptedGPUs sets the reservation source to "adopted" for newly allocated
+// GPUs that were already in unreserved use when they were force-reserved.
+// Must be called while holding the allocation lock.
+func (ae *AllocationEngine) markAdoptedGPUs(ctx context.Context, allocatedGPUs []int, adoptedGPUs []int) {
+	for _, gpuID := range allocatedGPUs {
+		if !containsGPU(adoptedGPUs, gpuID) {
+			continue
+		}
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil || state.User == "" {
+			continue
+		}
+		state.Source = types.ReservationSourceAdopted
+		if err := ae.client.SetGPUState(ctx, gpuID, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record adopted source for GPU %d: %v\n", gpuID, err)
+		}
+	}
+}
+
+// containsGPU reports whether gpuID is present in gpuIDs
+func containsGPU(gpuIDs []int, gpuID int) bool {
+	for _, id := range gpuIDs {
+		if id == gpuID {
+			return true
+		}
+	}
+	return false
 }
 
 // ReleaseGPUs releases manually reserved GPUs for a user
@@ -117,18 +641,22 @@ func (ae *AllocationEngine) ReleaseGPUs(ctx context.Context, user string) ([]int
 			continue
 		}
 
+		// Shared GPUs are released by giving up the user's share
+		if state.IsShared() && state.ShareOf(user) >= 0 {
+			released, err := ae.releaseShare(ctx, gpuID, user)
+			if err != nil {
+				return nil, fmt.Errorf("failed to release share of GPU %d: %v", gpuID, err)
+			}
+			if released {
+				releasedGPUs = append(releasedGPUs, gpuID)
+			}
+			continue
+		}
+
 		// Only release manual reservations by this user
 		if state.User == user && state.Type == types.ReservationTypeManual {
 			// Record usage history
-			duration := now.Sub(state.StartTime.ToTime()).Seconds()
-			usageRecord := &types.UsageRecord{
-				User:            state.User,
-				GPUID:           gpuID,
-				StartTime:       state.StartTime,
-				EndTime:         types.FlexibleTime{Time: now},
-				Duration:        duration,
-				ReservationType: state.Type,
-			}
+			usageRecord := types.NewUsageRecord(gpuID, state, now)
 
 			if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
 				// Log error but don't fail the release
@@ -162,18 +690,21 @@ func (ae *AllocationEngine) ReleaseSpecificGPUs(ctx context.Context, user string
 			continue
 		}
 
+		if state.IsShared() && state.ShareOf(user) >= 0 {
+			released, err := ae.releaseShare(ctx, gpuID, user)
+			if err != nil {
+				return nil, fmt.Errorf("failed to release share of GPU %d: %v", gpuID, err)
+			}
+			if released {
+				releasedGPUs = append(releasedGPUs, gpuID)
+			}
+			continue
+		}
+
 		// Release GPU if it's reserved by this user (either manual or run type)
 		if state.User == user && (state.Type == types.ReservationTypeManual || state.Type == types.ReservationTypeRun) {
 			// Record usage history
-			duration := now.Sub(state.StartTime.ToTime()).Seconds()
-			usageRecord := &types.UsageRecord{
-				User:            state.User,
-				GPUID:           gpuID,
-				StartTime:       state.StartTime,
-				EndTime:         types.FlexibleTime{Time: now},
-				Duration:        duration,
-				ReservationType: state.Type,
-			}
+			usageRecord := types.NewUsageRecord(gpuID, state, now)
 			if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
 				// Log error but don't fail the release
 				fmt.Fprintf(os.Stderr, "Warning: failed to record usage history: %v\n", err)
@@ -193,23 +724,111 @@ func (ae *AllocationEngine) ReleaseSpecificGPUs(ctx context.Context, user string
 	return releasedGPUs, nil
 }
 
-// GetGPUStatus returns the current status of all GPUs with validation
-func (ae *AllocationEngine) GetGPUStatus(ctx context.Context) ([]GPUStatusInfo, error) {
+// ReleaseAllGPUs releases every GPU reserved by a user, both manual and
+// run-type reservations, and the user's shares of shared GPUs
+func (ae *AllocationEngine) ReleaseAllGPUs(ctx context.Context, user string) ([]int, error) {
 	gpuCount, err := ae.client.GetGPUCount(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get actual GPU usage using cached provider information
+	gpuIDs := make([]int, gpuCount)
+	for gpuID := range gpuIDs {
+		gpuIDs[gpuID] = gpuID
+	}
+	return ae.ReleaseSpecificGPUs(ctx, user, gpuIDs)
+}
+
+// GetGPUUsage returns the current usage of each of the given GPUs. GPUs
+// without any detected usage are left out.
+func (ae *AllocationEngine) GetGPUUsage(ctx context.Context, gpuIDs []int) (map[int]*types.GPUUsage, error) {
 	usage, err := ae.detectGPUUsage(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to validate GPU usage: %v", err)
+		return nil, err
+	}
+
+	selected := make(map[int]*types.GPUUsage)
+	for _, gpuID := range gpuIDs {
+		if gpuUsage, ok := usage[gpuID]; ok {
+			selected[gpuID] = gpuUsage
+		}
+	}
+	return selected, nil
+}
+
+// CheckGPUHealth checks the given GPUs with the GPU provider and returns
+// what is wrong with each unhealthy one. GPUs the provider doesn't report at
+// all are unhealthy too. Returns ErrHealthCheckUnsupported if the provider
+// can't check GPU health.
+func (ae *AllocationEngine) CheckGPUHealth(ctx context.Context, gpuIDs []int) (map[int]string, error) {
+	pm, err := ae.providerManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := pm.CheckGPUHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unhealthy := make(map[int]string)
+	for _, gpuID := range gpuIDs {
+		problem, ok := health[gpuID]
+		if !ok {
+			problem = "not reported by the GPU provider"
+		}
+		if problem != "" {
+			unhealthy[gpuID] = problem
+		}
+	}
+	return unhealthy, nil
+}
+
+// GetGPUTelemetry reads the fan speed and clocks of each GPU from the GPU
+// provider. Returns ErrTelemetryUnsupported if the provider can't report
+// them.
+func (ae *AllocationEngine) GetGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error) {
+	pm, err := ae.providerManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pm.DetectGPUTelemetry(ctx)
+}
+
+// GetGPUStatus returns the current status of all GPUs with validation.
+// Reservation state is read from the read replica when one is configured.
+func (ae *AllocationEngine) GetGPUStatus(ctx context.Context) ([]GPUStatusInfo, error) {
+	return ae.getGPUStatus(ctx, true)
+}
+
+// GetGPUStatusWithoutValidation returns the status of all GPUs based purely on
+// the reservation state in Redis. It skips querying the GPU provider, so no
+// validation info is reported and unreserved usage is not detected.
+func (ae *AllocationEngine) GetGPUStatusWithoutValidation(ctx context.Context) ([]GPUStatusInfo, error) {
+	return ae.getGPUStatus(ctx, false)
+}
+
+func (ae *AllocationEngine) getGPUStatus(ctx context.Context, validate bool) ([]GPUStatusInfo, error) {
+	reader := ae.client.ReadOnly()
+
+	gpuCount, err := reader.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get actual GPU usage using cached provider information
+	var usage map[int]*types.GPUUsage
+	if validate {
+		usage, err = ae.detectGPUUsage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate GPU usage: %v", err)
+		}
 	}
 
 	var statuses []GPUStatusInfo
 
 	for gpuID := 0; gpuID < gpuCount; gpuID++ {
-		state, err := ae.client.GetGPUState(ctx, gpuID)
+		state, err := reader.GetGPUState(ctx, gpuID)
 		if err != nil {
 			statuses = append(statuses, GPUStatusInfo{
 				GPUID:  gpuID,
@@ -220,12 +839,18 @@ func (ae *AllocationEngine) GetGPUStatus(ctx context.Context) ([]GPUStatusInfo,
 		}
 
 		status := ae.buildGPUStatus(gpuID, state, usage[gpuID])
+		if !validate {
+			status.ValidationInfo = ValidationSkipped
+		}
 		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
 }
 
+// ValidationSkipped is the validation info reported when GPU validation was not performed
+const ValidationSkipped = "[validation skipped]"
+
 // GPUStatusInfo represents the status of a single GPU
 type GPUStatusInfo struct {
 	GPUID           int
@@ -238,12 +863,33 @@ type GPUStatusInfo struct {
 	LastReleased    time.Time
 	ValidationInfo  string
 	UnreservedUsers []string
-	ProcessInfo     string
-	Error           string
-	ModelInfo       *ModelInfo `json:"model_info,omitempty"` // Detected AI model information
-	Provider        string     `json:"provider,omitempty"`   // GPU provider (e.g., "NVIDIA", "AMD")
-	GPUModel        string     `json:"gpu_model,omitempty"`  // GPU model (e.g., "H100", "RTX 4090")
-	Note            string     `json:"note,omitempty"`       // Optional note describing the reservation purpose
+	// UnreservedOwners maps the unreserved users that are service accounts to
+	// the owner responsible for them (see Config.ProcessOwners)
+	UnreservedOwners map[string]string
+	ProcessInfo      string
+	Error            string
+	Source           string                 `json:"source,omitempty"`          // How the reservation was created ("run", "reserve", "adopted")
+	Priority         string                 `json:"priority,omitempty"`        // Reservation priority ("low", "normal", "high")
+	StartTime        time.Time              `json:"start_time,omitempty"`      // When the reservation was created
+	LastCheckpoint   time.Time              `json:"last_checkpoint,omitempty"` // When a long run reservation's usage was last checkpointed to the usage history
+	PIDs             []int                  `json:"pids,omitempty"`            // PIDs of processes using the GPU
+	Processes        []types.GPUProcessInfo `json:"processes,omitempty"`       // Processes using the GPU
+	ModelInfo        *ModelInfo             `json:"model_info,omitempty"`      // Detected AI model information
+	Provider         string                 `json:"provider,omitempty"`        // GPU provider (e.g., "NVIDIA", "AMD")
+	GPUModel         string                 `json:"gpu_model,omitempty"`       // GPU model (e.g., "H100", "RTX 4090")
+	UUID             string                 `json:"uuid,omitempty"`            // GPU UUID, if reported by the provider
+	Utilization      *int                   `json:"utilization,omitempty"`     // Compute utilization percent, if reported by the provider
+	Note             string                 `json:"note,omitempty"`            // Optional note describing the reservation purpose
+	Account          string                 `json:"account,omitempty"`         // Team account the usage is billed to
+	Renewable        bool                   `json:"renewable,omitempty"`       // Manual reservation extended by 'canhazgpu keepalive'
+	JobID            string                 `json:"job_id,omitempty"`          // Shared by all GPUs reserved by the same request
+	Command          string                 `json:"command,omitempty"`         // Command line of a run reservation, with secrets redacted
+	Shares           []types.GPUShare       `json:"shares,omitempty"`          // Holders of a shared GPU
+	MaxShares        int                    `json:"max_shares,omitempty"`      // How many holders a shared GPU can have
+	Annotation       string                 `json:"annotation,omitempty"`      // Note left on the reservation by an admin
+	AnnotatedBy      string                 `json:"annotated_by,omitempty"`
+	AnnotatedAt      time.Time              `json:"annotated_at,omitempty"`
+	Telemetry        *types.GPUTelemetry    `json:"telemetry,omitempty"` // Fan speed and clocks, only read for 'status --telemetry'
 }
 
 func (ae *AllocationEngine) buildGPUStatus(gpuID int, state *types.GPUState, usage *types.GPUUsage) GPUStatusInfo {
@@ -260,17 +906,34 @@ func (ae *AllocationEngine) buildGPUStatus(gpuID int, state *types.GPUState, usa
 		}
 		status.ReservationType = state.Type
 		status.Duration = time.Since(state.StartTime.ToTime())
+		status.StartTime = state.StartTime.ToTime()
+		status.LastCheckpoint = state.LastCheckpoint.ToTime()
 		status.LastHeartbeat = state.LastHeartbeat.ToTime()
 		status.ExpiryTime = state.ExpiryTime.ToTime()
 		status.Note = state.Note
+		status.Source = state.Source
+		status.Priority = state.Priority
+		status.Account = state.Account
+		status.Renewable = state.IsRenewable()
+		status.JobID = state.JobID
+		status.Command = state.Command
+		status.Annotation = state.Annotation
+		status.AnnotatedBy = state.AnnotatedBy
+		status.AnnotatedAt = state.AnnotatedAt.ToTime()
+		if state.IsShared() {
+			status.User = sharedHolders(state.Shares)
+			status.Shares = state.Shares
+			status.MaxShares = ae.maxSharesPerGPU()
+			status.ExpiryTime = earliestShareExpiry(state.Shares)
+		}
 
 		// Build validation info
 		if usage != nil && usage.MemoryMB > ae.config.MemoryThreshold {
 			if len(usage.Processes) > 0 {
-				status.ValidationInfo = fmt.Sprintf("[validated: %dMB, %d processes]",
-					usage.MemoryMB, len(usage.Processes))
+				status.ValidationInfo = fmt.Sprintf("[validated: %dMB%s, %d processes]",
+					usage.MemoryMB, utilizationInfo(usage), len(usage.Processes))
 			} else {
-				status.ValidationInfo = fmt.Sprintf("[validated: %dMB used]", usage.MemoryMB)
+				status.ValidationInfo = fmt.Sprintf("[validated: %dMB used%s]", usage.MemoryMB, utilizationInfo(usage))
 			}
 		} else {
 			status.ValidationInfo = "[validated: no usage detected]"
@@ -280,9 +943,19 @@ func (ae *AllocationEngine) buildGPUStatus(gpuID int, state *types.GPUState, usa
 		if IsGPUInUnreservedUse(usage, ae.config.MemoryThreshold) {
 			status.Status = "UNRESERVED"
 
-			// Get users from processes
+			// Get users from processes, leaving out ignored users, and the
+			// owners responsible for service accounts among them
 			var users []string
 			for user := range usage.Users {
+				if slices.Contains(ae.config.IgnoreUsers, user) {
+					continue
+				}
+				if owner := ae.config.ProcessOwner(user); owner != user {
+					if status.UnreservedOwners == nil {
+						status.UnreservedOwners = make(map[string]string)
+					}
+					status.UnreservedOwners[user] = owner
+				}
 				users = append(users, user)
 			}
 			status.UnreservedUsers = users
@@ -300,25 +973,52 @@ func (ae *AllocationEngine) buildGPUStatus(gpuID int, state *types.GPUState, usa
 
 			// Show memory usage for available GPUs
 			if usage != nil {
-				status.ValidationInfo = fmt.Sprintf("[validated: %dMB used]", usage.MemoryMB)
+				status.ValidationInfo = fmt.Sprintf("[validated: %dMB used%s]", usage.MemoryMB, ignoredInfo(usage))
+				if usage.Unconfirmed {
+					status.ValidationInfo = fmt.Sprintf("[validated: %dMB used%s, unconfirmed]", usage.MemoryMB, ignoredInfo(usage))
+				}
 			}
 		}
 	}
 
-	// Detect model information from processes if available
+	// Detect model information and collect PIDs from processes if available
 	if usage != nil && len(usage.Processes) > 0 {
 		status.ModelInfo = DetectModelFromProcesses(usage.Processes)
+		for _, proc := range usage.Processes {
+			status.PIDs = append(status.PIDs, proc.PID)
+		}
+		status.Processes = usage.Processes
 	}
 
 	// Add GPU provider and model information if available
 	if usage != nil {
 		status.Provider = usage.Provider
 		status.GPUModel = usage.Model
+		status.UUID = usage.UUID
+		status.Utilization = usage.Utilization
 	}
 
 	return status
 }
 
+// utilizationInfo formats a GPU's compute utilization for validation info,
+// so that reservations holding memory without computing stand out
+func utilizationInfo(usage *types.GPUUsage) string {
+	if usage.Utilization == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %d%% util", *usage.Utilization)
+}
+
+// ignoredInfo formats the memory used by ignored processes for validation
+// info, so that a free GPU with memory in use is explained
+func ignoredInfo(usage *types.GPUUsage) string {
+	if usage.IgnoredMemoryMB == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %dMB ignored", usage.IgnoredMemoryMB)
+}
+
 // CleanupExpiredReservations removes expired manual reservations
 func (ae *AllocationEngine) CleanupExpiredReservations(ctx context.Context) error {
 	gpuCount, err := ae.client.GetGPUCount(ctx)
@@ -326,6 +1026,13 @@ func (ae *AllocationEngine) CleanupExpiredReservations(ctx context.Context) erro
 		return err
 	}
 
+	bootTime := ae.detectReboot(ctx)
+
+	heartbeatTimeout, err := ae.client.GetHeartbeatTimeout(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: using the default heartbeat timeout: %v\n", err)
+	}
+
 	now := time.Now()
 
 	for gpuID := 0; gpuID < gpuCount; gpuID++ {
@@ -334,6 +1041,16 @@ func (ae *AllocationEngine) CleanupExpiredReservations(ctx context.Context) erro
 			continue
 		}
 
+		// Shared GPUs expire one share at a time
+		if state.IsShared() {
+			if hasExpiredShare(state, now) {
+				if _, err := ae.expireShares(ctx, gpuID); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to expire shares of GPU %d: %v\n", gpuID, err)
+				}
+			}
+			continue
+		}
+
 		var shouldRelease bool
 		var reason string
 
@@ -348,22 +1065,26 @@ func (ae *AllocationEngine) CleanupExpiredReservations(ctx context.Context) erro
 		// Check for stale heartbeats (run-type reservations)
 		if state.Type == types.ReservationTypeRun &&
 			!state.LastHeartbeat.ToTime().IsZero() &&
-			now.Sub(state.LastHeartbeat.ToTime()) > types.HeartbeatTimeout {
+			now.Sub(state.LastHeartbeat.ToTime()) > heartbeatTimeout {
 			shouldRelease = true
 			reason = "stale heartbeat"
 		}
 
+		// Check for run reservations whose process died unnoticed
+		if !shouldRelease {
+			shouldRelease, reason = deadRunProcess(state, now)
+		}
+
+		// Run reservations made before a reboot lost their process with it.
+		// Manual reservations are kept, since they aren't tied to a process.
+		if !shouldRelease && runReservationBeforeBoot(state, bootTime) {
+			shouldRelease = true
+			reason = "host rebooted"
+		}
+
 		if shouldRelease && state.User != "" {
 			// Record usage history
-			duration := now.Sub(state.StartTime.ToTime()).Seconds()
-			usageRecord := &types.UsageRecord{
-				User:            state.User,
-				GPUID:           gpuID,
-				StartTime:       state.StartTime,
-				EndTime:         types.FlexibleTime{Time: now},
-				Duration:        duration,
-				ReservationType: state.Type,
-			}
+			usageRecord := types.NewUsageRecord(gpuID, state, now)
 
 			if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
 				// Log error but don't fail the cleanup
@@ -383,6 +1104,34 @@ func (ae *AllocationEngine) CleanupExpiredReservations(ctx context.Context) erro
 	return nil
 }
 
+// detectReboot records the host's boot ID and, if it changed since it was
+// last recorded, returns when the host booted. Only the first process to see
+// the new boot ID gets the boot time; otherwise the zero time is returned.
+func (ae *AllocationEngine) detectReboot(ctx context.Context) time.Time {
+	bootID, err := HostBootID()
+	if err != nil {
+		return time.Time{}
+	}
+
+	previous, err := ae.client.SwapBootID(ctx, bootID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record boot ID: %v\n", err)
+		return time.Time{}
+	}
+	if previous == "" || previous == bootID {
+		return time.Time{}
+	}
+
+	bootTime, err := HostBootTime()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: host rebooted but its boot time is unknown: %v\n", err)
+		return time.Time{}
+	}
+	fmt.Fprintf(os.Stderr, "Host rebooted at %s, releasing run reservations made before then\n",
+		bootTime.Format("2006-01-02 15:04:05"))
+	return bootTime
+}
+
 // QueuedAllocationRequest extends AllocationRequest with queue-specific options
 type QueuedAllocationRequest struct {
 	*types.AllocationRequest
@@ -395,22 +1144,44 @@ type QueuedAllocationResult struct {
 	AllocatedGPUs []int
 	QueueEntry    *types.QueueEntry // Non-nil if allocation is still pending
 	Error         error
+	Timing        AllocationTiming
+}
+
+// AllocationTiming is how long a successful allocation took, shown by 'run
+// --timing'
+type AllocationTiming struct {
+	Total     time.Duration // From the start of the allocation to the GPUs being reserved
+	Detection time.Duration // Detecting GPU usage with nvidia-smi or amd-smi
+	LockWait  time.Duration // Waiting for the allocation lock
+	QueueWait time.Duration // Waiting in the queue before the final attempt, not part of Total
+}
+
+// recordAllocationLatency adds the latency of a successful allocation to the
+// histogram exported by the metrics. Failing to record it doesn't fail the
+// allocation.
+func (ae *AllocationEngine) recordAllocationLatency(ctx context.Context, latency time.Duration) {
+	if err := ae.client.RecordAllocationLatency(ctx, latency); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record allocation latency: %v\n", err)
+	}
 }
 
 // AllocateGPUsWithQueue allocates GPUs, optionally waiting in a queue if unavailable
 func (ae *AllocationEngine) AllocateGPUsWithQueue(ctx context.Context, request *QueuedAllocationRequest) (*QueuedAllocationResult, error) {
 	// First, try immediate allocation
-	allocatedGPUs, err := ae.AllocateGPUs(ctx, request.AllocationRequest)
+	allocatedGPUs, timing, err := ae.allocateGPUs(ctx, request.AllocationRequest)
 	if err == nil {
-		return &QueuedAllocationResult{AllocatedGPUs: allocatedGPUs}, nil
+		return &QueuedAllocationResult{AllocatedGPUs: allocatedGPUs, Timing: timing}, nil
 	}
 
-	// If not blocking, return the error immediately
-	if !request.Blocking {
+	// If not blocking, return the error immediately. Requests for all
+	// available GPUs don't wait either, since how many GPUs they need is only
+	// known at allocation time.
+	if !request.Blocking || request.AllAvailable {
 		return nil, err
 	}
 
 	// Create a queue entry
+	queuedAt := time.Now()
 	queueEntry := ae.createQueueEntry(request)
 
 	// Add to queue
@@ -435,6 +1206,7 @@ func (ae *AllocationEngine) AllocateGPUsWithQueue(ctx context.Context, request *
 	}
 
 	queueHeartbeat.Stop()
+	result.Timing.QueueWait = time.Since(queuedAt) - result.Timing.Total
 	return result, nil
 }
 
@@ -451,6 +1223,12 @@ func (ae *AllocationEngine) createQueueEntry(request *QueuedAllocationRequest) *
 		AllocatedGPUs:   []int{},
 		ReservationType: request.ReservationType,
 		Note:            request.Note,
+		Source:          request.Source,
+		Priority:        request.Priority,
+		Account:         request.Account,
+		Renewable:       request.Renewable,
+		RemindBefore:    request.RemindBefore,
+		Command:         request.Command,
 		EnqueueTime:     types.FlexibleTime{Time: now},
 		LastHeartbeat:   types.FlexibleTime{Time: now},
 	}
@@ -549,10 +1327,14 @@ func (ae *AllocationEngine) waitForGPUs(ctx context.Context, queueEntry *types.Q
 
 // tryAllocateForQueueEntry attempts to allocate GPUs for the first queue entry
 func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueEntry *types.QueueEntry, request *QueuedAllocationRequest) (*QueuedAllocationResult, error) {
+	start := time.Now()
+	var timing AllocationTiming
+
 	// Acquire lock
 	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
 		return nil, err
 	}
+	timing.LockWait = time.Since(start)
 	defer func() { _ = ae.client.ReleaseAllocationLock(ctx) }()
 
 	// Re-fetch the queue entry to get latest allocated GPUs
@@ -566,7 +1348,7 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 
 	// Check if already complete
 	if entry.IsComplete() {
-		return ae.finalizeAllocation(ctx, entry, request)
+		return ae.finalizeAllocation(ctx, entry, request, start, timing)
 	}
 
 	// Refresh heartbeats for already-allocated GPUs to prevent them from
@@ -584,13 +1366,17 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 	}
 
 	// Get available GPUs
+	detectStart := time.Now()
 	usage, err := ae.detectGPUUsage(ctx)
 	if err != nil {
 		return nil, err
 	}
+	timing.Detection = time.Since(detectStart)
 
 	unreservedGPUs := GetUnreservedGPUs(ctx, usage, ae.config.MemoryThreshold)
+	var adoptedGPUs []int
 	if request.Force {
+		adoptedGPUs = unreservedGPUs
 		unreservedGPUs = []int{}
 	}
 
@@ -600,8 +1386,29 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 		return nil, err
 	}
 
+	var classExcluded []int
+	if request.GPUClass != "" {
+		classExcluded, err = classExcludedGPUs(ae.config.GPUClasses, request.GPUClass, request.GPUCount, gpuCount, usage)
+		if err != nil {
+			return nil, err
+		}
+	}
+	states := ae.gpuStates(ctx, gpuCount)
+	_, cooling := freeGPUReleases(states, ae.config.GPUCooldown, now)
+	var expiresAt map[int]int64
+	if len(entry.RequestedIDs) == 0 {
+		expiresAt = reservationExpiries(states)
+	}
+
 	var availableGPUs []int
 	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		// Skip GPUs outside the requested class, GPUs that were just
+		// released, and GPUs next to a reservation about to expire
+		if containsGPU(classExcluded, gpuID) || containsGPU(cooling, gpuID) ||
+			nearExpiringReservation(expiresAt, gpuID, request.MinFreeDuration, now) {
+			continue
+		}
+
 		// Skip already allocated to this entry
 		alreadyAllocated := false
 		for _, allocatedID := range entry.AllocatedGPUs {
@@ -651,19 +1458,36 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 		}
 	}
 
+	// Calculate how many more we need
+	needed := entry.GetRequestedGPUCount() - len(entry.AllocatedGPUs)
+
+	// If too few GPUs are free and preemption was requested, free idle
+	// lower-priority reservations for the rest
+	var preempted map[int]*types.GPUState
+	if needed > len(availableGPUs) && request.Preempt {
+		excluded := append(append(append([]int{}, unreservedGPUs...), classExcluded...), cooling...)
+		preempted, err = ae.preemptIdleReservations(ctx, remainingRequest(request.AllocationRequest, entry), usage, excluded)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: preemption failed: %v\n", err)
+		}
+		for gpuID := range preempted {
+			availableGPUs = append(availableGPUs, gpuID)
+		}
+		sort.Ints(availableGPUs)
+	}
+
 	// No new GPUs available
 	if len(availableGPUs) == 0 {
 		return nil, nil
 	}
 
-	// Calculate how many more we need
-	needed := entry.GetRequestedGPUCount() - len(entry.AllocatedGPUs)
 	if needed > len(availableGPUs) {
 		needed = len(availableGPUs)
 	}
 
 	// Allocate the available GPUs (greedy partial allocation)
 	now = time.Now()
+	newlyAllocated := availableGPUs[:needed]
 	for i := 0; i < needed; i++ {
 		gpuID := availableGPUs[i]
 
@@ -675,12 +1499,24 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 			Type:           entry.ReservationType,
 			Note:           entry.Note,
 			PartialQueueID: entry.ID,
+			Source:         entry.Source,
+			Priority:       entry.Priority,
+			Account:        entry.Account,
+			JobID:          entry.ID,
+			Command:        entry.Command,
+		}
+		if containsGPU(adoptedGPUs, gpuID) {
+			gpuState.Source = types.ReservationSourceAdopted
 		}
 
 		if entry.ReservationType == types.ReservationTypeRun {
 			gpuState.LastHeartbeat = types.FlexibleTime{Time: now}
 		} else if entry.ReservationType == types.ReservationTypeManual && entry.ExpiryDuration > 0 {
 			gpuState.ExpiryTime = types.FlexibleTime{Time: now.Add(entry.ExpiryDuration)}
+			if entry.Renewable {
+				gpuState.RenewDuration = int64(entry.ExpiryDuration / time.Second)
+			}
+			gpuState.RemindBefore = int64(entry.RemindBefore / time.Second)
 		}
 
 		if err := ae.client.SetGPUState(ctx, gpuID, gpuState); err != nil {
@@ -689,6 +1525,7 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 
 		entry.AllocatedGPUs = append(entry.AllocatedGPUs, gpuID)
 	}
+	ae.markPreemptedGPUs(ctx, newlyAllocated, preempted)
 
 	// Update queue entry
 	if err := ae.client.UpdateQueueEntry(ctx, entry); err != nil {
@@ -697,14 +1534,29 @@ func (ae *AllocationEngine) tryAllocateForQueueEntry(ctx context.Context, queueE
 
 	// Check if complete
 	if entry.IsComplete() {
-		return ae.finalizeAllocation(ctx, entry, request)
+		return ae.finalizeAllocation(ctx, entry, request, start, timing)
 	}
 
 	return nil, nil // Still waiting for more GPUs
 }
 
-// finalizeAllocation converts partial allocations to final reservations
-func (ae *AllocationEngine) finalizeAllocation(ctx context.Context, entry *types.QueueEntry, request *QueuedAllocationRequest) (*QueuedAllocationResult, error) {
+// remainingRequest returns the part of a queued request that is still to be
+// allocated: the GPUs the queue entry doesn't hold yet
+func remainingRequest(request *types.AllocationRequest, entry *types.QueueEntry) *types.AllocationRequest {
+	remaining := *request
+	remaining.GPUCount = entry.GetRequestedGPUCount() - len(entry.AllocatedGPUs)
+	remaining.GPUIDs = nil
+	for _, gpuID := range entry.RequestedIDs {
+		if !containsGPU(entry.AllocatedGPUs, gpuID) {
+			remaining.GPUIDs = append(remaining.GPUIDs, gpuID)
+		}
+	}
+	return &remaining
+}
+
+// finalizeAllocation converts partial allocations to final reservations. The
+// timing of the attempt that started at start is completed and recorded.
+func (ae *AllocationEngine) finalizeAllocation(ctx context.Context, entry *types.QueueEntry, request *QueuedAllocationRequest, start time.Time, timing AllocationTiming) (*QueuedAllocationResult, error) {
 	now := time.Now()
 
 	// Clear the partial queue ID from all allocated GPUs
@@ -727,8 +1579,12 @@ func (ae *AllocationEngine) finalizeAllocation(ctx context.Context, entry *types
 		}
 	}
 
+	timing.Total = time.Since(start)
+	ae.recordAllocationLatency(ctx, timing.Total)
+
 	return &QueuedAllocationResult{
 		AllocatedGPUs: entry.AllocatedGPUs,
+		Timing:        timing,
 	}, nil
 }
 
@@ -749,15 +1605,7 @@ func (ae *AllocationEngine) cleanupQueueEntry(ctx context.Context, entry *types.
 		// Record usage history before releasing
 		state, stateErr := ae.client.GetGPUState(ctx, gpuID)
 		if stateErr == nil && state.User == entry.User {
-			duration := now.Sub(state.StartTime.ToTime()).Seconds()
-			usageRecord := &types.UsageRecord{
-				User:            state.User,
-				GPUID:           gpuID,
-				StartTime:       state.StartTime,
-				EndTime:         types.FlexibleTime{Time: now},
-				Duration:        duration,
-				ReservationType: state.Type,
-			}
+			usageRecord := types.NewUsageRecord(gpuID, state, now)
 			if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to record usage history: %v\n", err)
 			}
@@ -779,5 +1627,19 @@ func (ae *AllocationEngine) cleanupQueueEntry(ctx context.Context, entry *types.
 
 // GetQueueStatus returns the current queue status for display
 func (ae *AllocationEngine) GetQueueStatus(ctx context.Context) (*types.QueueStatus, error) {
-	return ae.client.GetQueueStatus(ctx)
+	status, err := ae.client.GetQueueStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(status.Entries) > 0 {
+		waits, err := ae.queueWaitEstimates(ctx, status.Entries, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to estimate queue wait times: %v\n", err)
+		} else {
+			status.EstimatedWaits = waits
+		}
+	}
+
+	return status, nil
 }
@@ -2,12 +2,15 @@ package gpu
 
 import (
 	"context"
+	"maps"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/russellb/canhazgpu/internal/redis_client"
 	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAllocationEngine_Structure(t *testing.T) {
@@ -64,6 +67,48 @@ func TestAllocationEngine_GetGPUStatus_Structure(t *testing.T) {
 	}
 }
 
+func TestAllocationEngine_GetGPUStatusWithoutValidation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := &types.Config{
+		RedisHost:       "localhost",
+		RedisPort:       6379,
+		RedisDB:         15,
+		MemoryThreshold: types.MemoryThresholdMB,
+	}
+	redisClient := redis_client.NewClient(config)
+	defer func() { _ = redisClient.Close() }()
+
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx); err != nil {
+		t.Skipf("Redis not available for testing: %v", err)
+	}
+	_ = redisClient.FlushTestDB(ctx)
+	defer func() { _ = redisClient.FlushTestDB(ctx) }()
+
+	// No provider is set, so this would fail if validation were attempted
+	assert.NoError(t, redisClient.SetGPUCount(ctx, 2))
+	assert.NoError(t, redisClient.SetGPUState(ctx, 1, &types.GPUState{
+		User:      "testuser",
+		StartTime: types.FlexibleTime{Time: time.Now()},
+		Type:      types.ReservationTypeManual,
+	}))
+
+	engine := NewAllocationEngine(redisClient, config)
+	statuses, err := engine.GetGPUStatusWithoutValidation(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+
+	assert.Equal(t, "AVAILABLE", statuses[0].Status)
+	assert.Equal(t, "IN_USE", statuses[1].Status)
+	assert.Equal(t, "testuser", statuses[1].User)
+	for _, status := range statuses {
+		assert.Equal(t, ValidationSkipped, status.ValidationInfo)
+	}
+}
+
 func TestAllocationEngine_AllocateGPUs_Structure(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -514,3 +559,460 @@ func TestReleaseSpecificGPUs(t *testing.T) {
 		assert.Empty(t, released)
 	})
 }
+
+func TestRemainingRequest(t *testing.T) {
+	request := &types.AllocationRequest{GPUCount: 3, User: "alice", Priority: types.PriorityHigh, Preempt: true}
+
+	// A count request needs the GPUs the entry doesn't hold yet
+	entry := &types.QueueEntry{RequestedCount: 3, AllocatedGPUs: []int{1}}
+	remaining := remainingRequest(request, entry)
+	assert.Equal(t, 2, remaining.GPUCount)
+	assert.Empty(t, remaining.GPUIDs)
+	assert.Equal(t, types.PriorityHigh, remaining.Priority)
+	assert.Equal(t, 3, request.GPUCount, "the request itself is unchanged")
+
+	// A specific IDs request needs the IDs not allocated yet
+	entry = &types.QueueEntry{RequestedIDs: []int{0, 2, 3}, AllocatedGPUs: []int{2}}
+	remaining = remainingRequest(&types.AllocationRequest{GPUIDs: []int{0, 2, 3}}, entry)
+	assert.Equal(t, []int{0, 3}, remaining.GPUIDs)
+}
+
+func TestPreemptIdleReservationsSkipsUnusableGPUs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+	engine := NewAllocationEngine(client, &types.Config{RedisHost: "localhost", RedisPort: 6379, RedisDB: 15})
+
+	// GPU 0 was just released and GPUs 2 and 3 are outside the requested
+	// class. GPUs 1 and 3 hold idle low-priority reservations, the one on
+	// GPU 3 held longer.
+	now := time.Now()
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-5 * time.Second)}}))
+	require.NoError(t, client.SetGPUState(ctx, 1, &types.GPUState{
+		User: "bob", Type: types.ReservationTypeRun, Priority: types.PriorityLow, StartTime: types.FlexibleTime{Time: now.Add(-time.Hour)},
+	}))
+	require.NoError(t, client.SetGPUState(ctx, 3, &types.GPUState{
+		User: "carol", Type: types.ReservationTypeRun, Priority: types.PriorityLow, StartTime: types.FlexibleTime{Time: now.Add(-2 * time.Hour)},
+	}))
+	usage := map[int]*types.GPUUsage{0: {}, 1: {MemoryMB: 10}, 2: {}, 3: {MemoryMB: 10}}
+
+	request := &types.AllocationRequest{
+		GPUCount:          1,
+		User:              "alice",
+		ReservationType:   types.ReservationTypeRun,
+		Priority:          types.PriorityHigh,
+		Preempt:           true,
+		ClassExcludedGPUs: []int{2, 3},
+		CoolingDownGPUs:   []int{0},
+	}
+
+	// The cooling down GPU doesn't count as free, and the reservation
+	// outside the requested class is left alone
+	preempted, err := engine.preemptIdleReservations(ctx, request, usage, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, slices.Sorted(maps.Keys(preempted)))
+
+	state, err := client.GetGPUState(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "carol", state.User)
+}
+
+func TestIsPreemptible(t *testing.T) {
+	now := time.Now()
+	request := &types.AllocationRequest{
+		GPUCount:        1,
+		User:            "alice",
+		ReservationType: types.ReservationTypeRun,
+		Priority:        types.PriorityHigh,
+		Preempt:         true,
+	}
+	idleUsage := &types.GPUUsage{MemoryMB: 10}
+	oldStart := types.FlexibleTime{Time: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name        string
+		state       *types.GPUState
+		usage       *types.GPUUsage
+		preemptible bool
+	}{
+		{
+			name:        "idle lower-priority reservation",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: oldStart},
+			usage:       idleUsage,
+			preemptible: true,
+		},
+		{
+			name:        "idle reservation without priority counts as normal",
+			state:       &types.GPUState{User: "bob", StartTime: oldStart},
+			usage:       idleUsage,
+			preemptible: true,
+		},
+		{
+			name:        "equal priority",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityHigh, StartTime: oldStart},
+			usage:       idleUsage,
+			preemptible: false,
+		},
+		{
+			name:        "own reservation",
+			state:       &types.GPUState{User: "alice", Priority: types.PriorityLow, StartTime: oldStart},
+			usage:       idleUsage,
+			preemptible: false,
+		},
+		{
+			name:        "recently started",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: types.FlexibleTime{Time: now.Add(-time.Minute)}},
+			usage:       idleUsage,
+			preemptible: false,
+		},
+		{
+			name:        "partial queue allocation",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: oldStart, PartialQueueID: "queue-1"},
+			usage:       idleUsage,
+			preemptible: false,
+		},
+		{
+			name:        "unknown usage",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: oldStart},
+			usage:       nil,
+			preemptible: false,
+		},
+		{
+			name:  "running processes",
+			state: &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: oldStart},
+			usage: &types.GPUUsage{
+				MemoryMB:  10,
+				Processes: []types.GPUProcessInfo{{PID: 1234, ProcessName: "python", User: "bob"}},
+			},
+			preemptible: false,
+		},
+		{
+			name:        "memory above threshold",
+			state:       &types.GPUState{User: "bob", Priority: types.PriorityLow, StartTime: oldStart},
+			usage:       &types.GPUUsage{MemoryMB: 4096},
+			preemptible: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.preemptible, isPreemptible(tt.state, tt.usage, request, types.MemoryThresholdMB, now))
+		})
+	}
+}
+
+func TestCoolingDown(t *testing.T) {
+	now := time.Now()
+	justReleased := &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-10 * time.Second)}}
+	releasedLongAgo := &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-time.Hour)}}
+	neverUsed := &types.GPUState{}
+	reserved := &types.GPUState{User: "bob", LastReleased: types.FlexibleTime{Time: now.Add(-10 * time.Second)}}
+
+	assert.True(t, coolingDown(justReleased, time.Minute, now))
+	assert.False(t, coolingDown(justReleased, 5*time.Second, now))
+	assert.False(t, coolingDown(justReleased, 0, now), "cooldown is off by default")
+	assert.False(t, coolingDown(releasedLongAgo, time.Minute, now))
+	assert.False(t, coolingDown(neverUsed, time.Minute, now))
+	assert.False(t, coolingDown(reserved, time.Minute, now))
+}
+
+func TestFreeGPUReleases(t *testing.T) {
+	now := time.Now()
+	justReleased := now.Add(-5 * time.Second)
+	states := map[int]*types.GPUState{
+		0: {LastReleased: types.FlexibleTime{Time: justReleased}},
+		1: {LastReleased: types.FlexibleTime{Time: now.Add(-time.Hour)}},
+		// Release times in any time zone are compared correctly
+		2: {LastReleased: types.FlexibleTime{Time: justReleased.In(time.FixedZone("", -5*3600))}},
+		3: {User: "bob", LastReleased: types.FlexibleTime{Time: justReleased}},
+		4: {},
+	}
+
+	releasedAt, cooling := freeGPUReleases(states, time.Minute, now)
+	assert.Equal(t, map[int]int64{0: justReleased.Unix(), 1: now.Add(-time.Hour).Unix(), 2: justReleased.Unix()}, releasedAt)
+	assert.Equal(t, []int{0, 2}, cooling)
+
+	// Without a cooldown nothing is cooling down
+	_, cooling = freeGPUReleases(states, 0, now)
+	assert.Empty(t, cooling)
+}
+
+func TestNotEnoughGPUsError(t *testing.T) {
+	request := &types.AllocationRequest{GPUCount: 2}
+	assert.EqualError(t, notEnoughGPUsError(request, 4, nil),
+		"not enough GPUs available. Requested: 2, Available: 4")
+
+	// The GPU class and the GPUs in use without reservation are both explained
+	request = &types.AllocationRequest{GPUCount: 2, GPUClass: types.GPUClassLarge, ClassExcludedGPUs: []int{0, 1}}
+	err := notEnoughGPUsError(request, 4, []int{2})
+	assert.ErrorContains(t, err, "Available: 1 in GPU class \"large\"")
+	assert.ErrorContains(t, err, "(1 GPUs in use without reservation")
+
+	request.CoolingDownGPUs = []int{3}
+	request.MinFreeDuration = time.Hour
+	err = notEnoughGPUsError(request, 4, []int{2})
+	assert.ErrorContains(t, err, "in GPU class \"large\" (1 GPUs in use without reservation")
+	assert.ErrorContains(t, err, "(GPUs [3] were just released and are cooling down)")
+	assert.ErrorContains(t, err, "(GPUs next to reservations expiring within 1h 0m 0s are skipped)")
+}
+
+func TestReservationExpiries(t *testing.T) {
+	expiry := time.Now().Add(10 * time.Minute)
+	states := map[int]*types.GPUState{
+		0: {User: "alice", Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: expiry}},
+		1: {User: "bob", Type: types.ReservationTypeRun},
+		2: {User: "carol", Type: types.ReservationTypeManual},
+		3: {},
+	}
+
+	assert.Equal(t, map[int]int64{0: expiry.Unix()}, reservationExpiries(states))
+}
+
+func TestNearExpiringReservation(t *testing.T) {
+	now := time.Now()
+	expiresAt := map[int]int64{
+		1: now.Add(10 * time.Minute).Unix(),
+		5: now.Add(3 * time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name     string
+		gpuID    int
+		minFree  time.Duration
+		expected bool
+	}{
+		{"next to a reservation expiring too soon", 0, time.Hour, true},
+		{"on the other side of it", 2, time.Hour, true},
+		{"not a neighbour", 3, time.Hour, false},
+		{"next to a reservation lasting long enough", 4, time.Hour, false},
+		{"expiring after a short minimum", 2, 5 * time.Minute, false},
+		{"no minimum", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nearExpiringReservation(expiresAt, tt.gpuID, tt.minFree, now))
+		})
+	}
+}
+
+func TestClassExcludedGPUs(t *testing.T) {
+	usage := map[int]*types.GPUUsage{
+		0: {GPUID: 0, TotalMemoryMB: 24576},
+		1: {GPUID: 1, TotalMemoryMB: 81559},
+		2: {GPUID: 2, TotalMemoryMB: 81559},
+		3: {GPUID: 3}, // Total memory unknown
+	}
+
+	excluded, err := classExcludedGPUs(nil, types.GPUClassLarge, 2, 5, usage)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 3, 4}, excluded)
+
+	excluded, err = classExcludedGPUs(nil, types.GPUClassSmall, 1, 5, usage)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, excluded)
+
+	_, err = classExcludedGPUs(nil, types.GPUClassLarge, 3, 5, usage)
+	assert.ErrorContains(t, err, "only 2 GPUs on this host are in GPU class \"large\"")
+
+	classes := map[string]types.GPUClass{"huge": {MinMemoryMB: 100000}}
+	_, err = classExcludedGPUs(classes, "huge", 1, 5, usage)
+	assert.ErrorContains(t, err, "no GPUs on this host are in GPU class \"huge\" (at least 100000MB of memory)")
+
+	_, err = classExcludedGPUs(classes, types.GPUClassLarge, 1, 5, usage)
+	assert.ErrorContains(t, err, "unknown GPU class")
+}
+
+func TestMarkUnconfirmedUsage(t *testing.T) {
+	now := time.Now()
+	usage := map[int]*types.GPUUsage{
+		0: {GPUID: 0, MemoryMB: 4096}, // first sighting
+		1: {GPUID: 1, MemoryMB: 4096}, // seen in the previous sample
+		2: {GPUID: 2, MemoryMB: 4096}, // seen too long ago
+		3: {GPUID: 3, MemoryMB: 4096}, // seen in a concurrent sample
+		4: {GPUID: 4, MemoryMB: 100},  // dropped below the threshold
+		5: {GPUID: 5, MemoryMB: 0},    // never seen
+	}
+	previous := map[int]time.Time{
+		1: now.Add(-30 * time.Second),
+		2: now.Add(-time.Hour),
+		3: now.Add(-100 * time.Millisecond),
+		4: now.Add(-30 * time.Second),
+	}
+
+	seen, cleared := markUnconfirmedUsage(usage, previous, types.MemoryThresholdMB, now)
+
+	assert.True(t, usage[0].Unconfirmed)
+	assert.False(t, usage[1].Unconfirmed)
+	assert.True(t, usage[2].Unconfirmed)
+	assert.True(t, usage[3].Unconfirmed)
+	assert.False(t, usage[4].Unconfirmed)
+	assert.False(t, usage[5].Unconfirmed)
+
+	// The concurrent sample keeps its original time so that a later sample
+	// can still confirm it
+	assert.Equal(t, map[int]time.Time{0: now, 1: now, 2: now}, seen)
+	assert.Equal(t, []int{4}, cleared)
+
+	// Memory used by ignored processes isn't sampled
+	ignored := map[int]*types.GPUUsage{0: {GPUID: 0, MemoryMB: 4096, IgnoredMemoryMB: 4000}}
+	seen, cleared = markUnconfirmedUsage(ignored, map[int]time.Time{0: now.Add(-30 * time.Second)}, types.MemoryThresholdMB, now)
+	assert.False(t, ignored[0].Unconfirmed)
+	assert.Empty(t, seen)
+	assert.Equal(t, []int{0}, cleared)
+}
+
+func TestBuildGPUStatusIgnoredProcesses(t *testing.T) {
+	engine := NewAllocationEngine(nil, &types.Config{MemoryThreshold: 1024, IgnoreUsers: []string{"root"}})
+
+	// A free GPU used only by an ignored daemon is available
+	usage := &types.GPUUsage{
+		MemoryMB:        2048,
+		IgnoredMemoryMB: 2048,
+		Processes:       []types.GPUProcessInfo{{PID: 1, ProcessName: "Xorg", User: "root", MemoryMB: 2048}},
+		Users:           map[string]bool{"root": true},
+	}
+	status := engine.buildGPUStatus(0, &types.GPUState{}, usage)
+	assert.Equal(t, "AVAILABLE", status.Status)
+	assert.Equal(t, "[validated: 2048MB used, 2048MB ignored]", status.ValidationInfo)
+
+	// Ignored users aren't listed as unreserved users
+	usage.MemoryMB = 10240
+	usage.Processes = append(usage.Processes, types.GPUProcessInfo{PID: 2, ProcessName: "python", User: "bob", MemoryMB: 8192})
+	usage.Users["bob"] = true
+	status = engine.buildGPUStatus(0, &types.GPUState{}, usage)
+	assert.Equal(t, "UNRESERVED", status.Status)
+	assert.Equal(t, []string{"bob"}, status.UnreservedUsers)
+}
+
+func TestBuildGPUStatusProcessOwners(t *testing.T) {
+	engine := NewAllocationEngine(nil, &types.Config{MemoryThreshold: 1024, ProcessOwners: map[string]string{"svc-vllm": "alice"}})
+	usage := &types.GPUUsage{
+		MemoryMB:  8192,
+		Processes: []types.GPUProcessInfo{{PID: 1, ProcessName: "vllm", User: "svc-vllm", MemoryMB: 8192}},
+		Users:     map[string]bool{"svc-vllm": true},
+	}
+
+	// Service accounts are listed as is, with the owner responsible for them
+	// alongside
+	status := engine.buildGPUStatus(0, &types.GPUState{}, usage)
+	assert.Equal(t, "UNRESERVED", status.Status)
+	assert.Equal(t, []string{"svc-vllm"}, status.UnreservedUsers)
+	assert.Equal(t, map[string]string{"svc-vllm": "alice"}, status.UnreservedOwners)
+}
+
+func TestBuildGPUStatusProcesses(t *testing.T) {
+	engine := NewAllocationEngine(nil, &types.Config{MemoryThreshold: 1024})
+	processes := []types.GPUProcessInfo{
+		{PID: 1234, ProcessName: "python", User: "alice", MemoryMB: 8000},
+		{PID: 5678, ProcessName: "vllm", User: "alice", MemoryMB: 4000},
+	}
+	usage := &types.GPUUsage{MemoryMB: 12000, Processes: processes, Users: map[string]bool{"alice": true}}
+
+	// PIDs are kept whether or not the GPU is reserved
+	for _, state := range []*types.GPUState{
+		{User: "alice", Type: types.ReservationTypeRun},
+		{User: "alice", Type: types.ReservationTypeManual},
+		{},
+	} {
+		status := engine.buildGPUStatus(0, state, usage)
+		assert.Equal(t, []int{1234, 5678}, status.PIDs)
+		assert.Equal(t, processes, status.Processes)
+	}
+}
+
+func TestBuildGPUStatusUtilization(t *testing.T) {
+	engine := NewAllocationEngine(nil, &types.Config{MemoryThreshold: 1024})
+	reserved := &types.GPUState{User: "alice", Type: types.ReservationTypeRun}
+	busy, idle := 85, 0
+
+	tests := []struct {
+		name       string
+		usage      *types.GPUUsage
+		validation string
+	}{
+		{
+			"computing",
+			&types.GPUUsage{MemoryMB: 8452, Utilization: &busy, Processes: []types.GPUProcessInfo{{PID: 1}, {PID: 2}}},
+			"[validated: 8452MB, 85% util, 2 processes]",
+		},
+		{
+			"idle without process details",
+			&types.GPUUsage{MemoryMB: 8452, Utilization: &idle},
+			"[validated: 8452MB used, 0% util]",
+		},
+		{
+			"utilization not reported",
+			&types.GPUUsage{MemoryMB: 8452, Processes: []types.GPUProcessInfo{{PID: 1}}},
+			"[validated: 8452MB, 1 processes]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := engine.buildGPUStatus(0, reserved, tt.usage)
+			assert.Equal(t, tt.validation, status.ValidationInfo)
+			assert.Equal(t, tt.usage.Utilization, status.Utilization)
+		})
+	}
+}
+
+func TestReleaseAllGPUs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := &types.Config{
+		RedisHost:       "localhost",
+		RedisPort:       6379,
+		RedisDB:         15,
+		MemoryThreshold: types.MemoryThresholdMB,
+	}
+	redisClient := redis_client.NewClient(config)
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			t.Logf("Warning: failed to close Redis client: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	if err := redisClient.Ping(ctx); err != nil {
+		t.Skip("Skipping test: Redis not available")
+	}
+	require.NoError(t, redisClient.SetGPUCount(ctx, 4))
+
+	now := time.Now()
+	states := []*types.GPUState{
+		{User: "testuser", StartTime: types.FlexibleTime{Time: now}, Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)}},
+		{User: "otheruser", StartTime: types.FlexibleTime{Time: now}, Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)}},
+		{User: "testuser", StartTime: types.FlexibleTime{Time: now}, Type: types.ReservationTypeRun, LastHeartbeat: types.FlexibleTime{Time: now}},
+		{},
+	}
+	for gpuID, state := range states {
+		require.NoError(t, redisClient.SetGPUState(ctx, gpuID, state))
+	}
+
+	engine := NewAllocationEngine(redisClient, config)
+	released, err := engine.ReleaseAllGPUs(ctx, "testuser")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, released)
+
+	// Other users' reservations are left alone
+	state, err := redisClient.GetGPUState(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "otheruser", state.User)
+
+	for _, gpuID := range []int{0, 2} {
+		state, err := redisClient.GetGPUState(ctx, gpuID)
+		require.NoError(t, err)
+		assert.Empty(t, state.User)
+	}
+
+	released, err = engine.ReleaseAllGPUs(ctx, "testuser")
+	require.NoError(t, err)
+	assert.Empty(t, released)
+}
@@ -10,7 +10,9 @@ import (
 )
 
 // AMDProvider implements the GPUProvider interface for AMD GPUs using amd-smi
-type AMDProvider struct{}
+type AMDProvider struct {
+	smiPath string // Configured amd-smi binary (empty = look up on PATH)
+}
 
 // unmarshalAMDSmiOutput handles both ROCm 7.x ({"gpu_data": [...]}) and
 // ROCm 6.x (bare array) JSON output formats from amd-smi.
@@ -30,9 +32,15 @@ func unmarshalAMDSmiOutput(data []byte) ([]map[string]interface{}, error) {
 	return result, nil
 }
 
-// NewAMDProvider creates a new AMD GPU provider
-func NewAMDProvider() *AMDProvider {
-	return &AMDProvider{}
+// NewAMDProvider creates a new AMD GPU provider. smiPath is the amd-smi
+// binary to run, or empty to look it up on PATH.
+func NewAMDProvider(smiPath string) *AMDProvider {
+	return &AMDProvider{smiPath: smiPath}
+}
+
+// command builds an amd-smi command
+func (a *AMDProvider) command(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	return smiCommand(ctx, a.smiPath, amdSMI, args...)
 }
 
 // Name returns the name of the provider
@@ -42,9 +50,11 @@ func (a *AMDProvider) Name() string {
 
 // IsAvailable checks if amd-smi is available on the system
 func (a *AMDProvider) IsAvailable() bool {
-	cmd := exec.Command("amd-smi", "--help")
-	err := cmd.Run()
-	return err == nil
+	cmd, err := a.command(context.Background(), "--help")
+	if err != nil {
+		return false
+	}
+	return cmd.Run() == nil
 }
 
 // DetectGPUUsage queries AMD GPU usage via amd-smi
@@ -64,14 +74,15 @@ func (a *AMDProvider) DetectGPUUsage(ctx context.Context) (map[int]*types.GPUUsa
 	}
 
 	// Combine memory usage and process information
-	for gpuID, memoryMB := range memoryUsage {
+	for gpuID, memory := range memoryUsage {
 		gpuUsage := &types.GPUUsage{
-			GPUID:     gpuID,
-			MemoryMB:  memoryMB,
-			Processes: []types.GPUProcessInfo{},
-			Users:     make(map[string]bool),
-			Provider:  "AMD",
-			Model:     "", // Leave blank for AMD GPUs
+			GPUID:         gpuID,
+			MemoryMB:      memory.usedMB,
+			TotalMemoryMB: memory.totalMB,
+			Processes:     []types.GPUProcessInfo{},
+			Users:         make(map[string]bool),
+			Provider:      "AMD",
+			Model:         "", // Leave blank for AMD GPUs
 		}
 
 		// Add processes for this GPU
@@ -90,7 +101,10 @@ func (a *AMDProvider) DetectGPUUsage(ctx context.Context) (map[int]*types.GPUUsa
 
 // GetGPUCount returns the number of AMD GPUs on the system
 func (a *AMDProvider) GetGPUCount(ctx context.Context) (int, error) {
-	cmd := exec.CommandContext(ctx, "amd-smi", "list", "--json")
+	cmd, err := a.command(ctx, "list", "--json")
+	if err != nil {
+		return 0, err
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		return 0, fmt.Errorf("amd-smi list failed: %v", err)
@@ -112,9 +126,18 @@ func (a *AMDProvider) GetGPUCount(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// amdGPUMemory is the memory of an AMD GPU reported by amd-smi
+type amdGPUMemory struct {
+	usedMB  int
+	totalMB int // 0 when amd-smi doesn't report it
+}
+
 // queryGPUMemory queries GPU memory usage via amd-smi
-func (a *AMDProvider) queryGPUMemory(ctx context.Context) (map[int]int, error) {
-	cmd := exec.CommandContext(ctx, "amd-smi", "metric", "-m", "--json")
+func (a *AMDProvider) queryGPUMemory(ctx context.Context) (map[int]amdGPUMemory, error) {
+	cmd, err := a.command(ctx, "metric", "-m", "--json")
+	if err != nil {
+		return nil, err
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("amd-smi metric failed: %v", err)
@@ -125,7 +148,13 @@ func (a *AMDProvider) queryGPUMemory(ctx context.Context) (map[int]int, error) {
 		return nil, fmt.Errorf("failed to parse amd-smi metric output: %v", err)
 	}
 
-	memory := make(map[int]int)
+	return parseAMDMemoryMetrics(metricData), nil
+}
+
+// parseAMDMemoryMetrics extracts each GPU's used and total VRAM from the
+// output of 'amd-smi metric -m --json'. GPUs without used VRAM are skipped.
+func parseAMDMemoryMetrics(metricData []map[string]interface{}) map[int]amdGPUMemory {
+	memory := make(map[int]amdGPUMemory)
 
 	// Parse GPU memory usage from JSON output
 	for _, gpu := range metricData {
@@ -133,26 +162,107 @@ func (a *AMDProvider) queryGPUMemory(ctx context.Context) (map[int]int, error) {
 			gpuID := int(gpuIDVal)
 
 			if memUsage, ok := gpu["mem_usage"].(map[string]interface{}); ok {
-				if usedVram, ok := memUsage["used_vram"].(map[string]interface{}); ok {
-					if memValue, ok := usedVram["value"].(float64); ok {
-						// Convert to MB if needed
-						memoryMB := int(memValue)
-						if unit, ok := usedVram["unit"].(string); ok && unit == "GB" {
-							memoryMB = int(memValue * 1024)
-						}
-						memory[gpuID] = memoryMB
-					}
+				if usedMB, ok := amdMemoryMB(memUsage["used_vram"]); ok {
+					totalMB, _ := amdMemoryMB(memUsage["total_vram"])
+					memory[gpuID] = amdGPUMemory{usedMB: usedMB, totalMB: totalMB}
 				}
 			}
 		}
 	}
 
-	return memory, nil
+	return memory
+}
+
+// amdMemoryMB converts an amd-smi memory value, such as
+// {"value": 192, "unit": "GB"}, to MB
+func amdMemoryMB(v interface{}) (int, bool) {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	memValue, ok := value["value"].(float64)
+	if !ok {
+		return 0, false
+	}
+	// Convert to MB if needed
+	if unit, ok := value["unit"].(string); ok && unit == "GB" {
+		return int(memValue * 1024), true
+	}
+	return int(memValue), true
+}
+
+// DetectGPUTelemetry queries the fan speed and the graphics and memory
+// clocks of each GPU via amd-smi
+func (a *AMDProvider) DetectGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error) {
+	cmd, err := a.command(ctx, "metric", "--fan", "--clock", "--json")
+	if err != nil {
+		return nil, err
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("amd-smi metric failed: %v", err)
+	}
+
+	metricData, err := unmarshalAMDSmiOutput(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse amd-smi metric output: %v", err)
+	}
+
+	return parseAMDTelemetryMetrics(metricData), nil
+}
+
+// parseAMDTelemetryMetrics extracts each GPU's fan usage and its first
+// graphics and memory clocks from the output of
+// 'amd-smi metric --fan --clock --json'. Values amd-smi reports as "N/A",
+// such as the fan of passively cooled GPUs, are left out.
+func parseAMDTelemetryMetrics(metricData []map[string]interface{}) map[int]*types.GPUTelemetry {
+	telemetry := make(map[int]*types.GPUTelemetry)
+
+	for _, gpu := range metricData {
+		gpuIDVal, ok := gpu["gpu"].(float64)
+		if !ok {
+			continue
+		}
+
+		gpuTelemetry := &types.GPUTelemetry{}
+		if fan, ok := gpu["fan"].(map[string]interface{}); ok {
+			gpuTelemetry.FanSpeed = amdMetricValue(fan["usage"])
+		}
+		if clock, ok := gpu["clock"].(map[string]interface{}); ok {
+			if gfx, ok := clock["gfx_0"].(map[string]interface{}); ok {
+				gpuTelemetry.SMClockMHz = amdMetricValue(gfx["clk"])
+			}
+			if mem, ok := clock["mem_0"].(map[string]interface{}); ok {
+				gpuTelemetry.MemClockMHz = amdMetricValue(mem["clk"])
+			}
+		}
+		telemetry[int(gpuIDVal)] = gpuTelemetry
+	}
+
+	return telemetry
+}
+
+// amdMetricValue returns the number of an amd-smi value such as
+// {"value": 1800, "unit": "MHz"}, or nil if it isn't a number
+func amdMetricValue(v interface{}) *int {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	number, ok := value["value"].(float64)
+	if !ok {
+		return nil
+	}
+	result := int(number)
+	return &result
 }
 
 // queryGPUProcesses queries GPU processes via amd-smi
 func (a *AMDProvider) queryGPUProcesses(ctx context.Context) (map[int][]types.GPUProcessInfo, error) {
-	cmd := exec.CommandContext(ctx, "amd-smi", "process", "--json")
+	cmd, err := a.command(ctx, "process", "--json")
+	if err != nil {
+		return nil, err
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		// amd-smi might return non-zero when no processes are found
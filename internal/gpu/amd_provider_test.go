@@ -2,6 +2,10 @@ package gpu
 
 import (
 	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUnmarshalAMDSmiOutput(t *testing.T) {
@@ -72,3 +76,38 @@ func TestUnmarshalAMDSmiOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAMDMemoryMetrics(t *testing.T) {
+	metricData, err := unmarshalAMDSmiOutput([]byte(`[
+		{"gpu": 0, "mem_usage": {"used_vram": {"value": 2048, "unit": "MB"}, "total_vram": {"value": 196592, "unit": "MB"}}},
+		{"gpu": 1, "mem_usage": {"used_vram": {"value": 1.5, "unit": "GB"}, "total_vram": {"value": 64, "unit": "GB"}}},
+		{"gpu": 2, "mem_usage": {"used_vram": {"value": 10, "unit": "MB"}}},
+		{"gpu": 3, "mem_usage": {}}
+	]`))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[int]amdGPUMemory{
+		0: {usedMB: 2048, totalMB: 196592},
+		1: {usedMB: 1536, totalMB: 65536},
+		2: {usedMB: 10},
+	}, parseAMDMemoryMetrics(metricData))
+}
+
+func TestParseAMDTelemetryMetrics(t *testing.T) {
+	metricData, err := unmarshalAMDSmiOutput([]byte(`{"gpu_data": [
+		{"gpu": 0, "fan": {"speed": 102, "max": 255, "rpm": 1500, "usage": {"value": 40, "unit": "%"}},
+		 "clock": {"gfx_0": {"clk": {"value": 2100, "unit": "MHz"}}, "mem_0": {"clk": {"value": 1300, "unit": "MHz"}}}},
+		{"gpu": 1, "fan": {"speed": "N/A", "max": "N/A", "rpm": "N/A", "usage": "N/A"},
+		 "clock": {"gfx_0": {"clk": {"value": 132, "unit": "MHz"}}, "mem_0": {"clk": "N/A"}}},
+		{"gpu": 2}
+	]}`))
+	require.NoError(t, err)
+
+	fan, gfx, mem := 40, 2100, 1300
+	gfx1 := 132
+	assert.Equal(t, map[int]*types.GPUTelemetry{
+		0: {FanSpeed: &fan, SMClockMHz: &gfx, MemClockMHz: &mem},
+		1: {SMClockMHz: &gfx1},
+		2: {},
+	}, parseAMDTelemetryMetrics(metricData))
+}
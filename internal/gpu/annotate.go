@@ -0,0 +1,68 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// AnnotateGPUs leaves an annotation from user on the reservations of the
+// given GPUs, replacing any previous one, or clears it if annotation is
+// empty. Every GPU must be reserved; annotations are dropped when the
+// reservation is released and never affect allocation.
+func (ae *AllocationEngine) AnnotateGPUs(ctx context.Context, gpuIDs []int, annotation, user string) error {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	// Check every GPU before changing any
+	states := make(map[int]*types.GPUState, len(gpuIDs))
+	for _, gpuID := range gpuIDs {
+		if gpuID < 0 || gpuID >= gpuCount {
+			return fmt.Errorf("GPU ID %d is out of range (0-%d)", gpuID, gpuCount-1)
+		}
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		if state.User == "" {
+			return fmt.Errorf("GPU %d is not reserved", gpuID)
+		}
+		states[gpuID] = state
+	}
+
+	now := time.Now()
+	for _, gpuID := range gpuIDs {
+		annotateState(states[gpuID], annotation, user, now)
+		if err := ae.client.SetGPUState(ctx, gpuID, states[gpuID]); err != nil {
+			return fmt.Errorf("failed to annotate GPU %d: %v", gpuID, err)
+		}
+	}
+	return nil
+}
+
+// annotateState sets or, if annotation is empty, clears the annotation of a
+// reservation
+func annotateState(state *types.GPUState, annotation, user string, now time.Time) {
+	if annotation == "" {
+		state.Annotation = ""
+		state.AnnotatedBy = ""
+		state.AnnotatedAt = types.FlexibleTime{}
+		return
+	}
+	state.Annotation = annotation
+	state.AnnotatedBy = user
+	state.AnnotatedAt = types.FlexibleTime{Time: now}
+}
@@ -0,0 +1,26 @@
+package gpu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateState(t *testing.T) {
+	now := time.Now()
+	state := &types.GPUState{User: "alice", Note: "training"}
+
+	annotateState(state, "contacted owner", "bob", now)
+	assert.Equal(t, "contacted owner", state.Annotation)
+	assert.Equal(t, "bob", state.AnnotatedBy)
+	assert.True(t, now.Equal(state.AnnotatedAt.Time))
+	assert.Equal(t, "training", state.Note)
+
+	annotateState(state, "", "bob", now)
+	assert.Empty(t, state.Annotation)
+	assert.Empty(t, state.AnnotatedBy)
+	assert.True(t, state.AnnotatedAt.IsZero())
+	assert.Equal(t, "alice", state.User)
+}
@@ -52,7 +52,25 @@ func (f *FakeProvider) GetGPUCount(ctx context.Context) (int, error) {
 	return f.gpuCount, nil
 }
 
+// CheckGPUHealth reports every fake GPU as healthy
+func (f *FakeProvider) CheckGPUHealth(ctx context.Context) (map[int]string, error) {
+	health := make(map[int]string, f.gpuCount)
+	for gpuID := range f.gpuCount {
+		health[gpuID] = ""
+	}
+	return health, nil
+}
+
 // SetGPUCount allows updating the GPU count (useful when loading from Redis)
 func (f *FakeProvider) SetGPUCount(count int) {
 	f.gpuCount = count
 }
+
+// DetectGPUTelemetry reports every fake GPU, without any telemetry values
+func (f *FakeProvider) DetectGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error) {
+	telemetry := make(map[int]*types.GPUTelemetry, f.gpuCount)
+	for gpuID := range f.gpuCount {
+		telemetry[gpuID] = &types.GPUTelemetry{}
+	}
+	return telemetry, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -94,6 +95,21 @@ func TestFakeProvider_SetGPUCount(t *testing.T) {
 	assert.Len(t, usage, 8)
 }
 
+func TestFakeProvider_CheckGPUHealth(t *testing.T) {
+	health, err := NewProviderManagerWithFake(2).CheckGPUHealth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{0: "", 1: ""}, health)
+
+	_, err = NewProviderManagerFromNames([]string{"amd"}, &types.Config{}).CheckGPUHealth(context.Background())
+	assert.ErrorIs(t, err, ErrHealthCheckUnsupported)
+}
+
+func TestFakeProvider_DetectGPUTelemetry(t *testing.T) {
+	telemetry, err := NewProviderManagerWithFake(2).DetectGPUTelemetry(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]*types.GPUTelemetry{0: {}, 1: {}}, telemetry)
+}
+
 func TestNewProviderManagerWithFake(t *testing.T) {
 	pm := NewProviderManagerWithFake(4)
 	require.NotNil(t, pm)
@@ -110,7 +126,7 @@ func TestNewProviderManagerWithFake(t *testing.T) {
 }
 
 func TestNewProviderManagerFromNames_Fake(t *testing.T) {
-	pm := NewProviderManagerFromNames([]string{"fake"})
+	pm := NewProviderManagerFromNames([]string{"fake"}, &types.Config{})
 	require.NotNil(t, pm)
 
 	// Should have exactly one provider (with default 0 GPUs)
@@ -2,8 +2,11 @@ package gpu
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/russellb/canhazgpu/internal/redis_client"
@@ -13,14 +16,33 @@ import (
 // consecutiveFailures tracks heartbeat failures to trigger reconnection
 const maxFailuresBeforeReconnect = 2
 
+// Failed heartbeats are retried with exponential backoff between these delays
+// instead of waiting for the next heartbeat interval
+const (
+	minHeartbeatRetryDelay = 1 * time.Second
+	maxHeartbeatRetryDelay = 30 * time.Second
+)
+
+// errReservationGone is returned by a keepalive heartbeat when a renewable
+// reservation has expired or been released, so retrying can't save it
+var errReservationGone = errors.New("renewable reservation has expired or was released")
+
 type HeartbeatManager struct {
 	client              *redis_client.Client
 	allocatedGPUs       []int
 	user                string
+	pid                 int
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	done                chan struct{}
 	consecutiveFailures int
+	lastSuccess         time.Time
+	timeout             time.Duration // The pool's heartbeat timeout, refreshed after each heartbeat
+	preempted           chan struct{}
+	preemptedOnce       sync.Once
+	lost                chan struct{}
+	lostOnce            sync.Once
+	keepalive           bool // Extends renewable manual reservations instead of heartbeating run reservations
 }
 
 func NewHeartbeatManager(client *redis_client.Client, allocatedGPUs []int, user string) *HeartbeatManager {
@@ -33,26 +55,49 @@ func NewHeartbeatManager(client *redis_client.Client, allocatedGPUs []int, user
 		ctx:           ctx,
 		cancel:        cancel,
 		done:          make(chan struct{}),
+		timeout:       types.HeartbeatTimeout,
+		preempted:     make(chan struct{}),
+		lost:          make(chan struct{}),
 	}
 }
 
+// NewKeepaliveManager creates a heartbeat manager that keeps renewable manual
+// reservations alive, extending their expiry on each heartbeat. Unlike a run
+// heartbeat, stopping it doesn't release the GPUs: the reservations lapse at
+// their current expiry.
+func NewKeepaliveManager(client *redis_client.Client, allocatedGPUs []int, user string) *HeartbeatManager {
+	hm := NewHeartbeatManager(client, allocatedGPUs, user)
+	hm.keepalive = true
+	return hm
+}
+
+// SetPID records the PID of the process holding the reservation, so that
+// cleanup can release the GPUs promptly if the process dies unnoticed
+func (hm *HeartbeatManager) SetPID(pid int) {
+	hm.pid = pid
+}
+
 // Start begins sending heartbeats for the allocated GPUs
 func (hm *HeartbeatManager) Start() error {
 	// Send initial heartbeat synchronously before starting background tasks
 	if err := hm.sendHeartbeat(); err != nil {
 		return fmt.Errorf("failed to send initial heartbeat: %w", err)
 	}
+	hm.refreshTimeout()
 
 	// Now start background tasks
 	go hm.heartbeatLoop()
 	return nil
 }
 
-// Stop stops the heartbeat and releases GPUs
+// Stop stops the heartbeat and releases GPUs. A keepalive leaves the
+// reservations to expire instead.
 func (hm *HeartbeatManager) Stop() {
 	hm.cancel()
 	<-hm.done
-	hm.releaseGPUs()
+	if !hm.keepalive {
+		hm.releaseGPUs()
+	}
 }
 
 // Wait blocks until the heartbeat manager is stopped
@@ -60,12 +105,46 @@ func (hm *HeartbeatManager) Wait() {
 	<-hm.done
 }
 
+// Preempted returns a channel that is closed when one of the allocated GPUs
+// has been taken over by a higher-priority reservation
+func (hm *HeartbeatManager) Preempted() <-chan struct{} {
+	return hm.preempted
+}
+
+// Lost returns a channel that is closed when heartbeats have failed for longer
+// than the heartbeat timeout, after which the reservation may have been
+// reclaimed
+func (hm *HeartbeatManager) Lost() <-chan struct{} {
+	return hm.lost
+}
+
+// Timeout returns the heartbeat timeout after which the reservation is
+// reported lost
+func (hm *HeartbeatManager) Timeout() time.Duration {
+	return hm.timeout
+}
+
+// refreshTimeout picks up changes to the pool's heartbeat-timeout setting, so
+// that the reservation is reported lost when cleanup would reclaim it. The
+// previous value is kept if the setting can't be read. A keepalive's timeout
+// comes from the reservations instead, see sendKeepalive.
+func (hm *HeartbeatManager) refreshTimeout() {
+	if hm.keepalive {
+		return
+	}
+	timeout, err := hm.client.GetHeartbeatTimeout(hm.ctx)
+	if err != nil {
+		return
+	}
+	hm.timeout = timeout
+}
+
 // heartbeatLoop sends periodic heartbeats with connection health checking
 func (hm *HeartbeatManager) heartbeatLoop() {
 	defer close(hm.done)
 
-	ticker := time.NewTicker(types.HeartbeatInterval)
-	defer ticker.Stop()
+	heartbeatTimer := time.NewTimer(heartbeatDelay(rand.Int64N))
+	defer heartbeatTimer.Stop()
 
 	// Health check runs more frequently than heartbeats to detect connection
 	// problems early. If we only checked at heartbeat time (60s), a dead
@@ -74,6 +153,9 @@ func (hm *HeartbeatManager) heartbeatLoop() {
 	healthTicker := time.NewTicker(types.HealthCheckInterval)
 	defer healthTicker.Stop()
 
+	// Retries of a failed heartbeat, nil when the last heartbeat succeeded
+	var retry <-chan time.Time
+
 	// Initial heartbeat already sent in Start(), so just loop
 	for {
 		select {
@@ -83,27 +165,84 @@ func (hm *HeartbeatManager) heartbeatLoop() {
 		case <-healthTicker.C:
 			hm.checkConnectionHealth()
 
-		case <-ticker.C:
-			if err := hm.sendHeartbeat(); err != nil {
-				hm.consecutiveFailures++
-				fmt.Fprintf(os.Stderr, "ERROR: Failed to send heartbeat (attempt %d): %v\n",
-					hm.consecutiveFailures, err)
+		case <-heartbeatTimer.C:
+			// A pending retry takes the place of the regular heartbeat
+			if retry == nil {
+				retry = hm.beat()
+			}
+			heartbeatTimer.Reset(heartbeatDelay(rand.Int64N))
 
-				// Try to recover by reconnecting if we've had multiple failures
-				if hm.consecutiveFailures >= maxFailuresBeforeReconnect {
-					hm.attemptReconnect()
-				}
+		case <-retry:
+			retry = hm.beat()
+		}
+	}
+}
 
-				fmt.Fprintf(os.Stderr, "GPU reservations may be at risk of expiring!\n")
-			} else {
-				if hm.consecutiveFailures > 0 {
-					fmt.Fprintf(os.Stderr, "Heartbeat recovered after %d failed attempts\n",
-						hm.consecutiveFailures)
-				}
-				hm.consecutiveFailures = 0
-			}
+// beat sends a heartbeat. If it fails, it reconnects to Redis when needed and
+// returns a channel that fires when the heartbeat should be retried, backing
+// off exponentially so that a Redis restart doesn't doom the reservation. It
+// returns nil once the heartbeat succeeds, or once failures have lasted longer
+// than the heartbeat timeout, at which point the reservation is reported lost.
+func (hm *HeartbeatManager) beat() <-chan time.Time {
+	err := hm.sendHeartbeat()
+	if err == nil {
+		if hm.consecutiveFailures > 0 {
+			fmt.Fprintf(os.Stderr, "Heartbeat recovered after %d failed attempts\n",
+				hm.consecutiveFailures)
 		}
+		hm.consecutiveFailures = 0
+		hm.refreshTimeout()
+		return nil
+	}
+
+	hm.consecutiveFailures++
+	fmt.Fprintf(os.Stderr, "ERROR: Failed to send heartbeat (attempt %d): %v\n",
+		hm.consecutiveFailures, err)
+
+	if errors.Is(err, errReservationGone) {
+		hm.lostOnce.Do(func() { close(hm.lost) })
+		return nil
+	}
+
+	// Cleanup reclaims the reservation once the heartbeat timeout has passed
+	// since the last successful heartbeat
+	if since := time.Since(hm.lastSuccess); since > hm.timeout {
+		fmt.Fprintf(os.Stderr, "ERROR: No heartbeat sent for %s, GPU reservations may have been released\n",
+			since.Round(time.Second))
+		hm.lostOnce.Do(func() { close(hm.lost) })
+		return nil
 	}
+
+	// Try to recover by reconnecting if we've had multiple failures
+	if hm.consecutiveFailures >= maxFailuresBeforeReconnect {
+		hm.attemptReconnect()
+	}
+
+	delay := heartbeatRetryDelay(hm.consecutiveFailures)
+	fmt.Fprintf(os.Stderr, "GPU reservations may be at risk of expiring! Retrying heartbeat in %s\n", delay)
+	return time.After(delay)
+}
+
+// heartbeatDelay returns how long to wait before the next regular heartbeat:
+// HeartbeatInterval plus or minus up to HeartbeatJitter, chosen with
+// randInt64N. Jobs started together, such as an array job, would otherwise
+// heartbeat in lockstep and hit Redis in bursts.
+func heartbeatDelay(randInt64N func(int64) int64) time.Duration {
+	jitter := time.Duration(randInt64N(int64(2*types.HeartbeatJitter)+1)) - types.HeartbeatJitter
+	return types.HeartbeatInterval + jitter
+}
+
+// heartbeatRetryDelay returns how long to wait before retrying after the given
+// number of consecutive heartbeat failures
+func heartbeatRetryDelay(failures int) time.Duration {
+	delay := minHeartbeatRetryDelay
+	for i := 1; i < failures && delay < maxHeartbeatRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxHeartbeatRetryDelay {
+		delay = maxHeartbeatRetryDelay
+	}
+	return delay
 }
 
 // checkConnectionHealth proactively verifies the Redis connection is alive.
@@ -117,7 +256,12 @@ func (hm *HeartbeatManager) checkConnectionHealth() {
 
 // attemptReconnect tries to re-establish the Redis connection.
 func (hm *HeartbeatManager) attemptReconnect() {
-	fmt.Fprintf(os.Stderr, "Attempting Redis reconnection...\n")
+	if hm.consecutiveFailures > 0 {
+		fmt.Fprintf(os.Stderr, "Attempting Redis reconnection (last heartbeat %s ago)...\n",
+			time.Since(hm.lastSuccess).Round(time.Second))
+	} else {
+		fmt.Fprintf(os.Stderr, "Attempting Redis reconnection...\n")
+	}
 	if err := hm.client.Reconnect(); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: Redis reconnection failed: %v\n", err)
 	} else {
@@ -127,6 +271,10 @@ func (hm *HeartbeatManager) attemptReconnect() {
 
 // sendHeartbeat updates the last_heartbeat timestamp for all allocated GPUs
 func (hm *HeartbeatManager) sendHeartbeat() error {
+	if hm.keepalive {
+		return hm.sendKeepalive()
+	}
+
 	now := time.Now()
 
 	for _, gpuID := range hm.allocatedGPUs {
@@ -138,10 +286,26 @@ func (hm *HeartbeatManager) sendHeartbeat() error {
 		// Only update if this is still our reservation
 		if state.User == hm.user && state.Type == types.ReservationTypeRun {
 			state.LastHeartbeat = types.FlexibleTime{Time: now}
+			if hm.pid > 0 {
+				state.PID = hm.pid
+			}
+			checkpoint := checkpointUsage(gpuID, state, now)
 			if err := hm.client.SetGPUState(hm.ctx, gpuID, state); err != nil {
 				return fmt.Errorf("failed to update heartbeat for GPU %d: %v", gpuID, err)
 			}
+			// The checkpoint is only recorded once the state says it was
+			// taken, so a failed heartbeat can't have it counted twice
+			if checkpoint != nil {
+				if err := hm.client.RecordUsageHistory(hm.ctx, checkpoint); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to record usage checkpoint for GPU %d: %v\n", gpuID, err)
+				}
+			}
 		} else if state.User != "" {
+			if state.PreemptedUser == hm.user {
+				// Our idle reservation was preempted by a higher-priority request
+				fmt.Fprintf(os.Stderr, "GPU %d reservation was preempted by %s\n", gpuID, state.User)
+				hm.preemptedOnce.Do(func() { close(hm.preempted) })
+			}
 			// GPU is reserved by someone else - this is expected, skip silently
 			continue
 		} else {
@@ -151,9 +315,67 @@ func (hm *HeartbeatManager) sendHeartbeat() error {
 		}
 	}
 
+	hm.lastSuccess = now
+	return nil
+}
+
+// checkpointUsage returns a checkpoint record of the usage of a run
+// reservation since its last checkpoint, or since it started, once that is
+// UsageCheckpointInterval or more ago, and marks the state as checkpointed.
+// It returns nil when no checkpoint is due.
+func checkpointUsage(gpuID int, state *types.GPUState, now time.Time) *types.UsageRecord {
+	if state.StartTime.IsZero() || now.Sub(state.UsageStart().ToTime()) < types.UsageCheckpointInterval {
+		return nil
+	}
+	record := types.NewUsageRecord(gpuID, state, now)
+	record.Checkpoint = true
+	record.ReservationStart = state.StartTime
+	state.LastCheckpoint = types.FlexibleTime{Time: now}
+	return record
+}
+
+// sendKeepalive extends each renewable reservation to its renew duration
+// from now. The timeout is the shortest renew duration, since that is when
+// the first reservation would lapse without a keepalive.
+func (hm *HeartbeatManager) sendKeepalive() error {
+	now := time.Now()
+	var timeout time.Duration
+
+	for _, gpuID := range hm.allocatedGPUs {
+		state, err := hm.client.GetGPUState(hm.ctx, gpuID)
+		if err != nil {
+			return fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+
+		if state.User != hm.user || !state.IsRenewable() {
+			return fmt.Errorf("GPU %d: %w", gpuID, errReservationGone)
+		}
+
+		renewKeepalive(state, now)
+		if err := hm.client.SetGPUState(hm.ctx, gpuID, state); err != nil {
+			return fmt.Errorf("failed to extend reservation for GPU %d: %v", gpuID, err)
+		}
+
+		renew := time.Duration(state.RenewDuration) * time.Second
+		if timeout == 0 || renew < timeout {
+			timeout = renew
+		}
+	}
+
+	hm.lastSuccess = now
+	hm.timeout = timeout
 	return nil
 }
 
+// renewKeepalive records a keepalive on a renewable reservation and pushes
+// its expiry out by the renew duration. The expiry reminder is re-armed, in
+// case keepalives stop before the new expiry.
+func renewKeepalive(state *types.GPUState, now time.Time) {
+	state.LastHeartbeat = types.FlexibleTime{Time: now}
+	state.ExpiryTime = types.FlexibleTime{Time: now.Add(time.Duration(state.RenewDuration) * time.Second)}
+	state.Reminded = false
+}
+
 // releaseGPUs releases all allocated GPUs when stopping
 func (hm *HeartbeatManager) releaseGPUs() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -170,15 +392,7 @@ func (hm *HeartbeatManager) releaseGPUs() {
 		// Only release if this is still our reservation
 		if state.User == hm.user && state.Type == types.ReservationTypeRun {
 			// Record usage history
-			duration := now.Sub(state.StartTime.ToTime()).Seconds()
-			usageRecord := &types.UsageRecord{
-				User:            state.User,
-				GPUID:           gpuID,
-				StartTime:       state.StartTime,
-				EndTime:         types.FlexibleTime{Time: now},
-				Duration:        duration,
-				ReservationType: state.Type,
-			}
+			usageRecord := types.NewUsageRecord(gpuID, state, now)
 
 			if err := hm.client.RecordUsageHistory(ctx, usageRecord); err != nil {
 				// Log error but don't fail the release
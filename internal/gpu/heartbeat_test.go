@@ -2,12 +2,15 @@ package gpu
 
 import (
 	"context"
+	"math/rand/v2"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/russellb/canhazgpu/internal/redis_client"
 	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHeartbeatManager_Structure(t *testing.T) {
@@ -356,3 +359,204 @@ func TestHeartbeatManager_ReservationLoss(t *testing.T) {
 		t.Error("❌ Heartbeat should have detected reservation loss but didn't return error")
 	}
 }
+
+func TestHeartbeatRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 1 * time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, heartbeatRetryDelay(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestHeartbeatDelay(t *testing.T) {
+	lowest := heartbeatDelay(func(n int64) int64 { return 0 })
+	highest := heartbeatDelay(func(n int64) int64 { return n - 1 })
+	assert.Equal(t, types.HeartbeatInterval-types.HeartbeatJitter, lowest)
+	assert.Equal(t, types.HeartbeatInterval+types.HeartbeatJitter, highest)
+
+	// Stays within the bounds, and well inside the heartbeat timeout
+	for i := 0; i < 100; i++ {
+		delay := heartbeatDelay(rand.Int64N)
+		assert.GreaterOrEqual(t, delay, lowest)
+		assert.LessOrEqual(t, delay, highest)
+	}
+	assert.Less(t, highest, types.HeartbeatTimeout/2)
+}
+
+func TestHeartbeatManager_BeatBackoff(t *testing.T) {
+	// Point the client at a port nothing listens on to simulate Redis being down
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	client := redis_client.NewClient(&types.Config{RedisHost: "127.0.0.1", RedisPort: port})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	manager := NewHeartbeatManager(client, []int{0}, "testuser")
+	manager.lastSuccess = time.Now()
+
+	// Failures are retried instead of waiting for the next heartbeat interval
+	assert.NotNil(t, manager.beat())
+	assert.Equal(t, 1, manager.consecutiveFailures)
+	assert.NotNil(t, manager.beat())
+	assert.Equal(t, 2, manager.consecutiveFailures)
+
+	select {
+	case <-manager.Lost():
+		t.Fatal("reservation reported lost before the heartbeat timeout")
+	default:
+	}
+
+	// Once the heartbeat timeout has passed, the reservation is reported lost
+	manager.lastSuccess = time.Now().Add(-types.HeartbeatTimeout - time.Second)
+	assert.Nil(t, manager.beat())
+	select {
+	case <-manager.Lost():
+	default:
+		t.Fatal("reservation not reported lost after the heartbeat timeout")
+	}
+
+	// Further failures don't close the channel twice
+	assert.Nil(t, manager.beat())
+}
+
+func TestRenewKeepalive(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	state := &types.GPUState{
+		User:          "testuser",
+		Type:          types.ReservationTypeManual,
+		StartTime:     types.FlexibleTime{Time: start},
+		ExpiryTime:    types.FlexibleTime{Time: start.Add(15 * time.Minute)},
+		RenewDuration: 900,
+		RemindBefore:  300,
+		Reminded:      true,
+	}
+	assert.True(t, state.IsRenewable())
+
+	now := time.Now()
+	renewKeepalive(state, now)
+	assert.Equal(t, now, state.LastHeartbeat.ToTime())
+	assert.Equal(t, now.Add(15*time.Minute), state.ExpiryTime.ToTime())
+	assert.Equal(t, start, state.StartTime.ToTime())
+	assert.False(t, state.Reminded)
+
+	// Run reservations and plain manual reservations aren't renewable
+	assert.False(t, (&types.GPUState{Type: types.ReservationTypeRun, RenewDuration: 900}).IsRenewable())
+	assert.False(t, (&types.GPUState{Type: types.ReservationTypeManual}).IsRenewable())
+}
+
+func TestCheckpointUsage(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-30 * time.Hour)
+	state := &types.GPUState{
+		User:      "testuser",
+		Type:      types.ReservationTypeRun,
+		StartTime: types.FlexibleTime{Time: start},
+	}
+
+	record := checkpointUsage(0, state, now)
+	require.NotNil(t, record)
+	assert.True(t, record.Checkpoint)
+	assert.Equal(t, start, record.StartTime.ToTime())
+	assert.Equal(t, start, record.ReservationStart.ToTime())
+	assert.InDelta(t, 30*3600, record.Duration, 1)
+	assert.Equal(t, now, state.LastCheckpoint.ToTime())
+
+	// The next checkpoint isn't due for another day
+	assert.Nil(t, checkpointUsage(0, state, now.Add(time.Hour)))
+
+	// The final record picks up where the last checkpoint left off
+	end := now.Add(time.Hour)
+	final := types.NewUsageRecord(0, state, end)
+	assert.False(t, final.Checkpoint)
+	assert.Equal(t, now, final.StartTime.ToTime())
+	assert.InDelta(t, 3600, final.Duration, 1)
+	assert.InDelta(t, 31*3600, final.ReservationDuration(), 1)
+
+	// Short reservations aren't checkpointed
+	short := &types.GPUState{Type: types.ReservationTypeRun, StartTime: types.FlexibleTime{Time: now.Add(-time.Hour)}}
+	assert.Nil(t, checkpointUsage(0, short, now))
+	assert.True(t, short.LastCheckpoint.IsZero())
+}
+
+func TestKeepaliveManager(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := &types.Config{
+		RedisHost: "localhost",
+		RedisPort: 6379,
+		RedisDB:   15,
+	}
+	client := redis_client.NewClient(config)
+
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer func() {
+		if err := client.ClearAllGPUStates(ctx); err != nil {
+			t.Logf("Warning: failed to clear GPU states in defer: %v", err)
+		}
+		if err := client.Close(); err != nil {
+			t.Logf("Warning: failed to close Redis client: %v", err)
+		}
+	}()
+	require.NoError(t, client.SetGPUCount(ctx, 2))
+
+	now := time.Now()
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{
+		User:          "testuser",
+		StartTime:     types.FlexibleTime{Time: now},
+		Type:          types.ReservationTypeManual,
+		ExpiryTime:    types.FlexibleTime{Time: now.Add(time.Minute)},
+		RenewDuration: 600,
+	}))
+
+	manager := NewKeepaliveManager(client, []int{0}, "testuser")
+	require.NoError(t, manager.Start())
+
+	// The expiry is pushed out to the renew duration and the timeout follows it
+	state, err := client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), state.ExpiryTime.ToTime(), 5*time.Second)
+	assert.Equal(t, 10*time.Minute, manager.Timeout())
+
+	// Stopping a keepalive leaves the reservation to expire
+	manager.Stop()
+	state, err = client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", state.User)
+
+	// Once the reservation is gone, the keepalive is lost without retrying
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{LastReleased: types.FlexibleTime{Time: time.Now()}}))
+	manager = NewKeepaliveManager(client, []int{0}, "testuser")
+	assert.Nil(t, manager.beat())
+	select {
+	case <-manager.Lost():
+	default:
+		t.Error("keepalive should be lost once the reservation is released")
+	}
+
+	// A plain manual reservation can't be kept alive
+	require.NoError(t, client.SetGPUState(ctx, 1, &types.GPUState{
+		User:       "testuser",
+		Type:       types.ReservationTypeManual,
+		ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)},
+	}))
+	assert.Error(t, NewKeepaliveManager(client, []int{1}, "testuser").Start())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -51,6 +52,41 @@ func DetectModelFromProcesses(processes []types.GPUProcessInfo) *ModelInfo {
 	return nil
 }
 
+// DetectModelFromCommand detects the model a command will run, e.g. the
+// command given to 'canhazgpu run'
+func DetectModelFromCommand(command []string) *ModelInfo {
+	return detectModelFromProcessName(strings.Join(command, " "))
+}
+
+// DefaultModelGPUHints maps model name patterns to the minimum number of GPUs
+// a model typically needs. Patterns are matched case-insensitively against
+// the model name.
+var DefaultModelGPUHints = map[string]int{
+	"-70b":          2,
+	"-72b":          2,
+	"-405b":         8,
+	"mixtral-8x22b": 4,
+}
+
+// MinGPUsForModel returns the minimum GPU count hinted for a model, along with
+// the matching pattern. When several patterns match, the longest (most
+// specific) one wins. It returns 0 if no pattern matches.
+func MinGPUsForModel(model string, hints map[string]int) (int, string) {
+	model = strings.ToLower(model)
+
+	minGPUs, matched := 0, ""
+	for pattern, count := range hints {
+		pattern = strings.ToLower(pattern)
+		if pattern == "" || !strings.Contains(model, pattern) {
+			continue
+		}
+		if len(pattern) > len(matched) || (len(pattern) == len(matched) && pattern < matched) {
+			minGPUs, matched = count, pattern
+		}
+	}
+	return minGPUs, matched
+}
+
 // detectModelFromProcessName parses a process name/command to extract model information
 func detectModelFromProcessName(processName string) *ModelInfo {
 	// Look for vLLM commands in various forms:
@@ -250,13 +286,41 @@ func parseVLLMCommand(command string) *ModelInfo {
 // "meta-llama/Llama-2-7b-chat-hf" -> "meta-llama"
 // "qwen/Qwen2-7B-Instruct" -> "qwen"
 // "deepseek-ai/deepseek-coder-6.7b-instruct" -> "deepseek-ai"
+// "Mistral/Mistral-7B-Instruct-v0.1" -> "mistralai"
 func extractProviderFromModel(model string) string {
 	if slashIndex := strings.Index(model, "/"); slashIndex != -1 {
-		return model[:slashIndex]
+		return normalizeProvider(model[:slashIndex])
 	}
 	return ""
 }
 
+// providerAliases maps lowercase provider names to the canonical provider
+// name used for display and provider icons
+var providerAliases = map[string]string{
+	"meta":        "meta-llama",
+	"facebook":    "meta-llama",
+	"llama":       "meta-llama",
+	"mistral":     "mistralai",
+	"mistral-ai":  "mistralai",
+	"deepseek":    "deepseek-ai",
+	"alibaba":     "qwen",
+	"red-hat-ai":  "redhatai",
+	"redhat":      "redhatai",
+	"neuralmagic": "redhatai",
+	"ibm":         "ibm-granite",
+	"granite":     "ibm-granite",
+}
+
+// normalizeProvider lowercases a provider name and resolves known aliases,
+// so that "Meta-Llama" and "facebook" both become "meta-llama"
+func normalizeProvider(provider string) string {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if canonical, ok := providerAliases[provider]; ok {
+		return canonical
+	}
+	return provider
+}
+
 // parseLMEvalCommand extracts model information from lm_eval commands
 // Examples:
 // - lm_eval --model vllm --model_args {"pretrained": "meta-llama/Meta-Llama-3-8B-Instruct", "gpu_memory_utilization": 0.8} --tasks gsm8k
@@ -429,27 +493,42 @@ func extractModelFromPath(path string) string {
 	return ""
 }
 
+// genericModelFlags are the flags parseGenericModelCommand reads the model
+// from: --model, and --model_name_or_path as used by HuggingFace Trainer
+// scripts
+var genericModelFlags = []string{"--model", "--model_name_or_path"}
+
 // parseGenericModelCommand extracts model information from any command with --model arguments
 // Examples:
 // - "python train.py --model openai/whisper-large-v3 --epochs 10"
 // - "some-inference-server --model=meta-llama/Llama-2-7b-chat-hf --port 8080"
 // - "custom-tool --batch-size 32 --model qwen/Qwen2-7B-Instruct --output ./results"
+// - "accelerate launch train.py --model_name_or_path meta-llama/Llama-3.1-8B"
+// - "torchrun --nproc_per_node 8 train.py --model_name_or_path=Qwen/Qwen2-7B"
 func parseGenericModelCommand(command string) *ModelInfo {
 	parts := strings.Fields(command)
 
+	// Distributed launchers take options of their own before the script, so
+	// only the script's arguments are searched
+	if scriptArgs := launcherScriptArgs(parts); scriptArgs != nil {
+		parts = scriptArgs
+	}
+
 	model := ""
 
-	// Check for --model flag anywhere in the command
-	for i := 0; i < len(parts); i++ {
-		// Handle --model value format
-		if parts[i] == "--model" && i+1 < len(parts) {
-			model = parts[i+1]
-			break
-		}
-		// Handle --model=value format
-		if strings.HasPrefix(parts[i], "--model=") {
-			model = strings.TrimPrefix(parts[i], "--model=")
-			break
+	// Check for a model flag anywhere in the command
+	for i := 0; i < len(parts) && model == ""; i++ {
+		for _, flag := range genericModelFlags {
+			// Handle --model value format
+			if parts[i] == flag && i+1 < len(parts) {
+				model = parts[i+1]
+				break
+			}
+			// Handle --model=value format
+			if strings.HasPrefix(parts[i], flag+"=") {
+				model = strings.TrimPrefix(parts[i], flag+"=")
+				break
+			}
 		}
 	}
 
@@ -465,3 +544,40 @@ func parseGenericModelCommand(command string) *ModelInfo {
 		Model:    truncateModelName(model),
 	}
 }
+
+// launcherScriptArgs returns the arguments given to the script started by a
+// distributed launcher, e.g. ["--model_name_or_path", "gpt2"] for
+// "accelerate launch --num_processes 2 train.py --model_name_or_path gpt2".
+// Launchers found anywhere in the command are handled, such as
+// "canhazgpu run -- torchrun ...". The script is the first .py file or the
+// module given with -m after the launcher. It returns nil if the command
+// doesn't use a launcher or its script can't be found.
+func launcherScriptArgs(parts []string) []string {
+	start := -1
+	for i, part := range parts {
+		switch {
+		case filepath.Base(part) == "torchrun":
+			start = i + 1
+		case part == "torch.distributed.run" || part == "torch.distributed.launch":
+			start = i + 1
+		case filepath.Base(part) == "accelerate" && i+1 < len(parts) && parts[i+1] == "launch":
+			start = i + 2
+		}
+		if start >= 0 {
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	for i := start; i < len(parts); i++ {
+		if (parts[i] == "-m" || parts[i] == "--module") && i+1 < len(parts) {
+			return parts[i+2:]
+		}
+		if strings.HasSuffix(parts[i], ".py") {
+			return parts[i+1:]
+		}
+	}
+	return nil
+}
@@ -266,6 +266,36 @@ func TestExtractProviderFromModel(t *testing.T) {
 			model:    "deepseek-ai/deepseek-coder-6.7b-instruct",
 			expected: "deepseek-ai",
 		},
+		{
+			name:     "Mixed case provider",
+			model:    "Meta-Llama/Llama-2-7b-chat-hf",
+			expected: "meta-llama",
+		},
+		{
+			name:     "Mixed case unknown provider",
+			model:    "TinyLlama/TinyLlama-1.1B-Chat-v1.0",
+			expected: "tinyllama",
+		},
+		{
+			name:     "Facebook alias",
+			model:    "facebook/opt-125m",
+			expected: "meta-llama",
+		},
+		{
+			name:     "Mistral alias",
+			model:    "mistral/Mistral-7B-Instruct-v0.1",
+			expected: "mistralai",
+		},
+		{
+			name:     "DeepSeek alias",
+			model:    "DeepSeek/deepseek-coder-6.7b-instruct",
+			expected: "deepseek-ai",
+		},
+		{
+			name:     "Red Hat alias",
+			model:    "RedHatAI/Llama-3.1-8B-Instruct-FP8",
+			expected: "redhatai",
+		},
 		{
 			name:     "Model without provider",
 			model:    "llama-2-7b",
@@ -415,6 +445,41 @@ func TestParseGenericModelCommand(t *testing.T) {
 			command:  "CUDA_VISIBLE_DEVICES=0,1 python -m some.module --config config.json --model=deepseek-ai/deepseek-coder-6.7b-instruct --verbose",
 			expected: &ModelInfo{Provider: "deepseek-ai", Model: "deepseek-ai/deepseek-coder-6.7b-instruct"},
 		},
+		{
+			name:     "HuggingFace Trainer script with --model_name_or_path",
+			command:  "python run_clm.py --model_name_or_path meta-llama/Llama-3.1-8B --output_dir ./out",
+			expected: &ModelInfo{Provider: "meta-llama", Model: "meta-llama/Llama-3.1-8B"},
+		},
+		{
+			name:     "--model_name_or_path=value format",
+			command:  "python run_clm.py --model_name_or_path=Qwen/Qwen2-7B",
+			expected: &ModelInfo{Provider: "qwen", Model: "Qwen/Qwen2-7B"},
+		},
+		{
+			name:     "accelerate launch with launcher options",
+			command:  "accelerate launch --num_processes 8 --mixed_precision bf16 train.py --model_name_or_path meta-llama/Llama-3.1-8B",
+			expected: &ModelInfo{Provider: "meta-llama", Model: "meta-llama/Llama-3.1-8B"},
+		},
+		{
+			name:     "accelerate launch of a module",
+			command:  "/opt/venv/bin/accelerate launch --config_file fsdp.yaml -m trainer.sft --model mistralai/Mistral-7B-v0.1",
+			expected: &ModelInfo{Provider: "mistralai", Model: "mistralai/Mistral-7B-v0.1"},
+		},
+		{
+			name:     "torchrun under canhazgpu run",
+			command:  "canhazgpu run --gpus 4 -- torchrun --nproc_per_node 4 --master-port 29500 train.py --model=google/gemma-2-9b",
+			expected: &ModelInfo{Provider: "google", Model: "google/gemma-2-9b"},
+		},
+		{
+			name:     "python -m torch.distributed.run",
+			command:  "python -m torch.distributed.run --nproc_per_node 2 finetune.py --model_name_or_path microsoft/phi-2",
+			expected: &ModelInfo{Provider: "microsoft", Model: "microsoft/phi-2"},
+		},
+		{
+			name:     "torchrun script without a model",
+			command:  "torchrun --nproc_per_node 8 pretrain.py --config big.yaml",
+			expected: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -632,3 +697,71 @@ func TestDetectModelFromParentProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectModelFromCommand(t *testing.T) {
+	info := DetectModelFromCommand([]string{"vllm", "serve", "meta-llama/Llama-3.1-70B-Instruct", "--tensor-parallel-size", "2"})
+	if assert.NotNil(t, info) {
+		assert.Equal(t, "meta-llama", info.Provider)
+		assert.Equal(t, "meta-llama/Llama-3.1-70B-Instruct", info.Model)
+	}
+
+	info = DetectModelFromCommand([]string{"accelerate", "launch", "train.py", "--model_name_or_path", "meta-llama/Llama-3.1-70B"})
+	if assert.NotNil(t, info) {
+		assert.Equal(t, "meta-llama/Llama-3.1-70B", info.Model)
+	}
+
+	assert.Nil(t, DetectModelFromCommand([]string{"python", "train.py"}))
+}
+
+func TestMinGPUsForModel(t *testing.T) {
+	tests := []struct {
+		name            string
+		model           string
+		hints           map[string]int
+		expectedGPUs    int
+		expectedPattern string
+	}{
+		{
+			name:            "Large model matches default hint",
+			model:           "meta-llama/Llama-3.1-70B-Instruct",
+			hints:           DefaultModelGPUHints,
+			expectedGPUs:    2,
+			expectedPattern: "-70b",
+		},
+		{
+			name:            "Very large model",
+			model:           "meta-llama/Llama-3.1-405B-Instruct-FP8",
+			hints:           DefaultModelGPUHints,
+			expectedGPUs:    8,
+			expectedPattern: "-405b",
+		},
+		{
+			name:         "Small model has no hint",
+			model:        "meta-llama/Llama-3.1-8B-Instruct",
+			hints:        DefaultModelGPUHints,
+			expectedGPUs: 0,
+		},
+		{
+			name:            "Most specific pattern wins",
+			model:           "meta-llama/Llama-3.1-70B-Instruct-FP8",
+			hints:           map[string]int{"-70b": 2, "-70b-instruct-fp8": 1},
+			expectedGPUs:    1,
+			expectedPattern: "-70b-instruct-fp8",
+		},
+		{
+			name:            "Case insensitive pattern",
+			model:           "Qwen/Qwen2.5-72B-Instruct",
+			hints:           map[string]int{"Qwen2.5-72B": 4},
+			expectedGPUs:    4,
+			expectedPattern: "qwen2.5-72b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gpus, pattern := MinGPUsForModel(tt.model, tt.hints)
+			assert.Equal(t, tt.expectedGPUs, gpus)
+			assert.Equal(t, tt.expectedPattern, pattern)
+		})
+	}
+}
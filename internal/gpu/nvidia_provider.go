@@ -12,11 +12,19 @@ import (
 )
 
 // NVIDIAProvider implements the GPUProvider interface for NVIDIA GPUs using nvidia-smi
-type NVIDIAProvider struct{}
+type NVIDIAProvider struct {
+	smiPath string // Configured nvidia-smi binary (empty = look up on PATH)
+}
+
+// NewNVIDIAProvider creates a new NVIDIA GPU provider. smiPath is the
+// nvidia-smi binary to run, or empty to look it up on PATH.
+func NewNVIDIAProvider(smiPath string) *NVIDIAProvider {
+	return &NVIDIAProvider{smiPath: smiPath}
+}
 
-// NewNVIDIAProvider creates a new NVIDIA GPU provider
-func NewNVIDIAProvider() *NVIDIAProvider {
-	return &NVIDIAProvider{}
+// command builds an nvidia-smi command
+func (n *NVIDIAProvider) command(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	return smiCommand(ctx, n.smiPath, nvidiaSMI, args...)
 }
 
 // Name returns the name of the provider
@@ -26,9 +34,11 @@ func (n *NVIDIAProvider) Name() string {
 
 // IsAvailable checks if nvidia-smi is available on the system
 func (n *NVIDIAProvider) IsAvailable() bool {
-	cmd := exec.Command("nvidia-smi", "--help")
-	err := cmd.Run()
-	return err == nil
+	cmd, err := n.command(context.Background(), "--help")
+	if err != nil {
+		return false
+	}
+	return cmd.Run() == nil
 }
 
 // DetectGPUUsage queries NVIDIA GPU usage via nvidia-smi.
@@ -52,12 +62,15 @@ func (n *NVIDIAProvider) DetectGPUUsage(ctx context.Context) (map[int]*types.GPU
 	usage := make(map[int]*types.GPUUsage)
 	for _, info := range gpuInfo {
 		gpuUsage := &types.GPUUsage{
-			GPUID:     info.index,
-			MemoryMB:  info.memoryMB,
-			Processes: []types.GPUProcessInfo{},
-			Users:     make(map[string]bool),
-			Provider:  "NVIDIA",
-			Model:     info.model,
+			GPUID:         info.index,
+			MemoryMB:      info.memoryMB,
+			Processes:     []types.GPUProcessInfo{},
+			Users:         make(map[string]bool),
+			Provider:      "NVIDIA",
+			Model:         info.model,
+			UUID:          info.uuid,
+			Utilization:   info.utilization,
+			TotalMemoryMB: info.totalMemoryMB,
 		}
 
 		if gpuProcesses, exists := processes[info.index]; exists {
@@ -75,7 +88,10 @@ func (n *NVIDIAProvider) DetectGPUUsage(ctx context.Context) (map[int]*types.GPU
 
 // GetGPUCount returns the number of NVIDIA GPUs on the system
 func (n *NVIDIAProvider) GetGPUCount(ctx context.Context) (int, error) {
-	cmd := exec.CommandContext(ctx, "nvidia-smi", "-L")
+	cmd, err := n.command(ctx, "-L")
+	if err != nil {
+		return 0, err
+	}
 	output, err := cmd.Output()
 	if err != nil {
 		return 0, fmt.Errorf("nvidia-smi -L failed: %v", err)
@@ -93,26 +109,63 @@ func (n *NVIDIAProvider) GetGPUCount(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// GetGPUUUIDs returns the UUID of each NVIDIA GPU, ordered by index
+func (n *NVIDIAProvider) GetGPUUUIDs(ctx context.Context) ([]string, error) {
+	gpuInfo, err := n.queryGPUInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query NVIDIA GPU info: %v", err)
+	}
+	return uuidsByIndex(gpuInfo)
+}
+
+// uuidsByIndex orders GPU UUIDs by index, checking that the indices run from
+// 0 without gaps
+func uuidsByIndex(gpuInfo []gpuInfoEntry) ([]string, error) {
+	uuids := make([]string, len(gpuInfo))
+	for _, info := range gpuInfo {
+		if info.index < 0 || info.index >= len(uuids) || uuids[info.index] != "" {
+			return nil, fmt.Errorf("unexpected GPU index %d", info.index)
+		}
+		if info.uuid == "" {
+			return nil, fmt.Errorf("no UUID reported for GPU %d", info.index)
+		}
+		uuids[info.index] = info.uuid
+	}
+	return uuids, nil
+}
+
 type gpuInfoEntry struct {
-	index    int
-	uuid     string
-	model    string
-	memoryMB int
+	index       int
+	uuid        string
+	model       string
+	memoryMB    int
+	utilization *int // nil when nvidia-smi reports it as unavailable
+
+	totalMemoryMB int // 0 when nvidia-smi doesn't report it
 }
 
-// queryGPUInfo queries GPU index, UUID, model name, and memory usage in a single nvidia-smi call.
+// queryGPUInfo queries GPU index, UUID, model name, memory usage,
+// utilization, and total memory in a single nvidia-smi call.
 func (n *NVIDIAProvider) queryGPUInfo(ctx context.Context) ([]gpuInfoEntry, error) {
-	cmd := exec.CommandContext(ctx, "nvidia-smi",
-		"--query-gpu=index,gpu_uuid,name,memory.used",
+	cmd, err := n.command(ctx,
+		"--query-gpu=index,gpu_uuid,name,memory.used,utilization.gpu,memory.total",
 		"--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, err
+	}
 
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("nvidia-smi failed: %v", err)
 	}
 
+	return parseGPUInfoOutput(string(output))
+}
+
+// parseGPUInfoOutput parses the CSV output of the nvidia-smi GPU query
+func parseGPUInfoOutput(output string) ([]gpuInfoEntry, error) {
 	var entries []gpuInfoEntry
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
@@ -139,22 +192,142 @@ func (n *NVIDIAProvider) queryGPUInfo(ctx context.Context) ([]gpuInfoEntry, erro
 			continue
 		}
 
+		// Utilization is "[N/A]" on GPUs that don't support it, such as
+		// MIG instances
+		var utilization *int
+		if len(fields) > 4 {
+			if percent, err := strconv.Atoi(strings.TrimSpace(fields[4])); err == nil {
+				utilization = &percent
+			}
+		}
+
+		var totalMemoryMB int
+		if len(fields) > 5 {
+			totalMemoryMB, _ = strconv.Atoi(strings.TrimSpace(fields[5]))
+		}
+
 		entries = append(entries, gpuInfoEntry{
-			index:    index,
-			uuid:     uuid,
-			model:    model,
-			memoryMB: memoryMB,
+			index:         index,
+			uuid:          uuid,
+			model:         model,
+			memoryMB:      memoryMB,
+			utilization:   utilization,
+			totalMemoryMB: totalMemoryMB,
 		})
 	}
 
 	return entries, scanner.Err()
 }
 
+// CheckGPUHealth reports GPUs with uncorrected ECC errors since the driver
+// was loaded or with memory pages waiting to be retired, both signs of
+// failing memory. A GPU that has fallen off the bus is missing from the
+// result.
+func (n *NVIDIAProvider) CheckGPUHealth(ctx context.Context) (map[int]string, error) {
+	cmd, err := n.command(ctx,
+		"--query-gpu=index,ecc.errors.uncorrected.volatile.total,retired_pages.pending",
+		"--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, err
+	}
+
+	// nvidia-smi fails when a GPU can't be queried, but still reports the
+	// others
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("nvidia-smi failed: %v", err)
+	}
+
+	return parseGPUHealthOutput(string(output)), nil
+}
+
+// parseGPUHealthOutput parses the CSV output of the nvidia-smi health query.
+// Values that aren't supported, such as ECC counts on GPUs without ECC
+// memory, are reported as "[N/A]" and ignored.
+func parseGPUHealthOutput(output string) map[int]string {
+	health := make(map[int]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ", ")
+		if len(fields) < 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+
+		var problems []string
+		if errors, err := strconv.Atoi(strings.TrimSpace(fields[1])); err == nil && errors > 0 {
+			problems = append(problems, fmt.Sprintf("%d uncorrected ECC error(s)", errors))
+		}
+		if strings.EqualFold(strings.TrimSpace(fields[2]), "Yes") {
+			problems = append(problems, "memory page retirement pending")
+		}
+		health[index] = strings.Join(problems, ", ")
+	}
+	return health
+}
+
+// DetectGPUTelemetry queries the fan speed and the SM and memory clocks of
+// each GPU
+func (n *NVIDIAProvider) DetectGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error) {
+	cmd, err := n.command(ctx,
+		"--query-gpu=index,fan.speed,clocks.sm,clocks.mem",
+		"--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %v", err)
+	}
+
+	return parseGPUTelemetryOutput(string(output)), nil
+}
+
+// parseGPUTelemetryOutput parses the CSV output of the nvidia-smi telemetry
+// query. Values that aren't supported, such as the fan speed of passively
+// cooled data center GPUs, are reported as "[N/A]" and left out.
+func parseGPUTelemetryOutput(output string) map[int]*types.GPUTelemetry {
+	telemetry := make(map[int]*types.GPUTelemetry)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ", ")
+		if len(fields) < 4 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+
+		telemetry[index] = &types.GPUTelemetry{
+			FanSpeed:    parseOptionalInt(fields[1]),
+			SMClockMHz:  parseOptionalInt(fields[2]),
+			MemClockMHz: parseOptionalInt(fields[3]),
+		}
+	}
+	return telemetry
+}
+
+// parseOptionalInt parses an nvidia-smi value, returning nil if it isn't a
+// number, e.g. "[N/A]"
+func parseOptionalInt(field string) *int {
+	value, err := strconv.Atoi(strings.TrimSpace(field))
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
 // queryGPUProcesses queries GPU processes via nvidia-smi, using a pre-built UUID-to-index map.
 func (n *NVIDIAProvider) queryGPUProcesses(ctx context.Context, uuidMap map[string]int) (map[int][]types.GPUProcessInfo, error) {
-	cmd := exec.CommandContext(ctx, "nvidia-smi",
+	cmd, err := n.command(ctx,
 		"--query-compute-apps=pid,process_name,gpu_uuid,used_memory",
 		"--format=csv,noheader")
+	if err != nil {
+		return nil, err
+	}
 
 	output, err := cmd.Output()
 	if err != nil {
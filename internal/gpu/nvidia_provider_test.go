@@ -0,0 +1,158 @@
+package gpu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDsByIndex(t *testing.T) {
+	uuids, err := uuidsByIndex([]gpuInfoEntry{
+		{index: 1, uuid: "GPU-bbbb"},
+		{index: 0, uuid: "GPU-aaaa"},
+		{index: 2, uuid: "GPU-cccc"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GPU-aaaa", "GPU-bbbb", "GPU-cccc"}, uuids)
+
+	_, err = uuidsByIndex([]gpuInfoEntry{{index: 0, uuid: "GPU-aaaa"}, {index: 2, uuid: "GPU-cccc"}})
+	assert.ErrorContains(t, err, "unexpected GPU index 2")
+
+	_, err = uuidsByIndex([]gpuInfoEntry{{index: 0, uuid: "GPU-aaaa"}, {index: 0, uuid: "GPU-bbbb"}})
+	assert.ErrorContains(t, err, "unexpected GPU index 0")
+
+	_, err = uuidsByIndex([]gpuInfoEntry{{index: 0}})
+	assert.ErrorContains(t, err, "no UUID reported for GPU 0")
+}
+
+func TestParseGPUInfoOutput(t *testing.T) {
+	output := "0, GPU-aaaa, NVIDIA H100 80GB HBM3, 8452, 85, 81559\n" +
+		"1, GPU-bbbb, NVIDIA H100 80GB HBM3, 3, 0, 81559\n" +
+		"2, GPU-cccc, NVIDIA A100-SXM4-40GB MIG 3g.20gb, 1024, [N/A]\n" +
+		"\n" +
+		"bad line\n"
+
+	entries, err := parseGPUInfoOutput(output)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, 0, entries[0].index)
+	assert.Equal(t, "GPU-aaaa", entries[0].uuid)
+	assert.Equal(t, "H100 80GB HBM3", entries[0].model)
+	assert.Equal(t, 8452, entries[0].memoryMB)
+	require.NotNil(t, entries[0].utilization)
+	assert.Equal(t, 85, *entries[0].utilization)
+	assert.Equal(t, 81559, entries[0].totalMemoryMB)
+
+	require.NotNil(t, entries[1].utilization)
+	assert.Equal(t, 0, *entries[1].utilization)
+
+	assert.Nil(t, entries[2].utilization)
+	assert.Equal(t, 1024, entries[2].memoryMB)
+	assert.Equal(t, 0, entries[2].totalMemoryMB)
+}
+
+func TestParseGPUHealthOutput(t *testing.T) {
+	output := "0, 0, No\n" +
+		"1, 3, No\n" +
+		"2, [N/A], [N/A]\n" +
+		"3, 1, Yes\n" +
+		"\n" +
+		"Unable to determine the device handle for GPU 0000:4A:00.0: Unknown Error\n"
+
+	assert.Equal(t, map[int]string{
+		0: "",
+		1: "3 uncorrected ECC error(s)",
+		2: "",
+		3: "1 uncorrected ECC error(s), memory page retirement pending",
+	}, parseGPUHealthOutput(output))
+}
+
+func TestNVIDIAProviderCheckGPUHealth(t *testing.T) {
+	// GPU 1 has fallen off the bus: nvidia-smi fails but reports the others
+	smiPath := writeMockSMI(t, `echo "0, 0, No"; echo "2, 0, Yes"; exit 15
+`)
+	health, err := NewNVIDIAProvider(smiPath).CheckGPUHealth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{0: "", 2: "memory page retirement pending"}, health)
+
+	_, err = NewNVIDIAProvider(writeMockSMI(t, "exit 1\n")).CheckGPUHealth(context.Background())
+	assert.ErrorContains(t, err, "nvidia-smi failed")
+}
+
+func TestParseGPUTelemetryOutput(t *testing.T) {
+	output := "0, 45, 1980, 2619\n" +
+		"1, [N/A], 345, 1593\n" +
+		"\n" +
+		"garbage\n"
+
+	fan, sm, mem := 45, 1980, 2619
+	sm1, mem1 := 345, 1593
+	assert.Equal(t, map[int]*types.GPUTelemetry{
+		0: {FanSpeed: &fan, SMClockMHz: &sm, MemClockMHz: &mem},
+		1: {SMClockMHz: &sm1, MemClockMHz: &mem1},
+	}, parseGPUTelemetryOutput(output))
+}
+
+func TestNVIDIAProviderDetectGPUTelemetry(t *testing.T) {
+	smiPath := writeMockSMI(t, `[ "$1" = "--query-gpu=index,fan.speed,clocks.sm,clocks.mem" ] && echo "0, 30, 1410, 1593"
+`)
+	telemetry, err := NewNVIDIAProvider(smiPath).DetectGPUTelemetry(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, telemetry, 0)
+	assert.Equal(t, 30, *telemetry[0].FanSpeed)
+
+	_, err = NewNVIDIAProvider(writeMockSMI(t, "exit 1\n")).DetectGPUTelemetry(context.Background())
+	assert.ErrorContains(t, err, "nvidia-smi failed")
+}
+
+// writeMockSMI writes an executable script standing in for an SMI tool
+func writeMockSMI(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "mock-smi")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+	return path
+}
+
+func TestNVIDIAProviderSMIPath(t *testing.T) {
+	smiPath := writeMockSMI(t, `case "$1" in
+--query-gpu=*) echo "0, GPU-aaa, NVIDIA H100, 2048, 40"; echo "1, GPU-bbb, NVIDIA H100, 0, 0" ;;
+-L) echo "GPU 0: NVIDIA H100"; echo "GPU 1: NVIDIA H100" ;;
+esac
+`)
+	provider := NewNVIDIAProvider(smiPath)
+	ctx := context.Background()
+
+	assert.True(t, provider.IsAvailable())
+
+	count, err := provider.GetGPUCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	usage, err := provider.DetectGPUUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	assert.Equal(t, 2048, usage[0].MemoryMB)
+	assert.Equal(t, "H100", usage[1].Model)
+
+	// A configured path that doesn't exist is reported as such rather than
+	// falling back to PATH
+	missing := NewNVIDIAProvider(filepath.Join(t.TempDir(), "nvidia-smi"))
+	assert.False(t, missing.IsAvailable())
+	_, err = missing.DetectGPUUsage(ctx)
+	assert.ErrorContains(t, err, "does not exist")
+}
+
+func TestValidateSMIPaths(t *testing.T) {
+	smiPath := writeMockSMI(t, "exit 0\n")
+	dir := t.TempDir()
+
+	assert.NoError(t, ValidateSMIPaths(&types.Config{}))
+	assert.NoError(t, ValidateSMIPaths(&types.Config{NvidiaSMIPath: smiPath, AMDSMIPath: smiPath}))
+	assert.ErrorContains(t, ValidateSMIPaths(&types.Config{AMDSMIPath: filepath.Join(dir, "amd-smi")}), "configured amd-smi path")
+	assert.ErrorContains(t, ValidateSMIPaths(&types.Config{NvidiaSMIPath: dir}), "is a directory")
+}
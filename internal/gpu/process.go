@@ -0,0 +1,141 @@
+package gpu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// procRoot is where the proc filesystem is mounted
+var procRoot = "/proc"
+
+// ProcessStatus describes what /proc says about a reserving process
+type ProcessStatus int
+
+const (
+	ProcessUnknown ProcessStatus = iota // /proc is unavailable, can't tell
+	ProcessAlive                        // Process exists and is not a zombie
+	ProcessZombie                       // Process has exited but hasn't been reaped
+	ProcessGone                         // No such process
+)
+
+// GetProcessStatus inspects /proc/<pid>/stat to determine whether a process
+// is alive, a zombie, or gone
+func GetProcessStatus(pid int) ProcessStatus {
+	if pid <= 0 {
+		return ProcessUnknown
+	}
+
+	content, err := os.ReadFile(filepath.Join(procRoot, fmt.Sprintf("%d", pid), "stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Only trust a missing entry if /proc itself is there
+			if _, err := os.Stat(filepath.Join(procRoot, "self")); err == nil {
+				return ProcessGone
+			}
+		}
+		return ProcessUnknown
+	}
+
+	state, err := parseProcessState(string(content))
+	if err != nil {
+		return ProcessUnknown
+	}
+
+	switch state {
+	case 'Z', 'X', 'x':
+		return ProcessZombie
+	default:
+		return ProcessAlive
+	}
+}
+
+// parseProcessState extracts the state character from the contents of
+// /proc/<pid>/stat. The command name is in parentheses and may itself contain
+// spaces or parentheses, so the state is found after the last ')'.
+func parseProcessState(stat string) (byte, error) {
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, fmt.Errorf("invalid stat file format")
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) == 0 || len(fields[0]) != 1 {
+		return 0, fmt.Errorf("invalid stat file format")
+	}
+
+	return fields[0][0], nil
+}
+
+// IsZombieProcess returns true if the process has exited but not been reaped
+func IsZombieProcess(pid int) bool {
+	return GetProcessStatus(pid) == ProcessZombie
+}
+
+// deadRunProcess checks whether the process holding a run reservation has
+// died without its supervisor releasing the GPU. A zombie is released right
+// away. A missing process is only trusted once heartbeats have also stopped,
+// since the PID may belong to a different PID namespace (e.g. a container).
+func deadRunProcess(state *types.GPUState, now time.Time) (bool, string) {
+	if state.Type != types.ReservationTypeRun || state.PID <= 0 {
+		return false, ""
+	}
+
+	switch GetProcessStatus(state.PID) {
+	case ProcessZombie:
+		return true, "defunct process"
+	case ProcessGone:
+		lastHeartbeat := state.LastHeartbeat.ToTime()
+		if !lastHeartbeat.IsZero() && now.Sub(lastHeartbeat) > 2*types.HeartbeatInterval {
+			return true, "process exited"
+		}
+	}
+
+	return false, ""
+}
+
+// HostBootID returns the kernel's boot ID, which changes every time the host
+// boots
+func HostBootID() (string, error) {
+	content, err := os.ReadFile(filepath.Join(procRoot, "sys", "kernel", "random", "boot_id"))
+	if err != nil {
+		return "", err
+	}
+	bootID := strings.TrimSpace(string(content))
+	if bootID == "" {
+		return "", fmt.Errorf("empty boot ID")
+	}
+	return bootID, nil
+}
+
+// HostBootTime returns when the host booted, from the btime line of /proc/stat
+func HostBootTime() (time.Time, error) {
+	content, err := os.ReadFile(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			seconds, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime: %v", err)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in stat file")
+}
+
+// runReservationBeforeBoot checks whether a run reservation was made before
+// the host last booted, in which case its process can't still be running
+func runReservationBeforeBoot(state *types.GPUState, bootTime time.Time) bool {
+	return state.Type == types.ReservationTypeRun && !bootTime.IsZero() &&
+		state.StartTime.ToTime().Before(bootTime)
+}
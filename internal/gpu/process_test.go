@@ -0,0 +1,168 @@
+package gpu
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcessState(t *testing.T) {
+	tests := []struct {
+		name     string
+		stat     string
+		expected byte
+		wantErr  bool
+	}{
+		{"running", "1234 (python) R 1 1234 1234 0 -1", 'R', false},
+		{"sleeping", "1234 (vllm) S 1 1234 1234 0 -1", 'S', false},
+		{"zombie", "1234 (python) Z 1 1234 1234 0 -1", 'Z', false},
+		{"name with spaces and parens", "1234 (my (odd) cmd) Z 1 1234", 'Z', false},
+		{"no command name", "1234 python Z", 0, true},
+		{"truncated", "1234 (python)", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := parseProcessState(tt.stat)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, state)
+		})
+	}
+}
+
+// fakeProc points procRoot at a temporary directory with the given stat
+// files, keyed by PID
+func fakeProc(t *testing.T, stats map[int]string) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "self"), 0755))
+	for pid, stat := range stats {
+		pidDir := filepath.Join(dir, strconv.Itoa(pid))
+		require.NoError(t, os.Mkdir(pidDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(pidDir, "stat"), []byte(stat), 0644))
+	}
+
+	original := procRoot
+	procRoot = dir
+	t.Cleanup(func() { procRoot = original })
+}
+
+func TestGetProcessStatus(t *testing.T) {
+	fakeProc(t, map[int]string{
+		100: "100 (python) S 1 100 100 0 -1",
+		200: "200 (python) Z 1 200 200 0 -1",
+	})
+
+	assert.Equal(t, ProcessAlive, GetProcessStatus(100))
+	assert.Equal(t, ProcessZombie, GetProcessStatus(200))
+	assert.Equal(t, ProcessGone, GetProcessStatus(300))
+	assert.Equal(t, ProcessUnknown, GetProcessStatus(0))
+
+	// Without /proc nothing can be said about the process
+	procRoot = filepath.Join(t.TempDir(), "missing")
+	assert.Equal(t, ProcessUnknown, GetProcessStatus(300))
+}
+
+func TestGetProcessStatusRealZombie(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires /proc")
+	}
+
+	// Start a child and don't wait for it, so it stays defunct after exiting
+	cmd := exec.Command("true")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for GetProcessStatus(cmd.Process.Pid) != ProcessZombie {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d never became a zombie", cmd.Process.Pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, IsZombieProcess(cmd.Process.Pid))
+	assert.Equal(t, ProcessAlive, GetProcessStatus(os.Getpid()))
+}
+
+func TestDeadRunProcess(t *testing.T) {
+	fakeProc(t, map[int]string{
+		100: "100 (python) S 1 100 100 0 -1",
+		200: "200 (python) Z 1 200 200 0 -1",
+	})
+
+	now := time.Now()
+	fresh := types.FlexibleTime{Time: now.Add(-10 * time.Second)}
+	missed := types.FlexibleTime{Time: now.Add(-3 * types.HeartbeatInterval)}
+
+	tests := []struct {
+		name     string
+		state    *types.GPUState
+		expected bool
+		reason   string
+	}{
+		{"alive", &types.GPUState{Type: types.ReservationTypeRun, PID: 100, LastHeartbeat: missed}, false, ""},
+		{"zombie", &types.GPUState{Type: types.ReservationTypeRun, PID: 200, LastHeartbeat: fresh}, true, "defunct process"},
+		{"gone with missed heartbeats", &types.GPUState{Type: types.ReservationTypeRun, PID: 300, LastHeartbeat: missed}, true, "process exited"},
+		{"gone with fresh heartbeat", &types.GPUState{Type: types.ReservationTypeRun, PID: 300, LastHeartbeat: fresh}, false, ""},
+		{"no PID recorded", &types.GPUState{Type: types.ReservationTypeRun, LastHeartbeat: missed}, false, ""},
+		{"manual reservation", &types.GPUState{Type: types.ReservationTypeManual, PID: 200}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dead, reason := deadRunProcess(tt.state, now)
+			assert.Equal(t, tt.expected, dead)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestHostBootIDAndTime(t *testing.T) {
+	fakeProc(t, nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(procRoot, "sys", "kernel", "random"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "sys", "kernel", "random", "boot_id"),
+		[]byte("3f1c2a9e-6b4d-4e8f-9a7b-2c5d8e1f0a3b\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "stat"),
+		[]byte("cpu  100 0 50 1000 0 0 0 0 0 0\nintr 12345\nbtime 1751932800\nprocesses 42\n"), 0644))
+
+	bootID, err := HostBootID()
+	require.NoError(t, err)
+	assert.Equal(t, "3f1c2a9e-6b4d-4e8f-9a7b-2c5d8e1f0a3b", bootID)
+
+	bootTime, err := HostBootTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1751932800, 0), bootTime)
+
+	require.NoError(t, os.WriteFile(filepath.Join(procRoot, "stat"), []byte("cpu  100 0 50 1000\n"), 0644))
+	_, err = HostBootTime()
+	assert.ErrorContains(t, err, "no btime")
+
+	procRoot = filepath.Join(t.TempDir(), "missing")
+	_, err = HostBootID()
+	assert.Error(t, err)
+}
+
+func TestRunReservationBeforeBoot(t *testing.T) {
+	bootTime := time.Now().Add(-time.Hour)
+	before := types.FlexibleTime{Time: bootTime.Add(-time.Minute)}
+	after := types.FlexibleTime{Time: bootTime.Add(time.Minute)}
+
+	assert.True(t, runReservationBeforeBoot(&types.GPUState{Type: types.ReservationTypeRun, StartTime: before}, bootTime))
+	assert.False(t, runReservationBeforeBoot(&types.GPUState{Type: types.ReservationTypeRun, StartTime: after}, bootTime))
+	assert.False(t, runReservationBeforeBoot(&types.GPUState{Type: types.ReservationTypeManual, StartTime: before}, bootTime))
+	// No reboot detected
+	assert.False(t, runReservationBeforeBoot(&types.GPUState{Type: types.ReservationTypeRun, StartTime: before}, time.Time{}))
+}
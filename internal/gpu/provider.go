@@ -2,11 +2,20 @@ package gpu
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 
 	"github.com/russellb/canhazgpu/internal/types"
 )
 
+// Names of the SMI tools, looked up on PATH unless a path is configured
+const (
+	nvidiaSMI = "nvidia-smi"
+	amdSMI    = "amd-smi"
+)
+
 // GPUProvider defines the interface for GPU providers (NVIDIA, AMD, etc.)
 type GPUProvider interface {
 	// Name returns the name of the provider (e.g., "nvidia", "amd")
@@ -22,31 +31,62 @@ type GPUProvider interface {
 	GetGPUCount(ctx context.Context) (int, error)
 }
 
+// UUIDProvider is implemented by GPU providers that can identify GPUs by UUID
+type UUIDProvider interface {
+	// GetGPUUUIDs returns the UUID of each GPU, ordered by index
+	GetGPUUUIDs(ctx context.Context) ([]string, error)
+}
+
+// HealthProvider is implemented by GPU providers that can check whether GPUs
+// are healthy
+type HealthProvider interface {
+	// CheckGPUHealth returns, for each GPU found by index, what is wrong
+	// with it, or "" if it looks healthy
+	CheckGPUHealth(ctx context.Context) (map[int]string, error)
+}
+
+// ErrHealthCheckUnsupported is returned by health checks when the GPU
+// provider doesn't implement HealthProvider
+var ErrHealthCheckUnsupported = errors.New("GPU health checks are not supported by this GPU provider (only NVIDIA is)")
+
+// TelemetryProvider is implemented by GPU providers that can report fan
+// speeds and clocks. It is queried separately from DetectGPUUsage, and only
+// when asked for, to keep the common path fast.
+type TelemetryProvider interface {
+	// DetectGPUTelemetry returns the telemetry of each GPU found, by index
+	DetectGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error)
+}
+
+// ErrTelemetryUnsupported is returned when the GPU provider doesn't
+// implement TelemetryProvider
+var ErrTelemetryUnsupported = errors.New("GPU telemetry is not supported by this GPU provider")
+
 // ProviderManager manages multiple GPU providers
 type ProviderManager struct {
 	providers []GPUProvider
 }
 
-// NewProviderManager creates a new provider manager
-func NewProviderManager() *ProviderManager {
+// NewProviderManager creates a new provider manager, running the SMI tools
+// configured in config
+func NewProviderManager(config *types.Config) *ProviderManager {
 	return &ProviderManager{
 		providers: []GPUProvider{
-			NewNVIDIAProvider(),
-			NewAMDProvider(),
+			NewNVIDIAProvider(config.NvidiaSMIPath),
+			NewAMDProvider(config.AMDSMIPath),
 		},
 	}
 }
 
 // NewProviderManagerFromNames creates a provider manager with only the specified providers
-func NewProviderManagerFromNames(providerNames []string) *ProviderManager {
+func NewProviderManagerFromNames(providerNames []string, config *types.Config) *ProviderManager {
 	var providers []GPUProvider
 
 	for _, name := range providerNames {
 		switch name {
 		case "nvidia":
-			providers = append(providers, NewNVIDIAProvider())
+			providers = append(providers, NewNVIDIAProvider(config.NvidiaSMIPath))
 		case "amd":
-			providers = append(providers, NewAMDProvider())
+			providers = append(providers, NewAMDProvider(config.AMDSMIPath))
 		case "fake":
 			// Create with 0 GPUs; count will be set from Redis when used
 			providers = append(providers, NewFakeProvider(0))
@@ -116,3 +156,78 @@ func (pm *ProviderManager) GetTotalGPUCount(ctx context.Context) (int, error) {
 	provider := availableProviders[0]
 	return provider.GetGPUCount(ctx)
 }
+
+// DetectGPUUUIDs returns the UUID of each GPU on this system, ordered by index
+func (pm *ProviderManager) DetectGPUUUIDs(ctx context.Context) ([]string, error) {
+	for _, provider := range pm.GetAvailableProviders() {
+		if uuidProvider, ok := provider.(UUIDProvider); ok {
+			return uuidProvider.GetGPUUUIDs(ctx)
+		}
+	}
+	return nil, fmt.Errorf("no available GPU provider reports GPU UUIDs (only NVIDIA is supported)")
+}
+
+// CheckGPUHealth checks the health of the GPUs with the provider, without
+// availability checks, like DetectAllGPUUsageWithoutChecks
+func (pm *ProviderManager) CheckGPUHealth(ctx context.Context) (map[int]string, error) {
+	if len(pm.providers) == 0 {
+		return nil, fmt.Errorf("no GPU providers configured in ProviderManager")
+	}
+	healthProvider, ok := pm.providers[0].(HealthProvider)
+	if !ok {
+		return nil, ErrHealthCheckUnsupported
+	}
+	return healthProvider.CheckGPUHealth(ctx)
+}
+
+// DetectGPUTelemetry reads the fan speeds and clocks of the GPUs from the
+// provider, without availability checks, like DetectAllGPUUsageWithoutChecks
+func (pm *ProviderManager) DetectGPUTelemetry(ctx context.Context) (map[int]*types.GPUTelemetry, error) {
+	if len(pm.providers) == 0 {
+		return nil, fmt.Errorf("no GPU providers configured in ProviderManager")
+	}
+	telemetryProvider, ok := pm.providers[0].(TelemetryProvider)
+	if !ok {
+		return nil, ErrTelemetryUnsupported
+	}
+	return telemetryProvider.DetectGPUTelemetry(ctx)
+}
+
+// smiCommand builds a command running an SMI tool: the configured path if one
+// is set, otherwise name looked up on PATH
+func smiCommand(ctx context.Context, configured, name string, args ...string) (*exec.Cmd, error) {
+	if configured == "" {
+		return exec.CommandContext(ctx, name, args...), nil
+	}
+	if err := checkSMIPath(configured, name); err != nil {
+		return nil, err
+	}
+	return exec.CommandContext(ctx, configured, args...), nil
+}
+
+// checkSMIPath checks that a configured SMI tool path is an existing file
+func checkSMIPath(path, name string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("configured %s path %s does not exist", name, path)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("configured %s path %s is a directory", name, path)
+	}
+	return nil
+}
+
+// ValidateSMIPaths checks that the SMI tool paths set in config exist
+func ValidateSMIPaths(config *types.Config) error {
+	if config.NvidiaSMIPath != "" {
+		if err := checkSMIPath(config.NvidiaSMIPath, nvidiaSMI); err != nil {
+			return err
+		}
+	}
+	if config.AMDSMIPath != "" {
+		if err := checkSMIPath(config.AMDSMIPath, amdSMI); err != nil {
+			return err
+		}
+	}
+	return nil
+}
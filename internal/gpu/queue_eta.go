@@ -0,0 +1,179 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// queueETAHistoryWindow is how much usage history is used to estimate how
+// long reservations last
+const queueETAHistoryWindow = 7 * 24 * time.Hour
+
+// queueWaitEstimates reads the GPU states and recent usage history, and
+// estimates how much longer each of the queue entries will wait
+func (ae *AllocationEngine) queueWaitEstimates(ctx context.Context, entries []*types.QueueEntry, now time.Time) (map[string]time.Duration, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[int]*types.GPUState, gpuCount)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		states[gpuID] = state
+	}
+
+	history, err := ae.client.GetUsageHistory(ctx, now.Add(-queueETAHistoryWindow), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage history: %v", err)
+	}
+
+	return estimateQueueWaits(entries, states, history, now), nil
+}
+
+// estimateQueueWaits roughly estimates how much longer each queue entry will
+// wait for its GPUs, keyed by entry ID. Reservations are assumed to last as
+// long as the average of the usage records, so current reservations end
+// when they reach that age (or another average if they already have), or at
+// their expiry if sooner. Entries are then served in queue order from the
+// GPUs expected to free up first. There are no estimates without usage
+// history, and none from the first entry that can't be served at all.
+func estimateQueueWaits(entries []*types.QueueEntry, states map[int]*types.GPUState, history []*types.UsageRecord, now time.Time) map[string]time.Duration {
+	avg := averageReservationDuration(history)
+	if avg <= 0 {
+		return nil
+	}
+
+	// GPUs allocated to queue entries stay held until the entry completes
+	held := make(map[int]bool)
+	for _, entry := range entries {
+		for _, gpuID := range entry.AllocatedGPUs {
+			held[gpuID] = true
+		}
+	}
+
+	// freeIn is how long from now each GPU is expected to be free
+	freeIn := make(map[int]time.Duration, len(states))
+	for gpuID, state := range states {
+		if !held[gpuID] {
+			freeIn[gpuID] = expectedFreeIn(state, avg, now)
+		}
+	}
+
+	waits := make(map[string]time.Duration, len(entries))
+	var previous time.Duration
+	for _, entry := range entries {
+		var take []int
+		if len(entry.RequestedIDs) > 0 {
+			for _, gpuID := range entry.RequestedIDs {
+				if slices.Contains(entry.AllocatedGPUs, gpuID) {
+					continue
+				}
+				if _, ok := freeIn[gpuID]; !ok {
+					return waits
+				}
+				take = append(take, gpuID)
+			}
+		} else {
+			remaining := entry.GetRequestedGPUCount() - len(entry.AllocatedGPUs)
+			if remaining > len(freeIn) {
+				return waits
+			}
+			take = soonestFree(freeIn)[:max(remaining, 0)]
+		}
+
+		// Only the first entry can acquire GPUs, so no entry is served
+		// before the ones ahead of it
+		wait := previous
+		for _, gpuID := range take {
+			wait = max(wait, freeIn[gpuID])
+		}
+		waits[entry.ID] = wait
+		previous = wait
+
+		runFor := avg
+		if entry.ExpiryDuration > 0 {
+			runFor = min(runFor, entry.ExpiryDuration)
+		}
+		for _, gpuID := range append(take, entry.AllocatedGPUs...) {
+			freeIn[gpuID] = wait + runFor
+		}
+	}
+
+	return waits
+}
+
+// averageReservationDuration returns the mean duration of the reservations in
+// the usage records, or 0 if there are none. Checkpoints are skipped, since
+// the final record gives the whole reservation's duration.
+func averageReservationDuration(history []*types.UsageRecord) time.Duration {
+	var total float64
+	count := 0
+	for _, record := range history {
+		if record.Checkpoint {
+			continue
+		}
+		if duration := record.ReservationDuration(); duration > 0 {
+			total += duration
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(total / float64(count) * float64(time.Second))
+}
+
+// expectedFreeIn estimates how long until the GPU is free, when reservations
+// last avg on average
+func expectedFreeIn(state *types.GPUState, avg time.Duration, now time.Time) time.Duration {
+	if state.IsShared() {
+		var longest time.Duration
+		for _, share := range state.Shares {
+			longest = max(longest, expectedRemaining(share.StartTime.ToTime(), share.ExpiryTime.ToTime(), avg, now))
+		}
+		return longest
+	}
+	if state.User == "" {
+		return 0
+	}
+	return expectedRemaining(state.StartTime.ToTime(), state.ExpiryTime.ToTime(), avg, now)
+}
+
+// expectedRemaining estimates how much longer a reservation made at start
+// lasts: until it is avg old, or for another avg once it is older than
+// that, but no later than its expiry, if any
+func expectedRemaining(start, expiry time.Time, avg time.Duration, now time.Time) time.Duration {
+	remaining := avg
+	if !start.IsZero() && now.Sub(start) < avg {
+		remaining = avg - now.Sub(start)
+	}
+	if !expiry.IsZero() {
+		remaining = min(remaining, max(expiry.Sub(now), 0))
+	}
+	return remaining
+}
+
+// soonestFree returns the GPU IDs in freeIn, those expected to be free
+// soonest first
+func soonestFree(freeIn map[int]time.Duration) []int {
+	ids := make([]int, 0, len(freeIn))
+	for gpuID := range freeIn {
+		ids = append(ids, gpuID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if freeIn[ids[i]] != freeIn[ids[j]] {
+			return freeIn[ids[i]] < freeIn[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
@@ -0,0 +1,122 @@
+package gpu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateQueueWaits(t *testing.T) {
+	now := time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) types.FlexibleTime { return types.FlexibleTime{Time: now.Add(-d)} }
+
+	// Reservations last 45 minutes on average
+	history := []*types.UsageRecord{
+		{User: "alice", GPUID: 0, Duration: 1800},
+		{User: "bob", GPUID: 1, Duration: 3600},
+		{User: "carol", GPUID: 2, Duration: 2700},
+	}
+	states := map[int]*types.GPUState{
+		// Held by alice's queue entry
+		0: {User: "alice", Type: types.ReservationTypeRun, StartTime: ago(time.Minute), PartialQueueID: "a"},
+		1: {User: "alice", Type: types.ReservationTypeRun, StartTime: ago(time.Minute), PartialQueueID: "a"},
+		// Expected to end in 30 minutes
+		2: {User: "bob", Type: types.ReservationTypeRun, StartTime: ago(15 * time.Minute)},
+		// Older than average, but expires in 10 minutes
+		3: {User: "carol", Type: types.ReservationTypeManual, StartTime: ago(2 * time.Hour), ExpiryTime: ago(-10 * time.Minute)},
+	}
+	entries := []*types.QueueEntry{
+		{ID: "a", User: "alice", RequestedCount: 4, AllocatedGPUs: []int{0, 1}},
+		{ID: "b", User: "dave", RequestedCount: 1},
+		{ID: "c", User: "eve", RequestedIDs: []int{3}},
+		{ID: "d", User: "frank", RequestedCount: 5},
+		{ID: "e", User: "grace", RequestedCount: 1},
+	}
+
+	waits := estimateQueueWaits(entries, states, history, now)
+	assert.Equal(t, map[string]time.Duration{
+		// Waits for GPUs 2 and 3
+		"a": 30 * time.Minute,
+		// alice's reservation ends 45 minutes after it starts
+		"b": 75 * time.Minute,
+		"c": 75 * time.Minute,
+		// frank can never be served, which holds up everyone behind him
+	}, waits)
+}
+
+func TestEstimateQueueWaitsAvailableGPUs(t *testing.T) {
+	now := time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC)
+	history := []*types.UsageRecord{{User: "alice", GPUID: 0, Duration: 3600}}
+	states := map[int]*types.GPUState{
+		0: {},
+		1: {User: "bob", Type: types.ReservationTypeRun, StartTime: types.FlexibleTime{Time: now.Add(-20 * time.Minute)}},
+	}
+	entries := []*types.QueueEntry{
+		{ID: "a", User: "alice", RequestedCount: 1, ExpiryDuration: 10 * time.Minute},
+		{ID: "b", User: "carol", RequestedCount: 1},
+	}
+
+	waits := estimateQueueWaits(entries, states, history, now)
+	// alice gets the available GPU for her 10 minute reservation, and carol
+	// gets it after that, before bob's GPU is expected to be free
+	assert.Equal(t, map[string]time.Duration{"a": 0, "b": 10 * time.Minute}, waits)
+}
+
+func TestEstimateQueueWaitsWithoutHistory(t *testing.T) {
+	now := time.Now()
+	states := map[int]*types.GPUState{0: {User: "bob", Type: types.ReservationTypeRun, StartTime: types.FlexibleTime{Time: now}}}
+	entries := []*types.QueueEntry{{ID: "a", User: "alice", RequestedCount: 1}}
+
+	assert.Nil(t, estimateQueueWaits(entries, states, nil, now))
+}
+
+func TestExpectedFreeIn(t *testing.T) {
+	now := time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC)
+	avg := time.Hour
+	at := func(d time.Duration) types.FlexibleTime { return types.FlexibleTime{Time: now.Add(d)} }
+
+	tests := []struct {
+		name  string
+		state *types.GPUState
+		want  time.Duration
+	}{
+		{"available", &types.GPUState{}, 0},
+		{"younger than average", &types.GPUState{User: "a", StartTime: at(-20 * time.Minute)}, 40 * time.Minute},
+		{"older than average", &types.GPUState{User: "a", StartTime: at(-3 * time.Hour)}, time.Hour},
+		{"expires sooner", &types.GPUState{User: "a", StartTime: at(-20 * time.Minute), ExpiryTime: at(5 * time.Minute)}, 5 * time.Minute},
+		{"expired", &types.GPUState{User: "a", StartTime: at(-20 * time.Minute), ExpiryTime: at(-time.Minute)}, 0},
+		{"shared", &types.GPUState{
+			User: types.SharedGPUUser,
+			Type: types.ReservationTypeShared,
+			Shares: []types.GPUShare{
+				{User: "a", StartTime: at(-50 * time.Minute), ExpiryTime: at(time.Hour)},
+				{User: "b", StartTime: at(-30 * time.Minute), ExpiryTime: at(20 * time.Minute)},
+			},
+		}, 20 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expectedFreeIn(tt.state, avg, now))
+		})
+	}
+}
+
+func TestAverageReservationDuration(t *testing.T) {
+	assert.Equal(t, time.Duration(0), averageReservationDuration(nil))
+	assert.Equal(t, 90*time.Second, averageReservationDuration([]*types.UsageRecord{
+		{Duration: 60},
+		{Duration: 120},
+		{Duration: 0}, // Ignored
+	}))
+
+	// A checkpointed reservation counts once, for its whole duration
+	now := time.Now()
+	assert.Equal(t, 2*time.Hour, averageReservationDuration([]*types.UsageRecord{
+		{Duration: 3000, Checkpoint: true},
+		{Duration: 600, EndTime: types.FlexibleTime{Time: now}, ReservationStart: types.FlexibleTime{Time: now.Add(-3 * time.Hour)}},
+		{Duration: 3600},
+	}))
+}
@@ -166,6 +166,101 @@ func TestQueueOrdering_FCFS(t *testing.T) {
 	assert.Equal(t, 2, pos)
 }
 
+func TestQueueOrdering_Priority(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+
+	// Enqueued oldest first, but higher priorities should jump ahead
+	entries := []struct {
+		id       string
+		priority string
+	}{
+		{"low-1", types.PriorityLow},
+		{"normal-1", ""},
+		{"high-1", types.PriorityHigh},
+		{"normal-2", types.PriorityNormal},
+		{"high-2", types.PriorityHigh},
+	}
+
+	for i, e := range entries {
+		entry := &types.QueueEntry{
+			ID:              e.id,
+			User:            "alice",
+			RequestedCount:  1,
+			AllocatedGPUs:   []int{},
+			ReservationType: types.ReservationTypeRun,
+			Priority:        e.priority,
+			EnqueueTime:     types.FlexibleTime{Time: time.Now().Add(time.Duration(i) * time.Second)},
+			LastHeartbeat:   types.FlexibleTime{Time: time.Now()},
+		}
+		require.NoError(t, client.AddToQueue(ctx, entry))
+	}
+
+	allEntries, err := client.GetAllQueueEntries(ctx)
+	require.NoError(t, err)
+	var ids []string
+	for _, entry := range allEntries {
+		ids = append(ids, entry.ID)
+	}
+	assert.Equal(t, []string{"high-1", "high-2", "normal-1", "normal-2", "low-1"}, ids)
+
+	isFirst, err := client.IsFirstInQueue(ctx, "high-1")
+	require.NoError(t, err)
+	assert.True(t, isFirst)
+
+	pos, err := client.GetQueuePosition(ctx, "low-1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, pos)
+}
+
+func TestQueueOrdering_PartialHolderKeepsHead(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+
+	// A normal-priority entry at the head has been given one of its two GPUs
+	holder := &types.QueueEntry{
+		ID:              "holder",
+		User:            "alice",
+		RequestedCount:  2,
+		AllocatedGPUs:   []int{},
+		ReservationType: types.ReservationTypeRun,
+		EnqueueTime:     types.FlexibleTime{Time: time.Now()},
+		LastHeartbeat:   types.FlexibleTime{Time: time.Now()},
+	}
+	require.NoError(t, client.AddToQueue(ctx, holder))
+	holder.AllocatedGPUs = []int{0}
+	require.NoError(t, client.UpdateQueueEntry(ctx, holder))
+
+	// A high-priority entry queued later doesn't displace it
+	high := &types.QueueEntry{
+		ID:              "high",
+		User:            "bob",
+		RequestedCount:  1,
+		AllocatedGPUs:   []int{},
+		ReservationType: types.ReservationTypeRun,
+		Priority:        types.PriorityHigh,
+		EnqueueTime:     types.FlexibleTime{Time: time.Now().Add(time.Second)},
+		LastHeartbeat:   types.FlexibleTime{Time: time.Now()},
+	}
+	require.NoError(t, client.AddToQueue(ctx, high))
+
+	isFirst, err := client.IsFirstInQueue(ctx, "holder")
+	require.NoError(t, err)
+	assert.True(t, isFirst)
+
+	pos, err := client.GetQueuePosition(ctx, "high")
+	require.NoError(t, err)
+	assert.Equal(t, 1, pos)
+}
+
 func TestQueuePosition_IsFirstInQueue(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -451,3 +546,53 @@ func TestQueueEntry_RequestedIDs(t *testing.T) {
 	// Verify GetRequestedGPUCount works correctly
 	assert.Equal(t, 3, retrieved.GetRequestedGPUCount())
 }
+
+func TestAddToQueue_Limits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	setupQueueTestRedis(t)
+	ctx := context.Background()
+	client := redis_client.NewClient(&types.Config{
+		RedisHost:              "localhost",
+		RedisPort:              6379,
+		RedisDB:                15,
+		MaxQueueLength:         3,
+		MaxQueueEntriesPerUser: 2,
+	})
+	defer func() { _ = client.Close() }()
+
+	newEntry := func(id, user, actualUser string) *types.QueueEntry {
+		return &types.QueueEntry{
+			ID:              id,
+			User:            user,
+			ActualUser:      actualUser,
+			RequestedCount:  1,
+			ReservationType: types.ReservationTypeRun,
+			EnqueueTime:     types.FlexibleTime{Time: time.Now()},
+			LastHeartbeat:   types.FlexibleTime{Time: time.Now()},
+		}
+	}
+
+	require.NoError(t, client.AddToQueue(ctx, newEntry("limit-1", "alice", "alice")))
+	require.NoError(t, client.AddToQueue(ctx, newEntry("limit-2", "experiment", "alice")))
+
+	// A custom --user doesn't get around the per-user limit
+	err := client.AddToQueue(ctx, newEntry("limit-3", "other", "alice"))
+	assert.ErrorContains(t, err, "user 'alice' already has 2 entries in the queue (max_queue_entries_per_user is 2)")
+
+	require.NoError(t, client.AddToQueue(ctx, newEntry("limit-4", "bob", "bob")))
+	err = client.AddToQueue(ctx, newEntry("limit-5", "carol", "carol"))
+	assert.ErrorContains(t, err, "the queue is full: it already has 3 entries (max_queue_length is 3)")
+
+	status, err := client.GetQueueStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, status.TotalWaiting)
+	assert.Equal(t, 3, status.MaxQueueLength)
+	assert.Equal(t, 2, status.MaxQueueEntriesPerUser)
+
+	entry, err := client.GetQueueEntry(ctx, "limit-3")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
@@ -0,0 +1,154 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// Reminder is a manual reservation that is about to expire, for the daemon to
+// notify its user of
+type Reminder struct {
+	User       string    `json:"user"`
+	ActualUser string    `json:"actual_user,omitempty"`
+	GPUIDs     []int     `json:"gpu_ids"`
+	ExpiryTime time.Time `json:"expiry_time"`
+	Note       string    `json:"note,omitempty"`
+	JobID      string    `json:"job_id,omitempty"`
+}
+
+// ClaimDueReminders returns the manual reservations whose reminder is due and
+// marks them as reminded, so that each reminder is only returned once. GPUs
+// reserved by the same request are grouped into one reminder.
+func (ae *AllocationEngine) ClaimDueReminders(ctx context.Context, now time.Time) ([]Reminder, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	var reminders []Reminder
+	byJob := make(map[string]int)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return reminders, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		if !reminderDue(state, now) {
+			continue
+		}
+
+		state.Reminded = true
+		if err := ae.client.SetGPUState(ctx, gpuID, state); err != nil {
+			return reminders, fmt.Errorf("failed to mark GPU %d as reminded: %v", gpuID, err)
+		}
+
+		job := state.JobID
+		if job == "" {
+			job = fmt.Sprintf("gpu:%d", gpuID)
+		}
+		if i, ok := byJob[job]; ok {
+			reminders[i].GPUIDs = append(reminders[i].GPUIDs, gpuID)
+			continue
+		}
+		byJob[job] = len(reminders)
+		reminders = append(reminders, Reminder{
+			User:       state.User,
+			ActualUser: state.ActualUser,
+			GPUIDs:     []int{gpuID},
+			ExpiryTime: state.ExpiryTime.ToTime(),
+			Note:       state.Note,
+			JobID:      state.JobID,
+		})
+	}
+
+	for i := range reminders {
+		sort.Ints(reminders[i].GPUIDs)
+	}
+	return reminders, nil
+}
+
+// reminderDue reports whether the reminder of a manual reservation should be
+// sent: it was asked for, hasn't been sent, and the reservation expires within
+// its remind-before window. Reservations still being filled from the queue
+// are skipped, since their expiry is reset once they complete.
+func reminderDue(state *types.GPUState, now time.Time) bool {
+	if state.Type != types.ReservationTypeManual || state.RemindBefore <= 0 || state.Reminded {
+		return false
+	}
+	if state.PartialQueueID != "" || state.ExpiryTime.IsZero() {
+		return false
+	}
+	expiry := state.ExpiryTime.ToTime()
+	remindAt := expiry.Add(-time.Duration(state.RemindBefore) * time.Second)
+	return now.Before(expiry) && !now.Before(remindAt)
+}
+
+// PreemptionNotice tells the holder of a manual reservation that it was
+// preempted by a higher-priority request. PreemptedBy is empty when the
+// preempting request didn't end up reserving the GPUs.
+type PreemptionNotice struct {
+	User        string `json:"user"`
+	GPUIDs      []int  `json:"gpu_ids"`
+	PreemptedBy string `json:"preempted_by,omitempty"`
+}
+
+// ClaimPreemptionNotices returns the manual reservations that were preempted
+// and clears their notice, so that each is only returned once. GPUs taken from
+// the same user by the same user are grouped into one notice.
+func (ae *AllocationEngine) ClaimPreemptionNotices(ctx context.Context) ([]PreemptionNotice, error) {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	var notices []PreemptionNotice
+	byUsers := make(map[[2]string]int)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return notices, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+		if !state.PreemptNotice {
+			continue
+		}
+
+		state.PreemptNotice = false
+		if err := ae.client.SetGPUState(ctx, gpuID, state); err != nil {
+			return notices, fmt.Errorf("failed to clear the preemption notice of GPU %d: %v", gpuID, err)
+		}
+
+		users := [2]string{state.PreemptedUser, state.User}
+		if i, ok := byUsers[users]; ok {
+			notices[i].GPUIDs = append(notices[i].GPUIDs, gpuID)
+			continue
+		}
+		byUsers[users] = len(notices)
+		notices = append(notices, PreemptionNotice{
+			User:        state.PreemptedUser,
+			GPUIDs:      []int{gpuID},
+			PreemptedBy: state.User,
+		})
+	}
+	return notices, nil
+}
@@ -0,0 +1,136 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminderDue(t *testing.T) {
+	now := time.Now()
+	manual := func(expiresIn time.Duration, remindBefore int64) *types.GPUState {
+		return &types.GPUState{
+			User:         "alice",
+			Type:         types.ReservationTypeManual,
+			ExpiryTime:   types.FlexibleTime{Time: now.Add(expiresIn)},
+			RemindBefore: remindBefore,
+		}
+	}
+
+	assert.True(t, reminderDue(manual(30*time.Minute, 3600), now))
+	assert.True(t, reminderDue(manual(time.Hour, 3600), now))
+	assert.False(t, reminderDue(manual(2*time.Hour, 3600), now), "not yet in the reminder window")
+	assert.False(t, reminderDue(manual(-time.Minute, 3600), now), "already expired")
+	assert.False(t, reminderDue(manual(30*time.Minute, 0), now), "no reminder asked for")
+
+	reminded := manual(30*time.Minute, 3600)
+	reminded.Reminded = true
+	assert.False(t, reminderDue(reminded, now))
+
+	partial := manual(30*time.Minute, 3600)
+	partial.PartialQueueID = "queue-entry"
+	assert.False(t, reminderDue(partial, now))
+
+	run := manual(30*time.Minute, 3600)
+	run.Type = types.ReservationTypeRun
+	assert.False(t, reminderDue(run, now))
+
+	assert.False(t, reminderDue(&types.GPUState{}, now))
+}
+
+func TestClaimDueReminders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	now := time.Now()
+	reservation := func(jobID string, expiresIn time.Duration, remindBefore int64) *types.GPUState {
+		return &types.GPUState{
+			User:         "alice",
+			ActualUser:   "alice",
+			Type:         types.ReservationTypeManual,
+			StartTime:    types.FlexibleTime{Time: now.Add(-time.Hour)},
+			ExpiryTime:   types.FlexibleTime{Time: now.Add(expiresIn)},
+			Note:         "training",
+			JobID:        jobID,
+			RemindBefore: remindBefore,
+		}
+	}
+	require.NoError(t, client.SetGPUState(ctx, 0, reservation("job-1", 30*time.Minute, 3600)))
+	require.NoError(t, client.SetGPUState(ctx, 2, reservation("job-1", 30*time.Minute, 3600)))
+	require.NoError(t, client.SetGPUState(ctx, 3, reservation("job-2", 3*time.Hour, 3600)))
+
+	engine := NewAllocationEngine(client, &types.Config{RedisHost: "localhost", RedisPort: 6379, RedisDB: 15})
+
+	reminders, err := engine.ClaimDueReminders(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, reminders, 1)
+	assert.Equal(t, "alice", reminders[0].User)
+	assert.Equal(t, []int{0, 2}, reminders[0].GPUIDs)
+	assert.Equal(t, "job-1", reminders[0].JobID)
+	assert.Equal(t, now.Add(30*time.Minute).Unix(), reminders[0].ExpiryTime.Unix())
+
+	state, err := client.GetGPUState(ctx, 2)
+	require.NoError(t, err)
+	assert.True(t, state.Reminded)
+
+	// Each reminder is only claimed once
+	reminders, err = engine.ClaimDueReminders(ctx, now)
+	require.NoError(t, err)
+	assert.Empty(t, reminders)
+}
+
+func TestClaimPreemptionNotices(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	now := time.Now()
+	preempted := func(by string) *types.GPUState {
+		state := &types.GPUState{
+			LastReleased:  types.FlexibleTime{Time: now},
+			PreemptedUser: "alice",
+			PreemptNotice: true,
+		}
+		if by != "" {
+			state.User = by
+			state.Type = types.ReservationTypeRun
+			state.StartTime = types.FlexibleTime{Time: now}
+		}
+		return state
+	}
+	require.NoError(t, client.SetGPUState(ctx, 0, preempted("bob")))
+	require.NoError(t, client.SetGPUState(ctx, 1, preempted("")))
+	require.NoError(t, client.SetGPUState(ctx, 2, preempted("bob")))
+
+	engine := NewAllocationEngine(client, &types.Config{RedisHost: "localhost", RedisPort: 6379, RedisDB: 15})
+
+	notices, err := engine.ClaimPreemptionNotices(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []PreemptionNotice{
+		{User: "alice", GPUIDs: []int{0, 2}, PreemptedBy: "bob"},
+		{User: "alice", GPUIDs: []int{1}},
+	}, notices)
+
+	state, err := client.GetGPUState(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, state.PreemptNotice)
+	assert.Equal(t, "alice", state.PreemptedUser)
+
+	// Each notice is only claimed once
+	notices, err = engine.ClaimPreemptionNotices(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, notices)
+}
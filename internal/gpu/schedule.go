@@ -0,0 +1,146 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// ScheduleEvent is a scheduled reservation whose window started, for the
+// daemon to log and notify its user of. Activated is false if the GPUs
+// couldn't be reserved, with the reason in Schedule.Conflict.
+type ScheduleEvent struct {
+	Schedule  types.ScheduledReservation
+	Activated bool
+}
+
+// AddScheduledReservation stores a scheduled reservation after checking that
+// its GPUs exist and that no other scheduled window has any of them at the
+// same time. Reservations that exist now are not checked, since they may be
+// released before the window starts.
+func (ae *AllocationEngine) AddScheduledReservation(ctx context.Context, schedule *types.ScheduledReservation) error {
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return err
+	}
+	for _, gpuID := range schedule.GPUIDs {
+		if gpuID < 0 || gpuID >= gpuCount {
+			return fmt.Errorf("GPU ID %d is out of range (0-%d)", gpuID, gpuCount-1)
+		}
+	}
+
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	schedules, err := ae.client.GetScheduledReservations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range schedules {
+		if other.Overlaps(schedule) {
+			return fmt.Errorf("GPU(s) %v are already scheduled for %s from %s to %s (schedule %s)",
+				other.GPUIDs, other.User, other.StartTime.Local().Format("2006-01-02 15:04"),
+				other.EndTime.Local().Format("2006-01-02 15:04"), other.ID)
+		}
+	}
+
+	return ae.client.SetScheduledReservation(ctx, schedule)
+}
+
+// CancelScheduledReservation removes a scheduled reservation made by user.
+// Windows that are active can't be canceled; their GPUs are released like any
+// other reservation.
+func (ae *AllocationEngine) CancelScheduledReservation(ctx context.Context, id, user string) (*types.ScheduledReservation, error) {
+	schedules, err := ae.client.GetScheduledReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		if schedule.ID != id {
+			continue
+		}
+		if schedule.ActualUser != user {
+			return nil, fmt.Errorf("schedule %s belongs to %s", id, schedule.ActualUser)
+		}
+		if schedule.Status == types.ScheduleStatusActive {
+			return nil, fmt.Errorf("schedule %s is already active; release GPU(s) %v with 'canhazgpu release' instead",
+				id, schedule.GPUIDs)
+		}
+		if err := ae.client.RemoveScheduledReservation(ctx, id); err != nil {
+			return nil, err
+		}
+		return schedule, nil
+	}
+
+	return nil, fmt.Errorf("no schedule with ID %s", id)
+}
+
+// ActivateDueSchedules reserves the GPUs of each scheduled window that has
+// started, as a manual reservation that expires when the window ends, and
+// removes windows that are over. A window whose GPUs are in use is reported
+// once as a conflict and retried on each call until it ends.
+func (ae *AllocationEngine) ActivateDueSchedules(ctx context.Context, now time.Time) ([]ScheduleEvent, error) {
+	schedules, err := ae.client.GetScheduledReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ScheduleEvent
+	for _, schedule := range schedules {
+		if !now.Before(schedule.EndTime.Time) {
+			// An active window's reservation expires by itself
+			if err := ae.client.RemoveScheduledReservation(ctx, schedule.ID); err != nil {
+				return events, fmt.Errorf("failed to remove schedule %s: %v", schedule.ID, err)
+			}
+			continue
+		}
+		if schedule.Status == types.ScheduleStatusActive || now.Before(schedule.StartTime.Time) {
+			continue
+		}
+
+		err := ae.activateSchedule(ctx, schedule)
+		if err == nil {
+			schedule.Status = types.ScheduleStatusActive
+			schedule.Conflict = ""
+			events = append(events, ScheduleEvent{Schedule: *schedule, Activated: true})
+		} else {
+			reported := schedule.Status == types.ScheduleStatusConflict
+			schedule.Status = types.ScheduleStatusConflict
+			schedule.Conflict = err.Error()
+			if !reported {
+				events = append(events, ScheduleEvent{Schedule: *schedule})
+			}
+		}
+
+		if err := ae.client.SetScheduledReservation(ctx, schedule); err != nil {
+			return events, fmt.Errorf("failed to update schedule %s: %v", schedule.ID, err)
+		}
+	}
+
+	return events, nil
+}
+
+// activateSchedule reserves the GPUs of a scheduled window until it ends
+func (ae *AllocationEngine) activateSchedule(ctx context.Context, schedule *types.ScheduledReservation) error {
+	expiryTime := schedule.EndTime.Time
+	_, err := ae.AllocateGPUs(ctx, &types.AllocationRequest{
+		GPUIDs:          schedule.GPUIDs,
+		User:            schedule.User,
+		ActualUser:      schedule.ActualUser,
+		ReservationType: types.ReservationTypeManual,
+		ExpiryTime:      &expiryTime,
+		Note:            schedule.Note,
+		Source:          types.ReservationSourceSchedule,
+		Account:         schedule.Account,
+	})
+	return err
+}
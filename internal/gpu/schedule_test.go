@@ -0,0 +1,90 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledReservations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+	require.NoError(t, client.SetAvailableProvider(ctx, "fake"))
+	engine := NewAllocationEngine(client, &types.Config{})
+
+	now := time.Now()
+	window := func(id string, gpuIDs []int, start, end time.Duration) *types.ScheduledReservation {
+		return &types.ScheduledReservation{
+			ID:         id,
+			User:       "alice",
+			ActualUser: "alice",
+			GPUIDs:     gpuIDs,
+			StartTime:  types.FlexibleTime{Time: now.Add(start)},
+			EndTime:    types.FlexibleTime{Time: now.Add(end)},
+			Status:     types.ScheduleStatusPending,
+		}
+	}
+
+	require.NoError(t, engine.AddScheduledReservation(ctx, window("night", []int{0, 1}, time.Hour, 9*time.Hour)))
+	require.NoError(t, engine.AddScheduledReservation(ctx, window("busy", []int{2}, time.Hour, 2*time.Hour)))
+	require.NoError(t, engine.AddScheduledReservation(ctx, window("after", []int{0}, 9*time.Hour, 10*time.Hour)))
+
+	err := engine.AddScheduledReservation(ctx, window("overlap", []int{1, 3}, 8*time.Hour, 10*time.Hour))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "schedule night")
+	err = engine.AddScheduledReservation(ctx, window("missing", []int{4}, time.Hour, 2*time.Hour))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of range")
+
+	// GPU 2 is reserved by someone else when its window starts
+	require.NoError(t, client.SetGPUState(ctx, 2, &types.GPUState{
+		User:       "bob",
+		Type:       types.ReservationTypeManual,
+		StartTime:  types.FlexibleTime{Time: now},
+		ExpiryTime: types.FlexibleTime{Time: now.Add(3 * time.Hour)},
+	}))
+
+	events, err := engine.ActivateDueSchedules(ctx, now.Add(90*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "night", events[0].Schedule.ID)
+	assert.True(t, events[0].Activated)
+	assert.Equal(t, "busy", events[1].Schedule.ID)
+	assert.False(t, events[1].Activated)
+	assert.Contains(t, events[1].Schedule.Conflict, "bob")
+
+	state, err := client.GetGPUState(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", state.User)
+	assert.Equal(t, types.ReservationSourceSchedule, state.Source)
+	assert.Equal(t, now.Add(9*time.Hour).Unix(), state.ExpiryTime.Unix())
+
+	// Conflicts are only reported once, and active windows can't be canceled
+	events, err = engine.ActivateDueSchedules(ctx, now.Add(95*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	_, err = engine.CancelScheduledReservation(ctx, "night", "alice")
+	assert.Error(t, err)
+
+	_, err = engine.CancelScheduledReservation(ctx, "after", "bob")
+	assert.Error(t, err)
+	canceled, err := engine.CancelScheduledReservation(ctx, "after", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, canceled.GPUIDs)
+
+	// Windows are removed once they are over
+	_, err = engine.ActivateDueSchedules(ctx, now.Add(10*time.Hour))
+	require.NoError(t, err)
+	schedules, err := client.GetScheduledReservations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+}
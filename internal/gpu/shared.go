@@ -0,0 +1,294 @@
+package gpu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// maxSharesPerGPU returns how many shared reservations one GPU can hold
+func (ae *AllocationEngine) maxSharesPerGPU() int {
+	if ae.config.MaxSharesPerGPU > 0 {
+		return ae.config.MaxSharesPerGPU
+	}
+	return types.DefaultMaxSharesPerGPU
+}
+
+// sharedCandidate is a GPU that can take another shared reservation
+type sharedCandidate struct {
+	gpuID int
+	state *types.GPUState
+}
+
+// AllocateSharedGPUs gives the requesting user a share of the requested GPUs.
+// A GPU can be shared if it is already shared and has room for another
+// holder, or if it is free. GPUs that are already shared are used first, so
+// that shared use packs onto as few GPUs as possible. Shared reservations
+// never wait in the queue.
+func (ae *AllocationEngine) AllocateSharedGPUs(ctx context.Context, request *types.AllocationRequest) ([]int, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if request.ReservationType != types.ReservationTypeShared {
+		return nil, fmt.Errorf("invalid reservation type for a shared reservation: %s", request.ReservationType)
+	}
+
+	usage, err := ae.detectGPUUsage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate GPU usage: %v", err)
+	}
+	unreservedGPUs := GetUnreservedGPUs(ctx, usage, ae.config.MemoryThreshold)
+
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	gpuCount, err := ae.client.GetGPUCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	maxShares := ae.maxSharesPerGPU()
+
+	var shared, free []sharedCandidate
+	reasons := make(map[int]string)
+	for gpuID := 0; gpuID < gpuCount; gpuID++ {
+		state, err := ae.client.GetGPUState(ctx, gpuID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state for GPU %d: %v", gpuID, err)
+		}
+
+		switch {
+		case state.IsShared():
+			if state.ShareOf(request.User) >= 0 {
+				reasons[gpuID] = "you already hold a share of it"
+			} else if len(state.Shares) >= maxShares {
+				reasons[gpuID] = fmt.Sprintf("it already has %d of %d shares taken", len(state.Shares), maxShares)
+			} else {
+				shared = append(shared, sharedCandidate{gpuID, state})
+			}
+		case state.User != "":
+			reasons[gpuID] = fmt.Sprintf("it is reserved by user '%s'", state.User)
+		case containsGPU(unreservedGPUs, gpuID):
+			reasons[gpuID] = "it is in use without reservation"
+		case coolingDown(state, ae.config.GPUCooldown, now):
+			reasons[gpuID] = "it was just released and is cooling down"
+		default:
+			free = append(free, sharedCandidate{gpuID, state})
+		}
+	}
+
+	selected, err := selectSharedGPUs(request, gpuCount, shared, free, reasons)
+	if err != nil {
+		return nil, err
+	}
+
+	share := types.GPUShare{
+		User:       request.User,
+		ActualUser: request.ActualUser,
+		StartTime:  types.FlexibleTime{Time: now},
+		ExpiryTime: types.FlexibleTime{Time: *request.ExpiryTime},
+		Note:       request.Note,
+		Account:    request.Account,
+	}
+
+	var allocated []int
+	for _, candidate := range selected {
+		state := candidate.state
+		if !state.IsShared() {
+			state = &types.GPUState{
+				User:      types.SharedGPUUser,
+				Type:      types.ReservationTypeShared,
+				StartTime: types.FlexibleTime{Time: now},
+				Source:    types.ReservationSourceReserve,
+			}
+		}
+		state.Shares = append(state.Shares, share)
+
+		if err := ae.client.SetGPUState(ctx, candidate.gpuID, state); err != nil {
+			return allocated, fmt.Errorf("failed to reserve a share of GPU %d: %v", candidate.gpuID, err)
+		}
+		allocated = append(allocated, candidate.gpuID)
+	}
+
+	sort.Ints(allocated)
+	return allocated, nil
+}
+
+// selectSharedGPUs picks the GPUs for a shared reservation: the requested
+// GPU IDs, which must all be shareable, or the requested number of GPUs,
+// preferring GPUs that are already shared. reasons explains why the GPUs
+// that aren't candidates can't be shared.
+func selectSharedGPUs(request *types.AllocationRequest, gpuCount int, shared, free []sharedCandidate, reasons map[int]string) ([]sharedCandidate, error) {
+	candidates := append(append([]sharedCandidate{}, shared...), free...)
+
+	if len(request.GPUIDs) > 0 {
+		var selected []sharedCandidate
+		for _, gpuID := range request.GPUIDs {
+			if gpuID >= gpuCount {
+				return nil, fmt.Errorf("invalid GPU ID %d: must be between 0 and %d", gpuID, gpuCount-1)
+			}
+			found := false
+			for _, candidate := range candidates {
+				if candidate.gpuID == gpuID {
+					selected = append(selected, candidate)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("GPU %d can't be shared: %s", gpuID, reasons[gpuID])
+			}
+		}
+		return selected, nil
+	}
+
+	if len(candidates) < request.GPUCount {
+		return nil, fmt.Errorf("not enough GPUs available for sharing. Requested: %d, Available: %d",
+			request.GPUCount, len(candidates))
+	}
+	return candidates[:request.GPUCount], nil
+}
+
+// releaseShare removes user's share of a shared GPU and records its usage.
+// The GPU becomes free once its last share is released. The allocation lock
+// is held while the state is updated, since holders share one GPU state.
+func (ae *AllocationEngine) releaseShare(ctx context.Context, gpuID int, user string) (bool, error) {
+	return ae.updateShares(ctx, gpuID, func(state *types.GPUState, now time.Time) []types.GPUShare {
+		i := state.ShareOf(user)
+		if i < 0 {
+			return nil
+		}
+		return []types.GPUShare{state.Shares[i]}
+	})
+}
+
+// expireShares removes the expired shares of a shared GPU and records their
+// usage
+func (ae *AllocationEngine) expireShares(ctx context.Context, gpuID int) (bool, error) {
+	return ae.updateShares(ctx, gpuID, func(state *types.GPUState, now time.Time) []types.GPUShare {
+		var expired []types.GPUShare
+		for _, share := range state.Shares {
+			if now.After(share.ExpiryTime.ToTime()) {
+				expired = append(expired, share)
+			}
+		}
+		return expired
+	})
+}
+
+// updateShares removes the shares chosen by remove from a shared GPU, under
+// the allocation lock. It reports whether any share was removed.
+func (ae *AllocationEngine) updateShares(ctx context.Context, gpuID int, remove func(*types.GPUState, time.Time) []types.GPUShare) (bool, error) {
+	if err := ae.client.AcquireAllocationLock(ctx); err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := ae.client.ReleaseAllocationLock(ctx); err != nil {
+			fmt.Printf("Warning: failed to release allocation lock: %v\n", err)
+		}
+	}()
+
+	// Re-read the state now that no one else can change it
+	state, err := ae.client.GetGPUState(ctx, gpuID)
+	if err != nil {
+		return false, err
+	}
+	if !state.IsShared() {
+		return false, nil
+	}
+
+	now := time.Now()
+	removed := remove(state, now)
+	if len(removed) == 0 {
+		return false, nil
+	}
+
+	for _, share := range removed {
+		usageRecord := &types.UsageRecord{
+			User:            share.User,
+			GPUID:           gpuID,
+			StartTime:       share.StartTime,
+			EndTime:         types.FlexibleTime{Time: now},
+			Duration:        now.Sub(share.StartTime.ToTime()).Seconds(),
+			ReservationType: types.ReservationTypeShared,
+			Account:         share.Account,
+		}
+		if err := ae.client.RecordUsageHistory(ctx, usageRecord); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record usage history: %v\n", err)
+		}
+	}
+
+	state.Shares = remainingShares(state.Shares, removed)
+	if len(state.Shares) == 0 {
+		state = &types.GPUState{LastReleased: types.FlexibleTime{Time: now}}
+	}
+	if err := ae.client.SetGPUState(ctx, gpuID, state); err != nil {
+		return false, fmt.Errorf("failed to update shares of GPU %d: %v", gpuID, err)
+	}
+	return true, nil
+}
+
+// remainingShares returns shares without the removed ones
+func remainingShares(shares, removed []types.GPUShare) []types.GPUShare {
+	var remaining []types.GPUShare
+	for _, share := range shares {
+		keep := true
+		for _, r := range removed {
+			if share.User == r.User {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			remaining = append(remaining, share)
+		}
+	}
+	return remaining
+}
+
+// sharedHolders describes the holders of a shared GPU for display, e.g.
+// "alice, bob"
+func sharedHolders(shares []types.GPUShare) string {
+	users := make([]string, len(shares))
+	for i, share := range shares {
+		users[i] = share.User
+		if share.ActualUser != "" && share.ActualUser != share.User {
+			users[i] = fmt.Sprintf("%s (%s)", share.ActualUser, share.User)
+		}
+	}
+	return strings.Join(users, ", ")
+}
+
+// hasExpiredShare reports whether any share of a shared GPU has expired
+func hasExpiredShare(state *types.GPUState, now time.Time) bool {
+	for _, share := range state.Shares {
+		if now.After(share.ExpiryTime.ToTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// earliestShareExpiry returns when the first share of a shared GPU expires
+func earliestShareExpiry(shares []types.GPUShare) time.Time {
+	var earliest time.Time
+	for _, share := range shares {
+		expiry := share.ExpiryTime.ToTime()
+		if earliest.IsZero() || expiry.Before(earliest) {
+			earliest = expiry
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,122 @@
+package gpu
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectSharedGPUs(t *testing.T) {
+	shared := []sharedCandidate{{gpuID: 2, state: &types.GPUState{Type: types.ReservationTypeShared}}}
+	free := []sharedCandidate{{gpuID: 0, state: &types.GPUState{}}, {gpuID: 1, state: &types.GPUState{}}}
+	reasons := map[int]string{3: "it is reserved by user 'bob'"}
+
+	selectedIDs := func(selected []sharedCandidate) []int {
+		ids := make([]int, len(selected))
+		for i, candidate := range selected {
+			ids[i] = candidate.gpuID
+		}
+		return ids
+	}
+
+	t.Run("prefers already shared GPUs", func(t *testing.T) {
+		selected, err := selectSharedGPUs(&types.AllocationRequest{GPUCount: 2}, 4, shared, free, reasons)
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 0}, selectedIDs(selected))
+	})
+
+	t.Run("not enough GPUs", func(t *testing.T) {
+		_, err := selectSharedGPUs(&types.AllocationRequest{GPUCount: 4}, 4, shared, free, reasons)
+		assert.ErrorContains(t, err, "Requested: 4, Available: 3")
+	})
+
+	t.Run("specific IDs", func(t *testing.T) {
+		selected, err := selectSharedGPUs(&types.AllocationRequest{GPUCount: 2, GPUIDs: []int{1, 2}}, 4, shared, free, reasons)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, selectedIDs(selected))
+	})
+
+	t.Run("specific ID can't be shared", func(t *testing.T) {
+		_, err := selectSharedGPUs(&types.AllocationRequest{GPUCount: 1, GPUIDs: []int{3}}, 4, shared, free, reasons)
+		assert.EqualError(t, err, "GPU 3 can't be shared: it is reserved by user 'bob'")
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		_, err := selectSharedGPUs(&types.AllocationRequest{GPUCount: 1, GPUIDs: []int{7}}, 4, shared, free, reasons)
+		assert.ErrorContains(t, err, "invalid GPU ID 7")
+	})
+}
+
+func TestShareHelpers(t *testing.T) {
+	now := time.Now()
+	shares := []types.GPUShare{
+		{User: "alice", ExpiryTime: types.FlexibleTime{Time: now.Add(2 * time.Hour)}},
+		{User: "ci", ActualUser: "bob", ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)}},
+	}
+	state := &types.GPUState{User: types.SharedGPUUser, Type: types.ReservationTypeShared, Shares: shares}
+
+	assert.Equal(t, "alice, bob (ci)", sharedHolders(shares))
+	assert.Equal(t, now.Add(time.Hour), earliestShareExpiry(shares))
+	assert.Equal(t, []types.GPUShare{shares[1]}, remainingShares(shares, shares[:1]))
+	assert.Empty(t, remainingShares(shares, shares))
+	assert.False(t, hasExpiredShare(state, now))
+	assert.True(t, hasExpiredShare(state, now.Add(90*time.Minute)))
+}
+
+func TestAllocateSharedGPUs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	client := setupQueueTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 2))
+	require.NoError(t, client.SetAvailableProvider(ctx, "fake"))
+	engine := NewAllocationEngine(client, &types.Config{MaxSharesPerGPU: 2})
+
+	expiry := time.Now().Add(time.Hour)
+	request := func(user string) *types.AllocationRequest {
+		return &types.AllocationRequest{
+			GPUCount:        1,
+			User:            user,
+			ReservationType: types.ReservationTypeShared,
+			ExpiryTime:      &expiry,
+		}
+	}
+
+	// The second holder joins the GPU the first one is sharing
+	for _, user := range []string{"alice", "bob"} {
+		allocated, err := engine.AllocateSharedGPUs(ctx, request(user))
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, allocated)
+	}
+
+	state, err := client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, types.SharedGPUUser, state.User)
+	assert.Len(t, state.Shares, 2)
+
+	// GPU 0 is full, so the third holder gets GPU 1
+	allocated, err := engine.AllocateSharedGPUs(ctx, request("carol"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, allocated)
+
+	// The GPU is free once its last share is released
+	released, err := engine.ReleaseGPUs(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, released)
+	released, err = engine.ReleaseGPUs(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, released)
+
+	state, err = client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, state.User)
+	assert.Empty(t, state.Shares)
+	assert.False(t, state.LastReleased.IsZero())
+}
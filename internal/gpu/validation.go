@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -86,7 +88,7 @@ func GetUnreservedGPUs(ctx context.Context, usage map[int]*types.GPUUsage, memor
 	var unreserved []int
 
 	for gpuID, gpuUsage := range usage {
-		if gpuUsage.MemoryMB > memoryThreshold {
+		if IsGPUInUnreservedUse(gpuUsage, memoryThreshold) {
 			unreserved = append(unreserved, gpuID)
 		}
 	}
@@ -94,7 +96,40 @@ func GetUnreservedGPUs(ctx context.Context, usage map[int]*types.GPUUsage, memor
 	return unreserved
 }
 
-// IsGPUInUnreservedUse checks if a specific GPU is in unreserved use
+// IsGPUInUnreservedUse checks if a specific GPU is in unreserved use. Usage
+// that hasn't been confirmed by a second sample and memory used by ignored
+// processes don't count.
 func IsGPUInUnreservedUse(usage *types.GPUUsage, memoryThreshold int) bool {
-	return usage != nil && usage.MemoryMB > memoryThreshold
+	return usage != nil && usage.UnreservedMemoryMB() > memoryThreshold && !usage.Unconfirmed
+}
+
+// markIgnoredProcesses sets IgnoredMemoryMB on each GPU to the memory used by
+// processes owned by one of ignoreUsers or named one of ignoreProcesses
+func markIgnoredProcesses(usage map[int]*types.GPUUsage, ignoreUsers, ignoreProcesses []string) {
+	if len(ignoreUsers) == 0 && len(ignoreProcesses) == 0 {
+		return
+	}
+
+	for _, gpuUsage := range usage {
+		if gpuUsage == nil {
+			continue
+		}
+		gpuUsage.IgnoredMemoryMB = 0
+		for _, proc := range gpuUsage.Processes {
+			if isIgnoredProcess(proc, ignoreUsers, ignoreProcesses) {
+				gpuUsage.IgnoredMemoryMB += proc.MemoryMB
+			}
+		}
+	}
+}
+
+// isIgnoredProcess reports whether a process is owned by one of ignoreUsers
+// or named one of ignoreProcesses. Names match the process name or, for
+// processes reported by path (e.g. /usr/lib/xorg/Xorg), its last element.
+func isIgnoredProcess(proc types.GPUProcessInfo, ignoreUsers, ignoreProcesses []string) bool {
+	if slices.Contains(ignoreUsers, proc.User) {
+		return true
+	}
+	return slices.Contains(ignoreProcesses, proc.ProcessName) ||
+		slices.Contains(ignoreProcesses, filepath.Base(proc.ProcessName))
 }
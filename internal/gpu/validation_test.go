@@ -65,7 +65,7 @@ func TestDetectGPUUsage_Integration(t *testing.T) {
 	t.Log("This test uses the new GPU Provider system (NVIDIA/AMD)")
 
 	// Use the new GPU Provider system
-	pm := NewProviderManager()
+	pm := NewProviderManager(&types.Config{})
 	availableProviders := pm.GetAvailableProviders()
 
 	if len(availableProviders) == 0 {
@@ -153,6 +153,21 @@ func TestIsGPUInUnreservedUse(t *testing.T) {
 			usage:    &types.GPUUsage{MemoryMB: 1536},
 			expected: true,
 		},
+		{
+			name:     "Above threshold but unconfirmed",
+			usage:    &types.GPUUsage{MemoryMB: 1536, Unconfirmed: true},
+			expected: false,
+		},
+		{
+			name:     "Above threshold only with ignored processes",
+			usage:    &types.GPUUsage{MemoryMB: 1536, IgnoredMemoryMB: 600},
+			expected: false,
+		},
+		{
+			name:     "Above threshold without ignored processes",
+			usage:    &types.GPUUsage{MemoryMB: 4096, IgnoredMemoryMB: 600},
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -162,3 +177,48 @@ func TestIsGPUInUnreservedUse(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkIgnoredProcesses(t *testing.T) {
+	usage := map[int]*types.GPUUsage{
+		// System daemons only
+		0: {GPUID: 0, MemoryMB: 1600, Processes: []types.GPUProcessInfo{
+			{PID: 100, ProcessName: "/usr/lib/xorg/Xorg", User: "root", MemoryMB: 1100},
+			{PID: 101, ProcessName: "dcgm-exporter", User: "dcgm", MemoryMB: 500},
+		}},
+		// A user's job next to a daemon
+		1: {GPUID: 1, MemoryMB: 9000, Processes: []types.GPUProcessInfo{
+			{PID: 102, ProcessName: "nv-hostengine", User: "dcgm", MemoryMB: 400},
+			{PID: 200, ProcessName: "python", User: "alice", MemoryMB: 8600},
+		}},
+		// No daemons
+		2: {GPUID: 2, MemoryMB: 2048, Processes: []types.GPUProcessInfo{
+			{PID: 201, ProcessName: "python", User: "bob", MemoryMB: 2048},
+		}},
+		3: {GPUID: 3},
+	}
+
+	markIgnoredProcesses(usage, []string{"dcgm"}, []string{"Xorg"})
+
+	assert.Equal(t, 1600, usage[0].IgnoredMemoryMB)
+	assert.Equal(t, 400, usage[1].IgnoredMemoryMB)
+	assert.Equal(t, 0, usage[2].IgnoredMemoryMB)
+	assert.Equal(t, 0, usage[3].IgnoredMemoryMB)
+
+	unreserved := GetUnreservedGPUs(context.Background(), usage, types.MemoryThresholdMB)
+	assert.ElementsMatch(t, []int{1, 2}, unreserved)
+
+	// A new sample is marked from scratch
+	markIgnoredProcesses(usage, nil, []string{"python"})
+	assert.ElementsMatch(t, []int{0}, GetUnreservedGPUs(context.Background(), usage, types.MemoryThresholdMB))
+}
+
+func TestIsIgnoredProcess(t *testing.T) {
+	users := []string{"gdm"}
+	processes := []string{"Xorg", "dcgm-exporter"}
+
+	assert.True(t, isIgnoredProcess(types.GPUProcessInfo{ProcessName: "Xorg", User: "root"}, users, processes))
+	assert.True(t, isIgnoredProcess(types.GPUProcessInfo{ProcessName: "/usr/lib/xorg/Xorg", User: "root"}, users, processes))
+	assert.True(t, isIgnoredProcess(types.GPUProcessInfo{ProcessName: "gnome-shell", User: "gdm"}, users, processes))
+	assert.False(t, isIgnoredProcess(types.GPUProcessInfo{ProcessName: "python", User: "alice"}, users, processes))
+	assert.False(t, isIgnoredProcess(types.GPUProcessInfo{ProcessName: "xorg", User: "root"}, users, processes))
+}
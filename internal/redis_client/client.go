@@ -5,29 +5,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
 )
 
 type Client struct {
-	rdb    *redis.Client
-	config *types.Config
+	rdb     *redis.Client
+	rdbRead *redis.Client // Read replica; same as rdb when no replica is configured
+	config  *types.Config
+
+	usageSink UsageSink // Forwards usage records outside Redis; nil when disabled
 }
 
 func NewClient(config *types.Config) *Client {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", config.RedisHost, config.RedisPort),
+	rdb := newRedisClient(config, config.RedisHost, config.RedisPort)
+
+	// Read-only queries (status, reports) go to the replica if one is configured,
+	// otherwise everything shares the primary connection.
+	rdbRead := rdb
+	if config.RedisReadHost != "" {
+		rdbRead = newRedisClient(config, config.RedisReadHost, readPort(config))
+	}
+
+	client := &Client{rdb: rdb, rdbRead: rdbRead, config: config}
+	if config.UsageSink.URL != "" {
+		client.usageSink = NewHTTPUsageSink(config.UsageSink)
+	}
+	return client
+}
+
+// newRedisClient creates a go-redis client with the connection settings shared
+// by the primary and the read replica.
+func newRedisClient(config *types.Config, host string, port int) *redis.Client {
+	dialTimeout, readTimeout, writeTimeout := config.RedisTimeouts()
+	return redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%d", host, port),
 		DB:   config.RedisDB,
 
 		// Connection health settings to detect and recover from stale connections.
 		// This is critical for long-lived processes like the supervisor, where a
 		// silently dead TCP connection would cause heartbeat failures and eventual
-		// reservation loss.
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
+		// reservation loss. They also make commands fail instead of hanging
+		// when Redis stops responding.
+		DialTimeout:  dialTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
 
 		// Limit pool size and set idle timeout so stale connections are cycled out.
 		PoolSize:     3,
@@ -41,16 +69,48 @@ func NewClient(config *types.Config) *Client {
 		MinRetryBackoff: 100 * time.Millisecond,
 		MaxRetryBackoff: 2 * time.Second,
 	})
+}
+
+// readPort returns the read replica port, defaulting to the primary port
+func readPort(config *types.Config) int {
+	if config.RedisReadPort > 0 {
+		return config.RedisReadPort
+	}
+	return config.RedisPort
+}
 
-	return &Client{rdb: rdb, config: config}
+// hasReadReplica reports whether reads are served by a separate replica connection
+func (c *Client) hasReadReplica() bool {
+	return c.rdbRead != nil && c.rdbRead != c.rdb
+}
+
+// ReadOnly returns a view of the client that sends every command to the read
+// replica. Only read operations should be issued through it. The returned
+// client shares connections with c and must not be closed separately.
+func (c *Client) ReadOnly() *Client {
+	return &Client{rdb: c.rdbRead, rdbRead: c.rdbRead, config: c.config}
 }
 
 func (c *Client) Close() error {
+	if c.hasReadReplica() {
+		if err := c.rdbRead.Close(); err != nil {
+			_ = c.rdb.Close()
+			return err
+		}
+	}
 	return c.rdb.Close()
 }
 
 func (c *Client) Ping(ctx context.Context) error {
-	return c.rdb.Ping(ctx).Err()
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		return err
+	}
+	if c.hasReadReplica() {
+		if err := c.rdbRead.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("read replica: %v", err)
+		}
+	}
+	return nil
 }
 
 // HealthCheck verifies the Redis connection is alive with a short timeout.
@@ -65,25 +125,17 @@ func (c *Client) HealthCheck(ctx context.Context) error {
 // This is used to recover from stale/dead TCP connections in long-lived
 // processes like the supervisor.
 func (c *Client) Reconnect() error {
-	// Close existing connection (ignore errors from already-broken connections)
+	// Close existing connections (ignore errors from already-broken connections)
+	if c.hasReadReplica() {
+		_ = c.rdbRead.Close()
+	}
 	_ = c.rdb.Close()
 
-	c.rdb = redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", c.config.RedisHost, c.config.RedisPort),
-		DB:   c.config.RedisDB,
-
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-
-		PoolSize:     3,
-		MinIdleConns: 1,
-		IdleTimeout:  90 * time.Second,
-
-		MaxRetries:      3,
-		MinRetryBackoff: 100 * time.Millisecond,
-		MaxRetryBackoff: 2 * time.Second,
-	})
+	c.rdb = newRedisClient(c.config, c.config.RedisHost, c.config.RedisPort)
+	c.rdbRead = c.rdb
+	if c.config.RedisReadHost != "" {
+		c.rdbRead = newRedisClient(c.config, c.config.RedisReadHost, readPort(c.config))
+	}
 
 	// Verify the new connection works
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -131,8 +183,63 @@ func (c *Client) GetAvailableProvider(ctx context.Context) (string, error) {
 	return val, nil
 }
 
+// gpuKey returns the Redis key holding a GPU's state. GPUs are keyed by
+// index, or by UUID when UseGPUUUIDs is set so that reservations follow the
+// physical GPU if indices change across reboots.
+func (c *Client) gpuKey(gpuID int) (string, error) {
+	if !c.config.UseGPUUUIDs {
+		return fmt.Sprintf("%sgpu:%d", types.RedisKeyPrefix, gpuID), nil
+	}
+	if gpuID < 0 || gpuID >= len(c.config.GPUUUIDs) {
+		return "", fmt.Errorf("no UUID known for GPU %d", gpuID)
+	}
+	return types.RedisKeyPrefix + "gpu:" + c.config.GPUUUIDs[gpuID], nil
+}
+
+// gpuKeysJSON returns the state keys of GPUs 0 to gpuCount-1 as a JSON array
+// for the allocation scripts
+func (c *Client) gpuKeysJSON(gpuCount int) (string, error) {
+	keys := make([]string, gpuCount)
+	for gpuID := range keys {
+		key, err := c.gpuKey(gpuID)
+		if err != nil {
+			return "", err
+		}
+		keys[gpuID] = key
+	}
+
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// gpuListJSON encodes GPU IDs for an allocation script. A nil list is
+// encoded as [] rather than null, which cjson decodes to a value that can't
+// be iterated.
+func gpuListJSON(gpuIDs []int) ([]byte, error) {
+	if gpuIDs == nil {
+		gpuIDs = []int{}
+	}
+	return json.Marshal(gpuIDs)
+}
+
+// renewDuration returns how many seconds each keepalive extends a renewable
+// reservation by, which is the duration it was made for, or 0 if the request
+// isn't renewable
+func renewDuration(request *types.AllocationRequest, currentTime int64) int64 {
+	if !request.Renewable || request.ExpiryTime == nil {
+		return 0
+	}
+	return max(request.ExpiryTime.Unix()-currentTime, 0)
+}
+
 func (c *Client) GetGPUState(ctx context.Context, gpuID int) (*types.GPUState, error) {
-	key := fmt.Sprintf("%sgpu:%d", types.RedisKeyPrefix, gpuID)
+	key, err := c.gpuKey(gpuID)
+	if err != nil {
+		return nil, err
+	}
 	val, err := c.rdb.Get(ctx, key).Result()
 	if err == redis.Nil {
 		// GPU is available
@@ -151,7 +258,10 @@ func (c *Client) GetGPUState(ctx context.Context, gpuID int) (*types.GPUState, e
 }
 
 func (c *Client) SetGPUState(ctx context.Context, gpuID int, state *types.GPUState) error {
-	key := fmt.Sprintf("%sgpu:%d", types.RedisKeyPrefix, gpuID)
+	key, err := c.gpuKey(gpuID)
+	if err != nil {
+		return err
+	}
 
 	if state.User == "" {
 		// GPU is available, just store last_released timestamp if it exists
@@ -176,15 +286,19 @@ func (c *Client) SetGPUState(ctx context.Context, gpuID int, state *types.GPUSta
 }
 
 func (c *Client) DeleteGPUState(ctx context.Context, gpuID int) error {
-	key := fmt.Sprintf("%sgpu:%d", types.RedisKeyPrefix, gpuID)
+	key, err := c.gpuKey(gpuID)
+	if err != nil {
+		return err
+	}
 	return c.rdb.Del(ctx, key).Err()
 }
 
 // Allocation Lock Management
 
 func (c *Client) AcquireAllocationLock(ctx context.Context) error {
-	for attempt := 0; attempt < types.MaxLockRetries; attempt++ {
-		acquired, err := c.rdb.SetNX(ctx, types.RedisKeyAllocationLock, "locked", types.LockTimeout).Result()
+	timeout, maxRetries := lockSettings(c.config)
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		acquired, err := c.rdb.SetNX(ctx, types.RedisKeyAllocationLock, "locked", timeout).Result()
 		if err != nil {
 			return err
 		}
@@ -197,13 +311,170 @@ func (c *Client) AcquireAllocationLock(ctx context.Context) error {
 		time.Sleep(sleepTime)
 	}
 
-	return fmt.Errorf("failed to acquire allocation lock after %d attempts", types.MaxLockRetries)
+	return fmt.Errorf("failed to acquire allocation lock after %d attempts", maxRetries)
+}
+
+// lockSettings returns the configured allocation lock timeout and number of
+// attempts, falling back to the defaults for unset values
+func lockSettings(config *types.Config) (time.Duration, int) {
+	timeout, maxRetries := types.LockTimeout, types.MaxLockRetries
+	if config != nil && config.LockTimeout > 0 {
+		timeout = config.LockTimeout
+	}
+	if config != nil && config.LockMaxRetries > 0 {
+		maxRetries = config.LockMaxRetries
+	}
+	return timeout, maxRetries
 }
 
 func (c *Client) ReleaseAllocationLock(ctx context.Context) error {
 	return c.rdb.Del(ctx, types.RedisKeyAllocationLock).Err()
 }
 
+// SwapBootID records the host's current boot ID and returns the one recorded
+// before, or "" if none was
+func (c *Client) SwapBootID(ctx context.Context, bootID string) (string, error) {
+	previous, err := c.rdb.GetSet(ctx, types.RedisKeyBootID, bootID).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return previous, err
+}
+
+// SetGPUModels records the model of each GPU, as last detected, so that usage
+// records can be tagged with it
+func (c *Client) SetGPUModels(ctx context.Context, models map[int]string) error {
+	if len(models) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(models))
+	for gpuID, model := range models {
+		values[strconv.Itoa(gpuID)] = model
+	}
+	return c.rdb.HSet(ctx, types.RedisKeyGPUModels, values).Err()
+}
+
+// GetGPUModel returns the last detected model of a GPU, or "" if it isn't
+// known
+func (c *Client) GetGPUModel(ctx context.Context, gpuID int) (string, error) {
+	model, err := c.rdb.HGet(ctx, types.RedisKeyGPUModels, strconv.Itoa(gpuID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return model, err
+}
+
+// AcquireDaemonLock claims the daemon lock for owner, so that only one
+// daemon runs per Redis database. Returns false if another owner holds it.
+func (c *Client) AcquireDaemonLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	return c.rdb.SetNX(ctx, types.RedisKeyDaemonLock, owner, ttl).Result()
+}
+
+// RefreshDaemonLock extends the daemon lock if it is still held by owner.
+// Returns false if the lock was lost to another owner or expired.
+func (c *Client) RefreshDaemonLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	luaScript := `
+		if redis.call('GET', KEYS[1]) == ARGV[1] then
+			return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		end
+		return 0
+	`
+	result, err := c.rdb.Eval(ctx, luaScript, []string{types.RedisKeyDaemonLock}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+// ReleaseDaemonLock removes the daemon lock if it is still held by owner
+func (c *Client) ReleaseDaemonLock(ctx context.Context, owner string) error {
+	luaScript := `
+		if redis.call('GET', KEYS[1]) == ARGV[1] then
+			return redis.call('DEL', KEYS[1])
+		end
+		return 0
+	`
+	return c.rdb.Eval(ctx, luaScript, []string{types.RedisKeyDaemonLock}, owner).Err()
+}
+
+// GetDaemonLockOwner returns the owner of the daemon lock, or "" if no daemon holds it
+func (c *Client) GetDaemonLockOwner(ctx context.Context) (string, error) {
+	owner, err := c.rdb.Get(ctx, types.RedisKeyDaemonLock).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return owner, err
+}
+
+// luaSaveTrace defines a Lua helper for the allocation scripts that stores the
+// allocation decision for 'canhazgpu explain-last'
+const luaSaveTrace = `
+		local function save_trace(trace, ttl)
+			-- cjson encodes empty tables as objects, so leave out empty lists
+			for name, value in pairs(trace) do
+				if type(value) == "table" and next(value) == nil then
+					trace[name] = nil
+				end
+			end
+			redis.call('SET', 'canhazgpu:allocation_trace:' .. trace.user, cjson.encode(trace), 'EX', ttl)
+		end
+`
+
+// luaCooldown defines Lua helpers for the allocation scripts that read the
+// GPUs cooling down after a release and the release times of the free GPUs,
+// from AllocationRequest.CoolingDownGPUs and ReleasedAt.
+const luaCooldown = `
+		local function decode_cooling(cooling_json)
+			local cooling = {}
+			for _, gpu_id in ipairs(cjson.decode(cooling_json)) do
+				cooling[tonumber(gpu_id)] = true
+			end
+			return cooling
+		end
+
+		local function released_seconds(released_at, gpu_id)
+			return tonumber(released_at[tostring(gpu_id)])
+		end
+`
+
+// cooldownJSON encodes the request's cooling down GPUs and release times for
+// the allocation scripts
+func cooldownJSON(request *types.AllocationRequest) (cooling []byte, releasedAt []byte, err error) {
+	cooling, err = gpuListJSON(request.CoolingDownGPUs)
+	if err != nil {
+		return nil, nil, err
+	}
+	releasedAt, err = gpuTimesJSON(request.ReleasedAt)
+	return cooling, releasedAt, err
+}
+
+// luaNearExpiring defines a Lua helper for the allocation scripts that checks
+// whether a GPU is next to a manual reservation expiring within the minimum
+// free duration, from AllocationRequest.ExpiresAt and MinFreeDuration.
+const luaNearExpiring = `
+		local function near_expiring(expires_at, gpu_id, current_time, min_free)
+			if min_free <= 0 then
+				return false
+			end
+			for _, neighbour in ipairs({gpu_id - 1, gpu_id + 1}) do
+				local expiry = tonumber(expires_at[tostring(neighbour)])
+				if expiry and expiry < current_time + min_free then
+					return true
+				end
+			end
+			return false
+		end
+`
+
+// gpuTimesJSON encodes Unix times by GPU ID for the allocation scripts. A nil
+// map is encoded as an empty object, since cjson decodes null as userdata.
+func gpuTimesJSON(times map[int]int64) ([]byte, error) {
+	if times == nil {
+		times = map[int]int64{}
+	}
+	return json.Marshal(times)
+}
+
 // Atomic GPU Allocation using Lua script
 func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.AllocationRequest, unreservedGPUs []int) ([]int, error) {
 	// Check if specific GPU IDs are requested
@@ -212,7 +483,7 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 	}
 
 	// MRU-per-user logic for allocating by count
-	luaScript := `
+	luaScript := luaCooldown + luaNearExpiring + luaSaveTrace + `
 		local gpu_count = tonumber(ARGV[1])
 		local requested = tonumber(ARGV[2])
 		local user = ARGV[3]
@@ -222,6 +493,28 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 		local expiry_time = ARGV[7]
 		local unreserved_gpus_json = ARGV[8]
 		local note = ARGV[9]
+		local source = ARGV[10]
+		local priority = ARGV[11]
+		local account = ARGV[12]
+		local cooling = decode_cooling(ARGV[13])
+		local trace_ttl = tonumber(ARGV[14])
+		local gpu_keys = cjson.decode(ARGV[15])
+		local renew_duration = tonumber(ARGV[16]) or 0
+		local job_id = ARGV[17]
+		local use_history = ARGV[18] == "1"
+		local class_excluded_json = ARGV[19]
+		local gpu_class = ARGV[20]
+		local command = ARGV[21]
+		local remind_before = tonumber(ARGV[22]) or 0
+		local released_at = cjson.decode(ARGV[23])
+		local min_free = tonumber(ARGV[24]) or 0
+		local expires_at = cjson.decode(ARGV[25])
+
+		-- GPUs outside the requested GPU class
+		local class_excluded = {}
+		for _, gpu_id in ipairs(cjson.decode(class_excluded_json)) do
+			class_excluded[tonumber(gpu_id)] = true
+		end
 
 		-- Parse unreserved GPUs
 		local unreserved_gpus = {}
@@ -238,8 +531,12 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 		local user_gpu_history = {}
 		local history_key = "canhazgpu:usage_history_sorted"
 
-		-- Get recent usage records for this user (last 100 records should be plenty)
-		local recent_records = redis.call('ZREVRANGE', history_key, 0, 99, 'WITHSCORES')
+		-- Get recent usage records for this user (last 100 records should be plenty).
+		-- With usage history disabled, records left from before are ignored too.
+		local recent_records = {}
+		if use_history then
+			recent_records = redis.call('ZREVRANGE', history_key, 0, 99, 'WITHSCORES')
+		end
 		for i = 1, #recent_records, 2 do
 			local record_json = recent_records[i]
 			local timestamp = tonumber(recent_records[i + 1])
@@ -254,24 +551,35 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 			end
 		end
 
-		-- Get available GPUs with MRU-per-user ranking
+		-- Get available GPUs with MRU-per-user ranking, keeping track of why
+		-- other GPUs were excluded
 		local available_gpus = {}
+		local excluded_unreserved = {}
+		local excluded_reserved = {}
+		local excluded_cooling = {}
+		local excluded_class = {}
 		for i = 0, gpu_count - 1 do
-			local key = "canhazgpu:gpu:" .. i
+			local key = gpu_keys[i + 1]
 			local gpu_data = redis.call('GET', key)
 
-			-- Skip unreserved GPUs
-			if not unreserved_gpus[i] then
+			-- Skip GPUs outside the requested class and unreserved GPUs
+			if class_excluded[i] then
+				table.insert(excluded_class, i)
+			elseif not unreserved_gpus[i] then
 				if not gpu_data then
 					-- GPU is available (never used)
 					table.insert(available_gpus, {
 						id = i,
 						last_released = 0,
+						released = 0,
 						user_last_used = user_gpu_history[i] or 0
 					})
 				else
 					local state = cjson.decode(gpu_data)
-					if not state.user then
+					if not state.user and cooling[i] then
+						-- GPU was released too recently and is cooling down
+						table.insert(excluded_cooling, i)
+					elseif not state.user then
 						-- GPU is available
 						local last_released = 0
 
@@ -304,13 +612,33 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 						table.insert(available_gpus, {
 							id = i,
 							last_released = last_released,
+							released = released_seconds(released_at, i) or 0,
 							user_last_used = user_gpu_history[i] or 0
 						})
+					else
+						table.insert(excluded_reserved, i)
 					end
 				end
+			else
+				table.insert(excluded_unreserved, i)
 			end
 		end
 
+		-- Skip GPUs next to a reservation that expires within the minimum
+		-- free duration
+		local excluded_near_expiring = {}
+		if min_free > 0 then
+			local kept = {}
+			for _, gpu in ipairs(available_gpus) do
+				if near_expiring(expires_at, gpu.id, current_time, min_free) then
+					table.insert(excluded_near_expiring, gpu.id)
+				else
+					table.insert(kept, gpu)
+				end
+			end
+			available_gpus = kept
+		end
+
 		-- Sort by MRU-per-user: prefer GPUs this user used most recently
 		-- If user never used a GPU, fall back to global LRU
 		table.sort(available_gpus, function(a, b)
@@ -329,8 +657,33 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 			return a.last_released < b.last_released
 		end)
 		
+		-- Record the ranked candidates for 'canhazgpu explain-last'
+		local trace = {
+			time = current_time,
+			user = user,
+			requested = requested,
+			candidates = {},
+			unreserved = excluded_unreserved,
+			reserved = excluded_reserved,
+			cooling_down = excluded_cooling,
+			other_class = excluded_class,
+			near_expiring = excluded_near_expiring
+		}
+		if gpu_class ~= "" then
+			trace.gpu_class = gpu_class
+		end
+		for _, gpu in ipairs(available_gpus) do
+			table.insert(trace.candidates, {
+				gpu_id = gpu.id,
+				user_last_used = gpu.user_last_used,
+				last_released = gpu.released
+			})
+		end
+
 		-- Check if we have enough GPUs
 		if #available_gpus < requested then
+			trace.error = "Not enough GPUs available"
+			save_trace(trace, trace_ttl)
 			return redis.error_reply("Not enough GPUs available")
 		end
 		
@@ -352,6 +705,12 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 				state.last_heartbeat = current_time
 			elseif reservation_type == "manual" and expiry_time ~= "nil" then
 				state.expiry_time = tonumber(expiry_time)
+				if renew_duration > 0 then
+					state.renew_duration = renew_duration
+				end
+				if remind_before > 0 then
+					state.remind_before = remind_before
+				end
 			end
 
 			-- Add note if provided
@@ -359,11 +718,31 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 				state.note = note
 			end
 
+			-- Record how the reservation was created
+			if source and source ~= "" then
+				state.source = source
+			end
+			if priority and priority ~= "" then
+				state.priority = priority
+			end
+			if account and account ~= "" then
+				state.account = account
+			end
+			if job_id and job_id ~= "" then
+				state.job_id = job_id
+			end
+			if command and command ~= "" then
+				state.command = command
+			end
+
 			-- Set GPU state
-			local key = "canhazgpu:gpu:" .. gpu_id
+			local key = gpu_keys[tonumber(gpu_id) + 1]
 			redis.call('SET', key, cjson.encode(state))
 		end
 		
+		trace.allocated = allocated
+		save_trace(trace, trace_ttl)
+
 		return allocated
 	`
 
@@ -378,6 +757,10 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 	if err != nil {
 		return nil, err
 	}
+	gpuKeys, err := c.gpuKeysJSON(gpuCount)
+	if err != nil {
+		return nil, err
+	}
 
 	// Prepare arguments
 	currentTime := time.Now().Unix()
@@ -386,6 +769,25 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 		expiryTime = fmt.Sprintf("%d", request.ExpiryTime.Unix())
 	}
 
+	// Prefer the GPUs the user used most recently unless usage history is off
+	useHistory := "1"
+	if c.config.DisableUsageHistory {
+		useHistory = "0"
+	}
+
+	classExcludedJSON, err := gpuListJSON(request.ClassExcludedGPUs)
+	if err != nil {
+		return nil, err
+	}
+	coolingJSON, releasedAtJSON, err := cooldownJSON(request)
+	if err != nil {
+		return nil, err
+	}
+	expiresAtJSON, err := gpuTimesJSON(request.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
 	// Execute Lua script
 	result, err := c.rdb.Eval(ctx, luaScript, []string{},
 		gpuCount,
@@ -397,6 +799,22 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 		expiryTime,
 		string(unreservedJSON),
 		request.Note,
+		request.Source,
+		request.Priority,
+		request.Account,
+		string(coolingJSON),
+		int(types.AllocationTraceTTL.Seconds()),
+		gpuKeys,
+		renewDuration(request, currentTime),
+		uuid.New().String(),
+		useHistory,
+		string(classExcludedJSON),
+		request.GPUClass,
+		request.Command,
+		int64(request.RemindBefore/time.Second),
+		string(releasedAtJSON),
+		int64(request.MinFreeDuration/time.Second),
+		string(expiresAtJSON),
 	).Result()
 
 	if err != nil {
@@ -436,7 +854,7 @@ func (c *Client) AtomicReserveGPUs(ctx context.Context, request *types.Allocatio
 
 // atomicReserveSpecificGPUs reserves specific GPU IDs if they are available
 func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.AllocationRequest, unreservedGPUs []int) ([]int, error) {
-	luaScript := `
+	luaScript := luaCooldown + luaSaveTrace + `
 		local requested_gpus_json = ARGV[1]
 		local user = ARGV[2]
 		local actual_user = ARGV[3]
@@ -446,6 +864,17 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 		local unreserved_gpus_json = ARGV[7]
 		local gpu_count = tonumber(ARGV[8])
 		local note = ARGV[9]
+		local source = ARGV[10]
+		local priority = ARGV[11]
+		local account = ARGV[12]
+		local cooling = decode_cooling(ARGV[13])
+		local trace_ttl = tonumber(ARGV[14])
+		local gpu_keys = cjson.decode(ARGV[15])
+		local renew_duration = tonumber(ARGV[16]) or 0
+		local job_id = ARGV[17]
+		local command = ARGV[18]
+		local remind_before = tonumber(ARGV[19]) or 0
+		local released_at = cjson.decode(ARGV[20])
 		
 		-- Parse requested GPU IDs
 		local requested_gpus = {}
@@ -469,23 +898,39 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 				end
 			end
 		end
+
+		-- Run reservations without a heartbeat for this long are treated as
+		-- released (the heartbeat-timeout pool setting, 5 minutes by default)
+		local heartbeat_timeout = tonumber(redis.call('HGET', 'canhazgpu:settings', 'heartbeat-timeout')) or 300
 		
+		-- Record the outcome for 'canhazgpu explain-last'
+		local trace = {
+			time = current_time,
+			user = user,
+			requested_ids = requested_gpus
+		}
+		local function fail(message)
+			trace.error = message
+			save_trace(trace, trace_ttl)
+			return redis.error_reply(message)
+		end
+
 		-- Validate all requested GPUs
 		for _, gpu_id in ipairs(requested_gpus) do
 			local gpu_id_num = tonumber(gpu_id)
 			
 			-- Check if GPU ID is valid (within range)
 			if gpu_id_num < 0 or gpu_id_num >= gpu_count then
-				return redis.error_reply("GPU ID " .. gpu_id .. " is out of range (0-" .. (gpu_count-1) .. ")")
+				return fail("GPU ID " .. gpu_id .. " is out of range (0-" .. (gpu_count-1) .. ")")
 			end
 			
 			-- Check if GPU is unreserved (in use without reservation)
 			if unreserved_gpus[gpu_id_num] then
-				return redis.error_reply("GPU " .. gpu_id .. " is in use without reservation")
+				return fail("GPU " .. gpu_id .. " is in use without reservation")
 			end
 			
 			-- Check if GPU is already reserved
-			local key = "canhazgpu:gpu:" .. gpu_id
+			local key = gpu_keys[tonumber(gpu_id) + 1]
 			local gpu_data = redis.call('GET', key)
 			
 			if gpu_data then
@@ -494,12 +939,18 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 					-- GPU is already reserved
 					if state.type == "manual" and state.expiry_time and tonumber(state.expiry_time) < current_time then
 						-- Manual reservation has expired, continue
-					elseif state.type == "run" and state.last_heartbeat and (current_time - tonumber(state.last_heartbeat)) > 300 then
-						-- Run reservation heartbeat timeout (5 minutes), continue
+					elseif state.type == "run" and state.last_heartbeat and (current_time - tonumber(state.last_heartbeat)) > heartbeat_timeout then
+						-- Run reservation heartbeat timed out, continue
 					else
 						-- GPU is actively reserved
-						return redis.error_reply("GPU " .. gpu_id .. " is already reserved by user '" .. state.user .. "'")
+						return fail("GPU " .. gpu_id .. " is already reserved by user '" .. state.user .. "'")
 					end
+				elseif cooling[tonumber(gpu_id)] then
+					local released = released_seconds(released_at, gpu_id)
+					if released then
+						return fail("GPU " .. gpu_id .. " was released " .. math.floor(current_time - released) .. "s ago and is cooling down")
+					end
+					return fail("GPU " .. gpu_id .. " was just released and is cooling down")
 				end
 			end
 		end
@@ -522,6 +973,12 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 				state.last_heartbeat = current_time
 			elseif reservation_type == "manual" and expiry_time ~= "nil" then
 				state.expiry_time = tonumber(expiry_time)
+				if renew_duration > 0 then
+					state.renew_duration = renew_duration
+				end
+				if remind_before > 0 then
+					state.remind_before = remind_before
+				end
 			end
 
 			-- Add note if provided
@@ -529,11 +986,31 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 				state.note = note
 			end
 
+			-- Record how the reservation was created
+			if source and source ~= "" then
+				state.source = source
+			end
+			if priority and priority ~= "" then
+				state.priority = priority
+			end
+			if account and account ~= "" then
+				state.account = account
+			end
+			if job_id and job_id ~= "" then
+				state.job_id = job_id
+			end
+			if command and command ~= "" then
+				state.command = command
+			end
+
 			-- Set GPU state
-			local key = "canhazgpu:gpu:" .. gpu_id
+			local key = gpu_keys[tonumber(gpu_id) + 1]
 			redis.call('SET', key, cjson.encode(state))
 		end
 		
+		trace.allocated = allocated
+		save_trace(trace, trace_ttl)
+
 		return allocated
 	`
 
@@ -549,11 +1026,20 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 		return nil, err
 	}
 
+	coolingJSON, releasedAtJSON, err := cooldownJSON(request)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get GPU count for validation
 	gpuCount, err := c.GetGPUCount(ctx)
 	if err != nil {
 		return nil, err
 	}
+	gpuKeys, err := c.gpuKeysJSON(gpuCount)
+	if err != nil {
+		return nil, err
+	}
 
 	// Prepare arguments
 	currentTime := time.Now().Unix()
@@ -573,6 +1059,17 @@ func (c *Client) atomicReserveSpecificGPUs(ctx context.Context, request *types.A
 		string(unreservedJSON),
 		gpuCount,
 		request.Note,
+		request.Source,
+		request.Priority,
+		request.Account,
+		string(coolingJSON),
+		int(types.AllocationTraceTTL.Seconds()),
+		gpuKeys,
+		renewDuration(request, currentTime),
+		uuid.New().String(),
+		request.Command,
+		int64(request.RemindBefore/time.Second),
+		string(releasedAtJSON),
 	).Result()
 
 	if err != nil {
@@ -625,14 +1122,92 @@ func (c *Client) ClearAllGPUStates(ctx context.Context) error {
 	return nil
 }
 
+// CountUsageHistory returns the number of usage records kept in Redis, in the
+// sorted set and as legacy per-record keys
+func (c *Client) CountUsageHistory(ctx context.Context) (int, error) {
+	sorted, err := c.rdb.ZCard(ctx, types.RedisKeyPrefix+"usage_history_sorted").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	legacyKeys, err := c.legacyUsageHistoryKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(sorted) + len(legacyKeys), nil
+}
+
+// ClearUsageHistory deletes all usage records (for admin --reset-usage-history)
+// and returns how many were deleted. GPU states are not touched.
+func (c *Client) ClearUsageHistory(ctx context.Context) (int, error) {
+	sortedSetKey := types.RedisKeyPrefix + "usage_history_sorted"
+
+	// Count and delete the sorted set together so records added in between
+	// are counted
+	pipe := c.rdb.TxPipeline()
+	sorted := pipe.ZCard(ctx, sortedSetKey)
+	pipe.Del(ctx, sortedSetKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	deleted := int(sorted.Val())
+
+	legacyKeys, err := c.legacyUsageHistoryKeys(ctx)
+	if err != nil {
+		return deleted, err
+	}
+	if len(legacyKeys) > 0 {
+		n, err := c.rdb.Del(ctx, legacyKeys...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+
+	return deleted, nil
+}
+
+// legacyUsageHistoryKeys returns the old per-record usage history keys
+func (c *Client) legacyUsageHistoryKeys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := c.rdb.Scan(ctx, 0, types.RedisKeyUsageHistory+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
 // FlushTestDB flushes the entire test database (DB 15).
 // This should only be used in tests to ensure a clean state.
 func (c *Client) FlushTestDB(ctx context.Context) error {
 	return c.rdb.FlushDB(ctx).Err()
 }
 
-// RecordUsageHistory records a GPU usage entry when a reservation is released
+// RecordUsageHistory records a GPU usage entry when a reservation is released,
+// and forwards it to the usage sink if one is configured. It does nothing if
+// usage history is disabled.
 func (c *Client) RecordUsageHistory(ctx context.Context, record *types.UsageRecord) error {
+	if c.config.DisableUsageHistory {
+		return nil
+	}
+
+	// Tag the record with this host so usage can be told apart when history
+	// from several hosts is combined, and with the GPU's model for weighted
+	// GPU hours
+	if record.Host == "" || record.GPUModel == "" {
+		tagged := *record
+		if tagged.Host == "" {
+			tagged.Host = utils.Hostname()
+		}
+		if tagged.GPUModel == "" {
+			if model, err := c.GetGPUModel(ctx, record.GPUID); err == nil {
+				tagged.GPUModel = model
+			}
+		}
+		record = &tagged
+	}
+
 	data, err := json.Marshal(record)
 	if err != nil {
 		return err
@@ -656,6 +1231,8 @@ func (c *Client) RecordUsageHistory(ctx context.Context, record *types.UsageReco
 		fmt.Printf("Warning: failed to set expiration on usage history: %v\n", err)
 	}
 
+	c.forwardUsageRecord(ctx, record, data)
+
 	return nil
 }
 
@@ -664,14 +1241,14 @@ func (c *Client) GetUsageHistory(ctx context.Context, startTime, endTime time.Ti
 	sortedSetKey := types.RedisKeyPrefix + "usage_history_sorted"
 
 	// Check if new sorted set format exists
-	exists, err := c.rdb.Exists(ctx, sortedSetKey).Result()
+	exists, err := c.rdbRead.Exists(ctx, sortedSetKey).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to check sorted set existence: %v", err)
 	}
 
 	if exists > 0 {
 		// Use efficient sorted set range query
-		results, err := c.rdb.ZRangeByScore(ctx, sortedSetKey, &redis.ZRangeBy{
+		results, err := c.rdbRead.ZRangeByScore(ctx, sortedSetKey, &redis.ZRangeBy{
 			Min: fmt.Sprintf("%d", startTime.Unix()),
 			Max: fmt.Sprintf("%d", endTime.Unix()),
 		}).Result()
@@ -715,14 +1292,14 @@ func (c *Client) GetUsageHistory(ctx context.Context, startTime, endTime time.Ti
 func (c *Client) getUsageHistoryOldFormat(ctx context.Context, startTime, endTime time.Time) ([]*types.UsageRecord, error) {
 	// Get all usage history keys using the old pattern
 	pattern := types.RedisKeyUsageHistory + "*"
-	keys, err := c.rdb.Keys(ctx, pattern).Result()
+	keys, err := c.rdbRead.Keys(ctx, pattern).Result()
 	if err != nil {
 		return nil, err
 	}
 
 	var records []*types.UsageRecord
 	for _, key := range keys {
-		data, err := c.rdb.Get(ctx, key).Result()
+		data, err := c.rdbRead.Get(ctx, key).Result()
 		if err != nil {
 			continue
 		}
@@ -774,35 +1351,246 @@ func (c *Client) migrateOldUsageRecords(ctx context.Context, records []*types.Us
 	return nil
 }
 
+// GetAllocationTrace returns the trace of the user's most recent allocation,
+// or nil if there is none
+func (c *Client) GetAllocationTrace(ctx context.Context, user string) (*types.AllocationTrace, error) {
+	val, err := c.rdb.Get(ctx, types.RedisKeyAllocationTrace+user).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var trace types.AllocationTrace
+	if err := json.Unmarshal([]byte(val), &trace); err != nil {
+		return nil, fmt.Errorf("corrupted allocation trace for user %s: %v", user, err)
+	}
+
+	return &trace, nil
+}
+
+// GetPoolSettings returns the pool-wide settings set with 'admin --set'
+func (c *Client) GetPoolSettings(ctx context.Context) (map[string]string, error) {
+	return c.rdbRead.HGetAll(ctx, types.RedisKeySettings).Result()
+}
+
+// SetPoolSetting stores a pool-wide setting, shared by every host using this Redis
+func (c *Client) SetPoolSetting(ctx context.Context, name, value string) error {
+	return c.rdb.HSet(ctx, types.RedisKeySettings, name, value).Err()
+}
+
+// UnsetPoolSetting removes a pool-wide setting, restoring its default
+func (c *Client) UnsetPoolSetting(ctx context.Context, name string) error {
+	return c.rdb.HDel(ctx, types.RedisKeySettings, name).Err()
+}
+
+// GetHeartbeatTimeout returns how long a run reservation may go without a
+// heartbeat before it is released. The heartbeat-timeout pool setting
+// overrides types.HeartbeatTimeout; the default is returned along with any
+// error reading it.
+func (c *Client) GetHeartbeatTimeout(ctx context.Context) (time.Duration, error) {
+	value, err := c.rdb.HGet(ctx, types.RedisKeySettings, types.SettingHeartbeatTimeout).Result()
+	if err == redis.Nil {
+		return types.HeartbeatTimeout, nil
+	}
+	if err != nil {
+		return types.HeartbeatTimeout, err
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return types.HeartbeatTimeout, fmt.Errorf("invalid %s setting %q", types.SettingHeartbeatTimeout, value)
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if err := types.ValidateHeartbeatTimeout(timeout); err != nil {
+		return types.HeartbeatTimeout, fmt.Errorf("invalid %s setting: %v", types.SettingHeartbeatTimeout, err)
+	}
+	return timeout, nil
+}
+
+// GetUnreservedSamples returns when each GPU was last seen above the memory
+// threshold, for GPUs whose latest sample was above it
+func (c *Client) GetUnreservedSamples(ctx context.Context) (map[int]time.Time, error) {
+	values, err := c.rdb.HGetAll(ctx, types.RedisKeyUnreservedSamples).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(map[int]time.Time, len(values))
+	for field, value := range values {
+		gpuID, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		millis, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		samples[gpuID] = time.UnixMilli(millis)
+	}
+
+	return samples, nil
+}
+
+// UpdateUnreservedSamples records the GPUs seen above the memory threshold in
+// the latest sample and forgets those seen below it. Samples expire after
+// maxAge, so an old sighting doesn't count as part of a new one's streak.
+func (c *Client) UpdateUnreservedSamples(ctx context.Context, seen map[int]time.Time, cleared []int, maxAge time.Duration) error {
+	pipe := c.rdb.TxPipeline()
+	for gpuID, at := range seen {
+		pipe.HSet(ctx, types.RedisKeyUnreservedSamples, strconv.Itoa(gpuID), at.UnixMilli())
+	}
+	for _, gpuID := range cleared {
+		pipe.HDel(ctx, types.RedisKeyUnreservedSamples, strconv.Itoa(gpuID))
+	}
+	if len(seen) > 0 {
+		pipe.Expire(ctx, types.RedisKeyUnreservedSamples, maxAge)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecordAllocationLatency adds the latency of a successful allocation to the
+// histogram exported by the metrics
+func (c *Client) RecordAllocationLatency(ctx context.Context, latency time.Duration) error {
+	seconds := latency.Seconds()
+	pipe := c.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, types.RedisKeyAllocationLatency, "count", 1)
+	pipe.HIncrByFloat(ctx, types.RedisKeyAllocationLatency, "sum", seconds)
+	for _, bound := range types.AllocationLatencyBuckets {
+		if seconds <= bound {
+			pipe.HIncrBy(ctx, types.RedisKeyAllocationLatency, latencyBucketField(bound), 1)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetAllocationLatencyStats returns the histogram of allocation latencies
+func (c *Client) GetAllocationLatencyStats(ctx context.Context) (*types.AllocationLatencyStats, error) {
+	values, err := c.rdbRead.HGetAll(ctx, types.RedisKeyAllocationLatency).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &types.AllocationLatencyStats{Buckets: make([]int64, len(types.AllocationLatencyBuckets))}
+	stats.Count, _ = strconv.ParseInt(values["count"], 10, 64)
+	stats.Sum, _ = strconv.ParseFloat(values["sum"], 64)
+	for i, bound := range types.AllocationLatencyBuckets {
+		stats.Buckets[i], _ = strconv.ParseInt(values[latencyBucketField(bound)], 10, 64)
+	}
+	return stats, nil
+}
+
+// latencyBucketField is the hash field counting allocations at or below bound
+// seconds
+func latencyBucketField(bound float64) string {
+	return "le_" + strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
 // Queue Management Operations
 
-// AddToQueue adds a new entry to the queue
+// queuePriorityOffset separates the queue scores of priority tiers. It is far
+// larger than any enqueue time in nanoseconds, so a higher-priority entry
+// always scores below a lower-priority one.
+const queuePriorityOffset = 1e19
+
+// queueScore returns the queue sorted set score of an entry, which orders the
+// queue by priority and then by enqueue time. Normal priority scores are plain
+// enqueue times, matching entries queued before priorities affected ordering.
+// Entries already holding some of their GPUs go ahead of every priority: only
+// the first entry allocates, so one displaced from the head would keep its
+// GPUs without getting the rest.
+func queueScore(entry *types.QueueEntry) float64 {
+	tier := types.PriorityRank(types.PriorityNormal) - types.PriorityRank(entry.Priority)
+	if len(entry.AllocatedGPUs) > 0 {
+		tier = types.PriorityRank(types.PriorityNormal) - types.PriorityRank(types.PriorityHigh) - 1
+	}
+	return float64(tier)*queuePriorityOffset + float64(entry.EnqueueTime.ToTime().UnixNano())
+}
+
+// AddToQueue adds a new entry to the queue. It is rejected if the queue
+// already holds max_queue_length entries, or its user already has
+// max_queue_entries_per_user of them; users are matched on their OS account
+// so that --user doesn't get around the limit.
 func (c *Client) AddToQueue(ctx context.Context, entry *types.QueueEntry) error {
-	// Store entry details
-	entryKey := types.RedisKeyQueueEntry + entry.ID
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal queue entry: %v", err)
 	}
 
-	// Use a transaction to add both the sorted set entry and the details atomically
-	pipe := c.rdb.TxPipeline()
+	// Check the limits and add the entry atomically, ordered by priority and
+	// then enqueue time, with its details stored separately
+	luaScript := `
+		local queue_key = KEYS[1]
+		local entry_prefix = ARGV[1]
+		local entry_id = ARGV[2]
+		local score = ARGV[3]
+		local data = ARGV[4]
+		local user = ARGV[5]
+		local max_length = tonumber(ARGV[6])
+		local max_per_user = tonumber(ARGV[7])
+
+		local ids = redis.call('ZRANGE', queue_key, 0, -1)
+		if max_length > 0 and #ids >= max_length then
+			return {'length', #ids}
+		end
 
-	// Add to sorted set with enqueue timestamp as score
-	score := float64(entry.EnqueueTime.ToTime().UnixNano())
-	pipe.ZAdd(ctx, types.RedisKeyQueue, &redis.Z{
-		Score:  score,
-		Member: entry.ID,
-	})
+		if max_per_user > 0 then
+			local count = 0
+			for _, id in ipairs(ids) do
+				local entry_data = redis.call('GET', entry_prefix .. id)
+				if entry_data then
+					local other = cjson.decode(entry_data)
+					local other_user = other.actual_user
+					if not other_user or other_user == '' then
+						other_user = other.user
+					end
+					if other_user == user then
+						count = count + 1
+					end
+				end
+			end
+			if count >= max_per_user then
+				return {'user', count}
+			end
+		end
 
-	// Store entry details
-	pipe.Set(ctx, entryKey, data, 0)
+		redis.call('ZADD', queue_key, score, entry_id)
+		redis.call('SET', entry_prefix .. entry_id, data)
+		return {'ok', #ids + 1}
+	`
 
-	_, err = pipe.Exec(ctx)
+	user := entry.ActualUser
+	if user == "" {
+		user = entry.User
+	}
+
+	result, err := c.rdb.Eval(ctx, luaScript, []string{types.RedisKeyQueue},
+		types.RedisKeyQueueEntry,
+		entry.ID,
+		strconv.FormatFloat(queueScore(entry), 'f', -1, 64),
+		string(data),
+		user,
+		c.config.MaxQueueLength,
+		c.config.MaxQueueEntriesPerUser,
+	).Slice()
 	if err != nil {
 		return fmt.Errorf("failed to add to queue: %v", err)
 	}
+	if len(result) != 2 {
+		return fmt.Errorf("unexpected result from queue script: %v", result)
+	}
 
+	switch result[0] {
+	case "length":
+		return fmt.Errorf("the queue is full: it already has %v entries (max_queue_length is %d)",
+			result[1], c.config.MaxQueueLength)
+	case "user":
+		return fmt.Errorf("user '%s' already has %v entries in the queue (max_queue_entries_per_user is %d)",
+			user, result[1], c.config.MaxQueueEntriesPerUser)
+	}
 	return nil
 }
 
@@ -850,7 +1638,13 @@ func (c *Client) UpdateQueueEntry(ctx context.Context, entry *types.QueueEntry)
 		return fmt.Errorf("failed to marshal queue entry: %v", err)
 	}
 
-	return c.rdb.Set(ctx, entryKey, data, 0).Err()
+	// Re-score the entry, which moves it to the head of the queue once it
+	// holds some of its GPUs. XX leaves entries that were removed alone.
+	pipe := c.rdb.TxPipeline()
+	pipe.Set(ctx, entryKey, data, 0)
+	pipe.ZAddXX(ctx, types.RedisKeyQueue, &redis.Z{Score: queueScore(entry), Member: entry.ID})
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 // UpdateQueueEntryHeartbeat updates the heartbeat timestamp for a queue entry
@@ -867,7 +1661,8 @@ func (c *Client) UpdateQueueEntryHeartbeat(ctx context.Context, queueID string)
 	return c.UpdateQueueEntry(ctx, entry)
 }
 
-// GetAllQueueEntries returns all queue entries in order (oldest first)
+// GetAllQueueEntries returns all queue entries in order: higher priority
+// first, then oldest first
 func (c *Client) GetAllQueueEntries(ctx context.Context) ([]*types.QueueEntry, error) {
 	// Get all queue IDs in order
 	queueIDs, err := c.rdb.ZRange(ctx, types.RedisKeyQueue, 0, -1).Result()
@@ -889,6 +1684,21 @@ func (c *Client) GetAllQueueEntries(ctx context.Context) ([]*types.QueueEntry, e
 	return entries, nil
 }
 
+// ClearQueue removes all entries from the queue
+func (c *Client) ClearQueue(ctx context.Context) error {
+	queueIDs, err := c.rdb.ZRange(ctx, types.RedisKeyQueue, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get queue IDs: %v", err)
+	}
+
+	keys := []string{types.RedisKeyQueue}
+	for _, queueID := range queueIDs {
+		keys = append(keys, types.RedisKeyQueueEntry+queueID)
+	}
+
+	return c.rdb.Del(ctx, keys...).Err()
+}
+
 // GetQueuePosition returns the 0-based position of an entry in the queue
 // Returns -1 if not found
 func (c *Client) GetQueuePosition(ctx context.Context, queueID string) (int, error) {
@@ -912,7 +1722,8 @@ func (c *Client) GetQueueLength(ctx context.Context) (int, error) {
 	return int(count), nil
 }
 
-// IsFirstInQueue checks if the given entry is first in the queue
+// IsFirstInQueue checks if the given entry is first in the queue, i.e. the
+// oldest entry of the highest priority waiting
 func (c *Client) IsFirstInQueue(ctx context.Context, queueID string) (bool, error) {
 	// Get the first entry in the queue
 	firstEntries, err := c.rdb.ZRange(ctx, types.RedisKeyQueue, 0, 0).Result()
@@ -968,14 +1779,16 @@ func (c *Client) CleanupStaleQueueEntries(ctx context.Context) ([]string, error)
 
 // GetQueueStatus returns the current queue status for display
 func (c *Client) GetQueueStatus(ctx context.Context) (*types.QueueStatus, error) {
-	entries, err := c.GetAllQueueEntries(ctx)
+	entries, err := c.ReadOnly().GetAllQueueEntries(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	status := &types.QueueStatus{
-		Entries:      entries,
-		TotalWaiting: len(entries),
+		Entries:                entries,
+		TotalWaiting:           len(entries),
+		MaxQueueLength:         c.config.MaxQueueLength,
+		MaxQueueEntriesPerUser: c.config.MaxQueueEntriesPerUser,
 	}
 
 	for _, entry := range entries {
@@ -985,3 +1798,44 @@ func (c *Client) GetQueueStatus(ctx context.Context) (*types.QueueStatus, error)
 
 	return status, nil
 }
+
+// SetScheduledReservation adds or updates a scheduled reservation
+func (c *Client) SetScheduledReservation(ctx context.Context, schedule *types.ScheduledReservation) error {
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled reservation: %v", err)
+	}
+	return c.rdb.HSet(ctx, types.RedisKeySchedule, schedule.ID, data).Err()
+}
+
+// GetScheduledReservations returns all scheduled reservations, earliest start
+// first
+func (c *Client) GetScheduledReservations(ctx context.Context) ([]*types.ScheduledReservation, error) {
+	values, err := c.rdb.HGetAll(ctx, types.RedisKeySchedule).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled reservations: %v", err)
+	}
+
+	schedules := make([]*types.ScheduledReservation, 0, len(values))
+	for id, data := range values {
+		var schedule types.ScheduledReservation
+		if err := json.Unmarshal([]byte(data), &schedule); err != nil {
+			return nil, fmt.Errorf("corrupted scheduled reservation %s: %v", id, err)
+		}
+		schedules = append(schedules, &schedule)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].StartTime.Equal(schedules[j].StartTime.Time) {
+			return schedules[i].StartTime.Before(schedules[j].StartTime.Time)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules, nil
+}
+
+// RemoveScheduledReservation deletes a scheduled reservation. It is not an
+// error if it doesn't exist.
+func (c *Client) RemoveScheduledReservation(ctx context.Context, id string) error {
+	return c.rdb.HDel(ctx, types.RedisKeySchedule, id).Err()
+}
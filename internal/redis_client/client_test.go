@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/russellb/canhazgpu/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -127,6 +128,23 @@ func TestClient_GPUState(t *testing.T) {
 	assert.True(t, retrievedState.LastReleased.IsZero())
 }
 
+func TestClient_SwapBootID(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	previous, err := client.SwapBootID(ctx, "boot-1")
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	previous, err = client.SwapBootID(ctx, "boot-1")
+	require.NoError(t, err)
+	assert.Equal(t, "boot-1", previous)
+
+	previous, err = client.SwapBootID(ctx, "boot-2")
+	require.NoError(t, err)
+	assert.Equal(t, "boot-1", previous)
+}
+
 func TestClient_AllocationLock(t *testing.T) {
 	client := setupTestRedis(t)
 	ctx := context.Background()
@@ -233,6 +251,40 @@ func TestClient_AtomicReserveGPUs_SimpleCase(t *testing.T) {
 	}
 }
 
+func TestClient_AtomicReserveGPUs_JobID(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	// GPUs reserved by one request share a job ID
+	allocated, err := client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUCount:        2,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}, []int{})
+	require.NoError(t, err)
+	require.Len(t, allocated, 2)
+
+	first, err := client.GetGPUState(ctx, allocated[0])
+	require.NoError(t, err)
+	second, err := client.GetGPUState(ctx, allocated[1])
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.JobID)
+	assert.Equal(t, first.JobID, second.JobID)
+
+	// Another request gets its own
+	other, err := client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUIDs:          []int{3},
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}, []int{})
+	require.NoError(t, err)
+	state, err := client.GetGPUState(ctx, other[0])
+	require.NoError(t, err)
+	assert.NotEmpty(t, state.JobID)
+	assert.NotEqual(t, first.JobID, state.JobID)
+}
+
 func TestClient_AtomicReserveGPUs_WithUnreserved(t *testing.T) {
 	client := setupTestRedis(t)
 	ctx := context.Background()
@@ -345,6 +397,50 @@ func TestClient_ClearAllGPUStates(t *testing.T) {
 	}
 }
 
+func TestClient_ClearUsageHistory(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.RecordUsageHistory(ctx, &types.UsageRecord{
+			User:            "testuser",
+			GPUID:           i,
+			StartTime:       types.FlexibleTime{Time: time.Now().Add(-time.Hour)},
+			EndTime:         types.FlexibleTime{Time: time.Now()},
+			ReservationType: types.ReservationTypeRun,
+		}))
+	}
+	legacyKey := types.RedisKeyUsageHistory + "1700000000:testuser:0"
+	require.NoError(t, client.rdb.Set(ctx, legacyKey, "{}", 0).Err())
+
+	// Neither GPU states nor other prefixes' keys are touched
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{User: "testuser", Type: types.ReservationTypeManual}))
+	require.NoError(t, client.rdb.Set(ctx, "other:usage_history:1", "{}", 0).Err())
+
+	count, err := client.CountUsageHistory(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	deleted, err := client.ClearUsageHistory(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, deleted)
+
+	count, err = client.CountUsageHistory(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	state, err := client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", state.User)
+	exists, err := client.rdb.Exists(ctx, "other:usage_history:1").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), exists)
+
+	deleted, err = client.ClearUsageHistory(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
 func TestClient_NewClient(t *testing.T) {
 	config := &types.Config{
 		RedisHost: "localhost",
@@ -360,6 +456,206 @@ func TestClient_NewClient(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestLockSettings(t *testing.T) {
+	timeout, retries := lockSettings(&types.Config{})
+	assert.Equal(t, types.LockTimeout, timeout)
+	assert.Equal(t, types.MaxLockRetries, retries)
+
+	timeout, retries = lockSettings(&types.Config{LockTimeout: 30 * time.Second, LockMaxRetries: 8})
+	assert.Equal(t, 30*time.Second, timeout)
+	assert.Equal(t, 8, retries)
+}
+
+func TestRenewDuration(t *testing.T) {
+	now := time.Now()
+	expiry := now.Add(15 * time.Minute)
+
+	assert.Equal(t, int64(900), renewDuration(&types.AllocationRequest{ExpiryTime: &expiry, Renewable: true}, now.Unix()))
+	assert.Zero(t, renewDuration(&types.AllocationRequest{ExpiryTime: &expiry}, now.Unix()))
+	assert.Zero(t, renewDuration(&types.AllocationRequest{Renewable: true}, now.Unix()))
+}
+
+func TestGPUListJSON(t *testing.T) {
+	data, err := gpuListJSON(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+
+	data, err = gpuListJSON([]int{1, 3})
+	require.NoError(t, err)
+	assert.Equal(t, "[1,3]", string(data))
+}
+
+func TestClient_AtomicReserveGPUs_Renewable(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, client.SetGPUCount(ctx, 2))
+
+	expiry := time.Now().Add(15 * time.Minute)
+	request := &types.AllocationRequest{
+		GPUIDs:          []int{1},
+		User:            "testuser",
+		ActualUser:      "testuser",
+		ReservationType: types.ReservationTypeManual,
+		ExpiryTime:      &expiry,
+		Renewable:       true,
+	}
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, allocated)
+
+	state, err := client.GetGPUState(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, state.IsRenewable())
+	assert.InDelta(t, 900, state.RenewDuration, 2)
+}
+
+func TestQueueScore(t *testing.T) {
+	now := time.Now()
+	entry := func(priority string, enqueued time.Time) *types.QueueEntry {
+		return &types.QueueEntry{Priority: priority, EnqueueTime: types.FlexibleTime{Time: enqueued}}
+	}
+
+	// Normal priority keeps the enqueue time score used before priority tiers
+	assert.Equal(t, float64(now.UnixNano()), queueScore(entry("", now)))
+	assert.Equal(t, queueScore(entry("", now)), queueScore(entry(types.PriorityNormal, now)))
+
+	// A later high-priority entry goes ahead of an earlier normal one, and a
+	// later normal entry ahead of an earlier low one
+	earlier := now.Add(-24 * time.Hour)
+	assert.Less(t, queueScore(entry(types.PriorityHigh, now)), queueScore(entry(types.PriorityNormal, earlier)))
+	assert.Less(t, queueScore(entry(types.PriorityNormal, now)), queueScore(entry(types.PriorityLow, earlier)))
+
+	// Within a tier the oldest entry goes first
+	assert.Less(t, queueScore(entry(types.PriorityHigh, earlier)), queueScore(entry(types.PriorityHigh, now)))
+	assert.Less(t, queueScore(entry(types.PriorityLow, earlier)), queueScore(entry(types.PriorityLow, now)))
+
+	// An entry holding some of its GPUs stays ahead of a later high-priority
+	// entry
+	started := entry(types.PriorityLow, earlier)
+	started.AllocatedGPUs = []int{0}
+	assert.Less(t, queueScore(started), queueScore(entry(types.PriorityHigh, earlier)))
+}
+
+func TestClient_GPUKey(t *testing.T) {
+	client := NewClient(&types.Config{RedisHost: "localhost", RedisPort: 6379})
+	defer func() {
+		_ = client.Close()
+	}()
+
+	key, err := client.gpuKey(2)
+	require.NoError(t, err)
+	assert.Equal(t, "canhazgpu:gpu:2", key)
+
+	// With UUIDs, state follows the physical GPU rather than its index
+	client.config.UseGPUUUIDs = true
+	client.config.GPUUUIDs = []string{"GPU-aaaa", "GPU-bbbb"}
+	key, err = client.gpuKey(1)
+	require.NoError(t, err)
+	assert.Equal(t, "canhazgpu:gpu:GPU-bbbb", key)
+
+	_, err = client.gpuKey(2)
+	assert.ErrorContains(t, err, "no UUID known for GPU 2")
+
+	keys, err := client.gpuKeysJSON(2)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["canhazgpu:gpu:GPU-aaaa", "canhazgpu:gpu:GPU-bbbb"]`, keys)
+}
+
+func TestClient_AtomicReserveGPUs_UUIDs(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 2))
+	client.config.UseGPUUUIDs = true
+	client.config.GPUUUIDs = []string{"GPU-aaaa", "GPU-bbbb"}
+
+	allocated, err := client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUIDs:          []int{1},
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, allocated)
+
+	exists, err := client.rdb.Exists(ctx, "canhazgpu:gpu:GPU-bbbb").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), exists)
+
+	// After the indices swap, the reservation is found at the GPU's new index
+	client.config.GPUUUIDs = []string{"GPU-bbbb", "GPU-aaaa"}
+	state, err := client.GetGPUState(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", state.User)
+
+	allocated, err = client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUCount:        1,
+		User:            "other",
+		ReservationType: types.ReservationTypeRun,
+	}, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, allocated)
+}
+
+func TestClient_NewClient_ReadReplica(t *testing.T) {
+	// Without a read host, reads share the primary connection
+	client := NewClient(&types.Config{
+		RedisHost: "localhost",
+		RedisPort: 6379,
+		RedisDB:   15,
+	})
+	assert.Same(t, client.rdb, client.rdbRead)
+	assert.False(t, client.hasReadReplica())
+	assert.Same(t, client.rdb, client.ReadOnly().rdb)
+	assert.NoError(t, client.Close())
+
+	// With a read host, reads go to a separate connection
+	client = NewClient(&types.Config{
+		RedisHost:     "localhost",
+		RedisPort:     6379,
+		RedisDB:       15,
+		RedisReadHost: "replica.example.com",
+	})
+	assert.NotSame(t, client.rdb, client.rdbRead)
+	assert.True(t, client.hasReadReplica())
+	assert.Equal(t, "replica.example.com:6379", client.rdbRead.Options().Addr)
+	assert.Same(t, client.rdbRead, client.ReadOnly().rdb)
+	assert.NoError(t, client.Close())
+
+	// An explicit read port overrides the primary port
+	client = NewClient(&types.Config{
+		RedisHost:     "localhost",
+		RedisPort:     6379,
+		RedisReadHost: "replica.example.com",
+		RedisReadPort: 6380,
+	})
+	assert.Equal(t, "replica.example.com:6380", client.rdbRead.Options().Addr)
+	assert.NoError(t, client.Close())
+}
+
+func TestNewClientTimeouts(t *testing.T) {
+	// Unset timeouts use the default
+	client := NewClient(&types.Config{RedisHost: "localhost", RedisPort: 6379, RedisReadTimeout: 10 * time.Second})
+	options := client.rdb.Options()
+	assert.Equal(t, types.RedisTimeout, options.DialTimeout)
+	assert.Equal(t, 10*time.Second, options.ReadTimeout)
+	assert.Equal(t, types.RedisTimeout, options.WriteTimeout)
+	assert.NoError(t, client.Close())
+
+	// The read replica uses the same timeouts
+	client = NewClient(&types.Config{
+		RedisHost:         "localhost",
+		RedisPort:         6379,
+		RedisReadHost:     "replica.example.com",
+		RedisDialTimeout:  time.Second,
+		RedisWriteTimeout: 2 * time.Second,
+	})
+	options = client.rdbRead.Options()
+	assert.Equal(t, time.Second, options.DialTimeout)
+	assert.Equal(t, types.RedisTimeout, options.ReadTimeout)
+	assert.Equal(t, 2*time.Second, options.WriteTimeout)
+	assert.NoError(t, client.Close())
+}
+
 func TestClient_AtomicReserveSpecificGPUs(t *testing.T) {
 	client := setupTestRedis(t)
 	ctx := context.Background()
@@ -583,6 +879,128 @@ func TestClient_RecordUsageHistory_NewFormat(t *testing.T) {
 	assert.Equal(t, int64(0), exists)
 }
 
+func TestClient_RecordUsageHistory_Host(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	endTime := time.Now().Add(-time.Hour)
+	record := &types.UsageRecord{
+		User:            "testuser",
+		GPUID:           0,
+		StartTime:       types.FlexibleTime{Time: endTime.Add(-time.Hour)},
+		EndTime:         types.FlexibleTime{Time: endTime},
+		Duration:        3600.0,
+		ReservationType: types.ReservationTypeRun,
+	}
+	require.NoError(t, client.RecordUsageHistory(ctx, record))
+	assert.Empty(t, record.Host, "the caller's record is left unchanged")
+
+	// Records that already name a host keep it
+	imported := *record
+	imported.GPUID = 1
+	imported.Host = "gpu-server-2"
+	require.NoError(t, client.RecordUsageHistory(ctx, &imported))
+
+	records, err := client.GetUsageHistory(ctx, endTime.Add(-time.Minute), endTime.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	hosts := map[int]string{}
+	for _, r := range records {
+		hosts[r.GPUID] = r.Host
+	}
+	assert.Equal(t, utils.Hostname(), hosts[0])
+	assert.Equal(t, "gpu-server-2", hosts[1])
+}
+
+func TestClient_RecordUsageHistory_GPUModel(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	model, err := client.GetGPUModel(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, model)
+
+	require.NoError(t, client.SetGPUModels(ctx, map[int]string{0: "H100 80GB HBM3", 1: "A100-SXM4-40GB"}))
+	model, err = client.GetGPUModel(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "A100-SXM4-40GB", model)
+
+	endTime := time.Now().Add(-time.Hour)
+	for gpuID := 0; gpuID < 3; gpuID++ {
+		require.NoError(t, client.RecordUsageHistory(ctx, &types.UsageRecord{
+			User:            "testuser",
+			GPUID:           gpuID,
+			StartTime:       types.FlexibleTime{Time: endTime.Add(-time.Hour)},
+			EndTime:         types.FlexibleTime{Time: endTime},
+			Duration:        3600.0,
+			ReservationType: types.ReservationTypeRun,
+		}))
+	}
+
+	records, err := client.GetUsageHistory(ctx, endTime.Add(-time.Minute), endTime.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	models := map[int]string{}
+	for _, r := range records {
+		models[r.GPUID] = r.GPUModel
+	}
+	assert.Equal(t, map[int]string{0: "H100 80GB HBM3", 1: "A100-SXM4-40GB", 2: ""}, models)
+}
+
+func TestClient_UsageHistoryDisabled(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 3))
+	now := time.Now()
+	releaseGPUs := func() {
+		for gpuID, ago := range []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour} {
+			require.NoError(t, client.SetGPUState(ctx, gpuID, &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-ago)}}))
+		}
+	}
+	releaseGPUs()
+
+	record := func(gpuID int) *types.UsageRecord {
+		return &types.UsageRecord{
+			User:            "testuser",
+			GPUID:           gpuID,
+			StartTime:       types.FlexibleTime{Time: now.Add(-2 * time.Hour)},
+			EndTime:         types.FlexibleTime{Time: now.Add(-time.Hour)},
+			Duration:        3600.0,
+			ReservationType: types.ReservationTypeRun,
+		}
+	}
+	require.NoError(t, client.RecordUsageHistory(ctx, record(2)))
+
+	request := &types.AllocationRequest{
+		GPUCount:        1,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}
+
+	// The GPU the user used most recently is preferred
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, allocated)
+	releaseGPUs()
+
+	// Without history, nothing more is recorded and earlier records are
+	// ignored, so the least recently released GPU is picked
+	client.config.DisableUsageHistory = true
+	require.NoError(t, client.RecordUsageHistory(ctx, record(1)))
+
+	records, err := client.GetUsageHistory(ctx, now.Add(-3*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 2, records[0].GPUID)
+
+	allocated, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, allocated)
+}
+
 func TestClient_GetUsageHistory_NewFormat(t *testing.T) {
 	client := setupTestRedis(t)
 	ctx := context.Background()
@@ -957,3 +1375,322 @@ func TestClient_ProviderExplicitSet(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "nvidia", provider)
 }
+
+func TestClient_DaemonLock(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	// First daemon acquires the lock
+	acquired, err := client.AcquireDaemonLock(ctx, "host-a:1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	owner, err := client.GetDaemonLockOwner(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "host-a:1", owner)
+
+	// A second daemon cannot acquire or refresh it
+	acquired, err = client.AcquireDaemonLock(ctx, "host-b:2", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	held, err := client.RefreshDaemonLock(ctx, "host-b:2", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, held)
+
+	// Releasing with the wrong owner leaves the lock in place
+	require.NoError(t, client.ReleaseDaemonLock(ctx, "host-b:2"))
+	held, err = client.RefreshDaemonLock(ctx, "host-a:1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, held)
+
+	// After the owner releases it, another daemon can take over
+	require.NoError(t, client.ReleaseDaemonLock(ctx, "host-a:1"))
+	owner, err = client.GetDaemonLockOwner(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "", owner)
+
+	acquired, err = client.AcquireDaemonLock(ctx, "host-b:2", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestClient_AtomicReserveGPUs_Cooldown(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	// GPUs 0 and 2 were just released and GPU 1 a while ago. The allocation
+	// engine works out which GPUs are cooling down.
+	now := time.Now()
+	require.NoError(t, client.SetGPUState(ctx, 0, &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-5 * time.Second)}}))
+	require.NoError(t, client.SetGPUState(ctx, 1, &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-time.Hour)}}))
+	require.NoError(t, client.SetGPUState(ctx, 2, &types.GPUState{LastReleased: types.FlexibleTime{Time: now.Add(-5 * time.Second)}}))
+	require.NoError(t, client.SetGPUState(ctx, 3, &types.GPUState{User: "bob", Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: now.Add(time.Hour)}}))
+
+	releasedAt := map[int]int64{0: now.Add(-5 * time.Second).Unix(), 1: now.Add(-time.Hour).Unix(), 2: now.Add(-5 * time.Second).Unix()}
+	request := &types.AllocationRequest{
+		GPUCount:        2,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+		CoolingDownGPUs: []int{0, 2},
+		ReleasedAt:      releasedAt,
+	}
+
+	// GPUs released within the cooldown window are skipped
+	_, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	assert.EqualError(t, err, "Not enough GPUs available")
+
+	// Specific GPU IDs in their cooldown are refused with an explanation
+	_, err = client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUIDs:          []int{2},
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+		CoolingDownGPUs: []int{0, 2},
+		ReleasedAt:      releasedAt,
+	}, []int{})
+	assert.ErrorContains(t, err, "GPU 2 was released")
+	assert.ErrorContains(t, err, "cooling down")
+
+	request.GPUCount = 1
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, allocated)
+
+	// Without a cooldown, just-released GPUs are allocated right away
+	request.GPUCount = 2
+	request.CoolingDownGPUs = nil
+	allocated, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{0, 2}, allocated)
+}
+
+func TestClient_AtomicReserveGPUs_MinFreeDuration(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	// Bob's reservation on GPU 1 expires in 10 minutes. The allocation engine
+	// works out when the manual reservations expire.
+	expiry := time.Now().Add(10 * time.Minute)
+	require.NoError(t, client.SetGPUState(ctx, 1, &types.GPUState{User: "bob", Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: expiry}}))
+
+	request := &types.AllocationRequest{
+		GPUCount:        1,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+		MinFreeDuration: time.Hour,
+		ExpiresAt:       map[int]int64{1: expiry.Unix()},
+	}
+
+	// GPUs 0 and 2 are next to the expiring reservation and are skipped
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3}, allocated)
+
+	_, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	assert.EqualError(t, err, "Not enough GPUs available")
+
+	trace, err := client.GetAllocationTrace(ctx, "testuser")
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.ElementsMatch(t, []int{0, 2}, trace.NearExpiring)
+
+	// A minimum shorter than the time left on the reservation allows them
+	request.MinFreeDuration = 5 * time.Minute
+	request.GPUCount = 2
+	allocated, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{0, 2}, allocated)
+}
+
+func TestClient_AllocationTrace(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+	require.NoError(t, client.SetGPUState(ctx, 2, &types.GPUState{User: "bob", Type: types.ReservationTypeManual, ExpiryTime: types.FlexibleTime{Time: time.Now().Add(time.Hour)}}))
+
+	trace, err := client.GetAllocationTrace(ctx, "testuser")
+	require.NoError(t, err)
+	assert.Nil(t, trace)
+
+	request := &types.AllocationRequest{
+		GPUCount:        2,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{1})
+	require.NoError(t, err)
+
+	trace, err = client.GetAllocationTrace(ctx, "testuser")
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, "testuser", trace.User)
+	assert.Equal(t, 2, trace.Requested)
+	assert.Equal(t, []int{1}, trace.Unreserved)
+	assert.Equal(t, []int{2}, trace.Reserved)
+	assert.Empty(t, trace.CoolingDown)
+	require.Len(t, trace.Candidates, 2)
+	assert.ElementsMatch(t, []int{0, 3}, allocated)
+	assert.ElementsMatch(t, allocated, trace.Allocated)
+	assert.Empty(t, trace.Error)
+
+	// Failed requests for specific GPUs are traced too
+	_, err = client.AtomicReserveGPUs(ctx, &types.AllocationRequest{
+		GPUIDs:          []int{2},
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+	}, []int{})
+	require.Error(t, err)
+
+	trace, err = client.GetAllocationTrace(ctx, "testuser")
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, []int{2}, trace.RequestedIDs)
+	assert.Empty(t, trace.Allocated)
+	assert.Contains(t, trace.Error, "already reserved by user 'bob'")
+}
+
+func TestClient_UnreservedSamples(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	samples, err := client.GetUnreservedSamples(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, samples)
+
+	now := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, client.UpdateUnreservedSamples(ctx, map[int]time.Time{0: now, 2: now}, nil, time.Minute))
+
+	samples, err = client.GetUnreservedSamples(ctx)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.True(t, samples[0].Equal(now))
+	assert.True(t, samples[2].Equal(now))
+
+	require.NoError(t, client.UpdateUnreservedSamples(ctx, nil, []int{0}, time.Minute))
+
+	samples, err = client.GetUnreservedSamples(ctx)
+	require.NoError(t, err)
+	assert.Len(t, samples, 1)
+	assert.Contains(t, samples, 2)
+}
+
+func TestClient_HeartbeatTimeout(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	timeout, err := client.GetHeartbeatTimeout(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, types.HeartbeatTimeout, timeout)
+
+	require.NoError(t, client.SetPoolSetting(ctx, types.SettingHeartbeatTimeout, "900"))
+	timeout, err = client.GetHeartbeatTimeout(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, timeout)
+
+	settings, err := client.GetPoolSettings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{types.SettingHeartbeatTimeout: "900"}, settings)
+
+	// Invalid values fall back to the default
+	require.NoError(t, client.SetPoolSetting(ctx, types.SettingHeartbeatTimeout, "10"))
+	timeout, err = client.GetHeartbeatTimeout(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, types.HeartbeatTimeout, timeout)
+
+	require.NoError(t, client.UnsetPoolSetting(ctx, types.SettingHeartbeatTimeout))
+	timeout, err = client.GetHeartbeatTimeout(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, types.HeartbeatTimeout, timeout)
+}
+
+func TestClient_AtomicReserveGPUs_GPUClass(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	request := &types.AllocationRequest{
+		GPUCount:          2,
+		User:              "testuser",
+		ReservationType:   types.ReservationTypeRun,
+		GPUClass:          types.GPUClassLarge,
+		ClassExcludedGPUs: []int{0, 1},
+	}
+
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{2, 3}, allocated)
+
+	trace, err := client.GetAllocationTrace(ctx, "testuser")
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, []int{0, 1}, trace.OtherClass)
+	assert.Equal(t, types.GPUClassLarge, trace.GPUClass)
+
+	// The GPUs outside the class are never allocated, even when free
+	request.User = "otheruser"
+	request.GPUCount = 1
+	_, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	assert.EqualError(t, err, "Not enough GPUs available")
+}
+
+func TestClient_AtomicReserveGPUs_Command(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.SetGPUCount(ctx, 4))
+
+	// By count
+	request := &types.AllocationRequest{
+		GPUCount:        1,
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+		Command:         "python train.py --token=***",
+	}
+	allocated, err := client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+	require.Len(t, allocated, 1)
+
+	state, err := client.GetGPUState(ctx, allocated[0])
+	require.NoError(t, err)
+	assert.Equal(t, "python train.py --token=***", state.Command)
+
+	// By ID
+	request = &types.AllocationRequest{
+		GPUIDs:          []int{3},
+		User:            "testuser",
+		ReservationType: types.ReservationTypeRun,
+		Command:         "vllm serve my/model",
+	}
+	_, err = client.AtomicReserveGPUs(ctx, request, []int{})
+	require.NoError(t, err)
+
+	state, err = client.GetGPUState(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "vllm serve my/model", state.Command)
+}
+
+func TestClient_AllocationLatency(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	stats, err := client.GetAllocationLatencyStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Count)
+	assert.Len(t, stats.Buckets, len(types.AllocationLatencyBuckets))
+
+	require.NoError(t, client.RecordAllocationLatency(ctx, 80*time.Millisecond))
+	require.NoError(t, client.RecordAllocationLatency(ctx, 3*time.Second))
+	require.NoError(t, client.RecordAllocationLatency(ctx, time.Minute))
+
+	stats, err = client.GetAllocationLatencyStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Count)
+	assert.InDelta(t, 63.08, stats.Sum, 0.001)
+	assert.Equal(t, []int64{0, 1, 1, 1, 1, 1, 2, 2}, stats.Buckets)
+}
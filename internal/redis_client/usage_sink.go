@@ -0,0 +1,143 @@
+package redis_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/russellb/canhazgpu/internal/types"
+)
+
+// usageSinkFlushBatch is the most buffered records resent after a successful
+// send, so that a large backlog doesn't hold up a single release
+const usageSinkFlushBatch = 100
+
+// UsageSink receives usage records as they are recorded, for long-term
+// storage outside Redis
+type UsageSink interface {
+	Send(ctx context.Context, record *types.UsageRecord) error
+}
+
+// HTTPUsageSink POSTs each usage record as JSON to an HTTP endpoint
+type HTTPUsageSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewHTTPUsageSink creates a sink for the endpoint in config
+func NewHTTPUsageSink(config types.UsageSinkConfig) *HTTPUsageSink {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = types.UsageSinkTimeout
+	}
+	return &HTTPUsageSink{
+		url:     config.URL,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Send POSTs the record, treating any non-2xx response as a failure
+func (s *HTTPUsageSink) Send(ctx context.Context, record *types.UsageRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// SetUsageSink replaces the sink usage records are forwarded to (nil disables
+// forwarding)
+func (c *Client) SetUsageSink(sink UsageSink) {
+	c.usageSink = sink
+}
+
+// forwardUsageRecord sends a usage record to the usage sink. A failure never
+// fails the release that recorded it: it is logged, and the record is kept in
+// Redis for a later attempt if buffering is enabled. Once a send succeeds,
+// buffered records are resent.
+func (c *Client) forwardUsageRecord(ctx context.Context, record *types.UsageRecord, data []byte) {
+	if c.usageSink == nil {
+		return
+	}
+
+	if err := c.usageSink.Send(ctx, record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to send usage record to usage sink: %v\n", err)
+		if c.config.UsageSink.Buffer {
+			c.bufferUsageRecord(ctx, data)
+		}
+		return
+	}
+
+	if c.config.UsageSink.Buffer {
+		c.flushUsageSinkBuffer(ctx)
+	}
+}
+
+// bufferUsageRecord keeps a record that couldn't be sent, dropping the oldest
+// records beyond UsageSinkBufferLimit
+func (c *Client) bufferUsageRecord(ctx context.Context, data []byte) {
+	pipe := c.rdb.TxPipeline()
+	pipe.RPush(ctx, types.RedisKeyUsageSinkBuffer, string(data))
+	pipe.LTrim(ctx, types.RedisKeyUsageSinkBuffer, -types.UsageSinkBufferLimit, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to buffer usage record: %v\n", err)
+	}
+}
+
+// flushUsageSinkBuffer resends buffered records, oldest first, stopping at the
+// first failure. A record is removed from the buffer before it is sent so
+// that concurrent flushes don't send it twice, and put back if the send fails.
+func (c *Client) flushUsageSinkBuffer(ctx context.Context) {
+	for i := 0; i < usageSinkFlushBatch; i++ {
+		data, err := c.rdb.LPop(ctx, types.RedisKeyUsageSinkBuffer).Result()
+		if err != nil {
+			if err != redis.Nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to read usage sink buffer: %v\n", err)
+			}
+			return
+		}
+
+		var record types.UsageRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: dropping invalid buffered usage record: %v\n", err)
+			continue
+		}
+
+		if err := c.usageSink.Send(ctx, &record); err != nil {
+			if err := c.rdb.LPush(ctx, types.RedisKeyUsageSinkBuffer, data).Err(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to buffer usage record: %v\n", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package redis_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUsageSink(t *testing.T) {
+	var received []types.UsageRecord
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var record types.UsageRecord
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&record))
+		received = append(received, record)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPUsageSink(types.UsageSinkConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	record := &types.UsageRecord{User: "alice", GPUID: 2, Duration: 60, ReservationType: types.ReservationTypeRun, Host: "gpu-server-1"}
+
+	require.NoError(t, sink.Send(context.Background(), record))
+	require.Len(t, received, 1)
+	assert.Equal(t, "alice", received[0].User)
+	assert.Equal(t, 2, received[0].GPUID)
+	assert.Equal(t, "gpu-server-1", received[0].Host)
+
+	status = http.StatusServiceUnavailable
+	err := sink.Send(context.Background(), record)
+	assert.ErrorContains(t, err, "503")
+}
+
+func TestHTTPUsageSinkTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := NewHTTPUsageSink(types.UsageSinkConfig{URL: server.URL, Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	err := sink.Send(context.Background(), &types.UsageRecord{User: "alice"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// fakeUsageSink records what it is sent and fails while failing is set
+type fakeUsageSink struct {
+	sent    []string
+	failing bool
+}
+
+func (s *fakeUsageSink) Send(ctx context.Context, record *types.UsageRecord) error {
+	if s.failing {
+		return fmt.Errorf("sink unavailable")
+	}
+	s.sent = append(s.sent, record.User)
+	return nil
+}
+
+func TestClient_UsageSinkBuffer(t *testing.T) {
+	client := setupTestRedis(t)
+	ctx := context.Background()
+
+	sink := &fakeUsageSink{failing: true}
+	client.SetUsageSink(sink)
+	client.config.UsageSink.Buffer = true
+
+	record := func(user string) *types.UsageRecord {
+		end := time.Now().Add(-time.Hour)
+		return &types.UsageRecord{
+			User:            user,
+			StartTime:       types.FlexibleTime{Time: end.Add(-time.Hour)},
+			EndTime:         types.FlexibleTime{Time: end},
+			Duration:        3600,
+			ReservationType: types.ReservationTypeManual,
+		}
+	}
+
+	// Failures don't fail the release; the records are buffered
+	require.NoError(t, client.RecordUsageHistory(ctx, record("alice")))
+	require.NoError(t, client.RecordUsageHistory(ctx, record("bob")))
+	assert.Empty(t, sink.sent)
+	buffered, err := client.rdb.LLen(ctx, types.RedisKeyUsageSinkBuffer).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), buffered)
+
+	// The next successful send resends the buffer, oldest first
+	sink.failing = false
+	require.NoError(t, client.RecordUsageHistory(ctx, record("carol")))
+	assert.Equal(t, []string{"carol", "alice", "bob"}, sink.sent)
+	buffered, err = client.rdb.LLen(ctx, types.RedisKeyUsageSinkBuffer).Result()
+	require.NoError(t, err)
+	assert.Zero(t, buffered)
+
+	// Without buffering, failed records are only logged
+	client.config.UsageSink.Buffer = false
+	sink.failing = true
+	require.NoError(t, client.RecordUsageHistory(ctx, record("dave")))
+	buffered, err = client.rdb.LLen(ctx, types.RedisKeyUsageSinkBuffer).Result()
+	require.NoError(t, err)
+	assert.Zero(t, buffered)
+
+	// Redis keeps every record regardless
+	records, err := client.GetUsageHistory(ctx, time.Now().Add(-3*time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Len(t, records, 4)
+}
@@ -2,7 +2,9 @@ package types
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,6 +19,83 @@ type GPUState struct {
 	LastReleased   FlexibleTime `json:"last_released,omitempty"`
 	Note           string       `json:"note,omitempty"`
 	PartialQueueID string       `json:"partial_queue_id,omitempty"` // Queue entry ID for partial allocations
+	Source         string       `json:"source,omitempty"`           // How the reservation was created: "run", "reserve", or "adopted"
+	Priority       string       `json:"priority,omitempty"`         // Reservation priority: "low", "normal", or "high" (empty = normal)
+	PreemptedUser  string       `json:"preempted_user,omitempty"`   // User whose idle reservation was preempted to create this one
+	PreemptNotice  bool         `json:"preempt_notice,omitempty"`   // Set until the daemon has told PreemptedUser that their manual reservation was preempted
+	PID            int          `json:"pid,omitempty"`              // PID of the process holding a run reservation
+	Account        string       `json:"account,omitempty"`          // Team account the usage is billed to
+	RenewDuration  int64        `json:"renew_duration,omitempty"`   // Seconds each keepalive extends a renewable manual reservation by (0 = not renewable)
+	JobID          string       `json:"job_id,omitempty"`           // Shared by all GPUs reserved by the same request
+	Command        string       `json:"command,omitempty"`          // Command line of a run reservation, with secret flag values redacted
+	Shares         []GPUShare   `json:"shares,omitempty"`           // Holders of a shared GPU
+	Annotation     string       `json:"annotation,omitempty"`       // Note left on the reservation with 'admin --annotate', cleared on release
+	AnnotatedBy    string       `json:"annotated_by,omitempty"`     // User who left the annotation
+	AnnotatedAt    FlexibleTime `json:"annotated_at,omitempty"`     // When the annotation was left
+	RemindBefore   int64        `json:"remind_before,omitempty"`    // Seconds before expiry the daemon reminds the user of a manual reservation (0 = no reminder)
+	Reminded       bool         `json:"reminded,omitempty"`         // Set once the daemon has sent the reminder
+	LastCheckpoint FlexibleTime `json:"last_checkpoint,omitempty"`  // When the usage of a long run reservation was last checkpointed to the usage history
+}
+
+// GPUShare is one holder's reservation of a shared GPU
+type GPUShare struct {
+	User       string       `json:"user"`
+	ActualUser string       `json:"actual_user,omitempty"`
+	StartTime  FlexibleTime `json:"start_time"`
+	ExpiryTime FlexibleTime `json:"expiry_time"`
+	Note       string       `json:"note,omitempty"`
+	Account    string       `json:"account,omitempty"`
+}
+
+// IsShared reports whether the state is a GPU shared by several holders
+func (s *GPUState) IsShared() bool {
+	return s.Type == ReservationTypeShared
+}
+
+// ShareOf returns the index of user's share of a shared GPU, or -1 if user
+// doesn't hold one
+func (s *GPUState) ShareOf(user string) int {
+	for i, share := range s.Shares {
+		if share.User == user {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsRenewable reports whether the state is a manual reservation kept alive
+// by 'canhazgpu keepalive'
+func (s *GPUState) IsRenewable() bool {
+	return s.Type == ReservationTypeManual && s.RenewDuration > 0
+}
+
+// UsageStart returns when the usage not yet in the usage history began: the
+// last checkpoint of a long run reservation, or else its start
+func (s *GPUState) UsageStart() FlexibleTime {
+	if !s.LastCheckpoint.IsZero() {
+		return s.LastCheckpoint
+	}
+	return s.StartTime
+}
+
+// NewUsageRecord returns the usage record of the reservation in state up to
+// end. A reservation that has been checkpointed only gets a record for the
+// time since its last checkpoint, which the earlier records don't cover.
+func NewUsageRecord(gpuID int, state *GPUState, end time.Time) *UsageRecord {
+	start := state.UsageStart()
+	record := &UsageRecord{
+		User:            state.User,
+		GPUID:           gpuID,
+		StartTime:       start,
+		EndTime:         FlexibleTime{Time: end},
+		Duration:        end.Sub(start.ToTime()).Seconds(),
+		ReservationType: state.Type,
+		Account:         state.Account,
+	}
+	if !state.LastCheckpoint.IsZero() {
+		record.ReservationStart = state.StartTime
+	}
+	return record
 }
 
 // FlexibleTime handles both Unix timestamps and RFC3339 time strings
@@ -76,6 +155,37 @@ type GPUUsage struct {
 	Users     map[string]bool  `json:"users"`
 	Provider  string           `json:"provider"` // "nvidia" or "amd"
 	Model     string           `json:"model"`    // GPU model name (e.g., "H100", "RTX 4090") or "AMD"
+	UUID      string           `json:"uuid,omitempty"`
+
+	// Utilization is the percentage of time the GPU was computing over the
+	// last sample period, or nil if the provider doesn't report it
+	Utilization *int `json:"utilization,omitempty"`
+
+	// Unconfirmed is set when the GPU is above the memory threshold but
+	// unreserved usage must persist across samples and hasn't yet
+	Unconfirmed bool `json:"unconfirmed,omitempty"`
+
+	// IgnoredMemoryMB is the memory used by processes that don't count
+	// toward unreserved usage (see Config.IgnoreUsers and IgnoreProcesses)
+	IgnoredMemoryMB int `json:"ignored_memory_mb,omitempty"`
+
+	// TotalMemoryMB is the GPU's total memory, or 0 if the provider doesn't
+	// report it
+	TotalMemoryMB int `json:"total_memory_mb,omitempty"`
+}
+
+// GPUTelemetry is the fan speed and clocks of a GPU, for thermal and
+// overclocking debugging. Values the provider doesn't report are nil.
+type GPUTelemetry struct {
+	FanSpeed    *int `json:"fan_speed,omitempty"`     // Percent of the maximum fan speed
+	SMClockMHz  *int `json:"sm_clock_mhz,omitempty"`  // Current SM (graphics) clock
+	MemClockMHz *int `json:"mem_clock_mhz,omitempty"` // Current memory clock
+}
+
+// UnreservedMemoryMB returns the memory in use that counts toward unreserved
+// usage, leaving out the memory of ignored processes
+func (u *GPUUsage) UnreservedMemoryMB() int {
+	return max(u.MemoryMB-u.IgnoredMemoryMB, 0)
 }
 
 // GPUProcessInfo represents a process using a GPU
@@ -96,6 +206,36 @@ type AllocationRequest struct {
 	ExpiryTime      *time.Time
 	Force           bool   // If true, allow reserving GPUs that are in unreserved use
 	Note            string // Optional note describing the reservation purpose
+	Source          string // How the reservation was created (see ReservationSource* constants)
+	Priority        string // Reservation priority (see Priority* constants, empty = normal)
+	Preempt         bool   // If true, preempt idle lower-priority reservations when GPUs are unavailable
+	Account         string // Team account the usage is billed to (empty = none)
+	AllAvailable    bool   // If true, allocate every GPU that is available at allocation time (GPUCount is ignored)
+	Renewable       bool   // If true, a manual reservation is extended by its duration on each keepalive
+	GPUClass        string // Only allocate GPUs of this memory class (see Config.GPUClasses, empty = any)
+	Command         string // Command line of a run reservation, already redacted
+
+	// RemindBefore has the daemon remind the user this long before a manual
+	// reservation expires (0 = no reminder)
+	RemindBefore time.Duration
+
+	// ClassExcludedGPUs are the GPUs outside GPUClass, set by the allocation
+	// engine from the detected GPU memory
+	ClassExcludedGPUs []int
+
+	// CoolingDownGPUs are the free GPUs still in their cooldown after being
+	// released (see Config.GPUCooldown), and ReleasedAt the Unix time each
+	// free GPU was last released. Both are set by the allocation engine while
+	// it holds the allocation lock.
+	CoolingDownGPUs []int
+	ReleasedAt      map[int]int64
+
+	// MinFreeDuration skips GPUs next to a manual reservation that expires
+	// within it when choosing GPUs by count (0 = no minimum). ExpiresAt is the
+	// Unix time the manual reservation on each GPU expires, set by the
+	// allocation engine while it holds the allocation lock.
+	MinFreeDuration time.Duration
+	ExpiresAt       map[int]int64
 }
 
 // Validate checks if the allocation request is valid
@@ -104,7 +244,11 @@ func (ar *AllocationRequest) Validate() error {
 	hasGPUCount := ar.GPUCount > 0
 	hasGPUIDs := len(ar.GPUIDs) > 0
 
-	if !hasGPUCount && !hasGPUIDs {
+	if ar.AllAvailable {
+		if hasGPUIDs {
+			return fmt.Errorf("cannot request all gpus together with specific gpu ids")
+		}
+	} else if !hasGPUCount && !hasGPUIDs {
 		return fmt.Errorf("either gpu count or specific gpu ids must be specified")
 	}
 
@@ -141,13 +285,58 @@ func (ar *AllocationRequest) Validate() error {
 		return fmt.Errorf("user cannot be empty")
 	}
 
-	if ar.ReservationType != ReservationTypeRun && ar.ReservationType != ReservationTypeManual {
+	switch ar.ReservationType {
+	case ReservationTypeRun, ReservationTypeManual:
+	case ReservationTypeShared:
+		if ar.ExpiryTime == nil {
+			return fmt.Errorf("shared reservations must have an expiry time")
+		}
+	default:
 		return fmt.Errorf("invalid reservation type: %s", ar.ReservationType)
 	}
 
+	if err := ValidatePriority(ar.Priority); err != nil {
+		return err
+	}
+
+	if ar.GPUClass != "" {
+		if hasGPUIDs {
+			return fmt.Errorf("cannot request a gpu class together with specific gpu ids")
+		}
+		if ar.AllAvailable {
+			return fmt.Errorf("cannot request a gpu class together with all gpus")
+		}
+		if ar.ReservationType == ReservationTypeShared {
+			return fmt.Errorf("cannot request a gpu class for a shared reservation")
+		}
+	}
+
 	return nil
 }
 
+// ValidatePriority checks that a priority is one of the known levels.
+// An empty priority is valid and treated as normal.
+func ValidatePriority(priority string) error {
+	switch priority {
+	case "", PriorityLow, PriorityNormal, PriorityHigh:
+		return nil
+	}
+	return fmt.Errorf("invalid priority: %s (must be %s, %s, or %s)", priority, PriorityLow, PriorityNormal, PriorityHigh)
+}
+
+// PriorityRank returns a comparable rank for a priority level, where a higher
+// rank means a higher priority. Empty or unknown priorities rank as normal.
+func PriorityRank(priority string) int {
+	switch priority {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
 // AllocationResult represents the result of a GPU allocation
 type AllocationResult struct {
 	AllocatedGPUs []int
@@ -162,6 +351,26 @@ type UsageRecord struct {
 	EndTime         FlexibleTime `json:"end_time"`
 	Duration        float64      `json:"duration_seconds"`
 	ReservationType string       `json:"reservation_type"`
+	Account         string       `json:"account,omitempty"`
+	Host            string       `json:"host,omitempty"`      // Host the GPU belongs to; empty in records from older versions
+	GPUModel        string       `json:"gpu_model,omitempty"` // Model of the GPU (e.g., "H100 80GB HBM3"); empty if it wasn't known
+	// Checkpoint marks a record written periodically while a long run
+	// reservation is still held. Each checkpoint covers the time since the
+	// previous one, and the record written on release the time since the last.
+	Checkpoint bool `json:"checkpoint,omitempty"`
+	// ReservationStart is when a checkpointed reservation began, since its
+	// records' StartTime is the previous checkpoint
+	ReservationStart FlexibleTime `json:"reservation_start,omitempty"`
+}
+
+// ReservationDuration returns how long the reservation had been held when
+// the record was written, which for a checkpointed reservation is more than
+// the record's own Duration
+func (r *UsageRecord) ReservationDuration() float64 {
+	if r.ReservationStart.IsZero() {
+		return r.Duration
+	}
+	return r.EndTime.ToTime().Sub(r.ReservationStart.ToTime()).Seconds()
 }
 
 // Config represents the application configuration
@@ -169,8 +378,259 @@ type Config struct {
 	RedisHost       string
 	RedisPort       int
 	RedisDB         int
+	RedisReadHost   string // Optional read replica for status/report queries (empty = use primary)
+	RedisReadPort   int    // Read replica port (0 = same as RedisPort)
 	MemoryThreshold int
-	RemoteHosts     []string // SSH addresses (can use ~/.ssh/config entries for friendly names)
+	RemoteHosts     []RemoteHost // Hosts shown by --all and reachable with --remote
+
+	// AllGPUsExcludeUnreserved makes requests for all GPUs skip GPUs in use
+	// without a reservation instead of failing
+	AllGPUsExcludeUnreserved bool
+
+	// UseGPUUUIDs keys GPU state by UUID instead of index, and GPUUUIDs maps
+	// each GPU's current index to its UUID
+	UseGPUUUIDs bool
+	GPUUUIDs    []string
+
+	// ConfirmUnreservedUsage only reports a GPU as in unreserved use once it
+	// has been above the memory threshold in two consecutive samples
+	ConfirmUnreservedUsage bool
+
+	// GPUCooldown keeps a released GPU from being allocated again until it
+	// has been free this long, giving drivers time to reset (0 = disabled)
+	GPUCooldown time.Duration
+
+	// RedisDialTimeout, RedisReadTimeout, and RedisWriteTimeout bound how
+	// long connecting to Redis and each command may take, so that commands
+	// fail instead of hanging on an unresponsive server (0 = RedisTimeout)
+	RedisDialTimeout  time.Duration
+	RedisReadTimeout  time.Duration
+	RedisWriteTimeout time.Duration
+
+	// LockTimeout is how long the allocation lock is held before it expires
+	// and LockMaxRetries how many attempts are made to acquire it (0 = use
+	// the LockTimeout and MaxLockRetries defaults)
+	LockTimeout    time.Duration
+	LockMaxRetries int
+
+	// UsageSink forwards usage records to an external endpoint as they are
+	// recorded (empty URL = disabled)
+	UsageSink UsageSinkConfig
+
+	// DisableUsageHistory stops usage records from being kept or forwarded
+	// to the usage sink. Allocation by count then ignores who used a GPU
+	// before and ranks GPUs by global LRU only.
+	DisableUsageHistory bool
+
+	// NvidiaSMIPath and AMDSMIPath are the nvidia-smi and amd-smi binaries to
+	// run (empty = look up on PATH)
+	NvidiaSMIPath string
+	AMDSMIPath    string
+
+	// MaxSharesPerGPU is how many shared reservations one GPU can hold at
+	// once (0 = DefaultMaxSharesPerGPU)
+	MaxSharesPerGPU int
+
+	// MaxQueueLength caps how many entries may wait in the queue, and
+	// MaxQueueEntriesPerUser how many of them one user may have (0 =
+	// unlimited)
+	MaxQueueLength         int
+	MaxQueueEntriesPerUser int
+
+	// GPUHourWeights maps GPU model name patterns to how much an hour on a
+	// GPU of that model counts for in weighted GPU hours. Patterns are
+	// matched case-insensitively against the model, the longest match wins,
+	// and unmatched models weigh 1.
+	GPUHourWeights map[string]float64
+
+	// ReminderWebhook is where the daemon POSTs reminders before manual
+	// reservations made with --remind-before expire (empty URL = disabled)
+	ReminderWebhook WebhookConfig
+
+	// GPUClasses maps the names accepted by --gpu-class to the memory range
+	// of the GPUs in each class (nil = DefaultGPUClasses)
+	GPUClasses map[string]GPUClass
+
+	// IgnoreUsers and IgnoreProcesses name the users and process names whose
+	// GPU memory doesn't count toward unreserved usage, e.g. system daemons
+	// like Xorg or the DCGM exporter
+	IgnoreUsers     []string
+	IgnoreProcesses []string
+
+	// ProcessOwners maps OS accounts, such as the service account of a
+	// systemd unit, to the person or team responsible for their GPU use.
+	// Keys are lower case.
+	ProcessOwners map[string]string
+
+	// CommandRedactFlags are the flags whose values are replaced by "***"
+	// in the command line stored with a run reservation, in addition to
+	// DefaultCommandRedactFlags
+	CommandRedactFlags []string
+}
+
+// RemoteHost is a remote_hosts entry. Only Host is required; the other
+// fields override the SSH and Redis settings used for that host.
+type RemoteHost struct {
+	Host      string // SSH address (can use ~/.ssh/config entries for friendly names)
+	SSHUser   string // SSH login user (empty = from ~/.ssh/config or the local user)
+	SSHPort   int    // SSH port (0 = from ~/.ssh/config or 22)
+	RedisPort int    // Redis port for canhazgpu on the host (0 = the host's own config)
+}
+
+// RemoteHostNames returns the Host of each remote host, in order
+func (c *Config) RemoteHostNames() []string {
+	names := make([]string, len(c.RemoteHosts))
+	for i, host := range c.RemoteHosts {
+		names[i] = host.Host
+	}
+	return names
+}
+
+// LookupRemoteHost returns the remote_hosts entry for host, or an entry with
+// no overrides if host isn't configured, e.g. for 'status --remote' to any
+// SSH address
+func (c *Config) LookupRemoteHost(host string) RemoteHost {
+	for _, remote := range c.RemoteHosts {
+		if remote.Host == host {
+			return remote
+		}
+	}
+	return RemoteHost{Host: host}
+}
+
+// ProcessOwner returns who is responsible for GPU use by the OS account user,
+// from ProcessOwners, or user itself if it isn't mapped
+func (c *Config) ProcessOwner(user string) string {
+	if owner, ok := c.ProcessOwners[strings.ToLower(user)]; ok {
+		return owner
+	}
+	return user
+}
+
+// RedisTimeouts returns the Redis dial, read, and write timeouts, using
+// RedisTimeout for any that aren't set
+func (c *Config) RedisTimeouts() (dial, read, write time.Duration) {
+	orDefault := func(timeout time.Duration) time.Duration {
+		if timeout <= 0 {
+			return RedisTimeout
+		}
+		return timeout
+	}
+	return orDefault(c.RedisDialTimeout), orDefault(c.RedisReadTimeout), orDefault(c.RedisWriteTimeout)
+}
+
+// GPUClass is a range of total GPU memory that --gpu-class can select
+type GPUClass struct {
+	MinMemoryMB int // Smallest total memory in the class
+	MaxMemoryMB int // Total memory the class stays below (0 = no limit)
+}
+
+// Matches reports whether a GPU with this much total memory is in the class
+func (c GPUClass) Matches(totalMemoryMB int) bool {
+	return totalMemoryMB >= c.MinMemoryMB && (c.MaxMemoryMB == 0 || totalMemoryMB < c.MaxMemoryMB)
+}
+
+// String describes the memory range of the class, e.g. "at least 40000MB"
+func (c GPUClass) String() string {
+	switch {
+	case c.MaxMemoryMB == 0:
+		return fmt.Sprintf("at least %dMB", c.MinMemoryMB)
+	case c.MinMemoryMB == 0:
+		return fmt.Sprintf("less than %dMB", c.MaxMemoryMB)
+	default:
+		return fmt.Sprintf("%dMB to less than %dMB", c.MinMemoryMB, c.MaxMemoryMB)
+	}
+}
+
+// DefaultGPUClasses returns the GPU classes used unless gpu_classes is set.
+// 40000MB separates 24GB and 32GB cards from 40GB and larger data center GPUs.
+func DefaultGPUClasses() map[string]GPUClass {
+	return map[string]GPUClass{
+		GPUClassSmall: {MaxMemoryMB: DefaultGPUClassBoundaryMB},
+		GPUClassLarge: {MinMemoryMB: DefaultGPUClassBoundaryMB},
+	}
+}
+
+// DefaultCommandRedactFlags returns the flags whose values are always redacted
+// from the command line stored with a run reservation
+func DefaultCommandRedactFlags() []string {
+	return []string{
+		"--token", "--access-token", "--auth-token", "--hf-token",
+		"--api-key", "--api_key", "--password", "--secret",
+	}
+}
+
+// UsageSinkConfig configures where usage records are forwarded for long-term
+// storage outside Redis
+type UsageSinkConfig struct {
+	URL     string            // HTTP(S) endpoint each record is POSTed to as JSON
+	Timeout time.Duration     // Per-request timeout (0 = UsageSinkTimeout)
+	Headers map[string]string // Extra request headers, e.g. for authentication
+	Buffer  bool              // Keep records that failed to send in Redis and retry them later
+}
+
+// WebhookConfig configures an HTTP endpoint that notifications are POSTed to
+type WebhookConfig struct {
+	URL     string            // HTTP(S) endpoint each notification is POSTed to as JSON
+	Headers map[string]string // Extra request headers, e.g. for authentication
+}
+
+// ValidateLockSettings checks an allocation lock timeout and retry count
+func ValidateLockSettings(timeout time.Duration, retries int) error {
+	if timeout < MinLockTimeout || timeout > MaxLockTimeout {
+		return fmt.Errorf("lock timeout must be between %s and %s, got %s", MinLockTimeout, MaxLockTimeout, timeout)
+	}
+	if retries < 1 || retries > MaxLockRetriesLimit {
+		return fmt.Errorf("lock retries must be between 1 and %d, got %d", MaxLockRetriesLimit, retries)
+	}
+	return nil
+}
+
+// ValidateHeartbeatTimeout checks a heartbeat-timeout pool setting
+func ValidateHeartbeatTimeout(timeout time.Duration) error {
+	if timeout < MinHeartbeatTimeout || timeout > MaxHeartbeatTimeout {
+		return fmt.Errorf("heartbeat timeout must be between %s and %s, got %s", MinHeartbeatTimeout, MaxHeartbeatTimeout, timeout)
+	}
+	return nil
+}
+
+// AllocationTrace records how the most recent allocation for a user was
+// decided. It is written by the allocation scripts and read by
+// 'canhazgpu explain-last'.
+type AllocationTrace struct {
+	Time         FlexibleTime          `json:"time"`
+	User         string                `json:"user"`
+	Requested    int                   `json:"requested,omitempty"`     // Number of GPUs requested by count
+	RequestedIDs []int                 `json:"requested_ids,omitempty"` // Specific GPU IDs requested
+	Candidates   []AllocationCandidate `json:"candidates,omitempty"`    // Available GPUs, best first
+	Unreserved   []int                 `json:"unreserved,omitempty"`    // Excluded: in use without reservation
+	Reserved     []int                 `json:"reserved,omitempty"`      // Excluded: already reserved
+	CoolingDown  []int                 `json:"cooling_down,omitempty"`  // Excluded: released within the cooldown
+	OtherClass   []int                 `json:"other_class,omitempty"`   // Excluded: not in the requested GPU class
+	NearExpiring []int                 `json:"near_expiring,omitempty"` // Excluded: next to a reservation expiring within the minimum free duration
+	GPUClass     string                `json:"gpu_class,omitempty"`     // GPU class requested with --gpu-class
+	Allocated    []int                 `json:"allocated,omitempty"`
+	Error        string                `json:"error,omitempty"`
+}
+
+// AllocationLatencyBuckets are the upper bounds in seconds of the allocation
+// latency histogram kept in RedisKeyAllocationLatency
+var AllocationLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// AllocationLatencyStats is the histogram of how long successful allocations
+// took, from the start of the request to the GPUs being reserved
+type AllocationLatencyStats struct {
+	Count   int64   // Number of allocations
+	Sum     float64 // Total latency in seconds
+	Buckets []int64 // Cumulative count at or below each of AllocationLatencyBuckets
+}
+
+// AllocationCandidate is an available GPU considered for an allocation by
+// count, with the scores used to rank it
+type AllocationCandidate struct {
+	GPUID        int     `json:"gpu_id"`
+	UserLastUsed float64 `json:"user_last_used"` // Unix time the user last used the GPU (MRU), 0 if never
+	LastReleased float64 `json:"last_released"`  // Unix time the GPU was last released (LRU), 0 if never
 }
 
 // QueueEntry represents a request waiting in the queue for GPUs
@@ -184,6 +644,12 @@ type QueueEntry struct {
 	ReservationType string        `json:"reservation_type"`
 	ExpiryDuration  time.Duration `json:"expiry_duration,omitempty"`
 	Note            string        `json:"note,omitempty"`
+	Source          string        `json:"source,omitempty"`
+	Priority        string        `json:"priority,omitempty"`
+	Account         string        `json:"account,omitempty"`
+	Renewable       bool          `json:"renewable,omitempty"`
+	RemindBefore    time.Duration `json:"remind_before,omitempty"`
+	Command         string        `json:"command,omitempty"`
 	EnqueueTime     FlexibleTime  `json:"enqueue_time"`
 	LastHeartbeat   FlexibleTime  `json:"last_heartbeat"`
 	WaitTimeout     *FlexibleTime `json:"wait_timeout,omitempty"`
@@ -202,36 +668,158 @@ func (qe *QueueEntry) IsComplete() bool {
 	return len(qe.AllocatedGPUs) >= qe.GetRequestedGPUCount()
 }
 
+// ScheduledReservation is a manual reservation of specific GPUs for a future
+// time window, made with 'canhazgpu schedule'. The daemon reserves the GPUs
+// when the window starts, and the reservation expires when it ends.
+type ScheduledReservation struct {
+	ID         string       `json:"id"`
+	User       string       `json:"user"`
+	ActualUser string       `json:"actual_user"`
+	GPUIDs     []int        `json:"gpu_ids"`
+	StartTime  FlexibleTime `json:"start_time"`
+	EndTime    FlexibleTime `json:"end_time"`
+	Note       string       `json:"note,omitempty"`
+	Account    string       `json:"account,omitempty"`
+	CreatedAt  FlexibleTime `json:"created_at"`
+	Status     string       `json:"status"`             // See ScheduleStatus* constants
+	Conflict   string       `json:"conflict,omitempty"` // Why the GPUs couldn't be reserved when the window started
+}
+
+// Overlaps reports whether two scheduled windows share a GPU at the same time
+func (s *ScheduledReservation) Overlaps(other *ScheduledReservation) bool {
+	if !s.StartTime.Before(other.EndTime.Time) || !other.StartTime.Before(s.EndTime.Time) {
+		return false
+	}
+	for _, gpuID := range s.GPUIDs {
+		if slices.Contains(other.GPUIDs, gpuID) {
+			return true
+		}
+	}
+	return false
+}
+
 // QueueStatus represents the current queue status for display
 type QueueStatus struct {
 	Entries            []*QueueEntry `json:"entries"`
 	TotalWaiting       int           `json:"total_waiting"`
 	TotalGPUsRequested int           `json:"total_gpus_requested"`
 	TotalGPUsAllocated int           `json:"total_gpus_allocated"`
+
+	// EstimatedWaits is a rough estimate of how much longer each entry will
+	// wait, by entry ID. Entries without an estimate are missing.
+	EstimatedWaits map[string]time.Duration `json:"estimated_waits,omitempty"`
+
+	// Limits enforced when entries are added (0 = unlimited)
+	MaxQueueLength         int `json:"max_queue_length,omitempty"`
+	MaxQueueEntriesPerUser int `json:"max_queue_entries_per_user,omitempty"`
 }
 
 // Constants
 const (
 	ReservationTypeRun    = "run"
 	ReservationTypeManual = "manual"
-
-	RedisKeyPrefix         = "canhazgpu:"
-	RedisKeyGPUCount       = RedisKeyPrefix + "gpu_count"
-	RedisKeyProvider       = RedisKeyPrefix + "provider"
-	RedisKeyAllocationLock = RedisKeyPrefix + "allocation_lock"
-	RedisKeyUsageHistory   = RedisKeyPrefix + "usage_history:"
-	RedisKeyQueue          = RedisKeyPrefix + "queue"
-	RedisKeyQueueEntry     = RedisKeyPrefix + "queue:entry:"
+	ReservationTypeShared = "shared" // Manual reservation of a GPU that others may reserve too
+
+	// SharedGPUUser is the user recorded on a shared GPU, so that it is never
+	// free for exclusive reservations. Its holders are listed in Shares.
+	SharedGPUUser = "(shared)"
+
+	// DefaultMaxSharesPerGPU is how many shared reservations one GPU can hold
+	// unless max_shares_per_gpu is set
+	DefaultMaxSharesPerGPU = 2
+
+	// Built-in GPU classes for --gpu-class, split at DefaultGPUClassBoundaryMB
+	// of total memory
+	GPUClassSmall             = "small"
+	GPUClassLarge             = "large"
+	DefaultGPUClassBoundaryMB = 40000
+
+	// Reservation sources record how a reservation was created
+	ReservationSourceRun      = "run"      // Created by 'canhazgpu run'
+	ReservationSourceReserve  = "reserve"  // Created by 'canhazgpu reserve'
+	ReservationSourceAdopted  = "adopted"  // Created with --force over GPUs already in unreserved use
+	ReservationSourceSchedule = "schedule" // Created by the daemon from a 'canhazgpu schedule' window
+
+	// Scheduled reservation states
+	ScheduleStatusPending  = "pending"  // Waiting for the window to start
+	ScheduleStatusActive   = "active"   // The GPUs are reserved
+	ScheduleStatusConflict = "conflict" // The window started but the GPUs were in use; retried until it ends
+
+	// Reservation priorities, used to decide which reservations may be preempted
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+
+	RedisKeyPrefix            = "canhazgpu:"
+	RedisKeyGPUCount          = RedisKeyPrefix + "gpu_count"
+	RedisKeyProvider          = RedisKeyPrefix + "provider"
+	RedisKeyAllocationLock    = RedisKeyPrefix + "allocation_lock"
+	RedisKeyDaemonLock        = RedisKeyPrefix + "daemon_lock"
+	RedisKeyUsageHistory      = RedisKeyPrefix + "usage_history:"
+	RedisKeyQueue             = RedisKeyPrefix + "queue"
+	RedisKeyQueueEntry        = RedisKeyPrefix + "queue:entry:"
+	RedisKeyAllocationTrace   = RedisKeyPrefix + "allocation_trace:"
+	RedisKeyUnreservedSamples = RedisKeyPrefix + "unreserved_samples"
+	RedisKeyUsageSinkBuffer   = RedisKeyPrefix + "usage_sink_buffer"
+	RedisKeySettings          = RedisKeyPrefix + "settings"
+	RedisKeyBootID            = RedisKeyPrefix + "boot_id"
+	RedisKeyGPUModels         = RedisKeyPrefix + "gpu_models"
+	RedisKeySchedule          = RedisKeyPrefix + "schedule"
+	RedisKeyAllocationLatency = RedisKeyPrefix + "allocation_latency"
+
+	// Pool-wide settings stored in RedisKeySettings by 'admin --set'
+	SettingHeartbeatTimeout = "heartbeat-timeout"
 
 	HeartbeatInterval   = 60 * time.Second
+	HeartbeatJitter     = 6 * time.Second // Heartbeats are sent HeartbeatInterval ± HeartbeatJitter apart
 	HeartbeatTimeout    = 5 * time.Minute
 	HealthCheckInterval = 15 * time.Second
+	RedisTimeout        = 5 * time.Second // Default Redis dial, read, and write timeout
 	LockTimeout         = 10 * time.Second
 	MaxLockRetries      = 5
 
+	// Limits for the lock_timeout and lock_max_retries config options. The
+	// backoff between attempts doubles each time, so the retry limit keeps the
+	// total wait to about 17 minutes.
+	MinLockTimeout      = 1 * time.Second
+	MaxLockTimeout      = 5 * time.Minute
+	MaxLockRetriesLimit = 10
+
+	// Limits for the heartbeat-timeout pool setting. The minimum leaves room
+	// for a missed heartbeat and its retries.
+	MinHeartbeatTimeout = 2 * time.Minute
+	MaxHeartbeatTimeout = 24 * time.Hour
+
+	// MinRenewDuration is the shortest renewable reservation, for the same
+	// reason as MinHeartbeatTimeout
+	MinRenewDuration = 2 * time.Minute
+
 	QueueHeartbeatInterval = 30 * time.Second
 	QueueHeartbeatTimeout  = 2 * time.Minute
 	QueuePollInterval      = 2 * time.Second
 
+	// PreemptionMinAge is how long a reservation must have been held before it
+	// can be preempted, giving new jobs time to start using their GPUs
+	PreemptionMinAge = 10 * time.Minute
+
+	// AllocationTraceTTL is how long the trace of a user's last allocation is
+	// kept for 'canhazgpu explain-last'
+	AllocationTraceTTL = 7 * 24 * time.Hour
+
+	// UsageCheckpointInterval is how often the heartbeat of a run reservation
+	// writes a checkpoint record to the usage history, so that reports cover
+	// long jobs before they finish
+	UsageCheckpointInterval = 24 * time.Hour
+
+	// UsageSinkTimeout is the default timeout for sending a usage record to
+	// the usage sink, and UsageSinkBufferLimit the number of unsent records
+	// kept for a later attempt (oldest are dropped first)
+	UsageSinkTimeout     = 5 * time.Second
+	UsageSinkBufferLimit = 10000
+
+	// ReminderWebhookTimeout is the timeout for posting a reminder to the
+	// reminder webhook
+	ReminderWebhookTimeout = 10 * time.Second
+
 	MemoryThresholdMB = 1024
 )
@@ -92,6 +92,7 @@ func TestGPUState_JSONSerialization(t *testing.T) {
 		Type:          "run",
 		ExpiryTime:    FlexibleTime{Time: originalTime.Add(time.Hour)},
 		LastReleased:  FlexibleTime{Time: originalTime.Add(-time.Hour)},
+		Source:        ReservationSourceAdopted,
 	}
 
 	// Marshal to JSON
@@ -106,6 +107,7 @@ func TestGPUState_JSONSerialization(t *testing.T) {
 	// Verify all fields
 	assert.Equal(t, state.User, restored.User)
 	assert.Equal(t, state.Type, restored.Type)
+	assert.Equal(t, state.Source, restored.Source)
 	assert.True(t, state.StartTime.ToTime().Equal(restored.StartTime.ToTime()))
 	assert.True(t, state.LastHeartbeat.ToTime().Equal(restored.LastHeartbeat.ToTime()))
 	assert.True(t, state.LastReleased.ToTime().Equal(restored.LastReleased.ToTime()))
@@ -249,6 +251,82 @@ func TestAllocationRequest_Validation(t *testing.T) {
 			},
 			valid: true,
 		},
+		{
+			name: "Valid request for all available GPUs",
+			request: &AllocationRequest{
+				AllAvailable:    true,
+				User:            "testuser",
+				ReservationType: "manual",
+			},
+			valid: true,
+		},
+		{
+			name: "Invalid - all available GPUs with GPU IDs",
+			request: &AllocationRequest{
+				AllAvailable:    true,
+				GPUIDs:          []int{0, 1},
+				User:            "testuser",
+				ReservationType: "run",
+			},
+			valid: false,
+		},
+		{
+			name: "Valid shared-type request",
+			request: &AllocationRequest{
+				GPUCount:        1,
+				User:            "testuser",
+				ReservationType: "shared",
+				ExpiryTime:      &time.Time{},
+			},
+			valid: true,
+		},
+		{
+			name: "Invalid - shared-type request without expiry",
+			request: &AllocationRequest{
+				GPUCount:        1,
+				User:            "testuser",
+				ReservationType: "shared",
+			},
+			valid: false,
+		},
+		{
+			name: "Invalid - all available GPUs without user",
+			request: &AllocationRequest{
+				AllAvailable:    true,
+				ReservationType: "run",
+			},
+			valid: false,
+		},
+		{
+			name: "Valid gpu class request",
+			request: &AllocationRequest{
+				GPUCount:        2,
+				User:            "testuser",
+				ReservationType: "run",
+				GPUClass:        GPUClassLarge,
+			},
+			valid: true,
+		},
+		{
+			name: "Invalid - gpu class with specific GPU IDs",
+			request: &AllocationRequest{
+				GPUIDs:          []int{1},
+				User:            "testuser",
+				ReservationType: "run",
+				GPUClass:        GPUClassLarge,
+			},
+			valid: false,
+		},
+		{
+			name: "Invalid - gpu class with all available GPUs",
+			request: &AllocationRequest{
+				AllAvailable:    true,
+				User:            "testuser",
+				ReservationType: "run",
+				GPUClass:        GPUClassSmall,
+			},
+			valid: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -263,6 +341,75 @@ func TestAllocationRequest_Validation(t *testing.T) {
 	}
 }
 
+func TestGPUClass(t *testing.T) {
+	classes := DefaultGPUClasses()
+	small, large := classes[GPUClassSmall], classes[GPUClassLarge]
+
+	// 24GB and 32GB cards are small, 40GB and larger are large
+	assert.True(t, small.Matches(24576))
+	assert.True(t, small.Matches(32768))
+	assert.False(t, small.Matches(40960))
+	assert.False(t, large.Matches(32768))
+	assert.True(t, large.Matches(40960))
+	assert.True(t, large.Matches(81559))
+
+	mid := GPUClass{MinMemoryMB: 40000, MaxMemoryMB: 90000}
+	assert.True(t, mid.Matches(81559))
+	assert.False(t, mid.Matches(196608))
+
+	assert.Equal(t, "less than 40000MB", small.String())
+	assert.Equal(t, "at least 40000MB", large.String())
+	assert.Equal(t, "40000MB to less than 90000MB", mid.String())
+}
+
+func TestPriority(t *testing.T) {
+	assert.NoError(t, ValidatePriority(""))
+	assert.NoError(t, ValidatePriority(PriorityLow))
+	assert.NoError(t, ValidatePriority(PriorityNormal))
+	assert.NoError(t, ValidatePriority(PriorityHigh))
+	assert.Error(t, ValidatePriority("urgent"))
+
+	// Empty priority ranks the same as normal
+	assert.Equal(t, PriorityRank(PriorityNormal), PriorityRank(""))
+	assert.Less(t, PriorityRank(PriorityLow), PriorityRank(PriorityNormal))
+	assert.Less(t, PriorityRank(PriorityNormal), PriorityRank(PriorityHigh))
+
+	request := &AllocationRequest{
+		GPUCount:        1,
+		User:            "testuser",
+		ReservationType: ReservationTypeRun,
+		Priority:        "urgent",
+	}
+	assert.Error(t, request.Validate())
+}
+
+func TestScheduledReservation_Overlaps(t *testing.T) {
+	start := time.Date(2026, 3, 4, 22, 0, 0, 0, time.UTC)
+	window := func(gpuIDs []int, from, to time.Duration) *ScheduledReservation {
+		return &ScheduledReservation{
+			GPUIDs:    gpuIDs,
+			StartTime: FlexibleTime{Time: start.Add(from)},
+			EndTime:   FlexibleTime{Time: start.Add(to)},
+		}
+	}
+
+	night := window([]int{0, 1}, 0, 8*time.Hour)
+	assert.True(t, night.Overlaps(window([]int{1, 2}, 7*time.Hour, 9*time.Hour)))
+	assert.True(t, night.Overlaps(window([]int{0}, time.Hour, 2*time.Hour)))
+	assert.False(t, night.Overlaps(window([]int{2, 3}, 0, 8*time.Hour)), "different GPUs")
+	assert.False(t, night.Overlaps(window([]int{0}, 8*time.Hour, 9*time.Hour)), "starts when the other ends")
+	assert.False(t, night.Overlaps(window([]int{0}, -time.Hour, 0)), "ends when the other starts")
+}
+
+func TestConfig_ProcessOwner(t *testing.T) {
+	config := &Config{ProcessOwners: map[string]string{"svc-vllm": "alice"}}
+	assert.Equal(t, "alice", config.ProcessOwner("svc-vllm"))
+	assert.Equal(t, "alice", config.ProcessOwner("SVC-VLLM"))
+	assert.Equal(t, "bob", config.ProcessOwner("bob"))
+
+	assert.Equal(t, "bob", (&Config{}).ProcessOwner("bob"))
+}
+
 func TestConfig_Defaults(t *testing.T) {
 	config := &Config{}
 
@@ -284,3 +431,14 @@ func TestConstants(t *testing.T) {
 	assert.Equal(t, 10*time.Second, LockTimeout)
 	assert.Equal(t, 5, MaxLockRetries)
 }
+
+func TestValidateLockSettings(t *testing.T) {
+	assert.NoError(t, ValidateLockSettings(LockTimeout, MaxLockRetries))
+	assert.NoError(t, ValidateLockSettings(MinLockTimeout, 1))
+	assert.NoError(t, ValidateLockSettings(MaxLockTimeout, MaxLockRetriesLimit))
+
+	assert.ErrorContains(t, ValidateLockSettings(500*time.Millisecond, 5), "lock timeout must be between")
+	assert.ErrorContains(t, ValidateLockSettings(time.Hour, 5), "lock timeout must be between")
+	assert.ErrorContains(t, ValidateLockSettings(LockTimeout, 0), "lock retries must be between")
+	assert.ErrorContains(t, ValidateLockSettings(LockTimeout, MaxLockRetriesLimit+1), "lock retries must be between")
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"os/user"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/russellb/canhazgpu/internal/types"
 )
 
 // GetUsernameFromUID converts a UID to username
@@ -20,6 +23,16 @@ func GetUsernameFromUID(uid int) (string, error) {
 	return u.Username, nil
 }
 
+// Hostname returns the name of this host, or "unknown" if it can't be
+// determined
+func Hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "unknown"
+	}
+	return hostname
+}
+
 // ParseDuration parses duration strings like "30m", "2h", "1d"
 func ParseDuration(duration string) (time.Duration, error) {
 	if duration == "" {
@@ -175,21 +188,15 @@ func FormatProcessList(processes []string, maxProcesses int) string {
 	return strings.Join(displayed, ", ") + fmt.Sprintf(" and %d more", remaining)
 }
 
+// ShellQuote quotes s as a single word for a POSIX shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // ExecuteRemoteCommand executes a command on a remote host via SSH
 // Returns stdout, stderr, and error
-func ExecuteRemoteCommand(ctx context.Context, host string, command string) (string, string, error) {
-	// Build SSH command
-	// Use -o BatchMode=yes to prevent interactive prompts
-	// Use -o ConnectTimeout=10 to timeout connection attempts
-	sshArgs := []string{
-		"-o", "BatchMode=yes",
-		"-o", "ConnectTimeout=10",
-		"-o", "StrictHostKeyChecking=accept-new",
-		host,
-		command,
-	}
-
-	cmd := exec.CommandContext(ctx, "ssh", sshArgs...)
+func ExecuteRemoteCommand(ctx context.Context, host types.RemoteHost, command string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "ssh", sshArgs(host, command)...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -200,11 +207,38 @@ func ExecuteRemoteCommand(ctx context.Context, host string, command string) (str
 	return stdout.String(), stderr.String(), err
 }
 
+// sshArgs returns the ssh arguments that run command on host, with the
+// host's SSH user and port overrides
+func sshArgs(host types.RemoteHost, command string) []string {
+	// Use -o BatchMode=yes to prevent interactive prompts
+	// Use -o ConnectTimeout=10 to timeout connection attempts
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=10",
+		"-o", "StrictHostKeyChecking=accept-new",
+	}
+	if host.SSHUser != "" {
+		args = append(args, "-l", host.SSHUser)
+	}
+	if host.SSHPort != 0 {
+		args = append(args, "-p", strconv.Itoa(host.SSHPort))
+	}
+	return append(args, host.Host, command)
+}
+
 // ExecuteRemoteCanHazGPU executes a canhazgpu command on a remote host
 // Automatically adds the full path to canhazgpu if needed
-func ExecuteRemoteCanHazGPU(ctx context.Context, host string, args []string) (string, string, error) {
+func ExecuteRemoteCanHazGPU(ctx context.Context, host types.RemoteHost, args []string) (string, string, error) {
 	// Build the command - try to use canhazgpu from PATH
 	// The remote host should have canhazgpu installed
-	command := "canhazgpu " + strings.Join(args, " ")
-	return ExecuteRemoteCommand(ctx, host, command)
+	return ExecuteRemoteCommand(ctx, host, remoteCanHazGPUCommand(host, args))
+}
+
+// remoteCanHazGPUCommand returns the shell command that runs canhazgpu with
+// args on host, pointed at the host's Redis port if it has an override
+func remoteCanHazGPUCommand(host types.RemoteHost, args []string) string {
+	if host.RedisPort != 0 {
+		args = append([]string{"--redis-port", strconv.Itoa(host.RedisPort)}, args...)
+	}
+	return "canhazgpu " + strings.Join(args, " ")
 }
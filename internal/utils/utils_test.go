@@ -1,12 +1,22 @@
 package utils
 
 import (
+	"os"
 	"testing"
 	"time"
 
+	"github.com/russellb/canhazgpu/internal/types"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestHostname(t *testing.T) {
+	expected, err := os.Hostname()
+	if err != nil || expected == "" {
+		expected = "unknown"
+	}
+	assert.Equal(t, expected, Hostname())
+}
+
 func TestParseDuration(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -361,3 +371,24 @@ func TestGetUsernameFromUID(t *testing.T) {
 		})
 	}
 }
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "'/usr/bin/nvidia-smi'", ShellQuote("/usr/bin/nvidia-smi"))
+	assert.Equal(t, `'/opt/it'\''s here/amd-smi'`, ShellQuote("/opt/it's here/amd-smi"))
+}
+
+func TestSSHArgs(t *testing.T) {
+	options := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10", "-o", "StrictHostKeyChecking=accept-new"}
+
+	assert.Equal(t, append(options, "gpu1", "canhazgpu status"),
+		sshArgs(types.RemoteHost{Host: "gpu1"}, "canhazgpu status"))
+	assert.Equal(t, append(options, "-l", "ops", "-p", "2222", "gpu2", "canhazgpu status"),
+		sshArgs(types.RemoteHost{Host: "gpu2", SSHUser: "ops", SSHPort: 2222}, "canhazgpu status"))
+}
+
+func TestRemoteCanHazGPUCommand(t *testing.T) {
+	assert.Equal(t, "canhazgpu status --json",
+		remoteCanHazGPUCommand(types.RemoteHost{Host: "gpu1"}, []string{"status", "--json"}))
+	assert.Equal(t, "canhazgpu --redis-port 6380 status --json",
+		remoteCanHazGPUCommand(types.RemoteHost{Host: "gpu1", RedisPort: 6380}, []string{"status", "--json"}))
+}